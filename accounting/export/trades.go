@@ -0,0 +1,212 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/queries"
+)
+
+// Format is the file format of an export
+type Format string
+
+// supported export formats
+const (
+	FormatCSV       Format = "csv"
+	FormatJSONLines Format = "jsonl"
+	FormatKoinlyCSV Format = "koinly"
+)
+
+const koinlyDateLayout = "2006-01-02 15:04:05 UTC"
+
+// Formats lists the supported formats
+var Formats = []Format{FormatCSV, FormatJSONLines, FormatKoinlyCSV}
+
+// ParseFormat converts a string to a Format
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if s == string(f) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid export format '%s', needs to be one of %v", s, Formats)
+}
+
+// TradeWriter writes exported trades to an output
+type TradeWriter interface {
+	Write(trade queries.ExportedTrade) error
+	// Flush needs to be called after the last trade is written
+	Flush() error
+}
+
+// MakeTradeWriter is a factory method for TradeWriter, it writes the header of the format immediately
+func MakeTradeWriter(format Format, w io.Writer) (TradeWriter, error) {
+	switch format {
+	case FormatCSV:
+		return makeCSVTradeWriter(w, csvHeader, csvRecord)
+	case FormatKoinlyCSV:
+		return makeCSVTradeWriter(w, koinlyHeader, koinlyRecord)
+	case FormatJSONLines:
+		return &jsonLinesTradeWriter{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("invalid export format '%s', needs to be one of %v", format, Formats)
+}
+
+var csvHeader = []string{
+	"date_utc",
+	"exchange_name",
+	"market_id",
+	"base",
+	"quote",
+	"txid",
+	"action",
+	"type",
+	"counter_price",
+	"base_volume",
+	"counter_cost",
+	"fee",
+	"account_id",
+	"order_id",
+	"client_order_id",
+}
+
+func csvRecord(t queries.ExportedTrade) []string {
+	return []string{
+		t.DateUTC.Format("2006-01-02T15:04:05.000Z"),
+		t.ExchangeName,
+		t.MarketID,
+		t.Base,
+		t.Quote,
+		t.TxID,
+		t.Action,
+		t.Type,
+		formatFloat(t.CounterPrice),
+		formatFloat(t.BaseVolume),
+		formatFloat(t.CounterCost),
+		formatFloat(t.Fee),
+		t.AccountID,
+		t.OrderID,
+		t.ClientOrderID,
+	}
+}
+
+// koinlyHeader is the universal CSV format of Koinly, which CoinTracker also imports
+var koinlyHeader = []string{
+	"Date",
+	"Sent Amount",
+	"Sent Currency",
+	"Received Amount",
+	"Received Currency",
+	"Fee Amount",
+	"Fee Currency",
+	"Net Worth Amount",
+	"Net Worth Currency",
+	"Label",
+	"Description",
+	"TxHash",
+}
+
+// koinlyRecord records a sell as sending the base asset and receiving the quote asset, and a buy as the reverse. Kelp records
+// fees in units of the quote asset
+func koinlyRecord(t queries.ExportedTrade) []string {
+	sentAmount, sentCurrency := t.BaseVolume, t.Base
+	receivedAmount, receivedCurrency := t.CounterCost, t.Quote
+	if t.Action == queries.DailyVolumeActionBuy.String() {
+		sentAmount, sentCurrency = t.CounterCost, t.Quote
+		receivedAmount, receivedCurrency = t.BaseVolume, t.Base
+	}
+
+	feeAmount, feeCurrency := "", ""
+	if t.Fee != 0 {
+		feeAmount, feeCurrency = formatFloat(t.Fee), t.Quote
+	}
+	return []string{
+		t.DateUTC.Format(koinlyDateLayout),
+		formatFloat(sentAmount),
+		sentCurrency,
+		formatFloat(receivedAmount),
+		receivedCurrency,
+		feeAmount,
+		feeCurrency,
+		"",
+		"",
+		"",
+		fmt.Sprintf("kelp %s trade on %s (market_id=%s)", t.Action, t.ExchangeName, t.MarketID),
+		t.TxID,
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type csvTradeWriter struct {
+	w        *csv.Writer
+	recordFn func(queries.ExportedTrade) []string
+}
+
+func makeCSVTradeWriter(w io.Writer, header []string, recordFn func(queries.ExportedTrade) []string) (*csvTradeWriter, error) {
+	cw := csv.NewWriter(w)
+	e := cw.Write(header)
+	if e != nil {
+		return nil, fmt.Errorf("could not write csv header: %s", e)
+	}
+	return &csvTradeWriter{w: cw, recordFn: recordFn}, nil
+}
+
+// Write impl
+func (c *csvTradeWriter) Write(trade queries.ExportedTrade) error {
+	return c.w.Write(c.recordFn(trade))
+}
+
+// Flush impl
+func (c *csvTradeWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonLinesTradeWriter struct {
+	encoder *json.Encoder
+}
+
+// Write impl
+func (j *jsonLinesTradeWriter) Write(trade queries.ExportedTrade) error {
+	return j.encoder.Encode(trade)
+}
+
+// Flush impl
+func (j *jsonLinesTradeWriter) Flush() error {
+	return nil
+}
+
+// WriteAll pages through the query (a queries.TradesExport) and writes every trade, returning the number of trades written
+func WriteAll(q api.Query, w TradeWriter) (int, error) {
+	count := 0
+	var cursor *queries.TradesExportCursor
+	for {
+		result, e := q.QueryRow(cursor)
+		if e != nil {
+			return count, e
+		}
+		page, ok := result.(*queries.TradesExportPage)
+		if !ok {
+			return count, fmt.Errorf("query '%s' returned an unexpected result type '%T'", q.Name(), result)
+		}
+
+		for _, t := range page.Trades {
+			e = w.Write(t)
+			if e != nil {
+				return count, fmt.Errorf("could not write trade (market_id=%s, txid=%s): %s", t.MarketID, t.TxID, e)
+			}
+			count++
+		}
+		if page.Next == nil {
+			return count, w.Flush()
+		}
+		cursor = page.Next
+	}
+}
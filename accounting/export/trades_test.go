@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/queries"
+)
+
+var testTrades = []queries.ExportedTrade{
+	{
+		MarketID:     "m1",
+		ExchangeName: "kraken",
+		Base:         "XLM",
+		Quote:        "USD",
+		TxID:         "tx1",
+		DateUTC:      time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC),
+		Action:       "sell",
+		Type:         "limit",
+		CounterPrice: 0.05,
+		BaseVolume:   100,
+		CounterCost:  5,
+		Fee:          0.01,
+		AccountID:    "acc",
+		OrderID:      "o1",
+	}, {
+		MarketID:     "m1",
+		ExchangeName: "kraken",
+		Base:         "XLM",
+		Quote:        "USD",
+		TxID:         "tx2",
+		DateUTC:      time.Date(2020, time.March, 5, 0, 0, 0, 0, time.UTC),
+		Action:       "buy",
+		Type:         "limit",
+		CounterPrice: 0.04,
+		BaseVolume:   50,
+		CounterCost:  2,
+	},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		parsed, e := ParseFormat(string(f))
+		if assert.NoError(t, e) {
+			assert.Equal(t, f, parsed)
+		}
+	}
+
+	_, e := ParseFormat("xlsx")
+	assert.Error(t, e)
+}
+
+func TestTradeWriters(t *testing.T) {
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: FormatCSV,
+			want: "date_utc,exchange_name,market_id,base,quote,txid,action,type,counter_price,base_volume,counter_cost,fee,account_id,order_id,client_order_id\n" +
+				"2020-03-04T05:06:07.000Z,kraken,m1,XLM,USD,tx1,sell,limit,0.05,100,5,0.01,acc,o1,\n" +
+				"2020-03-05T00:00:00.000Z,kraken,m1,XLM,USD,tx2,buy,limit,0.04,50,2,0,,,\n",
+		}, {
+			format: FormatKoinlyCSV,
+			want: "Date,Sent Amount,Sent Currency,Received Amount,Received Currency,Fee Amount,Fee Currency,Net Worth Amount,Net Worth Currency,Label,Description,TxHash\n" +
+				"2020-03-04 05:06:07 UTC,100,XLM,5,USD,0.01,USD,,,,kelp sell trade on kraken (market_id=m1),tx1\n" +
+				"2020-03-05 00:00:00 UTC,2,USD,50,XLM,,,,,,kelp buy trade on kraken (market_id=m1),tx2\n",
+		}, {
+			format: FormatJSONLines,
+			want: `{"market_id":"m1","exchange_name":"kraken","base":"XLM","quote":"USD","txid":"tx1","date_utc":"2020-03-04T05:06:07Z","action":"sell","type":"limit","counter_price":0.05,"base_volume":100,"counter_cost":5,"fee":0.01,"account_id":"acc","order_id":"o1","client_order_id":""}` + "\n" +
+				`{"market_id":"m1","exchange_name":"kraken","base":"XLM","quote":"USD","txid":"tx2","date_utc":"2020-03-05T00:00:00Z","action":"buy","type":"limit","counter_price":0.04,"base_volume":50,"counter_cost":2,"fee":0,"account_id":"","order_id":"","client_order_id":""}` + "\n",
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(string(k.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, e := MakeTradeWriter(k.format, &buf)
+			if !assert.NoError(t, e) {
+				return
+			}
+			for _, trade := range testTrades {
+				assert.NoError(t, w.Write(trade))
+			}
+			assert.NoError(t, w.Flush())
+			assert.Equal(t, k.want, buf.String())
+		})
+	}
+}
+
+// pagedQuery returns one trade per page and records the cursors it was called with
+type pagedQuery struct {
+	cursors []*queries.TradesExportCursor
+}
+
+var _ api.Query = &pagedQuery{}
+
+// Name impl
+func (q *pagedQuery) Name() string {
+	return "pagedQuery"
+}
+
+// QueryRow impl
+func (q *pagedQuery) QueryRow(args ...interface{}) (interface{}, error) {
+	cursor := args[0].(*queries.TradesExportCursor)
+	q.cursors = append(q.cursors, cursor)
+
+	i := len(q.cursors) - 1
+	if i >= len(testTrades) {
+		return nil, fmt.Errorf("queried past the last page")
+	}
+	page := &queries.TradesExportPage{Trades: []queries.ExportedTrade{testTrades[i]}}
+	if i < len(testTrades)-1 {
+		page.Next = &queries.TradesExportCursor{DateUTC: testTrades[i].DateUTC, MarketID: testTrades[i].MarketID, TxID: testTrades[i].TxID}
+	}
+	return page, nil
+}
+
+func TestWriteAll(t *testing.T) {
+	var buf bytes.Buffer
+	w, e := MakeTradeWriter(FormatJSONLines, &buf)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	q := &pagedQuery{}
+	count, e := WriteAll(q, w)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+	if assert.Equal(t, 2, len(q.cursors)) {
+		assert.Nil(t, q.cursors[0])
+		assert.Equal(t, "tx1", q.cursors[1].TxID)
+	}
+}
@@ -123,6 +123,7 @@ type TradeAPI interface {
 type PrepareDepositResult struct {
 	Fee      *model.Number // fee that will be deducted from your deposit, i.e. amount available is depositAmount - fee
 	Address  string        // address you should send the funds to
+	Memo     string        // memo that needs to be attached to the deposit, empty if not needed
 	ExpireTs int64         // expire time as a unix timestamp, 0 if it does not expire
 }
 
@@ -194,6 +195,49 @@ type WithdrawAPI interface {
 	) (*WithdrawFunds, error)
 }
 
+// MemoWithdrawAPI is defined by anything where you can withdraw funds to an address that needs a memo, such as the deposit address of an exchange
+type MemoWithdrawAPI interface {
+	/*
+		Input:
+			asset - asset you want to withdraw
+			amountToWithdraw - amount you want deducted from your account
+			address - address you want to withdraw to
+			memo - memo that identifies the deposit at the destination
+		Output:
+		    WithdrawFunds - result of the withdrawal
+			error - any error
+	*/
+	WithdrawFundsWithMemo(
+		asset model.Asset,
+		amountToWithdraw *model.Number,
+		address string,
+		memo string,
+	) (*WithdrawFunds, error)
+}
+
+// WithdrawalStatus is the state of a withdrawal on the venue that sent it
+type WithdrawalStatus string
+
+// WithdrawalStatus values
+const (
+	WithdrawalStatusPending WithdrawalStatus = "pending" // the venue has not sent the funds yet
+	WithdrawalStatusSent    WithdrawalStatus = "sent"    // the funds left the venue, they can still be in transit to the destination
+	WithdrawalStatusFailed  WithdrawalStatus = "failed"  // the withdrawal was rejected or canceled, the funds did not leave the venue
+)
+
+// WithdrawStatusAPI is defined by anything that can look up a withdrawal by the WithdrawalID returned when it was made
+type WithdrawStatusAPI interface {
+	/*
+		Input:
+			asset - asset that was withdrawn
+			withdrawalID - WithdrawalID of the WithdrawFunds result
+		Output:
+			WithdrawalStatus - state of the withdrawal, WithdrawalStatusPending if the venue does not know of it (yet)
+			error - any error
+	*/
+	GetWithdrawalStatus(asset model.Asset, withdrawalID string) (WithdrawalStatus, error)
+}
+
 // ErrWithdrawAmountAboveLimit error type
 type ErrWithdrawAmountAboveLimit error
 
@@ -237,6 +281,37 @@ type ExchangeShim interface {
 	FillTrackable
 }
 
+// TakerResult is the result of taking liquidity on SDEX with a path payment
+type TakerResult struct {
+	TxHash     string // empty in simulation mode
+	SendAsset  hProtocol.Asset
+	DestAsset  hProtocol.Asset
+	SendAmount *model.Number // the exact amount sent for a strict send, the quoted amount for a strict receive
+	DestAmount *model.Number // the exact amount received for a strict receive, the quoted amount for a strict send
+	SendMax    *model.Number // bound on the amount sent for a strict receive, nil for a strict send
+	DestMin    *model.Number // bound on the amount received for a strict send, nil for a strict receive
+	Path       []hProtocol.Asset
+	// DeletedOfferIDs are our own offers on the path that were deleted in the same transaction so the path payment does not cross them
+	DeletedOfferIDs []int64
+}
+
+// String is the Stringer method
+func (r *TakerResult) String() string {
+	return fmt.Sprintf("TakerResult[txHash=%s, send=%s %s (max=%v), dest=%s %s (min=%v), pathLength=%d, deletedOfferIDs=%v]",
+		r.TxHash, r.SendAmount, r.SendAsset.Code, r.SendMax, r.DestAmount, r.DestAsset.Code, r.DestMin, len(r.Path), r.DeletedOfferIDs)
+}
+
+// PathPaymentTaker takes liquidity on SDEX immediately with path payments to the trading account, which can cross multiple order
+// books and never leave a residual offer. maxSlippage is the fraction (0.01 = 1%) by which the executed amount can be worse than the
+// amount quoted by horizon's path finding. A path payment cannot cross offers of its own account, so our offers that it could take
+// are deleted in the same transaction
+type PathPaymentTaker interface {
+	// TakeStrictSend sends exactly sendAmount of sendAsset and receives at least the quoted amount of destAsset less the slippage
+	TakeStrictSend(sendAsset hProtocol.Asset, sendAmount *model.Number, destAsset hProtocol.Asset, maxSlippage float64) (*TakerResult, error)
+	// TakeStrictReceive receives exactly destAmount of destAsset and sends at most the quoted amount of sendAsset plus the slippage
+	TakeStrictReceive(sendAsset hProtocol.Asset, destAsset hProtocol.Asset, destAmount *model.Number, maxSlippage float64) (*TakerResult, error)
+}
+
 // ConvertOperation2TM is a temporary adapter to support transitioning from the old Go SDK to the new SDK without having to bump the major version
 func ConvertOperation2TM(ops []txnbuild.Operation) []build.TransactionMutator {
 	muts := []build.TransactionMutator{}
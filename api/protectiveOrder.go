@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/stellar/kelp/model"
+)
+
+// ProtectiveOrderType is the type of a protective order
+type ProtectiveOrderType string
+
+// These are the available protective order types
+const (
+	ProtectiveOrderTypeStopLoss   ProtectiveOrderType = "stop_loss"
+	ProtectiveOrderTypeTakeProfit ProtectiveOrderType = "take_profit"
+)
+
+// ParseProtectiveOrderType converts from the string representation of the protective order type
+func ParseProtectiveOrderType(s string) (ProtectiveOrderType, error) {
+	switch ProtectiveOrderType(s) {
+	case ProtectiveOrderTypeStopLoss:
+		return ProtectiveOrderTypeStopLoss, nil
+	case ProtectiveOrderTypeTakeProfit:
+		return ProtectiveOrderTypeTakeProfit, nil
+	}
+	return "", fmt.Errorf("invalid protective order type '%s', needs to be one of '%s' or '%s'", s, ProtectiveOrderTypeStopLoss, ProtectiveOrderTypeTakeProfit)
+}
+
+// ProtectiveOrder is an order that is sent to the market once the price of the pair crosses the trigger price, it is used to limit losses
+// (stop loss) or to lock in gains (take profit) on inventory that is held by the bot. A sell order protects inventory of the base asset
+// and a buy order protects inventory of the quote asset.
+type ProtectiveOrder struct {
+	Pair         *model.TradingPair
+	Type         ProtectiveOrderType
+	OrderAction  model.OrderAction // side of the order that is sent to the market when triggered
+	TriggerPrice *model.Number
+	LimitPrice   *model.Number // limit price of the order that is sent to the market when triggered, nil sends a market order
+	Volume       *model.Number // in units of the base asset
+	// ClientOrderID is set by the bot so it can tell its native orders apart from the orders of other bots trading on the same account
+	ClientOrderID string
+}
+
+// String is the stringer function
+func (o ProtectiveOrder) String() string {
+	limitPriceString := "market"
+	if o.LimitPrice != nil {
+		limitPriceString = o.LimitPrice.AsString()
+	}
+	return fmt.Sprintf("ProtectiveOrder[pair=%s, type=%s, action=%s, triggerPrice=%s, limitPrice=%s, volume=%s]",
+		o.Pair,
+		o.Type,
+		o.OrderAction,
+		o.TriggerPrice.AsString(),
+		limitPriceString,
+		o.Volume.AsString(),
+	)
+}
+
+// IsTriggered returns true when the price has crossed the trigger price. A sell stop loss triggers when the price falls to the trigger price and a
+// sell take profit triggers when the price rises to the trigger price, the directions are reversed for buy orders.
+func (o ProtectiveOrder) IsTriggered(price *model.Number) bool {
+	triggersOnRise := (o.Type == ProtectiveOrderTypeTakeProfit) == o.OrderAction.IsSell()
+	if triggersOnRise {
+		return price.Cmp(*o.TriggerPrice) >= 0
+	}
+	return price.Cmp(*o.TriggerPrice) <= 0
+}
+
+// ProtectiveOrderAPI is defined by exchanges that can hold protective orders natively, these orders are triggered by the exchange and
+// continue to protect the inventory when the bot is not running
+type ProtectiveOrderAPI interface {
+	// SupportsProtectiveOrder returns false when the exchange cannot hold this protective order natively
+	SupportsProtectiveOrder(order *ProtectiveOrder) bool
+
+	AddProtectiveOrder(order *ProtectiveOrder) (*model.TransactionID, error)
+
+	// GetOpenProtectiveOrders returns the client order IDs of the protective orders on the pair that are still open keyed by their order ID,
+	// the client order ID is empty when the order was not placed with one. The orders are cancelled with CancelOrder
+	GetOpenProtectiveOrders(pair *model.TradingPair) (map[string]string, error)
+}
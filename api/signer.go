@@ -0,0 +1,13 @@
+package api
+
+import (
+	"github.com/stellar/go/xdr"
+)
+
+// Signer interface is used to sign the transactions submitted to the Stellar network. Signers can hold keys locally or delegate to a
+// signing service that lives outside the kelp process.
+type Signer interface {
+	// Sign returns the signatures to add to the transaction, txHash is the network-specific hash that needs to be signed and txeB64 is the
+	// unsigned transaction envelope for signers that want to inspect the transaction before signing it
+	Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error)
+}
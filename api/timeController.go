@@ -11,3 +11,9 @@ type TimeController interface {
 	// SleepTime computes how long we want to sleep before the next call to ShouldUpdate
 	SleepTime(lastUpdateTime time.Time) time.Duration
 }
+
+// TradingWindow is implemented by TimeControllers that restrict when the bot is allowed to have offers on the book
+type TradingWindow interface {
+	// IsTradingWindowOpen returns true if the bot is allowed to trade at the given time
+	IsTradingWindowOpen(t time.Time) bool
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+)
+
+const balancesExamples = `  kelp balances --botConf ./path/trader.cfg
+  kelp balances --exchange kraken --api-key keystore:kraken_key --api-secret keystore:kraken_secret --pair XLM/USD --pair XLM/EUR`
+
+var balancesCmd = &cobra.Command{
+	Use:     "balances",
+	Short:   "Prints the balances, liabilities and open orders of a bot on SDEX and on its centralized exchange",
+	Example: balancesExamples,
+	Args:    cobra.NoArgs,
+}
+
+func init() {
+	options := addOperatorFlags(balancesCmd)
+
+	balancesCmd.Run = func(ccmd *cobra.Command, args []string) {
+		l := logger.MakeBasicLogger()
+		target, e := makeOperatorTarget(l, options)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+
+		if target.botConfig != nil {
+			e = printSdexBalances(target)
+			if e != nil {
+				logger.Fatal(l, e)
+			}
+		}
+		if target.exchange != nil {
+			if target.botConfig != nil {
+				fmt.Println()
+			}
+			e = printExchangeBalances(target)
+			if e != nil {
+				logger.Fatal(l, e)
+			}
+		}
+	}
+}
+
+func printSdexBalances(target *operatorTarget) error {
+	accountID := target.botConfig.TradingAccount()
+	account, e := target.client.AccountDetail(horizonclient.AccountRequest{AccountID: accountID})
+	if e != nil {
+		return fmt.Errorf("cannot get account data for account '%s': %s", accountID, e)
+	}
+
+	fmt.Printf("SDEX account %s\n", accountID)
+	fmt.Printf("  %-70s %20s %20s %20s\n", "Asset", "Balance", "Buying Liabilities", "Selling Liabilities")
+	for _, b := range account.Balances {
+		fmt.Printf("  %-70s %20s %20s %20s\n", utils.Asset2String(hProtocol.Asset(b.Asset)), b.Balance, b.BuyingLiabilities, b.SellingLiabilities)
+	}
+
+	offers, e := utils.LoadAllOffers(accountID, target.client)
+	if e != nil {
+		return fmt.Errorf("unable to load offers: %s", e)
+	}
+	fmt.Printf("  open offers (%d):\n", len(offers))
+	for _, o := range offers {
+		fmt.Printf("    %s\n", sdexOfferString(o))
+	}
+	return nil
+}
+
+func printExchangeBalances(target *operatorTarget) error {
+	assets := []interface{}{}
+	seen := map[interface{}]bool{}
+	for _, pair := range target.pairs {
+		for _, asset := range []interface{}{pair.Base, pair.Quote} {
+			if !seen[asset] {
+				seen[asset] = true
+				assets = append(assets, asset)
+			}
+		}
+	}
+	balances, e := target.exchange.GetAccountBalances(assets)
+	if e != nil {
+		return fmt.Errorf("unable to fetch balances from %s: %s", target.exchangeName, e)
+	}
+
+	fmt.Printf("%s account\n", target.exchangeName)
+	fmt.Printf("  %-10s %20s\n", "Asset", "Balance")
+	for _, asset := range assets {
+		balance, ok := balances[asset]
+		balanceString := "unknown"
+		if ok {
+			balanceString = balance.AsString()
+		}
+		fmt.Printf("  %-10s %20s\n", asset, balanceString)
+	}
+
+	openOrders, e := target.exchange.GetOpenOrders(target.pairs)
+	if e != nil {
+		return fmt.Errorf("unable to fetch open orders from %s: %s", target.exchangeName, e)
+	}
+	for _, pair := range sortedPairs(openOrders) {
+		fmt.Printf("  open orders on %s (%d):\n", pair, len(openOrders[pair]))
+		for _, o := range openOrders[pair] {
+			fmt.Printf("    %s\n", openOrderString(o))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// sdexMaxOpsPerTx is the maximum number of operations allowed in a single transaction on the Stellar network
+const sdexMaxOpsPerTx = 100
+
+const cancelAllExamples = `  kelp cancel-all --botConf ./path/trader.cfg
+  kelp cancel-all --botConf ./path/sdex_trader.cfg --all-pairs --yes
+  kelp cancel-all --exchange kraken --api-key keystore:kraken_key --api-secret keystore:kraken_secret --pair XLM/USD --pair XLM/BTC`
+
+var cancelAllCmd = &cobra.Command{
+	Use:     "cancel-all",
+	Short:   "Cancels all open orders of a bot on its trading pair, or on every pair",
+	Long:    "Cancels all open orders of a bot on its trading pair, or on every pair of the trading account with --all-pairs when trading on SDEX. --all-pairs is not available on centralized exchanges since open orders are only fetched for the pairs that are passed in, list every pair to cancel with --pair instead.",
+	Example: cancelAllExamples,
+	Args:    cobra.NoArgs,
+}
+
+func init() {
+	options := addOperatorFlags(cancelAllCmd)
+	allPairs := cancelAllCmd.Flags().Bool("all-pairs", false, "cancel the offers of the trading account on every pair instead of only the pair in the trader config file, only on SDEX (use --pair on centralized exchanges)")
+	yes := cancelAllCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation before cancelling")
+
+	cancelAllCmd.Run = func(ccmd *cobra.Command, args []string) {
+		l := logger.MakeBasicLogger()
+		target, e := makeOperatorTarget(l, options)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+
+		if target.exchange == nil {
+			e = cancelAllSdex(l, target, *allPairs, *yes)
+		} else if *allPairs {
+			e = fmt.Errorf("--all-pairs is only supported on SDEX since open orders on centralized exchanges are only fetched for the pairs that are passed in, use --pair to list the pairs to cancel on %s", target.exchangeName)
+		} else {
+			e = cancelAllExchange(target, *yes)
+		}
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+	}
+}
+
+func cancelAllSdex(l logger.Logger, target *operatorTarget, allPairs bool, yes bool) error {
+	offers, e := utils.LoadAllOffers(target.botConfig.TradingAccount(), target.client)
+	if e != nil {
+		return fmt.Errorf("unable to load offers: %s", e)
+	}
+	if !allPairs {
+		sellingAOffers, buyingAOffers := utils.FilterOffers(offers, target.botConfig.AssetBase(), target.botConfig.AssetQuote())
+		offers = append(sellingAOffers, buyingAOffers...)
+	}
+
+	fmt.Printf("found %d offers on SDEX for account %s:\n", len(offers), target.botConfig.TradingAccount())
+	for _, o := range offers {
+		fmt.Printf("  %s\n", sdexOfferString(o))
+	}
+	if len(offers) == 0 {
+		return nil
+	}
+	if !yes && !confirm(fmt.Sprintf("delete %d offers?", len(offers))) {
+		return fmt.Errorf("cancelled by user, no offers were deleted")
+	}
+
+	ops := target.sdex.DeleteAllOffers(offers)
+	for i := 0; i < len(ops); i += sdexMaxOpsPerTx {
+		end := i + sdexMaxOpsPerTx
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		var submitErr error
+		// to delete offers the submitMode doesn't matter, so use api.SubmitModeBoth as the default
+		e := target.sdex.SubmitOpsSynch(api.ConvertOperation2TM(ops[i:end]), api.SubmitModeBoth, func(hash string, e error) {
+			if e != nil {
+				submitErr = e
+				return
+			}
+			l.Infof("submitted tx with hash %s\n", hash)
+		})
+		if e == nil {
+			e = submitErr
+		}
+		if e != nil {
+			return fmt.Errorf("deleted %d of %d offers, unable to delete the remaining offers: %s", i, len(ops), e)
+		}
+	}
+	fmt.Printf("deleted %d offers\n", len(ops))
+	return nil
+}
+
+func cancelAllExchange(target *operatorTarget, yes bool) error {
+	openOrders, e := target.exchange.GetOpenOrders(target.pairs)
+	if e != nil {
+		return fmt.Errorf("unable to fetch open orders from %s: %s", target.exchangeName, e)
+	}
+
+	pairs := sortedPairs(openOrders)
+	numOrders := 0
+	fmt.Printf("found open orders on %s:\n", target.exchangeName)
+	for _, pair := range pairs {
+		for _, o := range openOrders[pair] {
+			fmt.Printf("  %s\n", openOrderString(o))
+			numOrders++
+		}
+	}
+	if numOrders == 0 {
+		fmt.Println("  none")
+		return nil
+	}
+	if !yes && !confirm(fmt.Sprintf("cancel %d orders?", numOrders)) {
+		return fmt.Errorf("cancelled by user, no orders were cancelled")
+	}
+
+	numFailed := 0
+	for _, pair := range pairs {
+		for _, o := range openOrders[pair] {
+			result, e := target.exchange.CancelOrder(model.MakeTransactionID(o.ID), pair)
+			if e != nil {
+				fmt.Printf("  %s: error: %s\n", o.ID, e)
+				numFailed++
+				continue
+			}
+			fmt.Printf("  %s: %s\n", o.ID, result)
+			if result == model.CancelResultFailed {
+				numFailed++
+			}
+		}
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d orders could not be cancelled", numFailed, numOrders)
+	}
+	fmt.Printf("cancelled %d orders\n", numOrders)
+	return nil
+}
+
+func sortedPairs(openOrders map[model.TradingPair][]model.OpenOrder) []model.TradingPair {
+	pairs := []model.TradingPair{}
+	for pair := range openOrders {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].String() < pairs[j].String()
+	})
+	return pairs
+}
+
+func sdexOfferString(o hProtocol.Offer) string {
+	return fmt.Sprintf("offerID=%d selling %s %s for %s at price %s", o.ID, o.Amount, utils.Asset2String(o.Selling), utils.Asset2String(o.Buying), o.Price)
+}
+
+func openOrderString(o model.OpenOrder) string {
+	return fmt.Sprintf("ID=%s %s %s %s at price %s", o.ID, o.OrderAction, o.Volume.AsString(), o.Pair, o.Price.AsString())
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stellar/go/support/config"
+	"github.com/stellar/kelp/accounting/export"
+	"github.com/stellar/kelp/queries"
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+const exportTradesExamples = `  kelp export trades --botConf ./path/trader.cfg --from 2020-01-01 --to 2021-01-01 --format koinly -o trades_2020.csv
+  kelp export trades --botConf ./path/trader.cfg --market-id 8b8ec8d2b6 --action sell --format jsonl`
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports data that the bots saved in the database",
+}
+
+var exportTradesCmd = &cobra.Command{
+	Use:     "trades",
+	Short:   "Exports trades from the trades table of the POSTGRES_DB in the trader config file as CSV, JSON Lines or Koinly CSV",
+	Example: exportTradesExamples,
+	Args:    cobra.NoArgs,
+}
+
+func init() {
+	botConfigPath := exportTradesCmd.Flags().StringP("botConf", "c", "", "(required) trader config file with the POSTGRES_DB to export from")
+	keystorePath := exportTradesCmd.Flags().String("keystore", "", "encrypted keystore file used to resolve a 'keystore:<name>' reference in the POSTGRES_DB password, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for")
+	marketIDs := exportTradesCmd.Flags().StringSlice("market-id", []string{}, "only export trades of this market_id, can be repeated")
+	accountIDs := exportTradesCmd.Flags().StringSlice("account-id", []string{}, "only export trades of this account_id, can be repeated")
+	from := exportTradesCmd.Flags().String("from", "", "only export trades on or after this UTC date (YYYY-MM-DD) or time (RFC3339)")
+	to := exportTradesCmd.Flags().String("to", "", "only export trades before this UTC date (YYYY-MM-DD) or time (RFC3339)")
+	action := exportTradesCmd.Flags().String("action", "", "only export trades with this action (buy or sell)")
+	formatString := exportTradesCmd.Flags().String("format", string(export.FormatCSV), fmt.Sprintf("output format, one of %v", export.Formats))
+	outputPath := exportTradesCmd.Flags().StringP("output", "o", "", "output file, defaults to stdout")
+	pageSize := exportTradesCmd.Flags().Int("page-size", 1000, "number of trades fetched from the database at a time")
+	e := exportTradesCmd.MarkFlagRequired("botConf")
+	if e != nil {
+		panic(e)
+	}
+
+	exportTradesCmd.Run = func(ccmd *cobra.Command, args []string) {
+		l := logger.MakeBasicLogger()
+		format, e := export.ParseFormat(*formatString)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		filter, e := makeTradesExportFilter(*marketIDs, *accountIDs, *from, *to, *action)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		db, e := connectExportDb(*botConfigPath, *keystorePath)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		defer db.Close()
+		q, e := queries.MakeTradesExport(db, filter, *pageSize)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+
+		var out io.Writer = os.Stdout
+		if *outputPath != "" {
+			f, e := os.Create(*outputPath)
+			if e != nil {
+				logger.Fatal(l, fmt.Errorf("could not create output file: %s", e))
+			}
+			defer f.Close()
+			out = f
+		}
+		w, e := export.MakeTradeWriter(format, out)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		count, e := export.WriteAll(q, w)
+		if e != nil {
+			logger.Fatal(l, fmt.Errorf("export failed after writing %d trades: %s", count, e))
+		}
+		l.Infof("exported %d trades\n", count)
+	}
+
+	exportCmd.AddCommand(exportTradesCmd)
+}
+
+func makeTradesExportFilter(marketIDs []string, accountIDs []string, from string, to string, action string) (queries.TradesExportFilter, error) {
+	filter := queries.TradesExportFilter{
+		MarketIDs:  marketIDs,
+		AccountIDs: accountIDs,
+	}
+	var e error
+	if from != "" {
+		filter.From, e = parseExportTime(from)
+		if e != nil {
+			return filter, fmt.Errorf("invalid --from: %s", e)
+		}
+	}
+	if to != "" {
+		filter.To, e = parseExportTime(to)
+		if e != nil {
+			return filter, fmt.Errorf("invalid --to: %s", e)
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, fmt.Errorf("--from (%s) needs to be before --to (%s)", from, to)
+	}
+	if action != "" {
+		a, e := queries.ParseDailyVolumeAction(action)
+		if e != nil {
+			return filter, fmt.Errorf("invalid --action, needs to be 'buy' or 'sell': %s", e)
+		}
+		filter.Action = &a
+	}
+	return filter, nil
+}
+
+func parseExportTime(s string) (*time.Time, error) {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		t, e := time.Parse(layout, s)
+		if e == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a date (YYYY-MM-DD) or time (RFC3339)", s)
+}
+
+// connectExportDb connects to the POSTGRES_DB of the trader config, without running upgrade scripts since exports only read
+func connectExportDb(botConfigPath string, keystorePath string) (*sql.DB, error) {
+	var botConfig trader.BotConfig
+	e := config.Read(botConfigPath, &botConfig)
+	utils.CheckConfigError(botConfig, e, botConfigPath)
+	if botConfig.PostgresDbConfig == nil {
+		return nil, fmt.Errorf("the trader config file '%s' does not have a POSTGRES_DB to export from", botConfigPath)
+	}
+	e = utils.ExpandEnvRefs(botConfig.PostgresDbConfig)
+	if e != nil {
+		return nil, fmt.Errorf("could not expand environment variables in POSTGRES_DB: %s", e)
+	}
+	resolveSecret := utils.ChainSecretResolvers(utils.ResolveFileRef, keystore.MakeSecretResolver(keystorePath))
+	e = utils.ResolveSecrets(resolveSecret, &botConfig.PostgresDbConfig.Password)
+	if e != nil {
+		return nil, e
+	}
+
+	db, e := sql.Open("postgres", botConfig.PostgresDbConfig.MakeConnectString())
+	if e != nil {
+		return nil, fmt.Errorf("could not open db: %s", e)
+	}
+	e = db.Ping()
+	if e != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not connect to db: %s", e)
+	}
+	return db, nil
+}
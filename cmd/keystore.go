@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/kelp/support/keystore"
+)
+
+var keystoreCmd = &cobra.Command{
+	Use:   "keystore",
+	Short: "Manages the encrypted keystore of secrets referenced in config files as 'keystore:<name>'",
+}
+
+var keystoreSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Adds or replaces a secret in the keystore, creating the keystore if it does not exist (secret is read from stdin)",
+	Args:  cobra.ExactArgs(1),
+}
+
+var keystoreListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the names of the secrets in the keystore",
+	Args:  cobra.NoArgs,
+}
+
+var keystoreDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Deletes a secret from the keystore",
+	Args:  cobra.ExactArgs(1),
+}
+
+func init() {
+	keystorePath := keystoreCmd.PersistentFlags().StringP("keystore", "k", "./keystore.json", "encrypted keystore file, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for")
+
+	keystoreSetCmd.Run = func(ccmd *cobra.Command, args []string) {
+		passphrase, e := keystore.ReadPassphrase(*keystorePath)
+		if e != nil {
+			log.Fatal(e)
+		}
+		ks, e := keystore.OpenOrCreate(*keystorePath, passphrase)
+		if e != nil {
+			log.Fatal(e)
+		}
+
+		fmt.Fprintf(os.Stderr, "enter secret for '%s': ", args[0])
+		line, e := bufio.NewReader(os.Stdin).ReadString('\n')
+		secret := strings.TrimRight(line, "\r\n")
+		if secret == "" {
+			log.Fatalf("no secret provided for '%s' (error=%v)", args[0], e)
+		}
+
+		e = ks.Set(args[0], secret)
+		if e != nil {
+			log.Fatal(e)
+		}
+		e = ks.Save()
+		if e != nil {
+			log.Fatal(e)
+		}
+		fmt.Printf("saved secret to keystore, reference it in config files as \"%s\"\n", keystore.MakeRef(args[0]))
+	}
+
+	keystoreListCmd.Run = func(ccmd *cobra.Command, args []string) {
+		ks := openKeystoreOrExit(*keystorePath)
+		for _, name := range ks.Names() {
+			fmt.Printf("  %s\n", keystore.MakeRef(name))
+		}
+	}
+
+	keystoreDeleteCmd.Run = func(ccmd *cobra.Command, args []string) {
+		ks := openKeystoreOrExit(*keystorePath)
+		if _, e := ks.Get(args[0]); e != nil {
+			log.Fatal(e)
+		}
+		ks.Delete(args[0])
+		e := ks.Save()
+		if e != nil {
+			log.Fatal(e)
+		}
+		fmt.Printf("deleted secret '%s' from keystore\n", args[0])
+	}
+
+	keystoreCmd.AddCommand(keystoreSetCmd)
+	keystoreCmd.AddCommand(keystoreListCmd)
+	keystoreCmd.AddCommand(keystoreDeleteCmd)
+}
+
+func openKeystoreOrExit(keystorePath string) *keystore.Keystore {
+	passphrase, e := keystore.ReadPassphrase(keystorePath)
+	if e != nil {
+		log.Fatal(e)
+	}
+	ks, e := keystore.Open(keystorePath, passphrase)
+	if e != nil {
+		log.Fatal(e)
+	}
+	return ks
+}
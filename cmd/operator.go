@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nikhilsaraf/go-tools/multithreading"
+	"github.com/spf13/cobra"
+
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/sdk"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+// operatorInputs are the flags shared by the operator commands (cancel-all, balances, take), which act on the accounts of a trader
+// config or on an exchange account given by its credentials
+type operatorInputs struct {
+	botConfigPath *string
+	keystorePath  *string
+	exchange      *string
+	apiKey        *string
+	apiSecret     *string
+	pairs         *[]string
+}
+
+func addOperatorFlags(c *cobra.Command) operatorInputs {
+	return operatorInputs{
+		botConfigPath: c.Flags().StringP("botConf", "c", "", "trading bot's basic config file path, the accounts of the bot are used"),
+		keystorePath:  c.Flags().String("keystore", "", "encrypted keystore file used to resolve 'keystore:<name>' references, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for"),
+		exchange:      c.Flags().String("exchange", "", "exchange to use instead of a trader config file, e.g. kraken or ccxt-binance"),
+		apiKey:        c.Flags().String("api-key", "", "API key for --exchange, can be a 'keystore:<name>' or 'file:<path>' reference"),
+		apiSecret:     c.Flags().String("api-secret", "", "API secret for --exchange, can be a 'keystore:<name>' or 'file:<path>' reference"),
+		pairs:         c.Flags().StringSlice("pair", []string{}, "trading pair on the exchange as BASE/QUOTE, can be repeated (defaults to the pair in the trader config file)"),
+	}
+}
+
+// operatorTarget holds the accounts that the operator commands act on
+type operatorTarget struct {
+	botConfig    *trader.BotConfig // nil when using exchange credentials
+	client       *horizonclient.Client
+	sdex         *plugins.SDEX // nil when using exchange credentials
+	exchangeName string
+	exchange     api.Exchange // nil when the trader config is trading on SDEX
+	pairs        []*model.TradingPair
+}
+
+func makeOperatorTarget(l logger.Logger, options operatorInputs) (*operatorTarget, error) {
+	checkInitRootFlags()
+	resolveSecret := utils.ChainSecretResolvers(utils.ResolveFileRef, keystore.MakeSecretResolver(*options.keystorePath))
+	pairs := []*model.TradingPair{}
+	for _, pairString := range *options.pairs {
+		pair, e := parsePairFlag(pairString)
+		if e != nil {
+			return nil, e
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if *options.botConfigPath == "" {
+		if *options.exchange == "" {
+			return nil, fmt.Errorf("need either a trader config file (--botConf) or an exchange (--exchange)")
+		}
+		if len(pairs) == 0 {
+			return nil, fmt.Errorf("need at least one --pair when using --exchange")
+		}
+		apiKey := api.ExchangeAPIKey{Key: *options.apiKey, Secret: *options.apiSecret}
+		e := utils.ResolveSecrets(resolveSecret, &apiKey.Key, &apiKey.Secret)
+		if e != nil {
+			return nil, e
+		}
+		exchange, e := plugins.MakeTradingExchange(*options.exchange, []api.ExchangeAPIKey{apiKey}, []api.ExchangeParam{}, []api.ExchangeHeader{}, false)
+		if e != nil {
+			return nil, fmt.Errorf("unable to make trading exchange: %s", e)
+		}
+		return &operatorTarget{
+			exchangeName: *options.exchange,
+			exchange:     exchange,
+			pairs:        pairs,
+		}, nil
+	}
+
+	if *options.exchange != "" {
+		return nil, fmt.Errorf("cannot use both a trader config file (--botConf) and an exchange (--exchange)")
+	}
+	botConfig, e := loadBotConfig(*options.botConfigPath, resolveSecret)
+	if e != nil {
+		return nil, e
+	}
+	if *rootCcxtRestURL == "" && botConfig.CcxtRestURL != nil {
+		e := sdk.SetBaseURL(*botConfig.CcxtRestURL)
+		if e != nil {
+			return nil, fmt.Errorf("unable to set CCXT-rest URL to '%s': %s", *botConfig.CcxtRestURL, e)
+		}
+	}
+
+	client := &horizonclient.Client{
+		HorizonURL: botConfig.HorizonURL,
+		HTTP:       http.DefaultClient,
+	}
+	tradingPair := &model.TradingPair{
+		Base:  model.Asset(utils.Asset2CodeString(botConfig.AssetBase())),
+		Quote: model.Asset(utils.Asset2CodeString(botConfig.AssetQuote())),
+	}
+	network := utils.ParseNetwork(botConfig.HorizonURL)
+	sdex := plugins.MakeSDEX(
+		client,
+		plugins.MakeIEIF(true),
+		nil,
+		botConfig.SourceSecretSeed,
+		botConfig.TradingSecretSeed,
+		botConfig.SourceAccount(),
+		botConfig.TradingAccount(),
+		network,
+		multithreading.MakeThreadTracker(),
+		0,
+		0,
+		false,
+		tradingPair,
+		map[model.Asset]hProtocol.Asset{
+			tradingPair.Base:  botConfig.AssetBase(),
+			tradingPair.Quote: botConfig.AssetQuote(),
+		},
+		makeFeeFn(l, botConfig, client),
+		false,
+		// sign and tag transactions the same way as the bot so multi-signature accounts and shared trading accounts work
+		makeSigner(botConfig, network),
+		botConfig.TxMemo,
+	)
+	target := &operatorTarget{
+		botConfig:    &botConfig,
+		client:       client,
+		sdex:         sdex,
+		exchangeName: botConfig.TradingExchangeName(),
+		pairs:        pairs,
+	}
+	if botConfig.IsTradingSdex() {
+		if len(pairs) > 0 {
+			return nil, fmt.Errorf("--pair can only be used with centralized exchanges, the trader config file is trading on SDEX")
+		}
+		return target, nil
+	}
+
+	target.exchange, e = makeExchangeAPI(botConfig, false)
+	if e != nil {
+		return nil, e
+	}
+	if len(target.pairs) == 0 {
+		target.pairs = []*model.TradingPair{tradingPair}
+	}
+	return target, nil
+}
+
+func parsePairFlag(pairString string) (*model.TradingPair, error) {
+	parts := strings.Split(pairString, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid pair '%s', needs to be in the format BASE/QUOTE", pairString)
+	}
+	return &model.TradingPair{
+		Base:  model.Asset(strings.ToUpper(parts[0])),
+		Quote: model.Asset(strings.ToUpper(parts[1])),
+	}, nil
+}
+
+// confirm asks the operator to confirm an action on stdin and returns true if the answer was yes
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/logger"
+)
+
+func TestParsePairFlag(t *testing.T) {
+	pair, e := parsePairFlag("xlm/USD")
+	if assert.NoError(t, e) {
+		assert.Equal(t, model.TradingPair{Base: model.Asset("XLM"), Quote: model.Asset("USD")}, *pair)
+	}
+
+	for _, pairString := range []string{"XLM", "XLM/", "/USD", "XLM/USD/BTC"} {
+		_, e := parsePairFlag(pairString)
+		assert.Error(t, e, pairString)
+	}
+}
+
+func TestMakeOperatorTargetFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "nothing to act on",
+			args:    []string{},
+			wantErr: "need either a trader config file (--botConf) or an exchange (--exchange)",
+		}, {
+			name:    "exchange without pair",
+			args:    []string{"--exchange", "kraken"},
+			wantErr: "need at least one --pair when using --exchange",
+		}, {
+			name:    "invalid pair",
+			args:    []string{"--exchange", "kraken", "--pair", "XLMUSD"},
+			wantErr: "invalid pair 'XLMUSD', needs to be in the format BASE/QUOTE",
+		}, {
+			name:    "both config and exchange",
+			args:    []string{"--botConf", "trader.cfg", "--exchange", "kraken"},
+			wantErr: "cannot use both a trader config file (--botConf) and an exchange (--exchange)",
+		},
+	}
+
+	for _, kase := range testCases {
+		t.Run(kase.name, func(t *testing.T) {
+			c := &cobra.Command{}
+			options := addOperatorFlags(c)
+			if !assert.NoError(t, c.Flags().Parse(kase.args)) {
+				return
+			}
+
+			_, e := makeOperatorTarget(logger.MakeBasicLogger(), options)
+			if assert.Error(t, e) {
+				assert.Equal(t, kase.wantErr, e.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+const planExamples = `  kelp plan --botConf ./path/trader.cfg --strategy buysell --stratConf ./path/buysell.cfg
+  kelp plan --botConf ./path/trader.cfg --strategy buysell --stratConf ./path/buysell.cfg 2>/dev/null`
+
+var planCmd = &cobra.Command{
+	Use:     "plan",
+	Short:   "Prints the offers that the strategy would create, modify and delete in the next update without submitting anything",
+	Long:    "Prints the offers that the strategy would create, modify and delete in the next update without submitting anything. The plan is printed to stdout and the logs of the bot are printed to stderr.",
+	Example: planExamples,
+}
+
+func init() {
+	options := inputs{}
+	options.botConfigPath = planCmd.Flags().StringP("botConf", "c", "", "(required) trading bot's basic config file path")
+	options.strategy = planCmd.Flags().StringP("strategy", "s", "", "(required) type of strategy to plan with")
+	options.stratConfigPath = planCmd.Flags().StringP("stratConf", "f", "", "strategy config file path")
+	options.operationalBuffer = planCmd.Flags().Float64("operationalBuffer", 20, "buffer of native XLM to maintain beyond minimum account balance requirement")
+	options.operationalBufferNonNativePct = planCmd.Flags().Float64("operationalBufferNonNativePct", 0.001, "buffer of non-native assets to maintain as a percentage (0.001 = 0.1%)")
+	options.keystorePath = planCmd.Flags().String("keystore", "", "encrypted keystore file used to resolve 'keystore:<name>' references in config files, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for")
+
+	// the bot is always run in simulation mode so nothing can be submitted, and without metrics since it is not a trading session
+	simMode := true
+	noHeaders := true
+	ui := false
+	logPrefix := ""
+	fixedIterations := uint64(0)
+	cpuProfile := ""
+	memProfile := ""
+	options.simMode = &simMode
+	options.noHeaders = &noHeaders
+	options.ui = &ui
+	options.logPrefix = &logPrefix
+	options.fixedIterations = &fixedIterations
+	options.cpuProfile = &cpuProfile
+	options.memProfile = &memProfile
+	options.planOnly = true
+
+	for _, flag := range []string{"botConf", "strategy"} {
+		e := planCmd.MarkFlagRequired(flag)
+		if e != nil {
+			panic(e)
+		}
+	}
+	for _, flag := range []string{"operationalBuffer", "operationalBufferNonNativePct"} {
+		e := planCmd.Flags().MarkHidden(flag)
+		if e != nil {
+			panic(e)
+		}
+	}
+	planCmd.Flags().SortFlags = false
+
+	planCmd.Run = func(ccmd *cobra.Command, args []string) {
+		runTradeCmd(options)
+	}
+}
+
+// printPlan runs a single update cycle of the bot without submitting anything and prints the resulting changes to the offers
+func printPlan(l logger.Logger, bot *trader.Trader, botConfig trader.BotConfig) {
+	plan, e := bot.Plan()
+	if e != nil {
+		logger.Fatal(l, fmt.Errorf("could not plan the next update: %s", e))
+	}
+
+	fmt.Printf("balances: %s=%.8f, %s=%.8f\n",
+		utils.Asset2CodeString(botConfig.AssetBase()),
+		plan.BaseBalance.Balance,
+		utils.Asset2CodeString(botConfig.AssetQuote()),
+		plan.QuoteBalance.Balance,
+	)
+	fmt.Printf("existing offers: %d sell, %d buy\n", plan.NumSellOffers, plan.NumBuyOffers)
+	fmt.Printf("prices are in units of %s and amounts are in units of %s\n",
+		utils.Asset2CodeString(botConfig.AssetQuote()),
+		utils.Asset2CodeString(botConfig.AssetBase()),
+	)
+	fmt.Println()
+	for _, c := range plan.Changes {
+		fmt.Println(c)
+	}
+	if len(plan.Changes) > 0 {
+		fmt.Println()
+	}
+
+	numCreate, numModify, numDelete := plan.Counts()
+	fmt.Printf("plan: %d to create, %d to modify, %d to delete", numCreate, numModify, numDelete)
+	if plan.NumFilteredOps > 0 {
+		fmt.Printf(" (%d operations were dropped by the submit filters)", plan.NumFilteredOps)
+	}
+	fmt.Println()
+}
@@ -78,6 +78,13 @@ func init() {
 	RootCmd.AddCommand(strategiesCmd)
 	RootCmd.AddCommand(exchangesCmd)
 	RootCmd.AddCommand(terminateCmd)
+	RootCmd.AddCommand(keystoreCmd)
+	RootCmd.AddCommand(validateCmd)
+	RootCmd.AddCommand(planCmd)
+	RootCmd.AddCommand(cancelAllCmd)
+	RootCmd.AddCommand(balancesCmd)
+	RootCmd.AddCommand(takeCmd)
+	RootCmd.AddCommand(exportCmd)
 	RootCmd.AddCommand(versionCmd)
 }
 
@@ -29,11 +29,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/support/config"
 	"github.com/stellar/go/support/errors"
 	"github.com/stellar/kelp/gui"
 	"github.com/stellar/kelp/gui/backend"
 	"github.com/stellar/kelp/plugins"
 	"github.com/stellar/kelp/support/kelpos"
+	"github.com/stellar/kelp/support/keystore"
 	"github.com/stellar/kelp/support/logger"
 	"github.com/stellar/kelp/support/networking"
 	"github.com/stellar/kelp/support/prefs"
@@ -67,6 +69,8 @@ type serverInputs struct {
 	noHeaders         *bool
 	verbose           *bool
 	noElectron        *bool
+	authConfigPath    *string
+	keystorePath      *string
 }
 
 func init() {
@@ -79,6 +83,8 @@ func init() {
 	options.noHeaders = serverCmd.Flags().Bool("no-headers", false, "do not use Amplitude or set X-App-Name and X-App-Version headers on requests to horizon")
 	options.verbose = serverCmd.Flags().BoolP("verbose", "v", false, "enable verbose log lines typically used for debugging")
 	options.noElectron = serverCmd.Flags().Bool("no-electron", false, "open in browser instead of using electron")
+	options.authConfigPath = serverCmd.Flags().String("auth-config", "", "(optional) path to the auth config file, enables login with roles and an audit log for the API server (needed when running on a shared host)")
+	options.keystorePath = serverCmd.Flags().String("keystore", "", "(optional) path to an encrypted keystore file (created if missing) where secrets of bots are written instead of in plaintext, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for")
 
 	serverCmd.Run = func(ccmd *cobra.Command, args []string) {
 		isLocalMode := env == envDev
@@ -333,10 +339,19 @@ func init() {
 		dataPath := kos.GetDotKelpWorkingDir().Join("bot_data")
 		botConfigsPath := dataPath.Join("configs")
 		botLogsPath := dataPath.Join("logs")
+		auth, e := makeAuthenticator(*options.authConfigPath, dataPath)
+		if e != nil {
+			panic(e)
+		}
+		ks, e := makeKeystore(*options.keystorePath)
+		if e != nil {
+			panic(e)
+		}
 		s, e := backend.MakeAPIServer(
 			kos,
 			botConfigsPath,
 			botLogsPath,
+			dataPath.Join("bot_registry.json"),
 			*options.horizonTestnetURI,
 			apiTestNet,
 			*options.horizonPubnetURI,
@@ -345,6 +360,8 @@ func init() {
 			*options.noHeaders,
 			quit,
 			metricsTracker,
+			auth,
+			ks,
 		)
 		if e != nil {
 			panic(e)
@@ -409,6 +426,76 @@ func init() {
 	}
 }
 
+// makeAuthenticator returns nil when no auth config is specified, which leaves the API server open to anyone who can reach the port
+func makeAuthenticator(authConfigPath string, dataPath *kelpos.OSPath) (*backend.Authenticator, error) {
+	if authConfigPath == "" {
+		log.Printf("no auth config specified, API server will not require a login\n")
+		return nil, nil
+	}
+
+	var authConfig backend.AuthConfig
+	e := config.Read(authConfigPath, &authConfig)
+	if e != nil {
+		return nil, fmt.Errorf("could not read auth config file at path '%s': %s", authConfigPath, e)
+	}
+	log.Printf("read auth config: %s\n", authConfig)
+
+	auditLogPath := authConfig.AuditLogFile
+	if auditLogPath == "" {
+		auditLogPath = dataPath.Join("audit.log").Native()
+	}
+	e = os.MkdirAll(filepath.Dir(auditLogPath), 0700)
+	if e != nil {
+		return nil, fmt.Errorf("could not create directory for audit log file at path '%s': %s", auditLogPath, e)
+	}
+	auditLog, e := os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if e != nil {
+		return nil, fmt.Errorf("could not open audit log file at path '%s': %s", auditLogPath, e)
+	}
+	log.Printf("writing audit log to file: %s\n", auditLogPath)
+
+	auth, e := backend.MakeAuthenticator(authConfig, auditLog)
+	if e != nil {
+		return nil, fmt.Errorf("invalid auth config: %s", e)
+	}
+	return auth, nil
+}
+
+// makeKeystore returns nil when no keystore is specified, in which case secrets are written to the bot configs in plaintext
+func makeKeystore(keystorePath string) (*keystore.Keystore, error) {
+	if keystorePath == "" {
+		log.Printf("no keystore specified, secrets will be written to bot configs in plaintext\n")
+		return nil, nil
+	}
+
+	// bots are started from a different working directory so they need the absolute path
+	keystorePath, e := filepath.Abs(keystorePath)
+	if e != nil {
+		return nil, fmt.Errorf("could not get absolute path of keystore file '%s': %s", keystorePath, e)
+	}
+	passphrase, e := keystore.ReadPassphrase(keystorePath)
+	if e != nil {
+		return nil, e
+	}
+	ks, e := keystore.OpenOrCreate(keystorePath, passphrase)
+	if e != nil {
+		return nil, e
+	}
+	// save so the file exists for the bots even if no secrets were added yet
+	e = ks.Save()
+	if e != nil {
+		return nil, e
+	}
+
+	// bots started by the server inherit its environment, which is how they unlock the keystore without a prompt
+	e = os.Setenv(keystore.PassphraseEnvVar, passphrase)
+	if e != nil {
+		return nil, fmt.Errorf("could not set %s for bots: %s", keystore.PassphraseEnvVar, e)
+	}
+	log.Printf("writing secrets of bots to keystore: %s\n", keystorePath)
+	return ks, nil
+}
+
 func checkIsCcxtUpTwice(ccxtURL string) error {
 	e := isCcxtUp(ccxtURL)
 	if e != nil {
@@ -431,7 +518,22 @@ func setMiddleware(r *chi.Mux) {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeoutMiddleware(60 * time.Second))
+}
+
+// timeoutMiddleware applies the timeout to all requests except for long-lived streaming requests
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+	return func(next http.Handler) http.Handler {
+		nextWithTimeout := withTimeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if backend.IsStreamingRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			nextWithTimeout.ServeHTTP(w, r)
+		})
+	}
 }
 
 func copyCcxtFolder(
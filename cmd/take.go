@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+const takeExamples = `  kelp take --botConf ./path/trader.cfg --send XLM --send-amount 100 --max-slippage 0.01
+  kelp take --botConf ./path/trader.cfg --send XLM --dest-amount 10 --max-slippage 0.005 --yes`
+
+var takeCmd = &cobra.Command{
+	Use:     "take",
+	Short:   "Takes liquidity on SDEX with a path payment between the base and quote assets of a bot, without leaving an offer on the book",
+	Example: takeExamples,
+	Args:    cobra.NoArgs,
+}
+
+func init() {
+	options := addOperatorFlags(takeCmd)
+	send := takeCmd.Flags().String("send", "", "code of the asset to send, needs to be the base or quote asset of the trader config file, the other asset is received")
+	sendAmount := takeCmd.Flags().String("send-amount", "", "exact amount of the send asset to send, cannot be used with --dest-amount")
+	destAmount := takeCmd.Flags().String("dest-amount", "", "exact amount of the other asset to receive, cannot be used with --send-amount")
+	maxSlippage := takeCmd.Flags().Float64("max-slippage", 0.01, "fraction by which the executed amount can be worse than the amount quoted by horizon (0.01 = 1%)")
+	yes := takeCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation before taking")
+
+	takeCmd.Run = func(ccmd *cobra.Command, args []string) {
+		l := logger.MakeBasicLogger()
+		target, e := makeOperatorTarget(l, options)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		if target.botConfig == nil || !target.botConfig.IsTradingSdex() {
+			logger.Fatal(l, fmt.Errorf("take needs a trader config file (--botConf) that trades on SDEX"))
+		}
+
+		request, e := makeTakeRequest(target.botConfig, *send, *sendAmount, *destAmount, *maxSlippage)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		if !*yes && !confirm(fmt.Sprintf("%s? our offers that the path payment could cross are deleted in the same transaction", request)) {
+			logger.Fatal(l, fmt.Errorf("cancelled by user, nothing was taken"))
+		}
+
+		result, e := request.take(target.sdex)
+		if e != nil {
+			logger.Fatal(l, e)
+		}
+		l.Infof("%s\n", result)
+	}
+}
+
+// takeRequest is a validated request to take liquidity, exactly one of sendAmount and destAmount is set
+type takeRequest struct {
+	sendAsset   hProtocol.Asset
+	destAsset   hProtocol.Asset
+	sendAmount  *model.Number
+	destAmount  *model.Number
+	maxSlippage float64
+}
+
+// String is the Stringer method
+func (r *takeRequest) String() string {
+	if r.sendAmount != nil {
+		return fmt.Sprintf("send exactly %s %s for %s with a max slippage of %.2f%%", r.sendAmount.AsString(), utils.Asset2CodeString(r.sendAsset), utils.Asset2CodeString(r.destAsset), r.maxSlippage*100)
+	}
+	return fmt.Sprintf("receive exactly %s %s for %s with a max slippage of %.2f%%", r.destAmount.AsString(), utils.Asset2CodeString(r.destAsset), utils.Asset2CodeString(r.sendAsset), r.maxSlippage*100)
+}
+
+func makeTakeRequest(botConfig *trader.BotConfig, send string, sendAmount string, destAmount string, maxSlippage float64) (*takeRequest, error) {
+	base := botConfig.AssetBase()
+	quote := botConfig.AssetQuote()
+	request := &takeRequest{maxSlippage: maxSlippage}
+	switch strings.ToUpper(send) {
+	case strings.ToUpper(utils.Asset2CodeString(base)):
+		request.sendAsset, request.destAsset = base, quote
+	case strings.ToUpper(utils.Asset2CodeString(quote)):
+		request.sendAsset, request.destAsset = quote, base
+	default:
+		return nil, fmt.Errorf("--send needs to be the base asset (%s) or the quote asset (%s) of the trader config file, was '%s'", utils.Asset2CodeString(base), utils.Asset2CodeString(quote), send)
+	}
+
+	if (sendAmount == "") == (destAmount == "") {
+		return nil, fmt.Errorf("need exactly one of --send-amount and --dest-amount")
+	}
+	var e error
+	if sendAmount != "" {
+		request.sendAmount, e = model.NumberFromString(sendAmount, utils.SdexPrecision)
+		if e != nil {
+			return nil, fmt.Errorf("invalid --send-amount '%s': %s", sendAmount, e)
+		}
+	} else {
+		request.destAmount, e = model.NumberFromString(destAmount, utils.SdexPrecision)
+		if e != nil {
+			return nil, fmt.Errorf("invalid --dest-amount '%s': %s", destAmount, e)
+		}
+	}
+	return request, nil
+}
+
+func (r *takeRequest) take(taker api.PathPaymentTaker) (*api.TakerResult, error) {
+	if r.sendAmount != nil {
+		return taker.TakeStrictSend(r.sendAsset, r.sendAmount, r.destAsset, r.maxSlippage)
+	}
+	return taker.TakeStrictReceive(r.sendAsset, r.destAsset, r.destAmount, r.maxSlippage)
+}
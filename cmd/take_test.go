@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"testing"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/trader"
+)
+
+const testTakeIssuer = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+
+// recordingTaker records the method that was called
+type recordingTaker struct {
+	calls []string
+}
+
+var _ api.PathPaymentTaker = &recordingTaker{}
+
+func (r *recordingTaker) TakeStrictSend(sendAsset hProtocol.Asset, sendAmount *model.Number, destAsset hProtocol.Asset, maxSlippage float64) (*api.TakerResult, error) {
+	r.calls = append(r.calls, "strictSend "+sendAmount.AsString()+" "+sendAsset.Type+"->"+destAsset.Code)
+	return &api.TakerResult{}, nil
+}
+
+func (r *recordingTaker) TakeStrictReceive(sendAsset hProtocol.Asset, destAsset hProtocol.Asset, destAmount *model.Number, maxSlippage float64) (*api.TakerResult, error) {
+	r.calls = append(r.calls, "strictReceive "+destAmount.AsString()+" "+sendAsset.Code+"->"+destAsset.Type)
+	return &api.TakerResult{}, nil
+}
+
+func TestMakeTakeRequest(t *testing.T) {
+	botConfig := trader.BotConfig{
+		TradingSecretSeed: "SAEZSI6DY7AXJFIYA4PM6SIBNEYYXIEM2MSOTHFGKHDW32MBQ7KVO6EN",
+		AssetCodeA:        "XLM",
+		AssetCodeB:        "USD",
+		IssuerB:           testTakeIssuer,
+	}
+	if !assert.NoError(t, botConfig.Init()) {
+		return
+	}
+
+	testCases := []struct {
+		name       string
+		send       string
+		sendAmount string
+		destAmount string
+		wantCall   string
+		wantErr    bool
+	}{
+		{
+			name:       "send base",
+			send:       "xlm",
+			sendAmount: "100",
+			wantCall:   "strictSend 100.0000000 native->USD",
+		}, {
+			name:       "receive base",
+			send:       "USD",
+			destAmount: "12.5",
+			wantCall:   "strictReceive 12.5000000 USD->native",
+		}, {
+			name:       "unknown asset",
+			send:       "BTC",
+			sendAmount: "1",
+			wantErr:    true,
+		}, {
+			name:    "no amount",
+			send:    "XLM",
+			wantErr: true,
+		}, {
+			name:       "both amounts",
+			send:       "XLM",
+			sendAmount: "1",
+			destAmount: "1",
+			wantErr:    true,
+		}, {
+			name:       "invalid amount",
+			send:       "XLM",
+			sendAmount: "abc",
+			wantErr:    true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			request, e := makeTakeRequest(&botConfig, k.send, k.sendAmount, k.destAmount, 0.01)
+			if k.wantErr {
+				assert.Error(t, e)
+				return
+			}
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			taker := &recordingTaker{}
+			_, e = request.take(taker)
+			if assert.NoError(t, e) {
+				assert.Equal(t, []string{k.wantCall}, taker.calls)
+			}
+		})
+	}
+}
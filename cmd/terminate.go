@@ -9,20 +9,25 @@ import (
 	"github.com/stellar/go/clients/horizonclient"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/stellar/go/support/config"
+	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/kelp/model"
 	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/support/database"
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/monitoring"
 	"github.com/stellar/kelp/support/utils"
 	"github.com/stellar/kelp/terminator"
 )
 
 var terminateCmd = &cobra.Command{
-	Hidden: true,
-	Use:    "terminate",
-	Short:  "Monitors a Stellar Account and terminates offers across all inactive bots",
+	Use:   "terminate",
+	Short: "Monitors a Stellar Account and terminates offers across all inactive bots",
+	Long:  "Monitors a Stellar Account and terminates offers across all inactive bots. Bots write a heartbeat to the POSTGRES_DB in their trader config file after every successful update, the terminator deletes the offers of bots whose heartbeat is older than ALLOW_INACTIVE_MINUTES and triggers an alert.",
 }
 
 func init() {
 	configPath := terminateCmd.Flags().StringP("conf", "c", "./terminator.cfg", "service's basic config file path")
+	keystorePath := terminateCmd.Flags().String("keystore", "", "encrypted keystore file used to resolve 'keystore:<name>' references in the config file")
 
 	terminateCmd.Run = func(ccmd *cobra.Command, args []string) {
 		log.Println("Starting Terminator: " + version + " [" + gitHash + "]")
@@ -30,6 +35,14 @@ func init() {
 		var configFile terminator.Config
 		err := config.Read(*configPath, &configFile)
 		utils.CheckConfigError(configFile, err, *configPath)
+		err = utils.ExpandEnvRefs(&configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = configFile.ResolveSecrets(utils.ChainSecretResolvers(utils.ResolveFileRef, keystore.MakeSecretResolver(*keystorePath)))
+		if err != nil {
+			log.Fatal(err)
+		}
 		err = configFile.Init()
 		if err != nil {
 			log.Fatal(err)
@@ -44,24 +57,20 @@ func init() {
 			AppName:    "kelp",
 			AppVersion: version,
 		}
-		sdex := plugins.MakeSDEX(
-			client,
-			plugins.MakeIEIF(true), // used true for now since it's only ever been tested on SDEX and uses SDEX's data for now
-			nil,
-			configFile.SourceSecretSeed,
-			configFile.TradingSecretSeed,
-			*configFile.SourceAccount,
-			*configFile.TradingAccount,
-			utils.ParseNetwork(configFile.HorizonURL),
-			multithreading.MakeThreadTracker(),
-			-1, // not needed here
-			-1, // not needed here
-			false,
-			nil, // not needed here
-			map[model.Asset]hProtocol.Asset{},
-			plugins.SdexFixedFeeFn(0),
-		)
-		terminator := terminator.MakeTerminator(client, sdex, *configFile.TradingAccount, configFile.TickIntervalSeconds, configFile.AllowInactiveMinutes)
+		sdex := makeTerminatorSDEX(configFile, client, utils.ParseNetwork(configFile.HorizonURL))
+		// run the same upgrade scripts as the trader so the bot_heartbeats table exists even if no bot has started yet
+		db, err := database.ConnectInitializedDatabase(configFile.PostgresDbConfig, upgradeScripts, version)
+		if err != nil {
+			log.Fatal(err)
+		}
+		alert, err := monitoring.MakeAlert(configFile.AlertType, configFile.AlertAPIKey)
+		if err != nil {
+			log.Fatalf("unable to set up monitoring for alert type '%s' with the given API key: %s\n", configFile.AlertType, err)
+		}
+		terminator, err := terminator.MakeTerminator(client, sdex, *configFile.TradingAccount, configFile.TickIntervalSeconds, configFile.AllowInactiveMinutes, db, alert)
+		if err != nil {
+			log.Fatal(err)
+		}
 		// --- end initialization of objects ----
 
 		for {
@@ -70,3 +79,33 @@ func init() {
 		}
 	}
 }
+
+// makeTerminatorSDEX makes the SDEX used to delete the offers of inactive bots, transactions pay the network minimum base fee
+// since txnbuild rejects anything lower
+func makeTerminatorSDEX(configFile terminator.Config, client *horizonclient.Client, network string) *plugins.SDEX {
+	sourceAccount := ""
+	if configFile.SourceAccount != nil {
+		sourceAccount = *configFile.SourceAccount
+	}
+
+	return plugins.MakeSDEX(
+		client,
+		plugins.MakeIEIF(true), // used true for now since it's only ever been tested on SDEX and uses SDEX's data for now
+		nil,
+		configFile.SourceSecretSeed,
+		configFile.TradingSecretSeed,
+		sourceAccount,
+		*configFile.TradingAccount,
+		network,
+		multithreading.MakeThreadTracker(),
+		-1, // not needed here
+		-1, // not needed here
+		false,
+		nil, // not needed here
+		map[model.Asset]hProtocol.Asset{},
+		plugins.SdexFixedFeeFn(txnbuild.MinBaseFee),
+		false,
+		nil,
+		"",
+	)
+}
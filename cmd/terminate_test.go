@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/postgresdb"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/terminator"
+)
+
+func TestMakeTerminatorSDEX_DeletesOffers(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+
+	issuer := keypair.MustRandom()
+	trading := keypair.MustRandom()
+	usd := hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()}
+	s.AddAccount(issuer.Address(), "100")
+	s.AddAccount(trading.Address(), "1000")
+	s.AddTrustline(trading.Address(), usd, "10000")
+	_, e := s.PlaceOffer(trading.Address(), utils.NativeAsset, usd, "10", "0.2")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// no SOURCE_SECRET_SEED, the config is loaded the same way as in the terminate command
+	configFile := terminator.Config{
+		TradingSecretSeed: trading.Seed(),
+		HorizonURL:        s.URL,
+		PostgresDbConfig:  &postgresdb.Config{}, // only needs to be set, no connection is made
+	}
+	if !assert.NoError(t, configFile.Init()) {
+		return
+	}
+	sdex := makeTerminatorSDEX(configFile, s.Client(), network.TestNetworkPassphrase)
+
+	offers, e := utils.LoadAllOffers(trading.Address(), s.Client())
+	if !assert.NoError(t, e) || !assert.Equal(t, 1, len(offers)) {
+		return
+	}
+	var submitErr error
+	e = sdex.SubmitOpsSynch(api.ConvertOperation2TM(sdex.DeleteAllOffers(offers)), api.SubmitModeBoth, func(hash string, e error) {
+		submitErr = e
+	})
+	if !assert.NoError(t, e) || !assert.NoError(t, submitErr) {
+		return
+	}
+
+	offers, e = utils.LoadAllOffers(trading.Address(), s.Client())
+	if assert.NoError(t, e) {
+		assert.Equal(t, 0, len(offers))
+	}
+}
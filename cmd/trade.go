@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,12 +20,15 @@ import (
 
 	"github.com/stellar/go/clients/horizonclient"
 	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/strkey"
 	"github.com/stellar/go/support/config"
+	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/kelp/api"
 	"github.com/stellar/kelp/kelpdb"
 	"github.com/stellar/kelp/model"
 	"github.com/stellar/kelp/plugins"
 	"github.com/stellar/kelp/support/database"
+	"github.com/stellar/kelp/support/keystore"
 	"github.com/stellar/kelp/support/logger"
 	"github.com/stellar/kelp/support/monitoring"
 	"github.com/stellar/kelp/support/networking"
@@ -58,6 +62,19 @@ var upgradeScripts = []*database.UpgradeScript{
 		kelpdb.SqlStrategyMirrorTradeTriggersTableCreate,
 		kelpdb.SqlTradesTableAlter2,
 	),
+	database.MakeUpgradeScript(7,
+		kelpdb.SqlTradesTableAlter3,
+	),
+	database.MakeUpgradeScript(8,
+		kelpdb.SqlOrdersTableCreate,
+		kelpdb.SqlOrdersIndexCreate,
+	),
+	database.MakeUpgradeScript(9,
+		kelpdb.SqlBotHeartbeatsTableCreate,
+	),
+	database.MakeUpgradeScript(10,
+		kelpdb.SqlRebalancerTransfersTableCreate,
+	),
 }
 
 const tradeExamples = `  kelp trade --botConf ./path/trader.cfg --strategy buysell --stratConf ./path/buysell.cfg
@@ -65,6 +82,18 @@ const tradeExamples = `  kelp trade --botConf ./path/trader.cfg --strategy buyse
 
 const prefsFilename = "kelp.prefs"
 
+// defaultExchangeRateLimitConfig is used when EXCHANGE_RATE_LIMIT is not specified in the trader config
+var defaultExchangeRateLimitConfig = trader.RateLimitConfig{
+	RequestsPerSecond: 0,
+	Burst:             1,
+	MaxRetries:        3,
+	BaseBackoffMillis: 500,
+	MaxBackoffMillis:  10000,
+}
+
+// defaultRemoteSignerTimeout is used when REMOTE_SIGNER.TIMEOUT_MILLIS is not specified in the trader config
+const defaultRemoteSignerTimeout = 10 * time.Second
+
 var tradeCmd = &cobra.Command{
 	Use:     "trade",
 	Short:   "Trades against the Stellar universal marketplace using the specified strategy",
@@ -108,6 +137,8 @@ type inputs struct {
 	ui                            *bool
 	cpuProfile                    *string
 	memProfile                    *string
+	keystorePath                  *string
+	planOnly                      bool // set by the plan command to print the next update instead of starting the bot
 }
 
 func validateCliParams(l logger.Logger, options inputs) {
@@ -129,29 +160,143 @@ func validateCliParams(l logger.Logger, options inputs) {
 	}
 }
 
-func validateBotConfig(l logger.Logger, botConfig trader.BotConfig) {
+func validateBotConfig(l logger.Logger, botConfig trader.BotConfig, strategy string) {
+	errs := checkBotConfig(botConfig, strategy)
+	for _, e := range errs {
+		l.Errorf("%s", e)
+	}
+	if len(errs) > 0 {
+		logger.Fatal(l, fmt.Errorf("found %d errors in the trader config file", len(errs)))
+	}
+}
+
+// checkBotConfig returns all the errors in the trader config that can be found without connecting to horizon or an exchange
+func checkBotConfig(botConfig trader.BotConfig, strategy string) []error {
+	errs := []error{}
 	if botConfig.IsTradingSdex() && botConfig.Fee == nil {
-		logger.Fatal(l, fmt.Errorf("The `FEE` object needs to exist in the trader config file when trading on SDEX"))
+		errs = append(errs, fmt.Errorf("The `FEE` object needs to exist in the trader config file when trading on SDEX"))
 	}
 
 	if !botConfig.IsTradingSdex() && botConfig.CentralizedMinBaseVolumeOverride != nil && *botConfig.CentralizedMinBaseVolumeOverride <= 0.0 {
-		logger.Fatal(l, fmt.Errorf("need to specify positive CENTRALIZED_MIN_BASE_VOLUME_OVERRIDE config param in trader config file when not trading on SDEX"))
+		errs = append(errs, fmt.Errorf("need to specify positive CENTRALIZED_MIN_BASE_VOLUME_OVERRIDE config param in trader config file when not trading on SDEX"))
 	}
 	if !botConfig.IsTradingSdex() && botConfig.CentralizedMinQuoteVolumeOverride != nil && *botConfig.CentralizedMinQuoteVolumeOverride <= 0.0 {
-		logger.Fatal(l, fmt.Errorf("need to specify positive CENTRALIZED_MIN_QUOTE_VOLUME_OVERRIDE config param in trader config file when not trading on SDEX"))
+		errs = append(errs, fmt.Errorf("need to specify positive CENTRALIZED_MIN_QUOTE_VOLUME_OVERRIDE config param in trader config file when not trading on SDEX"))
+	}
+	if e := checkPrecisionConfig(botConfig.IsTradingSdex(), botConfig.CentralizedVolumePrecisionOverride, "CENTRALIZED_VOLUME_PRECISION_OVERRIDE"); e != nil {
+		errs = append(errs, e)
+	}
+	if e := checkPrecisionConfig(botConfig.IsTradingSdex(), botConfig.CentralizedPricePrecisionOverride, "CENTRALIZED_PRICE_PRECISION_OVERRIDE"); e != nil {
+		errs = append(errs, e)
 	}
-	validatePrecisionConfig(l, botConfig.IsTradingSdex(), botConfig.CentralizedVolumePrecisionOverride, "CENTRALIZED_VOLUME_PRECISION_OVERRIDE")
-	validatePrecisionConfig(l, botConfig.IsTradingSdex(), botConfig.CentralizedPricePrecisionOverride, "CENTRALIZED_PRICE_PRECISION_OVERRIDE")
 
 	if botConfig.SleepMode != "" && botConfig.SleepMode != trader.SleepModeBegin.String() && botConfig.SleepMode != trader.SleepModeEnd.String() {
-		logger.Fatal(l, fmt.Errorf("SLEEP_MODE needs to be set to either '%s' or '%s'", trader.SleepModeBegin, trader.SleepModeEnd))
+		errs = append(errs, fmt.Errorf("SLEEP_MODE needs to be set to either '%s' or '%s'", trader.SleepModeBegin, trader.SleepModeEnd))
+	}
+
+	if _, e := api.ParseSubmitMode(botConfig.SubmitMode); e != nil {
+		errs = append(errs, e)
+	}
+
+	if botConfig.PassiveSellOffers && !botConfig.IsTradingSdex() {
+		errs = append(errs, fmt.Errorf("PASSIVE_SELL_OFFERS can only be set to true when trading on SDEX"))
+	}
+
+	if botConfig.TxMemo != "" {
+		if !botConfig.IsTradingSdex() {
+			errs = append(errs, fmt.Errorf("TX_MEMO can only be set when trading on SDEX"))
+		}
+		if len(botConfig.TxMemo) > txnbuild.MemoTextMaxLength {
+			errs = append(errs, fmt.Errorf("TX_MEMO can be at most %d bytes long, was %d bytes", txnbuild.MemoTextMaxLength, len(botConfig.TxMemo)))
+		}
+	}
+
+	for i, seed := range botConfig.SignerSeeds {
+		if !strkey.IsValidEd25519SecretSeed(seed) {
+			errs = append(errs, fmt.Errorf("SIGNER_SEEDS needs to contain valid secret seeds, the entry at index %d is not a valid secret seed", i))
+		}
+	}
+	if botConfig.RemoteSigner != nil {
+		if botConfig.RemoteSigner.URL == "" {
+			errs = append(errs, fmt.Errorf("REMOTE_SIGNER.URL needs to be set when the REMOTE_SIGNER config is specified"))
+		}
+		if botConfig.RemoteSigner.TimeoutMillis < 0 {
+			errs = append(errs, fmt.Errorf("REMOTE_SIGNER.TIMEOUT_MILLIS needs to be greater than or equal to 0"))
+		}
+	}
+
+	if botConfig.SynchronizeStateLoadEnable && botConfig.SynchronizeStateLoadMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("SYNCHRONIZE_STATE_LOAD_MAX_RETRIES needs to be greater than or equal to 0 when SYNCHRONIZE_STATE_LOAD_ENABLE is set to true"))
+	}
+
+	if botConfig.PostgresDbConfig != nil {
+		if !botConfig.SynchronizeStateLoadEnable && botConfig.FillTrackerSleepMillis == 0 {
+			errs = append(errs, fmt.Errorf("SYNCHRONIZE_STATE_LOAD_ENABLE needs to be enabled and/or FILL_TRACKER_SLEEP_MILLIS needs to be set in the trader config file when the POSTGRES_DB is enabled so we can fetch trades to be saved in the db"))
+		}
+		if botConfig.DbAccountID() == "" {
+			errs = append(errs, fmt.Errorf("DB_OVERRIDE__ACCOUNT_ID (or TX_MEMO when trading on SDEX) needs to be set in the trader config file when the POSTGRES_DB is enabled so we can assign an account_id to trades that are fetched before writing them in the db"))
+		}
+	}
+
+	if len(botConfig.Filters) > 0 && strategy != "sell" && strategy != "sell_twap" && strategy != "buy_twap" && strategy != "delete" {
+		errs = append(errs, fmt.Errorf("FILTERS currently only supported on 'sell', 'sell_twap', 'buy_twap', 'delete' strategies, remove FILTERS from the trader config file"))
+	}
+
+	if botConfig.EventTrigger != nil {
+		if botConfig.EventTrigger.FeedType != "" && botConfig.EventTrigger.PriceChangeThreshold <= 0 {
+			errs = append(errs, fmt.Errorf("PRICE_CHANGE_THRESHOLD in EVENT_TRIGGER needs to be > 0 when FEED_TYPE is set, but was %f", botConfig.EventTrigger.PriceChangeThreshold))
+		}
+		if botConfig.EventTrigger.TriggerOnFill && botConfig.FillTrackerSleepMillis == 0 {
+			errs = append(errs, fmt.Errorf("TRIGGER_ON_FILL in EVENT_TRIGGER needs FILL_TRACKER_SLEEP_MILLIS to be set to a non-zero value"))
+		}
+	}
+
+	if botConfig.ProtectiveOrders != nil {
+		errs = append(errs, checkProtectiveOrdersConfig(botConfig)...)
+	}
+	return errs
+}
+
+func checkProtectiveOrdersConfig(botConfig trader.BotConfig) []error {
+	poConfig := botConfig.ProtectiveOrders
+	errs := []error{}
+	if botConfig.IsTradingSdex() {
+		errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS can only be set when not trading on SDEX"))
+	}
+	if poConfig.Slippage < 0 || poConfig.Slippage >= 1 {
+		errs = append(errs, fmt.Errorf("SLIPPAGE in PROTECTIVE_ORDERS needs to be in the range [0, 1), but was %f", poConfig.Slippage))
+	}
+	if len(poConfig.Orders) == 0 {
+		errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS needs at least one entry in PROTECTIVE_ORDERS.ORDERS"))
 	}
+	for i, o := range poConfig.Orders {
+		if _, e := api.ParseProtectiveOrderType(o.Type); e != nil {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: %s", i, e))
+		}
+		if o.Side != "buy" && o.Side != "sell" {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: SIDE needs to be either 'buy' or 'sell', but was '%s'", i, o.Side))
+		}
+		if o.TriggerPrice <= 0 {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: TRIGGER_PRICE needs to be > 0, but was %f", i, o.TriggerPrice))
+		}
+		if o.LimitPrice < 0 {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: LIMIT_PRICE needs to be >= 0, but was %f", i, o.LimitPrice))
+		}
+		if o.LimitPrice == 0 && poConfig.Slippage == 0 && plugins.IsLimitOrdersOnly(botConfig.TradingExchange) {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: the '%s' exchange only accepts limit orders so the order would never be sent, set LIMIT_PRICE or set SLIPPAGE in PROTECTIVE_ORDERS to send an aggressive limit order", i, botConfig.TradingExchange))
+		}
+		if o.Amount <= 0 {
+			errs = append(errs, fmt.Errorf("PROTECTIVE_ORDERS.ORDERS at index %d: AMOUNT needs to be > 0, but was %f", i, o.Amount))
+		}
+	}
+	return errs
 }
 
-func validatePrecisionConfig(l logger.Logger, isTradingSdex bool, precisionField *int8, name string) {
+func checkPrecisionConfig(isTradingSdex bool, precisionField *int8, name string) error {
 	if !isTradingSdex && precisionField != nil && *precisionField < 0 {
-		logger.Fatal(l, fmt.Errorf("need to specify non-negative %s config param in trader config file when not trading on SDEX", name))
+		return fmt.Errorf("need to specify non-negative %s config param in trader config file when not trading on SDEX", name)
 	}
+	return nil
 }
 
 func init() {
@@ -170,6 +315,7 @@ func init() {
 	options.ui = tradeCmd.Flags().Bool("ui", false, "indicates a bot that is started from the Kelp UI server")
 	options.cpuProfile = tradeCmd.Flags().String("cpuprofile", "", "write cpu profile to `file`")
 	options.memProfile = tradeCmd.Flags().String("memprofile", "", "write memory profile to `file`")
+	options.keystorePath = tradeCmd.Flags().String("keystore", "", "encrypted keystore file used to resolve 'keystore:<name>' references in config files, the passphrase is read from "+keystore.PassphraseEnvVar+" or prompted for")
 
 	requiredFlag("botConf")
 	requiredFlag("strategy")
@@ -226,6 +372,33 @@ func makeStartupMessage(options inputs) string {
 	return startupMessage
 }
 
+// makeSigner makes the signer for SDEX transactions from the SIGNER_SEEDS and REMOTE_SIGNER configs, returns nil to sign with the
+// source and trading seeds
+func makeSigner(botConfig trader.BotConfig, network string) api.Signer {
+	if len(botConfig.SignerSeeds) == 0 && botConfig.RemoteSigner == nil {
+		return nil
+	}
+
+	// the source and trading accounts can be specified as public keys when their seeds are held by the remote signer
+	localSeeds := []string{}
+	for _, seed := range append([]string{botConfig.SourceSecretSeed, botConfig.TradingSecretSeed}, botConfig.SignerSeeds...) {
+		if strkey.IsValidEd25519SecretSeed(seed) {
+			localSeeds = append(localSeeds, seed)
+		}
+	}
+	signers := []api.Signer{plugins.MakeLocalSigner(localSeeds...)}
+
+	if botConfig.RemoteSigner != nil {
+		timeout := defaultRemoteSignerTimeout
+		if botConfig.RemoteSigner.TimeoutMillis > 0 {
+			timeout = time.Duration(botConfig.RemoteSigner.TimeoutMillis) * time.Millisecond
+		}
+		signers = append(signers, plugins.MakeRemoteSigner(botConfig.RemoteSigner.URL, botConfig.RemoteSigner.AuthToken, network, timeout))
+		log.Printf("signing transactions with a remote signer at %s\n", botConfig.RemoteSigner.URL)
+	}
+	return plugins.MakeMultiSigner(signers...)
+}
+
 func makeFeeFn(l logger.Logger, botConfig trader.BotConfig, newClient *horizonclient.Client) plugins.OpFeeStroops {
 	if !botConfig.IsTradingSdex() {
 		return plugins.SdexFixedFeeFn(0)
@@ -243,11 +416,28 @@ func makeFeeFn(l logger.Logger, botConfig trader.BotConfig, newClient *horizoncl
 	return feeFn
 }
 
-func readBotConfig(l logger.Logger, options inputs, botStartTime time.Time) trader.BotConfig {
+// loadBotConfig reads the trader config file and resolves the references to environment variables and secrets in it
+func loadBotConfig(botConfigPath string, resolveSecret utils.SecretResolver) (trader.BotConfig, error) {
 	var botConfig trader.BotConfig
-	e := config.Read(*options.botConfigPath, &botConfig)
-	utils.CheckConfigError(botConfig, e, *options.botConfigPath)
+	e := config.Read(botConfigPath, &botConfig)
+	utils.CheckConfigError(botConfig, e, botConfigPath)
+	e = utils.ExpandEnvRefs(&botConfig)
+	if e != nil {
+		return trader.BotConfig{}, fmt.Errorf("could not expand environment variables in trader config file '%s': %s", botConfigPath, e)
+	}
+	e = botConfig.ResolveSecrets(resolveSecret)
+	if e != nil {
+		return trader.BotConfig{}, e
+	}
 	e = botConfig.Init()
+	if e != nil {
+		return trader.BotConfig{}, e
+	}
+	return botConfig, nil
+}
+
+func readBotConfig(l logger.Logger, options inputs, botStartTime time.Time, resolveSecret utils.SecretResolver) trader.BotConfig {
+	botConfig, e := loadBotConfig(*options.botConfigPath, resolveSecret)
 	if e != nil {
 		logger.Fatal(l, e)
 	}
@@ -263,11 +453,53 @@ func readBotConfig(l logger.Logger, options inputs, botStartTime time.Time) trad
 
 	// only log botConfig file here so it can be included in the log file
 	utils.LogConfig(botConfig)
-	validateBotConfig(l, botConfig)
+	validateBotConfig(l, botConfig, *options.strategy)
 
 	return botConfig
 }
 
+// makeExchangeAPI makes the rate limited trading exchange of a trader config that is not trading on SDEX
+func makeExchangeAPI(botConfig trader.BotConfig, simMode bool) (api.Exchange, error) {
+	exchangeParams := []api.ExchangeParam{}
+	for _, param := range botConfig.ExchangeParams {
+		exchangeParams = append(exchangeParams, api.ExchangeParam{
+			Param: param.Param,
+			Value: param.Value,
+		})
+	}
+
+	exchangeHeaders := []api.ExchangeHeader{}
+	for _, header := range botConfig.ExchangeHeaders {
+		exchangeHeaders = append(exchangeHeaders, api.ExchangeHeader{
+			Header: header.Header,
+			Value:  header.Value,
+		})
+	}
+
+	exchangeAPIKeys := botConfig.ExchangeAPIKeys.ToExchangeAPIKeys()
+	exchangeAPI, e := plugins.MakeTradingExchange(botConfig.TradingExchange, exchangeAPIKeys, exchangeParams, exchangeHeaders, simMode)
+	if e != nil {
+		return nil, fmt.Errorf("unable to make trading exchange: %s", e)
+	}
+
+	rateLimitConfig := botConfig.ExchangeRateLimit
+	if rateLimitConfig == nil {
+		rateLimitConfig = &defaultExchangeRateLimitConfig
+	}
+	exchangeAPI, e = plugins.MakeRateLimitedExchange(
+		exchangeAPI,
+		rateLimitConfig.RequestsPerSecond,
+		rateLimitConfig.Burst,
+		rateLimitConfig.MaxRetries,
+		time.Duration(rateLimitConfig.BaseBackoffMillis)*time.Millisecond,
+		time.Duration(rateLimitConfig.MaxBackoffMillis)*time.Millisecond,
+	)
+	if e != nil {
+		return nil, fmt.Errorf("unable to make rate limited exchange from EXCHANGE_RATE_LIMIT config: %s", e)
+	}
+	return exchangeAPI, nil
+}
+
 func makeExchangeShimSdex(
 	l logger.Logger,
 	botConfig trader.BotConfig,
@@ -278,35 +510,18 @@ func makeExchangeShimSdex(
 	threadTracker *multithreading.ThreadTracker,
 	tradingPair *model.TradingPair,
 	sdexAssetMap map[model.Asset]hProtocol.Asset,
-) (api.ExchangeShim, *plugins.SDEX) {
-	var e error
+) (api.ExchangeShim, *plugins.SDEX, api.Exchange) {
 	var exchangeShim api.ExchangeShim
+	var exchangeAPI api.Exchange
 	if !botConfig.IsTradingSdex() {
-		exchangeParams := []api.ExchangeParam{}
-		for _, param := range botConfig.ExchangeParams {
-			exchangeParams = append(exchangeParams, api.ExchangeParam{
-				Param: param.Param,
-				Value: param.Value,
-			})
-		}
-
-		exchangeHeaders := []api.ExchangeHeader{}
-		for _, header := range botConfig.ExchangeHeaders {
-			exchangeHeaders = append(exchangeHeaders, api.ExchangeHeader{
-				Header: header.Header,
-				Value:  header.Value,
-			})
-		}
-
-		exchangeAPIKeys := botConfig.ExchangeAPIKeys.ToExchangeAPIKeys()
-		var exchangeAPI api.Exchange
-		exchangeAPI, e = plugins.MakeTradingExchange(botConfig.TradingExchange, exchangeAPIKeys, exchangeParams, exchangeHeaders, *options.simMode)
+		var e error
+		exchangeAPI, e = makeExchangeAPI(botConfig, *options.simMode)
 		if e != nil {
-			logger.Fatal(l, fmt.Errorf("unable to make trading exchange: %s", e))
-			return nil, nil
+			logger.Fatal(l, e)
+			return nil, nil, nil
 		}
 
-		exchangeShim = plugins.MakeBatchedExchange(exchangeAPI, *options.simMode, botConfig.AssetBase(), botConfig.AssetQuote(), botConfig.TradingAccount())
+		exchangeShim = plugins.MakeBatchedExchange(exchangeAPI, *options.simMode, botConfig.AssetBase(), botConfig.AssetQuote(), botConfig.TradingAccount(), botConfig.BotID())
 
 		// update precision overrides
 		exchangeShim.OverrideOrderConstraints(tradingPair, model.MakeOrderConstraintsOverride(
@@ -337,6 +552,7 @@ func makeExchangeShimSdex(
 	}
 
 	feeFn := makeFeeFn(l, botConfig, client)
+	signer := makeSigner(botConfig, network)
 	sdex := plugins.MakeSDEX(
 		client,
 		ieif,
@@ -353,12 +569,15 @@ func makeExchangeShimSdex(
 		tradingPair,
 		sdexAssetMap,
 		feeFn,
+		botConfig.PassiveSellOffers,
+		signer,
+		botConfig.TxMemo,
 	)
 
 	if botConfig.IsTradingSdex() {
 		exchangeShim = sdex
 	}
-	return exchangeShim, sdex
+	return exchangeShim, sdex, exchangeAPI
 }
 
 func makeStrategy(
@@ -377,7 +596,9 @@ func makeStrategy(
 	options inputs,
 	threadTracker *multithreading.ThreadTracker,
 	db *sql.DB,
+	alert api.Alert,
 	metricsTracker *plugins.MetricsTracker,
+	resolveSecret utils.SecretResolver,
 ) api.Strategy {
 	// setting the temp hack variables for the sdex price feeds
 	e := plugins.SetPrivateSdexHack(client, plugins.MakeIEIF(true), network)
@@ -403,6 +624,8 @@ func makeStrategy(
 		botConfig.IsTradingSdex(),
 		filterFactory,
 		db,
+		alert,
+		resolveSecret,
 	)
 	if e != nil {
 		l.Info("")
@@ -413,12 +636,65 @@ func makeStrategy(
 	return strategy
 }
 
+func makeEventTimeController(
+	l logger.Logger,
+	botConfig trader.BotConfig,
+	client *horizonclient.Client,
+	sdex *plugins.SDEX,
+	exchangeShim api.ExchangeShim,
+	fillTracker api.FillTracker,
+	threadTracker *multithreading.ThreadTracker,
+	metricsTracker *plugins.MetricsTracker,
+) api.TimeController {
+	eventConfig := botConfig.EventTrigger
+	var feed api.PriceFeed
+	if eventConfig.FeedType != "" {
+		var e error
+		feed, e = plugins.MakePriceFeed(eventConfig.FeedType, eventConfig.FeedURL)
+		if e != nil {
+			log.Println()
+			log.Println(fmt.Errorf("could not make price feed for EVENT_TRIGGER: %s", e))
+			// we want to delete all the offers and exit here since there is something wrong with our setup
+			deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+		}
+	}
+
+	eventTimeController := plugins.MakeEventTimeController(
+		time.Duration(botConfig.TickIntervalMillis)*time.Millisecond,
+		time.Duration(eventConfig.MinIntervalMillis)*time.Millisecond,
+		time.Duration(eventConfig.MaxIdleMillis)*time.Millisecond,
+		feed,
+		eventConfig.PriceChangeThreshold,
+	)
+
+	if eventConfig.TriggerOnFill {
+		// fills are only picked up in between update cycles when the fill tracker runs in the background
+		if fillTracker == nil || botConfig.FillTrackerSleepMillis == 0 {
+			log.Println()
+			log.Println("TRIGGER_ON_FILL in EVENT_TRIGGER needs FILL_TRACKER_SLEEP_MILLIS to be set to a non-zero value")
+			// we want to delete all the offers and exit here since there is something wrong with our setup
+			deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+		}
+		fillTracker.RegisterHandler(eventTimeController)
+	}
+
+	l.Infof("using event trigger with minInterval=%dms, maxIdle=%dms, feedType=%s, priceChangeThreshold=%f, triggerOnFill=%v\n",
+		eventConfig.MinIntervalMillis,
+		eventConfig.MaxIdleMillis,
+		eventConfig.FeedType,
+		eventConfig.PriceChangeThreshold,
+		eventConfig.TriggerOnFill,
+	)
+	return eventTimeController
+}
+
 func makeBot(
 	l logger.Logger,
 	botConfig trader.BotConfig,
 	client *horizonclient.Client,
 	sdex *plugins.SDEX,
 	exchangeShim api.ExchangeShim,
+	exchangeAPI api.Exchange, // nil when trading on SDEX
 	ieif *plugins.IEIF,
 	tradingPair *model.TradingPair,
 	filterFactory *plugins.FilterFactory,
@@ -428,11 +704,34 @@ func makeBot(
 	options inputs,
 	metricsTracker *plugins.MetricsTracker,
 	botStartTime time.Time,
+	orderTracker *plugins.OrderTracker,
+	heartbeatWriter *plugins.BotHeartbeatWriter,
+	alert api.Alert,
 ) *trader.Trader {
-	timeController := plugins.MakeIntervalTimeController(
-		time.Duration(botConfig.TickIntervalMillis)*time.Millisecond,
-		botConfig.MaxTickDelayMillis,
-	)
+	var timeController api.TimeController
+	if botConfig.EventTrigger != nil {
+		timeController = makeEventTimeController(l, botConfig, client, sdex, exchangeShim, fillTracker, threadTracker, metricsTracker)
+	} else {
+		timeController = plugins.MakeIntervalTimeController(
+			time.Duration(botConfig.TickIntervalMillis)*time.Millisecond,
+			botConfig.MaxTickDelayMillis,
+		)
+	}
+	if len(botConfig.TradingWindows) > 0 || len(botConfig.TradingBlackouts) > 0 {
+		var e error
+		timeController, e = plugins.MakeScheduleTimeController(
+			timeController,
+			botConfig.TradingWindows,
+			botConfig.TradingBlackouts,
+			botConfig.TradingWindowTimezone,
+		)
+		if e != nil {
+			log.Println()
+			log.Println(e)
+			// we want to delete all the offers and exit here since there is something wrong with our setup
+			deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+		}
+	}
 	submitMode, e := api.ParseSubmitMode(botConfig.SubmitMode)
 	if e != nil {
 		log.Println()
@@ -441,20 +740,9 @@ func makeBot(
 		deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
 	}
 
-	if botConfig.SynchronizeStateLoadEnable && botConfig.SynchronizeStateLoadMaxRetries < 0 {
-		log.Println()
-		utils.PrintErrorHintf("SYNCHRONIZE_STATE_LOAD_MAX_RETRIES needs to be greater than or equal to 0 when SYNCHRONIZE_STATE_LOAD_ENABLE is set to true")
-		// we want to delete all the offers and exit here since there is something wrong with our setup
-		deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
-	}
-
 	assetBase := botConfig.AssetBase()
 	assetQuote := botConfig.AssetQuote()
 	dataKey := model.MakeSortedBotKey(assetBase, assetQuote)
-	alert, e := monitoring.MakeAlert(botConfig.AlertType, botConfig.AlertAPIKey)
-	if e != nil {
-		l.Infof("Unable to set up monitoring for alert type '%s' with the given API key\n", botConfig.AlertType)
-	}
 
 	var valueBaseFeed api.PriceFeed
 	var valueQuoteFeed api.PriceFeed
@@ -483,12 +771,6 @@ func makeBot(
 			plugins.MakeFilterMakerMode(exchangeShim, sdex, tradingPair),
 		)
 	}
-	if len(botConfig.Filters) > 0 && *options.strategy != "sell" && *options.strategy != "sell_twap" && *options.strategy != "buy_twap" && *options.strategy != "delete" {
-		log.Println()
-		utils.PrintErrorHintf("FILTERS currently only supported on 'sell', 'sell_twap', 'buy_twap', 'delete' strategies, remove FILTERS from the trader config file")
-		// we want to delete all the offers and exit here since there is something wrong with our setup
-		deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
-	}
 	for _, filterString := range botConfig.Filters {
 		filter, e := filterFactory.MakeFilter(filterString)
 		if e != nil {
@@ -506,6 +788,17 @@ func makeBot(
 	)
 	// end make filters
 
+	var protectiveOrders *plugins.ProtectiveOrders
+	if botConfig.ProtectiveOrders != nil {
+		protectiveOrders, e = makeProtectiveOrders(botConfig.ProtectiveOrders, exchangeAPI, tradingPair, botConfig.BotID(), alert, *options.simMode)
+		if e != nil {
+			log.Println()
+			log.Println(e)
+			// we want to delete all the offers and exit here since there is something wrong with our setup
+			deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+		}
+	}
+
 	return trader.MakeTrader(
 		client,
 		ieif,
@@ -531,9 +824,61 @@ func makeBot(
 		alert,
 		metricsTracker,
 		botStartTime,
+		orderTracker,
+		heartbeatWriter,
+		protectiveOrders,
 	)
 }
 
+func makeProtectiveOrders(
+	poConfig *trader.ProtectiveOrdersConfig,
+	exchangeAPI api.Exchange,
+	tradingPair *model.TradingPair,
+	botID string,
+	alert api.Alert,
+	simMode bool,
+) (*plugins.ProtectiveOrders, error) {
+	if exchangeAPI == nil {
+		return nil, fmt.Errorf("PROTECTIVE_ORDERS can only be set when not trading on SDEX")
+	}
+	oc := exchangeAPI.GetOrderConstraints(tradingPair)
+
+	var feed api.PriceFeed
+	if poConfig.FeedType != "" {
+		var e error
+		feed, e = plugins.MakePriceFeed(poConfig.FeedType, poConfig.FeedURL)
+		if e != nil {
+			return nil, fmt.Errorf("could not make price feed for PROTECTIVE_ORDERS: %s", e)
+		}
+	}
+
+	orders := []*api.ProtectiveOrder{}
+	for _, o := range poConfig.Orders {
+		orderType, e := api.ParseProtectiveOrderType(o.Type)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse protective order type: %s", e)
+		}
+		var limitPrice *model.Number
+		if o.LimitPrice > 0 {
+			limitPrice = model.NumberFromFloat(o.LimitPrice, oc.PricePrecision)
+		}
+		orders = append(orders, &api.ProtectiveOrder{
+			Type:         orderType,
+			OrderAction:  model.OrderActionFromString(o.Side),
+			TriggerPrice: model.NumberFromFloat(o.TriggerPrice, oc.PricePrecision),
+			LimitPrice:   limitPrice,
+			Volume:       model.NumberFromFloat(o.Amount, oc.VolumePrecision),
+		})
+	}
+
+	protectiveOrders, e := plugins.MakeProtectiveOrders(exchangeAPI, tradingPair, botID, orders, feed, alert, poConfig.Slippage, simMode)
+	if e != nil {
+		return nil, fmt.Errorf("could not make protective orders: %s", e)
+	}
+	log.Printf("using %d protective orders on %s with feedType=%s, slippage=%f\n", len(orders), tradingPair, poConfig.FeedType, poConfig.Slippage)
+	return protectiveOrders, nil
+}
+
 func convertDeprecatedBotConfigValues(l logger.Logger, botConfig trader.BotConfig) trader.BotConfig {
 	if botConfig.CentralizedMinBaseVolumeOverride != nil && botConfig.MinCentralizedBaseVolumeDeprecated != nil {
 		l.Infof("deprecation warning: cannot set both '%s' (deprecated) and '%s' in the trader config, using value from '%s'\n", "MIN_CENTRALIZED_BASE_VOLUME", "CENTRALIZED_MIN_BASE_VOLUME_OVERRIDE", "CENTRALIZED_MIN_BASE_VOLUME_OVERRIDE")
@@ -559,7 +904,8 @@ func convertDeprecatedBotConfigValues(l logger.Logger, botConfig trader.BotConfi
 func runTradeCmd(options inputs) {
 	l := logger.MakeBasicLogger()
 	botStartTime := time.Now()
-	botConfig := readBotConfig(l, options, botStartTime)
+	resolveSecret := utils.ChainSecretResolvers(utils.ResolveFileRef, keystore.MakeSecretResolver(*options.keystorePath))
+	botConfig := readBotConfig(l, options, botStartTime, resolveSecret)
 	botConfig = convertDeprecatedBotConfigValues(l, botConfig)
 	l.Infof("Trading %s:%s for %s:%s\n", botConfig.AssetCodeA, botConfig.IssuerA, botConfig.AssetCodeB, botConfig.IssuerB)
 
@@ -624,9 +970,12 @@ func runTradeCmd(options inputs) {
 		logger.Fatal(l, fmt.Errorf("could not generate metrics tracker: %s", e))
 	}
 
-	e = metricsTracker.SendStartupEvent(time.Now())
-	if e != nil {
-		l.Infof("metric - could not send startup event metric: %s", e)
+	// plan is a dry run so it does not count as a startup of the bot
+	if !options.planOnly {
+		e = metricsTracker.SendStartupEvent(time.Now())
+		if e != nil {
+			l.Infof("metric - could not send startup event metric: %s", e)
+		}
 	}
 
 	// --- start initialization of objects ----
@@ -681,19 +1030,15 @@ func runTradeCmd(options inputs) {
 	}
 
 	var db *sql.DB
-	if botConfig.PostgresDbConfig != nil {
-		if !botConfig.SynchronizeStateLoadEnable && botConfig.FillTrackerSleepMillis == 0 {
-			log.Println()
-			utils.PrintErrorHintf("SYNCHRONIZE_STATE_LOAD_ENABLE needs to be enabled and/or FILL_TRACKER_SLEEP_MILLIS needs to be set in the trader.cfg file when the POSTGRES_DB is enabled so we can fetch trades to be saved in the db")
-			logger.Fatal(l, fmt.Errorf("invalid trader.cfg config, need to set SYNCHRONIZE_STATE_LOAD_ENABLE and/or FILL_TRACKER_SLEEP_MILLIS"))
-		}
-
-		if botConfig.DbOverrideAccountID == "" {
-			log.Println()
-			utils.PrintErrorHintf("DB_OVERRIDE__ACCOUNT_ID needs to be set in the trader.cfg file when the POSTGRES_DB is enabled so we can assign an account_id to trades that are fetched before writing them in the db")
-			logger.Fatal(l, fmt.Errorf("invalid trader.cfg config, need to set DB_OVERRIDE__ACCOUNT_ID"))
+	if botConfig.PostgresDbConfig != nil && options.planOnly {
+		// plan is a dry run so we do not upgrade the db and only allow reads, such as for the volume filters
+		var e error
+		db, e = database.ConnectReadOnlyDatabase(botConfig.PostgresDbConfig)
+		if e != nil {
+			logger.Fatal(l, fmt.Errorf("problem encountered while connecting to the db: %s", e))
 		}
-
+		log.Printf("made read-only db instance with config: %s\n", botConfig.PostgresDbConfig.MakeConnectString())
+	} else if botConfig.PostgresDbConfig != nil {
 		var e error
 		db, e = database.ConnectInitializedDatabase(botConfig.PostgresDbConfig, upgradeScripts, version)
 		if e != nil {
@@ -701,7 +1046,7 @@ func runTradeCmd(options inputs) {
 		}
 		log.Printf("made db instance with config: %s\n", botConfig.PostgresDbConfig.MakeConnectString())
 	}
-	exchangeShim, sdex := makeExchangeShimSdex(
+	exchangeShim, sdex, exchangeAPI := makeExchangeShimSdex(
 		l,
 		botConfig,
 		options,
@@ -729,6 +1074,10 @@ func runTradeCmd(options inputs) {
 		logger.Fatal(l, fmt.Errorf("could not convert quote trading pair to string: %s", e))
 	}
 	marketID := plugins.MakeMarketID(botConfig.TradingExchangeName(), baseString, quoteString)
+	alert, e := monitoring.MakeAlert(botConfig.AlertType, botConfig.AlertAPIKey)
+	if e != nil {
+		l.Infof("Unable to set up monitoring for alert type '%s' with the given API key\n", botConfig.AlertType)
+	}
 	strategy := makeStrategy(
 		l,
 		network,
@@ -745,7 +1094,9 @@ func runTradeCmd(options inputs) {
 		options,
 		threadTracker,
 		db,
+		alert,
 		metricsTracker,
+		resolveSecret,
 	)
 	fillTracker := makeFillTracker(
 		l,
@@ -758,7 +1109,34 @@ func runTradeCmd(options inputs) {
 		assetDisplayFn,
 		db,
 		threadTracker,
-		botConfig.DbOverrideAccountID,
+		botConfig.DbAccountID(),
+		metricsTracker,
+	)
+	orderTracker := makeOrderTracker(
+		l,
+		botConfig,
+		client,
+		sdex,
+		exchangeShim,
+		db,
+		fillTracker,
+		threadTracker,
+		baseString,
+		quoteString,
+		options,
+		metricsTracker,
+	)
+	heartbeatWriter := makeBotHeartbeatWriter(
+		l,
+		botConfig,
+		client,
+		sdex,
+		exchangeShim,
+		db,
+		threadTracker,
+		baseString,
+		quoteString,
+		options,
 		metricsTracker,
 	)
 	bot := makeBot(
@@ -767,6 +1145,7 @@ func runTradeCmd(options inputs) {
 		client,
 		sdex,
 		exchangeShim,
+		exchangeAPI,
 		ieif,
 		tradingPair,
 		filterFactory,
@@ -776,8 +1155,15 @@ func runTradeCmd(options inputs) {
 		options,
 		metricsTracker,
 		botStartTime,
+		orderTracker,
+		heartbeatWriter,
+		alert,
 	)
 	// --- end initialization of objects ---
+	if options.planOnly {
+		printPlan(l, bot, botConfig)
+		return
+	}
 	// --- start initialization of services ---
 	validateTrustlines(l, client, &botConfig)
 	if botConfig.MonitoringPort != 0 {
@@ -940,6 +1326,88 @@ func makeFillTracker(
 	return fillTracker
 }
 
+func makeOrderTracker(
+	l logger.Logger,
+	botConfig trader.BotConfig,
+	client *horizonclient.Client,
+	sdex *plugins.SDEX,
+	exchangeShim api.ExchangeShim,
+	db *sql.DB,
+	fillTracker api.FillTracker,
+	threadTracker *multithreading.ThreadTracker,
+	baseString string,
+	quoteString string,
+	options inputs,
+	metricsTracker *plugins.MetricsTracker,
+) *plugins.OrderTracker {
+	// plan is a dry run and does not reconcile orders, so we do not register the market in the db
+	if options.planOnly {
+		return nil
+	}
+
+	accountID := botConfig.TradingAccount()
+	marketID := plugins.MakeMarketID(botConfig.TradingExchangeName(), baseString, quoteString)
+	if db != nil {
+		// use the same accountID and marketID as the trades table so orders can be joined with their fills
+		accountID = botConfig.DbAccountID()
+
+		var e error
+		marketID, e = plugins.FetchOrRegisterMarketID(db, botConfig.TradingExchangeName(), baseString, quoteString)
+		if e != nil {
+			l.Info("")
+			l.Errorf("problem encountered while instantiating the order tracker: %s", e)
+			deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+		}
+	}
+
+	// offerIDs on SDEX are the orderIDs, but orders on centralized exchanges are mapped to offerIDs by the BatchedExchange
+	var orderIDFn plugins.OrderIDFn
+	if batchedExchange, ok := exchangeShim.(*plugins.BatchedExchange); ok {
+		orderIDFn = func(offer hProtocol.Offer) (string, string) {
+			orderID, clientOrderID, ok := batchedExchange.LookupOrderID(offer.ID)
+			if !ok {
+				return strconv.FormatInt(offer.ID, 10), ""
+			}
+			return orderID, clientOrderID
+		}
+	}
+
+	orderTracker := plugins.MakeOrderTracker(db, accountID, marketID, botConfig.AssetBase(), orderIDFn)
+	if fillTracker != nil {
+		fillTracker.RegisterHandler(orderTracker)
+	}
+	return orderTracker
+}
+
+// makeBotHeartbeatWriter returns nil when there is no db, or in simulation mode since a simulated bot should not keep the offers of a
+// real bot with the same assets alive, or when only planning the next update
+func makeBotHeartbeatWriter(
+	l logger.Logger,
+	botConfig trader.BotConfig,
+	client *horizonclient.Client,
+	sdex *plugins.SDEX,
+	exchangeShim api.ExchangeShim,
+	db *sql.DB,
+	threadTracker *multithreading.ThreadTracker,
+	baseString string,
+	quoteString string,
+	options inputs,
+	metricsTracker *plugins.MetricsTracker,
+) *plugins.BotHeartbeatWriter {
+	if db == nil || *options.simMode || options.planOnly {
+		return nil
+	}
+
+	marketID, e := plugins.FetchOrRegisterMarketID(db, botConfig.TradingExchangeName(), baseString, quoteString)
+	if e != nil {
+		l.Info("")
+		l.Errorf("problem encountered while instantiating the bot heartbeat writer: %s", e)
+		deleteAllOffersAndExit(l, botConfig, client, sdex, exchangeShim, threadTracker, metricsTracker)
+	}
+	botKey := model.MakeSortedBotKey(botConfig.AssetBase(), botConfig.AssetQuote())
+	return plugins.MakeBotHeartbeatWriter(db, botConfig.HeartbeatAccountID(), marketID, botKey)
+}
+
 func validateTrustlines(l logger.Logger, client *horizonclient.Client, botConfig *trader.BotConfig) {
 	if !botConfig.IsTradingSdex() {
 		l.Info("no need to validate trustlines because we're not using SDEX as the trading exchange")
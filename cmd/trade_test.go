@@ -5,9 +5,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stellar/kelp/support/database"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/trader"
 )
 
 func TestTradeUpgradeScripts(t *testing.T) {
@@ -281,3 +287,69 @@ func TestTradeUpgradeScripts(t *testing.T) {
 	allRows = database.QueryAllRows(db, "strategy_mirror_trade_triggers")
 	assert.Equal(t, 0, len(allRows))
 }
+
+func TestValidateTrustlinesAndMakeFeeFn(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+
+	issuer := keypair.MustRandom()
+	trading := keypair.MustRandom()
+	s.AddAccount(issuer.Address(), "100")
+	s.AddAccount(trading.Address(), "100")
+	s.AddTrustline(trading.Address(), hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()}, "1000")
+	s.SetFeeStats(hProtocol.FeeStats{LastLedgerBaseFee: 200, LedgerCapacityUsage: 0.5})
+
+	botConfig := trader.MakeBotConfig("", trading.Seed(), "XLM", "", "USD", issuer.Address(), 5000, 0, 0, "both", 0, 0, s.URL, nil, "", "", &trader.FeeConfig{
+		CapacityTrigger: 0.8,
+		Percentile:      90,
+		MaxOpFeeStroops: 5000,
+	}, nil, nil, nil, nil)
+	if !assert.NoError(t, botConfig.Init()) {
+		return
+	}
+	l := logger.MakeBasicLogger()
+
+	// exits the process if a trustline is missing
+	validateTrustlines(l, s.Client(), botConfig)
+	assert.Equal(t, 1, s.CallCount("accounts"))
+
+	fee, e := makeFeeFn(l, *botConfig, s.Client())()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, uint64(200), fee)
+}
+
+func TestCheckProtectiveOrdersConfig_LimitOrdersOnly(t *testing.T) {
+	testCases := []struct {
+		name       string
+		exchange   string
+		slippage   float64
+		limitPrice float64
+		wantErrors int
+	}{
+		{name: "market order on native binance", exchange: "binance", wantErrors: 1},
+		{name: "aggressive limit order on native binance", exchange: "binance", slippage: 0.01, wantErrors: 0},
+		{name: "limit price on native binance", exchange: "binance", limitPrice: 0.09, wantErrors: 0},
+		{name: "market order on ccxt binance", exchange: "ccxt-binance", wantErrors: 0},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			botConfig := trader.BotConfig{
+				TradingExchange: k.exchange,
+				ProtectiveOrders: &trader.ProtectiveOrdersConfig{
+					Slippage: k.slippage,
+					Orders: []trader.ProtectiveOrderConfig{{
+						Type:         "stop_loss",
+						Side:         "sell",
+						TriggerPrice: 0.1,
+						LimitPrice:   k.limitPrice,
+						Amount:       100,
+					}},
+				},
+			}
+			assert.Equal(t, k.wantErrors, len(checkProtectiveOrdersConfig(botConfig)))
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/nikhilsaraf/go-tools/multithreading"
+	"github.com/spf13/cobra"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/support/config"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/logger"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the trader and strategy config files without connecting to horizon or any exchange",
+	Example: `  kelp validate -c trader.cfg -s buysell -f buysell.cfg
+  kelp validate -c trader.cfg -s mirror -f mirror.cfg --keystore keystore.json`,
+}
+
+func init() {
+	botConfigPath := validateCmd.Flags().StringP("botConf", "c", "", "(required) trading bot's basic config file path")
+	strategy := validateCmd.Flags().StringP("strategy", "s", "", "(required) type of strategy the configs are used with")
+	stratConfigPath := validateCmd.Flags().StringP("stratConf", "f", "", "strategy config file path")
+	keystorePath := validateCmd.Flags().String("keystore", "", "encrypted keystore file used to resolve 'keystore:<name>' references, when not set the references are not checked")
+	verbose := validateCmd.Flags().BoolP("verbose", "v", false, "show the log output of the config factories")
+
+	validateCmd.MarkFlagRequired("botConf")
+	validateCmd.MarkFlagRequired("strategy")
+
+	validateCmd.Run = func(ccmd *cobra.Command, args []string) {
+		checkInitRootFlags()
+		if !*verbose {
+			log.SetOutput(ioutil.Discard)
+		}
+
+		errs := validateConfigs(*botConfigPath, *strategy, *stratConfigPath, makeValidateSecretResolver(*keystorePath))
+		if len(errs) > 0 {
+			fmt.Printf("found %d errors in the config files:\n", len(errs))
+			for _, e := range errs {
+				fmt.Printf("  - %s\n", e)
+			}
+			os.Exit(1)
+		}
+		fmt.Println("config files are valid")
+	}
+}
+
+// makeValidateSecretResolver resolves secret references like the trade command. When no keystore is given, references to the keystore
+// are replaced with a random seed so the rest of the config can be validated without the keystore passphrase
+func makeValidateSecretResolver(keystorePath string) utils.SecretResolver {
+	if keystorePath != "" {
+		return utils.ChainSecretResolvers(utils.ResolveFileRef, keystore.MakeSecretResolver(keystorePath))
+	}
+
+	return utils.ChainSecretResolvers(utils.ResolveFileRef, func(value string) (string, error) {
+		if keystore.IsRef(value) {
+			return keypair.MustRandom().Seed(), nil
+		}
+		return value, nil
+	})
+}
+
+// validateConfigs returns all the errors found in the config files using the same factories as the trade command, with the plugins
+// in offline validation mode so no connections are made to horizon, ccxt-rest or any exchange
+func validateConfigs(botConfigPath string, strategy string, stratConfigPath string, resolveSecret utils.SecretResolver) []error {
+	errs := []error{}
+	plugins.EnableOfflineValidation()
+
+	var botConfig trader.BotConfig
+	e := config.Read(botConfigPath, &botConfig)
+	if e != nil {
+		return append(errs, fmt.Errorf("could not read the trader config file '%s': %s", botConfigPath, e))
+	}
+	e = utils.ExpandEnvRefs(&botConfig)
+	if e != nil {
+		errs = append(errs, fmt.Errorf("could not expand environment variables in trader config file: %s", e))
+	}
+	e = botConfig.ResolveSecrets(resolveSecret)
+	if e != nil {
+		errs = append(errs, e)
+	}
+	e = botConfig.Init()
+	if e != nil {
+		// the remaining checks need the assets and accounts from the trader config
+		return append(errs, fmt.Errorf("invalid trader config file: %s", e))
+	}
+	botConfig = convertDeprecatedBotConfigValues(logger.MakeBasicLogger(), botConfig)
+	errs = append(errs, checkBotConfig(botConfig, strategy)...)
+
+	if !botConfig.IsTradingSdex() {
+		e = plugins.CheckExchangeTypeOffline(botConfig.TradingExchange, true)
+		if e != nil {
+			errs = append(errs, fmt.Errorf("invalid TRADING_EXCHANGE in trader config file: %s", e))
+		}
+	}
+	if botConfig.DollarValueFeedBaseAsset != "" {
+		if _, e := parseValueFeed(botConfig.DollarValueFeedBaseAsset); e != nil {
+			errs = append(errs, fmt.Errorf("invalid DOLLAR_VALUE_FEED_BASE_ASSET in trader config file: %s", e))
+		}
+	}
+	if botConfig.DollarValueFeedQuoteAsset != "" {
+		if _, e := parseValueFeed(botConfig.DollarValueFeedQuoteAsset); e != nil {
+			errs = append(errs, fmt.Errorf("invalid DOLLAR_VALUE_FEED_QUOTE_ASSET in trader config file: %s", e))
+		}
+	}
+	if botConfig.EventTrigger != nil && botConfig.EventTrigger.FeedType != "" {
+		if _, e := plugins.MakePriceFeed(botConfig.EventTrigger.FeedType, botConfig.EventTrigger.FeedURL); e != nil {
+			errs = append(errs, fmt.Errorf("could not make price feed for EVENT_TRIGGER: %s", e))
+		}
+	}
+	if len(botConfig.TradingWindows) > 0 || len(botConfig.TradingBlackouts) > 0 {
+		_, e = plugins.MakeScheduleTimeController(
+			plugins.MakeIntervalTimeController(time.Duration(botConfig.TickIntervalMillis)*time.Millisecond, botConfig.MaxTickDelayMillis),
+			botConfig.TradingWindows,
+			botConfig.TradingBlackouts,
+			botConfig.TradingWindowTimezone,
+		)
+		if e != nil {
+			errs = append(errs, e)
+		}
+	}
+
+	assetBase := botConfig.AssetBase()
+	assetQuote := botConfig.AssetQuote()
+	tradingPair := &model.TradingPair{
+		Base:  model.Asset(utils.Asset2CodeString(assetBase)),
+		Quote: model.Asset(utils.Asset2CodeString(assetQuote)),
+	}
+	sdexAssetMap := map[model.Asset]hProtocol.Asset{
+		tradingPair.Base:  assetBase,
+		tradingPair.Quote: assetQuote,
+	}
+	assetDisplayFn := model.MakePassthroughAssetDisplayFn()
+	if botConfig.IsTradingSdex() {
+		assetDisplayFn = model.MakeSdexMappedAssetDisplayFn(sdexAssetMap)
+	}
+
+	var db *sql.DB
+	if botConfig.PostgresDbConfig != nil {
+		// sql.Open only validates its arguments without connecting to the database
+		db, e = sql.Open("postgres", botConfig.PostgresDbConfig.MakeConnectString())
+		if e != nil {
+			errs = append(errs, fmt.Errorf("invalid POSTGRES_DB in trader config file: %s", e))
+		}
+	}
+
+	filterFactory := &plugins.FilterFactory{
+		ExchangeName:   botConfig.TradingExchangeName(),
+		TradingPair:    tradingPair,
+		AssetDisplayFn: assetDisplayFn,
+		BaseAsset:      assetBase,
+		QuoteAsset:     assetQuote,
+		DB:             db,
+	}
+	for _, filterString := range botConfig.Filters {
+		if _, e := filterFactory.MakeFilter(filterString); e != nil {
+			errs = append(errs, fmt.Errorf("invalid filter '%s' in trader config file: %s", filterString, e))
+		}
+	}
+
+	client := &horizonclient.Client{
+		HorizonURL: botConfig.HorizonURL,
+		HTTP:       http.DefaultClient,
+	}
+	network := utils.ParseNetwork(botConfig.HorizonURL)
+	// setting the temp hack variables for the sdex price feeds, this only fails if they were already set which is fine here
+	_ = plugins.SetPrivateSdexHack(client, plugins.MakeIEIF(true), network)
+	// the strategy is made against SDEX in simulation mode since we cannot connect to the trading exchange
+	ieif := plugins.MakeIEIF(botConfig.IsTradingSdex())
+	sdex := plugins.MakeSDEX(
+		client,
+		ieif,
+		nil,
+		botConfig.SourceSecretSeed,
+		botConfig.TradingSecretSeed,
+		botConfig.SourceAccount(),
+		botConfig.TradingAccount(),
+		network,
+		multithreading.MakeThreadTracker(),
+		0,
+		0,
+		true,
+		tradingPair,
+		sdexAssetMap,
+		plugins.SdexFixedFeeFn(0),
+		false,
+		nil,
+		"",
+	)
+	baseString, e := assetDisplayFn(tradingPair.Base)
+	if e != nil {
+		return append(errs, fmt.Errorf("could not convert base trading pair to string: %s", e))
+	}
+	quoteString, e := assetDisplayFn(tradingPair.Quote)
+	if e != nil {
+		return append(errs, fmt.Errorf("could not convert quote trading pair to string: %s", e))
+	}
+	_, e = plugins.MakeStrategy(
+		sdex,
+		sdex,
+		sdex,
+		ieif,
+		tradingPair,
+		&assetBase,
+		&assetQuote,
+		plugins.MakeMarketID(botConfig.TradingExchangeName(), baseString, quoteString),
+		strategy,
+		stratConfigPath,
+		true,
+		botConfig.IsTradingSdex(),
+		filterFactory,
+		db,
+		nil,
+		resolveSecret,
+	)
+	if e != nil {
+		errs = append(errs, e)
+	}
+	return errs
+}
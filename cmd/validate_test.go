@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleTraderConfigDir = "../examples/configs/trader"
+
+func TestValidateConfigsSamples(t *testing.T) {
+	for _, strategy := range []string{"buysell", "sell", "balanced", "mirror", "pendulum"} {
+		t.Run(strategy, func(t *testing.T) {
+			errs := validateConfigs(
+				filepath.Join(sampleTraderConfigDir, "sample_trader.cfg"),
+				strategy,
+				filepath.Join(sampleTraderConfigDir, "sample_"+strategy+".cfg"),
+				makeValidateSecretResolver(""),
+			)
+			assert.Empty(t, errs)
+		})
+	}
+}
+
+func TestValidateConfigsReportsAllErrors(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_validate")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	sampleBytes, e := ioutil.ReadFile(filepath.Join(sampleTraderConfigDir, "sample_trader.cfg"))
+	if !assert.NoError(t, e) {
+		return
+	}
+	// top-level keys need to come before the first table in the sample config
+	traderConfig := `TRADING_EXCHANGE="kraken"
+TRADING_SECRET_SEED="keystore:mybot"
+CENTRALIZED_PRICE_PRECISION_OVERRIDE=-1
+FILTERS=["price/limit/abc"]
+` + strings.Replace(string(sampleBytes), "\nTRADING_SECRET_SEED=", "\n# TRADING_SECRET_SEED=", 1)
+	traderConfigPath := filepath.Join(dir, "trader.cfg")
+	if !assert.NoError(t, ioutil.WriteFile(traderConfigPath, []byte(traderConfig), 0600)) {
+		return
+	}
+
+	errs := validateConfigs(traderConfigPath, "sell", filepath.Join(dir, "missing.cfg"), makeValidateSecretResolver(""))
+	if !assert.Len(t, errs, 3) {
+		return
+	}
+	assert.Contains(t, errs[0].Error(), "CENTRALIZED_PRICE_PRECISION_OVERRIDE")
+	assert.Contains(t, errs[1].Error(), "invalid filter 'price/limit/abc'")
+	assert.Contains(t, errs[2].Error(), "cannot make 'sell' strategy")
+}
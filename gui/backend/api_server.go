@@ -16,6 +16,7 @@ import (
 	"github.com/stellar/go/clients/horizonclient"
 	"github.com/stellar/kelp/plugins"
 	"github.com/stellar/kelp/support/kelpos"
+	"github.com/stellar/kelp/support/keystore"
 )
 
 // APIServer is an instance of the API service
@@ -34,6 +35,10 @@ type APIServer struct {
 	metricsTracker    *plugins.MetricsTracker
 	kelpErrorMap      map[string]KelpError
 	kelpErrorMapLock  *sync.Mutex
+	auth              *Authenticator // nil when authentication is disabled
+	events            *eventBroker
+	botRegistry       *botRegistry
+	keystore          *keystore.Keystore // nil when secrets are written to the bot configs in plaintext
 
 	cachedOptionsMetadata metadata
 }
@@ -43,6 +48,7 @@ func MakeAPIServer(
 	kos *kelpos.KelpOS,
 	botConfigsPath *kelpos.OSPath,
 	botLogsPath *kelpos.OSPath,
+	botRegistryPath *kelpos.OSPath,
 	horizonTestnetURI string,
 	apiTestNet *horizonclient.Client,
 	horizonPubnetURI string,
@@ -51,6 +57,8 @@ func MakeAPIServer(
 	noHeaders bool,
 	quitFn func(),
 	metricsTracker *plugins.MetricsTracker,
+	auth *Authenticator,
+	ks *keystore.Keystore,
 ) (*APIServer, error) {
 	kelpBinPath := kos.GetBinDir().Join(filepath.Base(os.Args[0]))
 
@@ -61,7 +69,12 @@ func MakeAPIServer(
 
 	kelpErrorMap := map[string]KelpError{}
 
-	return &APIServer{
+	botRegistry, e := loadBotRegistry(botRegistryPath.Native())
+	if e != nil {
+		return nil, fmt.Errorf("error while loading bot registry when making APIServer: %s", e)
+	}
+
+	s := &APIServer{
 		kelpBinPath:           kelpBinPath,
 		botConfigsPath:        botConfigsPath,
 		botLogsPath:           botLogsPath,
@@ -77,17 +90,24 @@ func MakeAPIServer(
 		metricsTracker:        metricsTracker,
 		kelpErrorMap:          kelpErrorMap,
 		kelpErrorMapLock:      &sync.Mutex{},
-	}, nil
+		auth:                  auth,
+		events:                makeEventBroker(),
+		botRegistry:           botRegistry,
+		keystore:              ks,
+	}
+	kos.SetBotStateListener(s.publishBotState)
+	return s, nil
 }
 
 // InitBackend initializes anything required to get the backend ready to serve
 func (s *APIServer) InitBackend() error {
 	// initial load of bots into memory
-	_, e := s.doListBots()
+	bots, e := s.doListBots()
 	if e != nil {
 		return fmt.Errorf("error listing/loading bots: %s", e)
 	}
 
+	s.startRegisteredBotsOnBoot(bots)
 	return nil
 }
 
@@ -182,6 +202,12 @@ func (s *APIServer) addKelpErrorToMap(ke KelpError) {
 	defer s.kelpErrorMapLock.Unlock()
 
 	s.kelpErrorMap[key] = ke
+	s.events.publish(botEvent{
+		Type:    eventTypeKelpError,
+		BotName: ke.ObjectName,
+		Date:    ke.Date,
+		Data:    ke,
+	})
 }
 
 func (s *APIServer) writeKelpError(w http.ResponseWriter, kerw KelpErrorResponseWrapper) {
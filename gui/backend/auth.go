@@ -0,0 +1,369 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role controls which API routes a user can access
+type Role string
+
+// roles, in increasing order of privileges
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+}
+
+// allows returns true if a principal with this role can access routes that require the given role
+func (r Role) allows(required Role) bool {
+	return roleLevels[r] >= roleLevels[required]
+}
+
+const sessionCookieName = "kelp_session"
+const defaultSessionTimeoutMinutes = 12 * 60
+
+// maxAuditBodyBytes limits how much of a request body we read into the audit log
+const maxAuditBodyBytes = 256
+
+// AuthUserConfig is a user that can log in with a password
+type AuthUserConfig struct {
+	Username     string `valid:"-" toml:"USERNAME" json:"username"`
+	PasswordHash string `valid:"-" toml:"PASSWORD_HASH" json:"password_hash"` // bcrypt hash
+	Role         string `valid:"-" toml:"ROLE" json:"role"`
+}
+
+// AuthTokenConfig is an API token that can be used in the Authorization header or exchanged for a session
+type AuthTokenConfig struct {
+	Name      string `valid:"-" toml:"NAME" json:"name"`
+	TokenHash string `valid:"-" toml:"TOKEN_SHA256" json:"token_sha256"` // hex-encoded sha256 of the token
+	Role      string `valid:"-" toml:"ROLE" json:"role"`
+}
+
+// AuthConfig is the config file for authentication on the GUI server
+type AuthConfig struct {
+	SessionTimeoutMinutes int64             `valid:"-" toml:"SESSION_TIMEOUT_MINUTES" json:"session_timeout_minutes"`
+	AuditLogFile          string            `valid:"-" toml:"AUDIT_LOG_FILE" json:"audit_log_file"`
+	Users                 []AuthUserConfig  `valid:"-" toml:"USERS" json:"users"`
+	Tokens                []AuthTokenConfig `valid:"-" toml:"TOKENS" json:"tokens"`
+}
+
+// String impl, does not print any secrets
+func (c AuthConfig) String() string {
+	return fmt.Sprintf("AuthConfig[SessionTimeoutMinutes=%d, AuditLogFile=%s, numUsers=%d, numTokens=%d]", c.SessionTimeoutMinutes, c.AuditLogFile, len(c.Users), len(c.Tokens))
+}
+
+// principal is the authenticated identity on a request
+type principal struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+type principalContextKey struct{}
+
+func contextWithPrincipal(ctx context.Context, p *principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalContextKey{}).(*principal)
+	return p
+}
+
+type session struct {
+	principal principal
+	expiry    time.Time
+}
+
+type authToken struct {
+	name string
+	hash []byte
+	role Role
+}
+
+// auditEntry is one line in the audit log
+type auditEntry struct {
+	Date       time.Time `json:"date"`
+	User       string    `json:"user"`
+	Role       Role      `json:"role"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Status     int       `json:"status"`
+}
+
+// Authenticator manages logins and sessions for the API server and writes the audit log
+type Authenticator struct {
+	users          map[string]AuthUserConfig
+	tokens         []authToken
+	sessionTimeout time.Duration
+	auditLog       io.Writer
+	dummyHash      []byte // used to compare passwords of unknown users so we don't leak which usernames exist via timing
+
+	// initialized runtime vars
+	sessions     map[string]*session
+	sessionsLock *sync.Mutex
+	auditLock    *sync.Mutex
+	now          func() time.Time
+}
+
+// MakeAuthenticator is a factory method
+func MakeAuthenticator(authConfig AuthConfig, auditLog io.Writer) (*Authenticator, error) {
+	if len(authConfig.Users) == 0 && len(authConfig.Tokens) == 0 {
+		return nil, fmt.Errorf("auth config needs at least one entry in USERS or TOKENS")
+	}
+
+	users := map[string]AuthUserConfig{}
+	for i, u := range authConfig.Users {
+		if u.Username == "" {
+			return nil, fmt.Errorf("USERNAME is empty for user at index %d", i)
+		}
+		if _, ok := users[u.Username]; ok {
+			return nil, fmt.Errorf("duplicate USERNAME '%s'", u.Username)
+		}
+		if _, e := bcrypt.Cost([]byte(u.PasswordHash)); e != nil {
+			return nil, fmt.Errorf("PASSWORD_HASH for user '%s' is not a valid bcrypt hash: %s", u.Username, e)
+		}
+		if _, ok := roleLevels[Role(u.Role)]; !ok {
+			return nil, fmt.Errorf("invalid ROLE '%s' for user '%s', needs to be one of '%s' or '%s'", u.Role, u.Username, RoleViewer, RoleOperator)
+		}
+		users[u.Username] = u
+	}
+
+	tokens := []authToken{}
+	for i, t := range authConfig.Tokens {
+		if t.Name == "" {
+			return nil, fmt.Errorf("NAME is empty for token at index %d", i)
+		}
+		hash, e := hex.DecodeString(t.TokenHash)
+		if e != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("TOKEN_SHA256 for token '%s' needs to be a hex-encoded sha256 hash", t.Name)
+		}
+		if _, ok := roleLevels[Role(t.Role)]; !ok {
+			return nil, fmt.Errorf("invalid ROLE '%s' for token '%s', needs to be one of '%s' or '%s'", t.Role, t.Name, RoleViewer, RoleOperator)
+		}
+		tokens = append(tokens, authToken{name: t.Name, hash: hash, role: Role(t.Role)})
+	}
+
+	dummyHash, e := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if e != nil {
+		return nil, fmt.Errorf("unable to generate dummy password hash: %s", e)
+	}
+
+	sessionTimeoutMinutes := authConfig.SessionTimeoutMinutes
+	if sessionTimeoutMinutes <= 0 {
+		sessionTimeoutMinutes = defaultSessionTimeoutMinutes
+	}
+
+	return &Authenticator{
+		users:          users,
+		tokens:         tokens,
+		sessionTimeout: time.Duration(sessionTimeoutMinutes) * time.Minute,
+		auditLog:       auditLog,
+		dummyHash:      dummyHash,
+		// initialized runtime vars
+		sessions:     map[string]*session{},
+		sessionsLock: &sync.Mutex{},
+		auditLock:    &sync.Mutex{},
+		now:          time.Now,
+	}, nil
+}
+
+func (a *Authenticator) checkPassword(username string, password string) (*principal, error) {
+	u, ok := a.users[username]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return nil, fmt.Errorf("invalid username or password")
+	}
+
+	e := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	if e != nil {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	return &principal{Name: u.Username, Role: Role(u.Role)}, nil
+}
+
+func (a *Authenticator) checkToken(token string) (*principal, error) {
+	hash := sha256.Sum256([]byte(token))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash) == 1 {
+			return &principal{Name: "token:" + t.name, Role: t.role}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// newSession returns the sessionID for a new session for the principal
+func (a *Authenticator) newSession(p principal) (string, time.Time, error) {
+	idBytes := make([]byte, 32)
+	_, e := rand.Read(idBytes)
+	if e != nil {
+		return "", time.Time{}, fmt.Errorf("unable to generate session id: %s", e)
+	}
+	sessionID := hex.EncodeToString(idBytes)
+	expiry := a.now().Add(a.sessionTimeout)
+
+	a.sessionsLock.Lock()
+	defer a.sessionsLock.Unlock()
+
+	// clear out expired sessions so the map does not grow unbounded
+	for id, s := range a.sessions {
+		if !a.now().Before(s.expiry) {
+			delete(a.sessions, id)
+		}
+	}
+	a.sessions[sessionID] = &session{principal: p, expiry: expiry}
+	return sessionID, expiry, nil
+}
+
+func (a *Authenticator) deleteSession(sessionID string) {
+	a.sessionsLock.Lock()
+	defer a.sessionsLock.Unlock()
+
+	delete(a.sessions, sessionID)
+}
+
+func (a *Authenticator) lookupSession(sessionID string) *principal {
+	a.sessionsLock.Lock()
+	defer a.sessionsLock.Unlock()
+
+	s, ok := a.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	if !a.now().Before(s.expiry) {
+		delete(a.sessions, sessionID)
+		return nil
+	}
+	p := s.principal
+	return &p
+}
+
+// authenticate returns the principal from the session cookie or a bearer token, nil if the request is not authenticated
+func (a *Authenticator) authenticate(r *http.Request) *principal {
+	if c, e := r.Cookie(sessionCookieName); e == nil && c.Value != "" {
+		if p := a.lookupSession(c.Value); p != nil {
+			return p
+		}
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		p, e := a.checkToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if e == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) audit(entry auditEntry) {
+	if a.auditLog == nil {
+		return
+	}
+
+	line, e := json.Marshal(entry)
+	if e != nil {
+		log.Printf("unable to marshal audit entry: %s\n", e)
+		return
+	}
+
+	a.auditLock.Lock()
+	defer a.auditLock.Unlock()
+	_, e = a.auditLog.Write(append(line, '\n'))
+	if e != nil {
+		log.Printf("unable to write audit entry (%s): %s\n", string(line), e)
+	}
+}
+
+// requireRole is a middleware that rejects requests without a principal that has the required role. It allows all requests when auth is disabled
+func (s *APIServer) requireRole(required Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p := s.auth.authenticate(r)
+			if p == nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("unauthorized, need to log in\n"))
+				return
+			}
+			if !p.Role.allows(required) {
+				log.Printf("denied access to %s for '%s' with role '%s' (required role '%s')\n", r.URL.Path, p.Name, p.Role, required)
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(fmt.Sprintf("forbidden, need role '%s'\n", required)))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// audited is a middleware that writes mutating requests to the audit log. The request body is only logged when logBody is set,
+// since some requests (such as upsertBotConfig) contain secrets
+func (s *APIServer) audited(logBody bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target := ""
+			if logBody && r.Body != nil {
+				bodyBytes, e := ioutil.ReadAll(r.Body)
+				if e != nil {
+					s.writeError(w, fmt.Sprintf("error when reading request input: %s\n", e))
+					return
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+				if len(bodyBytes) > maxAuditBodyBytes {
+					bodyBytes = bodyBytes[:maxAuditBodyBytes]
+				}
+				target = string(bodyBytes)
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			entry := auditEntry{
+				Date:       s.auth.now().UTC(),
+				Action:     r.URL.Path,
+				Target:     target,
+				RemoteAddr: r.RemoteAddr,
+				Status:     ww.Status(),
+			}
+			if entry.Status == 0 {
+				// handlers that only write a body implicitly respond with a 200
+				entry.Status = http.StatusOK
+			}
+			if p := principalFromContext(r.Context()); p != nil {
+				entry.User, entry.Role = p.Name, p.Role
+			}
+			s.auth.audit(entry)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func makeTestAuthenticator(t *testing.T, auditLog *bytes.Buffer) *Authenticator {
+	operatorHash, e := bcrypt.GenerateFromPassword([]byte("operator-password"), bcrypt.MinCost)
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	viewerHash, e := bcrypt.GenerateFromPassword([]byte("viewer-password"), bcrypt.MinCost)
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	tokenHash := sha256.Sum256([]byte("viewer-token"))
+
+	auth, e := MakeAuthenticator(AuthConfig{
+		SessionTimeoutMinutes: 60,
+		Users: []AuthUserConfig{
+			{Username: "op", PasswordHash: string(operatorHash), Role: "operator"},
+			{Username: "view", PasswordHash: string(viewerHash), Role: "viewer"},
+		},
+		Tokens: []AuthTokenConfig{
+			{Name: "monitoring", TokenHash: hex.EncodeToString(tokenHash[:]), Role: "viewer"},
+		},
+	}, auditLog)
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	return auth
+}
+
+func makeTestAuthRouter(s *APIServer) *chi.Mux {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := chi.NewRouter()
+	r.Post("/login", http.HandlerFunc(s.login))
+	r.With(s.requireRole(RoleViewer)).Post("/getState", ok)
+	r.With(s.requireRole(RoleOperator), s.audited(true)).Post("/start", ok)
+	return r
+}
+
+func TestMakeAuthenticatorValidation(t *testing.T) {
+	validHash, e := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	testCases := []struct {
+		name   string
+		config AuthConfig
+	}{
+		{
+			name:   "empty",
+			config: AuthConfig{},
+		}, {
+			name:   "invalid role",
+			config: AuthConfig{Users: []AuthUserConfig{{Username: "a", PasswordHash: string(validHash), Role: "admin"}}},
+		}, {
+			name:   "plaintext password",
+			config: AuthConfig{Users: []AuthUserConfig{{Username: "a", PasswordHash: "password", Role: "viewer"}}},
+		}, {
+			name: "duplicate username",
+			config: AuthConfig{Users: []AuthUserConfig{
+				{Username: "a", PasswordHash: string(validHash), Role: "viewer"},
+				{Username: "a", PasswordHash: string(validHash), Role: "operator"},
+			}},
+		}, {
+			name:   "plaintext token",
+			config: AuthConfig{Tokens: []AuthTokenConfig{{Name: "a", TokenHash: "my-token", Role: "viewer"}}},
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			_, e := MakeAuthenticator(k.config, nil)
+			assert.Error(t, e)
+		})
+	}
+}
+
+func TestAuthRoles(t *testing.T) {
+	auditLog := &bytes.Buffer{}
+	s := &APIServer{auth: makeTestAuthenticator(t, auditLog)}
+	r := makeTestAuthRouter(s)
+
+	login := func(body string) *http.Cookie {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/login", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			return nil
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == sessionCookieName {
+				return c
+			}
+		}
+		return nil
+	}
+	call := func(path string, cookie *http.Cookie, bearer string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader("mybot"))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Nil(t, login(`{"username": "op", "password": "wrong"}`))
+	assert.Nil(t, login(`{"username": "nobody", "password": "operator-password"}`))
+	opCookie := login(`{"username": "op", "password": "operator-password"}`)
+	viewCookie := login(`{"username": "view", "password": "viewer-password"}`)
+	tokenCookie := login(`{"token": "viewer-token"}`)
+	if !assert.NotNil(t, opCookie) || !assert.NotNil(t, viewCookie) || !assert.NotNil(t, tokenCookie) {
+		return
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call("/getState", nil, ""))
+	assert.Equal(t, http.StatusUnauthorized, call("/getState", &http.Cookie{Name: sessionCookieName, Value: "forged"}, ""))
+	assert.Equal(t, http.StatusUnauthorized, call("/getState", nil, "wrong-token"))
+	assert.Equal(t, http.StatusOK, call("/getState", viewCookie, ""))
+	assert.Equal(t, http.StatusOK, call("/getState", tokenCookie, ""))
+	assert.Equal(t, http.StatusOK, call("/getState", nil, "viewer-token"))
+	assert.Equal(t, http.StatusOK, call("/getState", opCookie, ""))
+	assert.Equal(t, http.StatusForbidden, call("/start", viewCookie, ""))
+	assert.Equal(t, http.StatusForbidden, call("/start", nil, "viewer-token"))
+	assert.Equal(t, http.StatusOK, call("/start", opCookie, ""))
+
+	// sessions expire
+	s.auth.now = func() time.Time { return time.Now().Add(61 * time.Minute) }
+	assert.Equal(t, http.StatusUnauthorized, call("/getState", opCookie, ""))
+
+	// failed logins, successful logins, and mutating actions are in the audit log
+	entries := []auditEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var entry auditEntry
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &entry)) {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	if !assert.Equal(t, 6, len(entries)) {
+		return
+	}
+	assert.Equal(t, "login", entries[0].Action)
+	assert.Equal(t, http.StatusUnauthorized, entries[0].Status)
+	assert.Equal(t, "token:monitoring", entries[4].User)
+	assert.Equal(t, "/start", entries[5].Action)
+	assert.Equal(t, "op", entries[5].User)
+	assert.Equal(t, RoleOperator, entries[5].Role)
+	assert.Equal(t, "mybot", entries[5].Target)
+	assert.Equal(t, http.StatusOK, entries[5].Status)
+}
+
+func TestAuthDisabled(t *testing.T) {
+	r := makeTestAuthRouter(&APIServer{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/start", strings.NewReader("mybot")))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -52,10 +52,15 @@ func (s *APIServer) autogenerateBot(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filenamePair := bot.Filenames()
-	sampleTrader := s.makeSampleTrader(kp.Seed())
+	sampleTrader, e := s.withSecretsInKeystore(bot.Name, *s.makeSampleTrader(kp.Seed()))
+	if e != nil {
+		// the bot is not registered at this stage so we don't throw a KelpError here
+		s.writeError(w, fmt.Sprintf("error storing secrets in keystore: %s\n", e))
+		return
+	}
 	traderFilePath := s.botConfigsPath.Join(filenamePair.Trader)
 	log.Printf("writing autogenerated bot config to file: %s\n", traderFilePath.AsString())
-	e = toml.WriteFile(traderFilePath.Native(), sampleTrader)
+	e = toml.WriteFile(traderFilePath.Native(), &sampleTrader)
 	if e != nil {
 		// the bot is not registered at this stage so we don't throw a KelpError here
 		s.writeError(w, fmt.Sprintf("error writing trader toml file: %s\n", e))
@@ -0,0 +1,197 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type botDesiredState string
+
+// desired states of bots that are persisted across restarts of the server
+const (
+	botDesiredStateRunning botDesiredState = "running"
+	botDesiredStateStopped botDesiredState = "stopped"
+)
+
+// restart backoff for bots that crash while their desired state is running
+const (
+	restartBaseBackoff = 5 * time.Second
+	restartMaxBackoff  = 5 * time.Minute
+	// a bot that runs for at least this long before crashing is considered healthy, which resets the backoff
+	restartStableRunDuration = 10 * time.Minute
+)
+
+// botRegistryEntry is the persisted state of a bot
+type botRegistryEntry struct {
+	Strategy            string          `json:"strategy"`
+	DesiredState        botDesiredState `json:"desired_state"`
+	Iterations          *uint8          `json:"iterations,omitempty"`
+	RestartCount        uint64          `json:"restart_count"`
+	ConsecutiveFailures uint64          `json:"consecutive_failures"`
+	LastRestartDate     *time.Time      `json:"last_restart_date,omitempty"`
+	LastExitDate        *time.Time      `json:"last_exit_date,omitempty"`
+	LastExitError       string          `json:"last_exit_error,omitempty"`
+}
+
+// botRegistry persists the desired state of bots to a file so bots can be restarted when the server restarts
+type botRegistry struct {
+	filepath string
+	lock     *sync.Mutex
+	entries  map[string]*botRegistryEntry
+}
+
+// loadBotRegistry loads the registry from the file, starting with an empty registry if the file does not exist
+func loadBotRegistry(filepath string) (*botRegistry, error) {
+	r := &botRegistry{
+		filepath: filepath,
+		lock:     &sync.Mutex{},
+		entries:  map[string]*botRegistryEntry{},
+	}
+
+	registryBytes, e := ioutil.ReadFile(filepath)
+	if os.IsNotExist(e) {
+		return r, nil
+	} else if e != nil {
+		return nil, fmt.Errorf("could not read bot registry file '%s': %s", filepath, e)
+	}
+
+	e = json.Unmarshal(registryBytes, &r.entries)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse bot registry file '%s': %s", filepath, e)
+	}
+	return r, nil
+}
+
+// save writes to a temporary file first so a crash while writing does not corrupt the registry, needs to be called with the lock held
+func (r *botRegistry) save() error {
+	registryBytes, e := json.MarshalIndent(r.entries, "", "  ")
+	if e != nil {
+		return fmt.Errorf("could not marshal bot registry: %s", e)
+	}
+
+	tmpFilepath := r.filepath + ".tmp"
+	e = ioutil.WriteFile(tmpFilepath, registryBytes, 0600)
+	if e != nil {
+		return fmt.Errorf("could not write bot registry file '%s': %s", tmpFilepath, e)
+	}
+	e = os.Rename(tmpFilepath, r.filepath)
+	if e != nil {
+		return fmt.Errorf("could not move bot registry file from '%s' to '%s': %s", tmpFilepath, r.filepath, e)
+	}
+	return nil
+}
+
+func (r *botRegistry) getOrCreate(botName string) *botRegistryEntry {
+	entry, ok := r.entries[botName]
+	if !ok {
+		entry = &botRegistryEntry{DesiredState: botDesiredStateStopped}
+		r.entries[botName] = entry
+	}
+	return entry
+}
+
+// get returns a copy of the entry for the bot
+func (r *botRegistry) get(botName string) (botRegistryEntry, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.entries[botName]
+	if !ok {
+		return botRegistryEntry{}, false
+	}
+	return *entry, true
+}
+
+// setRunning is called when a user starts a bot, which also resets the backoff
+func (r *botRegistry) setRunning(botName string, strategy string, iterations *uint8) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := r.getOrCreate(botName)
+	entry.Strategy = strategy
+	entry.DesiredState = botDesiredStateRunning
+	entry.Iterations = iterations
+	entry.ConsecutiveFailures = 0
+	return r.save()
+}
+
+// setStopped is called when a user stops a bot, or when a bot finishes by itself
+func (r *botRegistry) setStopped(botName string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := r.getOrCreate(botName)
+	entry.DesiredState = botDesiredStateStopped
+	return r.save()
+}
+
+func (r *botRegistry) remove(botName string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.entries, botName)
+	return r.save()
+}
+
+// runningBots returns the names of the bots whose desired state is running
+func (r *botRegistry) runningBots() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := []string{}
+	for name, entry := range r.entries {
+		if entry.DesiredState == botDesiredStateRunning {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recordCrash records a bot that exited with an error and returns whether it should be restarted and after how long
+func (r *botRegistry) recordCrash(botName string, runDuration time.Duration, exitError error, now time.Time) (bool, time.Duration, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.entries[botName]
+	if !ok || entry.DesiredState != botDesiredStateRunning {
+		return false, 0, nil
+	}
+
+	if runDuration >= restartStableRunDuration {
+		entry.ConsecutiveFailures = 0
+	}
+	backoff := restartBackoff(entry.ConsecutiveFailures)
+	entry.ConsecutiveFailures++
+	entry.LastExitDate = &now
+	entry.LastExitError = exitError.Error()
+	return true, backoff, r.save()
+}
+
+// recordRestart records that the bot was restarted after a crash
+func (r *botRegistry) recordRestart(botName string, now time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := r.getOrCreate(botName)
+	entry.RestartCount++
+	entry.LastRestartDate = &now
+	return r.save()
+}
+
+// restartBackoff doubles the backoff for every consecutive failure, up to the max
+func restartBackoff(consecutiveFailures uint64) time.Duration {
+	backoff := restartBaseBackoff
+	for i := uint64(0); i < consecutiveFailures && backoff < restartMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > restartMaxBackoff {
+		return restartMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, restartBackoff(0))
+	assert.Equal(t, 10*time.Second, restartBackoff(1))
+	assert.Equal(t, 20*time.Second, restartBackoff(2))
+	assert.Equal(t, 160*time.Second, restartBackoff(5))
+	assert.Equal(t, restartMaxBackoff, restartBackoff(6))
+	assert.Equal(t, restartMaxBackoff, restartBackoff(1000))
+}
+
+func TestBotRegistry(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_bot_registry")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	registryPath := filepath.Join(dir, "bot_registry.json")
+
+	r, e := loadBotRegistry(registryPath)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []string{}, r.runningBots())
+
+	now := time.Date(2020, 3, 14, 15, 0, 0, 0, time.UTC)
+	iterations := uint8(10)
+	assert.NoError(t, r.setRunning("Bot A", "buysell", &iterations))
+	assert.NoError(t, r.setRunning("Bot B", "buysell", nil))
+	assert.NoError(t, r.setRunning("Bot C", "buysell", nil))
+	assert.NoError(t, r.setStopped("Bot B"))
+	assert.NoError(t, r.remove("Bot C"))
+
+	// crash loops back off until the bot runs for long enough
+	crashErr := fmt.Errorf("exit status 1")
+	for _, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second} {
+		shouldRestart, backoff, e := r.recordCrash("Bot A", time.Second, crashErr, now)
+		assert.NoError(t, e)
+		assert.True(t, shouldRestart)
+		assert.Equal(t, want, backoff)
+		assert.NoError(t, r.recordRestart("Bot A", now))
+	}
+	shouldRestart, backoff, e := r.recordCrash("Bot A", restartStableRunDuration, crashErr, now)
+	assert.NoError(t, e)
+	assert.True(t, shouldRestart)
+	assert.Equal(t, 5*time.Second, backoff)
+
+	// stopped bots are not restarted
+	shouldRestart, _, e = r.recordCrash("Bot B", time.Second, crashErr, now)
+	assert.NoError(t, e)
+	assert.False(t, shouldRestart)
+
+	// state survives a restart of the server
+	r2, e := loadBotRegistry(registryPath)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []string{"Bot A"}, r2.runningBots())
+	entry, ok := r2.get("Bot A")
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "buysell", entry.Strategy)
+	assert.Equal(t, uint8(10), *entry.Iterations)
+	assert.Equal(t, uint64(3), entry.RestartCount)
+	assert.Equal(t, uint64(1), entry.ConsecutiveFailures)
+	assert.Equal(t, "exit status 1", entry.LastExitError)
+	assert.Equal(t, now, *entry.LastRestartDate)
+	_, ok = r2.get("Bot C")
+	assert.False(t, ok)
+
+	// a user starting the bot resets the backoff but keeps the restart count
+	assert.NoError(t, r2.setRunning("Bot A", "buysell", nil))
+	entry, _ = r2.get("Bot A")
+	assert.Equal(t, uint64(0), entry.ConsecutiveFailures)
+	assert.Equal(t, uint64(3), entry.RestartCount)
+}
@@ -67,6 +67,10 @@ func (s *APIServer) deleteBot(w http.ResponseWriter, r *http.Request) {
 
 	// unregister bot
 	s.kos.SafeUnregisterBot(botName)
+	e = s.botRegistry.remove(botName)
+	if e != nil {
+		s.addRegistryError(botName, e)
+	}
 
 	// delete configs
 	botPrefix := model2.GetPrefix(botName)
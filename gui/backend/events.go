@@ -0,0 +1,271 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/support/kelpos"
+)
+
+type eventType string
+
+// types of events that can be streamed
+const (
+	eventTypeBotState   eventType = "bot_state"
+	eventTypeKelpError  eventType = "kelp_error"
+	eventTypeUpdateLoop eventType = "update_loop"
+	eventTypeLogLine    eventType = "log_line"
+)
+
+var allEventTypes = []eventType{eventTypeBotState, eventTypeKelpError, eventTypeUpdateLoop, eventTypeLogLine}
+
+const eventsPath = "/api/v1/events"
+const eventSubscriptionBufferSize = 256
+const eventKeepaliveInterval = 15 * time.Second
+
+// max length of a line of bot output, longer lines are dropped from the stream
+const maxBotOutputLineBytes = 1024 * 1024
+
+// botEvent is an event that is streamed to clients
+type botEvent struct {
+	Type    eventType   `json:"type"`
+	BotName string      `json:"bot_name,omitempty"`
+	Date    time.Time   `json:"date"`
+	Data    interface{} `json:"data"`
+}
+
+type botStateEventData struct {
+	State string `json:"state"`
+}
+
+type logLineEventData struct {
+	Line string `json:"line"`
+}
+
+type eventSubscription struct {
+	events  chan botEvent
+	botName string // empty for all bots
+	types   map[eventType]bool
+}
+
+func (sub *eventSubscription) wants(e botEvent) bool {
+	if !sub.types[e.Type] {
+		return false
+	}
+	// events that are not specific to a bot, such as some kelp errors, go to every subscriber
+	return sub.botName == "" || e.BotName == "" || e.BotName == sub.botName
+}
+
+// eventBroker fans out events to all subscribers, dropping events for subscribers that are not keeping up so publishers never block
+type eventBroker struct {
+	lock          *sync.Mutex
+	subscriptions map[*eventSubscription]bool
+}
+
+func makeEventBroker() *eventBroker {
+	return &eventBroker{
+		lock:          &sync.Mutex{},
+		subscriptions: map[*eventSubscription]bool{},
+	}
+}
+
+func (b *eventBroker) subscribe(botName string, types []eventType) *eventSubscription {
+	typesMap := map[eventType]bool{}
+	for _, t := range types {
+		typesMap[t] = true
+	}
+	sub := &eventSubscription{
+		events:  make(chan botEvent, eventSubscriptionBufferSize),
+		botName: botName,
+		types:   typesMap,
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.subscriptions[sub] = true
+	return sub
+}
+
+func (b *eventBroker) unsubscribe(sub *eventSubscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subscriptions, sub)
+}
+
+func (b *eventBroker) publish(e botEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sub := range b.subscriptions {
+		if !sub.wants(e) {
+			continue
+		}
+
+		select {
+		case sub.events <- e:
+		default:
+			log.Printf("dropped event of type '%s' for bot '%s' because the subscriber is not keeping up\n", e.Type, e.BotName)
+		}
+	}
+}
+
+func (s *APIServer) publishBotState(botName string, state kelpos.BotState) {
+	s.events.publish(botEvent{
+		Type:    eventTypeBotState,
+		BotName: botName,
+		Date:    time.Now().UTC(),
+		Data:    botStateEventData{State: state.String()},
+	})
+}
+
+// streamBotOutput publishes every line of the bot's output and the update loop summaries in it, it returns once the output is closed.
+// Reading the output also ensures the bot does not block on a full stdout pipe
+func (s *APIServer) streamBotOutput(botName string, output io.Reader) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), maxBotOutputLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		now := time.Now().UTC()
+
+		s.events.publish(botEvent{
+			Type:    eventTypeLogLine,
+			BotName: botName,
+			Date:    now,
+			Data:    logLineEventData{Line: line},
+		})
+		if summary, ok := plugins.ParseUpdateLoopSummaryLogLine(line); ok {
+			s.events.publish(botEvent{
+				Type:    eventTypeUpdateLoop,
+				BotName: botName,
+				Date:    now,
+				Data:    summary,
+			})
+		}
+	}
+
+	if e := scanner.Err(); e != nil {
+		log.Printf("stopped streaming output of bot '%s', discarding the rest of the output: %s\n", botName, e)
+		_, _ = io.Copy(ioutil.Discard, output)
+	}
+}
+
+func parseEventTypes(typesParam string) ([]eventType, error) {
+	if typesParam == "" {
+		return allEventTypes, nil
+	}
+
+	types := []eventType{}
+	for _, t := range strings.Split(typesParam, ",") {
+		et := eventType(strings.TrimSpace(t))
+		valid := false
+		for _, known := range allEventTypes {
+			if et == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid event type '%s', needs to be one of %v", et, allEventTypes)
+		}
+		types = append(types, et)
+	}
+	return types, nil
+}
+
+// IsStreamingRequest returns true for long-lived requests that should not be subject to request timeouts
+func IsStreamingRequest(r *http.Request) bool {
+	return r.URL.Path == eventsPath
+}
+
+// streamEvents is a server-sent events endpoint. The optional "bot" query param filters events to a single bot and the optional
+// "types" query param is a comma-separated list of event types to stream
+func (s *APIServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, "streaming is not supported by the response writer\n")
+		return
+	}
+
+	botName := r.URL.Query().Get("bot")
+	types, e := parseEventTypes(r.URL.Query().Get("types"))
+	if e != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", e)))
+		return
+	}
+
+	// subscribe before sending the current state so we don't miss any transitions in between
+	sub := s.events.subscribe(botName, types)
+	defer s.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// send the current state of bots so clients do not need to poll for the initial state
+	if sub.types[eventTypeBotState] {
+		for _, name := range s.kos.RegisteredBots() {
+			if botName != "" && name != botName {
+				continue
+			}
+			b, e := s.kos.GetBot(name)
+			if e != nil {
+				continue
+			}
+			e = writeEvent(w, botEvent{
+				Type:    eventTypeBotState,
+				BotName: name,
+				Date:    time.Now().UTC(),
+				Data:    botStateEventData{State: b.State.String()},
+			})
+			if e != nil {
+				log.Printf("stopped streaming events: %s\n", e)
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			_, e = w.Write([]byte(": keepalive\n\n"))
+		case ev := <-sub.events:
+			e = writeEvent(w, ev)
+		}
+		if e != nil {
+			log.Printf("stopped streaming events: %s\n", e)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event in the server-sent events format
+func writeEvent(w io.Writer, ev botEvent) error {
+	eventBytes, e := json.Marshal(ev)
+	if e != nil {
+		return fmt.Errorf("could not marshal event: %s", e)
+	}
+
+	_, e = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, string(eventBytes))
+	if e != nil {
+		return fmt.Errorf("could not write event: %s", e)
+	}
+	return nil
+}
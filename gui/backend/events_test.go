@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/plugins"
+)
+
+func drainEvents(sub *eventSubscription) []botEvent {
+	events := []botEvent{}
+	for {
+		select {
+		case e := <-sub.events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEventBrokerFilters(t *testing.T) {
+	b := makeEventBroker()
+	all := b.subscribe("", allEventTypes)
+	botA := b.subscribe("bot A", allEventTypes)
+	statesOnly := b.subscribe("", []eventType{eventTypeBotState})
+
+	b.publish(botEvent{Type: eventTypeBotState, BotName: "bot A"})
+	b.publish(botEvent{Type: eventTypeLogLine, BotName: "bot B"})
+	b.publish(botEvent{Type: eventTypeKelpError})
+
+	assert.Equal(t, 3, len(drainEvents(all)))
+	assert.Equal(t, []eventType{eventTypeBotState, eventTypeKelpError}, eventTypes(drainEvents(botA)))
+	assert.Equal(t, []eventType{eventTypeBotState}, eventTypes(drainEvents(statesOnly)))
+
+	// unsubscribed subscribers do not get events and publishing never blocks on full subscribers
+	b.unsubscribe(all)
+	for i := 0; i < eventSubscriptionBufferSize+1; i++ {
+		b.publish(botEvent{Type: eventTypeBotState, BotName: "bot A"})
+	}
+	assert.Equal(t, 0, len(drainEvents(all)))
+	assert.Equal(t, eventSubscriptionBufferSize, len(drainEvents(botA)))
+}
+
+func eventTypes(events []botEvent) []eventType {
+	types := []eventType{}
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestStreamBotOutput(t *testing.T) {
+	summaryLine, e := plugins.MakeUpdateLoopSummaryLogLine(plugins.UpdateLoopSummary{
+		UpdateLoopResult: plugins.UpdateLoopResult{Success: true, NumUpdateOpsCreate: 4},
+		StartTime:        time.Date(2020, 3, 14, 15, 0, 0, 0, time.UTC),
+		MillisForUpdate:  1200,
+	})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	s := &APIServer{events: makeEventBroker()}
+	sub := s.events.subscribe("bot A", allEventTypes)
+	output := strings.Join([]string{
+		"2020/03/14 15:00:00 starting update loop",
+		"2020/03/14 15:00:01 " + summaryLine,
+	}, "\n")
+	s.streamBotOutput("bot A", strings.NewReader(output))
+
+	events := drainEvents(sub)
+	if !assert.Equal(t, []eventType{eventTypeLogLine, eventTypeLogLine, eventTypeUpdateLoop}, eventTypes(events)) {
+		return
+	}
+	assert.Equal(t, logLineEventData{Line: "2020/03/14 15:00:00 starting update loop"}, events[0].Data)
+	summary := events[2].Data.(*plugins.UpdateLoopSummary)
+	assert.True(t, summary.Success)
+	assert.Equal(t, 4, summary.NumUpdateOpsCreate)
+	assert.Equal(t, int64(1200), summary.MillisForUpdate)
+}
+
+func TestParseEventTypes(t *testing.T) {
+	types, e := parseEventTypes("")
+	assert.NoError(t, e)
+	assert.Equal(t, allEventTypes, types)
+
+	types, e = parseEventTypes("bot_state, log_line")
+	assert.NoError(t, e)
+	assert.Equal(t, []eventType{eventTypeBotState, eventTypeLogLine}, types)
+
+	_, e = parseEventTypes("bot_state,trades")
+	assert.Error(t, e)
+}
+
+func TestWriteEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := writeEvent(buf, botEvent{
+		Type:    eventTypeBotState,
+		BotName: "bot A",
+		Date:    time.Date(2020, 3, 14, 15, 0, 0, 0, time.UTC),
+		Data:    botStateEventData{State: "running"},
+	})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "event: bot_state\ndata: {\"type\":\"bot_state\",\"bot_name\":\"bot A\",\"date\":\"2020-03-14T15:00:00Z\",\"data\":{\"state\":\"running\"}}\n\n", buf.String())
+}
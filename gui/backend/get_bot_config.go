@@ -41,6 +41,7 @@ func (s *APIServer) getBotConfig(w http.ResponseWriter, r *http.Request) {
 		))
 		return
 	}
+	// references to secrets are returned as-is so they are written back unchanged when the config is saved, they are resolved when the bot starts
 	strategyFilePath := s.botConfigsPath.Join(filenamePair.Strategy)
 	var buysellConfig plugins.BuySellConfig
 	e = config.Read(strategyFilePath.Native(), &buysellConfig)
@@ -36,6 +36,9 @@ type botInfo struct {
 	NumAsks        int                `json:"num_asks"`
 	SpreadValue    float64            `json:"spread_value"`
 	SpreadPercent  float64            `json:"spread_pct"`
+	RestartCount   uint64             `json:"restart_count"`
+	LastRestart    string             `json:"last_restart"`
+	LastExitError  string             `json:"last_exit_error"`
 }
 
 func (s *APIServer) getBotInfo(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +86,20 @@ func (s *APIServer) runGetBotInfoDirect(w http.ResponseWriter, botName string) {
 		))
 		return
 	}
+	e = utils.ExpandEnvRefs(&botConfig)
+	if e == nil {
+		e = botConfig.ResolveSecrets(s.resolveSecret)
+	}
+	if e != nil {
+		s.writeKelpError(w, makeKelpErrorResponseWrapper(
+			errorTypeBot,
+			botName,
+			time.Now().UTC(),
+			errorLevelError,
+			fmt.Sprintf("cannot resolve secrets in bot config at path '%s': %s\n", traderFilePath.AsString(), e),
+		))
+		return
+	}
 	e = botConfig.Init()
 	if e != nil {
 		s.writeKelpError(w, makeKelpErrorResponseWrapper(
@@ -231,6 +248,13 @@ func (s *APIServer) runGetBotInfoDirect(w http.ResponseWriter, botName string) {
 		SpreadValue:    model.NumberFromFloat(spread, 8).AsFloat(),
 		SpreadPercent:  model.NumberFromFloat(spreadPct, 8).AsFloat(),
 	}
+	if entry, ok := s.botRegistry.get(botName); ok {
+		bi.RestartCount = entry.RestartCount
+		bi.LastExitError = entry.LastExitError
+		if entry.LastRestartDate != nil {
+			bi.LastRestart = entry.LastRestartDate.Format("1/_2/2006 15:04:05 MST")
+		}
+	}
 
 	marshalledJSON, e := json.MarshalIndent(bi, "", "  ")
 	if e != nil {
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/stellar/kelp/gui/model2"
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/toml"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stellar/kelp/trader"
+)
+
+// keystoreSecretName is the name under which a secret of a bot's trader config is stored in the keystore
+func keystoreSecretName(botName string, field string) string {
+	return fmt.Sprintf("%s/%s", model2.GetPrefix(botName), field)
+}
+
+// resolveSecret resolves references to files and to the keystore in config values
+func (s *APIServer) resolveSecret(value string) (string, error) {
+	value, e := utils.ResolveFileRef(value)
+	if e != nil {
+		return "", e
+	}
+
+	if s.keystore == nil {
+		if name, ok := keystore.ParseRef(value); ok {
+			return "", fmt.Errorf("config references secret '%s' in a keystore but the server was not started with a keystore, use the --keystore flag", name)
+		}
+		return value, nil
+	}
+	return s.keystore.Resolve(value)
+}
+
+// withSecretsInKeystore returns a copy of the trader config where the plaintext secrets are moved into the keystore and
+// replaced with references to it, the config is returned unchanged when the server was not started with a keystore
+func (s *APIServer) withSecretsInKeystore(botName string, botConfig trader.BotConfig) (trader.BotConfig, error) {
+	if s.keystore == nil {
+		return botConfig, nil
+	}
+
+	// copy the fields that are shared with the input config so we don't modify it
+	botConfig.ExchangeAPIKeys = append(toml.ExchangeAPIKeysToml{}, botConfig.ExchangeAPIKeys...)
+	if botConfig.PostgresDbConfig != nil {
+		pgConfig := *botConfig.PostgresDbConfig
+		botConfig.PostgresDbConfig = &pgConfig
+	}
+
+	secrets := map[string]*string{
+		"source_secret_seed":  &botConfig.SourceSecretSeed,
+		"trading_secret_seed": &botConfig.TradingSecretSeed,
+	}
+	for i := range botConfig.ExchangeAPIKeys {
+		secrets[fmt.Sprintf("exchange_api_key_%d", i)] = &botConfig.ExchangeAPIKeys[i].Key
+		secrets[fmt.Sprintf("exchange_api_secret_%d", i)] = &botConfig.ExchangeAPIKeys[i].Secret
+	}
+	if botConfig.PostgresDbConfig != nil {
+		secrets["postgres_db_password"] = &botConfig.PostgresDbConfig.Password
+	}
+
+	numStored := 0
+	for field, value := range secrets {
+		// references to files and environment variables are kept so the secret stays where the user put it
+		if *value == "" || keystore.IsRef(*value) || utils.IsConfigRef(*value) {
+			continue
+		}
+
+		name := keystoreSecretName(botName, field)
+		e := s.keystore.Set(name, *value)
+		if e != nil {
+			return trader.BotConfig{}, fmt.Errorf("could not add secret '%s' to keystore: %s", name, e)
+		}
+		*value = keystore.MakeRef(name)
+		numStored++
+	}
+
+	if numStored > 0 {
+		e := s.keystore.Save()
+		if e != nil {
+			return trader.BotConfig{}, fmt.Errorf("could not save keystore: %s", e)
+		}
+	}
+	return botConfig, nil
+}
@@ -0,0 +1,80 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/support/keystore"
+	"github.com/stellar/kelp/support/toml"
+	"github.com/stellar/kelp/trader"
+)
+
+func TestWithSecretsInKeystore(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_gui_keystore")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	ks, e := keystore.Create(filepath.Join(dir, "keystore.json"), "passphrase")
+	if !assert.NoError(t, e) {
+		return
+	}
+	secretFile := filepath.Join(dir, "api_secret")
+	if !assert.NoError(t, ioutil.WriteFile(secretFile, []byte("filesecret\n"), 0600)) {
+		return
+	}
+
+	botConfig := trader.BotConfig{
+		TradingSecretSeed: "SBTRADING",
+		SourceSecretSeed:  "keystore:existing",
+		ExchangeAPIKeys: toml.ExchangeAPIKeysToml{
+			{Key: "apikey", Secret: "apisecret"},
+			{Key: "${KELP_TEST_API_KEY}", Secret: "file:" + secretFile},
+		},
+		AlertAPIKey:        "file:" + secretFile,
+		GoogleClientID:     "keystore:google_client_id",
+		GoogleClientSecret: "file:" + secretFile,
+	}
+
+	// without a keystore the config is written as-is
+	s := &APIServer{}
+	unchanged, e := s.withSecretsInKeystore("My Bot", botConfig)
+	assert.NoError(t, e)
+	assert.Equal(t, botConfig, unchanged)
+	_, e = s.resolveSecret("keystore:existing")
+	assert.Error(t, e)
+
+	s = &APIServer{keystore: ks}
+	stored, e := s.withSecretsInKeystore("My Bot", botConfig)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "keystore:my_bot/trading_secret_seed", stored.TradingSecretSeed)
+	assert.Equal(t, "keystore:existing", stored.SourceSecretSeed)
+	assert.Equal(t, "keystore:my_bot/exchange_api_key_0", stored.ExchangeAPIKeys[0].Key)
+	assert.Equal(t, "keystore:my_bot/exchange_api_secret_0", stored.ExchangeAPIKeys[0].Secret)
+	// references to environment variables and files are not moved into the keystore
+	assert.Equal(t, "${KELP_TEST_API_KEY}", stored.ExchangeAPIKeys[1].Key)
+	assert.Equal(t, "file:"+secretFile, stored.ExchangeAPIKeys[1].Secret)
+
+	// the input config is not modified
+	assert.Equal(t, "SBTRADING", botConfig.TradingSecretSeed)
+	assert.Equal(t, "apisecret", botConfig.ExchangeAPIKeys[0].Secret)
+
+	// the references resolve to the original secrets
+	assert.NoError(t, ks.Set("existing", "SBSOURCE"))
+	assert.NoError(t, ks.Set("google_client_id", "googleid"))
+	assert.NoError(t, stored.ResolveSecrets(s.resolveSecret))
+	assert.Equal(t, "SBTRADING", stored.TradingSecretSeed)
+	assert.Equal(t, "SBSOURCE", stored.SourceSecretSeed)
+	assert.Equal(t, "apikey", stored.ExchangeAPIKeys[0].Key)
+	assert.Equal(t, "apisecret", stored.ExchangeAPIKeys[0].Secret)
+	assert.Equal(t, "filesecret", stored.ExchangeAPIKeys[1].Secret)
+	assert.Equal(t, "filesecret", stored.AlertAPIKey)
+	assert.Equal(t, "googleid", stored.GoogleClientID)
+	assert.Equal(t, "filesecret", stored.GoogleClientSecret)
+}
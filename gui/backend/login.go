@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const loginPage = `<!DOCTYPE html>
+<html>
+<head><title>Kelp - Log in</title></head>
+<body>
+<form method="POST" action="/api/v1/login">
+<p><input name="username" placeholder="username" autofocus></p>
+<p><input name="password" type="password" placeholder="password"></p>
+<p>or</p>
+<p><input name="token" type="password" placeholder="API token"></p>
+<p><button type="submit">Log in</button></p>
+</form>
+</body>
+</html>
+`
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+type loginResponse struct {
+	Name        string    `json:"name"`
+	Role        Role      `json:"role"`
+	ExpiresDate time.Time `json:"expires_date"`
+	AuthEnabled bool      `json:"auth_enabled"`
+}
+
+func (s *APIServer) loginForm(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(loginPage))
+}
+
+func (s *APIServer) login(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		s.writeError(w, "authentication is not enabled on this server\n")
+		return
+	}
+
+	isForm := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	var req loginRequest
+	if isForm {
+		e := r.ParseForm()
+		if e != nil {
+			s.writeError(w, fmt.Sprintf("error parsing login form: %s\n", e))
+			return
+		}
+		req = loginRequest{
+			Username: r.PostFormValue("username"),
+			Password: r.PostFormValue("password"),
+			Token:    r.PostFormValue("token"),
+		}
+	} else {
+		e := json.NewDecoder(r.Body).Decode(&req)
+		if e != nil {
+			s.writeError(w, fmt.Sprintf("error parsing login request: %s\n", e))
+			return
+		}
+	}
+
+	var p *principal
+	var e error
+	if req.Token != "" {
+		p, e = s.auth.checkToken(req.Token)
+	} else {
+		p, e = s.auth.checkPassword(req.Username, req.Password)
+	}
+	if e != nil {
+		s.auth.audit(auditEntry{
+			Date:       s.auth.now().UTC(),
+			User:       req.Username,
+			Action:     "login",
+			RemoteAddr: r.RemoteAddr,
+			Status:     http.StatusUnauthorized,
+		})
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(fmt.Sprintf("%s\n", e)))
+		return
+	}
+
+	sessionID, expiry, e := s.auth.newSession(*p)
+	if e != nil {
+		s.writeError(w, fmt.Sprintf("error creating session: %s\n", e))
+		return
+	}
+	s.auth.audit(auditEntry{
+		Date:       s.auth.now().UTC(),
+		User:       p.Name,
+		Role:       p.Role,
+		Action:     "login",
+		RemoteAddr: r.RemoteAddr,
+		Status:     http.StatusOK,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	if isForm {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	s.writeLoginResponse(w, p, expiry)
+}
+
+func (s *APIServer) logout(w http.ResponseWriter, r *http.Request) {
+	if s.auth != nil {
+		if c, e := r.Cookie(sessionCookieName); e == nil {
+			s.auth.deleteSession(c.Value)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *APIServer) whoami(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		// everyone is an operator when auth is disabled
+		s.writeJson(w, loginResponse{Role: RoleOperator, AuthEnabled: false})
+		return
+	}
+
+	p := principalFromContext(r.Context())
+	if p == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("unauthorized, need to log in\n"))
+		return
+	}
+	s.writeLoginResponse(w, p, time.Time{})
+}
+
+func (s *APIServer) writeLoginResponse(w http.ResponseWriter, p *principal, expiry time.Time) {
+	s.writeJson(w, loginResponse{
+		Name:        p.Name,
+		Role:        p.Role,
+		ExpiresDate: expiry,
+		AuthEnabled: true,
+	})
+}
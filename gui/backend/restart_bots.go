@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stellar/kelp/gui/model2"
+	"github.com/stellar/kelp/support/kelpos"
+)
+
+// deleteStrategy is passed in place of the strategy to run the bot once to delete its offers when stopping it
+const deleteStrategy = "delete"
+
+func (s *APIServer) addRegistryError(botName string, e error) {
+	s.addKelpErrorToMap(makeKelpErrorResponseWrapper(
+		errorTypeBot,
+		botName,
+		time.Now().UTC(),
+		errorLevelWarning,
+		fmt.Sprintf("could not update bot registry, the bot may not be restarted correctly when the server restarts: %s", e),
+	).KelpError)
+}
+
+// maybeRestartBot schedules a restart with backoff if the bot crashed while its desired state was running
+func (s *APIServer) maybeRestartBot(botName string, runDuration time.Duration, exitError error) {
+	shouldRestart, backoff, e := s.botRegistry.recordCrash(botName, runDuration, exitError, time.Now().UTC())
+	if e != nil {
+		s.addRegistryError(botName, e)
+	}
+	if !shouldRestart {
+		return
+	}
+
+	log.Printf("bot '%s' crashed after running for %s, restarting in %s\n", botName, runDuration, backoff)
+	time.AfterFunc(backoff, func() {
+		e := s.restartBot(botName)
+		if e != nil {
+			s.addKelpErrorToMap(makeKelpErrorResponseWrapper(
+				errorTypeBot,
+				botName,
+				time.Now().UTC(),
+				errorLevelError,
+				fmt.Sprintf("could not restart bot: %s", e),
+			).KelpError)
+			// count failures to launch as crashes so we keep backing off
+			s.maybeRestartBot(botName, 0, e)
+		}
+	})
+}
+
+func (s *APIServer) restartBot(botName string) error {
+	entry, ok := s.botRegistry.get(botName)
+	if !ok || entry.DesiredState != botDesiredStateRunning {
+		log.Printf("not restarting bot '%s' because it was stopped or deleted in the meantime\n", botName)
+		return nil
+	}
+
+	e := s.startRegisteredBot(botName, entry)
+	if e != nil {
+		return e
+	}
+
+	e = s.botRegistry.recordRestart(botName, time.Now().UTC())
+	if e != nil {
+		s.addRegistryError(botName, e)
+	}
+	log.Printf("restarted bot '%s' (restart count = %d)\n", botName, entry.RestartCount+1)
+	return nil
+}
+
+func (s *APIServer) startRegisteredBot(botName string, entry botRegistryEntry) error {
+	botState, e := s.doGetBotState(botName)
+	if e != nil {
+		return fmt.Errorf("unable to get bot state: %s", e)
+	}
+	if botState != kelpos.BotStateStopped {
+		return fmt.Errorf("bot is in state '%s' but needs to be in state '%s' to be started", botState, kelpos.BotStateStopped)
+	}
+
+	e = s.doStartBot(botName, entry.Strategy, entry.Iterations, nil)
+	if e != nil {
+		return fmt.Errorf("error starting bot: %s", e)
+	}
+
+	e = s.kos.AdvanceBotState(botName, kelpos.BotStateStopped)
+	if e != nil {
+		return fmt.Errorf("error advancing bot state: %s", e)
+	}
+	return nil
+}
+
+// startRegisteredBotsOnBoot starts the bots that were running when the server was last stopped
+func (s *APIServer) startRegisteredBotsOnBoot(bots []model2.Bot) {
+	existingBots := map[string]bool{}
+	for _, b := range bots {
+		existingBots[b.Name] = true
+	}
+
+	for _, botName := range s.botRegistry.runningBots() {
+		if !existingBots[botName] {
+			log.Printf("not starting bot '%s' on boot because its config files no longer exist\n", botName)
+			continue
+		}
+
+		entry, _ := s.botRegistry.get(botName)
+		botState, e := s.doGetBotState(botName)
+		if e == nil && botState == kelpos.BotStateRunning {
+			log.Printf("not starting bot '%s' on boot because it is already running\n", botName)
+			continue
+		}
+
+		log.Printf("starting bot '%s' on boot because it was running when the server was stopped\n", botName)
+		e = s.startRegisteredBot(botName, entry)
+		if e != nil {
+			s.addKelpErrorToMap(makeKelpErrorResponseWrapper(
+				errorTypeBot,
+				botName,
+				time.Now().UTC(),
+				errorLevelError,
+				fmt.Sprintf("could not start bot on boot: %s", e),
+			).KelpError)
+		}
+	}
+}
@@ -9,26 +9,45 @@ import (
 // SetRoutes
 func SetRoutes(r *chi.Mux, s *APIServer) {
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/version", http.HandlerFunc(s.version))
-		r.Get("/quit", http.HandlerFunc(s.quit))
-		r.Get("/listBots", http.HandlerFunc(s.listBots))
-		r.Get("/autogenerate", http.HandlerFunc(s.autogenerateBot))
-		r.Get("/genBotName", http.HandlerFunc(s.generateBotName))
-		r.Get("/getNewBotConfig", http.HandlerFunc(s.getNewBotConfig))
-		r.Get("/newSecretKey", http.HandlerFunc(s.newSecretKey))
-		r.Get("/optionsMetadata", http.HandlerFunc(s.optionsMetadata))
-		r.Get("/fetchKelpErrors", http.HandlerFunc(s.fetchKelpErrors))
-
-		r.Post("/removeKelpErrors", http.HandlerFunc(s.removeKelpErrors))
-		r.Post("/start", http.HandlerFunc(s.startBot))
-		r.Post("/stop", http.HandlerFunc(s.stopBot))
-		r.Post("/deleteBot", http.HandlerFunc(s.deleteBot))
-		r.Post("/getState", http.HandlerFunc(s.getBotState))
-		r.Post("/getBotInfo", http.HandlerFunc(s.getBotInfo))
-		r.Post("/getBotConfig", http.HandlerFunc(s.getBotConfig))
-		r.Post("/fetchPrice", http.HandlerFunc(s.fetchPrice))
-		r.Post("/upsertBotConfig", http.HandlerFunc(s.upsertBotConfig))
-		r.Post("/sendMetricEvent", http.HandlerFunc(s.sendMetricEvent))
+		r.Post("/login", http.HandlerFunc(s.login))
+		r.Post("/logout", http.HandlerFunc(s.logout))
+
+		// viewers can see the state of bots
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(RoleViewer))
+
+			r.Get("/whoami", http.HandlerFunc(s.whoami))
+			r.Get("/version", http.HandlerFunc(s.version))
+			r.Get("/listBots", http.HandlerFunc(s.listBots))
+			r.Get("/genBotName", http.HandlerFunc(s.generateBotName))
+			r.Get("/optionsMetadata", http.HandlerFunc(s.optionsMetadata))
+			r.Get("/fetchKelpErrors", http.HandlerFunc(s.fetchKelpErrors))
+			r.Get("/events", http.HandlerFunc(s.streamEvents))
+
+			r.Post("/getState", http.HandlerFunc(s.getBotState))
+			r.Post("/getBotInfo", http.HandlerFunc(s.getBotInfo))
+			r.Post("/fetchPrice", http.HandlerFunc(s.fetchPrice))
+			r.Post("/sendMetricEvent", http.HandlerFunc(s.sendMetricEvent))
+		})
+
+		// operators can start, stop, and edit bots, and can see their configs which include secret keys
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(RoleOperator))
+
+			r.Get("/getNewBotConfig", http.HandlerFunc(s.getNewBotConfig))
+			r.Get("/newSecretKey", http.HandlerFunc(s.newSecretKey))
+			r.Post("/getBotConfig", http.HandlerFunc(s.getBotConfig))
+
+			// mutating actions are written to the audit log, the body is only logged when it is the bot name
+			r.With(s.audited(false)).Get("/quit", http.HandlerFunc(s.quit))
+			r.With(s.audited(false)).Get("/autogenerate", http.HandlerFunc(s.autogenerateBot))
+			r.With(s.audited(false)).Post("/removeKelpErrors", http.HandlerFunc(s.removeKelpErrors))
+			r.With(s.audited(true)).Post("/start", http.HandlerFunc(s.startBot))
+			r.With(s.audited(true)).Post("/stop", http.HandlerFunc(s.stopBot))
+			r.With(s.audited(true)).Post("/deleteBot", http.HandlerFunc(s.deleteBot))
+			r.With(s.audited(false)).Post("/upsertBotConfig", http.HandlerFunc(s.upsertBotConfig))
+		})
 	})
+	r.Get("/login", http.HandlerFunc(s.loginForm))
 	r.Get("/ping", http.HandlerFunc(s.ping))
 }
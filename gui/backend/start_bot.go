@@ -2,10 +2,12 @@ package backend
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -45,6 +47,11 @@ func (s *APIServer) startBot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	e = s.botRegistry.setRunning(botName, "buysell", nil)
+	if e != nil {
+		s.addRegistryError(botName, e)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("{}"))
 }
@@ -105,6 +112,10 @@ func (s *APIServer) doStartBot(botName string, strategy string, iterations *uint
 	if s.ccxtRestUrl != "" {
 		command = fmt.Sprintf("%s --ccxt-rest-url %s", command, s.ccxtRestUrl)
 	}
+	if s.keystore != nil {
+		// the bot reads the passphrase from the environment it inherits from the server
+		command = fmt.Sprintf("%s --keystore %s", command, filepath.ToSlash(s.keystore.Filepath()))
+	}
 	log.Printf("run command for bot '%s': %s\n", botName, command)
 
 	p, e := s.runKelpCommandBackground(botName, command)
@@ -116,14 +127,22 @@ func (s *APIServer) doStartBot(botName string, strategy string, iterations *uint
 		return fmt.Errorf("kelpCommand (p.Cmd) was nil for bot '%s' with strategy '%s'", botName, strategy)
 	}
 
-	go func(kelpCommand *exec.Cmd, name string) {
+	startTime := time.Now()
+	go func(kelpCommand *exec.Cmd, name string, output io.Reader) {
 		defer s.kos.SafeUnregister(name)
 
+		// all reads from the output need to complete before we call Wait
+		s.streamBotOutput(name, output)
 		e := kelpCommand.Wait()
 		if e != nil {
 			if strings.Contains(e.Error(), "signal: killed") {
-				log.Printf("bot '%s' with strategy '%s' was stopped (most likely from UI action)", name, strategy)
-				return
+				entry, _ := s.botRegistry.get(name)
+				if strategy == deleteStrategy || entry.DesiredState != botDesiredStateRunning {
+					log.Printf("bot '%s' with strategy '%s' was stopped (most likely from UI action)", name, strategy)
+					return
+				}
+				// the bot was killed by something other than the UI, such as the OS running out of memory
+				log.Printf("bot '%s' with strategy '%s' was killed outside of the UI", name, strategy)
 			}
 
 			s.addKelpErrorToMap(makeKelpErrorResponseWrapper(
@@ -137,15 +156,27 @@ func (s *APIServer) doStartBot(botName string, strategy string, iterations *uint
 			// set state to stopped
 			s.abruptStoppedState(botName)
 
+			// the delete command is only run to stop the bot so we never restart it
+			if strategy != deleteStrategy {
+				s.maybeRestartBot(name, time.Since(startTime), e)
+			}
+
 			// we don't want to continue because the bot didn't finish correctly
 			return
 		}
 
 		log.Printf("finished start bot command for bot '%s' with strategy '%s'\n", name, strategy)
+		if strategy != deleteStrategy {
+			// the bot finished by itself (such as when running for a fixed number of iterations) so it should not be restarted
+			eInner := s.botRegistry.setStopped(name)
+			if eInner != nil {
+				s.addRegistryError(name, eInner)
+			}
+		}
 		if maybeFinishCallback != nil {
 			maybeFinishCallback()
 		}
-	}(p.Cmd, botName)
+	}(p.Cmd, botName, p.Stdout)
 
 	return nil
 }
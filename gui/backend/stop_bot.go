@@ -31,7 +31,13 @@ func (s *APIServer) stopBot(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *APIServer) doStopBot(botName string) error {
-	e := s.kos.AdvanceBotState(botName, kelpos.BotStateRunning)
+	// update the desired state first so the bot is not restarted when it is killed
+	e := s.botRegistry.setStopped(botName)
+	if e != nil {
+		s.addRegistryError(botName, e)
+	}
+
+	e = s.kos.AdvanceBotState(botName, kelpos.BotStateRunning)
 	if e != nil {
 		return fmt.Errorf("error advancing bot state: %s", e)
 	}
@@ -43,7 +49,7 @@ func (s *APIServer) doStopBot(botName string) error {
 	log.Printf("stopped bot '%s'\n", botName)
 
 	var numIterations uint8 = 1
-	e = s.doStartBot(botName, "delete", &numIterations, func() {
+	e = s.doStartBot(botName, deleteStrategy, &numIterations, func() {
 		eInner := s.deleteFinishCallback(botName)
 		if eInner != nil {
 			s.addKelpErrorToMap(makeKelpErrorResponseWrapper(
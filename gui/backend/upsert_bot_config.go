@@ -75,14 +75,21 @@ func (s *APIServer) upsertBotConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// resolve references to secrets on a copy so we validate the secrets themselves, the config is written with the references
+	resolvedReq, e := s.withResolvedSecrets(req)
+	if e != nil {
+		s.writeErrorJson(w, fmt.Sprintf("error resolving secrets in TraderConfig: %s", e))
+		return
+	}
+
 	// validate before init validation so we return validation errors to user instead of throwing unknown errors on init if file is invalid
-	if errResp := s.validateConfigs(req); errResp != nil {
+	if errResp := s.validateConfigs(resolvedReq); errResp != nil {
 		s.writeJson(w, errResp)
 		return
 	}
 
 	// init after validation so we return validation errors to user instead of throwing unknown errors on init if file is invalid
-	e = req.TraderConfig.Init()
+	e = resolvedReq.TraderConfig.Init()
 	if e != nil {
 		s.writeErrorJson(w, fmt.Sprintf("error running Init() for TraderConfig: %s", e))
 		return
@@ -96,7 +103,12 @@ func (s *APIServer) upsertBotConfig(w http.ResponseWriter, r *http.Request) {
 
 	filenamePair := model2.GetBotFilenames(req.Name, req.Strategy)
 	traderFilePath := s.botConfigsPath.Join(filenamePair.Trader)
-	botConfig := req.TraderConfig
+	// secrets are stored in the keystore instead of in plaintext when the server was started with a keystore
+	botConfig, e := s.withSecretsInKeystore(req.Name, req.TraderConfig)
+	if e != nil {
+		s.writeErrorJson(w, fmt.Sprintf("error storing secrets of bot '%s' in keystore: %s", req.Name, e))
+		return
+	}
 	log.Printf("upsert bot config to file: %s\n", traderFilePath.AsString())
 	e = toml.WriteFile(traderFilePath.Native(), &botConfig)
 	if e != nil {
@@ -114,11 +126,37 @@ func (s *APIServer) upsertBotConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// check if we need to create new funding accounts and new trustlines
-	s.reinitBotCheck(req)
+	s.reinitBotCheck(resolvedReq)
 
 	s.writeJson(w, upsertBotConfigResponse{Success: true})
 }
 
+// withResolvedSecrets returns a copy of the request with the references to secrets in the trader config resolved
+func (s *APIServer) withResolvedSecrets(req upsertBotConfigRequest) (upsertBotConfigRequest, error) {
+	// round trip through json so the resolved config does not share any slices or pointers with the input config
+	jsonBytes, e := json.Marshal(req.TraderConfig)
+	if e != nil {
+		return upsertBotConfigRequest{}, fmt.Errorf("could not marshal TraderConfig: %s", e)
+	}
+	var botConfig trader.BotConfig
+	e = json.Unmarshal(jsonBytes, &botConfig)
+	if e != nil {
+		return upsertBotConfigRequest{}, fmt.Errorf("could not unmarshal TraderConfig: %s", e)
+	}
+
+	e = utils.ExpandEnvRefs(&botConfig)
+	if e != nil {
+		return upsertBotConfigRequest{}, e
+	}
+	e = botConfig.ResolveSecrets(s.resolveSecret)
+	if e != nil {
+		return upsertBotConfigRequest{}, e
+	}
+
+	req.TraderConfig = botConfig
+	return req, nil
+}
+
 func (s *APIServer) validateConfigs(req upsertBotConfigRequest) *upsertBotConfigResponseErrors {
 	hasError := false
 	errResp := upsertBotConfigRequest{
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stellar/kelp/gui/model2"
+	"github.com/stellar/kelp/support/toml"
+	"github.com/stellar/kelp/trader"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -107,3 +110,40 @@ func TestIsBotNameValid(t *testing.T) {
 		})
 	}
 }
+
+func TestWithResolvedSecrets(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_gui_resolve")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "api_secret")
+	if !assert.NoError(t, ioutil.WriteFile(secretFile, []byte("filesecret\n"), 0600)) {
+		return
+	}
+	os.Setenv("KELP_TEST_TRADING_SEED", "SBTRADING")
+	defer os.Unsetenv("KELP_TEST_TRADING_SEED")
+
+	req := upsertBotConfigRequest{
+		Name: "My Bot",
+		TraderConfig: trader.BotConfig{
+			TradingSecretSeed: "${KELP_TEST_TRADING_SEED}",
+			ExchangeAPIKeys: toml.ExchangeAPIKeysToml{
+				{Key: "apikey", Secret: "file:" + secretFile},
+			},
+		},
+	}
+
+	s := &APIServer{}
+	resolvedReq, e := s.withResolvedSecrets(req)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "My Bot", resolvedReq.Name)
+	assert.Equal(t, "SBTRADING", resolvedReq.TraderConfig.TradingSecretSeed)
+	assert.Equal(t, "filesecret", resolvedReq.TraderConfig.ExchangeAPIKeys[0].Secret)
+
+	// the references in the input are kept so they are written back to the config file
+	assert.Equal(t, "${KELP_TEST_TRADING_SEED}", req.TraderConfig.TradingSecretSeed)
+	assert.Equal(t, "file:"+secretFile, req.TraderConfig.ExchangeAPIKeys[0].Secret)
+}
@@ -8,6 +8,10 @@ const SqlTradesTableCreate = "CREATE TABLE IF NOT EXISTS trades (market_id TEXT
 const SqlTradesTableAlter1 = "ALTER TABLE trades ADD COLUMN account_id TEXT"
 const SqlStrategyMirrorTradeTriggersTableCreate = "CREATE TABLE IF NOT EXISTS strategy_mirror_trade_triggers (market_id TEXT NOT NULL, txid TEXT NOT NULL, backing_market_id TEXT NOT NULL, backing_order_id TEXT NOT NULL, PRIMARY KEY (market_id, txid))"
 const SqlTradesTableAlter2 = "ALTER TABLE trades ADD COLUMN order_id TEXT"
+const SqlTradesTableAlter3 = "ALTER TABLE trades ADD COLUMN client_order_id TEXT"
+const SqlOrdersTableCreate = "CREATE TABLE IF NOT EXISTS orders (account_id TEXT NOT NULL, market_id TEXT NOT NULL, order_id TEXT NOT NULL, client_order_id TEXT NOT NULL, action TEXT NOT NULL, counter_price DOUBLE PRECISION NOT NULL, base_volume DOUBLE PRECISION NOT NULL, submit_date_utc TIMESTAMP WITHOUT TIME ZONE NOT NULL, cancel_date_utc TIMESTAMP WITHOUT TIME ZONE, close_date_utc TIMESTAMP WITHOUT TIME ZONE, state TEXT NOT NULL, filled_base_volume DOUBLE PRECISION NOT NULL, PRIMARY KEY (account_id, market_id, order_id))"
+const SqlBotHeartbeatsTableCreate = "CREATE TABLE IF NOT EXISTS bot_heartbeats (account_id TEXT NOT NULL, bot_key TEXT NOT NULL, market_id TEXT NOT NULL, base_code TEXT NOT NULL, base_issuer TEXT NOT NULL, quote_code TEXT NOT NULL, quote_issuer TEXT NOT NULL, last_update_utc TIMESTAMP WITHOUT TIME ZONE NOT NULL, PRIMARY KEY (account_id, bot_key))"
+const SqlRebalancerTransfersTableCreate = "CREATE TABLE IF NOT EXISTS rebalancer_transfers (market_id TEXT NOT NULL, asset TEXT NOT NULL, withdrawal_id TEXT NOT NULL, from_primary BOOLEAN NOT NULL, amount TEXT NOT NULL, expected_receive TEXT NOT NULL, starting_dest_balance TEXT NOT NULL, start_date_utc TIMESTAMP WITHOUT TIME ZONE NOT NULL, PRIMARY KEY (market_id, asset))"
 
 /*
 	indexes
@@ -15,6 +19,7 @@ const SqlTradesTableAlter2 = "ALTER TABLE trades ADD COLUMN order_id TEXT"
 const SqlTradesIndexCreate = "CREATE INDEX IF NOT EXISTS date ON trades (market_id, date_utc)"
 const SqlTradesIndexDrop = "DROP INDEX IF EXISTS date"
 const SqlTradesIndexCreate2 = "CREATE INDEX IF NOT EXISTS trades_mdd ON trades (market_id, DATE(date_utc), date_utc)"
+const SqlOrdersIndexCreate = "CREATE INDEX IF NOT EXISTS orders_ams ON orders (account_id, market_id, state)"
 
 // We don't include account_id in the primary key of the trades table because the account_id will initially be null until we clean that up (later)
 // For now we add it as a unique index on which we will later base the primary key. This does not provide us with any immediate benefit because the PK is a subset
@@ -28,13 +33,49 @@ const SqlTradesIndexCreate3 = "CREATE UNIQUE INDEX IF NOT EXISTS trades_amt ON t
 const SqlMarketsInsertTemplate = "INSERT INTO markets (market_id, exchange_name, base, quote) VALUES ('%s', '%s', '%s', '%s')"
 
 // SqlTradesInsertTemplate inserts into the trades table
-const SqlTradesInsertTemplate = "INSERT INTO trades (market_id, txid, date_utc, action, type, counter_price, base_volume, counter_cost, fee, account_id, order_id) VALUES ('%s', '%s', '%s', '%s', '%s', %.15f, %.15f, %.15f, %.15f, '%s', '%s')"
+const SqlTradesInsertTemplate = "INSERT INTO trades (market_id, txid, date_utc, action, type, counter_price, base_volume, counter_cost, fee, account_id, order_id, client_order_id) VALUES ('%s', '%s', '%s', '%s', '%s', %.15f, %.15f, %.15f, %.15f, '%s', '%s', '%s')"
+
+// SqlOrdersInsertTemplate inserts into the orders table, ignoring orders that were already inserted (such as when the bot restarts)
+const SqlOrdersInsertTemplate = "INSERT INTO orders (account_id, market_id, order_id, client_order_id, action, counter_price, base_volume, submit_date_utc, state, filled_base_volume) VALUES ('%s', '%s', '%s', '%s', '%s', %.15f, %.15f, '%s', '%s', 0) ON CONFLICT DO NOTHING"
 
 // SqlStrategyMirrorTradeTriggersInsertTemplate inserts into the strategy_mirror_trade_triggers table
 const SqlStrategyMirrorTradeTriggersInsertTemplate = "INSERT INTO strategy_mirror_trade_triggers (market_id, txid, backing_market_id, backing_order_id) VALUES ('%s', '%s', '%s', '%s')"
 
+// SqlBotHeartbeatsUpsertTemplate records the last update time of a bot, there is one row per bot
+const SqlBotHeartbeatsUpsertTemplate = "INSERT INTO bot_heartbeats (account_id, bot_key, market_id, base_code, base_issuer, quote_code, quote_issuer, last_update_utc) VALUES ('%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s') ON CONFLICT (account_id, bot_key) DO UPDATE SET market_id = EXCLUDED.market_id, last_update_utc = EXCLUDED.last_update_utc"
+
+// SqlRebalancerTransfersUpsert records a pending transfer of the rebalancer, there is at most one row per asset of a market
+const SqlRebalancerTransfersUpsert = "INSERT INTO rebalancer_transfers (market_id, asset, withdrawal_id, from_primary, amount, expected_receive, starting_dest_balance, start_date_utc) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (market_id, asset) DO UPDATE SET withdrawal_id = EXCLUDED.withdrawal_id"
+
+/*
+	update statements
+*/
+// SqlOrdersUpdatePriceVolumeTemplate updates an order that was modified in place
+const SqlOrdersUpdatePriceVolumeTemplate = "UPDATE orders SET counter_price = %.15f, base_volume = %.15f WHERE account_id = '%s' AND market_id = '%s' AND order_id = '%s'"
+
+// SqlOrdersUpdateFilledVolumeTemplate adds a fill to an order
+const SqlOrdersUpdateFilledVolumeTemplate = "UPDATE orders SET filled_base_volume = filled_base_volume + %.15f WHERE account_id = '%s' AND market_id = '%s' AND order_id = '%s'"
+
+// SqlOrdersUpdateStateTemplate sets the state of an order, cancel_date_utc is passed in as a literal so it can be NULL
+const SqlOrdersUpdateStateTemplate = "UPDATE orders SET state = '%s', cancel_date_utc = %s, close_date_utc = '%s' WHERE account_id = '%s' AND market_id = '%s' AND order_id = '%s'"
+
+/*
+	delete statements
+*/
+// SqlBotHeartbeatsDelete deletes the heartbeat of a bot unless the bot updated it after the cutoff
+const SqlBotHeartbeatsDelete = "DELETE FROM bot_heartbeats WHERE account_id = $1 AND bot_key = $2 AND last_update_utc < $3"
+
+// SqlRebalancerTransfersDelete deletes a pending transfer of the rebalancer once it is resolved
+const SqlRebalancerTransfersDelete = "DELETE FROM rebalancer_transfers WHERE market_id = $1 AND asset = $2"
+
 /*
 	queries
 */
 // SqlQueryMarketsById queries the markets table
 const SqlQueryMarketsById = "SELECT market_id, exchange_name, base, quote FROM markets WHERE market_id = $1 LIMIT 1"
+
+// SqlQueryOpenOrders queries the orders table for orders that have not been closed
+const SqlQueryOpenOrders = "SELECT order_id, client_order_id, action, counter_price, base_volume, submit_date_utc, state, filled_base_volume FROM orders WHERE account_id = $1 AND market_id = $2 AND close_date_utc IS NULL"
+
+// SqlQueryRebalancerTransfers queries the rebalancer_transfers table for the pending transfers of a market
+const SqlQueryRebalancerTransfers = "SELECT asset, withdrawal_id, from_primary, amount, expected_receive, starting_dest_balance, start_date_utc FROM rebalancer_transfers WHERE market_id = $1"
@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/stellar/go/price"
 )
@@ -26,8 +27,13 @@ const InvertPrecision = 11
 // InternalCalculationsPrecision is the precision to be used for internal calculations in a function
 const InternalCalculationsPrecision = 15
 
-// Number abstraction
+// Number abstraction, it is an exact decimal with precision digits after the decimal point. All arithmetic is done on the exact
+// decimal values and only the result is rounded to the precision, so results do not carry the rounding errors of float64 math
 type Number struct {
+	// decimal is the exact value formatted with precision digits after the decimal point, it is canonical so Numbers can be compared
+	// with == and reflect.DeepEqual. The empty string is the zero value
+	decimal string
+	// value is the float64 closest to decimal, cached since AsFloat is called often
 	value     float64
 	precision int8
 }
@@ -44,7 +50,20 @@ func (n Number) Precision() int8 {
 
 // AsString gives a string representation
 func (n Number) AsString() string {
-	return fmt.Sprintf(fmt.Sprintf("%%.%df", n.Precision()), n.AsFloat())
+	if n.decimal == "" {
+		return formatScaled(big.NewInt(0), n.precision)
+	}
+	return n.decimal
+}
+
+// AsRat returns the exact value as a big.Rat
+func (n Number) AsRat() *big.Rat {
+	r, ok := new(big.Rat).SetString(n.AsString())
+	if !ok {
+		// decimal is always produced by formatScaled so this can only happen if the Number was corrupted
+		panic(fmt.Sprintf("model.Number has an invalid decimal value '%s'", n.decimal))
+	}
+	return r
 }
 
 // AsRatio returns an integer numerator and denominator
@@ -56,9 +75,19 @@ func (n Number) AsRatio() (int32, int32, error) {
 	return int32(p.N), int32(p.D), nil
 }
 
+// Sign returns -1 if the number is negative, 0 if it is zero and +1 if it is positive
+func (n Number) Sign() int {
+	return n.AsRat().Sign()
+}
+
+// Cmp compares the exact values of the two numbers, ignoring precision. It returns -1 if n < n2, 0 if n == n2 and +1 if n > n2
+func (n Number) Cmp(n2 Number) int {
+	return n.AsRat().Cmp(n2.AsRat())
+}
+
 // Abs returns the absolute of the number
 func (n Number) Abs() *Number {
-	if n.AsFloat() < 0 {
+	if n.Sign() < 0 {
 		return n.Negate()
 	}
 	return &n
@@ -71,43 +100,53 @@ func (n Number) Negate() *Number {
 
 // Add returns a new Number after adding the passed in Number
 func (n Number) Add(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloat(n.AsFloat()+n2.AsFloat(), newPrecision)
+	sum := new(big.Rat).Add(n.AsRat(), n2.AsRat())
+	return numberFromRat(sum, minPrecision(n, n2), RoundUp)
 }
 
 // Subtract returns a new Number after subtracting out the passed in Number
 func (n Number) Subtract(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloat(n.AsFloat()-n2.AsFloat(), newPrecision)
+	diff := new(big.Rat).Sub(n.AsRat(), n2.AsRat())
+	return numberFromRat(diff, minPrecision(n, n2), RoundUp)
 }
 
 // Multiply returns a new Number after multiplying with the passed in Number by rounding up based on the smaller precision
 func (n Number) Multiply(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloat(n.AsFloat()*n2.AsFloat(), newPrecision)
+	return n.MultiplyWithRounding(n2, RoundUp)
 }
 
 // MultiplyRoundTruncate returns a new Number after multiplying with the passed in Number by truncating based on the smaller precision
 func (n Number) MultiplyRoundTruncate(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloatRoundTruncate(n.AsFloat()*n2.AsFloat(), newPrecision)
+	return n.MultiplyWithRounding(n2, RoundTruncate)
+}
+
+// MultiplyWithRounding returns a new Number after multiplying with the passed in Number by rounding based on the smaller precision
+func (n Number) MultiplyWithRounding(n2 Number, rounding Rounding) *Number {
+	product := new(big.Rat).Mul(n.AsRat(), n2.AsRat())
+	return numberFromRat(product, minPrecision(n, n2), rounding)
 }
 
 // Divide returns a new Number after dividing by the passed in Number by rounding up based on the smaller precision
 func (n Number) Divide(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloat(n.AsFloat()/n2.AsFloat(), newPrecision)
+	return n.DivideWithRounding(n2, RoundUp)
 }
 
 // DivideRoundTruncate returns a new Number after dividing by the passed in Number by truncating based on the smaller precision
 func (n Number) DivideRoundTruncate(n2 Number) *Number {
-	newPrecision := minPrecision(n, n2)
-	return NumberFromFloatRoundTruncate(n.AsFloat()/n2.AsFloat(), newPrecision)
+	return n.DivideWithRounding(n2, RoundTruncate)
+}
+
+// DivideWithRounding returns a new Number after dividing by the passed in Number by rounding based on the smaller precision, panics
+// when dividing by zero
+func (n Number) DivideWithRounding(n2 Number, rounding Rounding) *Number {
+	quotient := new(big.Rat).Quo(n.AsRat(), n2.AsRat())
+	return numberFromRat(quotient, minPrecision(n, n2), rounding)
 }
 
 // Scale takes in a scalar with which to multiply the number using the same precision of the original number
 func (n Number) Scale(scaleFactor float64) *Number {
-	return NumberFromFloat(n.AsFloat()*scaleFactor, n.precision)
+	scaled := new(big.Rat).Mul(n.AsRat(), ratFromFloat(scaleFactor))
+	return numberFromRat(scaled, n.precision, RoundUp)
 }
 
 // EqualsPrecisionNormalized returns true if the two numbers are the same after comparing them at the same (lowest) precision level
@@ -122,27 +161,36 @@ func (n Number) String() string {
 
 // NumberFromFloat makes a Number from a float by rounding up
 func NumberFromFloat(f float64, precision int8) *Number {
-	return &Number{
-		value:     toFixed(f, precision, RoundUp),
-		precision: precision,
-	}
+	return NumberFromFloatWithRounding(f, precision, RoundUp)
 }
 
 // NumberFromFloatRoundTruncate makes a Number from a float by truncating beyond the specified precision
 func NumberFromFloatRoundTruncate(f float64, precision int8) *Number {
-	return &Number{
-		value:     toFixed(f, precision, RoundTruncate),
-		precision: precision,
-	}
+	return NumberFromFloatWithRounding(f, precision, RoundTruncate)
 }
 
-// NumberFromString makes a Number from a string, by calling NumberFromFloat
+// NumberFromFloatWithRounding makes a Number from a float by rounding beyond the specified precision. The float is read as the shortest
+// decimal that converts back to the same float (i.e. 0.1 and not 0.1000000000000000055511151231257827), panics if the float is NaN or infinite
+func NumberFromFloatWithRounding(f float64, precision int8, rounding Rounding) *Number {
+	return numberFromRat(ratFromFloat(f), precision, rounding)
+}
+
+// NumberFromString makes a Number from a string by rounding up, the string is read exactly without converting it to a float first
 func NumberFromString(s string, precision int8) (*Number, error) {
 	parsed, e := strconv.ParseFloat(s, 64)
 	if e != nil {
 		return nil, e
 	}
-	return NumberFromFloat(parsed, precision), nil
+	if math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return nil, fmt.Errorf("cannot make a Number from the non-finite value '%s'", s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		// strconv accepts a few formats that big.Rat does not, such as hex floats
+		r = ratFromFloat(parsed)
+	}
+	return numberFromRat(r, precision, RoundUp), nil
 }
 
 // MustNumberFromString panics when there's an error
@@ -161,35 +209,27 @@ func InvertNumber(n *Number) *Number {
 	}
 
 	// return 0 for the inverse of 0 to keep it safe
-	if n.AsFloat() == 0 {
+	if n.Sign() == 0 {
 		log.Printf("trying to invert the number 0, returning the same number to keep it safe")
 		return n
 	}
 
-	bigNum := big.NewRat(1, 1)
-	bigNum = bigNum.SetFloat64(n.AsFloat())
-	bigInv := bigNum.Inv(bigNum)
-
-	bigInvFloat64, _ := bigInv.Float64()
-	return NumberFromFloat(bigInvFloat64, InvertPrecision)
+	inverse := new(big.Rat).Inv(n.AsRat())
+	return numberFromRat(inverse, InvertPrecision, RoundUp)
 }
 
 // NumberByCappingPrecision returns a number with a precision that is at max the passed in precision
 func NumberByCappingPrecision(n *Number, precision int8) *Number {
-	if n.Precision() > precision {
-		return NumberFromFloat(n.AsFloat(), precision)
-	}
-	return n
+	return NumberByCappingPrecisionWithRounding(n, precision, RoundUp)
 }
 
-func round(num float64, rounding Rounding) int64 {
-	if rounding == RoundUp {
-		return int64(num + math.Copysign(0.5, num))
-	} else if rounding == RoundTruncate {
-		return int64(num)
-	} else {
-		panic(fmt.Sprintf("unknown rounding type %v", rounding))
+// NumberByCappingPrecisionWithRounding returns a number with a precision that is at max the passed in precision, using the rounding to
+// drop the extra digits
+func NumberByCappingPrecisionWithRounding(n *Number, precision int8, rounding Rounding) *Number {
+	if n.Precision() > precision {
+		return numberFromRat(n.AsRat(), precision, rounding)
 	}
+	return n
 }
 
 // Rounding is a type that defines various approaching to rounding numbers
@@ -197,31 +237,115 @@ type Rounding int
 
 // Rounding types
 const (
+	// RoundUp rounds half away from zero (1.15 -> 1.2, -1.15 -> -1.2, 1.14 -> 1.1)
 	RoundUp Rounding = iota
+	// RoundTruncate rounds towards zero (1.19 -> 1.1, -1.19 -> -1.1)
 	RoundTruncate
+	// RoundFloor rounds towards negative infinity (1.19 -> 1.1, -1.11 -> -1.2), use it for amounts we sell so we never sell more than we have
+	RoundFloor
+	// RoundCeil rounds towards positive infinity (1.11 -> 1.2, -1.19 -> -1.1), use it for the cost of a buy so we never reserve less than we need
+	RoundCeil
 )
 
+// String is the Stringer method
+func (r Rounding) String() string {
+	switch r {
+	case RoundUp:
+		return "RoundUp"
+	case RoundTruncate:
+		return "RoundTruncate"
+	case RoundFloor:
+		return "RoundFloor"
+	case RoundCeil:
+		return "RoundCeil"
+	}
+	return fmt.Sprintf("Rounding(%d)", int(r))
+}
+
 func toFixed(num float64, precision int8, rounding Rounding) float64 {
-	bigNum := big.NewRat(1, 1)
-	bigNum = bigNum.SetFloat64(num)
-	bigPow := big.NewRat(1, 1)
-	bigPow = bigPow.SetFloat64(math.Pow(10, float64(precision)))
-
-	// multiply
-	bigMultiply := bigNum.Mul(bigNum, bigPow)
-
-	// convert to int after rounding
-	bigMultiplyFloat64, _ := bigMultiply.Float64()
-	roundedInt64 := round(bigMultiplyFloat64, rounding)
-	bigMultiplyIntFloat64 := big.NewRat(1, 1)
-	bigMultiplyIntFloat64 = bigMultiplyIntFloat64.SetInt64(roundedInt64)
-
-	// divide it
-	bigPowInverse := bigPow.Inv(bigPow)
-	bigResult := bigMultiply.Mul(bigMultiplyIntFloat64, bigPowInverse)
-
-	br, _ := bigResult.Float64()
-	return br
+	return NumberFromFloatWithRounding(num, precision, rounding).AsFloat()
+}
+
+// ratFromFloat converts a float to the shortest decimal that converts back to the same float
+func ratFromFloat(f float64) *big.Rat {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic(fmt.Sprintf("cannot make a Number from the non-finite float %f", f))
+	}
+
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	if !ok {
+		panic(fmt.Sprintf("could not convert float %g to a big.Rat", f))
+	}
+	return r
+}
+
+// numberFromRat rounds the exact value r to the precision
+func numberFromRat(r *big.Rat, precision int8, rounding Rounding) *Number {
+	scaled := new(big.Rat).Mul(r, pow10Rat(precision))
+	num, den := scaled.Num(), scaled.Denom()
+
+	// Quo truncates towards zero and the remainder has the sign of num
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if remainder.Sign() != 0 {
+		switch rounding {
+		case RoundUp:
+			twiceRemainder := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1)
+			if twiceRemainder.Cmp(den) >= 0 {
+				quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+			}
+		case RoundTruncate:
+			// quotient is already truncated
+		case RoundFloor:
+			if num.Sign() < 0 {
+				quotient.Sub(quotient, big.NewInt(1))
+			}
+		case RoundCeil:
+			if num.Sign() > 0 {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		default:
+			panic(fmt.Sprintf("unknown rounding type %v", rounding))
+		}
+	}
+
+	value, _ := new(big.Rat).Quo(new(big.Rat).SetInt(quotient), pow10Rat(precision)).Float64()
+	return &Number{
+		decimal:   formatScaled(quotient, precision),
+		value:     value,
+		precision: precision,
+	}
+}
+
+// pow10Rat returns 10^exp, exp can be negative
+func pow10Rat(exp int8) *big.Rat {
+	abs := int64(exp)
+	if abs < 0 {
+		abs = -abs
+	}
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(abs), nil)
+	if exp < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+// formatScaled formats the decimal value scaled/10^precision with precision digits after the decimal point
+func formatScaled(scaled *big.Int, precision int8) string {
+	if precision <= 0 {
+		return new(big.Rat).Quo(new(big.Rat).SetInt(scaled), pow10Rat(precision)).FloatString(0)
+	}
+
+	digits := new(big.Int).Abs(scaled).String()
+	p := int(precision)
+	if len(digits) <= p {
+		digits = strings.Repeat("0", p-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if scaled.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + digits[:len(digits)-p] + "." + digits[len(digits)-p:]
 }
 
 func minPrecision(n1 Number, n2 Number) int8 {
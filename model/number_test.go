@@ -459,3 +459,113 @@ func TestAsRatio(t *testing.T) {
 		})
 	}
 }
+
+func TestNumberRoundingModes(t *testing.T) {
+	testCases := []struct {
+		f         float64
+		precision int8
+		want      map[Rounding]string
+	}{
+		{
+			f:         1.15,
+			precision: 1,
+			want:      map[Rounding]string{RoundUp: "1.2", RoundTruncate: "1.1", RoundFloor: "1.1", RoundCeil: "1.2"},
+		}, {
+			f:         -1.15,
+			precision: 1,
+			want:      map[Rounding]string{RoundUp: "-1.2", RoundTruncate: "-1.1", RoundFloor: "-1.2", RoundCeil: "-1.1"},
+		}, {
+			f:         1.11,
+			precision: 1,
+			want:      map[Rounding]string{RoundUp: "1.1", RoundTruncate: "1.1", RoundFloor: "1.1", RoundCeil: "1.2"},
+		}, {
+			f:         2.0,
+			precision: 1,
+			want:      map[Rounding]string{RoundUp: "2.0", RoundTruncate: "2.0", RoundFloor: "2.0", RoundCeil: "2.0"},
+		}, {
+			f:         -0.04,
+			precision: 1,
+			want:      map[Rounding]string{RoundUp: "0.0", RoundTruncate: "0.0", RoundFloor: "-0.1", RoundCeil: "0.0"},
+		}, {
+			f:         0.00000015,
+			precision: 7,
+			want:      map[Rounding]string{RoundUp: "0.0000002", RoundTruncate: "0.0000001", RoundFloor: "0.0000001", RoundCeil: "0.0000002"},
+		}, {
+			f:         1234.5,
+			precision: 0,
+			want:      map[Rounding]string{RoundUp: "1235", RoundTruncate: "1234", RoundFloor: "1234", RoundCeil: "1235"},
+		},
+	}
+
+	for _, kase := range testCases {
+		for rounding, wantString := range kase.want {
+			t.Run(fmt.Sprintf("%v_%d_%s", kase.f, kase.precision, rounding), func(t *testing.T) {
+				n := NumberFromFloatWithRounding(kase.f, kase.precision, rounding)
+				assert.Equal(t, wantString, n.AsString())
+				assert.Equal(t, kase.precision, n.Precision())
+			})
+		}
+	}
+}
+
+func TestNumberExactArithmetic(t *testing.T) {
+	// 0.1 + 0.2 is 0.30000000000000004 with float64 math
+	sum := NumberFromFloat(0.1, 16).Add(*NumberFromFloat(0.2, 16))
+	assert.Equal(t, "0.3000000000000000", sum.AsString())
+	assert.Equal(t, 0.3, sum.AsFloat())
+
+	// stroop-level amounts on SDEX: 3.14 * 0.131 is 0.41134 exactly, truncating the float64 product gives 0.41133
+	product := NumberFromFloat(3.14, 5).MultiplyRoundTruncate(*NumberFromFloat(0.131, 5))
+	assert.Equal(t, "0.41134", product.AsString())
+
+	// small-cap prices keep their digits when multiplied with large volumes
+	cost := MustNumberFromString("0.0000123", 7).MultiplyWithRounding(*MustNumberFromString("987654.3210000", 7), RoundCeil)
+	assert.Equal(t, "12.1481482", cost.AsString())
+	sold := MustNumberFromString("12.1481482", 7).DivideWithRounding(*MustNumberFromString("0.0000123", 7), RoundFloor)
+	assert.Equal(t, "987654.3252032", sold.AsString())
+
+	// numbers with the same value and precision are equal regardless of how they were made
+	assert.Equal(t, NumberFromFloat(1.0, 2), MustNumberFromString("1", 2))
+	assert.Equal(t, *NumberFromFloat(0.0, 3), *NumberFromFloat(-0.0001, 3))
+}
+
+func TestNumberCmp(t *testing.T) {
+	assert.Equal(t, 0, NumberFromFloat(1.1, 1).Cmp(*NumberFromFloat(1.10, 5)))
+	assert.Equal(t, -1, NumberFromFloat(1.1, 1).Cmp(*NumberFromFloat(1.10001, 5)))
+	assert.Equal(t, 1, NumberFromFloat(-1.0, 1).Cmp(*NumberFromFloat(-1.00001, 5)))
+	assert.Equal(t, 0, NumberConstants.Zero.Sign())
+	assert.Equal(t, -1, NumberFromFloat(-0.5, 1).Sign())
+	assert.Equal(t, 0, Number{}.Sign())
+	assert.Equal(t, "0", Number{}.AsString())
+}
+
+func TestNumberFromStringExact(t *testing.T) {
+	testCases := []struct {
+		s          string
+		precision  int8
+		wantString string
+		wantErr    bool
+	}{
+		{s: "0.1", precision: 7, wantString: "0.1000000"},
+		{s: "123456789.1234567", precision: 7, wantString: "123456789.1234567"},
+		{s: "0.00000005", precision: 7, wantString: "0.0000001"},
+		{s: "-2.5", precision: 0, wantString: "-3"},
+		{s: "1e-3", precision: 4, wantString: "0.0010"},
+		{s: "abc", precision: 2, wantErr: true},
+		{s: "NaN", precision: 2, wantErr: true},
+		{s: "+Inf", precision: 2, wantErr: true},
+	}
+
+	for _, kase := range testCases {
+		t.Run(kase.s, func(t *testing.T) {
+			n, e := NumberFromString(kase.s, kase.precision)
+			if kase.wantErr {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) {
+				assert.Equal(t, kase.wantString, n.AsString())
+			}
+		})
+	}
+}
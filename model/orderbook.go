@@ -99,12 +99,13 @@ func OrderTypeFromString(s string) OrderType {
 
 // Order represents an order in the orderbook
 type Order struct {
-	Pair        *TradingPair
-	OrderAction OrderAction
-	OrderType   OrderType
-	Price       *Number
-	Volume      *Number
-	Timestamp   *Timestamp
+	Pair          *TradingPair
+	OrderAction   OrderAction
+	OrderType     OrderType
+	Price         *Number
+	Volume        *Number
+	Timestamp     *Timestamp
+	ClientOrderID string // empty when unknown or not supported by the exchange
 }
 
 // String is the stringer function
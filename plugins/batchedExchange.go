@@ -1,12 +1,14 @@
 package plugins
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/stellar/go/build"
@@ -28,8 +30,11 @@ type BatchedExchange struct {
 	baseAsset       hProtocol.Asset
 	quoteAsset      hProtocol.Asset
 	tradingAccount  string
+	botID           string // tells apart the client order IDs of bots that share the trading account
 	orderID2OfferID map[string]int64
 	offerID2OrderID map[int64]string
+	// client order IDs are lost when converting to offers so we keep track of them here
+	orderID2ClientOrderID map[string]string
 }
 
 var _ api.ExchangeShim = BatchedExchange{}
@@ -41,16 +46,19 @@ func MakeBatchedExchange(
 	baseAsset hProtocol.Asset,
 	quoteAsset hProtocol.Asset,
 	tradingAccount string,
+	botID string,
 ) *BatchedExchange {
 	return &BatchedExchange{
-		commands:        []Command{},
-		inner:           inner,
-		simMode:         simMode,
-		baseAsset:       baseAsset,
-		quoteAsset:      quoteAsset,
-		tradingAccount:  tradingAccount,
-		orderID2OfferID: map[string]int64{},
-		offerID2OrderID: map[int64]string{},
+		commands:              []Command{},
+		inner:                 inner,
+		simMode:               simMode,
+		baseAsset:             baseAsset,
+		quoteAsset:            quoteAsset,
+		tradingAccount:        tradingAccount,
+		botID:                 botID,
+		orderID2OfferID:       map[string]int64{},
+		offerID2OrderID:       map[int64]string{},
+		orderID2ClientOrderID: map[string]string{},
 	}
 }
 
@@ -190,6 +198,7 @@ func (b BatchedExchange) SubmitOps(opsOld []build.TransactionMutator, submitMode
 		}
 		return fmt.Errorf("could not convert ops2commands: %s | allOps = %v", e, ops)
 	}
+	b.assignClientOrderIDs(b.commands)
 
 	if b.simMode {
 		log.Printf("running in simulation mode so not submitting to the inner exchange\n")
@@ -227,6 +236,48 @@ func (b BatchedExchange) SubmitOps(opsOld []build.TransactionMutator, submitMode
 	return nil
 }
 
+// assignClientOrderIDs sets a client order ID on every add command so the inner exchange can place orders idempotently
+func (b BatchedExchange) assignClientOrderIDs(commands []Command) {
+	for i, c := range commands {
+		if c.op != OpAdd || c.add.ClientOrderID != "" {
+			continue
+		}
+		c.add.ClientOrderID = makeClientOrderID(b.botID, c.add, i)
+	}
+}
+
+// makeClientOrderIDPrefix derives the first group of the client order IDs of the bot's orders from the bot and the pair, so the bot can tell
+// its orders apart from the orders of other bots trading on the same account (or placed by hand)
+func makeClientOrderIDPrefix(botID string, pair *model.TradingPair) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%s", botID, pair)))
+	return fmt.Sprintf("%x", h[0:4])
+}
+
+// hasClientOrderIDPrefix returns true when the client order ID was made with the prefix
+func hasClientOrderIDPrefix(clientOrderID string, prefix string) bool {
+	return strings.HasPrefix(clientOrderID, prefix+"-")
+}
+
+// makeClientOrderID deterministically derives a client order ID from the order and its position in the batch, formatted as a UUID since
+// that is the most restrictive format required by exchanges (max 36 chars, only hex digits and dashes). The first group is the prefix of
+// the bot (see makeClientOrderIDPrefix)
+func makeClientOrderID(botID string, order *model.Order, index int) string {
+	tsString := ""
+	if order.Timestamp != nil {
+		tsString = fmt.Sprintf("%d", order.Timestamp.AsInt64())
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%s|%d",
+		botID,
+		order.Pair,
+		order.OrderAction,
+		order.Price.AsString(),
+		order.Volume.AsString(),
+		tsString,
+		index,
+	)))
+	return fmt.Sprintf("%s-%x-%x-%x-%x", makeClientOrderIDPrefix(botID, order.Pair), h[0:2], h[2:4], h[4:6], h[6:12])
+}
+
 func (b BatchedExchange) logResults(results []submitResult) {
 	log.Printf("Results from submitting:\n")
 	for _, r := range results {
@@ -324,6 +375,10 @@ func (b BatchedExchange) OpenOrders2Offers(orders []model.OpenOrder, baseAsset h
 			priceString = invertedPrice.AsString()
 		}
 
+		if order.ClientOrderID != "" {
+			b.orderID2ClientOrderID[order.ID] = order.ClientOrderID
+		}
+
 		// generate an offerID for the non-numerical orderID (hoops we have to jump through because of the hacked approach to using centralized exchanges)
 		var ID int64
 		if v, ok := b.orderID2OfferID[order.ID]; ok {
@@ -354,6 +409,15 @@ func (b BatchedExchange) OpenOrders2Offers(orders []model.OpenOrder, baseAsset h
 	return offers, nil
 }
 
+// LookupOrderID returns the orderID and clientOrderID (if known) on the inner exchange for an offerID returned by LoadOffersHack
+func (b BatchedExchange) LookupOrderID(offerID int64) (string /*orderID*/, string /*clientOrderID*/, bool) {
+	orderID, ok := b.offerID2OrderID[offerID]
+	if !ok {
+		return "", "", false
+	}
+	return orderID, b.orderID2ClientOrderID[orderID], true
+}
+
 func convert2Price(number *model.Number) (hProtocol.Price, error) {
 	n, d, e := number.AsRatio()
 	if e != nil {
@@ -385,14 +449,19 @@ func manageOffer2Order(mob *txnbuild.ManageSellOffer, baseAsset hProtocol.Asset,
 	if e != nil {
 		return nil, fmt.Errorf("could not compare assets, error: %s", e)
 	}
+	// floor the volume of sells so we never sell more than the offer
+	volumeRounding := model.RoundFloor
 	if isBuy {
 		orderAction = model.OrderActionBuy
 		// TODO need to test price and volume conversions correctly
 		// volume calculation needs to happen first since it uses the non-inverted price when multiplying
-		volume = model.NumberFromFloat(volume.AsFloat()*price.AsFloat(), orderConstraints.VolumePrecision)
+		volume = volume.Multiply(*price)
 		price = model.InvertNumber(price)
+		// the volume of a buy is derived from the offer price which SDEX limits to 7 decimals, so round it to the nearest unit instead
+		// of dropping a whole unit at the tick-size edge
+		volumeRounding = model.RoundUp
 	}
-	volume = model.NumberByCappingPrecision(volume, orderConstraints.VolumePrecision)
+	volume = model.NumberByCappingPrecisionWithRounding(volume, orderConstraints.VolumePrecision, volumeRounding)
 	price = model.NumberByCappingPrecision(price, orderConstraints.PricePrecision)
 
 	return &model.Order{
@@ -48,3 +48,50 @@ func TestManageOffer2Order(t *testing.T) {
 		assert.Equal(t, k.wantAmount, order.Volume.AsFloat())
 	}
 }
+
+func TestMakeClientOrderID(t *testing.T) {
+	order := &model.Order{
+		Pair:        &model.TradingPair{Base: model.XLM, Quote: model.USDT},
+		OrderAction: model.OrderActionSell,
+		OrderType:   model.OrderTypeLimit,
+		Price:       model.NumberFromFloat(0.1, 5),
+		Volume:      model.NumberFromFloat(100.0, 5),
+		Timestamp:   model.MakeTimestamp(1584198000000),
+	}
+
+	id := makeClientOrderID("bot1", order, 0)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$", id)
+	// starts with the prefix of the bot
+	assert.True(t, hasClientOrderIDPrefix(id, makeClientOrderIDPrefix("bot1", order.Pair)))
+	assert.False(t, hasClientOrderIDPrefix(id, makeClientOrderIDPrefix("bot2", order.Pair)))
+	// deterministic
+	assert.Equal(t, id, makeClientOrderID("bot1", order, 0))
+	// identical orders in the same batch get different IDs
+	assert.NotEqual(t, id, makeClientOrderID("bot1", order, 1))
+	// different bots get different IDs
+	assert.NotEqual(t, id, makeClientOrderID("bot2", order, 0))
+}
+
+func TestAssignClientOrderIDs(t *testing.T) {
+	order := &model.Order{
+		Pair:        &model.TradingPair{Base: model.XLM, Quote: model.USDT},
+		OrderAction: model.OrderActionBuy,
+		OrderType:   model.OrderTypeLimit,
+		Price:       model.NumberFromFloat(0.1, 5),
+		Volume:      model.NumberFromFloat(100.0, 5),
+	}
+	preassigned := *order
+	preassigned.ClientOrderID = "existing"
+	commands := []Command{
+		MakeCommandCancel(&model.OpenOrder{Order: *order, ID: "1"}),
+		MakeCommandAdd(order),
+		MakeCommandAdd(&preassigned),
+	}
+
+	b := MakeBatchedExchange(nil, false, utils.Asset2Asset2(testBaseAsset), utils.Asset2Asset2(testQuoteAsset), "account", "bot1")
+	b.assignClientOrderIDs(commands)
+
+	assert.Equal(t, "", commands[0].cancel.ClientOrderID)
+	assert.Equal(t, makeClientOrderID("bot1", order, 1), commands[1].add.ClientOrderID)
+	assert.Equal(t, "existing", commands[2].add.ClientOrderID)
+}
@@ -0,0 +1,950 @@
+package plugins
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+const binanceDefaultBaseURL = "https://api.binance.com"
+
+// binanceBaseURLParam is the exchange param that overrides the base URL of the REST API, such as the testnet or a local stub
+const binanceBaseURLParam = "base_url"
+const binanceRecvWindowMillis = 5000
+const binanceHTTPTimeout = 30 * time.Second
+
+// binanceRateLimitMillis keeps us well under the default request weight limit of 1200 per minute
+const binanceRateLimitMillis = 100
+
+// binanceTradesLimit is the max number of trades returned by a single request to the trades endpoints
+const binanceTradesLimit = 1000
+
+// binanceTradesWindowMillis is the time range after startTime that is covered by a request to the myTrades endpoint without an endTime
+const binanceTradesWindowMillis = int64(24 * time.Hour / time.Millisecond)
+
+// binanceAmountPrecision is the precision used by binance for balances, costs, and fees
+const binanceAmountPrecision = 8
+
+// error codes returned by binance when canceling and querying orders that do not exist
+const binanceErrorCodeUnknownOrder = -2011
+const binanceErrorCodeNoSuchOrder = -2013
+
+// binanceSymbol is a market listed in binance's exchangeInfo
+type binanceSymbol struct {
+	symbol      string
+	constraints model.OrderConstraints
+}
+
+// binanceExchange is a native integration with the binance REST API that does not need ccxt-rest
+type binanceExchange struct {
+	baseURL            string
+	apiKeys            []api.ExchangeAPIKey
+	apiNextIndex       uint8
+	httpClient         *http.Client
+	assetConverter     model.AssetConverterInterface
+	delimiter          string
+	ocOverridesHandler *OrderConstraintsOverridesHandler
+	depthLimits        *ccxtExchangeSpecificParamFactoryBinance // binance only accepts specific orderbook depths
+	clientOrderIDs     *clientOrderIDMap
+	isSimulated        bool // will simulate add and cancel orders if this is true
+	nowFn              func() time.Time
+
+	// uninitialized
+	symbols map[model.TradingPair]*binanceSymbol // loaded from exchangeInfo when the exchange is made
+}
+
+// ensure that binanceExchange conforms to the Exchange interface
+var _ api.Exchange = &binanceExchange{}
+
+// ensure that binanceExchange reports its rate limit
+var _ rateLimitMetadataProvider = &binanceExchange{}
+
+// makeBinanceExchange is a factory method to make the binance exchange
+func makeBinanceExchange(apiKeys []api.ExchangeAPIKey, exchangeParams []api.ExchangeParam, isSimulated bool) (api.Exchange, error) {
+	if len(apiKeys) == 0 || len(apiKeys) > math.MaxUint8 {
+		return nil, fmt.Errorf("invalid number of apiKeys: %d", len(apiKeys))
+	}
+
+	baseURL := binanceDefaultBaseURL
+	for _, p := range exchangeParams {
+		if p.Param != binanceBaseURLParam {
+			continue
+		}
+		v, ok := p.Value.(string)
+		if !ok || v == "" {
+			return nil, fmt.Errorf("the value of the '%s' exchange param needs to be a URL", binanceBaseURLParam)
+		}
+		baseURL = v
+	}
+
+	b := &binanceExchange{
+		baseURL:            strings.TrimSuffix(baseURL, "/"),
+		apiKeys:            apiKeys,
+		apiNextIndex:       0,
+		httpClient:         &http.Client{Timeout: binanceHTTPTimeout},
+		assetConverter:     model.Display,
+		delimiter:          "",
+		ocOverridesHandler: MakeEmptyOrderConstraintsOverridesHandler(),
+		depthLimits:        makeCcxtExchangeSpecificParamFactoryBinance(),
+		clientOrderIDs:     makeClientOrderIDMap(),
+		isSimulated:        isSimulated,
+		nowFn:              time.Now,
+	}
+
+	symbols, e := b.fetchSymbols()
+	if e != nil {
+		return nil, fmt.Errorf("error loading the symbols of binance: %s", e)
+	}
+	b.symbols = symbols
+	return b, nil
+}
+
+// nextAPIKey rotates the API key being used so we can overcome rate limit issues
+func (b *binanceExchange) nextAPIKey() api.ExchangeAPIKey {
+	apiKey := b.apiKeys[b.apiNextIndex]
+	// rotate key for the next call
+	b.apiNextIndex = (b.apiNextIndex + 1) % uint8(len(b.apiKeys))
+	return apiKey
+}
+
+func (b *binanceExchange) getRateLimitMillis() int64 {
+	return binanceRateLimitMillis
+}
+
+// binanceError is the body of an error response from binance
+type binanceError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// request makes a request to the binance REST API, signed requests include the timestamp, the API key, and an HMAC signature of the params
+func (b *binanceExchange) request(method string, path string, params url.Values, signed bool, responseData interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	headers := map[string]string{}
+	var query string
+	if signed {
+		apiKey := b.nextAPIKey()
+		if apiKey.Key == "" || apiKey.Secret == "" {
+			return fmt.Errorf("binance needs an API key and secret for %s %s", method, path)
+		}
+		params.Set("timestamp", strconv.FormatInt(b.nowFn().UnixNano()/int64(time.Millisecond), 10))
+		params.Set("recvWindow", strconv.Itoa(binanceRecvWindowMillis))
+		query = params.Encode()
+		// the signature is over the query string exactly as it is sent, so it needs to be the last param
+		query += "&signature=" + signBinanceQuery(apiKey.Secret, query)
+		headers["X-MBX-APIKEY"] = apiKey.Key
+	} else {
+		query = params.Encode()
+	}
+
+	reqURL := b.baseURL + path
+	if query != "" {
+		reqURL += "?" + query
+	}
+	req, e := http.NewRequest(method, reqURL, nil)
+	if e != nil {
+		return fmt.Errorf("could not create http request: %s", e)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, e := b.httpClient.Do(req)
+	if e != nil {
+		return fmt.Errorf("could not execute http request: %s", e)
+	}
+	defer resp.Body.Close()
+
+	body, e := ioutil.ReadAll(resp.Body)
+	if e != nil {
+		return fmt.Errorf("could not read http response: %s", e)
+	}
+
+	if resp.StatusCode >= 400 {
+		var be binanceError
+		if json.Unmarshal(body, &be) == nil && be.Msg != "" {
+			return makeBinanceAPIError(resp.StatusCode, be)
+		}
+		return fmt.Errorf("binance error (httpStatus=%d %s): %s", resp.StatusCode, http.StatusText(resp.StatusCode), string(body))
+	}
+
+	if responseData != nil {
+		e = json.Unmarshal(body, responseData)
+		if e != nil {
+			return fmt.Errorf("could not unmarshall response body into json: %s | response body: %s", e, string(body))
+		}
+	}
+	return nil
+}
+
+// signBinanceQuery returns the hex encoded HMAC-SHA256 signature of the query string
+func signBinanceQuery(secret string, query string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// binanceAPIError is an error returned by the binance API with an error code
+type binanceAPIError struct {
+	httpStatus int
+	code       int
+	msg        string
+}
+
+func makeBinanceAPIError(httpStatus int, be binanceError) *binanceAPIError {
+	return &binanceAPIError{
+		httpStatus: httpStatus,
+		code:       be.Code,
+		msg:        be.Msg,
+	}
+}
+
+// Error impl, the http status text is included so rate limit and transient errors are recognized by the RateLimitedExchange
+func (e *binanceAPIError) Error() string {
+	return fmt.Sprintf("binance error (httpStatus=%d %s, code=%d): %s", e.httpStatus, http.StatusText(e.httpStatus), e.code, e.msg)
+}
+
+// fetchSymbols fetches the markets and their order constraints from exchangeInfo
+func (b *binanceExchange) fetchSymbols() (map[model.TradingPair]*binanceSymbol, error) {
+	var info struct {
+		Symbols []struct {
+			Symbol     string                   `json:"symbol"`
+			BaseAsset  string                   `json:"baseAsset"`
+			QuoteAsset string                   `json:"quoteAsset"`
+			Filters    []map[string]interface{} `json:"filters"`
+		} `json:"symbols"`
+	}
+	e := b.request("GET", "/api/v3/exchangeInfo", nil, false, &info)
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch exchangeInfo from binance: %s", e)
+	}
+
+	symbols := map[model.TradingPair]*binanceSymbol{}
+	for _, s := range info.Symbols {
+		filters := map[string]map[string]interface{}{}
+		for _, f := range s.Filters {
+			if filterType, ok := f["filterType"].(string); ok {
+				filters[filterType] = f
+			}
+		}
+
+		oc, e := makeBinanceOrderConstraints(filters)
+		if e != nil {
+			return nil, fmt.Errorf("could not read order constraints for symbol %s: %s", s.Symbol, e)
+		}
+		pair := model.TradingPair{
+			Base:  b.assetConverter.MustFromString(s.BaseAsset),
+			Quote: b.assetConverter.MustFromString(s.QuoteAsset),
+		}
+		symbols[pair] = &binanceSymbol{
+			symbol:      s.Symbol,
+			constraints: *oc,
+		}
+	}
+	log.Printf("loaded %d symbols from binance exchangeInfo\n", len(symbols))
+	return symbols, nil
+}
+
+// makeBinanceOrderConstraints reads the precision from the tick and step sizes and the minimums from the filters of a symbol
+func makeBinanceOrderConstraints(filters map[string]map[string]interface{}) (*model.OrderConstraints, error) {
+	filterValue := func(filterType string, field string) (string, error) {
+		f, ok := filters[filterType]
+		if !ok {
+			return "", fmt.Errorf("missing filter %s", filterType)
+		}
+		v, ok := f[field].(string)
+		if !ok {
+			return "", fmt.Errorf("missing field %s in filter %s", field, filterType)
+		}
+		return v, nil
+	}
+
+	tickSize, e := filterValue("PRICE_FILTER", "tickSize")
+	if e != nil {
+		return nil, e
+	}
+	stepSize, e := filterValue("LOT_SIZE", "stepSize")
+	if e != nil {
+		return nil, e
+	}
+	minQty, e := filterValue("LOT_SIZE", "minQty")
+	if e != nil {
+		return nil, e
+	}
+
+	pricePrecision, e := binanceStepPrecision(tickSize)
+	if e != nil {
+		return nil, fmt.Errorf("invalid tickSize: %s", e)
+	}
+	volumePrecision, e := binanceStepPrecision(stepSize)
+	if e != nil {
+		return nil, fmt.Errorf("invalid stepSize: %s", e)
+	}
+	minBaseVolume, e := model.NumberFromString(minQty, volumePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("invalid minQty: %s", e)
+	}
+
+	oc := &model.OrderConstraints{
+		PricePrecision:  pricePrecision,
+		VolumePrecision: volumePrecision,
+		MinBaseVolume:   *minBaseVolume,
+	}
+	// older symbols use MIN_NOTIONAL and newer ones use NOTIONAL
+	for _, filterType := range []string{"MIN_NOTIONAL", "NOTIONAL"} {
+		minNotional, e := filterValue(filterType, "minNotional")
+		if e != nil {
+			continue
+		}
+		oc.MinQuoteVolume, e = model.NumberFromString(minNotional, binanceAmountPrecision)
+		if e != nil {
+			return nil, fmt.Errorf("invalid minNotional: %s", e)
+		}
+		break
+	}
+	return oc, nil
+}
+
+// binanceStepPrecision converts a step such as "0.00100000" to the number of decimal places it allows (3)
+func binanceStepPrecision(step string) (int8, error) {
+	f, e := strconv.ParseFloat(step, 64)
+	if e != nil {
+		return 0, e
+	}
+	if f <= 0 {
+		return 0, fmt.Errorf("step needs to be positive, was %s", step)
+	}
+
+	parts := strings.SplitN(step, ".", 2)
+	if len(parts) == 1 {
+		return 0, nil
+	}
+	return int8(len(strings.TrimRight(parts[1], "0"))), nil
+}
+
+// getSymbol returns the binance symbol for the pair, such as "XLMBTC"
+func (b *binanceExchange) getSymbol(pair *model.TradingPair) (string, error) {
+	s, ok := b.symbols[*pair]
+	if !ok {
+		return "", fmt.Errorf("trading pair %s is not listed on binance", pair)
+	}
+	return s.symbol, nil
+}
+
+// GetOrderConstraints impl
+func (b *binanceExchange) GetOrderConstraints(pair *model.TradingPair) *model.OrderConstraints {
+	if s, ok := b.symbols[*pair]; ok {
+		oc := s.constraints
+		return b.ocOverridesHandler.Apply(pair, &oc)
+	}
+
+	if b.ocOverridesHandler.IsCompletelyOverriden(pair) {
+		override := b.ocOverridesHandler.Get(pair)
+		return model.MakeOrderConstraintsFromOverride(override)
+	}
+	panic(fmt.Sprintf("binanceExchange could not find orderConstraints for trading pair %v. Try using the \"ccxt-binance\" integration instead.", pair))
+}
+
+// OverrideOrderConstraints impl, can partially override values for specific pairs
+func (b *binanceExchange) OverrideOrderConstraints(pair *model.TradingPair, override *model.OrderConstraintsOverride) {
+	b.ocOverridesHandler.Upsert(pair, override)
+}
+
+// GetAssetConverter impl.
+func (b *binanceExchange) GetAssetConverter() model.AssetConverterInterface {
+	return b.assetConverter
+}
+
+// GetAccountBalances impl, the balance of an asset includes the amount locked in open orders
+func (b *binanceExchange) GetAccountBalances(assetList []interface{}) (map[interface{}]model.Number, error) {
+	var account struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	e := b.request("GET", "/api/v3/account", nil, true, &account)
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch account from binance: %s", e)
+	}
+
+	balances := map[string]*model.Number{}
+	for _, bal := range account.Balances {
+		free, e := model.NumberFromString(bal.Free, binanceAmountPrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse free balance of %s: %s", bal.Asset, e)
+		}
+		locked, e := model.NumberFromString(bal.Locked, binanceAmountPrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse locked balance of %s: %s", bal.Asset, e)
+		}
+		balances[bal.Asset] = free.Add(*locked)
+	}
+
+	m := map[interface{}]model.Number{}
+	for _, elem := range assetList {
+		var asset model.Asset
+		if v, ok := elem.(model.Asset); ok {
+			asset = v
+		} else {
+			return nil, fmt.Errorf("invalid type of asset passed in, only model.Asset accepted")
+		}
+
+		assetString, e := b.assetConverter.ToString(asset)
+		if e != nil {
+			return nil, e
+		}
+		if bal, ok := balances[assetString]; ok {
+			m[asset] = *bal
+		} else {
+			// binance only lists assets that the account has held
+			m[asset] = *model.NumberFromFloat(0, binanceAmountPrecision)
+		}
+	}
+	return m, nil
+}
+
+// GetTickerPrice impl.
+func (b *binanceExchange) GetTickerPrice(pairs []model.TradingPair) (map[model.TradingPair]api.Ticker, error) {
+	priceResult := map[model.TradingPair]api.Ticker{}
+	for _, p := range pairs {
+		symbol, e := b.getSymbol(&p)
+		if e != nil {
+			return nil, e
+		}
+
+		var ticker struct {
+			BidPrice  string `json:"bidPrice"`
+			AskPrice  string `json:"askPrice"`
+			LastPrice string `json:"lastPrice"`
+		}
+		e = b.request("GET", "/api/v3/ticker/24hr", url.Values{"symbol": {symbol}}, false, &ticker)
+		if e != nil {
+			return nil, fmt.Errorf("could not fetch ticker for %s from binance: %s", symbol, e)
+		}
+
+		pricePrecision := b.GetOrderConstraints(&p).PricePrecision
+		bid, e := model.NumberFromString(ticker.BidPrice, pricePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse bid price: %s", e)
+		}
+		ask, e := model.NumberFromString(ticker.AskPrice, pricePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse ask price: %s", e)
+		}
+		last, e := model.NumberFromString(ticker.LastPrice, pricePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse last price: %s", e)
+		}
+		priceResult[p] = api.Ticker{
+			AskPrice:  ask,
+			BidPrice:  bid,
+			LastPrice: last,
+		}
+	}
+	return priceResult, nil
+}
+
+// GetOrderBook impl.
+func (b *binanceExchange) GetOrderBook(pair *model.TradingPair, maxCount int32) (*model.OrderBook, error) {
+	symbol, e := b.getSymbol(pair)
+	if e != nil {
+		return nil, e
+	}
+	limit, e := b.depthLimits.transformLimit(int(maxCount))
+	if e != nil {
+		return nil, e
+	}
+
+	var depth struct {
+		Bids [][]string `json:"bids"`
+		Asks [][]string `json:"asks"`
+	}
+	e = b.request("GET", "/api/v3/depth", url.Values{"symbol": {symbol}, "limit": {strconv.Itoa(limit)}}, false, &depth)
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch orderbook for %s from binance: %s", symbol, e)
+	}
+
+	ts := model.MakeTimestampFromTime(b.nowFn())
+	asks, e := b.readOrders(depth.Asks, pair, model.OrderActionSell, int(maxCount), ts)
+	if e != nil {
+		return nil, fmt.Errorf("could not read asks: %s", e)
+	}
+	bids, e := b.readOrders(depth.Bids, pair, model.OrderActionBuy, int(maxCount), ts)
+	if e != nil {
+		return nil, fmt.Errorf("could not read bids: %s", e)
+	}
+	return model.MakeOrderBook(pair, asks, bids), nil
+}
+
+func (b *binanceExchange) readOrders(levels [][]string, pair *model.TradingPair, orderAction model.OrderAction, maxCount int, ts *model.Timestamp) ([]model.Order, error) {
+	orderConstraints := b.GetOrderConstraints(pair)
+	orders := []model.Order{}
+	for _, level := range levels {
+		if len(orders) >= maxCount {
+			break
+		}
+		if len(level) < 2 {
+			return nil, fmt.Errorf("invalid orderbook level: %v", level)
+		}
+
+		price, e := model.NumberFromString(level[0], orderConstraints.PricePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse price: %s", e)
+		}
+		volume, e := model.NumberFromString(level[1], orderConstraints.VolumePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse volume: %s", e)
+		}
+		orders = append(orders, model.Order{
+			Pair:        pair,
+			OrderAction: orderAction,
+			OrderType:   model.OrderTypeLimit,
+			Price:       price,
+			Volume:      volume,
+			Timestamp:   ts,
+		})
+	}
+	return orders, nil
+}
+
+// GetTrades impl, the cursor is the ID of the next aggregate trade to fetch
+func (b *binanceExchange) GetTrades(pair *model.TradingPair, maybeCursor interface{}) (*api.TradesResult, error) {
+	symbol, e := b.getSymbol(pair)
+	if e != nil {
+		return nil, e
+	}
+
+	params := url.Values{"symbol": {symbol}, "limit": {strconv.Itoa(binanceTradesLimit)}}
+	if maybeCursor != nil {
+		fromID, e := parseBinanceCursor(maybeCursor)
+		if e != nil {
+			return nil, e
+		}
+		params.Set("fromId", strconv.FormatInt(fromID, 10))
+	}
+
+	var aggTrades []struct {
+		ID           int64  `json:"a"`
+		Price        string `json:"p"`
+		Quantity     string `json:"q"`
+		Time         int64  `json:"T"`
+		IsBuyerMaker bool   `json:"m"`
+	}
+	e = b.request("GET", "/api/v3/aggTrades", params, false, &aggTrades)
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch trades for %s from binance: %s", symbol, e)
+	}
+
+	orderConstraints := b.GetOrderConstraints(pair)
+	trades := []model.Trade{}
+	for _, t := range aggTrades {
+		price, e := model.NumberFromString(t.Price, orderConstraints.PricePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse price of trade %d: %s", t.ID, e)
+		}
+		volume, e := model.NumberFromString(t.Quantity, orderConstraints.VolumePrecision)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse volume of trade %d: %s", t.ID, e)
+		}
+
+		// the action is from the perspective of the taker
+		action := model.OrderActionBuy
+		if t.IsBuyerMaker {
+			action = model.OrderActionSell
+		}
+		trades = append(trades, model.Trade{
+			Order: model.Order{
+				Pair:        pair,
+				OrderAction: action,
+				OrderType:   model.OrderTypeLimit,
+				Price:       price,
+				Volume:      volume,
+				Timestamp:   model.MakeTimestamp(t.Time),
+			},
+			TransactionID: model.MakeTransactionID(strconv.FormatInt(t.ID, 10)),
+		})
+	}
+	sort.Sort(model.TradesByTsID(trades))
+
+	cursor := maybeCursor
+	if len(aggTrades) > 0 {
+		// add 1 to the last ID so we don't repeat the same trade on the next run
+		cursor = aggTrades[len(aggTrades)-1].ID + 1
+	}
+	return &api.TradesResult{
+		Cursor: cursor,
+		Trades: trades,
+	}, nil
+}
+
+func parseBinanceCursor(cursor interface{}) (int64, error) {
+	switch c := cursor.(type) {
+	case int64:
+		return c, nil
+	case int:
+		return int64(c), nil
+	case string:
+		i, e := strconv.ParseInt(c, 10, 64)
+		if e != nil {
+			return 0, fmt.Errorf("could not parse cursor '%s' as an int64: %s", c, e)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("invalid type of cursor: %v (type=%T)", cursor, cursor)
+}
+
+// binanceMyTrade is an entry in the response of the myTrades endpoint
+type binanceMyTrade struct {
+	ID              int64  `json:"id"`
+	OrderID         int64  `json:"orderId"`
+	Price           string `json:"price"`
+	Quantity        string `json:"qty"`
+	QuoteQuantity   string `json:"quoteQty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            int64  `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+}
+
+// GetTradeHistory impl, cursors are timestamps in millis (the same as the ccxt-binance integration), the start is inclusive and the end is inclusive
+func (b *binanceExchange) GetTradeHistory(pair model.TradingPair, maybeCursorStart interface{}, maybeCursorEnd interface{}) (*api.TradeHistoryResult, error) {
+	symbol, e := b.getSymbol(&pair)
+	if e != nil {
+		return nil, e
+	}
+
+	var maybeEnd *int64
+	if maybeCursorEnd != nil {
+		end, e := parseBinanceCursor(maybeCursorEnd)
+		if e != nil {
+			return nil, e
+		}
+		maybeEnd = &end
+	}
+
+	params := url.Values{"symbol": {symbol}, "limit": {strconv.Itoa(binanceTradesLimit)}}
+	var maybeStart *int64
+	if maybeCursorStart != nil {
+		start, e := parseBinanceCursor(maybeCursorStart)
+		if e != nil {
+			return nil, e
+		}
+		params.Set("startTime", strconv.FormatInt(start, 10))
+		maybeStart = &start
+	}
+
+	orderConstraints := b.GetOrderConstraints(&pair)
+	trades := []model.Trade{}
+	for {
+		var page []binanceMyTrade
+		e = b.request("GET", "/api/v3/myTrades", params, true, &page)
+		if e != nil {
+			return nil, fmt.Errorf("could not fetch trade history for %s from binance: %s", symbol, e)
+		}
+
+		done := len(page) < binanceTradesLimit
+		for _, raw := range page {
+			if maybeEnd != nil && raw.Time > *maybeEnd {
+				done = true
+				break
+			}
+
+			t, e := b.readMyTrade(&pair, orderConstraints, raw)
+			if e != nil {
+				return nil, fmt.Errorf("could not read trade %d: %s", raw.ID, e)
+			}
+			trades = append(trades, *t)
+		}
+		if done {
+			break
+		}
+
+		// fetch the next page by trade ID, which cannot be combined with startTime
+		params.Del("startTime")
+		params.Set("fromId", strconv.FormatInt(page[len(page)-1].ID+1, 10))
+	}
+	sort.Sort(model.TradesByTsID(trades))
+
+	cursor := maybeCursorStart
+	if len(trades) > 0 {
+		// add 1 to the last timestamp so we don't repeat the same trade on the next run
+		cursor = strconv.FormatInt(trades[len(trades)-1].Timestamp.AsInt64()+1, 10)
+	} else if maybeStart != nil {
+		// binance only returns the trades within a day of startTime, so we move the cursor past an empty window otherwise we would never
+		// fetch the trades made after it
+		next := *maybeStart + binanceTradesWindowMillis
+		if nowMillis := b.nowFn().UnixNano() / int64(time.Millisecond); nowMillis < next {
+			next = nowMillis
+		}
+		if maybeEnd != nil && *maybeEnd+1 < next {
+			next = *maybeEnd + 1
+		}
+		if next > *maybeStart {
+			cursor = strconv.FormatInt(next, 10)
+		}
+	}
+	return &api.TradeHistoryResult{
+		Cursor: cursor,
+		Trades: trades,
+	}, nil
+}
+
+func (b *binanceExchange) readMyTrade(pair *model.TradingPair, orderConstraints *model.OrderConstraints, raw binanceMyTrade) (*model.Trade, error) {
+	price, e := model.NumberFromString(raw.Price, orderConstraints.PricePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse price: %s", e)
+	}
+	volume, e := model.NumberFromString(raw.Quantity, orderConstraints.VolumePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse volume: %s", e)
+	}
+	cost, e := model.NumberFromString(raw.QuoteQuantity, binanceAmountPrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse cost: %s", e)
+	}
+	// the fee is in units of the commission asset, which can be BNB instead of an asset of the pair
+	fee, e := model.NumberFromString(raw.Commission, binanceAmountPrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse fee: %s", e)
+	}
+
+	action := model.OrderActionSell
+	if raw.IsBuyer {
+		action = model.OrderActionBuy
+	}
+	orderID := strconv.FormatInt(raw.OrderID, 10)
+	return &model.Trade{
+		Order: model.Order{
+			Pair:          pair,
+			OrderAction:   action,
+			OrderType:     model.OrderTypeLimit,
+			Price:         price,
+			Volume:        volume,
+			Timestamp:     model.MakeTimestamp(raw.Time),
+			ClientOrderID: b.clientOrderIDs.get(orderID),
+		},
+		TransactionID: model.MakeTransactionID(strconv.FormatInt(raw.ID, 10)),
+		OrderID:       orderID,
+		Cost:          cost,
+		Fee:           fee,
+	}, nil
+}
+
+// GetLatestTradeCursor impl.
+func (b *binanceExchange) GetLatestTradeCursor() (interface{}, error) {
+	timeNowMillis := b.nowFn().UnixNano() / int64(time.Millisecond)
+	return strconv.FormatInt(timeNowMillis, 10), nil
+}
+
+// binanceOrder is an order in the responses of the order endpoints
+type binanceOrder struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	Status        string `json:"status"`
+	Type          string `json:"type"`
+	Side          string `json:"side"`
+	Time          int64  `json:"time"`
+}
+
+// GetOpenOrders impl.
+func (b *binanceExchange) GetOpenOrders(pairs []*model.TradingPair) (map[model.TradingPair][]model.OpenOrder, error) {
+	m := map[model.TradingPair][]model.OpenOrder{}
+	for _, pair := range pairs {
+		symbol, e := b.getSymbol(pair)
+		if e != nil {
+			return nil, e
+		}
+
+		var orders []binanceOrder
+		e = b.request("GET", "/api/v3/openOrders", url.Values{"symbol": {symbol}}, true, &orders)
+		if e != nil {
+			return nil, fmt.Errorf("cannot load open orders for %s from binance: %s", symbol, e)
+		}
+
+		orderConstraints := b.GetOrderConstraints(pair)
+		openOrders := []model.OpenOrder{}
+		for _, o := range orders {
+			oo, e := b.readOpenOrder(pair, orderConstraints, o)
+			if e != nil {
+				return nil, fmt.Errorf("could not read open order %d: %s", o.OrderID, e)
+			}
+			openOrders = append(openOrders, *oo)
+		}
+		m[*pair] = openOrders
+	}
+	return m, nil
+}
+
+func (b *binanceExchange) readOpenOrder(pair *model.TradingPair, orderConstraints *model.OrderConstraints, o binanceOrder) (*model.OpenOrder, error) {
+	price, e := model.NumberFromString(o.Price, orderConstraints.PricePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse price: %s", e)
+	}
+	volume, e := model.NumberFromString(o.OrigQty, orderConstraints.VolumePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse volume: %s", e)
+	}
+	executed, e := model.NumberFromString(o.ExecutedQty, orderConstraints.VolumePrecision)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse executed volume: %s", e)
+	}
+
+	orderID := strconv.FormatInt(o.OrderID, 10)
+	b.clientOrderIDs.put(orderID, o.ClientOrderID)
+	ts := model.MakeTimestamp(o.Time)
+	return &model.OpenOrder{
+		Order: model.Order{
+			Pair:          pair,
+			OrderAction:   model.OrderActionFromString(strings.ToLower(o.Side)),
+			OrderType:     model.OrderTypeLimit,
+			Price:         price,
+			Volume:        volume,
+			Timestamp:     ts,
+			ClientOrderID: o.ClientOrderID,
+		},
+		ID:             orderID,
+		StartTime:      ts,
+		VolumeExecuted: executed,
+	}, nil
+}
+
+// AddOrder impl, orders are placed as LIMIT_MAKER orders in maker_only mode so binance rejects them instead of taking liquidity
+func (b *binanceExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*model.TransactionID, error) {
+	symbol, e := b.getSymbol(order.Pair)
+	if e != nil {
+		return nil, e
+	}
+	if order.OrderType != model.OrderTypeLimit {
+		return nil, fmt.Errorf("binance integration only supports limit orders, got %s", order.OrderType)
+	}
+
+	if b.isSimulated {
+		log.Printf("not adding order to Binance in simulation mode, order=%s\n", *order)
+		return model.MakeTransactionID("simulated"), nil
+	}
+
+	orderConstraints := b.GetOrderConstraints(order.Pair)
+	if order.Price.Precision() > orderConstraints.PricePrecision {
+		return nil, fmt.Errorf("binance price precision can be a maximum of %d, got %d, value = %s", orderConstraints.PricePrecision, order.Price.Precision(), order.Price.AsString())
+	}
+	if order.Volume.Precision() > orderConstraints.VolumePrecision {
+		return nil, fmt.Errorf("binance volume precision can be a maximum of %d, got %d, value = %s", orderConstraints.VolumePrecision, order.Volume.Precision(), order.Volume.AsString())
+	}
+
+	params := url.Values{
+		"symbol":           {symbol},
+		"side":             {strings.ToUpper(order.OrderAction.String())},
+		"quantity":         {order.Volume.AsString()},
+		"price":            {order.Price.AsString()},
+		"newOrderRespType": {"ACK"},
+	}
+	if submitMode == api.SubmitModeMakerOnly {
+		params.Set("type", "LIMIT_MAKER")
+	} else {
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", "GTC")
+	}
+	if order.ClientOrderID != "" {
+		params.Set("newClientOrderId", order.ClientOrderID)
+	}
+
+	log.Printf("binance is submitting order: symbol=%s, orderAction=%s, orderType=%s, volume=%s, price=%s, submitMode=%s, clientOrderID=%s\n",
+		symbol, order.OrderAction.String(), params.Get("type"), order.Volume.AsString(), order.Price.AsString(), submitMode.String(), order.ClientOrderID)
+	var resp binanceOrder
+	e = b.request("POST", "/api/v3/order", params, true, &resp)
+	if e != nil {
+		// the order may have been placed even though we got an error, unless the exchange rejected it because of rate limits
+		if order.ClientOrderID != "" && isExchangeRetryableError(e) && !isExchangeRateLimitError(e) {
+			txID, eReconcile := b.findOrderByClientID(symbol, order.ClientOrderID)
+			if eReconcile != nil {
+				log.Printf("unable to reconcile order with clientOrderID=%s after error when creating it: %s\n", order.ClientOrderID, eReconcile)
+			} else if txID != nil {
+				log.Printf("order with clientOrderID=%s was placed (ID=%s) even though there was an error when creating it: %s\n", order.ClientOrderID, txID.String(), e)
+				return txID, nil
+			}
+		}
+		return nil, fmt.Errorf("error while creating limit order %s: %s", *order, e)
+	}
+
+	orderID := strconv.FormatInt(resp.OrderID, 10)
+	b.clientOrderIDs.put(orderID, order.ClientOrderID)
+	return model.MakeTransactionID(orderID), nil
+}
+
+// findOrderByClientID returns the ID of the order with the given client order ID, or nil if there is no such order
+func (b *binanceExchange) findOrderByClientID(symbol string, clientOrderID string) (*model.TransactionID, error) {
+	var o binanceOrder
+	e := b.request("GET", "/api/v3/order", url.Values{"symbol": {symbol}, "origClientOrderId": {clientOrderID}}, true, &o)
+	if e != nil {
+		if be, ok := e.(*binanceAPIError); ok && be.code == binanceErrorCodeNoSuchOrder {
+			return nil, nil
+		}
+		return nil, e
+	}
+	return model.MakeTransactionID(strconv.FormatInt(o.OrderID, 10)), nil
+}
+
+// CancelOrder impl.
+func (b *binanceExchange) CancelOrder(txID *model.TransactionID, pair model.TradingPair) (model.CancelOrderResult, error) {
+	if b.isSimulated {
+		return model.CancelResultCancelSuccessful, nil
+	}
+	symbol, e := b.getSymbol(&pair)
+	if e != nil {
+		return model.CancelResultFailed, e
+	}
+	log.Printf("binance is canceling order: ID=%s, tradingPair=%s\n", txID.String(), pair.String())
+
+	var resp binanceOrder
+	e = b.request("DELETE", "/api/v3/order", url.Values{"symbol": {symbol}, "orderId": {txID.String()}}, true, &resp)
+	if e != nil {
+		if be, ok := e.(*binanceAPIError); ok && be.code == binanceErrorCodeUnknownOrder {
+			// the order was already filled or canceled
+			return model.CancelResultFailed, nil
+		}
+		return model.CancelResultFailed, e
+	}
+
+	if resp.Status == "CANCELED" {
+		return model.CancelResultCancelSuccessful, nil
+	}
+	return model.CancelResultPending, nil
+}
+
+// PrepareDeposit impl.
+func (b *binanceExchange) PrepareDeposit(asset model.Asset, amount *model.Number) (*api.PrepareDepositResult, error) {
+	return nil, fmt.Errorf("deposits are not supported by the binance integration")
+}
+
+// GetWithdrawInfo impl.
+func (b *binanceExchange) GetWithdrawInfo(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawInfo, error) {
+	return nil, fmt.Errorf("withdrawals are not supported by the binance integration")
+}
+
+// WithdrawFunds impl.
+func (b *binanceExchange) WithdrawFunds(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawFunds, error) {
+	return nil, fmt.Errorf("withdrawals are not supported by the binance integration")
+}
@@ -0,0 +1,634 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+const testBinanceKey = "key"
+const testBinanceSecret = "secret"
+
+var testBinancePair = model.TradingPair{Base: model.XLM, Quote: model.BTC}
+
+// binanceStub is a local HTTP server that implements the parts of the binance REST API used by binanceExchange
+type binanceStub struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mutex       *sync.Mutex
+	requests    []url.Values // params of the requests to the order endpoints
+	myTrades    []binanceMyTrade
+	openOrders  map[int64]binanceOrder
+	nextOrderID int64
+	errorStatus int // when set, the order endpoint responds with this status
+	errorCode   int
+}
+
+func makeBinanceStub(t *testing.T) *binanceStub {
+	s := &binanceStub{
+		t:           t,
+		mutex:       &sync.Mutex{},
+		requests:    []url.Values{},
+		myTrades:    []binanceMyTrade{},
+		openOrders:  map[int64]binanceOrder{},
+		nextOrderID: 100,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *binanceStub) makeExchange(t *testing.T) *binanceExchange {
+	x, e := makeBinanceExchange(
+		[]api.ExchangeAPIKey{{Key: testBinanceKey, Secret: testBinanceSecret}},
+		[]api.ExchangeParam{{Param: binanceBaseURLParam, Value: s.server.URL}},
+		false,
+	)
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	return x.(*binanceExchange)
+}
+
+func (s *binanceStub) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w).Encode(v)
+	assert.NoError(s.t, e)
+}
+
+func (s *binanceStub) writeError(w http.ResponseWriter, status int, code int, msg string) {
+	s.writeJSON(w, status, binanceError{Code: code, Msg: msg})
+}
+
+// verifySignature checks the API key header and the signature at the end of the query string
+func (s *binanceStub) verifySignature(r *http.Request) bool {
+	parts := strings.SplitN(r.URL.RawQuery, "&signature=", 2)
+	return len(parts) == 2 &&
+		r.Header.Get("X-MBX-APIKEY") == testBinanceKey &&
+		parts[1] == signBinanceQuery(testBinanceSecret, parts[0]) &&
+		r.URL.Query().Get("timestamp") != ""
+}
+
+func (s *binanceStub) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/api/v3/exchangeInfo":
+		s.writeJSON(w, 200, map[string]interface{}{
+			"symbols": []map[string]interface{}{{
+				"symbol":     "XLMBTC",
+				"status":     "TRADING",
+				"baseAsset":  "XLM",
+				"quoteAsset": "BTC",
+				"filters": []map[string]interface{}{
+					{"filterType": "PRICE_FILTER", "minPrice": "0.00000001", "maxPrice": "1000.00000000", "tickSize": "0.00000001"},
+					{"filterType": "LOT_SIZE", "minQty": "1.00000000", "maxQty": "90000000.00000000", "stepSize": "1.00000000"},
+					{"filterType": "MIN_NOTIONAL", "minNotional": "0.00010000", "applyToMarket": true, "avgPriceMins": 5},
+					{"filterType": "MAX_NUM_ORDERS", "maxNumOrders": 200},
+				},
+			}},
+		})
+		return
+	case "/api/v3/ticker/24hr":
+		s.writeJSON(w, 200, map[string]string{"symbol": q.Get("symbol"), "bidPrice": "0.00000810", "askPrice": "0.00000812", "lastPrice": "0.00000811"})
+		return
+	case "/api/v3/depth":
+		assert.Equal(s.t, "XLMBTC", q.Get("symbol"))
+		s.writeJSON(w, 200, map[string]interface{}{
+			"lastUpdateId": 1,
+			"bids":         [][]string{{"0.00000810", "100.00000000"}, {"0.00000809", "200.00000000"}, {"0.00000808", "300.00000000"}},
+			"asks":         [][]string{{"0.00000812", "150.00000000"}, {"0.00000813", "250.00000000"}},
+		})
+		return
+	case "/api/v3/aggTrades":
+		fromID, _ := strconv.ParseInt(q.Get("fromId"), 10, 64)
+		trades := []map[string]interface{}{}
+		for id := int64(10); id < 13; id++ {
+			if id >= fromID {
+				trades = append(trades, map[string]interface{}{"a": id, "p": "0.00000811", "q": "10.00000000", "T": 1600000000000 + id, "m": id%2 == 0})
+			}
+		}
+		s.writeJSON(w, 200, trades)
+		return
+	}
+
+	if !s.verifySignature(r) {
+		s.writeError(w, 400, -1022, "Signature for this request is not valid.")
+		return
+	}
+	switch r.URL.Path {
+	case "/api/v3/account":
+		s.writeJSON(w, 200, map[string]interface{}{
+			"balances": []map[string]string{
+				{"asset": "XLM", "free": "1000.50000000", "locked": "200.00000000"},
+				{"asset": "BTC", "free": "0.01000000", "locked": "0.00000000"},
+			},
+		})
+	case "/api/v3/myTrades":
+		s.handleMyTrades(w, q)
+	case "/api/v3/openOrders":
+		orders := []binanceOrder{}
+		for _, o := range s.openOrders {
+			orders = append(orders, o)
+		}
+		s.writeJSON(w, 200, orders)
+	case "/api/v3/order":
+		s.requests = append(s.requests, q)
+		s.handleOrder(w, r.Method, q)
+	default:
+		s.writeError(w, 404, -1, "unknown path "+r.URL.Path)
+	}
+}
+
+func (s *binanceStub) handleMyTrades(w http.ResponseWriter, q url.Values) {
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if q.Get("fromId") != "" && q.Get("startTime") != "" {
+		s.writeError(w, 400, -1128, "Combination of optional parameters invalid.")
+		return
+	}
+
+	page := []binanceMyTrade{}
+	for _, t := range s.myTrades {
+		if q.Get("fromId") != "" {
+			fromID, _ := strconv.ParseInt(q.Get("fromId"), 10, 64)
+			if t.ID < fromID {
+				continue
+			}
+		}
+		if q.Get("startTime") != "" {
+			startTime, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+			// without an endTime binance only returns the trades within a day of startTime
+			if t.Time < startTime || t.Time >= startTime+binanceTradesWindowMillis {
+				continue
+			}
+		}
+		if len(page) < limit {
+			page = append(page, t)
+		}
+	}
+	s.writeJSON(w, 200, page)
+}
+
+func (s *binanceStub) handleOrder(w http.ResponseWriter, method string, q url.Values) {
+	if s.errorStatus != 0 {
+		s.writeError(w, s.errorStatus, s.errorCode, "stubbed error")
+		return
+	}
+
+	switch method {
+	case "POST":
+		if q.Get("type") == "LIMIT_MAKER" && q.Get("side") == "BUY" && q.Get("price") >= "0.00000812" {
+			s.writeError(w, 400, -2010, "Order would immediately match and take.")
+			return
+		}
+		id := s.nextOrderID
+		s.nextOrderID++
+		s.openOrders[id] = binanceOrder{
+			Symbol:        q.Get("symbol"),
+			OrderID:       id,
+			ClientOrderID: q.Get("newClientOrderId"),
+			Price:         q.Get("price"),
+			OrigQty:       q.Get("quantity"),
+			ExecutedQty:   "0.00000000",
+			Status:        "NEW",
+			Type:          q.Get("type"),
+			Side:          q.Get("side"),
+			Time:          1600000000000,
+		}
+		s.writeJSON(w, 200, map[string]interface{}{"symbol": q.Get("symbol"), "orderId": id, "clientOrderId": q.Get("newClientOrderId")})
+	case "DELETE":
+		id, _ := strconv.ParseInt(q.Get("orderId"), 10, 64)
+		o, ok := s.openOrders[id]
+		if !ok {
+			s.writeError(w, 400, binanceErrorCodeUnknownOrder, "Unknown order sent.")
+			return
+		}
+		delete(s.openOrders, id)
+		o.Status = "CANCELED"
+		s.writeJSON(w, 200, o)
+	case "GET":
+		for _, o := range s.openOrders {
+			if o.ClientOrderID == q.Get("origClientOrderId") {
+				s.writeJSON(w, 200, o)
+				return
+			}
+		}
+		s.writeError(w, 400, binanceErrorCodeNoSuchOrder, "Order does not exist.")
+	}
+}
+
+func TestBinanceStepPrecision(t *testing.T) {
+	testCases := []struct {
+		step      string
+		want      int8
+		wantError bool
+	}{
+		{step: "0.00000001", want: 8},
+		{step: "0.00100000", want: 3},
+		{step: "1.00000000", want: 0},
+		{step: "10", want: 0},
+		{step: "0.00000000", wantError: true},
+		{step: "abc", wantError: true},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.step, func(t *testing.T) {
+			precision, e := binanceStepPrecision(k.step)
+			if k.wantError {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) {
+				assert.Equal(t, k.want, precision)
+			}
+		})
+	}
+}
+
+func TestMakeBinanceExchange_ExchangeInfoError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, e := makeBinanceExchange(
+		[]api.ExchangeAPIKey{{Key: testBinanceKey, Secret: testBinanceSecret}},
+		[]api.ExchangeParam{{Param: binanceBaseURLParam, Value: server.URL}},
+		false,
+	)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "exchangeInfo")
+	}
+}
+
+func TestBinanceGetOrderConstraints(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	oc := x.GetOrderConstraints(&testBinancePair)
+	assert.Equal(t, int8(8), oc.PricePrecision)
+	assert.Equal(t, int8(0), oc.VolumePrecision)
+	assert.Equal(t, "1", oc.MinBaseVolume.AsString())
+	if assert.NotNil(t, oc.MinQuoteVolume) {
+		assert.Equal(t, "0.00010000", oc.MinQuoteVolume.AsString())
+	}
+
+	// overrides are applied on top of the values from exchangeInfo
+	volumePrecision := int8(2)
+	x.OverrideOrderConstraints(&testBinancePair, model.MakeOrderConstraintsOverride(nil, &volumePrecision, nil, nil))
+	assert.Equal(t, int8(2), x.GetOrderConstraints(&testBinancePair).VolumePrecision)
+}
+
+func TestBinanceMarketData(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	tickers, e := x.GetTickerPrice([]model.TradingPair{testBinancePair})
+	if assert.NoError(t, e) {
+		assert.Equal(t, "0.00000810", tickers[testBinancePair].BidPrice.AsString())
+		assert.Equal(t, "0.00000812", tickers[testBinancePair].AskPrice.AsString())
+		assert.Equal(t, "0.00000811", tickers[testBinancePair].LastPrice.AsString())
+	}
+
+	ob, e := x.GetOrderBook(&testBinancePair, 2)
+	if assert.NoError(t, e) {
+		if assert.Equal(t, 2, len(ob.Bids())) {
+			assert.Equal(t, "0.00000810", ob.Bids()[0].Price.AsString())
+			assert.Equal(t, "100", ob.Bids()[0].Volume.AsString())
+			assert.Equal(t, model.OrderActionBuy, ob.Bids()[0].OrderAction)
+		}
+		if assert.Equal(t, 2, len(ob.Asks())) {
+			assert.Equal(t, "0.00000812", ob.Asks()[0].Price.AsString())
+			assert.Equal(t, model.OrderActionSell, ob.Asks()[0].OrderAction)
+		}
+	}
+
+	trades, e := x.GetTrades(&testBinancePair, nil)
+	if assert.NoError(t, e) {
+		assert.Equal(t, 3, len(trades.Trades))
+		assert.Equal(t, int64(13), trades.Cursor)
+		assert.Equal(t, model.OrderActionSell, trades.Trades[0].OrderAction)
+		assert.Equal(t, model.OrderActionBuy, trades.Trades[1].OrderAction)
+	}
+	trades, e = x.GetTrades(&testBinancePair, "12")
+	if assert.NoError(t, e) && assert.Equal(t, 1, len(trades.Trades)) {
+		assert.Equal(t, "12", trades.Trades[0].TransactionID.String())
+	}
+}
+
+func TestBinanceGetAccountBalances(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	balances, e := x.GetAccountBalances([]interface{}{model.XLM, model.BTC, model.USD})
+	if !assert.NoError(t, e) {
+		return
+	}
+	xlm := balances[model.XLM]
+	btc := balances[model.BTC]
+	usd := balances[model.USD]
+	assert.Equal(t, "1200.50000000", xlm.AsString())
+	assert.Equal(t, "0.01000000", btc.AsString())
+	assert.Equal(t, "0.00000000", usd.AsString())
+
+	// signed requests fail without an API key
+	x.apiKeys = []api.ExchangeAPIKey{{Key: "", Secret: ""}}
+	_, e = x.GetAccountBalances([]interface{}{model.XLM})
+	assert.Error(t, e)
+
+	// requests signed with the wrong secret are rejected by binance
+	x.apiKeys = []api.ExchangeAPIKey{{Key: testBinanceKey, Secret: "wrong"}}
+	_, e = x.GetAccountBalances([]interface{}{model.XLM})
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "code=-1022")
+	}
+}
+
+func TestBinanceAddOrder(t *testing.T) {
+	testCases := []struct {
+		name        string
+		submitMode  api.SubmitMode
+		action      model.OrderAction
+		price       string
+		wantType    string
+		wantTIF     string
+		wantError   bool
+		wantOpenIDs int
+	}{
+		{
+			name:        "both",
+			submitMode:  api.SubmitModeBoth,
+			action:      model.OrderActionSell,
+			price:       "0.00000820",
+			wantType:    "LIMIT",
+			wantTIF:     "GTC",
+			wantOpenIDs: 1,
+		}, {
+			name:        "maker only",
+			submitMode:  api.SubmitModeMakerOnly,
+			action:      model.OrderActionSell,
+			price:       "0.00000820",
+			wantType:    "LIMIT_MAKER",
+			wantTIF:     "",
+			wantOpenIDs: 1,
+		}, {
+			name:        "maker only rejected because it would take",
+			submitMode:  api.SubmitModeMakerOnly,
+			action:      model.OrderActionBuy,
+			price:       "0.00000812",
+			wantType:    "LIMIT_MAKER",
+			wantError:   true,
+			wantOpenIDs: 0,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			stub := makeBinanceStub(t)
+			defer stub.server.Close()
+			x := stub.makeExchange(t)
+
+			txID, e := x.AddOrder(&model.Order{
+				Pair:          &testBinancePair,
+				OrderAction:   k.action,
+				OrderType:     model.OrderTypeLimit,
+				Price:         model.MustNumberFromString(k.price, 8),
+				Volume:        model.MustNumberFromString("100", 0),
+				ClientOrderID: "client1",
+			}, k.submitMode)
+			if k.wantError {
+				assert.Error(t, e)
+			} else if assert.NoError(t, e) {
+				assert.Equal(t, "100", txID.String())
+			}
+
+			if assert.Equal(t, 1, len(stub.requests)) {
+				req := stub.requests[0]
+				assert.Equal(t, "XLMBTC", req.Get("symbol"))
+				assert.Equal(t, strings.ToUpper(k.action.String()), req.Get("side"))
+				assert.Equal(t, k.wantType, req.Get("type"))
+				assert.Equal(t, k.wantTIF, req.Get("timeInForce"))
+				assert.Equal(t, k.price, req.Get("price"))
+				assert.Equal(t, "100", req.Get("quantity"))
+				assert.Equal(t, "client1", req.Get("newClientOrderId"))
+			}
+
+			openOrders, e := x.GetOpenOrders([]*model.TradingPair{&testBinancePair})
+			if assert.NoError(t, e) && assert.Equal(t, k.wantOpenIDs, len(openOrders[testBinancePair])) && k.wantOpenIDs > 0 {
+				o := openOrders[testBinancePair][0]
+				assert.Equal(t, "100", o.ID)
+				assert.Equal(t, "client1", o.ClientOrderID)
+				assert.Equal(t, k.action, o.OrderAction)
+				assert.Equal(t, k.price, o.Price.AsString())
+				assert.Equal(t, "0", o.VolumeExecuted.AsString())
+			}
+		})
+	}
+}
+
+func TestBinanceAddOrderReconcilesAfterTransientError(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	// the order was placed but the response was lost
+	stub.openOrders[42] = binanceOrder{Symbol: "XLMBTC", OrderID: 42, ClientOrderID: "client1", Price: "0.00000820", OrigQty: "100", ExecutedQty: "0", Side: "SELL"}
+	stub.errorStatus = http.StatusBadGateway
+	stub.errorCode = -1
+	order := &model.Order{
+		Pair:          &testBinancePair,
+		OrderAction:   model.OrderActionSell,
+		OrderType:     model.OrderTypeLimit,
+		Price:         model.MustNumberFromString("0.00000820", 8),
+		Volume:        model.MustNumberFromString("100", 0),
+		ClientOrderID: "client1",
+	}
+
+	// only the POST fails, the lookup of the order by its client order ID succeeds
+	x.httpClient.Transport = &clearErrorAfterPostTransport{stub: stub, inner: http.DefaultTransport}
+	txID, e := x.AddOrder(order, api.SubmitModeBoth)
+	if assert.NoError(t, e) {
+		assert.Equal(t, "42", txID.String())
+	}
+
+	// rate limit errors are not reconciled because the exchange did not process the request
+	stub.errorStatus = http.StatusTooManyRequests
+	stub.errorCode = -1003
+	x.httpClient.Transport = http.DefaultTransport
+	_, e = x.AddOrder(order, api.SubmitModeBoth)
+	if assert.Error(t, e) {
+		assert.True(t, isExchangeRateLimitError(e))
+	}
+}
+
+// clearErrorAfterPostTransport clears the stubbed error after the first POST so the follow up requests succeed
+type clearErrorAfterPostTransport struct {
+	stub  *binanceStub
+	inner http.RoundTripper
+}
+
+func (c *clearErrorAfterPostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, e := c.inner.RoundTrip(r)
+	if r.Method == "POST" {
+		c.stub.mutex.Lock()
+		c.stub.errorStatus = 0
+		c.stub.mutex.Unlock()
+	}
+	return resp, e
+}
+
+func TestBinanceCancelOrder(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+	stub.openOrders[7] = binanceOrder{Symbol: "XLMBTC", OrderID: 7, Price: "0.00000820", OrigQty: "100", ExecutedQty: "0", Side: "SELL"}
+
+	result, e := x.CancelOrder(model.MakeTransactionID("7"), testBinancePair)
+	if assert.NoError(t, e) {
+		assert.Equal(t, model.CancelResultCancelSuccessful, result)
+	}
+	assert.Equal(t, 0, len(stub.openOrders))
+
+	// the order no longer exists
+	result, e = x.CancelOrder(model.MakeTransactionID("7"), testBinancePair)
+	if assert.NoError(t, e) {
+		assert.Equal(t, model.CancelResultFailed, result)
+	}
+}
+
+func TestBinanceGetTradeHistory(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	// more trades than fit in a single page
+	for i := int64(1); i <= binanceTradesLimit+5; i++ {
+		stub.myTrades = append(stub.myTrades, binanceMyTrade{
+			ID:              i,
+			OrderID:         i / 10,
+			Price:           "0.00000811",
+			Quantity:        "10.00000000",
+			QuoteQuantity:   "0.00008110",
+			Commission:      "0.00000010",
+			CommissionAsset: "BTC",
+			Time:            1600000000000 + i,
+			IsBuyer:         i%2 == 0,
+		})
+	}
+	x.clientOrderIDs.put("0", "client0")
+
+	result, e := x.GetTradeHistory(testBinancePair, "1600000000003", nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, binanceTradesLimit+3, len(result.Trades)) {
+		first := result.Trades[0]
+		assert.Equal(t, "3", first.TransactionID.String())
+		assert.Equal(t, "0", first.OrderID)
+		assert.Equal(t, "client0", first.ClientOrderID)
+		assert.Equal(t, model.OrderActionSell, first.OrderAction)
+		assert.Equal(t, "0.00008110", first.Cost.AsString())
+		assert.Equal(t, "0.00000010", first.Fee.AsString())
+		assert.Equal(t, model.OrderActionBuy, result.Trades[1].OrderAction)
+		assert.Equal(t, strconv.Itoa(binanceTradesLimit+5), result.Trades[len(result.Trades)-1].TransactionID.String())
+	}
+	assert.Equal(t, strconv.FormatInt(1600000000000+binanceTradesLimit+5+1, 10), result.Cursor)
+
+	// the end cursor is inclusive
+	result, e = x.GetTradeHistory(testBinancePair, "1600000000003", "1600000000005")
+	if assert.NoError(t, e) {
+		assert.Equal(t, 3, len(result.Trades))
+		assert.Equal(t, "1600000000006", result.Cursor)
+	}
+
+}
+
+func TestBinanceGetTradeHistory_EmptyWindow(t *testing.T) {
+	stub := makeBinanceStub(t)
+	defer stub.server.Close()
+	x := stub.makeExchange(t)
+
+	start := int64(1600000000000)
+	// the only trade is made a day and a half after the start
+	stub.myTrades = append(stub.myTrades, binanceMyTrade{
+		ID:              1,
+		OrderID:         1,
+		Price:           "0.00000811",
+		Quantity:        "10.00000000",
+		QuoteQuantity:   "0.00008110",
+		Commission:      "0.00000010",
+		CommissionAsset: "BTC",
+		Time:            start + binanceTradesWindowMillis*3/2,
+	})
+
+	testCases := []struct {
+		name       string
+		nowMillis  int64
+		cursor     string
+		end        interface{}
+		wantCursor string
+		wantTrades int
+	}{
+		{
+			name:       "cursor moves to the end of the empty window",
+			nowMillis:  start + 2*binanceTradesWindowMillis,
+			cursor:     strconv.FormatInt(start, 10),
+			wantCursor: strconv.FormatInt(start+binanceTradesWindowMillis, 10),
+		}, {
+			name:       "trade is fetched from the next window",
+			nowMillis:  start + 2*binanceTradesWindowMillis,
+			cursor:     strconv.FormatInt(start+binanceTradesWindowMillis, 10),
+			wantCursor: strconv.FormatInt(start+binanceTradesWindowMillis*3/2+1, 10),
+			wantTrades: 1,
+		}, {
+			name:       "cursor does not move past now",
+			nowMillis:  start + 1000,
+			cursor:     strconv.FormatInt(start, 10),
+			wantCursor: strconv.FormatInt(start+1000, 10),
+		}, {
+			name:       "cursor does not move past the end",
+			nowMillis:  start + 2*binanceTradesWindowMillis,
+			cursor:     strconv.FormatInt(start, 10),
+			end:        strconv.FormatInt(start+5, 10),
+			wantCursor: strconv.FormatInt(start+6, 10),
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			nowMillis := k.nowMillis
+			x.nowFn = func() time.Time { return time.Unix(0, nowMillis*int64(time.Millisecond)) }
+
+			result, e := x.GetTradeHistory(testBinancePair, k.cursor, k.end)
+			if assert.NoError(t, e) {
+				assert.Equal(t, k.wantTrades, len(result.Trades))
+				assert.Equal(t, k.wantCursor, result.Cursor)
+			}
+		})
+	}
+}
+
+func TestBinanceGetLatestTradeCursor(t *testing.T) {
+	x := &binanceExchange{nowFn: func() time.Time { return time.Unix(1600000000, 0) }}
+	cursor, e := x.GetLatestTradeCursor()
+	if assert.NoError(t, e) {
+		assert.Equal(t, "1600000000000", cursor)
+	}
+}
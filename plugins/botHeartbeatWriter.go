@@ -0,0 +1,53 @@
+package plugins
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stellar/kelp/kelpdb"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/postgresdb"
+)
+
+// BotHeartbeatWriter records the last update time of a bot in the bot_heartbeats table, the terminator deletes the offers of bots
+// whose heartbeat is too old
+type BotHeartbeatWriter struct {
+	db        *sql.DB
+	accountID string
+	marketID  string
+	botKey    *model.BotKey
+}
+
+// MakeBotHeartbeatWriter is a factory method for BotHeartbeatWriter
+func MakeBotHeartbeatWriter(db *sql.DB, accountID string, marketID string, botKey *model.BotKey) *BotHeartbeatWriter {
+	return &BotHeartbeatWriter{
+		db:        db,
+		accountID: accountID,
+		marketID:  marketID,
+		botKey:    botKey,
+	}
+}
+
+// WriteHeartbeat records that the bot was alive at the given time
+func (w *BotHeartbeatWriter) WriteHeartbeat(lastUpdate time.Time) error {
+	sqlUpsert := makeSQLUpsertBotHeartbeat(w.accountID, w.marketID, w.botKey, lastUpdate)
+	_, e := w.db.Exec(sqlUpsert)
+	if e != nil {
+		return fmt.Errorf("could not execute sql upsert statement (%s): %s", sqlUpsert, e)
+	}
+	return nil
+}
+
+func makeSQLUpsertBotHeartbeat(accountID string, marketID string, botKey *model.BotKey, lastUpdate time.Time) string {
+	return fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate,
+		accountID,
+		botKey.Hash(),
+		marketID,
+		botKey.AssetBaseCode,
+		botKey.AssetBaseIssuer,
+		botKey.AssetQuoteCode,
+		botKey.AssetQuoteIssuer,
+		lastUpdate.UTC().Format(postgresdb.TimestampFormatString),
+	)
+}
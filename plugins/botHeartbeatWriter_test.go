@@ -0,0 +1,25 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/model"
+)
+
+func TestMakeSQLUpsertBotHeartbeat(t *testing.T) {
+	botKey := &model.BotKey{
+		AssetBaseCode:    "native",
+		AssetBaseIssuer:  "",
+		AssetQuoteCode:   "USD",
+		AssetQuoteIssuer: "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX",
+	}
+	lastUpdate := time.Date(2020, time.May, 6, 7, 8, 9, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	sqlUpsert := makeSQLUpsertBotHeartbeat("GACCOUNT", "96eda0a6ec", botKey, lastUpdate)
+	assert.Equal(t, "INSERT INTO bot_heartbeats (account_id, bot_key, market_id, base_code, base_issuer, quote_code, quote_issuer, last_update_utc) "+
+		"VALUES ('GACCOUNT', '"+botKey.Hash()+"', '96eda0a6ec', 'native', '', 'USD', 'GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX', '2020/05/06 05:08:09 UTC') "+
+		"ON CONFLICT (account_id, bot_key) DO UPDATE SET market_id = EXCLUDED.market_id, last_update_utc = EXCLUDED.last_update_utc", sqlUpsert)
+}
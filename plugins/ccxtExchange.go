@@ -5,6 +5,8 @@ import (
 	"log"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/stellar/kelp/api"
@@ -26,6 +28,10 @@ type ccxtExchangeSpecificParamFactory interface {
 	getParamsForGetTradeHistory() interface{}
 	useSignToDenoteSideForTrades() bool
 	getCursorFetchTrades(model.Trade) (interface{}, error)
+	// name of the param used to send a client order id when adding an order, empty if not supported
+	getClientOrderIDParam() string
+	// order type and params used to hold the protective order natively on the exchange, false if not supported
+	getParamsForProtectiveOrder(order *api.ProtectiveOrder) (string, map[string]interface{}, bool)
 }
 
 // ccxtExchange is the implementation for the CCXT REST library that supports many exchanges (https://github.com/franz-see/ccxt-rest, https://github.com/ccxt/ccxt/)
@@ -36,6 +42,36 @@ type ccxtExchange struct {
 	api                *sdk.Ccxt
 	simMode            bool
 	esParamFactory     ccxtExchangeSpecificParamFactory
+	clientOrderIDs     *clientOrderIDMap
+}
+
+// clientOrderIDMap remembers the client order ID of the orders we have seen so we can attach them to fills
+type clientOrderIDMap struct {
+	lock *sync.Mutex
+	m    map[string]string // orderID -> clientOrderID
+}
+
+func makeClientOrderIDMap() *clientOrderIDMap {
+	return &clientOrderIDMap{
+		lock: &sync.Mutex{},
+		m:    map[string]string{},
+	}
+}
+
+func (c *clientOrderIDMap) put(orderID string, clientOrderID string) {
+	if orderID == "" || clientOrderID == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.m[orderID] = clientOrderID
+}
+
+func (c *clientOrderIDMap) get(orderID string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.m[orderID]
 }
 
 // makeCcxtExchange is a factory method to make an exchange using the CCXT interface
@@ -89,6 +125,7 @@ func makeCcxtExchange(
 		api:                c,
 		simMode:            simMode,
 		esParamFactory:     esParamFactory,
+		clientOrderIDs:     makeClientOrderIDMap(),
 	}, nil
 }
 
@@ -130,6 +167,11 @@ func (c ccxtExchange) GetTickerPrice(pairs []model.TradingPair) (map[model.Tradi
 	return priceResult, nil
 }
 
+// getRateLimitMillis is used by the RateLimitedExchange to configure its token bucket
+func (c ccxtExchange) getRateLimitMillis() int64 {
+	return c.api.GetRateLimitMillis()
+}
+
 // GetAssetConverter impl
 func (c ccxtExchange) GetAssetConverter() model.AssetConverterInterface {
 	return c.assetConverter
@@ -302,6 +344,7 @@ func (c ccxtExchange) GetTradeHistory(pair model.TradingPair, maybeCursorStart i
 			}
 		}
 		t.OrderID = orderID
+		t.ClientOrderID = c.clientOrderIDs.get(orderID)
 
 		trades = append(trades, *t)
 	}
@@ -479,6 +522,10 @@ func (c ccxtExchange) GetOpenOrders(pairs []*model.TradingPair) (map[model.Tradi
 
 		openOrderList := []model.OpenOrder{}
 		for _, o := range ccxtOrderList {
+			if isCcxtProtectiveOrderType(o.Type) {
+				// protective orders are managed by ProtectiveOrders and should not be seen as offers by the strategy
+				continue
+			}
 			openOrder, e := c.convertOpenOrderFromCcxt(&pair, o)
 			if e != nil {
 				return nil, fmt.Errorf("cannot convertOpenOrderFromCcxt: %s", e)
@@ -501,15 +548,18 @@ func (c ccxtExchange) convertOpenOrderFromCcxt(pair *model.TradingPair, o sdk.Cc
 		orderAction = model.OrderActionBuy
 	}
 	ts := model.MakeTimestamp(o.Timestamp)
+	clientOrderID := c.readClientOrderID(o)
+	c.clientOrderIDs.put(o.ID, clientOrderID)
 
 	return &model.OpenOrder{
 		Order: model.Order{
-			Pair:        pair,
-			OrderAction: orderAction,
-			OrderType:   model.OrderTypeLimit,
-			Price:       model.NumberFromFloat(o.Price, c.GetOrderConstraints(pair).PricePrecision),
-			Volume:      model.NumberFromFloat(o.Amount, c.GetOrderConstraints(pair).VolumePrecision),
-			Timestamp:   ts,
+			Pair:          pair,
+			OrderAction:   orderAction,
+			OrderType:     model.OrderTypeLimit,
+			Price:         model.NumberFromFloat(o.Price, c.GetOrderConstraints(pair).PricePrecision),
+			Volume:        model.NumberFromFloat(o.Amount, c.GetOrderConstraints(pair).VolumePrecision),
+			Timestamp:     ts,
+			ClientOrderID: clientOrderID,
 		},
 		ID:             o.ID,
 		StartTime:      ts,
@@ -518,6 +568,69 @@ func (c ccxtExchange) convertOpenOrderFromCcxt(pair *model.TradingPair, o sdk.Cc
 	}, nil
 }
 
+// readClientOrderID reads the client order ID from the unified field, falling back to the raw response for older versions of ccxt
+func (c ccxtExchange) readClientOrderID(o sdk.CcxtOpenOrder) string {
+	if o.ClientOrderID != "" {
+		return o.ClientOrderID
+	}
+
+	if c.esParamFactory == nil || c.esParamFactory.getClientOrderIDParam() == "" {
+		return ""
+	}
+
+	infoMap, ok := o.Info.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, k := range []string{"clientOrderId", c.esParamFactory.getClientOrderIDParam()} {
+		if v, ok := infoMap[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// makeAddOrderParams returns the exchange specific params for adding the order and the client order ID used, if supported by the exchange
+func (c ccxtExchange) makeAddOrderParams(order *model.Order, submitMode api.SubmitMode) (interface{}, string, error) {
+	if c.esParamFactory == nil {
+		return nil, "", nil
+	}
+
+	maybeExchangeSpecificParams := c.esParamFactory.getParamsForAddOrder(submitMode)
+	clientOrderIDParam := c.esParamFactory.getClientOrderIDParam()
+	if order.ClientOrderID == "" || clientOrderIDParam == "" {
+		return maybeExchangeSpecificParams, "", nil
+	}
+
+	paramsMap := map[string]interface{}{}
+	if maybeExchangeSpecificParams != nil {
+		m, ok := maybeExchangeSpecificParams.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("cannot add client order ID to exchange specific params of type %T", maybeExchangeSpecificParams)
+		}
+		for k, v := range m {
+			paramsMap[k] = v
+		}
+	}
+	paramsMap[clientOrderIDParam] = order.ClientOrderID
+	return paramsMap, order.ClientOrderID, nil
+}
+
+// findOpenOrderByClientID returns the ID of the open order with the given client order ID, or nil if there is no such order
+func (c ccxtExchange) findOpenOrderByClientID(pair *model.TradingPair, clientOrderID string) (*model.TransactionID, error) {
+	openOrders, e := c.GetOpenOrders([]*model.TradingPair{pair})
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch open orders: %s", e)
+	}
+
+	for _, o := range openOrders[*pair] {
+		if o.ClientOrderID == clientOrderID {
+			return model.MakeTransactionID(o.ID), nil
+		}
+	}
+	return nil, nil
+}
+
 // AddOrder impl
 func (c ccxtExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*model.TransactionID, error) {
 	pairString, e := order.Pair.ToString(c.assetConverter, c.delimiter)
@@ -530,21 +643,110 @@ func (c ccxtExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*
 		side = "buy"
 	}
 
-	log.Printf("ccxt is submitting order: pair=%s, orderAction=%s, orderType=%s, volume=%s, price=%s, submitMode=%s\n",
-		pairString, order.OrderAction.String(), order.OrderType.String(), order.Volume.AsString(), order.Price.AsString(), submitMode.String())
+	maybeExchangeSpecificParams, clientOrderID, e := c.makeAddOrderParams(order, submitMode)
+	if e != nil {
+		return nil, fmt.Errorf("error making exchange specific params for adding order: %s", e)
+	}
 
-	var maybeExchangeSpecificParams interface{}
-	if c.esParamFactory != nil {
-		maybeExchangeSpecificParams = c.esParamFactory.getParamsForAddOrder(submitMode)
+	log.Printf("ccxt is submitting order: pair=%s, orderAction=%s, orderType=%s, volume=%s, price=%s, submitMode=%s, clientOrderID=%s\n",
+		pairString, order.OrderAction.String(), order.OrderType.String(), order.Volume.AsString(), order.Price.AsString(), submitMode.String(), clientOrderID)
+
+	ccxtOpenOrder, e := c.api.CreateOrder(pairString, order.OrderType.String(), side, order.Volume.AsFloat(), order.Price.AsFloat(), maybeExchangeSpecificParams)
+	if e != nil {
+		// the order may have been placed even though we got an error, unless the exchange rejected it because of rate limits
+		if clientOrderID != "" && isExchangeRetryableError(e) && !isExchangeRateLimitError(e) {
+			txID, eReconcile := c.findOpenOrderByClientID(order.Pair, clientOrderID)
+			if eReconcile != nil {
+				log.Printf("unable to reconcile order with clientOrderID=%s after error when creating it: %s\n", clientOrderID, eReconcile)
+			} else if txID != nil {
+				log.Printf("order with clientOrderID=%s was placed (ID=%s) even though there was an error when creating it: %s\n", clientOrderID, txID.String(), e)
+				return txID, nil
+			}
+		}
+		return nil, fmt.Errorf("error while creating %s order %s: %s", order.OrderType.String(), *order, e)
+	}
+	c.clientOrderIDs.put(ccxtOpenOrder.ID, clientOrderID)
+
+	return model.MakeTransactionID(ccxtOpenOrder.ID), nil
+}
+
+// ensure that ccxtExchange can hold protective orders natively when the exchange specific param factory supports them
+var _ api.ProtectiveOrderAPI = ccxtExchange{}
+
+// ccxtProtectiveOrderTypes are the (lowercased) order types reported by ccxt for the protective orders we place
+var ccxtProtectiveOrderTypes = map[string]bool{
+	"stop_loss":         true,
+	"stop_loss_limit":   true,
+	"take_profit":       true,
+	"take_profit_limit": true,
+}
+
+func isCcxtProtectiveOrderType(orderType string) bool {
+	return ccxtProtectiveOrderTypes[strings.ToLower(orderType)]
+}
+
+// SupportsProtectiveOrder impl
+func (c ccxtExchange) SupportsProtectiveOrder(order *api.ProtectiveOrder) bool {
+	if c.esParamFactory == nil {
+		return false
+	}
+	_, _, ok := c.esParamFactory.getParamsForProtectiveOrder(order)
+	return ok
+}
+
+// AddProtectiveOrder impl
+func (c ccxtExchange) AddProtectiveOrder(order *api.ProtectiveOrder) (*model.TransactionID, error) {
+	if !c.SupportsProtectiveOrder(order) {
+		return nil, fmt.Errorf("exchange does not support protective order natively: %s", order)
+	}
+	orderType, params, _ := c.esParamFactory.getParamsForProtectiveOrder(order)
+	if clientOrderIDParam := c.esParamFactory.getClientOrderIDParam(); clientOrderIDParam != "" && order.ClientOrderID != "" {
+		params[clientOrderIDParam] = order.ClientOrderID
 	}
-	ccxtOpenOrder, e := c.api.CreateLimitOrder(pairString, side, order.Volume.AsFloat(), order.Price.AsFloat(), maybeExchangeSpecificParams)
+
+	pairString, e := order.Pair.ToString(c.assetConverter, c.delimiter)
 	if e != nil {
-		return nil, fmt.Errorf("error while creating limit order %s: %s", *order, e)
+		return nil, fmt.Errorf("error converting pair to string: %s", e)
+	}
+	side := "sell"
+	if order.OrderAction.IsBuy() {
+		side = "buy"
+	}
+	// the price is only used by the exchange for the limit variants of the protective orders
+	price := order.TriggerPrice
+	if order.LimitPrice != nil {
+		price = order.LimitPrice
 	}
 
+	log.Printf("ccxt is submitting protective order: pair=%s, orderType=%s, %s, params=%v\n", pairString, orderType, order, params)
+	ccxtOpenOrder, e := c.api.CreateOrder(pairString, orderType, side, order.Volume.AsFloat(), price.AsFloat(), params)
+	if e != nil {
+		return nil, fmt.Errorf("error while creating protective order %s: %s", order, e)
+	}
 	return model.MakeTransactionID(ccxtOpenOrder.ID), nil
 }
 
+// GetOpenProtectiveOrders impl
+func (c ccxtExchange) GetOpenProtectiveOrders(pair *model.TradingPair) (map[string]string, error) {
+	pairString, e := pair.ToString(c.assetConverter, c.delimiter)
+	if e != nil {
+		return nil, fmt.Errorf("error converting pair to string: %s", e)
+	}
+
+	openOrdersMap, e := c.api.FetchOpenOrders([]string{pairString})
+	if e != nil {
+		return nil, fmt.Errorf("error while fetching open orders for trading pair '%s': %s", pairString, e)
+	}
+
+	clientOrderIDs := map[string]string{}
+	for _, o := range openOrdersMap[pairString] {
+		if isCcxtProtectiveOrderType(o.Type) {
+			clientOrderIDs[o.ID] = c.readClientOrderID(o)
+		}
+	}
+	return clientOrderIDs, nil
+}
+
 // CancelOrder impl
 func (c ccxtExchange) CancelOrder(txID *model.TransactionID, pair model.TradingPair) (model.CancelOrderResult, error) {
 	log.Printf("ccxt is canceling order: ID=%s, tradingPair: %s\n", txID.String(), pair.String())
@@ -41,6 +41,16 @@ func (f *ccxtExchangeSpecificParamFactoryCoinbasepro) getCursorFetchTrades(t mod
 	return nil, nil
 }
 
+// coinbase pro requires the client order id to be a UUID
+func (f *ccxtExchangeSpecificParamFactoryCoinbasepro) getClientOrderIDParam() string {
+	return "client_oid"
+}
+
+func (f *ccxtExchangeSpecificParamFactoryCoinbasepro) getParamsForProtectiveOrder(order *api.ProtectiveOrder) (string, map[string]interface{}, bool) {
+	// not supported so the protective orders are simulated
+	return "", nil, false
+}
+
 var _ ccxtExchangeSpecificParamFactory = &ccxtExchangeSpecificParamFactoryCoinbasepro{}
 
 /****************************** BINANCE ******************************/
@@ -127,6 +137,24 @@ func (f *ccxtExchangeSpecificParamFactoryBinance) getCursorFetchTrades(t model.T
 	return cursor, nil
 }
 
+func (f *ccxtExchangeSpecificParamFactoryBinance) getClientOrderIDParam() string {
+	return "newClientOrderId"
+}
+
+// binance holds stop loss and take profit orders natively, the LIMIT variants are used when the order has a limit price
+func (f *ccxtExchangeSpecificParamFactoryBinance) getParamsForProtectiveOrder(order *api.ProtectiveOrder) (string, map[string]interface{}, bool) {
+	orderType := "STOP_LOSS"
+	if order.Type == api.ProtectiveOrderTypeTakeProfit {
+		orderType = "TAKE_PROFIT"
+	}
+	if order.LimitPrice != nil {
+		orderType += "_LIMIT"
+	}
+	return orderType, map[string]interface{}{
+		"stopPrice": order.TriggerPrice.AsFloat(),
+	}, true
+}
+
 var _ ccxtExchangeSpecificParamFactory = &ccxtExchangeSpecificParamFactoryBinance{}
 
 /****************************** BITSTAMP ******************************/
@@ -160,4 +188,13 @@ func (f *ccxtExchangeSpecificParamFactoryBitstamp) getCursorFetchTrades(t model.
 	return nil, nil
 }
 
+func (f *ccxtExchangeSpecificParamFactoryBitstamp) getClientOrderIDParam() string {
+	return "client_order_id"
+}
+
+func (f *ccxtExchangeSpecificParamFactoryBitstamp) getParamsForProtectiveOrder(order *api.ProtectiveOrder) (string, map[string]interface{}, bool) {
+	// not supported so the protective orders are simulated
+	return "", nil, false
+}
+
 var _ ccxtExchangeSpecificParamFactory = &ccxtExchangeSpecificParamFactoryBitstamp{}
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
 )
 
 func TestBinanceTransformLimit(t *testing.T) {
@@ -86,3 +89,32 @@ func TestBinanceTransformLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestBinanceParamsForProtectiveOrder(t *testing.T) {
+	testCases := []struct {
+		orderType     api.ProtectiveOrderType
+		limitPrice    *model.Number
+		wantOrderType string
+	}{
+		{api.ProtectiveOrderTypeStopLoss, nil, "STOP_LOSS"},
+		{api.ProtectiveOrderTypeStopLoss, model.NumberFromFloat(0.09, 4), "STOP_LOSS_LIMIT"},
+		{api.ProtectiveOrderTypeTakeProfit, nil, "TAKE_PROFIT"},
+		{api.ProtectiveOrderTypeTakeProfit, model.NumberFromFloat(0.13, 4), "TAKE_PROFIT_LIMIT"},
+	}
+
+	f := makeCcxtExchangeSpecificParamFactoryBinance()
+	for _, k := range testCases {
+		t.Run(k.wantOrderType, func(t *testing.T) {
+			orderType, params, ok := f.getParamsForProtectiveOrder(&api.ProtectiveOrder{
+				Type:         k.orderType,
+				OrderAction:  model.OrderActionSell,
+				TriggerPrice: model.NumberFromFloat(0.1, 4),
+				LimitPrice:   k.limitPrice,
+				Volume:       model.NumberFromFloat(100, 2),
+			})
+			assert.True(t, ok)
+			assert.Equal(t, k.wantOrderType, orderType)
+			assert.Equal(t, map[string]interface{}{"stopPrice": 0.1}, params)
+		})
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/stellar/kelp/api"
 	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/sdk"
+	"github.com/stellar/kelp/support/sdk/ccxtfake"
 )
 
 type exchangeAuthData struct {
@@ -54,6 +56,7 @@ func TestGetTickerPrice_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for _, exchangeName := range supportedExchanges {
 		t.Run(exchangeName, func(t *testing.T) {
@@ -92,6 +95,7 @@ func TestGetOrderBook_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for _, exchangeName := range supportedExchanges {
 		for _, obDepth := range []int32{1, 5, 8, 10, 15, 16, 20} {
@@ -135,6 +139,7 @@ func TestGetTrades_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for _, exchangeName := range supportedExchanges {
 		t.Run(exchangeName, func(t *testing.T) {
@@ -169,6 +174,7 @@ func TestGetTradeHistory_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for exchangeName, authData := range supportedTradingExchanges {
 		t.Run(exchangeName, func(t *testing.T) {
@@ -243,6 +249,9 @@ func validateTrades(t *testing.T, pair model.TradingPair, trades []model.Trade)
 func TestGetLatestTradeCursor_Ccxt(t *testing.T) {
 	for exchangeName, authData := range supportedTradingExchanges {
 		t.Run(exchangeName, func(t *testing.T) {
+			_, stop := ccxtfake.StartServer(exchangeName, sdk.GetBaseURL, sdk.SetBaseURL)
+			defer stop()
+
 			testCcxtExchange, e := makeCcxtExchange(
 				exchangeName,
 				testOrderConstraints[exchangeName],
@@ -287,6 +296,7 @@ func TestGetAccountBalances_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for exchangeName, authData := range supportedTradingExchanges {
 		t.Run(exchangeName, func(t *testing.T) {
@@ -334,6 +344,7 @@ func TestGetOpenOrders_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	tradingPairs := []model.TradingPair{
 		{Base: model.XLM, Quote: model.BTC},
@@ -436,6 +447,7 @@ func TestAddOrder_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	for exchangeName, authData := range supportedTradingExchanges {
 		for _, kase := range []struct {
@@ -512,6 +524,7 @@ func TestCancelOrder_Ccxt(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, sdk.GetBaseURL())
 
 	// TODO error converting type and ID for bitstamp
 	for exchangeName, authData := range supportedTradingExchanges {
@@ -587,6 +600,12 @@ func TestGetOrderConstraints_Ccxt_Precision(t *testing.T) {
 		},
 	}
 
+	s, stop := ccxtfake.StartServer("binance", sdk.GetBaseURL, sdk.SetBaseURL)
+	defer stop()
+	s.AddMarket("binance", ccxtfake.Market{Symbol: "XLM/BTC", Base: "XLM", Quote: "BTC", PricePrecision: 8, AmountPrecision: 8})
+	s.AddExchange("bitstamp", 100)
+	s.AddMarket("bitstamp", ccxtfake.Market{Symbol: "XLM/USD", Base: "XLM", Quote: "USD", PricePrecision: 5, AmountPrecision: 8})
+
 	for _, kase := range testCases {
 		t.Run(kase.exchangeName, func(t *testing.T) {
 			testCcxtExchange, e := makeCcxtExchange(
@@ -612,3 +631,215 @@ func TestGetOrderConstraints_Ccxt_Precision(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeAddOrderParams_Ccxt(t *testing.T) {
+	testCases := []struct {
+		exchangeName      string
+		clientOrderID     string
+		submitMode        api.SubmitMode
+		wantParams        interface{}
+		wantClientOrderID string
+	}{
+		{
+			exchangeName:      "binance",
+			clientOrderID:     "abc",
+			submitMode:        api.SubmitModeBoth,
+			wantParams:        map[string]interface{}{"newClientOrderId": "abc"},
+			wantClientOrderID: "abc",
+		}, {
+			exchangeName:      "binance",
+			clientOrderID:     "",
+			submitMode:        api.SubmitModeBoth,
+			wantParams:        nil,
+			wantClientOrderID: "",
+		}, {
+			exchangeName:      "coinbasepro",
+			clientOrderID:     "abc",
+			submitMode:        api.SubmitModeMakerOnly,
+			wantParams:        map[string]interface{}{"post_only": true, "client_oid": "abc"},
+			wantClientOrderID: "abc",
+		}, {
+			exchangeName:      "poloniex",
+			clientOrderID:     "abc",
+			submitMode:        api.SubmitModeBoth,
+			wantParams:        nil,
+			wantClientOrderID: "",
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(fmt.Sprintf("%s/%s", k.exchangeName, k.clientOrderID), func(t *testing.T) {
+			c := ccxtExchange{esParamFactory: getEsParamFactory(k.exchangeName)}
+			params, clientOrderID, e := c.makeAddOrderParams(&model.Order{ClientOrderID: k.clientOrderID}, k.submitMode)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, k.wantParams, params)
+			assert.Equal(t, k.wantClientOrderID, clientOrderID)
+		})
+	}
+}
+
+// makeFakeCcxtExchange starts a fake ccxt-rest server with funded balances on "binance" and makes a ccxtExchange against it,
+// callers should defer the returned function to stop the server and restore the ccxt base URL
+func makeFakeCcxtExchange(t *testing.T) (*ccxtfake.Server, api.Exchange, func()) {
+	s, stop := ccxtfake.StartServer("binance", sdk.GetBaseURL, sdk.SetBaseURL)
+	s.SetBalance("binance", "XLM", 1000)
+	s.SetBalance("binance", "USDT", 100)
+
+	testCcxtExchange, e := makeCcxtExchange(
+		"binance",
+		nil,
+		[]api.ExchangeAPIKey{{Key: "key", Secret: "secret"}},
+		[]api.ExchangeParam{},
+		[]api.ExchangeHeader{},
+		false,
+		getEsParamFactory("binance"),
+	)
+	if !assert.NoError(t, e) {
+		stop()
+		t.FailNow()
+	}
+	return s, testCcxtExchange, stop
+}
+
+func TestFakeCcxt_TradingCycle(t *testing.T) {
+	s, testCcxtExchange, stop := makeFakeCcxtExchange(t)
+	defer stop()
+	pair := model.MakeTradingPair(model.XLM, model.USDT)
+
+	assert.Equal(t, model.MakeOrderConstraintsWithCost(5, 1, 1, 1), testCcxtExchange.GetOrderConstraints(pair))
+
+	txID, e := testCcxtExchange.AddOrder(&model.Order{
+		Pair:          pair,
+		OrderAction:   model.OrderActionSell,
+		OrderType:     model.OrderTypeLimit,
+		Price:         model.NumberFromFloat(0.2, 5),
+		Volume:        model.NumberFromFloat(100, 1),
+		ClientOrderID: "client1",
+	}, api.SubmitModeBoth)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	openOrders, e := testCcxtExchange.GetOpenOrders([]*model.TradingPair{pair})
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(openOrders[*pair])) {
+		o := openOrders[*pair][0]
+		assert.Equal(t, txID.String(), o.ID)
+		assert.Equal(t, "client1", o.ClientOrderID)
+		assert.Equal(t, model.OrderActionSell, o.OrderAction)
+		assert.Equal(t, "0.20000", o.Price.AsString())
+	}
+
+	ob, e := testCcxtExchange.GetOrderBook(pair, 5)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(ob.Asks())) {
+		assert.Equal(t, "100.0", ob.Asks()[0].Volume.AsString())
+	}
+
+	// another participant takes part of our order
+	_, e = s.PlaceOrder("binance", "XLM/USDT", "buy", 0.25, 30)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	tradeHistory, e := testCcxtExchange.GetTradeHistory(*pair, nil, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(tradeHistory.Trades)) {
+		trade := tradeHistory.Trades[0]
+		assert.Equal(t, model.OrderActionSell, trade.OrderAction)
+		assert.Equal(t, txID.String(), trade.OrderID)
+		assert.Equal(t, "client1", trade.ClientOrderID)
+		assert.Equal(t, "0.20000", trade.Price.AsString())
+		assert.Equal(t, "30.0", trade.Volume.AsString())
+		assert.Equal(t, 0.006, trade.Fee.AsFloat())
+	}
+
+	balances, e := testCcxtExchange.GetAccountBalances([]interface{}{model.XLM, model.USDT})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 970.0, balances[model.XLM].AsFloat())
+	assert.Equal(t, 105.994, balances[model.USDT].AsFloat())
+
+	ticker, e := testCcxtExchange.GetTickerPrice([]model.TradingPair{*pair})
+	if assert.Error(t, e, "there are no bids on the book so the ticker is incomplete") {
+		assert.Nil(t, ticker)
+	}
+
+	result, e := testCcxtExchange.CancelOrder(txID, *pair)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, model.CancelResultCancelSuccessful, result)
+
+	openOrders, e = testCcxtExchange.GetOpenOrders([]*model.TradingPair{pair})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, len(openOrders[*pair]))
+}
+
+func TestFakeCcxt_AddOrderReconcilesAfterTransientError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		message     string
+		applied     bool
+		wantOrderID bool
+	}{
+		{
+			name:        "order placed but response lost",
+			message:     "NetworkError: socket hang up",
+			applied:     true,
+			wantOrderID: true,
+		}, {
+			name:        "order not placed",
+			message:     "NetworkError: socket hang up",
+			applied:     false,
+			wantOrderID: false,
+		}, {
+			name:        "rate limited orders are never placed",
+			message:     "DDoSProtection: rate limit exceeded",
+			applied:     false,
+			wantOrderID: false,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			s, testCcxtExchange, stop := makeFakeCcxtExchange(t)
+			defer stop()
+
+			if k.applied {
+				s.FailNextAfterApplying("binance", "createOrder", k.message)
+			} else {
+				s.FailNext("binance", "createOrder", k.message)
+			}
+			txID, e := testCcxtExchange.AddOrder(&model.Order{
+				Pair:          model.MakeTradingPair(model.XLM, model.USDT),
+				OrderAction:   model.OrderActionBuy,
+				OrderType:     model.OrderTypeLimit,
+				Price:         model.NumberFromFloat(0.1, 5),
+				Volume:        model.NumberFromFloat(100, 1),
+				ClientOrderID: "client1",
+			}, api.SubmitModeBoth)
+
+			if k.wantOrderID {
+				if assert.NoError(t, e) && assert.NotNil(t, txID) {
+					assert.NotEqual(t, "", txID.String())
+				}
+			} else {
+				assert.Error(t, e)
+				assert.Nil(t, txID)
+			}
+			assert.Equal(t, 1, s.CallCount("binance", "createOrder"))
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package plugins
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+// EventTimeController triggers updates when the reference price moves, when an order is filled, or when the bot has been idle for too long
+type EventTimeController struct {
+	pollInterval         time.Duration
+	minInterval          time.Duration
+	maxIdleInterval      time.Duration
+	feed                 api.PriceFeed // can be nil
+	priceChangeThreshold float64
+
+	// initialized runtime vars
+	lock *sync.Mutex
+
+	// uninitialized runtime vars
+	referencePrice *float64
+	hasFill        bool
+}
+
+// MakeEventTimeController is a factory method
+//
+// pollInterval is how often we check for events, minInterval is the minimum time between two updates so we don't trigger update storms,
+// and maxIdleInterval is the maximum time between two updates when no events occur (0 disables it).
+// feed is the reference price feed (can be nil) and priceChangeThreshold is the relative move of the feed that triggers an update (0.005 = 0.5%).
+func MakeEventTimeController(
+	pollInterval time.Duration,
+	minInterval time.Duration,
+	maxIdleInterval time.Duration,
+	feed api.PriceFeed,
+	priceChangeThreshold float64,
+) *EventTimeController {
+	return &EventTimeController{
+		pollInterval:         pollInterval,
+		minInterval:          minInterval,
+		maxIdleInterval:      maxIdleInterval,
+		feed:                 feed,
+		priceChangeThreshold: priceChangeThreshold,
+		// initialized runtime vars
+		lock: &sync.Mutex{},
+	}
+}
+
+var _ api.TimeController = &EventTimeController{}
+var _ api.FillHandler = &EventTimeController{}
+
+// HandleFill impl, registering this with the FillTracker triggers an update after an order is filled
+func (t *EventTimeController) HandleFill(trade model.Trade) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.hasFill = true
+	return nil
+}
+
+// ShouldUpdate impl
+func (t *EventTimeController) ShouldUpdate(lastUpdateTime time.Time, currentUpdateTime time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	elapsedSinceUpdate := currentUpdateTime.Sub(lastUpdateTime)
+	if elapsedSinceUpdate < t.minInterval {
+		log.Printf("eventTimeController shouldUpdate=false, elapsedSinceUpdate=%s is less than minInterval=%s\n", elapsedSinceUpdate, t.minInterval)
+		return false
+	}
+
+	reason := ""
+	if t.maxIdleInterval > 0 && elapsedSinceUpdate >= t.maxIdleInterval {
+		reason = "maxIdleInterval exceeded"
+	} else if t.hasFill {
+		reason = "order filled"
+	}
+
+	price, priceMoved := t.checkPrice()
+	if reason == "" && priceMoved {
+		reason = "price moved"
+	}
+
+	if reason == "" {
+		log.Printf("eventTimeController shouldUpdate=false, elapsedSinceUpdate=%s\n", elapsedSinceUpdate)
+		return false
+	}
+
+	// reset the events since we are about to update
+	t.hasFill = false
+	if price != nil {
+		t.referencePrice = price
+	}
+	log.Printf("eventTimeController shouldUpdate=true, reason=%s, elapsedSinceUpdate=%s\n", reason, elapsedSinceUpdate)
+	return true
+}
+
+// checkPrice fetches the price from the feed and returns it along with whether it moved beyond the threshold from the reference price
+func (t *EventTimeController) checkPrice() (*float64, bool) {
+	if t.feed == nil {
+		return nil, false
+	}
+
+	price, e := t.feed.GetPrice()
+	if e != nil {
+		log.Printf("eventTimeController could not fetch price from feed, ignoring price moves in this check: %s\n", e)
+		return nil, false
+	}
+
+	if t.referencePrice == nil || *t.referencePrice == 0 {
+		// the first price we see becomes the reference price
+		t.referencePrice = &price
+		return &price, false
+	}
+
+	priceChange := math.Abs(price-*t.referencePrice) / *t.referencePrice
+	log.Printf("eventTimeController price=%.8f, referencePrice=%.8f, priceChange=%.8f, priceChangeThreshold=%.8f\n", price, *t.referencePrice, priceChange, t.priceChangeThreshold)
+	return &price, priceChange >= t.priceChangeThreshold
+}
+
+// SleepTime impl
+func (t *EventTimeController) SleepTime(lastUpdateTime time.Time) time.Duration {
+	return t.sleepTimeInternal(lastUpdateTime, time.Now())
+}
+
+// realNow is the actual current time, we never wake up before minInterval has elapsed since there is no point in checking for events until then
+func (t *EventTimeController) sleepTimeInternal(lastUpdateTime time.Time, realNow time.Time) time.Duration {
+	minIntervalCatchup := t.minInterval - realNow.Sub(lastUpdateTime)
+	if minIntervalCatchup > t.pollInterval {
+		return minIntervalCatchup
+	}
+	return t.pollInterval
+}
@@ -0,0 +1,137 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/model"
+)
+
+// sequenceFeed returns the next price in the sequence on every call, repeating the last price once exhausted
+type sequenceFeed struct {
+	prices []float64
+	index  int
+}
+
+func (f *sequenceFeed) GetPrice() (float64, error) {
+	if len(f.prices) == 0 {
+		return 0, fmt.Errorf("no prices")
+	}
+
+	p := f.prices[f.index]
+	if f.index < len(f.prices)-1 {
+		f.index++
+	}
+	return p, nil
+}
+
+func TestEventShouldUpdate(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		prices                 []float64
+		fill                   bool
+		millisSinceLastUpdates []int64
+		wantShouldUpdates      []bool
+	}{
+		{
+			name:                   "below min interval",
+			prices:                 []float64{1.0, 2.0},
+			fill:                   true,
+			millisSinceLastUpdates: []int64{999},
+			wantShouldUpdates:      []bool{false},
+		}, {
+			name:                   "no events",
+			prices:                 []float64{1.0, 1.004, 0.996},
+			millisSinceLastUpdates: []int64{1000, 2000, 3000},
+			wantShouldUpdates:      []bool{false, false, false},
+		}, {
+			name:                   "price moves up",
+			prices:                 []float64{1.0, 1.0051},
+			millisSinceLastUpdates: []int64{1000, 2000},
+			wantShouldUpdates:      []bool{false, true},
+		}, {
+			name:                   "price moves down",
+			prices:                 []float64{1.0, 0.9949},
+			millisSinceLastUpdates: []int64{1000, 2000},
+			wantShouldUpdates:      []bool{false, true},
+		}, {
+			name:                   "reference price resets after update",
+			prices:                 []float64{1.0, 1.0051, 1.006, 1.0102},
+			millisSinceLastUpdates: []int64{1000, 2000, 3000, 4000},
+			wantShouldUpdates:      []bool{false, true, false, true},
+		}, {
+			name:                   "fill",
+			prices:                 []float64{1.0},
+			fill:                   true,
+			millisSinceLastUpdates: []int64{1000, 2000},
+			wantShouldUpdates:      []bool{true, false},
+		}, {
+			name:                   "max idle",
+			prices:                 []float64{1.0},
+			millisSinceLastUpdates: []int64{59999, 60000},
+			wantShouldUpdates:      []bool{false, true},
+		}, {
+			name:                   "feed error",
+			prices:                 []float64{},
+			millisSinceLastUpdates: []int64{1000, 60000},
+			wantShouldUpdates:      []bool{false, true},
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			tc := MakeEventTimeController(time.Second, time.Second, time.Minute, &sequenceFeed{prices: k.prices}, 0.005)
+			if k.fill {
+				e := tc.HandleFill(model.Trade{})
+				if !assert.NoError(t, e) {
+					return
+				}
+			}
+
+			lastUpdateTime, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+			for i, millis := range k.millisSinceLastUpdates {
+				currentUpdateTime := lastUpdateTime.Add(time.Millisecond * time.Duration(millis))
+				assert.Equal(t, k.wantShouldUpdates[i], tc.ShouldUpdate(lastUpdateTime, currentUpdateTime), fmt.Sprintf("check at index %d", i))
+			}
+		})
+	}
+}
+
+func TestEventSleepTime(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		millisSinceLastUpdate int64
+		wantDuration          time.Duration
+	}{
+		{
+			name:                  "no time diff",
+			millisSinceLastUpdate: 0,
+			wantDuration:          5 * time.Second,
+		}, {
+			name:                  "inside min interval",
+			millisSinceLastUpdate: 4000,
+			wantDuration:          1 * time.Second,
+		}, {
+			name:                  "min interval elapsed",
+			millisSinceLastUpdate: 4500,
+			wantDuration:          500 * time.Millisecond,
+		}, {
+			name:                  "past min interval",
+			millisSinceLastUpdate: 15000,
+			wantDuration:          500 * time.Millisecond,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			tc := MakeEventTimeController(500*time.Millisecond, 5*time.Second, time.Minute, nil, 0.005)
+
+			lastUpdateTime, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+			realNow := lastUpdateTime.Add(time.Millisecond * time.Duration(k.millisSinceLastUpdate))
+			assert.Equal(t, k.wantDuration, tc.sleepTimeInternal(lastUpdateTime, realNow))
+		})
+	}
+}
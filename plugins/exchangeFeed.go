@@ -20,8 +20,9 @@ type exchangeFeed struct {
 var _ api.PriceFeed = &exchangeFeed{}
 
 func newExchangeFeed(name string, tickerAPI *api.TickerAPI, pair *model.TradingPair, modifier string) (*exchangeFeed, error) {
-	if modifier != "mid" && modifier != "ask" && modifier != "bid" && modifier != "last" {
-		return nil, fmt.Errorf("unsupported modifier '%s' on exchange type URL", modifier)
+	e := checkExchangeFeedModifier(modifier)
+	if e != nil {
+		return nil, e
 	}
 
 	return &exchangeFeed{
@@ -32,6 +33,13 @@ func newExchangeFeed(name string, tickerAPI *api.TickerAPI, pair *model.TradingP
 	}, nil
 }
 
+func checkExchangeFeedModifier(modifier string) error {
+	if modifier != "mid" && modifier != "ask" && modifier != "bid" && modifier != "last" {
+		return fmt.Errorf("unsupported modifier '%s' on exchange type URL", modifier)
+	}
+	return nil
+}
+
 // GetPrice impl
 func (f *exchangeFeed) GetPrice() (float64, error) {
 	tickerAPI := *f.tickerAPI
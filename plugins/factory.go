@@ -27,7 +27,9 @@ type strategyFactoryData struct {
 	simMode         bool
 	isTradingSdex   bool
 	filterFactory   *FilterFactory
+	resolveSecret   utils.SecretResolver
 	db              *sql.DB
+	alert           api.Alert
 }
 
 // StrategyContainer contains the strategy factory method along with some metadata
@@ -54,8 +56,10 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Beginner",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg BuySellConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			s, e := makeBuySellStrategy(strategyFactoryData.sdex, strategyFactoryData.tradingPair, strategyFactoryData.ieif, strategyFactoryData.assetBase, strategyFactoryData.assetQuote, &cfg)
 			if e != nil {
@@ -71,10 +75,16 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Advanced",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg mirrorConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
+			err = cfg.ExchangeAPIKeys.ResolveSecrets(strategyFactoryData.resolveSecret)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
-			s, e := makeMirrorStrategy(strategyFactoryData.sdex, strategyFactoryData.ieif, strategyFactoryData.tradingPair, strategyFactoryData.assetBase, strategyFactoryData.assetQuote, strategyFactoryData.marketID, &cfg, strategyFactoryData.db, strategyFactoryData.simMode)
+			s, e := makeMirrorStrategy(strategyFactoryData.sdex, strategyFactoryData.ieif, strategyFactoryData.tradingPair, strategyFactoryData.assetBase, strategyFactoryData.assetQuote, strategyFactoryData.marketID, &cfg, strategyFactoryData.db, strategyFactoryData.alert, strategyFactoryData.simMode)
 			if e != nil {
 				return nil, fmt.Errorf("makeFn failed: %s", e)
 			}
@@ -88,8 +98,10 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Beginner",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg sellConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			s, e := makeSellStrategy(strategyFactoryData.sdex, strategyFactoryData.tradingPair, strategyFactoryData.ieif, strategyFactoryData.assetBase, strategyFactoryData.assetQuote, &cfg)
 			if e != nil {
@@ -105,8 +117,10 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Intermediate",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg balancedConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			return makeBalancedStrategy(strategyFactoryData.sdex, strategyFactoryData.tradingPair, strategyFactoryData.ieif, strategyFactoryData.assetBase, strategyFactoryData.assetQuote, &cfg), nil
 		},
@@ -127,8 +141,10 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Beginner",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg pendulumConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			return makePendulumStrategy(
 				strategyFactoryData.sdex,
@@ -150,8 +166,10 @@ var strategies = map[string]StrategyContainer{
 		Complexity:  "Intermediate",
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			var cfg sellTwapConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			s, e := makeSellTwapStrategy(
 				strategyFactoryData.sdex,
@@ -176,8 +194,10 @@ var strategies = map[string]StrategyContainer{
 		makeFn: func(strategyFactoryData strategyFactoryData) (api.Strategy, error) {
 			// reuse the sellTwapConfig struct since we need the same info for buyTwap
 			var cfg sellTwapConfig
-			err := config.Read(strategyFactoryData.stratConfigPath, &cfg)
-			utils.CheckConfigError(cfg, err, strategyFactoryData.stratConfigPath)
+			err := readStrategyConfig(strategyFactoryData.stratConfigPath, &cfg)
+			if err != nil {
+				return nil, fmt.Errorf("makeFn failed: %s", err)
+			}
 			utils.LogConfig(cfg)
 			s, e := makeBuyTwapStrategy(
 				strategyFactoryData.sdex,
@@ -212,6 +232,8 @@ func MakeStrategy(
 	isTradingSdex bool,
 	filterFactory *FilterFactory,
 	db *sql.DB,
+	alert api.Alert,
+	resolveSecret utils.SecretResolver,
 ) (api.Strategy, error) {
 	log.Printf("Making strategy: %s\n", strategy)
 	if s, ok := strategies[strategy]; ok {
@@ -233,6 +255,8 @@ func MakeStrategy(
 			isTradingSdex:   isTradingSdex,
 			filterFactory:   filterFactory,
 			db:              db,
+			alert:           alert,
+			resolveSecret:   resolveSecret,
 		})
 		if e != nil {
 			return nil, fmt.Errorf("cannot make '%s' strategy: %s", strategy, e)
@@ -243,6 +267,22 @@ func MakeStrategy(
 	return nil, fmt.Errorf("invalid strategy type: %s", strategy)
 }
 
+// readStrategyConfig reads the strategy config file and expands references to environment variables in it
+func readStrategyConfig(stratConfigPath string, cfg fmt.Stringer) error {
+	e := config.Read(stratConfigPath, cfg)
+	if e != nil && offlineValidation {
+		// report the error instead of exiting so all errors in the configs are reported together
+		return fmt.Errorf("could not parse the strategy config file '%s', check that the correct type of file was passed in: %s", stratConfigPath, e)
+	}
+	utils.CheckConfigError(cfg, e, stratConfigPath)
+
+	e = utils.ExpandEnvRefs(cfg)
+	if e != nil {
+		return fmt.Errorf("could not expand environment variables in strategy config file '%s': %s", stratConfigPath, e)
+	}
+	return nil
+}
+
 // Strategies returns the list of strategies along with metadata
 func Strategies() map[string]StrategyContainer {
 	return strategies
@@ -264,6 +304,7 @@ type ExchangeContainer struct {
 	Tested          bool
 	AtomicPostOnly  bool
 	TradeHasOrderId bool
+	LimitOrdersOnly bool
 	makeFn          func(exchangeFactoryData exchangeFactoryData) (api.Exchange, error)
 }
 
@@ -278,6 +319,33 @@ func getExchanges() map[string]ExchangeContainer {
 	return *exchanges
 }
 
+// makeNativeExchanges returns the exchange integrations that are not added via ccxt-rest
+func makeNativeExchanges() map[string]ExchangeContainer {
+	return map[string]ExchangeContainer{
+		"kraken": {
+			SortOrder:    0,
+			Description:  "Kraken is a popular centralized cryptocurrency exchange",
+			TradeEnabled: true,
+			Tested:       true,
+			makeFn: func(exchangeFactoryData exchangeFactoryData) (api.Exchange, error) {
+				return makeKrakenExchange(exchangeFactoryData.apiKeys, exchangeFactoryData.exchangeParams, exchangeFactoryData.simMode)
+			},
+		},
+		"binance": {
+			SortOrder:       1,
+			Description:     "Binance is a popular centralized cryptocurrency exchange, this integration does not need ccxt-rest",
+			TradeEnabled:    true,
+			Tested:          false,
+			AtomicPostOnly:  true,
+			TradeHasOrderId: true,
+			LimitOrdersOnly: true,
+			makeFn: func(exchangeFactoryData exchangeFactoryData) (api.Exchange, error) {
+				return makeBinanceExchange(exchangeFactoryData.apiKeys, exchangeFactoryData.exchangeParams, exchangeFactoryData.simMode)
+			},
+		},
+	}
+}
+
 func loadExchanges() {
 	// marked as tested if key exists in this map (regardless of bool value)
 	testedCcxtExchanges := map[string]bool{
@@ -298,17 +366,8 @@ func loadExchanges() {
 		"binance": true,
 	}
 
-	exchanges = &map[string]ExchangeContainer{
-		"kraken": {
-			SortOrder:    0,
-			Description:  "Kraken is a popular centralized cryptocurrency exchange",
-			TradeEnabled: true,
-			Tested:       true,
-			makeFn: func(exchangeFactoryData exchangeFactoryData) (api.Exchange, error) {
-				return makeKrakenExchange(exchangeFactoryData.apiKeys, exchangeFactoryData.simMode)
-			},
-		},
-	}
+	nativeExchanges := makeNativeExchanges()
+	exchanges = &nativeExchanges
 
 	// add all CCXT exchanges (tested exchanges first)
 	sortOrderIndex := len(*exchanges)
@@ -396,3 +455,10 @@ func MakeTradingExchange(exchangeType string, apiKeys []api.ExchangeAPIKey, exch
 func Exchanges() map[string]ExchangeContainer {
 	return getExchanges()
 }
+
+// IsLimitOrdersOnly returns true when the exchange integration rejects orders that are not limit orders. Only the native integrations set
+// LimitOrdersOnly so this does not load the ccxt exchanges, which allows it to be used when validating the config before the ccxt URL is set
+func IsLimitOrdersOnly(exchangeType string) bool {
+	x, ok := makeNativeExchanges()[exchangeType]
+	return ok && x.LimitOrdersOnly
+}
@@ -201,6 +201,7 @@ func (f *FillDBWriter) HandleFill(trade model.Trade) error {
 		f.checkedFloat(trade.Fee),
 		f.accountID,
 		trade.OrderID,
+		trade.ClientOrderID,
 	)
 	_, e = f.db.Exec(sqlInsert)
 	if e != nil {
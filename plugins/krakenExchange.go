@@ -20,6 +20,9 @@ import (
 // ensure that krakenExchange conforms to the Exchange interface
 var _ api.Exchange = &krakenExchange{}
 
+// ensure that krakenExchange can look up its withdrawals
+var _ api.WithdrawStatusAPI = &krakenExchange{}
+
 const precisionBalances = 10
 const tradesFetchSleepTimeSeconds = 60
 
@@ -51,13 +54,21 @@ func (m asset2Address2Key) getKey(asset model.Asset, address string) (string, er
 	return key, nil
 }
 
+// krakenWithdrawKeyParamPrefix is the prefix of the exchange params that register withdrawal keys, the param is of the form
+// "withdraw_key:<ASSET>:<address>" and the value is the name of the withdrawal key set up for that address on kraken
+const krakenWithdrawKeyParamPrefix = "withdraw_key:"
+
 // makeKrakenExchange is a factory method to make the kraken exchange
-// TODO 2, should take in config file for withdrawalKeys mapping
-func makeKrakenExchange(apiKeys []api.ExchangeAPIKey, isSimulated bool) (api.Exchange, error) {
+func makeKrakenExchange(apiKeys []api.ExchangeAPIKey, exchangeParams []api.ExchangeParam, isSimulated bool) (api.Exchange, error) {
 	if len(apiKeys) == 0 || len(apiKeys) > math.MaxUint8 {
 		return nil, fmt.Errorf("invalid number of apiKeys: %d", len(apiKeys))
 	}
 
+	withdrawKeys, e := parseKrakenWithdrawKeys(exchangeParams)
+	if e != nil {
+		return nil, e
+	}
+
 	krakenAPIs := []*krakenapi.KrakenApi{}
 	for _, apiKey := range apiKeys {
 		krakenAPIClient := krakenapi.New(apiKey.Key, apiKey.Secret)
@@ -71,11 +82,37 @@ func makeKrakenExchange(apiKeys []api.ExchangeAPIKey, isSimulated bool) (api.Exc
 		apiNextIndex:             0,
 		delimiter:                "",
 		ocOverridesHandler:       MakeEmptyOrderConstraintsOverridesHandler(),
-		withdrawKeys:             asset2Address2Key{},
+		withdrawKeys:             withdrawKeys,
 		isSimulated:              isSimulated,
 	}, nil
 }
 
+// parseKrakenWithdrawKeys reads the withdrawal keys from the exchange params, other params are ignored
+func parseKrakenWithdrawKeys(exchangeParams []api.ExchangeParam) (asset2Address2Key, error) {
+	withdrawKeys := asset2Address2Key{}
+	for _, p := range exchangeParams {
+		if !strings.HasPrefix(p.Param, krakenWithdrawKeyParamPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(p.Param, krakenWithdrawKeyParamPrefix), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid withdraw key param '%s', needs to be of the form '%s<ASSET>:<address>'", p.Param, krakenWithdrawKeyParamPrefix)
+		}
+		key, ok := p.Value.(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("the value of withdraw key param '%s' needs to be the name of the withdrawal key on kraken", p.Param)
+		}
+
+		asset := model.Asset(parts[0])
+		if _, ok := withdrawKeys[asset]; !ok {
+			withdrawKeys[asset] = map[string]string{}
+		}
+		withdrawKeys[asset][parts[1]] = key
+	}
+	return withdrawKeys, nil
+}
+
 // nextAPI rotates the API key being used so we can overcome rate limit issues
 func (k *krakenExchange) nextAPI() *krakenapi.KrakenApi {
 	log.Printf("returning kraken API key at index %d", k.apiNextIndex)
@@ -732,6 +769,7 @@ func (k *krakenExchange) PrepareDeposit(asset model.Asset, amount *model.Number)
 			return &api.PrepareDepositResult{
 				Fee:      dm.fee,
 				Address:  earliestAddress.address,
+				Memo:     earliestAddress.memo,
 				ExpireTs: earliestAddress.expireTs,
 			}, nil
 		}
@@ -787,6 +825,7 @@ func (k *krakenExchange) getDepositMethods(asset string) (*depositMethod, error)
 
 type depositAddress struct {
 	address  string
+	memo     string
 	expireTs int64
 	isNew    bool
 }
@@ -833,6 +872,15 @@ func parseDepositAddress(m map[string]interface{}) (*depositAddress, error) {
 		return nil, e
 	}
 
+	// memo is only returned for assets that need one, such as XLM
+	memo, e := networking.ParseString(m, "memo", "DepositAddresses")
+	if e != nil {
+		if !strings.HasPrefix(e.Error(), networking.PrefixFieldNotFound) {
+			return nil, e
+		}
+		memo = ""
+	}
+
 	// expiretm
 	expireN, e := networking.ParseNumber(m, "expiretm", "DepositAddresses")
 	if e != nil {
@@ -852,6 +900,7 @@ func parseDepositAddress(m map[string]interface{}) (*depositAddress, error) {
 
 	return &depositAddress{
 		address:  address,
+		memo:     memo,
 		expireTs: expireTs,
 		isNew:    isNew,
 	}, nil
@@ -949,6 +998,62 @@ func parseWithdrawResponse(resp interface{}) (*api.WithdrawFunds, error) {
 	}
 }
 
+// GetWithdrawalStatus impl.
+func (k *krakenExchange) GetWithdrawalStatus(asset model.Asset, withdrawalID string) (api.WithdrawalStatus, error) {
+	krakenAsset, e := k.assetConverter.ToString(asset)
+	if e != nil {
+		return "", e
+	}
+
+	resp, e := k.nextAPI().Query(
+		"WithdrawStatus",
+		map[string]string{"asset": krakenAsset},
+	)
+	if e != nil {
+		return "", e
+	}
+	return parseWithdrawStatusResponse(resp, withdrawalID)
+}
+
+// parseWithdrawStatusResponse finds the withdrawal with the refid in the recent withdrawals returned by WithdrawStatus
+func parseWithdrawStatusResponse(resp interface{}, refid string) (api.WithdrawalStatus, error) {
+	arr, ok := resp.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("could not parse response type from WithdrawStatus: %s", reflect.TypeOf(resp))
+	}
+
+	for _, elem := range arr {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("could not parse inner response type of returned []interface{} from WithdrawStatus: %s", reflect.TypeOf(elem))
+		}
+		elemRefid, e := networking.ParseString(m, "refid", "WithdrawStatus")
+		if e != nil {
+			return "", e
+		}
+		if elemRefid != refid {
+			continue
+		}
+
+		status, e := networking.ParseString(m, "status", "WithdrawStatus")
+		if e != nil {
+			return "", e
+		}
+		switch status {
+		case "Success":
+			return api.WithdrawalStatusSent, nil
+		case "Failure":
+			return api.WithdrawalStatusFailed, nil
+		}
+		// a withdrawal that was canceled on request has the status-prop "canceled"
+		if prop, ok := m["status-prop"].(string); ok && prop == "canceled" {
+			return api.WithdrawalStatusFailed, nil
+		}
+		return api.WithdrawalStatusPending, nil
+	}
+	return api.WithdrawalStatusPending, nil
+}
+
 // krakenPrecisionMatrix describes the price and volume precision and min base volume for each trading pair
 // taken from this URL: https://support.kraken.com/hc/en-us/articles/360001389366-Price-and-volume-decimal-precision
 var krakenPrecisionMatrix = map[model.TradingPair]model.OrderConstraints{
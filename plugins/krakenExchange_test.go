@@ -492,3 +492,105 @@ func TestWithdrawFunds(t *testing.T) {
 	fmt.Printf("refid=%v\n", result.WithdrawalID)
 	assert.Fail(t, "force fail")
 }
+
+func TestParseKrakenWithdrawKeys(t *testing.T) {
+	testCases := []struct {
+		name      string
+		params    []api.ExchangeParam
+		wantKeys  asset2Address2Key
+		wantError bool
+	}{
+		{
+			name: "withdraw keys and other params",
+			params: []api.ExchangeParam{
+				{Param: "withdraw_key:XLM:GADDRESS1", Value: "stellar wallet"},
+				{Param: "withdraw_key:XLM:GADDRESS2", Value: "other wallet"},
+				{Param: "withdraw_key:BTC:1BitcoinAddress", Value: "btc wallet"},
+				{Param: "trading_agreement", Value: "agree"},
+			},
+			wantKeys: asset2Address2Key{
+				model.XLM: {"GADDRESS1": "stellar wallet", "GADDRESS2": "other wallet"},
+				model.BTC: {"1BitcoinAddress": "btc wallet"},
+			},
+		}, {
+			name:     "no params",
+			params:   []api.ExchangeParam{},
+			wantKeys: asset2Address2Key{},
+		}, {
+			name:      "missing address",
+			params:    []api.ExchangeParam{{Param: "withdraw_key:XLM", Value: "stellar wallet"}},
+			wantError: true,
+		}, {
+			name:      "value is not a key name",
+			params:    []api.ExchangeParam{{Param: "withdraw_key:XLM:GADDRESS1", Value: 1.0}},
+			wantError: true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			withdrawKeys, e := parseKrakenWithdrawKeys(k.params)
+			if k.wantError {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) {
+				assert.Equal(t, k.wantKeys, withdrawKeys)
+			}
+		})
+	}
+}
+
+func TestParseWithdrawStatusResponse(t *testing.T) {
+	withdrawal := func(refid string, status string) map[string]interface{} {
+		return map[string]interface{}{"method": "Stellar XLM", "asset": "XXLM", "refid": refid, "amount": "10.0000000", "status": status}
+	}
+	canceled := withdrawal("AGBZNBO-5P2XSB-RFVF6J", "Pending")
+	canceled["status-prop"] = "canceled"
+
+	testCases := []struct {
+		name       string
+		resp       interface{}
+		wantStatus api.WithdrawalStatus
+		wantError  bool
+	}{
+		{
+			name:       "success",
+			resp:       []interface{}{withdrawal("OTHER", "Failure"), withdrawal("AGBZNBO-5P2XSB-RFVF6J", "Success")},
+			wantStatus: api.WithdrawalStatusSent,
+		}, {
+			name:       "failure",
+			resp:       []interface{}{withdrawal("AGBZNBO-5P2XSB-RFVF6J", "Failure")},
+			wantStatus: api.WithdrawalStatusFailed,
+		}, {
+			name:       "canceled",
+			resp:       []interface{}{canceled},
+			wantStatus: api.WithdrawalStatusFailed,
+		}, {
+			name:       "in progress",
+			resp:       []interface{}{withdrawal("AGBZNBO-5P2XSB-RFVF6J", "Settled")},
+			wantStatus: api.WithdrawalStatusPending,
+		}, {
+			name:       "not listed yet",
+			resp:       []interface{}{withdrawal("OTHER", "Success")},
+			wantStatus: api.WithdrawalStatusPending,
+		}, {
+			name:      "not a list",
+			resp:      map[string]interface{}{},
+			wantError: true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			status, e := parseWithdrawStatusResponse(k.resp, "AGBZNBO-5P2XSB-RFVF6J")
+			if k.wantError {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) {
+				assert.Equal(t, k.wantStatus, status)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/stellar/kelp/support/networking"
@@ -193,11 +194,45 @@ type eventWrapper struct {
 // UpdateLoopResult contains the results of the orderbook update.
 // Note that this is used in `trader/trader.go`, but it is defined here to avoid an import cycle.
 type UpdateLoopResult struct {
-	Success            bool
-	NumPruneOps        int
-	NumUpdateOpsDelete int
-	NumUpdateOpsUpdate int
-	NumUpdateOpsCreate int
+	Success            bool `json:"success"`
+	NumPruneOps        int  `json:"num_prune_ops"`
+	NumUpdateOpsDelete int  `json:"num_update_ops_delete"`
+	NumUpdateOpsUpdate int  `json:"num_update_ops_update"`
+	NumUpdateOpsCreate int  `json:"num_update_ops_create"`
+}
+
+// updateLoopSummaryLogPrefix marks the log line written after every update loop, which the GUI backend parses from the bot's output
+const updateLoopSummaryLogPrefix = "update loop summary: "
+
+// UpdateLoopSummary is the summary of an update loop that is written to the log
+type UpdateLoopSummary struct {
+	UpdateLoopResult
+	StartTime       time.Time `json:"start_time"`
+	MillisForUpdate int64     `json:"millis_for_update"`
+}
+
+// MakeUpdateLoopSummaryLogLine converts the summary to a log line that can be parsed with ParseUpdateLoopSummaryLogLine
+func MakeUpdateLoopSummaryLogLine(summary UpdateLoopSummary) (string, error) {
+	summaryBytes, e := json.Marshal(summary)
+	if e != nil {
+		return "", fmt.Errorf("could not marshal update loop summary: %s", e)
+	}
+	return updateLoopSummaryLogPrefix + string(summaryBytes), nil
+}
+
+// ParseUpdateLoopSummaryLogLine returns the summary in the log line, or false if the log line does not contain a summary
+func ParseUpdateLoopSummaryLogLine(line string) (*UpdateLoopSummary, bool) {
+	i := strings.Index(line, updateLoopSummaryLogPrefix)
+	if i < 0 {
+		return nil, false
+	}
+
+	var summary UpdateLoopSummary
+	e := json.Unmarshal([]byte(line[i+len(updateLoopSummaryLogPrefix):]), &summary)
+	if e != nil {
+		return nil, false
+	}
+	return &summary, true
 }
 
 // response structure taken from here: https://help.amplitude.com/hc/en-us/articles/360032842391-HTTP-API-V2#tocSsuccesssummary
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nikhilsaraf/go-tools/multithreading"
 
@@ -52,6 +53,43 @@ type mirrorConfig struct {
 	ExchangeAPIKeys                           toml.ExchangeAPIKeysToml `valid:"-" toml:"EXCHANGE_API_KEYS"`
 	ExchangeParams                            toml.ExchangeParamsToml  `valid:"-" toml:"EXCHANGE_PARAMS"`
 	ExchangeHeaders                           toml.ExchangeHeadersToml `valid:"-" toml:"EXCHANGE_HEADERS"`
+	Rebalance                                 *rebalanceConfig         `valid:"-" toml:"REBALANCE"`
+}
+
+// rebalanceConfig contains the configuration params for moving inventory between SDEX and the backing exchange
+type rebalanceConfig struct {
+	CheckIntervalSeconds  int                  `valid:"-" toml:"CHECK_INTERVAL_SECONDS"`
+	ConfirmTimeoutSeconds int                  `valid:"-" toml:"CONFIRM_TIMEOUT_SECONDS"`
+	Base                  *rebalanceRuleConfig `valid:"-" toml:"BASE"`
+	Quote                 *rebalanceRuleConfig `valid:"-" toml:"QUOTE"`
+}
+
+// rebalanceRuleConfig contains the configuration params for rebalancing a single asset
+type rebalanceRuleConfig struct {
+	TargetPrimary   float64  `valid:"-" toml:"TARGET_PRIMARY"`
+	Threshold       float64  `valid:"-" toml:"THRESHOLD"`
+	MinTransfer     float64  `valid:"-" toml:"MIN_TRANSFER"`
+	MaxTransfer     *float64 `valid:"-" toml:"MAX_TRANSFER"`
+	MaxFeeFraction  float64  `valid:"-" toml:"MAX_FEE_FRACTION"`
+	ConfirmFraction float64  `valid:"-" toml:"CONFIRM_FRACTION"`
+}
+
+// toRule converts the config to a RebalanceRule, amounts are converted using the passed in precision
+func (c *rebalanceRuleConfig) toRule(primaryAsset model.Asset, backingAsset model.Asset, precision int8) RebalanceRule {
+	var maxTransfer *model.Number
+	if c.MaxTransfer != nil {
+		maxTransfer = model.NumberFromFloat(*c.MaxTransfer, precision)
+	}
+	return RebalanceRule{
+		PrimaryAsset:    primaryAsset,
+		BackingAsset:    backingAsset,
+		TargetPrimary:   c.TargetPrimary,
+		Threshold:       c.Threshold,
+		MinTransfer:     model.NumberFromFloat(c.MinTransfer, precision),
+		MaxTransfer:     maxTransfer,
+		MaxFeeFraction:  c.MaxFeeFraction,
+		ConfirmFraction: c.ConfirmFraction,
+	}
 }
 
 // String impl.
@@ -101,6 +139,7 @@ type mirrorStrategy struct {
 	mutex                                 *sync.Mutex
 	baseSurplus                           map[model.OrderAction]*assetSurplus // baseSurplus keeps track of any surplus we have of the base asset that needs to be offset on the backing exchange
 	db                                    *sql.DB
+	rebalancer                            *Rebalancer // nil when rebalancing is not configured
 
 	// uninitialized
 	sellOnPrimaryBalanceCoordinator *balanceCoordinator
@@ -147,6 +186,7 @@ func makeMirrorStrategy(
 	marketID string,
 	config *mirrorConfig,
 	db *sql.DB,
+	alert api.Alert,
 	simMode bool,
 ) (api.Strategy, error) {
 	convertDeprecatedMirrorConfigValues(config)
@@ -175,6 +215,22 @@ func makeMirrorStrategy(
 		return nil, fmt.Errorf("invalid mirror strategy config file, ASK_VOLUME_DIVIDE_BY needs to be -1.0 or > 0")
 	}
 
+	if config.Rebalance != nil {
+		if config.Rebalance.CheckIntervalSeconds <= 0 {
+			return nil, fmt.Errorf("invalid mirror strategy config file, REBALANCE.CHECK_INTERVAL_SECONDS needs to be > 0")
+		}
+		if config.Rebalance.ConfirmTimeoutSeconds <= 0 {
+			return nil, fmt.Errorf("invalid mirror strategy config file, REBALANCE.CONFIRM_TIMEOUT_SECONDS needs to be > 0")
+		}
+		if config.Rebalance.Base == nil && config.Rebalance.Quote == nil {
+			return nil, fmt.Errorf("invalid mirror strategy config file, need to set at least one of REBALANCE.BASE or REBALANCE.QUOTE when REBALANCE is set")
+		}
+		if db == nil {
+			utils.PrintErrorHintf("need to set the POSTGRES_DB config in the trader config file when REBALANCE is set so pending transfers are not repeated after a restart")
+			return nil, fmt.Errorf("db should not be nil when REBALANCE is set")
+		}
+	}
+
 	var exchange api.Exchange
 	var e error
 	var strategyMirrorTradeTriggerExistsQuery *queries.StrategyMirrorTradeTriggerExists
@@ -183,10 +239,11 @@ func makeMirrorStrategy(
 			return nil, fmt.Errorf("db should not be nil when OffsetTrades is enabled")
 		}
 
-		exchangeAPIKeys := config.ExchangeAPIKeys.ToExchangeAPIKeys()
-		exchangeParams := config.ExchangeParams.ToExchangeParams()
-		exchangeHeaders := config.ExchangeHeaders.ToExchangeHeaders()
-		exchange, e = MakeTradingExchange(config.Exchange, exchangeAPIKeys, exchangeParams, exchangeHeaders, simMode)
+		if offlineValidation {
+			e = CheckExchangeTypeOffline(config.Exchange, true)
+		} else {
+			exchange, e = makeMirrorTradingExchange(config, simMode)
+		}
 		if e != nil {
 			return nil, e
 		}
@@ -212,6 +269,17 @@ func makeMirrorStrategy(
 		if e != nil {
 			return nil, fmt.Errorf("unable to create strategyMirrorTradeTriggerExistsQuery: %s", e)
 		}
+	} else if offlineValidation {
+		e = CheckExchangeTypeOffline(config.Exchange, config.Rebalance != nil)
+		if e != nil {
+			return nil, e
+		}
+	} else if config.Rebalance != nil {
+		// rebalancing deposits to and withdraws from the backing exchange so we need a trading exchange even when not offsetting trades
+		exchange, e = makeMirrorTradingExchange(config, simMode)
+		if e != nil {
+			return nil, e
+		}
 	} else {
 		exchange, e = MakeExchange(config.Exchange, simMode)
 		if e != nil {
@@ -219,6 +287,11 @@ func makeMirrorStrategy(
 		}
 	}
 
+	if offlineValidation {
+		// the rest of the setup needs the backing exchange which is not available offline
+		return nil, nil
+	}
+
 	// we have two sets of (tradingPair, orderConstraints): the primaryExchange and the backingExchange
 	primaryConstraints := sdex.GetOrderConstraints(pair)
 	// backingPair is taken from the mirror strategy config not from the passed in trading pair
@@ -318,6 +391,32 @@ func makeMirrorStrategy(
 		return nil, fmt.Errorf("cannot construct the mirrorStrategy, ORDERBOOK_DEPTH config param should not exceed %d", maxOrderbookDepth)
 	}
 
+	var rebalancer *Rebalancer
+	if config.Rebalance != nil && simMode {
+		log.Printf("not rebalancing inventory between SDEX and the backing exchange because we are in simulation mode\n")
+	} else if config.Rebalance != nil {
+		rules := []RebalanceRule{}
+		if config.Rebalance.Base != nil {
+			rules = append(rules, config.Rebalance.Base.toRule(pair.Base, backingPair.Base, primaryConstraints.VolumePrecision))
+		}
+		if config.Rebalance.Quote != nil {
+			rules = append(rules, config.Rebalance.Quote.toRule(pair.Quote, backingPair.Quote, primaryConstraints.VolumePrecision))
+		}
+		rebalancer, e = MakeRebalancer(
+			sdex,
+			exchange,
+			rules,
+			time.Duration(config.Rebalance.CheckIntervalSeconds)*time.Second,
+			time.Duration(config.Rebalance.ConfirmTimeoutSeconds)*time.Second,
+			makeDbTransferStore(db, marketID),
+			alert,
+		)
+		if e != nil {
+			return nil, fmt.Errorf("invalid REBALANCE config in mirror strategy config file: %s", e)
+		}
+		log.Printf("rebalancing inventory between SDEX and the backing exchange with %d rules\n", len(rules))
+	}
+
 	return &mirrorStrategy{
 		sdex:                                  sdex,
 		ieif:                                  ieif,
@@ -342,10 +441,19 @@ func makeMirrorStrategy(
 			model.OrderActionBuy:  makeAssetSurplus(),
 			model.OrderActionSell: makeAssetSurplus(),
 		},
-		db: db,
+		db:         db,
+		rebalancer: rebalancer,
 	}, nil
 }
 
+// makeMirrorTradingExchange makes the backing exchange with the API keys, params, and headers from the config
+func makeMirrorTradingExchange(config *mirrorConfig, simMode bool) (api.Exchange, error) {
+	exchangeAPIKeys := config.ExchangeAPIKeys.ToExchangeAPIKeys()
+	exchangeParams := config.ExchangeParams.ToExchangeParams()
+	exchangeHeaders := config.ExchangeHeaders.ToExchangeHeaders()
+	return MakeTradingExchange(config.Exchange, exchangeAPIKeys, exchangeParams, exchangeHeaders, simMode)
+}
+
 // PruneExistingOffers deletes any extra offers
 func (s *mirrorStrategy) PruneExistingOffers(buyingAOffers []hProtocol.Offer, sellingAOffers []hProtocol.Offer) ([]build.TransactionMutator, []hProtocol.Offer, []hProtocol.Offer) {
 	return []build.TransactionMutator{}, buyingAOffers, sellingAOffers
@@ -353,6 +461,14 @@ func (s *mirrorStrategy) PruneExistingOffers(buyingAOffers []hProtocol.Offer, se
 
 // PreUpdate changes the strategy's state in prepration for the update
 func (s *mirrorStrategy) PreUpdate(maxAssetA float64, maxAssetB float64, trustA float64, trustB float64) error {
+	if s.rebalancer != nil {
+		// a failed rebalance should not stop the bot from updating its offers, it is retried on the next check
+		e := s.rebalancer.MaybeRun()
+		if e != nil {
+			log.Printf("error while rebalancing inventory, continuing with the update: %s\n", e)
+		}
+	}
+
 	// we don't care about or use balance coordinators if we are not offsetting trades
 	if !s.offsetTrades {
 		return nil
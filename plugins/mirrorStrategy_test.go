@@ -236,10 +236,10 @@ func TestBalanceCoordinatorCheckBalance(t *testing.T) {
 			inputVol:               model.NumberFromFloat(3.14, 5),
 			inputPrice:             model.NumberFromFloat(0.131, 5),
 			wantHasBackingBalance:  true,
-			wantNewBaseVolume:      model.NumberFromFloat(3.13999, 5),
-			wantNewQuoteVolume:     model.NumberFromFloat(0.41133, 5),
-			wantPlacedPrimaryUnits: model.NumberFromFloat(103.69999, 5),
-			wantPlacedBackingUnits: model.NumberFromFloat(8.86833, 5),
+			wantNewBaseVolume:      model.NumberFromFloat(3.14, 5),
+			wantNewQuoteVolume:     model.NumberFromFloat(0.41134, 5),
+			wantPlacedPrimaryUnits: model.NumberFromFloat(103.7, 5),
+			wantPlacedBackingUnits: model.NumberFromFloat(8.86834, 5),
 		}, {
 			name: "3. sell primary-available backing-partial zero",
 			bc: &balanceCoordinator{
@@ -0,0 +1,65 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stellar/kelp/api"
+)
+
+// offlineValidation is set when configs are validated without network access, see EnableOfflineValidation
+var offlineValidation = false
+
+// EnableOfflineValidation makes the factories in this package check their inputs without connecting to ccxt-rest or to
+// centralized exchanges. Feeds that need an exchange are replaced with stand-ins that cannot fetch prices and strategies that
+// need an exchange (mirror) stop after validating their config and return a nil strategy, so this should only be used to
+// validate configs and never when running a bot
+func EnableOfflineValidation() {
+	offlineValidation = true
+}
+
+// CheckExchangeTypeOffline checks that the exchange type exists without loading the list of ccxt exchanges from ccxt-rest,
+// any exchange type with the "ccxt-" prefix is accepted since we cannot know which ones ccxt-rest supports
+func CheckExchangeTypeOffline(exchangeType string, needsTrading bool) error {
+	ccxtName := strings.TrimPrefix(exchangeType, "ccxt-")
+	if ccxtName != exchangeType {
+		if ccxtName == "" {
+			return fmt.Errorf("invalid exchange type '%s', needs the name of the exchange after 'ccxt-'", exchangeType)
+		}
+		return nil
+	}
+
+	exchange, ok := makeNativeExchanges()[exchangeType]
+	if !ok {
+		return fmt.Errorf("invalid exchange type: %s", exchangeType)
+	}
+	if needsTrading && !exchange.TradeEnabled {
+		return fmt.Errorf("trading is not enabled on this exchange: %s", exchangeType)
+	}
+	return nil
+}
+
+// offlineExchangeFeed stands in for an exchange feed when validating configs offline
+type offlineExchangeFeed struct {
+	url string
+}
+
+var _ api.PriceFeed = &offlineExchangeFeed{}
+
+func makeOfflineExchangeFeed(url string, exchangeType string, modifier string) (*offlineExchangeFeed, error) {
+	e := CheckExchangeTypeOffline(exchangeType, false)
+	if e != nil {
+		return nil, fmt.Errorf("cannot make priceFeed because of an error when making the '%s' exchange: %s", exchangeType, e)
+	}
+
+	e = checkExchangeFeedModifier(modifier)
+	if e != nil {
+		return nil, e
+	}
+	return &offlineExchangeFeed{url: url}, nil
+}
+
+// GetPrice impl
+func (f *offlineExchangeFeed) GetPrice() (float64, error) {
+	return 0, fmt.Errorf("cannot fetch price from exchange feed '%s' when validating configs offline", f.url)
+}
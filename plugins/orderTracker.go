@@ -0,0 +1,544 @@
+package plugins
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/kelpdb"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/postgresdb"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// states of an order in the orders table
+const (
+	OrderStateOpen      = "open"
+	OrderStateUnknown   = "unknown"   // open on the exchange but not submitted by this bot
+	OrderStateCancelled = "cancelled" // cancelled by this bot
+	OrderStateFilled    = "filled"
+	OrderStateOrphaned  = "orphaned" // disappeared from the exchange without being cancelled by this bot or fully filled
+	// submitted by this bot but never showed up on the exchange, it was either taken immediately or rejected
+	OrderStateFilledOrRejected = "filled_or_rejected"
+)
+
+// number of reconciliations after which we expect the exchange to reflect submitted ops, since ops may be submitted asynchronously
+const orderTrackerReconcileLag = 2
+
+// relative difference allowed when matching submitted ops to offers, since conversions to and from offers can lose precision
+const orderTrackerMatchTolerance = 0.01
+
+// OrderIDFn returns the orderID and clientOrderID on the exchange for an offer, clientOrderID can be empty
+type OrderIDFn func(offer hProtocol.Offer) (string /*orderID*/, string /*clientOrderID*/)
+
+type trackedOrder struct {
+	orderID       string
+	clientOrderID string
+	offerID       int64
+	action        model.OrderAction
+	price         float64 // counter price
+	volume        float64 // base volume
+	submitTime    time.Time
+	state         string
+	filledVolume  float64
+
+	// when this bot requested the order to be cancelled or modified
+	cancelRequestTime *time.Time
+	modifyRequested   bool
+	numChecksSince    int
+}
+
+type pendingOrder struct {
+	seq            uint64 // unique per tracker, used to derive an orderID if the order never shows up on the exchange
+	sellingBase    bool
+	price          float64 // as specified on the op
+	amount         float64 // as specified on the op
+	submitTime     time.Time
+	numChecksSince int
+}
+
+// OrderReconciliation is the result of reconciling the tracked orders against the orders on the exchange
+type OrderReconciliation struct {
+	NumOpen     int
+	NumNew      int
+	NumUnknown  int
+	NumLeaked   int // cancelled by this bot but still open on the exchange
+	NumClosed   map[string]int
+	UnknownIDs  []string
+	LeakedIDs   []string
+	OrphanedIDs []string
+}
+
+// String is the Stringer method
+func (r *OrderReconciliation) String() string {
+	return fmt.Sprintf("OrderReconciliation[numOpen=%d, numNew=%d, numUnknown=%d, numLeaked=%d, numClosed=%v, unknownIDs=%v, leakedIDs=%v, orphanedIDs=%v]",
+		r.NumOpen, r.NumNew, r.NumUnknown, r.NumLeaked, r.NumClosed, r.UnknownIDs, r.LeakedIDs, r.OrphanedIDs)
+}
+
+// OrderTracker tracks the lifecycle of orders submitted by the bot and optionally writes them to the orders table in the database
+type OrderTracker struct {
+	db        *sql.DB // can be nil
+	accountID string
+	marketID  string
+	baseAsset hProtocol.Asset
+	orderIDFn OrderIDFn
+
+	// initialized runtime vars
+	lock    *sync.Mutex
+	orders  map[string]*trackedOrder // orderID -> order
+	pending []*pendingOrder
+
+	// uninitialized runtime vars
+	reconciled bool
+	pendingSeq uint64
+}
+
+var _ api.FillHandler = &OrderTracker{}
+
+// MakeOrderTracker is a factory method, db can be nil and orderIDFn can be nil (when the offerID is the orderID, such as on SDEX)
+func MakeOrderTracker(
+	db *sql.DB,
+	accountID string,
+	marketID string,
+	baseAsset hProtocol.Asset,
+	orderIDFn OrderIDFn,
+) *OrderTracker {
+	if orderIDFn == nil {
+		orderIDFn = func(offer hProtocol.Offer) (string, string) {
+			return strconv.FormatInt(offer.ID, 10), ""
+		}
+	}
+
+	return &OrderTracker{
+		db:        db,
+		accountID: accountID,
+		marketID:  marketID,
+		baseAsset: baseAsset,
+		orderIDFn: orderIDFn,
+		// initialized runtime vars
+		lock:    &sync.Mutex{},
+		orders:  map[string]*trackedOrder{},
+		pending: []*pendingOrder{},
+	}
+}
+
+// RecordSubmittedOps should be called right before submitting ops to the exchange
+func (t *OrderTracker) RecordSubmittedOps(ops []txnbuild.Operation, submitTime time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	offerID2Order := map[int64]*trackedOrder{}
+	for _, o := range t.orders {
+		offerID2Order[o.offerID] = o
+	}
+
+	for _, op := range ops {
+		mso, ok := op.(*txnbuild.ManageSellOffer)
+		if !ok {
+			continue
+		}
+
+		amount, e := strconv.ParseFloat(mso.Amount, 64)
+		if e != nil {
+			log.Printf("orderTracker could not parse amount of op, ignoring op: %s\n", e)
+			continue
+		}
+		price, e := strconv.ParseFloat(mso.Price, 64)
+		if e != nil {
+			log.Printf("orderTracker could not parse price of op, ignoring op: %s\n", e)
+			continue
+		}
+
+		if mso.OfferID != 0 {
+			o, ok := offerID2Order[mso.OfferID]
+			if !ok {
+				log.Printf("orderTracker did not find order for offerID %d in submitted op\n", mso.OfferID)
+			} else {
+				cancelRequestTime := submitTime
+				o.cancelRequestTime = &cancelRequestTime
+				o.modifyRequested = amount != 0
+				o.numChecksSince = 0
+			}
+		}
+
+		// a modified offer may be replaced by a new order on some exchanges so it is also pending
+		if amount != 0 {
+			sellingBase := t.isBaseAsset(utils.Asset2Asset2(mso.Selling))
+			t.pendingSeq++
+			t.pending = append(t.pending, &pendingOrder{
+				seq:         t.pendingSeq,
+				sellingBase: sellingBase,
+				price:       price,
+				amount:      amount,
+				submitTime:  submitTime,
+			})
+		}
+	}
+}
+
+// Reconcile compares the tracked orders against the offers on the exchange and should be called whenever offers are loaded
+func (t *OrderTracker) Reconcile(sellingAOffers []hProtocol.Offer, buyingAOffers []hProtocol.Offer, now time.Time) (*OrderReconciliation, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	isFirstReconcile := !t.reconciled
+	if isFirstReconcile && t.db != nil {
+		e := t.loadOpenOrdersFromDb()
+		if e != nil {
+			return nil, fmt.Errorf("could not load open orders from db: %s", e)
+		}
+	}
+	t.reconciled = true
+
+	result := &OrderReconciliation{
+		NumClosed:   map[string]int{},
+		UnknownIDs:  []string{},
+		LeakedIDs:   []string{},
+		OrphanedIDs: []string{},
+	}
+	seen := map[string]bool{}
+	for _, offerList := range [][]hProtocol.Offer{sellingAOffers, buyingAOffers} {
+		for _, offer := range offerList {
+			orderID, clientOrderID := t.orderIDFn(offer)
+			seen[orderID] = true
+			result.NumOpen++
+
+			if o, ok := t.orders[orderID]; ok {
+				o.offerID = offer.ID
+				e := t.checkTrackedOrder(o, offer, result)
+				if e != nil {
+					return nil, e
+				}
+				continue
+			}
+
+			state := OrderStateOpen
+			submitTime := now
+			if p := t.popMatchingPending(offer); p != nil {
+				submitTime = p.submitTime
+				result.NumNew++
+			} else if isFirstReconcile {
+				// orders that existed before the bot started are adopted
+				if offer.LastModifiedTime != nil {
+					submitTime = *offer.LastModifiedTime
+				}
+			} else {
+				state = OrderStateUnknown
+				result.NumUnknown++
+				result.UnknownIDs = append(result.UnknownIDs, orderID)
+			}
+
+			o, e := t.makeTrackedOrder(orderID, clientOrderID, offer, submitTime, state)
+			if e != nil {
+				return nil, e
+			}
+			// only track the order once it is in the db, otherwise we would never retry the insert and later updates would have no row
+			e = t.insertOrder(o)
+			if e != nil {
+				return nil, e
+			}
+			t.orders[orderID] = o
+		}
+	}
+
+	for orderID, o := range t.orders {
+		if seen[orderID] {
+			continue
+		}
+
+		state := OrderStateOrphaned
+		var cancelTime *time.Time
+		if o.cancelRequestTime != nil {
+			state = OrderStateCancelled
+			cancelTime = o.cancelRequestTime
+		} else if o.filledVolume >= o.volume*(1-orderTrackerMatchTolerance) {
+			state = OrderStateFilled
+		} else {
+			result.OrphanedIDs = append(result.OrphanedIDs, orderID)
+		}
+		result.NumClosed[state]++
+
+		e := t.closeOrder(o, state, cancelTime, now)
+		if e != nil {
+			return nil, e
+		}
+		delete(t.orders, orderID)
+	}
+
+	// submitted ops that never showed up were either rejected or taken immediately, we still record them so the orders table has every
+	// order that the bot submitted
+	remaining := []*pendingOrder{}
+	for _, p := range t.pending {
+		p.numChecksSince++
+		if p.numChecksSince < orderTrackerReconcileLag {
+			remaining = append(remaining, p)
+			continue
+		}
+
+		e := t.recordUnmatchedPending(p, now)
+		if e != nil {
+			return nil, e
+		}
+		result.NumClosed[OrderStateFilledOrRejected]++
+	}
+	t.pending = remaining
+
+	return result, nil
+}
+
+// recordUnmatchedPending writes a pending order that never showed up on the exchange to the orders table as a closed order
+func (t *OrderTracker) recordUnmatchedPending(p *pendingOrder, now time.Time) error {
+	o := &trackedOrder{
+		// the exchange never assigned an ID so we derive one that is unique for this bot
+		orderID:    fmt.Sprintf("unmatched-%d-%d", p.submitTime.UnixNano(), p.seq),
+		action:     model.OrderActionSell,
+		price:      p.price,
+		volume:     p.amount,
+		submitTime: p.submitTime,
+		state:      OrderStateFilledOrRejected,
+	}
+	if !p.sellingBase {
+		if p.price == 0 {
+			return fmt.Errorf("price of pending order was 0")
+		}
+		o.action = model.OrderActionBuy
+		o.price, o.volume = 1/p.price, p.amount*p.price
+	}
+	log.Printf("orderTracker: submitted order (action=%s, price=%f, volume=%f) never showed up on the exchange, recording it as %s\n", o.action, o.price, o.volume, o.state)
+
+	e := t.insertOrder(o)
+	if e != nil {
+		return e
+	}
+	return t.closeOrder(o, o.state, nil, now)
+}
+
+func (t *OrderTracker) checkTrackedOrder(o *trackedOrder, offer hProtocol.Offer, result *OrderReconciliation) error {
+	if o.cancelRequestTime == nil {
+		return nil
+	}
+
+	if o.modifyRequested {
+		// the order was modified in place
+		_, action, price, volume, e := t.offer2Values(offer)
+		if e != nil {
+			return e
+		}
+		if action == o.action && (!approxEqual(price, o.price) || !approxEqual(volume, o.volume)) {
+			o.price, o.volume = price, volume
+			e = t.updateOrderPriceVolume(o)
+			if e != nil {
+				return e
+			}
+		}
+		t.popMatchingPending(offer)
+		o.cancelRequestTime = nil
+		o.modifyRequested = false
+		o.numChecksSince = 0
+		return nil
+	}
+
+	o.numChecksSince++
+	if o.numChecksSince >= orderTrackerReconcileLag {
+		result.NumLeaked++
+		result.LeakedIDs = append(result.LeakedIDs, o.orderID)
+		// only report a leaked order once, it will be reported again if we request a cancel again
+		o.cancelRequestTime = nil
+		o.numChecksSince = 0
+	}
+	return nil
+}
+
+func (t *OrderTracker) popMatchingPending(offer hProtocol.Offer) *pendingOrder {
+	sellingBase := t.isBaseAsset(offer.Selling)
+	price, e := strconv.ParseFloat(offer.Price, 64)
+	if e != nil {
+		return nil
+	}
+	amount, e := strconv.ParseFloat(offer.Amount, 64)
+	if e != nil {
+		return nil
+	}
+
+	for i, p := range t.pending {
+		if p.sellingBase == sellingBase && approxEqual(p.price, price) && approxEqual(p.amount, amount) {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			return p
+		}
+	}
+	return nil
+}
+
+// offer2Values converts the offer to values in terms of the base asset
+func (t *OrderTracker) offer2Values(offer hProtocol.Offer) (bool /*sellingBase*/, model.OrderAction, float64 /*price*/, float64 /*volume*/, error) {
+	sellingBase := t.isBaseAsset(offer.Selling)
+	price, e := strconv.ParseFloat(offer.Price, 64)
+	if e != nil {
+		return false, model.OrderActionSell, 0, 0, fmt.Errorf("could not parse price of offer %d: %s", offer.ID, e)
+	}
+	amount, e := strconv.ParseFloat(offer.Amount, 64)
+	if e != nil {
+		return false, model.OrderActionSell, 0, 0, fmt.Errorf("could not parse amount of offer %d: %s", offer.ID, e)
+	}
+
+	if sellingBase {
+		return true, model.OrderActionSell, price, amount, nil
+	}
+	if price == 0 {
+		return false, model.OrderActionBuy, 0, 0, fmt.Errorf("price of offer %d was 0", offer.ID)
+	}
+	return false, model.OrderActionBuy, 1 / price, amount * price, nil
+}
+
+func (t *OrderTracker) isBaseAsset(asset hProtocol.Asset) bool {
+	return utils.Asset2String(asset) == utils.Asset2String(t.baseAsset)
+}
+
+func (t *OrderTracker) makeTrackedOrder(orderID string, clientOrderID string, offer hProtocol.Offer, submitTime time.Time, state string) (*trackedOrder, error) {
+	_, action, price, volume, e := t.offer2Values(offer)
+	if e != nil {
+		return nil, e
+	}
+
+	return &trackedOrder{
+		orderID:       orderID,
+		clientOrderID: clientOrderID,
+		offerID:       offer.ID,
+		action:        action,
+		price:         price,
+		volume:        volume,
+		submitTime:    submitTime,
+		state:         state,
+	}, nil
+}
+
+// HandleFill impl
+func (t *OrderTracker) HandleFill(trade model.Trade) error {
+	if trade.OrderID == "" || trade.Volume == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if o, ok := t.orders[trade.OrderID]; ok {
+		o.filledVolume += trade.Volume.AsFloat()
+	}
+
+	if t.db == nil {
+		return nil
+	}
+	sqlUpdate := fmt.Sprintf(kelpdb.SqlOrdersUpdateFilledVolumeTemplate,
+		trade.Volume.AsFloat(),
+		t.accountID,
+		t.marketID,
+		trade.OrderID,
+	)
+	_, e := t.db.Exec(sqlUpdate)
+	if e != nil {
+		return fmt.Errorf("could not execute sql update statement (%s): %s", sqlUpdate, e)
+	}
+	return nil
+}
+
+func (t *OrderTracker) loadOpenOrdersFromDb() error {
+	rows, e := t.db.Query(kelpdb.SqlQueryOpenOrders, t.accountID, t.marketID)
+	if e != nil {
+		return fmt.Errorf("could not execute sql select query (%s): %s", kelpdb.SqlQueryOpenOrders, e)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o trackedOrder
+		var action string
+		e = rows.Scan(&o.orderID, &o.clientOrderID, &action, &o.price, &o.volume, &o.submitTime, &o.state, &o.filledVolume)
+		if e != nil {
+			return fmt.Errorf("could not scan row into trackedOrder struct: %s", e)
+		}
+		o.action = model.OrderActionFromString(action)
+		t.orders[o.orderID] = &o
+	}
+	log.Printf("orderTracker loaded %d open orders from db\n", len(t.orders))
+	return nil
+}
+
+func (t *OrderTracker) insertOrder(o *trackedOrder) error {
+	if t.db == nil {
+		return nil
+	}
+
+	sqlInsert := fmt.Sprintf(kelpdb.SqlOrdersInsertTemplate,
+		t.accountID,
+		t.marketID,
+		o.orderID,
+		o.clientOrderID,
+		o.action.String(),
+		o.price,
+		o.volume,
+		o.submitTime.UTC().Format(postgresdb.TimestampFormatString),
+		o.state,
+	)
+	_, e := t.db.Exec(sqlInsert)
+	if e != nil {
+		return fmt.Errorf("could not execute sql insert values statement (%s): %s", sqlInsert, e)
+	}
+	return nil
+}
+
+func (t *OrderTracker) updateOrderPriceVolume(o *trackedOrder) error {
+	if t.db == nil {
+		return nil
+	}
+
+	sqlUpdate := fmt.Sprintf(kelpdb.SqlOrdersUpdatePriceVolumeTemplate,
+		o.price,
+		o.volume,
+		t.accountID,
+		t.marketID,
+		o.orderID,
+	)
+	_, e := t.db.Exec(sqlUpdate)
+	if e != nil {
+		return fmt.Errorf("could not execute sql update statement (%s): %s", sqlUpdate, e)
+	}
+	return nil
+}
+
+func (t *OrderTracker) closeOrder(o *trackedOrder, state string, cancelTime *time.Time, closeTime time.Time) error {
+	if t.db == nil {
+		return nil
+	}
+
+	cancelTimeLiteral := "NULL"
+	if cancelTime != nil {
+		cancelTimeLiteral = fmt.Sprintf("'%s'", cancelTime.UTC().Format(postgresdb.TimestampFormatString))
+	}
+	sqlUpdate := fmt.Sprintf(kelpdb.SqlOrdersUpdateStateTemplate,
+		state,
+		cancelTimeLiteral,
+		closeTime.UTC().Format(postgresdb.TimestampFormatString),
+		t.accountID,
+		t.marketID,
+		o.orderID,
+	)
+	_, e := t.db.Exec(sqlUpdate)
+	if e != nil {
+		return fmt.Errorf("could not execute sql update statement (%s): %s", sqlUpdate, e)
+	}
+	return nil
+}
+
+func approxEqual(a float64, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= orderTrackerMatchTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
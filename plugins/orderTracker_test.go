@@ -0,0 +1,209 @@
+package plugins
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/utils"
+)
+
+func makeTestOrderTrackerOffer(id int64, sellingBase bool, price string, amount string) hProtocol.Offer {
+	selling, buying := utils.Asset2Asset2(testBaseAsset), utils.Asset2Asset2(testQuoteAsset)
+	if !sellingBase {
+		selling, buying = buying, selling
+	}
+	return hProtocol.Offer{
+		ID:      id,
+		Selling: selling,
+		Buying:  buying,
+		Price:   price,
+		Amount:  amount,
+	}
+}
+
+func makeTestOrderTrackerOp(offerID int64, sellingBase bool, price string, amount string) txnbuild.Operation {
+	var selling, buying txnbuild.Asset = testBaseAsset, testQuoteAsset
+	if !sellingBase {
+		selling, buying = buying, selling
+	}
+	return &txnbuild.ManageSellOffer{
+		Selling: selling,
+		Buying:  buying,
+		Price:   price,
+		Amount:  amount,
+		OfferID: offerID,
+	}
+}
+
+func TestOrderTrackerReconcile(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+	tracker := MakeOrderTracker(nil, "account", "market", utils.Asset2Asset2(testBaseAsset), nil)
+
+	// offers that exist when the bot starts are adopted
+	existingSell := makeTestOrderTrackerOffer(1, true, "0.5", "100.0")
+	existingBuy := makeTestOrderTrackerOffer(2, false, "2.5", "10.0")
+	r, e := tracker.Reconcile([]hProtocol.Offer{existingSell}, []hProtocol.Offer{existingBuy}, now)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, r.NumOpen)
+	assert.Equal(t, 0, r.NumUnknown)
+	if !assert.Equal(t, 2, len(tracker.orders)) {
+		return
+	}
+	assert.Equal(t, model.OrderActionSell, tracker.orders["1"].action)
+	assert.Equal(t, 0.5, tracker.orders["1"].price)
+	assert.Equal(t, 100.0, tracker.orders["1"].volume)
+	// buy offers are converted to base units
+	assert.Equal(t, model.OrderActionBuy, tracker.orders["2"].action)
+	assert.Equal(t, 0.4, tracker.orders["2"].price)
+	assert.Equal(t, 25.0, tracker.orders["2"].volume)
+
+	// cancel the sell, create a new sell, and the buy gets filled
+	tracker.RecordSubmittedOps([]txnbuild.Operation{
+		makeTestOrderTrackerOp(1, true, "0.5", "0"),
+		makeTestOrderTrackerOp(0, true, "0.6", "50.0"),
+	}, now.Add(time.Second))
+	e = tracker.HandleFill(model.Trade{
+		Order: model.Order{
+			OrderAction: model.OrderActionBuy,
+			Volume:      model.NumberFromFloat(25.0, 7),
+		},
+		OrderID: "2",
+	})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	newSell := makeTestOrderTrackerOffer(3, true, "0.6", "50.0")
+	unknownSell := makeTestOrderTrackerOffer(4, true, "0.9", "1.0")
+	r, e = tracker.Reconcile([]hProtocol.Offer{newSell, unknownSell}, []hProtocol.Offer{}, now.Add(2*time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, r.NumOpen)
+	assert.Equal(t, 1, r.NumNew)
+	assert.Equal(t, 1, r.NumUnknown)
+	assert.Equal(t, []string{"4"}, r.UnknownIDs)
+	assert.Equal(t, map[string]int{OrderStateCancelled: 1, OrderStateFilled: 1}, r.NumClosed)
+	assert.Equal(t, 2, len(tracker.orders))
+	assert.Equal(t, now.Add(time.Second), tracker.orders["3"].submitTime)
+	assert.Equal(t, OrderStateUnknown, tracker.orders["4"].state)
+
+	// an order that disappears without being cancelled or filled is orphaned
+	r, e = tracker.Reconcile([]hProtocol.Offer{newSell}, []hProtocol.Offer{}, now.Add(3*time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, map[string]int{OrderStateOrphaned: 1}, r.NumClosed)
+	assert.Equal(t, []string{"4"}, r.OrphanedIDs)
+	assert.Equal(t, 0, len(tracker.pending))
+}
+
+func TestOrderTrackerReconcileInsertError(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+	// nothing listens on this port so every statement fails
+	db, e := sql.Open("postgres", "host=127.0.0.1 port=1 user=kelp dbname=kelp sslmode=disable connect_timeout=1")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer db.Close()
+	tracker := MakeOrderTracker(db, "account", "market", utils.Asset2Asset2(testBaseAsset), nil)
+	// skip loading the open orders from the db
+	tracker.reconciled = true
+
+	sell := makeTestOrderTrackerOffer(1, true, "0.5", "100.0")
+	_, e = tracker.Reconcile([]hProtocol.Offer{sell}, []hProtocol.Offer{}, now)
+	assert.Error(t, e)
+	assert.Equal(t, 0, len(tracker.orders), "the order is not tracked when it could not be inserted")
+
+	// the insert is retried on the next reconciliation
+	tracker.db = nil
+	r, e := tracker.Reconcile([]hProtocol.Offer{sell}, []hProtocol.Offer{}, now.Add(time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 1, r.NumUnknown)
+	assert.Equal(t, 1, len(tracker.orders))
+}
+
+func TestOrderTrackerLeakedOrder(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+	tracker := MakeOrderTracker(nil, "account", "market", utils.Asset2Asset2(testBaseAsset), nil)
+
+	sell := makeTestOrderTrackerOffer(1, true, "0.5", "100.0")
+	_, e := tracker.Reconcile([]hProtocol.Offer{sell}, []hProtocol.Offer{}, now)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	tracker.RecordSubmittedOps([]txnbuild.Operation{makeTestOrderTrackerOp(1, true, "0.5", "0")}, now)
+	// the exchange may take some time to reflect the cancel
+	r, e := tracker.Reconcile([]hProtocol.Offer{sell}, []hProtocol.Offer{}, now.Add(time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, r.NumLeaked)
+
+	r, e = tracker.Reconcile([]hProtocol.Offer{sell}, []hProtocol.Offer{}, now.Add(2*time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 1, r.NumLeaked)
+	assert.Equal(t, []string{"1"}, r.LeakedIDs)
+	assert.Equal(t, OrderStateOpen, tracker.orders["1"].state)
+}
+
+func TestOrderTrackerModifiedOrder(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+	tracker := MakeOrderTracker(nil, "account", "market", utils.Asset2Asset2(testBaseAsset), nil)
+
+	_, e := tracker.Reconcile([]hProtocol.Offer{makeTestOrderTrackerOffer(1, true, "0.5", "100.0")}, []hProtocol.Offer{}, now)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	tracker.RecordSubmittedOps([]txnbuild.Operation{makeTestOrderTrackerOp(1, true, "0.55", "80.0")}, now)
+	r, e := tracker.Reconcile([]hProtocol.Offer{makeTestOrderTrackerOffer(1, true, "0.55", "80.0")}, []hProtocol.Offer{}, now.Add(time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, r.NumNew)
+	assert.Equal(t, 0, r.NumUnknown)
+	assert.Equal(t, 0, len(r.NumClosed))
+	assert.Equal(t, 0.55, tracker.orders["1"].price)
+	assert.Equal(t, 80.0, tracker.orders["1"].volume)
+	assert.Nil(t, tracker.orders["1"].cancelRequestTime)
+	assert.Equal(t, 0, len(tracker.pending))
+}
+
+func TestOrderTrackerUnmatchedPendingOrder(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+	tracker := MakeOrderTracker(nil, "account", "market", utils.Asset2Asset2(testBaseAsset), nil)
+	_, e := tracker.Reconcile([]hProtocol.Offer{}, []hProtocol.Offer{}, now)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// the buy is taken immediately so it never shows up on the exchange
+	tracker.RecordSubmittedOps([]txnbuild.Operation{makeTestOrderTrackerOp(0, false, "2.5", "10.0")}, now)
+	r, e := tracker.Reconcile([]hProtocol.Offer{}, []hProtocol.Offer{}, now.Add(time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, len(r.NumClosed))
+	assert.Equal(t, 1, len(tracker.pending))
+
+	r, e = tracker.Reconcile([]hProtocol.Offer{}, []hProtocol.Offer{}, now.Add(2*time.Second))
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, map[string]int{OrderStateFilledOrRejected: 1}, r.NumClosed)
+	assert.Equal(t, 0, len(tracker.pending))
+}
@@ -55,6 +55,10 @@ func MakePriceFeed(feedType string, url string) (api.PriceFeed, error) {
 			exchangeModifier = urlParts[3]
 		}
 
+		if offlineValidation {
+			return makeOfflineExchangeFeed(url, urlParts[0], exchangeModifier)
+		}
+
 		exchange, e := MakeExchange(urlParts[0], true)
 		if e != nil {
 			return nil, fmt.Errorf("cannot make priceFeed because of an error when making the '%s' exchange: %s", urlParts[0], e)
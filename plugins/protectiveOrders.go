@@ -0,0 +1,394 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+// protectiveOrderState tracks a protective order managed by ProtectiveOrders
+type protectiveOrderState struct {
+	order    *api.ProtectiveOrder
+	native   bool                 // true when the order is held by the exchange, false when it is simulated against the price feed
+	nativeID *model.TransactionID // set once the native order has been placed
+	done     bool                 // true once the order was triggered or cancelled
+	// failureAlerted is set once we alerted that the triggered order could not be submitted, so retries on every run do not alert again
+	failureAlerted bool
+}
+
+// ProtectiveOrders places stop loss and take profit orders on a trading pair. Orders are held natively by the exchange when it implements
+// api.ProtectiveOrderAPI and supports the order, otherwise they are simulated by watching the price feed and sending a market order (or an
+// aggressive limit order when slippage is set) once the trigger price is crossed. Orders on the same side protect the same inventory, so
+// once one of them is triggered the others on that side are cancelled. Since the exchange holds the inventory of open orders, only the
+// first order on each side is held natively and the offers that the strategy of this bot placed on the side of a simulated order are
+// cancelled before the triggered order is sent. Triggered orders are surfaced through the alert.
+type ProtectiveOrders struct {
+	exchange  api.TradeAPI
+	pair      *model.TradingPair
+	priceFeed api.PriceFeed // only used for simulated orders, can be nil when all orders are held natively
+	alert     api.Alert
+	slippage  float64 // simulated orders without a limit price are sent as limit orders this fraction past the feed price, 0 sends market orders
+	simMode   bool
+	// clientOrderIDPrefix starts the client order IDs of our native orders and of the orders of the strategy placed through BatchedExchange,
+	// it is derived from the bot so we never cancel orders of other bots
+	clientOrderIDPrefix string
+
+	// uninitialized
+	orders      []*protectiveOrderState
+	initialized bool
+}
+
+// MakeProtectiveOrders is a factory method
+func MakeProtectiveOrders(
+	exchange api.TradeAPI,
+	pair *model.TradingPair,
+	botID string,
+	orders []*api.ProtectiveOrder,
+	priceFeed api.PriceFeed,
+	alert api.Alert,
+	slippage float64,
+	simMode bool,
+) (*ProtectiveOrders, error) {
+	if slippage < 0 || slippage >= 1 {
+		return nil, fmt.Errorf("slippage needs to be in the range [0, 1), was %f", slippage)
+	}
+
+	poAPI, hasNativeAPI := exchange.(api.ProtectiveOrderAPI)
+	clientOrderIDPrefix := makeClientOrderIDPrefix(botID, pair)
+	nativeSides := map[model.OrderAction]bool{}
+	states := []*protectiveOrderState{}
+	for i, o := range orders {
+		if o.TriggerPrice == nil || o.TriggerPrice.Sign() <= 0 {
+			return nil, fmt.Errorf("protective order at index %d: trigger price needs to be positive", i)
+		}
+		if o.LimitPrice != nil && o.LimitPrice.Sign() <= 0 {
+			return nil, fmt.Errorf("protective order at index %d: limit price needs to be positive when set", i)
+		}
+		if o.Volume == nil || o.Volume.Sign() <= 0 {
+			return nil, fmt.Errorf("protective order at index %d: volume needs to be positive", i)
+		}
+		o.Pair = pair
+		o.ClientOrderID = makeProtectiveClientOrderID(clientOrderIDPrefix, i, o)
+
+		// orders are always simulated in sim mode so we never place anything on the exchange. The exchange would reject a second native
+		// order on a side for insufficient balance since the first one holds the inventory, so the other orders on that side are simulated
+		native := !simMode && hasNativeAPI && !nativeSides[o.OrderAction] && poAPI.SupportsProtectiveOrder(o)
+		nativeSides[o.OrderAction] = nativeSides[o.OrderAction] || native
+		if !native && priceFeed == nil {
+			return nil, fmt.Errorf("protective order at index %d cannot be held natively by the exchange and needs a price feed to be simulated: %s", i, o)
+		}
+		states = append(states, &protectiveOrderState{
+			order:  o,
+			native: native,
+		})
+	}
+
+	return &ProtectiveOrders{
+		exchange:            exchange,
+		pair:                pair,
+		priceFeed:           priceFeed,
+		alert:               alert,
+		slippage:            slippage,
+		simMode:             simMode,
+		clientOrderIDPrefix: clientOrderIDPrefix,
+		orders:              states,
+	}, nil
+}
+
+// makeProtectiveClientOrderID is formatted as a UUID like the client order IDs of the other orders of the bot and starts with the prefix of
+// the bot (see makeClientOrderID)
+func makeProtectiveClientOrderID(prefix string, index int, order *api.ProtectiveOrder) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s", prefix, index, order)))
+	return fmt.Sprintf("%s-%x-%x-%x-%x", prefix, h[0:2], h[2:4], h[4:6], h[6:12])
+}
+
+// Run places the native orders that are not placed yet and checks every active order once, this is meant to be called on every update of
+// the bot. Errors on an order do not stop the other orders from being checked, a simulated order that could not be sent is retried on the
+// next run.
+func (p *ProtectiveOrders) Run() error {
+	if !p.initialized {
+		e := p.cancelStaleNativeOrders()
+		if e != nil {
+			return fmt.Errorf("could not cancel protective orders left over from a previous run: %s", e)
+		}
+		p.initialized = true
+	}
+
+	var openIDs map[string]bool
+	var price *model.Number
+	errs := []error{}
+	for _, s := range p.orders {
+		if s.done {
+			continue
+		}
+
+		var e error
+		if s.native {
+			if openIDs == nil {
+				openIDs, e = p.fetchOpenNativeIDs()
+				if e != nil {
+					return e
+				}
+			}
+			e = p.runNative(s, openIDs)
+		} else {
+			if price == nil {
+				price, e = p.fetchPrice()
+				if e != nil {
+					return e
+				}
+			}
+			e = p.runSimulated(s, price)
+		}
+
+		if e != nil {
+			log.Printf("protectiveOrders: error on %s: %s\n", s.order, e)
+			errs = append(errs, e)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("protective orders encountered %d errors, first error: %s", len(errs), errs[0])
+	}
+	return nil
+}
+
+// cancelStaleNativeOrders cancels the native protective orders on the pair that were placed by a previous run of the bot, so they are not
+// duplicated when we place our orders again. Orders without our client order ID prefix belong to other bots (or were placed by hand) and
+// are left alone.
+func (p *ProtectiveOrders) cancelStaleNativeOrders() error {
+	hasNative := false
+	for _, s := range p.orders {
+		hasNative = hasNative || s.native
+	}
+	if !hasNative {
+		return nil
+	}
+
+	clientOrderIDs, e := p.exchange.(api.ProtectiveOrderAPI).GetOpenProtectiveOrders(p.pair)
+	if e != nil {
+		return fmt.Errorf("could not fetch open protective orders: %s", e)
+	}
+	for id, clientOrderID := range clientOrderIDs {
+		if !hasClientOrderIDPrefix(clientOrderID, p.clientOrderIDPrefix) {
+			log.Printf("protectiveOrders: leaving open protective order id=%s (clientOrderID=%s) since it was not placed by this bot\n", id, clientOrderID)
+			continue
+		}
+		log.Printf("protectiveOrders: cancelling protective order left over from a previous run, id=%s\n", id)
+		_, e = p.exchange.CancelOrder(model.MakeTransactionID(id), *p.pair)
+		if e != nil {
+			return fmt.Errorf("could not cancel protective order with id=%s: %s", id, e)
+		}
+	}
+	return nil
+}
+
+func (p *ProtectiveOrders) fetchOpenNativeIDs() (map[string]bool, error) {
+	clientOrderIDs, e := p.exchange.(api.ProtectiveOrderAPI).GetOpenProtectiveOrders(p.pair)
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch open protective orders: %s", e)
+	}
+
+	openIDs := map[string]bool{}
+	for id := range clientOrderIDs {
+		openIDs[id] = true
+	}
+	return openIDs, nil
+}
+
+func (p *ProtectiveOrders) fetchPrice() (*model.Number, error) {
+	priceFloat, e := p.priceFeed.GetPrice()
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch price from feed: %s", e)
+	}
+	if priceFloat <= 0 {
+		return nil, fmt.Errorf("price feed returned a non-positive price: %f", priceFloat)
+	}
+	return model.NumberFromFloat(priceFloat, p.pricePrecision()), nil
+}
+
+func (p *ProtectiveOrders) pricePrecision() int8 {
+	if oc := p.exchange.GetOrderConstraints(p.pair); oc != nil {
+		return oc.PricePrecision
+	}
+	return largePrecision
+}
+
+func (p *ProtectiveOrders) runNative(s *protectiveOrderState, openIDs map[string]bool) error {
+	poAPI := p.exchange.(api.ProtectiveOrderAPI)
+	if s.nativeID == nil {
+		txID, e := poAPI.AddProtectiveOrder(s.order)
+		if e != nil {
+			return fmt.Errorf("could not place protective order on the exchange: %s", e)
+		}
+		log.Printf("protectiveOrders: placed %s on the exchange, id=%s\n", s.order, txID)
+		s.nativeID = txID
+		return nil
+	}
+
+	if openIDs[s.nativeID.String()] {
+		return nil
+	}
+	// the exchange no longer has the order open, it was either triggered by the exchange or cancelled by someone else
+	log.Printf("protectiveOrders: %s (id=%s) is no longer open on the exchange\n", s.order, s.nativeID)
+	s.done = true
+	p.triggerAlert(s, "protective order is no longer open on the exchange, it was triggered or cancelled", nil, nil)
+	return p.cancelSameSide(s)
+}
+
+func (p *ProtectiveOrders) runSimulated(s *protectiveOrderState, price *model.Number) error {
+	if !s.order.IsTriggered(price) {
+		return nil
+	}
+
+	order := p.makeTriggeredOrder(s.order, price)
+	if p.simMode {
+		log.Printf("protectiveOrders: %s was triggered at price %s, not submitting %s in sim mode\n", s.order, price.AsString(), order)
+		s.done = true
+		p.triggerAlert(s, "protective order was triggered", price, nil)
+		return p.cancelSameSide(s)
+	}
+
+	txID, e := p.submitTriggeredOrder(s, order)
+	if e != nil {
+		// the order is retried on every run until it is submitted, only alert on the first failure so we do not alert on every run
+		if !s.failureAlerted {
+			p.triggerAlert(s, "protective order was triggered but the order could not be submitted, will keep retrying", price, e)
+			s.failureAlerted = true
+		}
+		return fmt.Errorf("could not submit triggered order %s: %s", order, e)
+	}
+	log.Printf("protectiveOrders: %s was triggered at price %s, submitted %s with id=%s\n", s.order, price.AsString(), order, txID)
+
+	s.done = true
+	p.triggerAlert(s, "protective order was triggered", price, nil)
+	return nil
+}
+
+// submitTriggeredOrder cancels the other protective orders and the offers of the strategy on the side of the triggered order before sending
+// it, since the exchange holds the inventory of these orders and the triggered order needs that inventory
+func (p *ProtectiveOrders) submitTriggeredOrder(s *protectiveOrderState, order *model.Order) (*model.TransactionID, error) {
+	e := p.cancelSameSide(s)
+	if e != nil {
+		return nil, e
+	}
+
+	e = p.cancelStrategyOffers(s)
+	if e != nil {
+		return nil, e
+	}
+
+	return p.exchange.AddOrder(order, api.SubmitModeBoth)
+}
+
+// cancelStrategyOffers cancels the open orders of the strategy on the side of the triggered order, the strategy places its offers again on
+// the next update using the balance that is left after the triggered order. Orders are told apart by their client order ID, orders of
+// other bots and orders placed by hand are left alone. We cannot tell who placed an order when the exchange does not report its client
+// order ID so we return an error instead of cancelling it, the triggered order is retried on the next run.
+func (p *ProtectiveOrders) cancelStrategyOffers(triggered *protectiveOrderState) error {
+	openOrders, e := p.exchange.GetOpenOrders([]*model.TradingPair{p.pair})
+	if e != nil {
+		return fmt.Errorf("could not fetch open orders to cancel before sending triggered order: %s", e)
+	}
+
+	ownIDs := []string{}
+	for _, o := range openOrders[*p.pair] {
+		if o.OrderAction != triggered.order.OrderAction {
+			continue
+		}
+
+		if o.ClientOrderID == "" {
+			return fmt.Errorf("cannot tell whether open order with id=%s was placed by this bot since the exchange did not return its client order ID, not cancelling it", o.ID)
+		}
+		if !hasClientOrderIDPrefix(o.ClientOrderID, p.clientOrderIDPrefix) {
+			log.Printf("protectiveOrders: leaving open order id=%s (clientOrderID=%s) since it was not placed by this bot\n", o.ID, o.ClientOrderID)
+			continue
+		}
+		ownIDs = append(ownIDs, o.ID)
+	}
+
+	for _, id := range ownIDs {
+		_, e = p.exchange.CancelOrder(model.MakeTransactionID(id), *p.pair)
+		if e != nil {
+			return fmt.Errorf("could not cancel open order with id=%s before sending triggered order: %s", id, e)
+		}
+		log.Printf("protectiveOrders: cancelled open order with id=%s to free the inventory for %s\n", id, triggered.order)
+	}
+	return nil
+}
+
+// makeTriggeredOrder makes the order that is sent to the market when a simulated protective order is triggered
+func (p *ProtectiveOrders) makeTriggeredOrder(po *api.ProtectiveOrder, price *model.Number) *model.Order {
+	order := &model.Order{
+		Pair:        p.pair,
+		OrderAction: po.OrderAction,
+		OrderType:   model.OrderTypeLimit,
+		Price:       po.LimitPrice,
+		Volume:      po.Volume,
+	}
+	if po.LimitPrice != nil {
+		return order
+	}
+
+	if p.slippage == 0 {
+		// the price is only informational for market orders
+		order.OrderType = model.OrderTypeMarket
+		order.Price = price
+		return order
+	}
+
+	// cross the spread by the slippage so the order is filled immediately
+	scale := 1 - p.slippage
+	if po.OrderAction.IsBuy() {
+		scale = 1 + p.slippage
+	}
+	order.Price = model.NumberFromFloat(price.AsFloat()*scale, p.pricePrecision())
+	return order
+}
+
+// cancelSameSide cancels the other active orders on the same side as the triggered order, since they protect the same inventory
+func (p *ProtectiveOrders) cancelSameSide(triggered *protectiveOrderState) error {
+	for _, s := range p.orders {
+		if s == triggered || s.done || s.order.OrderAction != triggered.order.OrderAction {
+			continue
+		}
+
+		if s.nativeID != nil {
+			_, e := p.exchange.CancelOrder(s.nativeID, *p.pair)
+			if e != nil {
+				return fmt.Errorf("could not cancel protective order %s (id=%s) after %s was triggered: %s", s.order, s.nativeID, triggered.order, e)
+			}
+		}
+		log.Printf("protectiveOrders: cancelled %s since %s was triggered\n", s.order, triggered.order)
+		s.done = true
+	}
+	return nil
+}
+
+func (p *ProtectiveOrders) triggerAlert(s *protectiveOrderState, description string, price *model.Number, cause error) {
+	details := map[string]interface{}{
+		"pair":          p.pair.String(),
+		"type":          string(s.order.Type),
+		"action":        s.order.OrderAction.String(),
+		"trigger_price": s.order.TriggerPrice.AsString(),
+		"volume":        s.order.Volume.AsString(),
+		"native":        s.native,
+	}
+	if s.nativeID != nil {
+		details["order_id"] = s.nativeID.String()
+	}
+	if price != nil {
+		details["price"] = price.AsString()
+	}
+	if cause != nil {
+		details["error"] = cause.Error()
+	}
+
+	e := p.alert.Trigger(description, details)
+	if e != nil {
+		log.Printf("protectiveOrders: unable to trigger alert: %s\n", e)
+	}
+}
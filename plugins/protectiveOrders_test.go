@@ -0,0 +1,422 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+var testProtectivePair = &model.TradingPair{Base: model.XLM, Quote: model.USDT}
+
+// mockProtectiveExchange records the orders sent to it, protective orders are held natively when supportsNative is set
+type mockProtectiveExchange struct {
+	api.TradeAPI // methods not used by ProtectiveOrders are not implemented
+
+	supportsNative bool
+	addOrderError  error
+	addedOrders    []*model.Order
+	openOrders     []model.OpenOrder // orders of the strategy
+	openProtective map[string]*api.ProtectiveOrder
+	cancelledIDs   []string
+	nextID         int
+}
+
+var _ api.ProtectiveOrderAPI = &mockProtectiveExchange{}
+
+func makeMockProtectiveExchange(supportsNative bool) *mockProtectiveExchange {
+	return &mockProtectiveExchange{
+		supportsNative: supportsNative,
+		addedOrders:    []*model.Order{},
+		openOrders:     []model.OpenOrder{},
+		openProtective: map[string]*api.ProtectiveOrder{},
+		cancelledIDs:   []string{},
+	}
+}
+
+// triggerNative simulates the exchange triggering the native protective order with the passed in id
+func (x *mockProtectiveExchange) triggerNative(id string) {
+	delete(x.openProtective, id)
+}
+
+// GetOrderConstraints impl
+func (x *mockProtectiveExchange) GetOrderConstraints(pair *model.TradingPair) *model.OrderConstraints {
+	return model.MakeOrderConstraints(4, 2, 1.0)
+}
+
+// AddOrder impl
+func (x *mockProtectiveExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*model.TransactionID, error) {
+	if x.addOrderError != nil {
+		return nil, x.addOrderError
+	}
+	x.addedOrders = append(x.addedOrders, order)
+	x.nextID++
+	return model.MakeTransactionID(strconv.Itoa(x.nextID)), nil
+}
+
+// GetOpenOrders impl
+func (x *mockProtectiveExchange) GetOpenOrders(pairs []*model.TradingPair) (map[model.TradingPair][]model.OpenOrder, error) {
+	return map[model.TradingPair][]model.OpenOrder{*testProtectivePair: x.openOrders}, nil
+}
+
+// CancelOrder impl
+func (x *mockProtectiveExchange) CancelOrder(txID *model.TransactionID, pair model.TradingPair) (model.CancelOrderResult, error) {
+	delete(x.openProtective, txID.String())
+	remaining := []model.OpenOrder{}
+	for _, o := range x.openOrders {
+		if o.ID != txID.String() {
+			remaining = append(remaining, o)
+		}
+	}
+	x.openOrders = remaining
+	x.cancelledIDs = append(x.cancelledIDs, txID.String())
+	return model.CancelResultCancelSuccessful, nil
+}
+
+// SupportsProtectiveOrder impl
+func (x *mockProtectiveExchange) SupportsProtectiveOrder(order *api.ProtectiveOrder) bool {
+	return x.supportsNative
+}
+
+// AddProtectiveOrder impl
+func (x *mockProtectiveExchange) AddProtectiveOrder(order *api.ProtectiveOrder) (*model.TransactionID, error) {
+	x.nextID++
+	id := strconv.Itoa(x.nextID)
+	x.openProtective[id] = order
+	return model.MakeTransactionID(id), nil
+}
+
+// GetOpenProtectiveOrders impl
+func (x *mockProtectiveExchange) GetOpenProtectiveOrders(pair *model.TradingPair) (map[string]string, error) {
+	clientOrderIDs := map[string]string{}
+	for id, o := range x.openProtective {
+		clientOrderIDs[id] = o.ClientOrderID
+	}
+	return clientOrderIDs, nil
+}
+
+// makeTestOpenOrder makes an open order placed by the bot, the client order ID is empty when botID is empty
+func makeTestOpenOrder(id string, action model.OrderAction, botID string) model.OpenOrder {
+	clientOrderID := ""
+	if botID != "" {
+		clientOrderID = makeClientOrderIDPrefix(botID, testProtectivePair) + "-0000-0000-0000-000000000000"
+	}
+	return model.OpenOrder{
+		Order: model.Order{
+			Pair:          testProtectivePair,
+			OrderAction:   action,
+			OrderType:     model.OrderTypeLimit,
+			Price:         model.NumberFromFloat(0.11, 4),
+			Volume:        model.NumberFromFloat(50, 2),
+			ClientOrderID: clientOrderID,
+		},
+		ID: id,
+	}
+}
+
+// recordingAlert keeps the descriptions of all triggered alerts
+type recordingAlert struct {
+	descriptions []string
+}
+
+// Trigger impl
+func (a *recordingAlert) Trigger(description string, details interface{}) error {
+	a.descriptions = append(a.descriptions, description)
+	return nil
+}
+
+func makeTestProtectiveOrder(orderType api.ProtectiveOrderType, action model.OrderAction, triggerPrice float64, limitPrice float64) *api.ProtectiveOrder {
+	var limit *model.Number
+	if limitPrice > 0 {
+		limit = model.NumberFromFloat(limitPrice, 4)
+	}
+	return &api.ProtectiveOrder{
+		Type:         orderType,
+		OrderAction:  action,
+		TriggerPrice: model.NumberFromFloat(triggerPrice, 4),
+		LimitPrice:   limit,
+		Volume:       model.NumberFromFloat(100, 2),
+	}
+}
+
+func TestProtectiveOrderIsTriggered(t *testing.T) {
+	testCases := []struct {
+		orderType api.ProtectiveOrderType
+		action    model.OrderAction
+		price     float64
+		want      bool
+	}{
+		{api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.11, false},
+		{api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, true},
+		{api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.09, true},
+		{api.ProtectiveOrderTypeTakeProfit, model.OrderActionSell, 0.09, false},
+		{api.ProtectiveOrderTypeTakeProfit, model.OrderActionSell, 0.11, true},
+		{api.ProtectiveOrderTypeStopLoss, model.OrderActionBuy, 0.09, false},
+		{api.ProtectiveOrderTypeStopLoss, model.OrderActionBuy, 0.11, true},
+		{api.ProtectiveOrderTypeTakeProfit, model.OrderActionBuy, 0.11, false},
+		{api.ProtectiveOrderTypeTakeProfit, model.OrderActionBuy, 0.09, true},
+	}
+
+	for _, k := range testCases {
+		t.Run(fmt.Sprintf("%s_%s_%.2f", k.orderType, k.action, k.price), func(t *testing.T) {
+			o := makeTestProtectiveOrder(k.orderType, k.action, 0.10, 0)
+			assert.Equal(t, k.want, o.IsTriggered(model.NumberFromFloat(k.price, 4)))
+		})
+	}
+}
+
+func TestProtectiveOrders_Simulated(t *testing.T) {
+	testCases := []struct {
+		name          string
+		limitPrice    float64
+		slippage      float64
+		wantOrderType model.OrderType
+		wantPrice     float64
+	}{
+		{
+			name:          "market order",
+			wantOrderType: model.OrderTypeMarket,
+			wantPrice:     0.095,
+		}, {
+			name:          "aggressive limit order",
+			slippage:      0.02,
+			wantOrderType: model.OrderTypeLimit,
+			wantPrice:     0.0931,
+		}, {
+			name:          "limit price",
+			limitPrice:    0.09,
+			slippage:      0.02,
+			wantOrderType: model.OrderTypeLimit,
+			wantPrice:     0.09,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			exchange := makeMockProtectiveExchange(false)
+			exchange.openOrders = []model.OpenOrder{
+				makeTestOpenOrder("strategySell", model.OrderActionSell, "bot1"),
+				makeTestOpenOrder("strategyBuy", model.OrderActionBuy, "bot1"),
+			}
+			alert := &recordingAlert{}
+			feed := &sequenceFeed{prices: []float64{0.105, 0.095}}
+			stopLoss := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, k.limitPrice)
+			takeProfit := makeTestProtectiveOrder(api.ProtectiveOrderTypeTakeProfit, model.OrderActionSell, 0.12, 0)
+			p, e := MakeProtectiveOrders(exchange, testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss, takeProfit}, feed, alert, k.slippage, false)
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			// price is above the stop loss
+			if !assert.NoError(t, p.Run()) {
+				return
+			}
+			assert.Equal(t, 0, len(exchange.addedOrders))
+			assert.Equal(t, 0, len(alert.descriptions))
+
+			// price drops below the stop loss so the sell offer of the strategy is cancelled to free the inventory before the order is
+			// sent, and the take profit on the same side is cancelled
+			if !assert.NoError(t, p.Run()) {
+				return
+			}
+			assert.Equal(t, []string{"strategySell"}, exchange.cancelledIDs)
+			assert.Equal(t, []model.OpenOrder{makeTestOpenOrder("strategyBuy", model.OrderActionBuy, "bot1")}, exchange.openOrders)
+			if assert.Equal(t, 1, len(exchange.addedOrders)) {
+				o := exchange.addedOrders[0]
+				assert.Equal(t, model.OrderActionSell, o.OrderAction)
+				assert.Equal(t, k.wantOrderType, o.OrderType)
+				assert.Equal(t, k.wantPrice, o.Price.AsFloat())
+				assert.Equal(t, 100.0, o.Volume.AsFloat())
+			}
+			assert.Equal(t, []string{"protective order was triggered"}, alert.descriptions)
+
+			// nothing is sent again
+			if !assert.NoError(t, p.Run()) {
+				return
+			}
+			assert.Equal(t, 1, len(exchange.addedOrders))
+		})
+	}
+}
+
+func TestProtectiveOrders_SimulatedOnlyCancelsOwnOrders(t *testing.T) {
+	testCases := []struct {
+		name          string
+		openOrders    []model.OpenOrder
+		wantErr       bool
+		wantCancelled []string
+		wantAdded     int
+	}{
+		{
+			name: "orders of other bots are left alone",
+			openOrders: []model.OpenOrder{
+				makeTestOpenOrder("ownSell", model.OrderActionSell, "bot1"),
+				makeTestOpenOrder("otherBotSell", model.OrderActionSell, "bot2"),
+				makeTestOpenOrder("ownBuy", model.OrderActionBuy, "bot1"),
+			},
+			wantCancelled: []string{"ownSell"},
+			wantAdded:     1,
+		}, {
+			name: "orders without a client order ID fail the trigger",
+			openOrders: []model.OpenOrder{
+				makeTestOpenOrder("ownSell", model.OrderActionSell, "bot1"),
+				makeTestOpenOrder("unknownSell", model.OrderActionSell, ""),
+			},
+			wantErr:       true,
+			wantCancelled: []string{},
+			wantAdded:     0,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			exchange := makeMockProtectiveExchange(false)
+			exchange.openOrders = k.openOrders
+			alert := &recordingAlert{}
+			feed := &sequenceFeed{prices: []float64{0.09}}
+			stopLoss := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+			p, e := MakeProtectiveOrders(exchange, testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss}, feed, alert, 0, false)
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			e = p.Run()
+			if k.wantErr {
+				assert.Error(t, e)
+			} else {
+				assert.NoError(t, e)
+			}
+			assert.Equal(t, k.wantCancelled, exchange.cancelledIDs)
+			assert.Equal(t, k.wantAdded, len(exchange.addedOrders))
+			assert.Equal(t, 1, len(alert.descriptions))
+		})
+	}
+}
+
+func TestProtectiveOrders_SimulatedRetriesFailedOrder(t *testing.T) {
+	exchange := makeMockProtectiveExchange(false)
+	exchange.addOrderError = fmt.Errorf("insufficient funds")
+	alert := &recordingAlert{}
+	feed := &sequenceFeed{prices: []float64{0.13}}
+	takeProfit := makeTestProtectiveOrder(api.ProtectiveOrderTypeTakeProfit, model.OrderActionSell, 0.12, 0)
+	p, e := MakeProtectiveOrders(exchange, testProtectivePair, "bot1", []*api.ProtectiveOrder{takeProfit}, feed, alert, 0, false)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	assert.Error(t, p.Run())
+	assert.Equal(t, 0, len(exchange.addedOrders))
+	assert.Equal(t, 1, len(alert.descriptions))
+
+	// we only alert on the first failure
+	assert.Error(t, p.Run())
+	assert.Equal(t, 0, len(exchange.addedOrders))
+	assert.Equal(t, 1, len(alert.descriptions))
+
+	exchange.addOrderError = nil
+	assert.NoError(t, p.Run())
+	assert.Equal(t, 1, len(exchange.addedOrders))
+	assert.Equal(t, 2, len(alert.descriptions))
+}
+
+func TestProtectiveOrders_SimModeDoesNotSubmit(t *testing.T) {
+	exchange := makeMockProtectiveExchange(true)
+	alert := &recordingAlert{}
+	feed := &sequenceFeed{prices: []float64{0.09}}
+	stopLoss := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	p, e := MakeProtectiveOrders(exchange, testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss}, feed, alert, 0, true)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	assert.NoError(t, p.Run())
+	assert.Equal(t, 0, len(exchange.openProtective), "orders are not held natively in sim mode")
+	assert.Equal(t, 0, len(exchange.addedOrders))
+	assert.Equal(t, 1, len(alert.descriptions))
+}
+
+func TestProtectiveOrders_Native(t *testing.T) {
+	exchange := makeMockProtectiveExchange(true)
+	// left over from a previous run of the bot, from another bot trading on the same account and placed by hand
+	exchange.openProtective["stale"] = makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	exchange.openProtective["stale"].ClientOrderID = makeClientOrderIDPrefix("bot1", testProtectivePair) + "-0000-0000-0000-000000000000"
+	exchange.openProtective["otherBot"] = makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	exchange.openProtective["otherBot"].ClientOrderID = makeClientOrderIDPrefix("bot2", testProtectivePair) + "-0000-0000-0000-000000000000"
+	exchange.openProtective["manual"] = makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	alert := &recordingAlert{}
+	feed := &sequenceFeed{prices: []float64{0.11, 0.11, 0.13}}
+	stopLoss := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	takeProfit := makeTestProtectiveOrder(api.ProtectiveOrderTypeTakeProfit, model.OrderActionSell, 0.12, 0)
+	buyStop := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionBuy, 0.15, 0)
+	p, e := MakeProtectiveOrders(exchange, testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss, takeProfit, buyStop}, feed, alert, 0, false)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// only our stale order is cancelled, the first order on each side is placed on the exchange and the take profit is simulated since
+	// the stop loss holds the inventory on the sell side
+	if !assert.NoError(t, p.Run()) {
+		return
+	}
+	assert.Equal(t, []string{"stale"}, exchange.cancelledIDs)
+	assert.Equal(t, 4, len(exchange.openProtective))
+	assert.Equal(t, stopLoss, exchange.openProtective["1"])
+	assert.Equal(t, buyStop, exchange.openProtective["2"])
+	assert.Equal(t, 0, len(alert.descriptions))
+
+	// nothing changes while the orders are open
+	if !assert.NoError(t, p.Run()) {
+		return
+	}
+	assert.Equal(t, 4, len(exchange.openProtective))
+
+	// the simulated take profit is triggered so the stop loss on the same side is cancelled before the order is sent
+	if !assert.NoError(t, p.Run()) {
+		return
+	}
+	assert.Equal(t, []string{"stale", "1"}, exchange.cancelledIDs)
+	assert.Equal(t, 1, len(exchange.addedOrders))
+	assert.Equal(t, []string{"protective order was triggered"}, alert.descriptions)
+
+	// the exchange triggers the buy stop
+	exchange.triggerNative("2")
+	if !assert.NoError(t, p.Run()) {
+		return
+	}
+	assert.Equal(t, 2, len(exchange.openProtective))
+	assert.Equal(t, []string{"protective order was triggered", "protective order is no longer open on the exchange, it was triggered or cancelled"}, alert.descriptions)
+	assert.Equal(t, 1, len(exchange.addedOrders))
+}
+
+func TestMakeProtectiveClientOrderID(t *testing.T) {
+	o := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	prefix := makeClientOrderIDPrefix("bot1", testProtectivePair)
+	id := makeProtectiveClientOrderID(prefix, 0, o)
+
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$", id)
+	assert.True(t, strings.HasPrefix(id, prefix+"-"))
+	assert.Equal(t, id, makeProtectiveClientOrderID(prefix, 0, o), "ids are deterministic")
+	assert.NotEqual(t, id, makeProtectiveClientOrderID(prefix, 1, o))
+	assert.NotEqual(t, prefix, makeClientOrderIDPrefix("bot2", testProtectivePair))
+}
+
+func TestMakeProtectiveOrders_Errors(t *testing.T) {
+	feed := &sequenceFeed{prices: []float64{0.1}}
+	stopLoss := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	zeroVolume := makeTestProtectiveOrder(api.ProtectiveOrderTypeStopLoss, model.OrderActionSell, 0.10, 0)
+	zeroVolume.Volume = model.NumberConstants.Zero
+
+	_, e := MakeProtectiveOrders(makeMockProtectiveExchange(false), testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss}, feed, &recordingAlert{}, 1.0, false)
+	assert.Error(t, e, "slippage out of range")
+	_, e = MakeProtectiveOrders(makeMockProtectiveExchange(false), testProtectivePair, "bot1", []*api.ProtectiveOrder{zeroVolume}, feed, &recordingAlert{}, 0, false)
+	assert.Error(t, e, "zero volume")
+	_, e = MakeProtectiveOrders(makeMockProtectiveExchange(false), testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss}, nil, &recordingAlert{}, 0, false)
+	assert.Error(t, e, "simulated order without a price feed")
+	_, e = MakeProtectiveOrders(makeMockProtectiveExchange(true), testProtectivePair, "bot1", []*api.ProtectiveOrder{stopLoss}, nil, &recordingAlert{}, 0, false)
+	assert.NoError(t, e, "native order without a price feed")
+}
@@ -0,0 +1,428 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+// rateLimitMetadataProvider is implemented by exchanges that know their own rate limits (such as ccxt)
+type rateLimitMetadataProvider interface {
+	// minimum millis between requests, 0 if unknown
+	getRateLimitMillis() int64
+}
+
+// retryableErrorSubstrings are (lowercased) markers of errors that are transient, i.e. the same request can succeed if we try again later
+var retryableErrorSubstrings = []string{
+	// ccxt error classes
+	"networkerror",
+	"requesttimeout",
+	"exchangenotavailable",
+	"onmaintenance",
+	"invalidnonce",
+	// kraken
+	"eservice:unavailable",
+	"eservice:busy",
+	"egeneral:temporary lockout",
+	// binance: internal error and timestamp outside of the recvWindow
+	"code=-1001)",
+	"code=-1021)",
+	// transport
+	"could not execute http request",
+	"connection reset",
+	"connection refused",
+	"i/o timeout",
+	"timeout exceeded",
+	"unexpected eof",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+}
+
+// rateLimitErrorSubstrings are (lowercased) markers of errors where the exchange rejected the request because we sent too many requests
+var rateLimitErrorSubstrings = []string{
+	"rate limit exceeded",
+	"ratelimitexceeded",
+	"ddosprotection",
+	"too many requests",
+	// binance
+	"code=-1003)",
+}
+
+// isExchangeRateLimitError returns true if the exchange rejected the request because of rate limits, the request was not processed by the exchange
+func isExchangeRateLimitError(e error) bool {
+	return containsAny(strings.ToLower(e.Error()), rateLimitErrorSubstrings)
+}
+
+// isExchangeRetryableError returns true if the error is transient, the request may or may not have been processed by the exchange
+func isExchangeRetryableError(e error) bool {
+	return isExchangeRateLimitError(e) || containsAny(strings.ToLower(e.Error()), retryableErrorSubstrings)
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenBucket allows bursts of up to capacity requests and refills at ratePerSecond
+type tokenBucket struct {
+	ratePerSecond float64
+	capacity      float64
+
+	// initialized runtime vars
+	lock *sync.Mutex
+
+	// uninitialized runtime vars
+	tokens     float64
+	lastRefill time.Time
+}
+
+func makeTokenBucket(ratePerSecond float64, burst int) *tokenBucket {
+	capacity := float64(burst)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tokenBucket{
+		ratePerSecond: ratePerSecond,
+		capacity:      capacity,
+		lock:          &sync.Mutex{},
+		tokens:        capacity,
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller needs to wait before using it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.lastRefill.IsZero() && now.After(b.lastRefill) {
+		b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.lastRefill).Seconds()*b.ratePerSecond)
+	}
+	if b.lastRefill.IsZero() || now.After(b.lastRefill) {
+		b.lastRefill = now
+	}
+
+	// tokens can go negative, which queues up callers behind each other
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.ratePerSecond * float64(time.Second))
+}
+
+// RateLimitedExchange is a decorator around an api.Exchange that throttles requests and retries transient errors with exponential backoff
+type RateLimitedExchange struct {
+	inner       api.Exchange
+	bucket      *tokenBucket // can be nil
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	// these are fields so tests can control time and jitter
+	now    func() time.Time
+	sleep  func(time.Duration)
+	jitter func() float64
+}
+
+// ensure that RateLimitedExchange conforms to the Exchange interface
+var _ api.Exchange = &RateLimitedExchange{}
+
+// MakeRateLimitedExchange is a factory method
+//
+// requestsPerSecond of 0 uses the rate limit reported by the inner exchange if available, otherwise requests are not throttled.
+// burst is the number of requests that can be sent back-to-back before throttling kicks in.
+// maxRetries is the number of times a request is retried on transient errors, with backoff starting at baseBackoff and doubling up to maxBackoff.
+func MakeRateLimitedExchange(
+	inner api.Exchange,
+	requestsPerSecond float64,
+	burst int,
+	maxRetries int,
+	baseBackoff time.Duration,
+	maxBackoff time.Duration,
+) (*RateLimitedExchange, error) {
+	if requestsPerSecond < 0 {
+		return nil, fmt.Errorf("requestsPerSecond cannot be negative: %f", requestsPerSecond)
+	}
+	if maxRetries < 0 {
+		return nil, fmt.Errorf("maxRetries cannot be negative: %d", maxRetries)
+	}
+	if baseBackoff < 0 || maxBackoff < baseBackoff {
+		return nil, fmt.Errorf("invalid backoff values, need 0 <= baseBackoff (%s) <= maxBackoff (%s)", baseBackoff, maxBackoff)
+	}
+
+	if requestsPerSecond == 0 {
+		if p, ok := inner.(rateLimitMetadataProvider); ok && p.getRateLimitMillis() > 0 {
+			requestsPerSecond = 1000.0 / float64(p.getRateLimitMillis())
+			log.Printf("using rate limit reported by exchange: %d millis between requests (%.4f requests per second)\n", p.getRateLimitMillis(), requestsPerSecond)
+		}
+	}
+
+	var bucket *tokenBucket
+	if requestsPerSecond > 0 {
+		bucket = makeTokenBucket(requestsPerSecond, burst)
+	}
+
+	return &RateLimitedExchange{
+		inner:       inner,
+		bucket:      bucket,
+		maxRetries:  maxRetries,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		now:         time.Now,
+		sleep:       time.Sleep,
+		jitter:      rand.Float64,
+	}, nil
+}
+
+// backoff returns the time to wait before the given retry attempt (0-indexed), using exponential backoff with equal jitter
+func (x *RateLimitedExchange) backoff(attempt int) time.Duration {
+	d := float64(x.baseBackoff) * math.Pow(2, float64(attempt))
+	if d > float64(x.maxBackoff) {
+		d = float64(x.maxBackoff)
+	}
+	// keep at least half the delay so we always back off, and randomize the rest so many bots don't retry in lockstep
+	return time.Duration(d/2 + x.jitter()*d/2)
+}
+
+func (x *RateLimitedExchange) throttle() {
+	if x.bucket == nil {
+		return
+	}
+
+	wait := x.bucket.reserve(x.now())
+	if wait > 0 {
+		x.sleep(wait)
+	}
+}
+
+// do runs fn, retrying errors for which shouldRetry returns true until maxRetries is exhausted
+func (x *RateLimitedExchange) do(name string, shouldRetry func(e error) bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		x.throttle()
+		e := fn()
+		if e == nil {
+			return nil
+		}
+
+		if !shouldRetry(e) {
+			return e
+		}
+
+		if attempt >= x.maxRetries {
+			return fmt.Errorf("giving up on %s after %d retries: %s", name, attempt, e)
+		}
+
+		backoff := x.backoff(attempt)
+		log.Printf("retryable error in %s (attempt %d of %d), retrying in %s: %s\n", name, attempt+1, x.maxRetries+1, backoff, e)
+		x.sleep(backoff)
+	}
+}
+
+// doIdempotent should be used for requests that are safe to send again even if the exchange has already processed them
+func (x *RateLimitedExchange) doIdempotent(name string, fn func() error) error {
+	return x.do(name, isExchangeRetryableError, fn)
+}
+
+// doNonIdempotent should be used for requests that change state on the exchange, we only retry them when we know for sure that they were not processed
+func (x *RateLimitedExchange) doNonIdempotent(name string, fn func() error) error {
+	return x.do(name, isExchangeRateLimitError, fn)
+}
+
+// GetAccountBalances impl
+func (x *RateLimitedExchange) GetAccountBalances(assetList []interface{}) (map[interface{}]model.Number, error) {
+	var result map[interface{}]model.Number
+	e := x.doIdempotent("GetAccountBalances", func() error {
+		var e error
+		result, e = x.inner.GetAccountBalances(assetList)
+		return e
+	})
+	return result, e
+}
+
+// GetTickerPrice impl
+func (x *RateLimitedExchange) GetTickerPrice(pairs []model.TradingPair) (map[model.TradingPair]api.Ticker, error) {
+	var result map[model.TradingPair]api.Ticker
+	e := x.doIdempotent("GetTickerPrice", func() error {
+		var e error
+		result, e = x.inner.GetTickerPrice(pairs)
+		return e
+	})
+	return result, e
+}
+
+// GetAssetConverter impl
+func (x *RateLimitedExchange) GetAssetConverter() model.AssetConverterInterface {
+	return x.inner.GetAssetConverter()
+}
+
+// GetOrderConstraints impl
+func (x *RateLimitedExchange) GetOrderConstraints(pair *model.TradingPair) *model.OrderConstraints {
+	return x.inner.GetOrderConstraints(pair)
+}
+
+// OverrideOrderConstraints impl
+func (x *RateLimitedExchange) OverrideOrderConstraints(pair *model.TradingPair, override *model.OrderConstraintsOverride) {
+	x.inner.OverrideOrderConstraints(pair, override)
+}
+
+// GetOrderBook impl
+func (x *RateLimitedExchange) GetOrderBook(pair *model.TradingPair, maxCount int32) (*model.OrderBook, error) {
+	var result *model.OrderBook
+	e := x.doIdempotent("GetOrderBook", func() error {
+		var e error
+		result, e = x.inner.GetOrderBook(pair, maxCount)
+		return e
+	})
+	return result, e
+}
+
+// GetTrades impl
+func (x *RateLimitedExchange) GetTrades(pair *model.TradingPair, maybeCursor interface{}) (*api.TradesResult, error) {
+	var result *api.TradesResult
+	e := x.doIdempotent("GetTrades", func() error {
+		var e error
+		result, e = x.inner.GetTrades(pair, maybeCursor)
+		return e
+	})
+	return result, e
+}
+
+// GetTradeHistory impl
+func (x *RateLimitedExchange) GetTradeHistory(pair model.TradingPair, maybeCursorStart interface{}, maybeCursorEnd interface{}) (*api.TradeHistoryResult, error) {
+	var result *api.TradeHistoryResult
+	e := x.doIdempotent("GetTradeHistory", func() error {
+		var e error
+		result, e = x.inner.GetTradeHistory(pair, maybeCursorStart, maybeCursorEnd)
+		return e
+	})
+	return result, e
+}
+
+// GetLatestTradeCursor impl
+func (x *RateLimitedExchange) GetLatestTradeCursor() (interface{}, error) {
+	var result interface{}
+	e := x.doIdempotent("GetLatestTradeCursor", func() error {
+		var e error
+		result, e = x.inner.GetLatestTradeCursor()
+		return e
+	})
+	return result, e
+}
+
+// GetOpenOrders impl
+func (x *RateLimitedExchange) GetOpenOrders(pairs []*model.TradingPair) (map[model.TradingPair][]model.OpenOrder, error) {
+	var result map[model.TradingPair][]model.OpenOrder
+	e := x.doIdempotent("GetOpenOrders", func() error {
+		var e error
+		result, e = x.inner.GetOpenOrders(pairs)
+		return e
+	})
+	return result, e
+}
+
+// AddOrder impl, only retried on rate limit errors since a timed out request may have placed the order
+func (x *RateLimitedExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*model.TransactionID, error) {
+	var result *model.TransactionID
+	e := x.doNonIdempotent("AddOrder", func() error {
+		var e error
+		result, e = x.inner.AddOrder(order, submitMode)
+		return e
+	})
+	return result, e
+}
+
+// CancelOrder impl, cancelling an order twice is harmless so this is retried on all transient errors
+func (x *RateLimitedExchange) CancelOrder(txID *model.TransactionID, pair model.TradingPair) (model.CancelOrderResult, error) {
+	var result model.CancelOrderResult
+	e := x.doIdempotent("CancelOrder", func() error {
+		var e error
+		result, e = x.inner.CancelOrder(txID, pair)
+		return e
+	})
+	return result, e
+}
+
+// PrepareDeposit impl
+func (x *RateLimitedExchange) PrepareDeposit(asset model.Asset, amount *model.Number) (*api.PrepareDepositResult, error) {
+	var result *api.PrepareDepositResult
+	e := x.doNonIdempotent("PrepareDeposit", func() error {
+		var e error
+		result, e = x.inner.PrepareDeposit(asset, amount)
+		return e
+	})
+	return result, e
+}
+
+// GetWithdrawInfo impl
+func (x *RateLimitedExchange) GetWithdrawInfo(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawInfo, error) {
+	var result *api.WithdrawInfo
+	e := x.doIdempotent("GetWithdrawInfo", func() error {
+		var e error
+		result, e = x.inner.GetWithdrawInfo(asset, amountToWithdraw, address)
+		return e
+	})
+	return result, e
+}
+
+// WithdrawFunds impl, only retried on rate limit errors since a timed out request may have initiated the withdrawal
+func (x *RateLimitedExchange) WithdrawFunds(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawFunds, error) {
+	var result *api.WithdrawFunds
+	e := x.doNonIdempotent("WithdrawFunds", func() error {
+		var e error
+		result, e = x.inner.WithdrawFunds(asset, amountToWithdraw, address)
+		return e
+	})
+	return result, e
+}
+
+// ensure that RateLimitedExchange forwards protective orders to the inner exchange
+var _ api.ProtectiveOrderAPI = &RateLimitedExchange{}
+
+// SupportsProtectiveOrder impl, false when the inner exchange cannot hold protective orders natively
+func (x *RateLimitedExchange) SupportsProtectiveOrder(order *api.ProtectiveOrder) bool {
+	poAPI, ok := x.inner.(api.ProtectiveOrderAPI)
+	return ok && poAPI.SupportsProtectiveOrder(order)
+}
+
+// AddProtectiveOrder impl
+func (x *RateLimitedExchange) AddProtectiveOrder(order *api.ProtectiveOrder) (*model.TransactionID, error) {
+	poAPI, ok := x.inner.(api.ProtectiveOrderAPI)
+	if !ok {
+		return nil, fmt.Errorf("inner exchange does not support protective orders (type=%T)", x.inner)
+	}
+
+	var result *model.TransactionID
+	e := x.doNonIdempotent("AddProtectiveOrder", func() error {
+		var e error
+		result, e = poAPI.AddProtectiveOrder(order)
+		return e
+	})
+	return result, e
+}
+
+// GetOpenProtectiveOrders impl
+func (x *RateLimitedExchange) GetOpenProtectiveOrders(pair *model.TradingPair) (map[string]string, error) {
+	poAPI, ok := x.inner.(api.ProtectiveOrderAPI)
+	if !ok {
+		return nil, fmt.Errorf("inner exchange does not support protective orders (type=%T)", x.inner)
+	}
+
+	var result map[string]string
+	e := x.doIdempotent("GetOpenProtectiveOrders", func() error {
+		var e error
+		result, e = poAPI.GetOpenProtectiveOrders(pair)
+		return e
+	})
+	return result, e
+}
@@ -0,0 +1,187 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+// errorSequenceExchange returns the next error in the sequence on every call to the methods it implements, nil once exhausted
+type errorSequenceExchange struct {
+	api.Exchange // nil, calling methods not implemented here will panic
+	errs         []error
+	numCalls     int
+}
+
+func (x *errorSequenceExchange) next() error {
+	x.numCalls++
+	if x.numCalls > len(x.errs) {
+		return nil
+	}
+	return x.errs[x.numCalls-1]
+}
+
+func (x *errorSequenceExchange) GetOpenOrders(pairs []*model.TradingPair) (map[model.TradingPair][]model.OpenOrder, error) {
+	if e := x.next(); e != nil {
+		return nil, e
+	}
+	return map[model.TradingPair][]model.OpenOrder{}, nil
+}
+
+func (x *errorSequenceExchange) AddOrder(order *model.Order, submitMode api.SubmitMode) (*model.TransactionID, error) {
+	if e := x.next(); e != nil {
+		return nil, e
+	}
+	return model.MakeTransactionID("txid"), nil
+}
+
+func (x *errorSequenceExchange) getRateLimitMillis() int64 {
+	return 250
+}
+
+func TestIsExchangeRetryableError(t *testing.T) {
+	testCases := []struct {
+		err           error
+		wantRateLimit bool
+		wantRetryable bool
+	}{
+		{fmt.Errorf("error in response, bodyString: {\"error\":\"DDoSProtection: binance too many requests\"}"), true, true},
+		{fmt.Errorf("EAPI:Rate limit exceeded"), true, true},
+		{fmt.Errorf("could not execute http request: dial tcp: i/o timeout"), false, true},
+		{fmt.Errorf("error in response, bodyString: {\"error\":\"RequestTimeout\"}"), false, true},
+		{fmt.Errorf("EService:Unavailable"), false, true},
+		{fmt.Errorf("error in response, bodyString: {\"error\":\"InsufficientFunds\"}"), false, false},
+		{fmt.Errorf("EOrder:Invalid price"), false, false},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.err.Error(), func(t *testing.T) {
+			assert.Equal(t, k.wantRateLimit, isExchangeRateLimitError(k.err))
+			assert.Equal(t, k.wantRetryable, isExchangeRetryableError(k.err))
+		})
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	b := makeTokenBucket(2, 2)
+	start, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+
+	// burst
+	assert.Equal(t, time.Duration(0), b.reserve(start))
+	assert.Equal(t, time.Duration(0), b.reserve(start))
+	// callers queue up behind each other
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start))
+	assert.Equal(t, time.Second, b.reserve(start))
+	// refills over time, up to the burst capacity
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(5*time.Second)))
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(5*time.Second)))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start.Add(5*time.Second)))
+}
+
+func TestRateLimitedExchangeBackoff(t *testing.T) {
+	testCases := []struct {
+		jitter float64
+		want   []time.Duration
+	}{
+		{
+			jitter: 0,
+			want:   []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond},
+		}, {
+			jitter: 1,
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(fmt.Sprintf("jitter=%.1f", k.jitter), func(t *testing.T) {
+			x, e := MakeRateLimitedExchange(&errorSequenceExchange{}, 1, 1, 5, 100*time.Millisecond, time.Second)
+			if !assert.NoError(t, e) {
+				return
+			}
+			jitter := k.jitter
+			x.jitter = func() float64 { return jitter }
+
+			for attempt, want := range k.want {
+				assert.Equal(t, want, x.backoff(attempt), fmt.Sprintf("attempt %d", attempt))
+			}
+		})
+	}
+}
+
+func TestRateLimitedExchangeRetries(t *testing.T) {
+	rateLimitErr := fmt.Errorf("EAPI:Rate limit exceeded")
+	timeoutErr := fmt.Errorf("could not execute http request: i/o timeout")
+	fatalErr := fmt.Errorf("EOrder:Insufficient funds")
+
+	testCases := []struct {
+		name         string
+		addOrder     bool
+		errs         []error
+		wantNumCalls int
+		wantErr      bool
+	}{
+		{
+			name:         "success",
+			errs:         []error{},
+			wantNumCalls: 1,
+		}, {
+			name:         "recovers from transient errors",
+			errs:         []error{timeoutErr, rateLimitErr},
+			wantNumCalls: 3,
+		}, {
+			name:         "gives up after max retries",
+			errs:         []error{timeoutErr, timeoutErr, timeoutErr, timeoutErr},
+			wantNumCalls: 3,
+			wantErr:      true,
+		}, {
+			name:         "fatal error is not retried",
+			errs:         []error{fatalErr},
+			wantNumCalls: 1,
+			wantErr:      true,
+		}, {
+			name:         "add order is retried on rate limit error",
+			addOrder:     true,
+			errs:         []error{rateLimitErr},
+			wantNumCalls: 2,
+		}, {
+			name:         "add order is not retried on timeout",
+			addOrder:     true,
+			errs:         []error{timeoutErr},
+			wantNumCalls: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			inner := &errorSequenceExchange{errs: k.errs}
+			x, e := MakeRateLimitedExchange(inner, 0, 1, 2, 100*time.Millisecond, time.Second)
+			if !assert.NoError(t, e) {
+				return
+			}
+			// the rate is picked up from the inner exchange
+			if !assert.NotNil(t, x.bucket) {
+				return
+			}
+			assert.Equal(t, 4.0, x.bucket.ratePerSecond)
+
+			now, _ := time.Parse(time.RFC3339, "2020-03-14T15:00:00Z")
+			x.now = func() time.Time { return now }
+			x.sleep = func(d time.Duration) { now = now.Add(d) }
+			x.jitter = func() float64 { return 0 }
+
+			if k.addOrder {
+				_, e = x.AddOrder(&model.Order{}, api.SubmitModeBoth)
+			} else {
+				_, e = x.GetOpenOrders([]*model.TradingPair{})
+			}
+			assert.Equal(t, k.wantErr, e != nil)
+			assert.Equal(t, k.wantNumCalls, inner.numCalls)
+		})
+	}
+}
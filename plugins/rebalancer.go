@@ -0,0 +1,402 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+// TransferVenue is an account on an exchange that the Rebalancer can move funds into and out of
+type TransferVenue interface {
+	api.Account
+	api.DepositAPI
+	api.WithdrawAPI
+}
+
+// RebalanceRule configures how an asset is balanced between the primary and backing venues
+type RebalanceRule struct {
+	PrimaryAsset    model.Asset
+	BackingAsset    model.Asset
+	TargetPrimary   float64       // fraction of the total that should be held on the primary venue, in the range [0, 1]
+	Threshold       float64       // rebalance when the fraction on the primary venue is more than this away from TargetPrimary
+	MinTransfer     *model.Number // transfers smaller than this are skipped
+	MaxTransfer     *model.Number // transfers are capped at this amount, can be nil
+	MaxFeeFraction  float64       // transfers are skipped when fees (withdrawal and deposit) are more than this fraction of the amount sent
+	ConfirmFraction float64       // a transfer is confirmed once the destination balance grew by this fraction of the amount we expect to receive
+}
+
+// pendingTransfer is a transfer that was started and that we are waiting on to be confirmed
+type pendingTransfer struct {
+	withdrawalID        string // empty when the withdrawal returned an error or the bot stopped before it returned
+	fromPrimary         bool
+	amount              *model.Number
+	expectedReceive     *model.Number
+	startingDestBalance *model.Number
+	startTime           time.Time
+
+	// uninitialized
+	alerted bool // the timeout alert was triggered, not persisted so a restarted bot alerts again
+}
+
+// String impl
+func (t *pendingTransfer) String() string {
+	direction := "backing->primary"
+	if t.fromPrimary {
+		direction = "primary->backing"
+	}
+	return fmt.Sprintf("pendingTransfer(id=%s, %s, amount=%s, expectedReceive=%s, startingDestBalance=%s, startTime=%s)",
+		t.withdrawalID, direction, t.amount.AsString(), t.expectedReceive.AsString(), t.startingDestBalance.AsString(), t.startTime.Format(time.RFC3339))
+}
+
+// Rebalancer watches the balances of assets on the primary and backing venues and moves funds between them when they drift too far from
+// the target. Only one transfer per asset is in flight at a time and it is persisted in the store before the withdrawal is made, so no
+// new transfer is started for the asset until the pending one is resolved, even across restarts.
+//
+// When the source venue implements api.WithdrawStatusAPI the withdrawal is looked up by its ID: a failed withdrawal resolves the transfer
+// and a withdrawal that was not sent yet cannot be confirmed. A sent withdrawal is confirmed by the balance on the destination venue, which
+// also moves with fills, so the confirm fraction should leave room for fills. A transfer that is not confirmed within the confirm timeout
+// triggers an alert and keeps blocking the asset, delete its row from the rebalancer_transfers table once it is resolved by hand.
+type Rebalancer struct {
+	primary        TransferVenue
+	backing        TransferVenue
+	rules          []RebalanceRule
+	checkInterval  time.Duration
+	confirmTimeout time.Duration
+	store          transferStore
+	alert          api.Alert
+	nowFn          func() time.Time
+
+	// uninitialized
+	pending   map[model.Asset]*pendingTransfer // keyed by the primary asset of the rule
+	lastCheck time.Time
+}
+
+// MakeRebalancer is a factory method
+func MakeRebalancer(
+	primary TransferVenue,
+	backing TransferVenue,
+	rules []RebalanceRule,
+	checkInterval time.Duration,
+	confirmTimeout time.Duration,
+	store transferStore,
+	alert api.Alert,
+) (*Rebalancer, error) {
+	for i, r := range rules {
+		if r.TargetPrimary < 0 || r.TargetPrimary > 1 {
+			return nil, fmt.Errorf("rule at index %d: target primary fraction needs to be in the range [0, 1], was %f", i, r.TargetPrimary)
+		}
+		if r.Threshold <= 0 || r.Threshold >= 1 {
+			return nil, fmt.Errorf("rule at index %d: threshold needs to be in the range (0, 1), was %f", i, r.Threshold)
+		}
+		if r.MaxFeeFraction < 0 || r.MaxFeeFraction >= 1 {
+			return nil, fmt.Errorf("rule at index %d: max fee fraction needs to be in the range [0, 1), was %f", i, r.MaxFeeFraction)
+		}
+		if r.ConfirmFraction <= 0 || r.ConfirmFraction > 1 {
+			return nil, fmt.Errorf("rule at index %d: confirm fraction needs to be in the range (0, 1], was %f", i, r.ConfirmFraction)
+		}
+		if r.MinTransfer == nil || r.MinTransfer.Sign() <= 0 {
+			return nil, fmt.Errorf("rule at index %d: min transfer needs to be positive", i)
+		}
+		if r.MaxTransfer != nil && r.MaxTransfer.Cmp(*r.MinTransfer) < 0 {
+			return nil, fmt.Errorf("rule at index %d: max transfer (%s) cannot be less than min transfer (%s)", i, r.MaxTransfer.AsString(), r.MinTransfer.AsString())
+		}
+	}
+
+	pending, e := store.loadPendingTransfers()
+	if e != nil {
+		return nil, fmt.Errorf("could not load pending transfers: %s", e)
+	}
+	for asset, pt := range pending {
+		log.Printf("rebalancer: loaded pending transfer for %s: %s\n", asset, pt)
+	}
+
+	return &Rebalancer{
+		primary:        primary,
+		backing:        backing,
+		rules:          rules,
+		checkInterval:  checkInterval,
+		confirmTimeout: confirmTimeout,
+		store:          store,
+		alert:          alert,
+		nowFn:          time.Now,
+		pending:        pending,
+	}, nil
+}
+
+// MaybeRun runs the Rebalancer if checkInterval has passed since the last run, this is meant to be called on every update of the bot
+func (r *Rebalancer) MaybeRun() error {
+	now := r.nowFn()
+	if !r.lastCheck.IsZero() && now.Sub(r.lastCheck) < r.checkInterval {
+		return nil
+	}
+	r.lastCheck = now
+	return r.Run()
+}
+
+// Run checks every rule once, confirming pending transfers and starting new ones where needed. Errors on a rule do not stop the
+// other rules from being checked.
+func (r *Rebalancer) Run() error {
+	errs := []error{}
+	for _, rule := range r.rules {
+		e := r.runRule(rule)
+		if e != nil {
+			log.Printf("rebalancer: error on rule for %s: %s\n", rule.PrimaryAsset, e)
+			errs = append(errs, e)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("rebalancer encountered %d errors, first error: %s", len(errs), errs[0])
+	}
+	return nil
+}
+
+func (r *Rebalancer) runRule(rule RebalanceRule) error {
+	primaryBalance, e := venueBalance(r.primary, rule.PrimaryAsset)
+	if e != nil {
+		return fmt.Errorf("could not fetch balance of %s on primary venue: %s", rule.PrimaryAsset, e)
+	}
+	backingBalance, e := venueBalance(r.backing, rule.BackingAsset)
+	if e != nil {
+		return fmt.Errorf("could not fetch balance of %s on backing venue: %s", rule.BackingAsset, e)
+	}
+
+	if pt, ok := r.pending[rule.PrimaryAsset]; ok {
+		return r.checkPendingTransfer(rule, pt, primaryBalance, backingBalance)
+	}
+
+	amount, fromPrimary := computeRebalanceAmount(rule, primaryBalance, backingBalance)
+	if amount == nil {
+		return nil
+	}
+	log.Printf("rebalancer: %s is out of balance (primary=%s, backing=%s, targetPrimary=%.4f), moving %s from the %s venue\n",
+		rule.PrimaryAsset, primaryBalance.AsString(), backingBalance.AsString(), rule.TargetPrimary, amount.AsString(), venueName(fromPrimary))
+
+	from, to := r.backing, r.primary
+	fromAsset, toAsset := rule.BackingAsset, rule.PrimaryAsset
+	startingDestBalance := primaryBalance
+	if fromPrimary {
+		from, to = r.primary, r.backing
+		fromAsset, toAsset = rule.PrimaryAsset, rule.BackingAsset
+		startingDestBalance = backingBalance
+	}
+
+	now := r.nowFn()
+	pt, e := startTransfer(from, fromAsset, to, toAsset, amount, rule.MaxFeeFraction, now, func(pt *pendingTransfer) error {
+		pt.fromPrimary = fromPrimary
+		pt.startingDestBalance = startingDestBalance
+		pt.startTime = now
+		// persist the transfer before withdrawing so a restart in between does not withdraw again
+		e := r.store.savePendingTransfer(rule.PrimaryAsset, pt)
+		if e != nil {
+			return fmt.Errorf("could not save the transfer before withdrawing, not withdrawing: %s", e)
+		}
+		r.pending[rule.PrimaryAsset] = pt
+		return nil
+	})
+	if e != nil {
+		if pt, ok := r.pending[rule.PrimaryAsset]; ok {
+			// the withdrawal can have been made even though it returned an error (e.g. on a timeout), so the transfer stays pending until it
+			// is confirmed by the balance or it times out
+			log.Printf("rebalancer: withdrawal returned an error, keeping the transfer pending: %s\n", pt)
+		}
+		return e
+	}
+	if pt == nil {
+		return nil
+	}
+
+	e = r.store.savePendingTransfer(rule.PrimaryAsset, pt)
+	if e != nil {
+		// the transfer stays pending in the store without an ID so the asset remains blocked
+		return fmt.Errorf("could not save the withdrawal ID of %s: %s", pt, e)
+	}
+	log.Printf("rebalancer: started %s\n", pt)
+	return nil
+}
+
+// resolvePendingTransfer stops tracking the pending transfer of the asset, cause is returned as the error when it is non-nil
+func (r *Rebalancer) resolvePendingTransfer(asset model.Asset, cause error) error {
+	e := r.store.deletePendingTransfer(asset)
+	if e != nil {
+		// keep tracking the transfer in memory so the delete is retried on the next check
+		return fmt.Errorf("could not delete pending transfer of %s from the store: %s", asset, e)
+	}
+	delete(r.pending, asset)
+	return cause
+}
+
+func (r *Rebalancer) checkPendingTransfer(rule RebalanceRule, pt *pendingTransfer, primaryBalance *model.Number, backingBalance *model.Number) error {
+	from, fromAsset := r.backing, rule.BackingAsset
+	destBalance := primaryBalance
+	if pt.fromPrimary {
+		from, fromAsset = r.primary, rule.PrimaryAsset
+		destBalance = backingBalance
+	}
+	received := destBalance.Subtract(*pt.startingDestBalance)
+
+	if statusAPI, ok := from.(api.WithdrawStatusAPI); ok && pt.withdrawalID != "" {
+		status, e := statusAPI.GetWithdrawalStatus(fromAsset, pt.withdrawalID)
+		if e != nil {
+			return fmt.Errorf("could not fetch the withdrawal status of %s: %s", pt, e)
+		}
+		switch status {
+		case api.WithdrawalStatusFailed:
+			return r.resolvePendingTransfer(rule.PrimaryAsset, fmt.Errorf("withdrawal failed on the %s venue, no funds were sent: %s", venueName(pt.fromPrimary), pt))
+		case api.WithdrawalStatusPending:
+			log.Printf("rebalancer: waiting on the %s venue to send %s\n", venueName(pt.fromPrimary), pt)
+			return r.checkTimeout(pt, received)
+		}
+	}
+
+	needed := pt.expectedReceive.Scale(rule.ConfirmFraction)
+	if received.Cmp(*needed) >= 0 {
+		log.Printf("rebalancer: confirmed %s, received %s\n", pt, received.AsString())
+		return r.resolvePendingTransfer(rule.PrimaryAsset, nil)
+	}
+	log.Printf("rebalancer: waiting on %s, received %s so far\n", pt, received.AsString())
+	return r.checkTimeout(pt, received)
+}
+
+// checkTimeout triggers an alert once when the transfer was not confirmed within the confirm timeout, the transfer stays pending
+func (r *Rebalancer) checkTimeout(pt *pendingTransfer, received *model.Number) error {
+	if pt.alerted || r.nowFn().Sub(pt.startTime) <= r.confirmTimeout {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"withdrawal_id":    pt.withdrawalID,
+		"direction":        venueName(pt.fromPrimary) + "->" + venueName(!pt.fromPrimary),
+		"amount":           pt.amount.AsString(),
+		"expected_receive": pt.expectedReceive.AsString(),
+		"received":         received.AsString(),
+		"start_time":       pt.startTime.Format(time.RFC3339),
+	}
+	e := r.alert.Trigger("rebalancer transfer was not confirmed in time, no new transfers are started for the asset until it is resolved", details)
+	if e != nil {
+		log.Printf("rebalancer: unable to trigger alert: %s\n", e)
+	}
+	pt.alerted = true
+	return fmt.Errorf("transfer was not confirmed within %s (received %s so far), blocking new transfers for the asset until it is resolved: %s", r.confirmTimeout, received.AsString(), pt)
+}
+
+// computeRebalanceAmount returns the amount to move and the direction, or a nil amount if no transfer is needed
+func computeRebalanceAmount(rule RebalanceRule, primaryBalance *model.Number, backingBalance *model.Number) (*model.Number, bool) {
+	total := primaryBalance.Add(*backingBalance)
+	if total.Sign() <= 0 {
+		return nil, false
+	}
+
+	primaryFraction := primaryBalance.Divide(*total).AsFloat()
+	if primaryFraction > rule.TargetPrimary-rule.Threshold && primaryFraction < rule.TargetPrimary+rule.Threshold {
+		return nil, false
+	}
+
+	targetPrimaryBalance := total.Scale(rule.TargetPrimary)
+	diff := primaryBalance.Subtract(*targetPrimaryBalance)
+	fromPrimary := diff.Sign() > 0
+	amount := model.NumberByCappingPrecisionWithRounding(diff.Abs(), minPrecision(primaryBalance, backingBalance), model.RoundFloor)
+	if rule.MaxTransfer != nil && amount.Cmp(*rule.MaxTransfer) > 0 {
+		amount = rule.MaxTransfer
+	}
+	if amount.Cmp(*rule.MinTransfer) < 0 {
+		log.Printf("rebalancer: skipping transfer of %s because it is less than the min transfer of %s\n", amount.AsString(), rule.MinTransfer.AsString())
+		return nil, false
+	}
+	return amount, fromPrimary
+}
+
+// startTransfer gets deposit instructions from the destination venue, checks limits and fees with the source venue, and then withdraws to
+// the destination. beforeWithdraw is called with the transfer right before the withdrawal and the withdrawal is not made if it returns an
+// error. Returns a nil pendingTransfer when the transfer was skipped because of fees
+func startTransfer(
+	from TransferVenue,
+	fromAsset model.Asset,
+	to TransferVenue,
+	toAsset model.Asset,
+	amount *model.Number,
+	maxFeeFraction float64,
+	now time.Time,
+	beforeWithdraw func(pt *pendingTransfer) error,
+) (*pendingTransfer, error) {
+	deposit, e := to.PrepareDeposit(toAsset, amount)
+	if e != nil {
+		return nil, fmt.Errorf("could not prepare deposit of %s %s: %s", amount.AsString(), toAsset, e)
+	}
+	if deposit.ExpireTs != 0 && time.Unix(deposit.ExpireTs, 0).Before(now) {
+		return nil, fmt.Errorf("deposit address %s expired at %d", deposit.Address, deposit.ExpireTs)
+	}
+
+	withdrawInfo, e := from.GetWithdrawInfo(fromAsset, amount, deposit.Address)
+	if e != nil {
+		return nil, fmt.Errorf("could not get withdraw info for %s %s: %s", amount.AsString(), fromAsset, e)
+	}
+	expectedReceive := withdrawInfo.AmountToReceive
+	if deposit.Fee != nil {
+		expectedReceive = expectedReceive.Subtract(*deposit.Fee)
+	}
+	if expectedReceive.Sign() <= 0 {
+		log.Printf("rebalancer: skipping transfer of %s %s because fees would consume the full amount\n", amount.AsString(), fromAsset)
+		return nil, nil
+	}
+	feeFraction := amount.Subtract(*expectedReceive).Divide(*amount).AsFloat()
+	if feeFraction > maxFeeFraction {
+		log.Printf("rebalancer: skipping transfer of %s %s because fees would be %.4f of the amount, more than the max fee fraction of %.4f\n", amount.AsString(), fromAsset, feeFraction, maxFeeFraction)
+		return nil, nil
+	}
+
+	memoFrom, canAttachMemo := from.(api.MemoWithdrawAPI)
+	if deposit.Memo != "" && !canAttachMemo {
+		return nil, fmt.Errorf("deposit address %s needs a memo but the source venue cannot attach a memo to withdrawals", deposit.Address)
+	}
+
+	pt := &pendingTransfer{
+		amount:          amount,
+		expectedReceive: expectedReceive,
+	}
+	e = beforeWithdraw(pt)
+	if e != nil {
+		return nil, e
+	}
+
+	var result *api.WithdrawFunds
+	if deposit.Memo != "" {
+		result, e = memoFrom.WithdrawFundsWithMemo(fromAsset, amount, deposit.Address, deposit.Memo)
+	} else {
+		result, e = from.WithdrawFunds(fromAsset, amount, deposit.Address)
+	}
+	if e != nil {
+		return nil, fmt.Errorf("could not withdraw %s %s to %s: %s", amount.AsString(), fromAsset, deposit.Address, e)
+	}
+	pt.withdrawalID = result.WithdrawalID
+	return pt, nil
+}
+
+func venueBalance(venue TransferVenue, asset model.Asset) (*model.Number, error) {
+	balances, e := venue.GetAccountBalances([]interface{}{asset})
+	if e != nil {
+		return nil, e
+	}
+	balance, ok := balances[asset]
+	if !ok {
+		return nil, fmt.Errorf("balance for asset %s was not returned", asset)
+	}
+	return &balance, nil
+}
+
+func venueName(primary bool) string {
+	if primary {
+		return "primary"
+	}
+	return "backing"
+}
+
+func minPrecision(a *model.Number, b *model.Number) int8 {
+	if a.Precision() < b.Precision() {
+		return a.Precision()
+	}
+	return b.Precision()
+}
@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/stellar/kelp/kelpdb"
+	"github.com/stellar/kelp/model"
+)
+
+// transferStore persists the pending transfers of the Rebalancer so a restarted bot does not start a second transfer for an asset
+type transferStore interface {
+	// loadPendingTransfers returns the pending transfers keyed by the primary asset of their rule
+	loadPendingTransfers() (map[model.Asset]*pendingTransfer, error)
+	savePendingTransfer(asset model.Asset, pt *pendingTransfer) error
+	deletePendingTransfer(asset model.Asset) error
+}
+
+// dbTransferStore keeps the pending transfers of a market in the rebalancer_transfers table
+type dbTransferStore struct {
+	db       *sql.DB
+	marketID string
+}
+
+var _ transferStore = &dbTransferStore{}
+
+// makeDbTransferStore is a factory method
+func makeDbTransferStore(db *sql.DB, marketID string) *dbTransferStore {
+	return &dbTransferStore{
+		db:       db,
+		marketID: marketID,
+	}
+}
+
+func (s *dbTransferStore) loadPendingTransfers() (map[model.Asset]*pendingTransfer, error) {
+	rows, e := s.db.Query(kelpdb.SqlQueryRebalancerTransfers, s.marketID)
+	if e != nil {
+		return nil, fmt.Errorf("could not execute sql select query (%s): %s", kelpdb.SqlQueryRebalancerTransfers, e)
+	}
+	defer rows.Close()
+
+	pending := map[model.Asset]*pendingTransfer{}
+	for rows.Next() {
+		var asset, amount, expectedReceive, startingDestBalance string
+		var pt pendingTransfer
+		e = rows.Scan(&asset, &pt.withdrawalID, &pt.fromPrimary, &amount, &expectedReceive, &startingDestBalance, &pt.startTime)
+		if e != nil {
+			return nil, fmt.Errorf("could not scan row into pendingTransfer struct: %s", e)
+		}
+		pt.amount, e = numberFromDbString(amount)
+		if e != nil {
+			return nil, e
+		}
+		pt.expectedReceive, e = numberFromDbString(expectedReceive)
+		if e != nil {
+			return nil, e
+		}
+		pt.startingDestBalance, e = numberFromDbString(startingDestBalance)
+		if e != nil {
+			return nil, e
+		}
+		pending[model.Asset(asset)] = &pt
+	}
+	return pending, nil
+}
+
+func (s *dbTransferStore) savePendingTransfer(asset model.Asset, pt *pendingTransfer) error {
+	_, e := s.db.Exec(kelpdb.SqlRebalancerTransfersUpsert,
+		s.marketID,
+		string(asset),
+		pt.withdrawalID,
+		pt.fromPrimary,
+		pt.amount.AsString(),
+		pt.expectedReceive.AsString(),
+		pt.startingDestBalance.AsString(),
+		pt.startTime.UTC(),
+	)
+	if e != nil {
+		return fmt.Errorf("could not execute sql upsert statement (%s): %s", kelpdb.SqlRebalancerTransfersUpsert, e)
+	}
+	return nil
+}
+
+func (s *dbTransferStore) deletePendingTransfer(asset model.Asset) error {
+	_, e := s.db.Exec(kelpdb.SqlRebalancerTransfersDelete, s.marketID, string(asset))
+	if e != nil {
+		return fmt.Errorf("could not execute sql delete statement (%s): %s", kelpdb.SqlRebalancerTransfersDelete, e)
+	}
+	return nil
+}
+
+// numberFromDbString parses an amount that was written with AsString, keeping its precision
+func numberFromDbString(s string) (*model.Number, error) {
+	precision := 0
+	if i := strings.Index(s, "."); i >= 0 {
+		precision = len(s) - i - 1
+	}
+	n, e := model.NumberFromString(s, int8(precision))
+	if e != nil {
+		return nil, fmt.Errorf("could not parse amount '%s' from the db: %s", s, e)
+	}
+	return n, nil
+}
@@ -0,0 +1,531 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+)
+
+type mockWithdrawal struct {
+	asset   model.Asset
+	amount  *model.Number
+	address string
+	memo    string
+}
+
+// mockTransferVenue keeps balances in memory, withdrawals are debited immediately and need to be credited to the destination by the test
+type mockTransferVenue struct {
+	balances       map[model.Asset]*model.Number
+	depositAddress string
+	depositMemo    string
+	depositFee     *model.Number
+	withdrawFee    *model.Number
+	withdrawLimit  *model.Number
+	withdrawals    []mockWithdrawal
+}
+
+var _ TransferVenue = &mockTransferVenue{}
+
+func makeMockTransferVenue(address string, asset model.Asset, balance float64) *mockTransferVenue {
+	return &mockTransferVenue{
+		balances:       map[model.Asset]*model.Number{asset: model.NumberFromFloat(balance, 7)},
+		depositAddress: address,
+		depositFee:     model.NumberConstants.Zero,
+		withdrawFee:    model.NumberConstants.Zero,
+		withdrawals:    []mockWithdrawal{},
+	}
+}
+
+func (v *mockTransferVenue) credit(asset model.Asset, amount float64) {
+	v.balances[asset] = v.balances[asset].Add(*model.NumberFromFloat(amount, 7))
+}
+
+// GetAccountBalances impl
+func (v *mockTransferVenue) GetAccountBalances(assetList []interface{}) (map[interface{}]model.Number, error) {
+	m := map[interface{}]model.Number{}
+	for _, a := range assetList {
+		asset := a.(model.Asset)
+		if b, ok := v.balances[asset]; ok {
+			m[asset] = *b
+		}
+	}
+	return m, nil
+}
+
+// PrepareDeposit impl
+func (v *mockTransferVenue) PrepareDeposit(asset model.Asset, amount *model.Number) (*api.PrepareDepositResult, error) {
+	return &api.PrepareDepositResult{
+		Fee:     v.depositFee,
+		Address: v.depositAddress,
+		Memo:    v.depositMemo,
+	}, nil
+}
+
+// GetWithdrawInfo impl
+func (v *mockTransferVenue) GetWithdrawInfo(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawInfo, error) {
+	if v.withdrawLimit != nil && amountToWithdraw.Cmp(*v.withdrawLimit) > 0 {
+		return nil, api.MakeErrWithdrawAmountAboveLimit(amountToWithdraw, v.withdrawLimit)
+	}
+	return &api.WithdrawInfo{
+		AmountToReceive: amountToWithdraw.Subtract(*v.withdrawFee),
+	}, nil
+}
+
+// WithdrawFunds impl
+func (v *mockTransferVenue) WithdrawFunds(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawFunds, error) {
+	return v.withdraw(asset, amountToWithdraw, address, "")
+}
+
+func (v *mockTransferVenue) withdraw(asset model.Asset, amountToWithdraw *model.Number, address string, memo string) (*api.WithdrawFunds, error) {
+	v.balances[asset] = v.balances[asset].Subtract(*amountToWithdraw)
+	v.withdrawals = append(v.withdrawals, mockWithdrawal{
+		asset:   asset,
+		amount:  amountToWithdraw,
+		address: address,
+		memo:    memo,
+	})
+	return &api.WithdrawFunds{
+		WithdrawalID: fmt.Sprintf("withdrawal%d", len(v.withdrawals)),
+	}, nil
+}
+
+// mockMemoTransferVenue is a mockTransferVenue that can attach memos to withdrawals
+type mockMemoTransferVenue struct {
+	*mockTransferVenue
+}
+
+var _ api.MemoWithdrawAPI = &mockMemoTransferVenue{}
+
+// WithdrawFundsWithMemo impl
+func (v *mockMemoTransferVenue) WithdrawFundsWithMemo(asset model.Asset, amountToWithdraw *model.Number, address string, memo string) (*api.WithdrawFunds, error) {
+	return v.withdraw(asset, amountToWithdraw, address, memo)
+}
+
+// mockStatusTransferVenue is a mockTransferVenue that reports the status of its withdrawals, withdrawals are sent unless the test sets a status
+type mockStatusTransferVenue struct {
+	*mockTransferVenue
+	statuses map[string]api.WithdrawalStatus
+}
+
+var _ api.WithdrawStatusAPI = &mockStatusTransferVenue{}
+
+// GetWithdrawalStatus impl
+func (v *mockStatusTransferVenue) GetWithdrawalStatus(asset model.Asset, withdrawalID string) (api.WithdrawalStatus, error) {
+	if status, ok := v.statuses[withdrawalID]; ok {
+		return status, nil
+	}
+	return api.WithdrawalStatusSent, nil
+}
+
+// memoryTransferStore keeps copies of the pending transfers like the db would
+type memoryTransferStore struct {
+	pending  map[model.Asset]pendingTransfer
+	saveFail bool
+}
+
+var _ transferStore = &memoryTransferStore{}
+
+func makeMemoryTransferStore() *memoryTransferStore {
+	return &memoryTransferStore{pending: map[model.Asset]pendingTransfer{}}
+}
+
+func (s *memoryTransferStore) loadPendingTransfers() (map[model.Asset]*pendingTransfer, error) {
+	m := map[model.Asset]*pendingTransfer{}
+	for asset, pt := range s.pending {
+		ptCopy := pt
+		m[asset] = &ptCopy
+	}
+	return m, nil
+}
+
+func (s *memoryTransferStore) savePendingTransfer(asset model.Asset, pt *pendingTransfer) error {
+	if s.saveFail {
+		return fmt.Errorf("save failed")
+	}
+	s.pending[asset] = *pt
+	return nil
+}
+
+func (s *memoryTransferStore) deletePendingTransfer(asset model.Asset) error {
+	delete(s.pending, asset)
+	return nil
+}
+
+func makeTestRebalanceRule() RebalanceRule {
+	return RebalanceRule{
+		PrimaryAsset:    model.XLM,
+		BackingAsset:    model.XLM,
+		TargetPrimary:   0.5,
+		Threshold:       0.1,
+		MinTransfer:     model.NumberFromFloat(1.0, 7),
+		MaxFeeFraction:  0.01,
+		ConfirmFraction: 0.99,
+	}
+}
+
+func makeTestRebalancer(t *testing.T, primary TransferVenue, backing TransferVenue, rule RebalanceRule, store *memoryTransferStore, now *time.Time) *Rebalancer {
+	r, e := MakeRebalancer(primary, backing, []RebalanceRule{rule}, time.Minute, time.Hour, store, &recordingAlert{})
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	r.nowFn = func() time.Time { return *now }
+	return r
+}
+
+func TestMakeRebalancer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		modify    func(r *RebalanceRule)
+		wantError bool
+	}{
+		{
+			name:   "valid",
+			modify: func(r *RebalanceRule) {},
+		}, {
+			name:      "target out of range",
+			modify:    func(r *RebalanceRule) { r.TargetPrimary = 1.5 },
+			wantError: true,
+		}, {
+			name:      "zero threshold",
+			modify:    func(r *RebalanceRule) { r.Threshold = 0 },
+			wantError: true,
+		}, {
+			name:      "zero confirm fraction",
+			modify:    func(r *RebalanceRule) { r.ConfirmFraction = 0 },
+			wantError: true,
+		}, {
+			name:      "missing min transfer",
+			modify:    func(r *RebalanceRule) { r.MinTransfer = nil },
+			wantError: true,
+		}, {
+			name:      "max transfer less than min transfer",
+			modify:    func(r *RebalanceRule) { r.MaxTransfer = model.NumberFromFloat(0.5, 7) },
+			wantError: true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			rule := makeTestRebalanceRule()
+			k.modify(&rule)
+			_, e := MakeRebalancer(&mockTransferVenue{}, &mockTransferVenue{}, []RebalanceRule{rule}, time.Minute, time.Hour, makeMemoryTransferStore(), &recordingAlert{})
+			if k.wantError {
+				assert.Error(t, e)
+			} else {
+				assert.NoError(t, e)
+			}
+		})
+	}
+}
+
+func TestComputeRebalanceAmount(t *testing.T) {
+	testCases := []struct {
+		name            string
+		primary         float64
+		backing         float64
+		maxTransfer     *model.Number
+		wantAmount      *model.Number
+		wantFromPrimary bool
+	}{
+		{
+			name:       "within threshold",
+			primary:    55,
+			backing:    45,
+			wantAmount: nil,
+		}, {
+			name:            "too much on primary",
+			primary:         80,
+			backing:         20,
+			wantAmount:      model.NumberFromFloat(30, 7),
+			wantFromPrimary: true,
+		}, {
+			name:            "too much on backing",
+			primary:         10,
+			backing:         90,
+			wantAmount:      model.NumberFromFloat(40, 7),
+			wantFromPrimary: false,
+		}, {
+			name:            "capped by max transfer",
+			primary:         80,
+			backing:         20,
+			maxTransfer:     model.NumberFromFloat(10, 7),
+			wantAmount:      model.NumberFromFloat(10, 7),
+			wantFromPrimary: true,
+		}, {
+			name:       "less than min transfer",
+			primary:    1.8,
+			backing:    0.2,
+			wantAmount: nil,
+		}, {
+			name:       "no balance",
+			primary:    0,
+			backing:    0,
+			wantAmount: nil,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			rule := makeTestRebalanceRule()
+			rule.MaxTransfer = k.maxTransfer
+			amount, fromPrimary := computeRebalanceAmount(rule, model.NumberFromFloat(k.primary, 7), model.NumberFromFloat(k.backing, 7))
+			if k.wantAmount == nil {
+				assert.Nil(t, amount)
+				return
+			}
+			if assert.NotNil(t, amount) {
+				assert.Equal(t, k.wantAmount.AsString(), amount.AsString())
+				assert.Equal(t, k.wantFromPrimary, fromPrimary)
+			}
+		})
+	}
+}
+
+func TestRebalancerTransferLifecycle(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := makeMockTransferVenue("primaryAddress", model.XLM, 80)
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+	backing.depositFee = model.NumberFromFloat(0.1, 7)
+	store := makeMemoryTransferStore()
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), store, &now)
+
+	// starts a transfer from the primary venue to the deposit address on the backing venue
+	assert.NoError(t, r.Run())
+	if !assert.Equal(t, 1, len(primary.withdrawals)) {
+		return
+	}
+	assert.Equal(t, "30.0000000", primary.withdrawals[0].amount.AsString())
+	assert.Equal(t, "backingAddress", primary.withdrawals[0].address)
+	assert.Equal(t, 0, len(backing.withdrawals))
+
+	// does not start another transfer while the first one is pending, even though the balances are out of range
+	now = now.Add(time.Minute)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(primary.withdrawals))
+	assert.Equal(t, 1, len(r.pending))
+	if assert.Equal(t, 1, len(store.pending)) {
+		assert.Equal(t, "withdrawal1", store.pending[model.XLM].withdrawalID)
+		assert.True(t, store.pending[model.XLM].fromPrimary)
+	}
+
+	// confirms once the expected amount (less the deposit fee) arrives
+	backing.credit(model.XLM, 29.9)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 0, len(r.pending))
+	assert.Equal(t, 0, len(store.pending))
+
+	// balances are within the threshold now
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(primary.withdrawals))
+	assert.Equal(t, 0, len(backing.withdrawals))
+}
+
+func TestRebalancerTransferTimeout(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := makeMockTransferVenue("primaryAddress", model.XLM, 10)
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 90)
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), makeMemoryTransferStore(), &now)
+
+	assert.NoError(t, r.Run())
+	if !assert.Equal(t, 1, len(backing.withdrawals)) {
+		return
+	}
+	assert.Equal(t, "40.0000000", backing.withdrawals[0].amount.AsString())
+	assert.Equal(t, "primaryAddress", backing.withdrawals[0].address)
+
+	// a partial receipt does not confirm the transfer
+	primary.credit(model.XLM, 20)
+	now = now.Add(30 * time.Minute)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(r.pending))
+
+	// alerts once and keeps blocking new transfers for the asset
+	now = now.Add(time.Hour)
+	assert.Error(t, r.Run())
+	assert.Equal(t, 1, len(r.pending))
+	now = now.Add(time.Hour)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(r.pending))
+	assert.Equal(t, 1, len(r.alert.(*recordingAlert).descriptions))
+	assert.Equal(t, 1, len(backing.withdrawals))
+}
+
+func TestRebalancerPendingTransferSurvivesRestart(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := makeMockTransferVenue("primaryAddress", model.XLM, 80)
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+	store := makeStoreWithPendingTransfer(t, primary, backing, &now)
+
+	// the restarted bot loads the pending transfer and does not withdraw again while the funds are in transit
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), store, &now)
+	assert.Equal(t, 1, len(r.pending))
+	now = now.Add(time.Minute)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(primary.withdrawals))
+
+	backing.credit(model.XLM, 30)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 0, len(r.pending))
+	assert.Equal(t, 0, len(store.pending))
+}
+
+// makeStoreWithPendingTransfer starts a transfer from the primary venue with a rebalancer that is then discarded, like a bot that stopped
+func makeStoreWithPendingTransfer(t *testing.T, primary TransferVenue, backing TransferVenue, now *time.Time) *memoryTransferStore {
+	store := makeMemoryTransferStore()
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), store, now)
+	if !assert.NoError(t, r.Run()) || !assert.Equal(t, 1, len(store.pending)) {
+		t.FailNow()
+	}
+	return store
+}
+
+func TestRebalancerWithdrawalStatus(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := &mockStatusTransferVenue{
+		mockTransferVenue: makeMockTransferVenue("primaryAddress", model.XLM, 80),
+		statuses:          map[string]api.WithdrawalStatus{"withdrawal1": api.WithdrawalStatusPending},
+	}
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+	store := makeMemoryTransferStore()
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), store, &now)
+
+	assert.NoError(t, r.Run())
+	if !assert.Equal(t, 1, len(primary.withdrawals)) {
+		return
+	}
+
+	// a fill on the backing venue does not confirm a withdrawal that was not sent yet
+	backing.credit(model.XLM, 30)
+	now = now.Add(time.Minute)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 1, len(r.pending))
+
+	// the funds return to the primary venue when the withdrawal fails, which resolves the transfer
+	primary.statuses["withdrawal1"] = api.WithdrawalStatusFailed
+	primary.credit(model.XLM, 30)
+	backing.credit(model.XLM, -30)
+	assert.Error(t, r.Run())
+	assert.Equal(t, 0, len(r.pending))
+	assert.Equal(t, 0, len(store.pending))
+
+	// so the next check starts a new transfer, which is confirmed by the balance once it is sent
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 2, len(primary.withdrawals))
+	backing.credit(model.XLM, 30)
+	assert.NoError(t, r.Run())
+	assert.Equal(t, 0, len(r.pending))
+}
+
+func TestRebalancerDoesNotWithdrawWhenSaveFails(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := makeMockTransferVenue("primaryAddress", model.XLM, 80)
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+	store := makeMemoryTransferStore()
+	store.saveFail = true
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), store, &now)
+
+	assert.Error(t, r.Run())
+	assert.Equal(t, 0, len(primary.withdrawals))
+	assert.Equal(t, 0, len(r.pending))
+}
+
+func TestRebalancerSkipsTransfers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		modify    func(primary *mockTransferVenue, backing *mockTransferVenue)
+		wantError bool
+	}{
+		{
+			name: "withdraw limit",
+			modify: func(primary *mockTransferVenue, backing *mockTransferVenue) {
+				primary.withdrawLimit = model.NumberFromFloat(10, 7)
+			},
+			wantError: true,
+		}, {
+			name: "withdraw fee above max fee fraction",
+			modify: func(primary *mockTransferVenue, backing *mockTransferVenue) {
+				primary.withdrawFee = model.NumberFromFloat(1, 7)
+			},
+		}, {
+			name: "deposit fee above max fee fraction",
+			modify: func(primary *mockTransferVenue, backing *mockTransferVenue) {
+				backing.depositFee = model.NumberFromFloat(1, 7)
+			},
+		}, {
+			name: "deposit needs a memo that the source venue cannot attach",
+			modify: func(primary *mockTransferVenue, backing *mockTransferVenue) {
+				backing.depositMemo = "12345"
+			},
+			wantError: true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			now := time.Unix(1600000000, 0)
+			primary := makeMockTransferVenue("primaryAddress", model.XLM, 80)
+			backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+			k.modify(primary, backing)
+			r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), makeMemoryTransferStore(), &now)
+
+			e := r.Run()
+			if k.wantError {
+				assert.Error(t, e)
+			} else {
+				assert.NoError(t, e)
+			}
+			assert.Equal(t, 0, len(primary.withdrawals))
+			assert.Equal(t, 0, len(r.pending))
+			assert.Equal(t, 0, len(r.store.(*memoryTransferStore).pending))
+		})
+	}
+}
+
+func TestRebalancerWithdrawsWithMemo(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := &mockMemoTransferVenue{makeMockTransferVenue("primaryAddress", model.XLM, 80)}
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 20)
+	backing.depositMemo = "12345"
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), makeMemoryTransferStore(), &now)
+
+	assert.NoError(t, r.Run())
+	if assert.Equal(t, 1, len(primary.withdrawals)) {
+		assert.Equal(t, "12345", primary.withdrawals[0].memo)
+	}
+}
+
+func TestRebalancerMaybeRun(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	primary := makeMockTransferVenue("primaryAddress", model.XLM, 50)
+	backing := makeMockTransferVenue("backingAddress", model.XLM, 50)
+	r := makeTestRebalancer(t, primary, backing, makeTestRebalanceRule(), makeMemoryTransferStore(), &now)
+
+	assert.NoError(t, r.MaybeRun())
+	primary.credit(model.XLM, 100)
+
+	// balances are out of range but the check interval has not passed yet
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, r.MaybeRun())
+	assert.Equal(t, 0, len(primary.withdrawals))
+
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, r.MaybeRun())
+	assert.Equal(t, 1, len(primary.withdrawals))
+}
+
+func TestNumberFromDbString(t *testing.T) {
+	for _, s := range []string{"30.0000000", "0.0012345678", "42"} {
+		n, e := numberFromDbString(s)
+		if assert.NoError(t, e) {
+			assert.Equal(t, s, n.AsString())
+		}
+	}
+
+	_, e := numberFromDbString("abc")
+	assert.Error(t, e)
+}
@@ -0,0 +1,325 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/kelp/api"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// tradingWindow is a recurring window of time on specific days of the week, specified in minutes since midnight
+type tradingWindow struct {
+	days      map[time.Weekday]bool
+	startMins int
+	endMins   int
+}
+
+func (w tradingWindow) contains(t time.Time) bool {
+	mins := t.Hour()*60 + t.Minute()
+	if w.startMins < w.endMins {
+		return w.days[t.Weekday()] && mins >= w.startMins && mins < w.endMins
+	}
+
+	// window wraps past midnight, the day of the week refers to the day on which the window starts
+	if mins >= w.startMins {
+		return w.days[t.Weekday()]
+	}
+	previousDay := (t.Weekday() + 6) % 7
+	return mins < w.endMins && w.days[previousDay]
+}
+
+// blackoutPeriod is a one-off period of time during which we do not want to trade, the end is exclusive
+type blackoutPeriod struct {
+	start time.Time
+	end   time.Time
+}
+
+func (b blackoutPeriod) contains(t time.Time) bool {
+	return !t.Before(b.start) && t.Before(b.end)
+}
+
+// ScheduleTimeController wraps a TimeController and restricts trading to scheduled windows
+type ScheduleTimeController struct {
+	timeController api.TimeController
+	windows        []tradingWindow
+	blackouts      []blackoutPeriod
+	location       *time.Location
+}
+
+// MakeScheduleTimeController is a factory method
+//
+// windowSpecs are of the form "<days>/<HH:MM>-<HH:MM>", for example "mon-fri/08:00-20:00" or "sat,sun/10:00-14:00" or "*/00:00-24:00".
+// blackoutSpecs are either a date "YYYY-MM-DD" (the full day) or a range of RFC3339 timestamps "<start>/<end>".
+// timezone is the IANA name of the location in which windows and blackout dates are interpreted, defaults to UTC when empty.
+func MakeScheduleTimeController(
+	timeController api.TimeController,
+	windowSpecs []string,
+	blackoutSpecs []string,
+	timezone string,
+) (api.TimeController, error) {
+	location := time.UTC
+	if timezone != "" {
+		l, e := time.LoadLocation(timezone)
+		if e != nil {
+			return nil, fmt.Errorf("could not load timezone '%s': %s", timezone, e)
+		}
+		location = l
+	}
+
+	windows := []tradingWindow{}
+	for _, spec := range windowSpecs {
+		w, e := parseTradingWindow(spec)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse trading window '%s': %s", spec, e)
+		}
+		windows = append(windows, *w)
+	}
+
+	blackouts := []blackoutPeriod{}
+	for _, spec := range blackoutSpecs {
+		b, e := parseBlackoutPeriod(spec, location)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse blackout '%s': %s", spec, e)
+		}
+		blackouts = append(blackouts, *b)
+	}
+
+	return &ScheduleTimeController{
+		timeController: timeController,
+		windows:        windows,
+		blackouts:      blackouts,
+		location:       location,
+	}, nil
+}
+
+var _ api.TimeController = &ScheduleTimeController{}
+var _ api.TradingWindow = &ScheduleTimeController{}
+
+// ShouldUpdate impl, always updates when the trading window opened since the last update so the bot picks up the early wake from SleepTime
+func (s *ScheduleTimeController) ShouldUpdate(lastUpdateTime time.Time, currentUpdateTime time.Time) bool {
+	if !lastUpdateTime.IsZero() && !s.IsTradingWindowOpen(lastUpdateTime) && s.IsTradingWindowOpen(currentUpdateTime) {
+		log.Printf("scheduleTimeController: trading window opened since the last update, updating now\n")
+		return true
+	}
+	return s.timeController.ShouldUpdate(lastUpdateTime, currentUpdateTime)
+}
+
+// SleepTime impl
+func (s *ScheduleTimeController) SleepTime(lastUpdateTime time.Time) time.Duration {
+	return s.sleepTimeInternal(s.timeController.SleepTime(lastUpdateTime), time.Now())
+}
+
+// sleepTimeInternal wakes up the bot earlier than the underlying sleep time when a closed trading window opens in the meantime
+func (s *ScheduleTimeController) sleepTimeInternal(sleepTime time.Duration, realNow time.Time) time.Duration {
+	if sleepTime <= 0 || s.IsTradingWindowOpen(realNow) {
+		return sleepTime
+	}
+
+	wakeTime := realNow.Add(sleepTime)
+	openTime := s.nextOpenTime(realNow, wakeTime)
+	if openTime == nil {
+		return sleepTime
+	}
+
+	log.Printf("scheduleTimeController: trading window opens at %s, shortening sleep time\n", openTime.In(s.location).Format(time.RFC3339))
+	return openTime.Sub(realNow)
+}
+
+// nextOpenTime returns the first time in (from, until] when the trading window is open, or nil if it stays closed
+func (s *ScheduleTimeController) nextOpenTime(from time.Time, until time.Time) *time.Time {
+	// the trading window can only open at the start of a window or at the end of a blackout, so we only need to check those candidates
+	candidates := []time.Time{}
+	for _, b := range s.blackouts {
+		if b.end.After(from) && !b.end.After(until) {
+			candidates = append(candidates, b.end)
+		}
+	}
+	localFrom := from.In(s.location)
+	for day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, s.location); !day.After(until); day = day.AddDate(0, 0, 1) {
+		for _, w := range s.windows {
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.startMins, 0, 0, s.location)
+			if start.After(from) && !start.After(until) {
+				candidates = append(candidates, start)
+			}
+		}
+	}
+
+	var earliest *time.Time
+	for i := range candidates {
+		c := candidates[i]
+		if (earliest == nil || c.Before(*earliest)) && s.IsTradingWindowOpen(c) {
+			earliest = &c
+		}
+	}
+	return earliest
+}
+
+// IsTradingWindowOpen impl
+func (s *ScheduleTimeController) IsTradingWindowOpen(t time.Time) bool {
+	localTime := t.In(s.location)
+	for _, b := range s.blackouts {
+		if b.contains(localTime) {
+			return false
+		}
+	}
+
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, w := range s.windows {
+		if w.contains(localTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTradingWindow(spec string) (*tradingWindow, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("needs 2 parts separated by the delimiter (/), e.g. 'mon-fri/08:00-20:00'")
+	}
+
+	days, e := parseWeekdays(parts[0])
+	if e != nil {
+		return nil, fmt.Errorf("could not parse days: %s", e)
+	}
+
+	times := strings.Split(parts[1], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("time range needs to be of the form HH:MM-HH:MM")
+	}
+	startMins, e := parseMinutesOfDay(times[0])
+	if e != nil {
+		return nil, fmt.Errorf("could not parse start time: %s", e)
+	}
+	endMins, e := parseMinutesOfDay(times[1])
+	if e != nil {
+		return nil, fmt.Errorf("could not parse end time: %s", e)
+	}
+	if startMins == endMins {
+		return nil, fmt.Errorf("start time and end time cannot be the same")
+	}
+	if startMins == 24*60 {
+		return nil, fmt.Errorf("start time cannot be 24:00")
+	}
+
+	return &tradingWindow{
+		days:      days,
+		startMins: startMins,
+		endMins:   endMins,
+	}, nil
+}
+
+func parseWeekdays(daysSpec string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	if daysSpec == "*" {
+		for _, d := range weekdayNames {
+			days[d] = true
+		}
+		return days, nil
+	}
+
+	for _, item := range strings.Split(strings.ToLower(daysSpec), ",") {
+		rangeParts := strings.Split(item, "-")
+		if len(rangeParts) > 2 {
+			return nil, fmt.Errorf("invalid day range '%s'", item)
+		}
+
+		first, ok := weekdayNames[rangeParts[0]]
+		if !ok {
+			return nil, fmt.Errorf("invalid day '%s', needs to be one of sun, mon, tue, wed, thu, fri, sat", rangeParts[0])
+		}
+		last := first
+		if len(rangeParts) == 2 {
+			last, ok = weekdayNames[rangeParts[1]]
+			if !ok {
+				return nil, fmt.Errorf("invalid day '%s', needs to be one of sun, mon, tue, wed, thu, fri, sat", rangeParts[1])
+			}
+		}
+
+		// ranges can wrap around the end of the week, e.g. "fri-mon"
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseMinutesOfDay parses HH:MM into the number of minutes since midnight, allows 24:00 to represent the end of the day
+func parseMinutesOfDay(hhmm string) (int, error) {
+	parts := strings.Split(hhmm, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time '%s', needs to be of the form HH:MM", hhmm)
+	}
+
+	hours, e := strconv.Atoi(parts[0])
+	if e != nil {
+		return 0, fmt.Errorf("invalid hours in time '%s': %s", hhmm, e)
+	}
+	minutes, e := strconv.Atoi(parts[1])
+	if e != nil {
+		return 0, fmt.Errorf("invalid minutes in time '%s': %s", hhmm, e)
+	}
+
+	if hours == 24 && minutes == 0 {
+		return 24 * 60, nil
+	}
+	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("time '%s' is out of range", hhmm)
+	}
+	return hours*60 + minutes, nil
+}
+
+func parseBlackoutPeriod(spec string, location *time.Location) (*blackoutPeriod, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) == 1 {
+		day, e := time.ParseInLocation("2006-01-02", spec, location)
+		if e != nil {
+			return nil, fmt.Errorf("could not parse date, needs to be of the form YYYY-MM-DD: %s", e)
+		}
+		return &blackoutPeriod{
+			start: day,
+			end:   day.AddDate(0, 0, 1),
+		}, nil
+	}
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("needs to be either a date (YYYY-MM-DD) or 2 RFC3339 timestamps separated by the delimiter (/)")
+	}
+	start, e := time.Parse(time.RFC3339, parts[0])
+	if e != nil {
+		return nil, fmt.Errorf("could not parse start timestamp: %s", e)
+	}
+	end, e := time.Parse(time.RFC3339, parts[1])
+	if e != nil {
+		return nil, fmt.Errorf("could not parse end timestamp: %s", e)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end timestamp needs to be after start timestamp")
+	}
+
+	return &blackoutPeriod{
+		start: start,
+		end:   end,
+	}, nil
+}
@@ -0,0 +1,268 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTradingWindowOpen(t *testing.T) {
+	testCases := []struct {
+		name      string
+		windows   []string
+		blackouts []string
+		timezone  string
+		at        string
+		wantOpen  bool
+	}{
+		{
+			name:     "no windows",
+			windows:  []string{},
+			at:       "2020-03-14T15:00:00Z",
+			wantOpen: true,
+		}, {
+			name:     "weekday inside",
+			windows:  []string{"mon-fri/08:00-20:00"},
+			at:       "2020-03-13T08:00:00Z", // friday
+			wantOpen: true,
+		}, {
+			name:     "weekday end is exclusive",
+			windows:  []string{"mon-fri/08:00-20:00"},
+			at:       "2020-03-13T20:00:00Z",
+			wantOpen: false,
+		}, {
+			name:     "weekday before start",
+			windows:  []string{"mon-fri/08:00-20:00"},
+			at:       "2020-03-13T07:59:59Z",
+			wantOpen: false,
+		}, {
+			name:     "weekend",
+			windows:  []string{"mon-fri/08:00-20:00"},
+			at:       "2020-03-14T15:00:00Z", // saturday
+			wantOpen: false,
+		}, {
+			name:     "multiple windows",
+			windows:  []string{"mon-fri/08:00-20:00", "sat,sun/10:00-14:00"},
+			at:       "2020-03-14T13:59:00Z",
+			wantOpen: true,
+		}, {
+			name:     "full day",
+			windows:  []string{"*/00:00-24:00"},
+			at:       "2020-03-15T23:59:59Z",
+			wantOpen: true,
+		}, {
+			name:     "wraps past midnight, same day",
+			windows:  []string{"fri/22:00-02:00"},
+			at:       "2020-03-13T23:00:00Z",
+			wantOpen: true,
+		}, {
+			name:     "wraps past midnight, next day",
+			windows:  []string{"fri/22:00-02:00"},
+			at:       "2020-03-14T01:00:00Z",
+			wantOpen: true,
+		}, {
+			name:     "wraps past midnight, next day outside",
+			windows:  []string{"fri/22:00-02:00"},
+			at:       "2020-03-14T22:30:00Z",
+			wantOpen: false,
+		}, {
+			name:     "day range wraps around the week",
+			windows:  []string{"sat-mon/00:00-24:00"},
+			at:       "2020-03-15T12:00:00Z", // sunday
+			wantOpen: true,
+		}, {
+			name:     "timezone",
+			windows:  []string{"mon-fri/08:00-20:00"},
+			timezone: "America/New_York",
+			at:       "2020-03-13T23:00:00Z", // 19:00 in New York
+			wantOpen: true,
+		}, {
+			name:      "blackout date",
+			windows:   []string{"mon-fri/08:00-20:00"},
+			blackouts: []string{"2020-03-13"},
+			at:        "2020-03-13T12:00:00Z",
+			wantOpen:  false,
+		}, {
+			name:      "blackout date without windows",
+			blackouts: []string{"2020-03-13"},
+			at:        "2020-03-14T00:00:00Z",
+			wantOpen:  true,
+		}, {
+			name:      "blackout range",
+			windows:   []string{"mon-fri/08:00-20:00"},
+			blackouts: []string{"2020-03-13T11:00:00Z/2020-03-13T12:30:00Z"},
+			at:        "2020-03-13T12:00:00Z",
+			wantOpen:  false,
+		}, {
+			name:      "blackout range end is exclusive",
+			windows:   []string{"mon-fri/08:00-20:00"},
+			blackouts: []string{"2020-03-13T11:00:00Z/2020-03-13T12:30:00Z"},
+			at:        "2020-03-13T12:30:00Z",
+			wantOpen:  true,
+		},
+	}
+
+	for i, k := range testCases {
+		t.Run(fmt.Sprintf("%d. %s", i+1, k.name), func(t *testing.T) {
+			tc, e := MakeScheduleTimeController(makeIntervalTimeControllerForTest(5*time.Second, 0), k.windows, k.blackouts, k.timezone)
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			at, e := time.Parse(time.RFC3339, k.at)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, k.wantOpen, tc.(*ScheduleTimeController).IsTradingWindowOpen(at))
+		})
+	}
+}
+
+func TestMakeScheduleTimeControllerErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		windows   []string
+		blackouts []string
+		timezone  string
+	}{
+		{name: "missing days", windows: []string{"08:00-20:00"}},
+		{name: "invalid day", windows: []string{"mon-fry/08:00-20:00"}},
+		{name: "invalid time", windows: []string{"mon-fri/08:00-25:00"}},
+		{name: "empty window", windows: []string{"mon-fri/08:00-08:00"}},
+		{name: "start at end of day", windows: []string{"mon-fri/24:00-08:00"}},
+		{name: "invalid blackout date", blackouts: []string{"2020-13-01"}},
+		{name: "inverted blackout range", blackouts: []string{"2020-03-13T12:30:00Z/2020-03-13T11:00:00Z"}},
+		{name: "invalid timezone", timezone: "Mars/Olympus_Mons"},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			_, e := MakeScheduleTimeController(makeIntervalTimeControllerForTest(5*time.Second, 0), k.windows, k.blackouts, k.timezone)
+			assert.Error(t, e)
+		})
+	}
+}
+
+func TestScheduleSleepTime(t *testing.T) {
+	testCases := []struct {
+		name         string
+		windows      []string
+		blackouts    []string
+		realNow      string
+		sleepTime    time.Duration
+		wantDuration time.Duration
+	}{
+		{
+			name:         "inside window",
+			windows:      []string{"mon-fri/08:00-20:00"},
+			realNow:      "2020-03-13T12:00:00Z",
+			sleepTime:    5 * time.Minute,
+			wantDuration: 5 * time.Minute,
+		}, {
+			name:         "window opens after sleep time",
+			windows:      []string{"mon-fri/08:00-20:00"},
+			realNow:      "2020-03-13T07:00:00Z",
+			sleepTime:    5 * time.Minute,
+			wantDuration: 5 * time.Minute,
+		}, {
+			name:         "window opens before sleep time",
+			windows:      []string{"mon-fri/08:00-20:00"},
+			realNow:      "2020-03-13T07:58:30Z",
+			sleepTime:    5 * time.Minute,
+			wantDuration: 90 * time.Second,
+		}, {
+			name:         "blackout ends before sleep time",
+			blackouts:    []string{"2020-03-13T11:00:00Z/2020-03-13T12:00:10Z"},
+			realNow:      "2020-03-13T12:00:00Z",
+			sleepTime:    5 * time.Minute,
+			wantDuration: 10 * time.Second,
+		}, {
+			name:         "window opens on the next day",
+			windows:      []string{"sat/10:00-14:00"},
+			realNow:      "2020-03-13T23:00:00Z",
+			sleepTime:    12 * time.Hour,
+			wantDuration: 11 * time.Hour,
+		}, {
+			name:         "window opens at the end of a blackout inside the window",
+			windows:      []string{"mon-fri/08:00-20:00"},
+			blackouts:    []string{"2020-03-13T07:00:00Z/2020-03-13T09:30:00Z"},
+			realNow:      "2020-03-13T07:30:00Z",
+			sleepTime:    3 * time.Hour,
+			wantDuration: 2 * time.Hour,
+		}, {
+			name:         "negative sleep time",
+			windows:      []string{"mon-fri/08:00-20:00"},
+			realNow:      "2020-03-13T07:58:30Z",
+			sleepTime:    -1 * time.Millisecond,
+			wantDuration: -1 * time.Millisecond,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			tc, e := MakeScheduleTimeController(makeIntervalTimeControllerForTest(5*time.Minute, 0), k.windows, k.blackouts, "")
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			realNow, e := time.Parse(time.RFC3339, k.realNow)
+			if !assert.NoError(t, e) {
+				return
+			}
+			gotDuration := tc.(*ScheduleTimeController).sleepTimeInternal(k.sleepTime, realNow)
+			assert.Equal(t, k.wantDuration, gotDuration)
+		})
+	}
+}
+
+func TestScheduleShouldUpdate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lastUpdate string
+		current    string
+		want       bool
+	}{
+		{
+			name:       "window opened since the last update",
+			lastUpdate: "2020-03-13T07:58:30Z",
+			current:    "2020-03-13T08:00:00Z",
+			want:       true,
+		}, {
+			name:       "window open on both updates defers to the tick interval",
+			lastUpdate: "2020-03-13T08:00:00Z",
+			current:    "2020-03-13T08:01:30Z",
+			want:       false,
+		}, {
+			name:       "window closed on both updates defers to the tick interval",
+			lastUpdate: "2020-03-13T07:00:00Z",
+			current:    "2020-03-13T07:01:30Z",
+			want:       false,
+		}, {
+			name:       "tick interval elapsed",
+			lastUpdate: "2020-03-13T08:00:00Z",
+			current:    "2020-03-13T08:06:00Z",
+			want:       true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			tc, e := MakeScheduleTimeController(makeIntervalTimeControllerForTest(5*time.Minute, 0), []string{"mon-fri/08:00-20:00"}, nil, "")
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			lastUpdate, e := time.Parse(time.RFC3339, k.lastUpdate)
+			if !assert.NoError(t, e) {
+				return
+			}
+			current, e := time.Parse(time.RFC3339, k.current)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, k.want, tc.ShouldUpdate(lastUpdate, current))
+		})
+	}
+}
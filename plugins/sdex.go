@@ -18,6 +18,7 @@ import (
 	"github.com/stellar/go/clients/horizonclient"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
 	"github.com/stellar/kelp/api"
 	"github.com/stellar/kelp/model"
 	"github.com/stellar/kelp/support/networking"
@@ -51,12 +52,16 @@ type SDEX struct {
 	assetMap                      map[model.Asset]hProtocol.Asset // this is needed until we fully address putting SDEX behind the Exchange interface
 	opFeeStroopsFn                OpFeeStroops
 	tradingOnSdex                 bool
+	passiveSellOffers             bool
+	signer                        api.Signer
+	txMemo                        string
 
 	// uninitialized
 	seqNum             uint64
 	reloadSeqNum       bool
 	ieif               *IEIF
 	ocOverridesHandler *OrderConstraintsOverridesHandler
+	tradeAttribution   *tradeAttribution // nil when the bot does not tag its transactions with a memo
 }
 
 // enforce SDEX implements api.Constrainable
@@ -89,6 +94,9 @@ func MakeSDEX(
 	pair *model.TradingPair,
 	assetMap map[model.Asset]hProtocol.Asset,
 	opFeeStroopsFn OpFeeStroops,
+	passiveSellOffers bool,
+	signer api.Signer, // nil signs with the source and trading seeds
+	txMemo string,
 ) *SDEX {
 	sdex := &SDEX{
 		API:                           api,
@@ -106,6 +114,9 @@ func MakeSDEX(
 		assetMap:                      assetMap,
 		opFeeStroopsFn:                opFeeStroopsFn,
 		tradingOnSdex:                 exchangeShim == nil,
+		passiveSellOffers:             passiveSellOffers,
+		signer:                        signer,
+		txMemo:                        txMemo,
 		ocOverridesHandler:            MakeEmptyOrderConstraintsOverridesHandler(),
 	}
 
@@ -122,6 +133,16 @@ func MakeSDEX(
 		sdex.SourceSeed = sdex.TradingSeed
 		log.Println("No Source Account Set")
 	}
+	if sdex.signer == nil {
+		sdex.signer = MakeLocalSigner(sdex.SourceSeed, sdex.TradingSeed)
+	}
+	if sdex.passiveSellOffers {
+		log.Println("new offers will be created as passive sell offers")
+	}
+	if sdex.txMemo != "" {
+		// other bots can share the trading account so only the trades of our transactions and offers belong to this bot
+		sdex.tradeAttribution = makeTradeAttribution(sdex.API, sdex.SourceAccount, sdex.TradingAccount, sdex.txMemo)
+	}
 	sdex.reloadSeqNum = true
 
 	return sdex
@@ -139,8 +160,14 @@ func (sdex *SDEX) GetAccountBalances(assetList []interface{}) (map[interface{}]m
 		var a hProtocol.Asset
 		if v, ok := elem.(hProtocol.Asset); ok {
 			a = v
+		} else if v, ok := elem.(model.Asset); ok {
+			var e error
+			a, e = sdex.horizonAsset(v)
+			if e != nil {
+				return nil, e
+			}
 		} else {
-			return nil, fmt.Errorf("invalid type of asset passed in, only horizon.Asset accepted")
+			return nil, fmt.Errorf("invalid type of asset passed in, only horizon.Asset and model.Asset accepted")
 		}
 
 		balance, e := sdex.ieif.assetBalance(a)
@@ -301,6 +328,12 @@ func (sdex *SDEX) createModifySellOffer(offer *hProtocol.Offer, selling hProtoco
 	if amount <= 0 {
 		return nil, fmt.Errorf("error: cannot create or modify offer, invalid amount: %.8f", amount)
 	}
+	// floor the amount we sell so we never sell more than was asked for
+	amountNumber := model.NumberFromFloatWithRounding(amount, sdexOrderConstraints.VolumePrecision, model.RoundFloor)
+	if amountNumber.Sign() <= 0 {
+		return nil, fmt.Errorf("error: cannot create or modify offer, amount %.8f rounds down to zero at precision %d", amount, sdexOrderConstraints.VolumePrecision)
+	}
+	amount = amountNumber.AsFloat()
 
 	// check liability limits on the asset being sold
 	incrementalSell := amount
@@ -313,7 +346,9 @@ func (sdex *SDEX) createModifySellOffer(offer *hProtocol.Offer, selling hProtoco
 	}
 
 	// check trust limits on asset being bought
-	incrementalBuy := price * amount
+	// ceil the amount we buy so the trust limit check is conservative
+	priceNumber := model.NumberFromFloat(price, model.InternalCalculationsPrecision)
+	incrementalBuy := priceNumber.MultiplyWithRounding(*model.NumberFromFloat(amount, model.InternalCalculationsPrecision), model.RoundCeil).AsFloat()
 	willOverbuy, e := sdex.ieif.willOverbuy(buying, incrementalBuy)
 	if e != nil {
 		return nil, e
@@ -338,7 +373,7 @@ func (sdex *SDEX) createModifySellOffer(offer *hProtocol.Offer, selling hProtoco
 	}
 
 	stringPrice := strconv.FormatFloat(price, 'f', int(sdexOrderConstraints.PricePrecision), 64)
-	stringAmount := strconv.FormatFloat(amount, 'f', int(sdexOrderConstraints.VolumePrecision), 64)
+	stringAmount := amountNumber.AsString()
 
 	result, err := txnbuild.CreateOfferOp(utils.Asset2Asset(selling), utils.Asset2Asset(buying), stringAmount, stringPrice)
 	if err != nil {
@@ -369,7 +404,20 @@ func (sdex *SDEX) SubmitOps(ops []build.TransactionMutator, submitMode api.Submi
 
 // submitOps submits the passed in operations to the network in a single transaction. Asynchronous or not based on flag.
 func (sdex *SDEX) submitOps(opsOld []build.TransactionMutator, asyncCallback func(hash string, e error), asyncMode bool) error {
-	ops := api.ConvertTM2Operation(opsOld)
+	return sdex.submitTxOps(api.ConvertTM2Operation(opsOld), asyncCallback, asyncMode)
+}
+
+// submitTxOps submits operations of any type to the network in a single transaction. Asynchronous or not based on flag.
+func (sdex *SDEX) submitTxOps(ops []txnbuild.Operation, asyncCallback func(hash string, e error), asyncMode bool) error {
+	// the memo lets bots that share a trading account tell their transactions apart
+	return sdex.submitTxOpsWithMemo(ops, sdex.txMemo, asyncCallback, asyncMode)
+}
+
+// submitTxOpsWithMemo submits operations of any type to the network in a single transaction with a text memo, no memo is set when empty
+func (sdex *SDEX) submitTxOpsWithMemo(ops []txnbuild.Operation, memoText string, asyncCallback func(hash string, e error), asyncMode bool) error {
+	if sdex.passiveSellOffers {
+		ops = convertToPassiveSellOffers(ops)
+	}
 
 	// compute fee per operation
 	opFee, e := sdex.opFeeStroopsFn()
@@ -377,6 +425,11 @@ func (sdex *SDEX) submitOps(opsOld []build.TransactionMutator, asyncCallback fun
 		return fmt.Errorf("SubmitOps error when computing op fee: %s", e)
 	}
 
+	var memo txnbuild.Memo
+	if memoText != "" {
+		memo = txnbuild.MemoText(memoText)
+	}
+
 	sdex.incrementSeqNum()
 	tx, e := txnbuild.NewTransaction(
 		txnbuild.TransactionParams{
@@ -393,6 +446,7 @@ func (sdex *SDEX) submitOps(opsOld []build.TransactionMutator, asyncCallback fun
 			// to obtain the sequence number for the transaction.
 			IncrementSequenceNum: true,
 			Operations:           ops,
+			Memo:                 memo,
 			Timebounds:           txnbuild.NewInfiniteTimeout(),
 		},
 	)
@@ -428,23 +482,67 @@ func (sdex *SDEX) submitOps(opsOld []build.TransactionMutator, asyncCallback fun
 	return nil
 }
 
+// convertToPassiveSellOffers converts the operations that create new offers into CreatePassiveSellOffer operations so our bids and asks
+// at the same price do not cross each other. Modifies and deletes reference an existing offerID and are left as ManageSellOffer operations,
+// which keeps the passive flag on the offer. The liabilities of a passive offer are the same as that of a regular offer so IEIF is unaffected.
+func convertToPassiveSellOffers(ops []txnbuild.Operation) []txnbuild.Operation {
+	converted := []txnbuild.Operation{}
+	for _, op := range ops {
+		mso, ok := op.(*txnbuild.ManageSellOffer)
+		if !ok || mso.OfferID != 0 {
+			converted = append(converted, op)
+			continue
+		}
+
+		converted = append(converted, &txnbuild.CreatePassiveSellOffer{
+			Selling:       mso.Selling,
+			Buying:        mso.Buying,
+			Amount:        mso.Amount,
+			Price:         mso.Price,
+			SourceAccount: mso.SourceAccount,
+		})
+	}
+	return converted
+}
+
 // CreateBuyOffer creates a buy offer
 func (sdex *SDEX) CreateBuyOffer(base hProtocol.Asset, counter hProtocol.Asset, price float64, amount float64, incrementalNativeAmountRaw float64) (*txnbuild.ManageSellOffer, error) {
 	return sdex.CreateSellOffer(counter, base, 1/price, amount*price, incrementalNativeAmountRaw)
 }
 
 func (sdex *SDEX) sign(tx *txnbuild.Transaction) (string, error) {
-	var e error
-	if sdex.SourceSeed != sdex.TradingSeed {
-		tx, e = utils.SignWithSeed(tx, sdex.Network, sdex.SourceSeed, sdex.TradingSeed)
-	} else {
-		tx, e = utils.SignWithSeed(tx, sdex.Network, sdex.SourceSeed)
+	txHash, e := tx.Hash(sdex.Network)
+	if e != nil {
+		return "", fmt.Errorf("could not hash transaction: %s", e)
+	}
+	unsignedTxeB64, e := tx.Base64()
+	if e != nil {
+		return "", fmt.Errorf("could not encode unsigned transaction: %s", e)
 	}
+
+	signatures, e := sdex.signer.Sign(txHash, unsignedTxeB64)
 	if e != nil {
 		return "", fmt.Errorf("error signing transaction: %s", e)
 	}
+	return addSignatures(tx, signatures)
+}
+
+// addSignatures returns the base64 encoded transaction envelope with the signatures added to the ones already on the transaction
+func addSignatures(tx *txnbuild.Transaction, signatures []xdr.DecoratedSignature) (string, error) {
+	txe, e := tx.TxEnvelope()
+	if e != nil {
+		return "", fmt.Errorf("could not get transaction envelope: %s", e)
+	}
 
-	return tx.Base64()
+	switch txe.Type {
+	case xdr.EnvelopeTypeEnvelopeTypeTxV0:
+		txe.V0.Signatures = append(txe.V0.Signatures, signatures...)
+	case xdr.EnvelopeTypeEnvelopeTypeTx:
+		txe.V1.Signatures = append(txe.V1.Signatures, signatures...)
+	default:
+		return "", fmt.Errorf("cannot add signatures to transaction envelope of type %s", txe.Type)
+	}
+	return xdr.MarshalBase64(txe)
 }
 
 func (sdex *SDEX) submit(txeB64 string, asyncCallback func(hash string, e error), asyncMode bool) {
@@ -475,6 +573,12 @@ func (sdex *SDEX) submit(txeB64 string, asyncCallback func(hash string, e error)
 		modeString = "(async)"
 	}
 	log.Printf("%s tx confirmation hash: %s\n", modeString, resp.Hash)
+	if sdex.tradeAttribution != nil {
+		e = sdex.tradeAttribution.recordSubmittedTx(resp.Hash, resp.ResultXdr)
+		if e != nil {
+			log.Printf("%s error: fills of the offers of this transaction may not be attributed to this bot: %s\n", modeString, e)
+		}
+	}
 	sdex.invokeAsyncCallback(asyncCallback, resp.Hash, nil, asyncMode)
 }
 
@@ -526,6 +630,14 @@ func (sdex *SDEX) GetTradeHistory(pair model.TradingPair, maybeCursorStart inter
 		return nil, fmt.Errorf("error while converting pair to base and quote asset: %s", e)
 	}
 
+	if sdex.tradeAttribution != nil {
+		// offers that are open now can be filled later, find out which of them belong to this bot before they are gone
+		e = sdex.tradeAttribution.loadOpenOffersOnce()
+		if e != nil {
+			return nil, fmt.Errorf("could not attribute the offers of the shared trading account: %s", e)
+		}
+	}
+
 	var cursorStart string
 	if maybeCursorStart != nil {
 		var ok bool
@@ -599,24 +711,28 @@ func (sdex *SDEX) GetTradeHistory(pair model.TradingPair, maybeCursorStart inter
 
 		hitRateLimit := false
 		updatedResult, hitCursorEnd, e := sdex.tradesPage2TradeHistoryResult(baseAsset, quoteAsset, tradesPage, cursorEnd)
-		numFetchedTrades := len(updatedResult.Trades)
 		if e != nil {
 			if isRateLimitError(e) {
-				log.Printf("encountered a rate limit error when converting tradesPage2TradeHistoryResult, process what we were able to fetch (len = %d), we will continue loading trades in the next call from where we left off", numFetchedTrades)
+				log.Printf("encountered a rate limit error when converting tradesPage2TradeHistoryResult, process what we were able to fetch (len = %d), we will continue loading trades in the next call from where we left off", len(updatedResult.Trades))
 				hitRateLimit = true
 				// don't do anything here, just continue to the logic outside this error check so we process the results
 			} else {
 				return nil, fmt.Errorf("error converting tradesPage2TradesResult: %s", e)
 			}
 		}
+		numFetchedTrades := 0
 		if updatedResult != nil {
+			numFetchedTrades = len(updatedResult.Trades)
 			trades = append(trades, updatedResult.Trades...)
+			if cursor, ok := updatedResult.Cursor.(string); ok && cursor != "" {
+				// move past the trades that were ignored because they belong to another bot sharing the trading account, otherwise a page
+				// of such trades would be fetched again forever.
+				// it could fail this condition because we hit a rate limit issue on trying to process the first result in the list even though the list had more than 0 items
+				cursorStart = cursor
+			}
 		}
 		if len(trades) > sdexTradesFetchLimit {
 			trades = trades[:sdexTradesFetchLimit]
-		}
-		if len(trades) > 0 {
-			// it could fail this condition because we hit a rate limit issue on trying to process the first result in the list even though the list had more than 0 items
 			cursorStart = trades[len(trades)-1].TransactionID.String()
 		}
 
@@ -663,6 +779,17 @@ func (sdex *SDEX) getOrderAction(baseAsset hProtocol.Asset, quoteAsset hProtocol
 		return nil, nil
 	}
 
+	if sdex.tradeAttribution != nil {
+		isOwnTrade, e := sdex.tradeAttribution.isOwnTrade(trade)
+		if e != nil {
+			return nil, fmt.Errorf("could not check whether trade %s belongs to the bot with memo '%s': %s", trade.ID, sdex.txMemo, e)
+		}
+		if !isOwnTrade {
+			// the trade belongs to another bot that shares the trading account so we ignore this trade
+			return nil, nil
+		}
+	}
+
 	effectsLink := makeEffectsLink(trade)
 	for {
 		var output map[string]interface{}
@@ -848,7 +975,7 @@ func (sdex *SDEX) tradesPage2TradeHistoryResult(baseAsset hProtocol.Asset, quote
 		}
 		if orderAction == nil {
 			// encountered a trade that is different from the base and quote asset for our trading account
-			log.Printf("encountered a trade (ID=%s) that is different from the base and quote asset (%s:%s/%s:%s) on the bot or uses a different trading account, botTraderAccount=%s (tradeBaseAccount=%s, tradeCounterAccount=%s)", t.ID, t.BaseAssetCode, t.BaseAssetIssuer, t.CounterAssetCode, t.CounterAssetIssuer, sdex.TradingAccount, t.BaseAccount, t.CounterAccount)
+			log.Printf("encountered a trade (ID=%s) that is different from the base and quote asset (%s:%s/%s:%s) on the bot or uses a different trading account or belongs to a bot with a different memo, botTraderAccount=%s, botTxMemo='%s' (tradeBaseAccount=%s, tradeCounterAccount=%s)", t.ID, t.BaseAssetCode, t.BaseAssetIssuer, t.CounterAssetCode, t.CounterAssetIssuer, sdex.TradingAccount, sdex.txMemo, t.BaseAccount, t.CounterAccount)
 			continue
 		}
 
@@ -858,6 +985,11 @@ func (sdex *SDEX) tradesPage2TradeHistoryResult(baseAsset hProtocol.Asset, quote
 		}
 		floatPrice := float64(t.Price.N) / float64(t.Price.D)
 		price := model.NumberFromFloat(floatPrice, sdexOrderConstraints.PricePrecision)
+		// the offer that belongs to our trading account
+		orderID := t.BaseOfferID
+		if t.BaseAccount != sdex.TradingAccount {
+			orderID = t.CounterOfferID
+		}
 
 		trades = append(trades, model.Trade{
 			Order: model.Order{
@@ -871,7 +1003,7 @@ func (sdex *SDEX) tradesPage2TradeHistoryResult(baseAsset hProtocol.Asset, quote
 			TransactionID: model.MakeTransactionID(t.ID),
 			Cost:          price.Multiply(*vol),
 			Fee:           model.NumberFromFloat(baseFee, sdexOrderConstraints.PricePrecision),
-			// OrderID unavailable?
+			OrderID:       orderID,
 		})
 
 		if cursor == cursorEnd {
@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFeeFromStats(t *testing.T) {
+	testCases := []struct {
+		name          string
+		capacityUsage float64
+		lastFee       int64
+		p90Fee        int64
+		failStatus    int
+		failTitle     string
+		wantFee       uint64
+		wantError     bool
+	}{
+		{
+			name:          "below capacity trigger uses last ledger base fee",
+			capacityUsage: 0.5,
+			lastFee:       100,
+			p90Fee:        5000,
+			wantFee:       100,
+		}, {
+			name:          "below capacity trigger is capped at max fee",
+			capacityUsage: 0.5,
+			lastFee:       2000,
+			p90Fee:        5000,
+			wantFee:       1000,
+		}, {
+			name:          "above capacity trigger uses percentile",
+			capacityUsage: 0.9,
+			lastFee:       100,
+			p90Fee:        500,
+			wantFee:       500,
+		}, {
+			name:          "above capacity trigger is capped at max fee",
+			capacityUsage: 0.9,
+			lastFee:       100,
+			p90Fee:        5000,
+			wantFee:       1000,
+		}, {
+			name:       "endpoint not available uses max fee",
+			failStatus: 404,
+			failTitle:  "Endpoint Not Available",
+			wantFee:    1000,
+		}, {
+			name:       "other errors are returned",
+			failStatus: 500,
+			failTitle:  "Internal Server Error",
+			wantError:  true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+			defer s.Close()
+
+			feeStats := hProtocol.FeeStats{
+				LastLedgerBaseFee:   k.lastFee,
+				LedgerCapacityUsage: k.capacityUsage,
+			}
+			feeStats.MaxFee.P90 = k.p90Fee
+			s.SetFeeStats(feeStats)
+			if k.failStatus != 0 {
+				s.FailNext("fee_stats", k.failStatus, k.failTitle)
+			}
+
+			fee, e := getFeeFromStats(s.Client(), 0.8, 90, 1000)
+			if k.wantError {
+				assert.Error(t, e)
+				return
+			}
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, k.wantFee, fee)
+			assert.Equal(t, 1, s.CallCount("fee_stats"))
+		})
+	}
+}
@@ -74,6 +74,9 @@ func makeSDEXFeed(url string) (*sdexFeed, error) {
 		tradingPair,
 		sdexAssetMap,
 		SdexFixedFeeFn(0),
+		false,
+		nil,
+		"",
 	)
 
 	return &sdexFeed{
@@ -0,0 +1,322 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// enforce SDEX implements api.PathPaymentTaker
+var _ api.PathPaymentTaker = &SDEX{}
+
+// TakeStrictSend impl
+func (sdex *SDEX) TakeStrictSend(sendAsset hProtocol.Asset, sendAmount *model.Number, destAsset hProtocol.Asset, maxSlippage float64) (*api.TakerResult, error) {
+	// floor the amount we send so we never send more than was asked for
+	sendAmount, e := checkTakerInputs(sendAsset, sendAmount, destAsset, maxSlippage, model.RoundFloor)
+	if e != nil {
+		return nil, e
+	}
+
+	pathsPage, e := sdex.API.StrictSendPaths(horizonclient.StrictSendPathsRequest{
+		DestinationAssets: pathAssetString(destAsset),
+		SourceAssetType:   horizonclient.AssetType(sendAsset.Type),
+		SourceAssetCode:   sendAsset.Code,
+		SourceAssetIssuer: sendAsset.Issuer,
+		SourceAmount:      sendAmount.AsString(),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch strict send paths from horizon: %s", e)
+	}
+	path, quotedDestAmount, e := selectStrictSendPath(pathsPage.Embedded.Records, destAsset)
+	if e != nil {
+		return nil, e
+	}
+	destMin := applySlippage(quotedDestAmount, -maxSlippage, model.RoundFloor)
+
+	result := &api.TakerResult{
+		SendAsset:  sendAsset,
+		DestAsset:  destAsset,
+		SendAmount: sendAmount,
+		DestAmount: quotedDestAmount,
+		DestMin:    destMin,
+		Path:       path.Path,
+	}
+	op := &txnbuild.PathPaymentStrictSend{
+		SendAsset:   utils.Asset2Asset(sendAsset),
+		SendAmount:  sendAmount.AsString(),
+		Destination: sdex.TradingAccount,
+		DestAsset:   utils.Asset2Asset(destAsset),
+		DestMin:     destMin.AsString(),
+		Path:        pathAssets(path.Path),
+	}
+	if sdex.SourceAccount != sdex.TradingAccount {
+		op.SourceAccount = &txnbuild.SimpleAccount{AccountID: sdex.TradingAccount}
+	}
+	return sdex.submitTakerOp(op, sendAmount, quotedDestAmount, result)
+}
+
+// TakeStrictReceive impl
+func (sdex *SDEX) TakeStrictReceive(sendAsset hProtocol.Asset, destAsset hProtocol.Asset, destAmount *model.Number, maxSlippage float64) (*api.TakerResult, error) {
+	// ceil the amount we receive so we never receive less than was asked for
+	destAmount, e := checkTakerInputs(sendAsset, destAmount, destAsset, maxSlippage, model.RoundCeil)
+	if e != nil {
+		return nil, e
+	}
+
+	pathsPage, e := sdex.API.StrictReceivePaths(horizonclient.PathsRequest{
+		DestinationAssetType:   horizonclient.AssetType(destAsset.Type),
+		DestinationAssetCode:   destAsset.Code,
+		DestinationAssetIssuer: destAsset.Issuer,
+		DestinationAmount:      destAmount.AsString(),
+		SourceAssets:           pathAssetString(sendAsset),
+	})
+	if e != nil {
+		return nil, fmt.Errorf("could not fetch strict receive paths from horizon: %s", e)
+	}
+	path, quotedSendAmount, e := selectStrictReceivePath(pathsPage.Embedded.Records, sendAsset)
+	if e != nil {
+		return nil, e
+	}
+	sendMax := applySlippage(quotedSendAmount, maxSlippage, model.RoundCeil)
+
+	result := &api.TakerResult{
+		SendAsset:  sendAsset,
+		DestAsset:  destAsset,
+		SendAmount: quotedSendAmount,
+		DestAmount: destAmount,
+		SendMax:    sendMax,
+		Path:       path.Path,
+	}
+	op := &txnbuild.PathPaymentStrictReceive{
+		SendAsset:   utils.Asset2Asset(sendAsset),
+		SendMax:     sendMax.AsString(),
+		Destination: sdex.TradingAccount,
+		DestAsset:   utils.Asset2Asset(destAsset),
+		DestAmount:  destAmount.AsString(),
+		Path:        pathAssets(path.Path),
+	}
+	if sdex.SourceAccount != sdex.TradingAccount {
+		op.SourceAccount = &txnbuild.SimpleAccount{AccountID: sdex.TradingAccount}
+	}
+	return sdex.submitTakerOp(op, sendMax, destAmount, result)
+}
+
+// submitTakerOp checks balances and trust limits before synchronously submitting the path payment, maxSend and maxReceive are the most
+// we can send of the send asset and receive of the dest asset. Our offers that the path payment could cross are deleted before it in
+// the same transaction, so they are only deleted if the path payment succeeds. Offers of other bots sharing the trading account are never
+// deleted, we fail instead when the path payment could cross them.
+func (sdex *SDEX) submitTakerOp(op txnbuild.Operation, maxSend *model.Number, maxReceive *model.Number, result *api.TakerResult) (*api.TakerResult, error) {
+	destAsset := result.DestAsset
+	willOversell, e := sdex.ieif.willOversell(result.SendAsset, maxSend.AsFloat())
+	if e != nil {
+		return nil, e
+	}
+	if willOversell {
+		return nil, fmt.Errorf("not enough balance of %s to send up to %s", utils.Asset2String(result.SendAsset), maxSend.AsString())
+	}
+	willOverbuy, e := sdex.ieif.willOverbuy(destAsset, maxReceive.AsFloat())
+	if e != nil {
+		return nil, e
+	}
+	if willOverbuy {
+		return nil, fmt.Errorf("receiving %s of %s would exceed the trust limit", maxReceive.AsString(), utils.Asset2String(destAsset))
+	}
+
+	offers, e := sdex.LoadOffersHack()
+	if e != nil {
+		return nil, fmt.Errorf("could not load our offers to check if the path payment would cross them: %s", e)
+	}
+	crossing, e := sdex.ownSelfCrossingOffers(selfCrossingOffers(offers, result.SendAsset, result.Path, destAsset))
+	if e != nil {
+		return nil, e
+	}
+	ops := []txnbuild.Operation{}
+	for _, offer := range crossing {
+		log.Printf("deleting our offer %d (selling %s for %s) since the path payment could cross it\n", offer.ID, utils.Asset2String(offer.Selling), utils.Asset2String(offer.Buying))
+		deleteOp := sdex.DeleteOffer(offer)
+		ops = append(ops, &deleteOp)
+		result.DeletedOfferIDs = append(result.DeletedOfferIDs, offer.ID)
+	}
+	ops = append(ops, op)
+
+	var submitErr error
+	e = sdex.submitTxOps(ops, func(hash string, e error) {
+		result.TxHash, submitErr = hash, e
+	}, false)
+	if e != nil {
+		return nil, fmt.Errorf("could not submit path payment: %s", e)
+	}
+	if submitErr != nil {
+		if hasOpResultCode(submitErr, "op_offer_cross_self") {
+			return nil, fmt.Errorf("path payment crossed an offer of our own account that was placed after we loaded our offers, try again: %s", submitErr)
+		}
+		return nil, fmt.Errorf("path payment failed: %s", submitErr)
+	}
+	log.Printf("took liquidity on SDEX: %s\n", result)
+	return result, nil
+}
+
+// selfCrossingOffers returns our offers that the path payment could take, these are the offers on the book of each hop that sell the next
+// asset on the path for the previous one. Taking any of them fails the whole path payment with op_offer_cross_self.
+func selfCrossingOffers(offers []hProtocol.Offer, sendAsset hProtocol.Asset, path []hProtocol.Asset, destAsset hProtocol.Asset) []hProtocol.Offer {
+	hops := append(append([]hProtocol.Asset{sendAsset}, path...), destAsset)
+	crossing := []hProtocol.Offer{}
+	for _, offer := range offers {
+		for i := 0; i+1 < len(hops); i++ {
+			if sameAsset(offer.Buying, hops[i]) && sameAsset(offer.Selling, hops[i+1]) {
+				crossing = append(crossing, offer)
+				break
+			}
+		}
+	}
+	return crossing
+}
+
+// ownSelfCrossingOffers returns the crossing offers when they were all placed by this bot. Bots that share the trading account are told
+// apart by the memo on their transactions, so we return an error when the path payment could cross an offer of another bot since we do
+// not want to delete it and the path payment would fail with op_offer_cross_self. All offers of the account belong to us without a memo.
+func (sdex *SDEX) ownSelfCrossingOffers(crossing []hProtocol.Offer) ([]hProtocol.Offer, error) {
+	if sdex.txMemo == "" || len(crossing) == 0 {
+		return crossing, nil
+	}
+
+	own, e := FilterOffersByMemo(sdex.API, sdex.TradingAccount, sdex.txMemo, crossing)
+	if e != nil {
+		return nil, fmt.Errorf("could not check which of the offers that the path payment could cross belong to the bot with memo '%s': %s", sdex.txMemo, e)
+	}
+	if len(own) == len(crossing) {
+		return crossing, nil
+	}
+
+	ownIDs := map[int64]bool{}
+	for _, o := range own {
+		ownIDs[o.ID] = true
+	}
+	otherIDs := []int64{}
+	for _, o := range crossing {
+		if !ownIDs[o.ID] {
+			otherIDs = append(otherIDs, o.ID)
+		}
+	}
+	return nil, fmt.Errorf("the path payment could cross offers %v of other bots sharing the trading account, not deleting them", otherIDs)
+}
+
+// hasOpResultCode is true when the error is a failed transaction with an operation that failed with the result code
+func hasOpResultCode(e error, code string) bool {
+	herr, ok := errors.Cause(e).(*horizonclient.Error)
+	if !ok {
+		return false
+	}
+	rcs, e := herr.ResultCodes()
+	if e != nil {
+		return false
+	}
+	for _, opCode := range rcs.OperationCodes {
+		if opCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTakerInputs(sendAsset hProtocol.Asset, amount *model.Number, destAsset hProtocol.Asset, maxSlippage float64, rounding model.Rounding) (*model.Number, error) {
+	if sameAsset(sendAsset, destAsset) {
+		return nil, fmt.Errorf("the send asset and dest asset need to be different, both were %s", utils.Asset2String(sendAsset))
+	}
+	if maxSlippage < 0 || maxSlippage >= 1 {
+		return nil, fmt.Errorf("maxSlippage needs to be in the range [0, 1), was %f", maxSlippage)
+	}
+	if amount == nil {
+		return nil, fmt.Errorf("amount needs to be non-nil")
+	}
+
+	rounded := model.NumberByCappingPrecisionWithRounding(amount, sdexOrderConstraints.VolumePrecision, rounding)
+	if rounded.Sign() <= 0 {
+		return nil, fmt.Errorf("amount needs to be positive at precision %d, was %s", sdexOrderConstraints.VolumePrecision, amount.AsString())
+	}
+	return rounded, nil
+}
+
+// selectStrictSendPath selects the path that receives the most of the dest asset
+func selectStrictSendPath(paths []hProtocol.Path, destAsset hProtocol.Asset) (*hProtocol.Path, *model.Number, error) {
+	var best *hProtocol.Path
+	var bestAmount *model.Number
+	for i, p := range paths {
+		asset := hProtocol.Asset{Type: p.DestinationAssetType, Code: p.DestinationAssetCode, Issuer: p.DestinationAssetIssuer}
+		if !sameAsset(asset, destAsset) {
+			continue
+		}
+		amount, e := model.NumberFromString(p.DestinationAmount, sdexOrderConstraints.VolumePrecision)
+		if e != nil {
+			return nil, nil, fmt.Errorf("could not parse destination amount of path: %s", e)
+		}
+		if best == nil || amount.Cmp(*bestAmount) > 0 {
+			best, bestAmount = &paths[i], amount
+		}
+	}
+	if best == nil {
+		return nil, nil, fmt.Errorf("no strict send path found to %s", utils.Asset2String(destAsset))
+	}
+	return best, bestAmount, nil
+}
+
+// selectStrictReceivePath selects the path that sends the least of the send asset
+func selectStrictReceivePath(paths []hProtocol.Path, sendAsset hProtocol.Asset) (*hProtocol.Path, *model.Number, error) {
+	var best *hProtocol.Path
+	var bestAmount *model.Number
+	for i, p := range paths {
+		asset := hProtocol.Asset{Type: p.SourceAssetType, Code: p.SourceAssetCode, Issuer: p.SourceAssetIssuer}
+		if !sameAsset(asset, sendAsset) {
+			continue
+		}
+		amount, e := model.NumberFromString(p.SourceAmount, sdexOrderConstraints.VolumePrecision)
+		if e != nil {
+			return nil, nil, fmt.Errorf("could not parse source amount of path: %s", e)
+		}
+		if best == nil || amount.Cmp(*bestAmount) < 0 {
+			best, bestAmount = &paths[i], amount
+		}
+	}
+	if best == nil {
+		return nil, nil, fmt.Errorf("no strict receive path found from %s", utils.Asset2String(sendAsset))
+	}
+	return best, bestAmount, nil
+}
+
+// applySlippage scales the amount by (1 + slippage) at SDEX precision
+func applySlippage(amount *model.Number, slippage float64, rounding model.Rounding) *model.Number {
+	factor := model.NumberFromFloat(1+slippage, model.InternalCalculationsPrecision)
+	scaled := amount.MultiplyWithRounding(*factor, rounding)
+	return model.NumberByCappingPrecisionWithRounding(scaled, sdexOrderConstraints.VolumePrecision, rounding)
+}
+
+// pathAssetString formats an asset the way horizon's path finding endpoints expect it in a list of assets
+func pathAssetString(asset hProtocol.Asset) string {
+	if asset.Type == utils.Native {
+		return utils.Native
+	}
+	return asset.Code + ":" + asset.Issuer
+}
+
+func pathAssets(path []hProtocol.Asset) []txnbuild.Asset {
+	assets := []txnbuild.Asset{}
+	for _, a := range path {
+		assets = append(assets, utils.Asset2Asset(a))
+	}
+	return assets
+}
+
+func sameAsset(a hProtocol.Asset, b hProtocol.Asset) bool {
+	if a.Type == utils.Native || b.Type == utils.Native {
+		return a.Type == b.Type
+	}
+	return a.Code == b.Code && a.Issuer == b.Issuer
+}
@@ -0,0 +1,325 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/utils"
+)
+
+const testTakerIssuer = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+
+var testTakerNative = hProtocol.Asset{Type: "native"}
+var testTakerUSD = hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: testTakerIssuer}
+var testTakerBTC = hProtocol.Asset{Type: "credit_alphanum4", Code: "BTC", Issuer: testTakerIssuer}
+
+func makeTestPath(source hProtocol.Asset, sourceAmount string, dest hProtocol.Asset, destAmount string, path ...hProtocol.Asset) hProtocol.Path {
+	return hProtocol.Path{
+		SourceAssetType:        source.Type,
+		SourceAssetCode:        source.Code,
+		SourceAssetIssuer:      source.Issuer,
+		SourceAmount:           sourceAmount,
+		DestinationAssetType:   dest.Type,
+		DestinationAssetCode:   dest.Code,
+		DestinationAssetIssuer: dest.Issuer,
+		DestinationAmount:      destAmount,
+		Path:                   path,
+	}
+}
+
+func TestSelectStrictSendPath(t *testing.T) {
+	paths := []hProtocol.Path{
+		makeTestPath(testTakerNative, "100.0000000", testTakerUSD, "9.5000000"),
+		makeTestPath(testTakerNative, "100.0000000", testTakerBTC, "0.0100000"),
+		makeTestPath(testTakerNative, "100.0000000", testTakerUSD, "9.7000000", testTakerBTC),
+		makeTestPath(testTakerNative, "100.0000000", testTakerUSD, "9.6000000"),
+	}
+
+	path, amount, e := selectStrictSendPath(paths, testTakerUSD)
+	if assert.NoError(t, e) {
+		assert.Equal(t, "9.7000000", amount.AsString())
+		assert.Equal(t, []hProtocol.Asset{testTakerBTC}, path.Path)
+	}
+
+	_, _, e = selectStrictSendPath(paths, hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: testTakerIssuer})
+	assert.Error(t, e)
+}
+
+func TestSelectStrictReceivePath(t *testing.T) {
+	paths := []hProtocol.Path{
+		makeTestPath(testTakerNative, "105.0000000", testTakerUSD, "10.0000000"),
+		makeTestPath(testTakerNative, "103.5000000", testTakerUSD, "10.0000000", testTakerBTC),
+		makeTestPath(testTakerBTC, "0.0010000", testTakerUSD, "10.0000000"),
+	}
+
+	path, amount, e := selectStrictReceivePath(paths, testTakerNative)
+	if assert.NoError(t, e) {
+		assert.Equal(t, "103.5000000", amount.AsString())
+		assert.Equal(t, []hProtocol.Asset{testTakerBTC}, path.Path)
+	}
+
+	_, _, e = selectStrictReceivePath(paths, testTakerUSD)
+	assert.Error(t, e)
+}
+
+func TestApplySlippage(t *testing.T) {
+	quoted := model.MustNumberFromString("9.7000001", 7)
+
+	// strict send: the minimum we accept is floored so it never asks for more than the slippage allows
+	assert.Equal(t, "9.6030000", applySlippage(quoted, -0.01, model.RoundFloor).AsString())
+	// strict receive: the maximum we send is ceiled so a path at exactly the slippage bound still executes
+	assert.Equal(t, "9.7970002", applySlippage(quoted, 0.01, model.RoundCeil).AsString())
+	assert.Equal(t, "9.7000001", applySlippage(quoted, 0, model.RoundCeil).AsString())
+}
+
+func TestCheckTakerInputs(t *testing.T) {
+	amount, e := checkTakerInputs(testTakerNative, model.MustNumberFromString("1.123456789", 9), testTakerUSD, 0.01, model.RoundFloor)
+	if assert.NoError(t, e) {
+		assert.Equal(t, "1.1234567", amount.AsString())
+	}
+	amount, e = checkTakerInputs(testTakerNative, model.MustNumberFromString("1.123456711", 9), testTakerUSD, 0.01, model.RoundCeil)
+	if assert.NoError(t, e) {
+		assert.Equal(t, "1.1234568", amount.AsString())
+	}
+
+	_, e = checkTakerInputs(testTakerUSD, model.NumberFromFloat(1.0, 7), testTakerUSD, 0.01, model.RoundFloor)
+	assert.Error(t, e)
+	_, e = checkTakerInputs(testTakerNative, model.NumberFromFloat(1.0, 7), testTakerUSD, 1.0, model.RoundFloor)
+	assert.Error(t, e)
+	_, e = checkTakerInputs(testTakerNative, model.NumberFromFloat(1.0, 7), testTakerUSD, -0.1, model.RoundFloor)
+	assert.Error(t, e)
+	_, e = checkTakerInputs(testTakerNative, model.MustNumberFromString("0.00000001", 8), testTakerUSD, 0.01, model.RoundFloor)
+	assert.Error(t, e)
+	_, e = checkTakerInputs(testTakerNative, nil, testTakerUSD, 0.01, model.RoundFloor)
+	assert.Error(t, e)
+}
+
+func TestPathAssetString(t *testing.T) {
+	assert.Equal(t, "native", pathAssetString(testTakerNative))
+	assert.Equal(t, "USD:"+testTakerIssuer, pathAssetString(testTakerUSD))
+}
+
+func TestSelfCrossingOffers(t *testing.T) {
+	offers := []hProtocol.Offer{
+		{ID: 1, Selling: testTakerUSD, Buying: testTakerNative}, // on the direct book, crossed when sending XLM for USD
+		{ID: 2, Selling: testTakerNative, Buying: testTakerUSD}, // other side of the book
+		{ID: 3, Selling: testTakerBTC, Buying: testTakerNative}, // first hop of XLM -> BTC -> USD
+		{ID: 4, Selling: testTakerUSD, Buying: testTakerBTC},    // second hop of XLM -> BTC -> USD
+		{ID: 5, Selling: testTakerNative, Buying: testTakerBTC},
+	}
+
+	ids := func(offers []hProtocol.Offer) []int64 {
+		result := []int64{}
+		for _, o := range offers {
+			result = append(result, o.ID)
+		}
+		return result
+	}
+	assert.Equal(t, []int64{1}, ids(selfCrossingOffers(offers, testTakerNative, []hProtocol.Asset{}, testTakerUSD)))
+	assert.Equal(t, []int64{2}, ids(selfCrossingOffers(offers, testTakerUSD, []hProtocol.Asset{}, testTakerNative)))
+	assert.Equal(t, []int64{3, 4}, ids(selfCrossingOffers(offers, testTakerNative, []hProtocol.Asset{testTakerBTC}, testTakerUSD)))
+}
+
+// fakeBalance returns the balance of the account on the fake horizon server
+func fakeBalance(t *testing.T, s *horizonfake.Server, accountID string, asset hProtocol.Asset) string {
+	account, e := s.Client().AccountDetail(horizonclient.AccountRequest{AccountID: accountID})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, b := range account.Balances {
+		if sameAsset(hProtocol.Asset(b.Asset), asset) {
+			return b.Balance
+		}
+	}
+	return ""
+}
+
+func TestSDEX_TakeStrictSend(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+	// the maker sells 10 USD at 10 XLM each
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	result, e := sdex.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("50", 7), accounts.usd, 0.01)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.NotEqual(t, "", result.TxHash)
+	assert.Equal(t, "50.0000000", result.SendAmount.AsString())
+	assert.Equal(t, "5.0000000", result.DestAmount.AsString())
+	assert.Equal(t, "4.9500000", result.DestMin.AsString())
+	assert.Equal(t, 0, len(result.DeletedOfferIDs))
+
+	assert.Equal(t, "105.0000000", fakeBalance(t, s, accounts.trading, accounts.usd))
+	// 50 XLM sent and 100 stroops of fees
+	assert.Equal(t, "949.9999900", fakeBalance(t, s, accounts.trading, utils.NativeAsset))
+	assert.Equal(t, "1050.0000000", fakeBalance(t, s, accounts.maker, utils.NativeAsset))
+
+	// taking more than the book holds finds no path
+	_, e = sdex.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("100", 7), accounts.usd, 0.01)
+	assert.Error(t, e)
+}
+
+func TestSDEX_TakeStrictReceive(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	result, e := sdex.TakeStrictReceive(utils.NativeAsset, accounts.usd, model.MustNumberFromString("2", 7), 0.01)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "20.0000000", result.SendAmount.AsString())
+	assert.Equal(t, "20.2000000", result.SendMax.AsString())
+	assert.Equal(t, "2.0000000", result.DestAmount.AsString())
+
+	assert.Equal(t, "102.0000000", fakeBalance(t, s, accounts.trading, accounts.usd))
+	assert.Equal(t, "979.9999900", fakeBalance(t, s, accounts.trading, utils.NativeAsset))
+}
+
+func TestSDEX_TakeMultiHop(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+	eur := hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: accounts.usd.Issuer}
+	s.AddTrustline(accounts.maker, eur, "10000")
+	s.SetBalance(accounts.maker, eur, "100")
+	// buying USD directly costs 12 XLM each but only 10 XLM each through EUR
+	for _, o := range []struct {
+		selling hProtocol.Asset
+		buying  hProtocol.Asset
+		price   string
+	}{
+		{accounts.usd, utils.NativeAsset, "12"},
+		{eur, utils.NativeAsset, "5"},
+		{accounts.usd, eur, "2"},
+	} {
+		_, e := s.PlaceOffer(accounts.maker, o.selling, o.buying, "10", o.price)
+		if !assert.NoError(t, e) {
+			return
+		}
+	}
+
+	result, e := sdex.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("50", 7), accounts.usd, 0.01)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []hProtocol.Asset{eur}, result.Path)
+	assert.Equal(t, "5.0000000", result.DestAmount.AsString())
+	// the trading account never holds the intermediate asset
+	assert.Equal(t, "", fakeBalance(t, s, accounts.trading, eur))
+	assert.Equal(t, "105.0000000", fakeBalance(t, s, accounts.trading, accounts.usd))
+}
+
+func TestSDEX_TakeDeletesSelfCrossingOffers(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+	// our ask is the best offer selling USD so the path payment would cross it, our bid is on the other side of the book
+	ourAsk, e := s.PlaceOffer(accounts.trading, accounts.usd, utils.NativeAsset, "1", "9")
+	if !assert.NoError(t, e) {
+		return
+	}
+	ourBid, e := s.PlaceOffer(accounts.trading, utils.NativeAsset, accounts.usd, "10", "0.125")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// horizon quotes the path with our ask so allow enough slippage for the maker's price
+	result, e := sdex.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("20", 7), accounts.usd, 0.1)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []int64{ourAsk}, result.DeletedOfferIDs)
+
+	offers, e := sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(offers)) {
+		assert.Equal(t, ourBid, offers[0].ID)
+	}
+	// 20 XLM buys 2 USD from the maker
+	assert.Equal(t, "102.0000000", fakeBalance(t, s, accounts.trading, accounts.usd))
+}
+
+func TestSDEX_TakeOnlyDeletesOffersOfTheBot(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	_, accounts := makeFakeSDEX(s)
+	bot1 := makeFakeSDEXWithMemo(s, accounts, "bot1")
+	bot2 := makeFakeSDEXWithMemo(s, accounts, "bot2")
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+	// the bid of bot1 sells USD so the path payment would cross it, the ask of bot2 is on the other side of the book. Each SDEX caches the
+	// sequence number of the shared account so bot1 submits last
+	submitFakeOffer(t, bot2, accounts, false, 0.2, 10)
+	submitFakeOffer(t, bot1, accounts, true, 0.1, 10)
+
+	result, e := bot1.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("20", 7), accounts.usd, 0.1)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 1, len(result.DeletedOfferIDs))
+	offers, e := bot1.LoadOffersHack()
+	if assert.NoError(t, e) && assert.Equal(t, 1, len(offers)) {
+		assert.Equal(t, utils.NativeAsset, offers[0].Selling, "the ask of bot2 is left alone")
+	}
+
+	// bot2 places a bid that the path payment would cross after it was restarted, bot1 fails instead of deleting it
+	submitFakeOffer(t, makeFakeSDEXWithMemo(s, accounts, "bot2"), accounts, true, 0.1, 10)
+	_, e = bot1.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("20", 7), accounts.usd, 0.1)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "other bots sharing the trading account")
+	}
+	offers, e = bot1.LoadOffersHack()
+	if assert.NoError(t, e) {
+		assert.Equal(t, 2, len(offers))
+	}
+}
+
+func TestSDEX_TakeFailsOnSlippage(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+	// our own ask makes the quote better than what the maker offers, so the path payment fails on the slippage bound
+	_, e = s.PlaceOffer(accounts.trading, accounts.usd, utils.NativeAsset, "10", "5")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	_, e = sdex.TakeStrictSend(utils.NativeAsset, model.MustNumberFromString("20", 7), accounts.usd, 0.01)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "path payment failed")
+	}
+	// the deletes are in the same transaction as the path payment so our offer is kept
+	offers, e := sdex.LoadOffersHack()
+	if assert.NoError(t, e) {
+		assert.Equal(t, 1, len(offers))
+	}
+	assert.Equal(t, "100.0000000", fakeBalance(t, s, accounts.trading, accounts.usd))
+}
@@ -0,0 +1,246 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+
+	"github.com/stellar/kelp/support/utils"
+)
+
+// tradeAttribution attributes the trades of a trading account that is shared by several bots to the bot that tags its transactions
+// with memo.
+//
+// A trade belongs to the bot when the offer of the trading account was left on the book by a transaction with the memo (the bot was the
+// maker), or when the trade was made by an operation of the trading account in a transaction with the memo (the bot was the taker).
+// Offers are learned from the results of the transactions submitted by the bot, offers that were already open when the bot started are
+// looked up once in the transaction history of the account. Fills on offers that were opened and closed while the bot was not running
+// are not attributed to any bot.
+type tradeAttribution struct {
+	api            *horizonclient.Client
+	sourceAccount  string
+	tradingAccount string
+	memo           string
+
+	// uninitialized
+	lock             *sync.Mutex
+	offers           map[int64]bool  // offer ID -> left on the book by a transaction with the memo
+	txs              map[string]bool // transaction hash -> submitted by the bot
+	loadedOpenOffers bool
+}
+
+func makeTradeAttribution(api *horizonclient.Client, sourceAccount string, tradingAccount string, memo string) *tradeAttribution {
+	return &tradeAttribution{
+		api:            api,
+		sourceAccount:  sourceAccount,
+		tradingAccount: tradingAccount,
+		memo:           memo,
+		lock:           &sync.Mutex{},
+		offers:         map[int64]bool{},
+		txs:            map[string]bool{},
+	}
+}
+
+// recordSubmittedTx records the offers left on the book by a transaction that the bot submitted successfully
+func (a *tradeAttribution) recordSubmittedTx(hash string, resultXdr string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.txs[hash] = true
+	offerIDs, e := offerIDsFromResult(resultXdr)
+	if e != nil {
+		return fmt.Errorf("could not read the offers from the result of transaction %s: %s", hash, e)
+	}
+	for _, id := range offerIDs {
+		a.offers[id] = true
+	}
+	return nil
+}
+
+// loadOpenOffersOnce finds the owners of the offers that are open when it is first called, the fill tracker calls it when it starts
+func (a *tradeAttribution) loadOpenOffersOnce() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.loadedOpenOffers {
+		return nil
+	}
+	offers, e := utils.LoadAllOffers(a.tradingAccount, a.api)
+	if e != nil {
+		return fmt.Errorf("could not load the open offers on account %s: %s", a.tradingAccount, e)
+	}
+	e = a.loadOfferOwners(offers)
+	if e != nil {
+		return fmt.Errorf("could not load the owners of the open offers on account %s: %s", a.tradingAccount, e)
+	}
+	a.loadedOpenOffers = true
+	return nil
+}
+
+// isOwnTrade returns whether the trade belongs to this bot
+func (a *tradeAttribution) isOwnTrade(trade hProtocol.Trade) (bool, error) {
+	offerIDString := trade.BaseOfferID
+	if trade.BaseAccount != a.tradingAccount {
+		offerIDString = trade.CounterOfferID
+	}
+	offerID, e := strconv.ParseInt(offerIDString, 10, 64)
+	if e != nil {
+		return false, fmt.Errorf("could not parse offer ID '%s' of trade %s: %s", offerIDString, trade.ID, e)
+	}
+
+	e = a.loadOpenOffersOnce()
+	if e != nil {
+		return false, e
+	}
+
+	a.lock.Lock()
+	isOwnOffer, isKnownOffer := a.offers[offerID]
+	a.lock.Unlock()
+	if isKnownOffer {
+		return isOwnOffer, nil
+	}
+
+	// the offer was never on the book (e.g. the bot took offers) or it belongs to another party, check who made the trade
+	return a.isOwnOperation(strings.Split(trade.ID, "-")[0])
+}
+
+// isOwnOperation returns whether the operation is from the trading account in a transaction with the memo
+func (a *tradeAttribution) isOwnOperation(opID string) (bool, error) {
+	op, e := a.api.OperationDetail(opID)
+	if e != nil {
+		return false, fmt.Errorf("could not load operation %s: %s", opID, e)
+	}
+	hash := op.GetTransactionHash()
+
+	a.lock.Lock()
+	isOwnTx, isKnownTx := a.txs[hash]
+	a.lock.Unlock()
+	if isKnownTx {
+		return isOwnTx, nil
+	}
+
+	tx, e := a.api.TransactionDetail(hash)
+	if e != nil {
+		return false, fmt.Errorf("could not load transaction %s of operation %s: %s", hash, opID, e)
+	}
+	// the trading account made the trade since it owns one side of it, so only the source and the memo of the transaction need checking
+	isOwnTx = a.hasMemo(tx) && (tx.Account == a.sourceAccount || tx.Account == a.tradingAccount)
+
+	a.lock.Lock()
+	a.txs[hash] = isOwnTx
+	a.lock.Unlock()
+	return isOwnTx, nil
+}
+
+func (a *tradeAttribution) hasMemo(tx hProtocol.Transaction) bool {
+	return tx.MemoType == "text" && tx.Memo == a.memo
+}
+
+// loadOfferOwners goes back in the transaction history of the trading account until it finds the transaction that left each of the
+// open offers on the book, needs to be called with the lock held
+func (a *tradeAttribution) loadOfferOwners(offers []hProtocol.Offer) error {
+	pending := map[int64]bool{}
+	for _, o := range offers {
+		if _, ok := a.offers[o.ID]; !ok {
+			pending[o.ID] = true
+		}
+	}
+
+	cursor := ""
+	for len(pending) > 0 {
+		txsPage, e := a.api.Transactions(horizonclient.TransactionRequest{
+			ForAccount: a.tradingAccount,
+			Order:      horizonclient.OrderDesc,
+			Cursor:     cursor,
+			Limit:      uint(maxPageLimit),
+		})
+		if e != nil {
+			return fmt.Errorf("could not load transactions from cursor '%s': %s", cursor, e)
+		}
+		if len(txsPage.Embedded.Records) == 0 {
+			break
+		}
+
+		for _, tx := range txsPage.Embedded.Records {
+			cursor = tx.PT
+			offerIDs, e := offerIDsFromResult(tx.ResultXdr)
+			if e != nil {
+				return fmt.Errorf("could not read the offers from the result of transaction %s: %s", tx.Hash, e)
+			}
+			for _, id := range offerIDs {
+				if pending[id] {
+					// the most recent transaction that left the offer on the book owns it
+					a.offers[id] = a.hasMemo(tx)
+					delete(pending, id)
+				}
+			}
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("could not find the transactions that opened %d offers on account %s, their fills are not attributed to the bot with memo '%s'\n", len(pending), a.tradingAccount, a.memo)
+		for id := range pending {
+			a.offers[id] = false
+		}
+	}
+	return nil
+}
+
+// FilterOffersByMemo returns the open offers of the trading account that were left on the book by a transaction with the memo, so the
+// offers of a bot can be told apart from the offers of other bots sharing the trading account
+func FilterOffersByMemo(api *horizonclient.Client, tradingAccount string, memo string, offers []hProtocol.Offer) ([]hProtocol.Offer, error) {
+	a := makeTradeAttribution(api, tradingAccount, tradingAccount, memo)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	e := a.loadOfferOwners(offers)
+	if e != nil {
+		return nil, fmt.Errorf("could not load the owners of the offers on account %s: %s", tradingAccount, e)
+	}
+	filtered := []hProtocol.Offer{}
+	for _, o := range offers {
+		if a.offers[o.ID] {
+			filtered = append(filtered, o)
+		}
+	}
+	return filtered, nil
+}
+
+// offerIDsFromResult returns the IDs of the offers that the operations of a transaction created or updated
+func offerIDsFromResult(resultXdr string) ([]int64, error) {
+	var result xdr.TransactionResult
+	e := xdr.SafeUnmarshalBase64(resultXdr, &result)
+	if e != nil {
+		return nil, fmt.Errorf("could not decode the transaction result: %s", e)
+	}
+	opResults, ok := result.OperationResults()
+	if !ok {
+		return []int64{}, nil
+	}
+
+	offerIDs := []int64{}
+	for _, opResult := range opResults {
+		if opResult.Tr == nil {
+			continue
+		}
+
+		var success *xdr.ManageOfferSuccessResult
+		switch opResult.Tr.Type {
+		case xdr.OperationTypeManageSellOffer:
+			success = opResult.Tr.MustManageSellOfferResult().Success
+		case xdr.OperationTypeCreatePassiveSellOffer:
+			success = opResult.Tr.MustCreatePassiveSellOfferResult().Success
+		case xdr.OperationTypeManageBuyOffer:
+			success = opResult.Tr.MustManageBuyOfferResult().Success
+		}
+		if success != nil && success.Offer.Offer != nil {
+			offerIDs = append(offerIDs, int64(success.Offer.Offer.OfferId))
+		}
+	}
+	return offerIDs, nil
+}
@@ -0,0 +1,173 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// submitFakeOffer creates an offer with the SDEX, buy is from the point of view of the base asset XLM
+func submitFakeOffer(t *testing.T, sdex *SDEX, accounts fakeSDEXAccounts, isBuy bool, price float64, amount float64) {
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	var op *txnbuild.ManageSellOffer
+	var e error
+	if isBuy {
+		op, e = sdex.CreateBuyOffer(utils.NativeAsset, accounts.usd, price, amount, 0)
+	} else {
+		op, e = sdex.CreateSellOffer(utils.NativeAsset, accounts.usd, price, amount, 0)
+	}
+	if !assert.NoError(t, e) || !assert.NoError(t, submitFakeSDEX(sdex, op)) {
+		t.FailNow()
+	}
+}
+
+func tradeStrings(t *testing.T, sdex *SDEX) []string {
+	result, e := sdex.GetTradeHistory(*sdex.pair, nil, nil)
+	if !assert.NoError(t, e) {
+		t.FailNow()
+	}
+	trades := []string{}
+	for _, trade := range result.Trades {
+		trades = append(trades, fmt.Sprintf("%s %s @ %s", trade.OrderAction, trade.Volume.AsString(), trade.Price.AsString()))
+	}
+	return trades
+}
+
+func TestSDEX_GetTradeHistory_SharedTradingAccount(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	untagged, accounts := makeFakeSDEX(s)
+	bot1Before := makeFakeSDEXWithMemo(s, accounts, "bot1")
+	bot1 := makeFakeSDEXWithMemo(s, accounts, "bot1")
+	bot2 := makeFakeSDEXWithMemo(s, accounts, "bot2")
+
+	// bot1 opened an ask before it was restarted, bot2 has an ask and the restarted bot1 a bid
+	submitFakeOffer(t, bot1Before, accounts, false, 0.1, 10)
+	submitFakeOffer(t, bot2, accounts, false, 0.2, 10)
+	// the fill trackers load the open offers of the account when they start, the ask of bot1 is still open
+	assert.Equal(t, []string{}, tradeStrings(t, bot1))
+	assert.Equal(t, []string{}, tradeStrings(t, bot2))
+	submitFakeOffer(t, bot1, accounts, true, 0.05, 10)
+
+	// the maker takes both asks and sells into the bid of bot1
+	_, e := s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "3", "5")
+	if !assert.NoError(t, e) {
+		return
+	}
+	_, e = s.PlaceOffer(accounts.maker, utils.NativeAsset, accounts.usd, "4", "0.05")
+	if !assert.NoError(t, e) {
+		return
+	}
+	// bot1 takes the ask of the maker, its offer is filled completely so it never rests on the book
+	_, e = s.PlaceOffer(accounts.maker, utils.NativeAsset, accounts.usd, "10", "0.3")
+	if !assert.NoError(t, e) {
+		return
+	}
+	submitFakeOffer(t, bot1, accounts, true, 0.3, 2)
+
+	assert.Equal(t, []string{
+		"sell 10.0000000 @ 0.1000000",
+		"buy 4.0000000 @ 0.0500000",
+		"buy 2.0000000 @ 0.3000000",
+	}, tradeStrings(t, bot1))
+	assert.Equal(t, []string{
+		"sell 10.0000000 @ 0.2000000",
+	}, tradeStrings(t, bot2))
+	// a bot without a memo gets all the trades of the account
+	assert.Equal(t, 4, len(tradeStrings(t, untagged)))
+
+	// the open offers are only loaded once per bot
+	assert.Equal(t, 2, s.CallCount("account_transactions"))
+}
+
+func TestOfferIDsFromResult(t *testing.T) {
+	seller := xdr.MustAddress(keypair.MustRandom().Address())
+	offerResult := func(effect xdr.ManageOfferEffect, offerID int64) *xdr.ManageSellOfferResult {
+		success := &xdr.ManageOfferSuccessResult{Offer: xdr.ManageOfferSuccessResultOffer{Effect: effect}}
+		if effect != xdr.ManageOfferEffectManageOfferDeleted {
+			success.Offer.Offer = &xdr.OfferEntry{SellerId: seller, OfferId: xdr.Int64(offerID), Selling: xdr.MustNewNativeAsset(), Buying: xdr.MustNewNativeAsset(), Price: xdr.Price{N: 1, D: 1}}
+		}
+		return &xdr.ManageSellOfferResult{Code: xdr.ManageSellOfferResultCodeManageSellOfferSuccess, Success: success}
+	}
+	opResults := []xdr.OperationResult{
+		{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypeManageSellOffer, ManageSellOfferResult: offerResult(xdr.ManageOfferEffectManageOfferCreated, 7)}},
+		{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypeManageSellOffer, ManageSellOfferResult: offerResult(xdr.ManageOfferEffectManageOfferDeleted, 0)}},
+		{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypeCreatePassiveSellOffer, CreatePassiveSellOfferResult: offerResult(xdr.ManageOfferEffectManageOfferUpdated, 5)}},
+		{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypePayment, PaymentResult: &xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentSuccess}}},
+	}
+	resultXdr, e := xdr.MarshalBase64(xdr.TransactionResult{
+		FeeCharged: 400,
+		Result:     xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxSuccess, Results: &opResults},
+	})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	testCases := []struct {
+		name      string
+		resultXdr string
+		want      []int64
+		wantErr   bool
+	}{
+		{
+			name:      "offers left on the book",
+			resultXdr: resultXdr,
+			want:      []int64{7, 5},
+		}, {
+			name:      "not xdr",
+			resultXdr: "not xdr",
+			wantErr:   true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			offerIDs, e := offerIDsFromResult(k.resultXdr)
+			if k.wantErr {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) {
+				assert.Equal(t, k.want, offerIDs)
+			}
+		})
+	}
+}
+
+func TestTradeAttribution_OfferOpenedWithoutMemo(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	untagged, accounts := makeFakeSDEX(s)
+	submitFakeOffer(t, untagged, accounts, false, 0.1, 10)
+	offers, e := untagged.LoadOffersHack()
+	if !assert.NoError(t, e) || !assert.Equal(t, 1, len(offers)) {
+		return
+	}
+
+	// the maker takes half of the offer
+	_, e = s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "0.5", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+	trades, e := s.Client().Trades(horizonclient.TradeRequest{ForAccount: accounts.trading})
+	if !assert.NoError(t, e) || !assert.Equal(t, 1, len(trades.Embedded.Records)) {
+		return
+	}
+
+	attribution := makeTradeAttribution(s.Client(), accounts.trading, accounts.trading, "bot1")
+	isOwnTrade, e := attribution.isOwnTrade(trades.Embedded.Records[0])
+	if assert.NoError(t, e) {
+		assert.False(t, isOwnTrade)
+	}
+	assert.Equal(t, map[int64]bool{offers[0].ID: false}, attribution.offers)
+	assert.Equal(t, 0, s.CallCount("operation"))
+}
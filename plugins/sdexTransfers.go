@@ -0,0 +1,149 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// enforce SDEX implements the apis needed to move funds to and from the trading account
+var _ api.DepositAPI = &SDEX{}
+var _ api.WithdrawAPI = &SDEX{}
+var _ api.MemoWithdrawAPI = &SDEX{}
+var _ api.WithdrawStatusAPI = &SDEX{}
+var _ TransferVenue = &SDEX{}
+
+// horizonAsset converts a model.Asset of the trading pair to the asset on the Stellar network
+func (sdex *SDEX) horizonAsset(asset model.Asset) (hProtocol.Asset, error) {
+	a, ok := sdex.assetMap[asset]
+	if !ok {
+		return hProtocol.Asset{}, fmt.Errorf("asset %s is not one of the assets of the trading pair", asset)
+	}
+	return a, nil
+}
+
+// PrepareDeposit impl, deposits are payments to the trading account so there is no fee and no memo
+func (sdex *SDEX) PrepareDeposit(asset model.Asset, amount *model.Number) (*api.PrepareDepositResult, error) {
+	hAsset, e := sdex.horizonAsset(asset)
+	if e != nil {
+		return nil, e
+	}
+
+	willOverbuy, e := sdex.ieif.willOverbuy(hAsset, amount.AsFloat())
+	if e != nil {
+		return nil, e
+	}
+	if willOverbuy {
+		return nil, api.MakeErrDepositAmountAboveLimit(amount, amount)
+	}
+
+	return &api.PrepareDepositResult{
+		Fee:      model.NumberConstants.Zero,
+		Address:  sdex.TradingAccount,
+		ExpireTs: 0,
+	}, nil
+}
+
+// GetWithdrawInfo impl, the network fee is paid in XLM by the source account so the full amount is received
+func (sdex *SDEX) GetWithdrawInfo(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawInfo, error) {
+	hAsset, e := sdex.checkWithdrawal(asset, amountToWithdraw, address)
+	if e != nil {
+		return nil, e
+	}
+
+	willOversell, e := sdex.ieif.willOversell(hAsset, amountToWithdraw.AsFloat())
+	if e != nil {
+		return nil, e
+	}
+	if willOversell {
+		return nil, api.MakeErrWithdrawAmountAboveLimit(amountToWithdraw, amountToWithdraw)
+	}
+
+	return &api.WithdrawInfo{
+		AmountToReceive: amountToWithdraw,
+	}, nil
+}
+
+// WithdrawFunds impl
+func (sdex *SDEX) WithdrawFunds(asset model.Asset, amountToWithdraw *model.Number, address string) (*api.WithdrawFunds, error) {
+	return sdex.WithdrawFundsWithMemo(asset, amountToWithdraw, address, "")
+}
+
+// WithdrawFundsWithMemo impl, synchronously submits a payment from the trading account and returns the tx hash as the WithdrawalID
+func (sdex *SDEX) WithdrawFundsWithMemo(asset model.Asset, amountToWithdraw *model.Number, address string, memo string) (*api.WithdrawFunds, error) {
+	hAsset, e := sdex.checkWithdrawal(asset, amountToWithdraw, address)
+	if e != nil {
+		return nil, e
+	}
+	if len(memo) > txnbuild.MemoTextMaxLength {
+		return nil, fmt.Errorf("memo can be at most %d bytes long, was %d bytes", txnbuild.MemoTextMaxLength, len(memo))
+	}
+
+	op := &txnbuild.Payment{
+		Destination: address,
+		Amount:      amountToWithdraw.AsString(),
+		Asset:       utils.Asset2Asset(hAsset),
+	}
+	if sdex.SourceAccount != sdex.TradingAccount {
+		op.SourceAccount = &txnbuild.SimpleAccount{AccountID: sdex.TradingAccount}
+	}
+
+	var txHash string
+	var submitErr error
+	e = sdex.submitTxOpsWithMemo([]txnbuild.Operation{op}, memo, func(hash string, e error) {
+		txHash, submitErr = hash, e
+	}, false)
+	if e != nil {
+		return nil, fmt.Errorf("could not submit payment: %s", e)
+	}
+	if submitErr != nil {
+		return nil, fmt.Errorf("payment failed: %s", submitErr)
+	}
+	log.Printf("withdrew %s %s from the trading account to %s (memo='%s'), txHash=%s\n", amountToWithdraw.AsString(), asset, address, memo, txHash)
+
+	return &api.WithdrawFunds{
+		WithdrawalID: txHash,
+	}, nil
+}
+
+// GetWithdrawalStatus impl, withdrawals are submitted synchronously so the payment is final once horizon has the transaction
+func (sdex *SDEX) GetWithdrawalStatus(asset model.Asset, withdrawalID string) (api.WithdrawalStatus, error) {
+	tx, e := sdex.API.TransactionDetail(withdrawalID)
+	if e != nil {
+		if horizonclient.IsNotFoundError(e) {
+			return api.WithdrawalStatusPending, nil
+		}
+		return "", fmt.Errorf("could not load transaction %s: %s", withdrawalID, e)
+	}
+	if !tx.Successful {
+		return api.WithdrawalStatusFailed, nil
+	}
+	return api.WithdrawalStatusSent, nil
+}
+
+func (sdex *SDEX) checkWithdrawal(asset model.Asset, amountToWithdraw *model.Number, address string) (hProtocol.Asset, error) {
+	hAsset, e := sdex.horizonAsset(asset)
+	if e != nil {
+		return hProtocol.Asset{}, e
+	}
+	if !strkey.IsValidEd25519PublicKey(address) {
+		return hProtocol.Asset{}, fmt.Errorf("address '%s' is not a valid Stellar account", address)
+	}
+	if address == sdex.TradingAccount {
+		return hProtocol.Asset{}, fmt.Errorf("cannot withdraw to the trading account itself")
+	}
+
+	capped := model.NumberByCappingPrecisionWithRounding(amountToWithdraw, sdexOrderConstraints.VolumePrecision, model.RoundFloor)
+	if capped.Sign() <= 0 || capped.Cmp(*amountToWithdraw) != 0 {
+		return hProtocol.Asset{}, api.MakeErrWithdrawAmountInvalid(amountToWithdraw, model.NumberConstants.Zero)
+	}
+	return hAsset, nil
+}
@@ -0,0 +1,351 @@
+package plugins
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nikhilsaraf/go-tools/multithreading"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertToPassiveSellOffers(t *testing.T) {
+	usd := txnbuild.CreditAsset{Code: "USD", Issuer: testTakerIssuer}
+	trading := &txnbuild.SimpleAccount{AccountID: testTakerIssuer}
+	create := &txnbuild.ManageSellOffer{Selling: txnbuild.NativeAsset{}, Buying: usd, Amount: "10.0000000", Price: "0.1000000", SourceAccount: trading}
+	modify := &txnbuild.ManageSellOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "1.0000000", Price: "10.0000000", OfferID: 12}
+	del := &txnbuild.ManageSellOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "0", Price: "10.0000000", OfferID: 13}
+	payment := &txnbuild.Payment{Destination: testTakerIssuer, Amount: "1", Asset: txnbuild.NativeAsset{}}
+
+	converted := convertToPassiveSellOffers([]txnbuild.Operation{create, modify, del, payment})
+
+	assert.Equal(t, []txnbuild.Operation{
+		&txnbuild.CreatePassiveSellOffer{Selling: txnbuild.NativeAsset{}, Buying: usd, Amount: "10.0000000", Price: "0.1000000", SourceAccount: trading},
+		modify,
+		del,
+		payment,
+	}, converted)
+}
+
+type fakeSDEXAccounts struct {
+	trading     string
+	tradingSeed string
+	maker       string
+	usd         hProtocol.Asset
+}
+
+// makeFakeSDEX returns an SDEX trading XLM/USD on a fake horizon server, the trading account and a maker account each hold 1000 XLM and 100 USD
+func makeFakeSDEX(s *horizonfake.Server) (*SDEX, fakeSDEXAccounts) {
+	issuer := keypair.MustRandom()
+	trading := keypair.MustRandom()
+	maker := keypair.MustRandom()
+	usd := hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()}
+
+	s.AddAccount(issuer.Address(), "100")
+	for _, kp := range []*keypair.Full{trading, maker} {
+		s.AddAccount(kp.Address(), "1000")
+		s.AddTrustline(kp.Address(), usd, "10000")
+		s.SetBalance(kp.Address(), usd, "100")
+	}
+
+	accounts := fakeSDEXAccounts{trading: trading.Address(), tradingSeed: trading.Seed(), maker: maker.Address(), usd: usd}
+	return makeFakeSDEXWithMemo(s, accounts, ""), accounts
+}
+
+// makeFakeSDEXWithMemo returns an SDEX trading XLM/USD on the trading account of accounts that tags its transactions with the memo
+func makeFakeSDEXWithMemo(s *horizonfake.Server, accounts fakeSDEXAccounts, txMemo string) *SDEX {
+	pair := &model.TradingPair{Base: model.XLM, Quote: model.USD}
+	assetMap := map[model.Asset]hProtocol.Asset{
+		model.XLM: utils.NativeAsset,
+		model.USD: accounts.usd,
+	}
+	return MakeSDEX(
+		s.Client(),
+		MakeIEIF(true),
+		nil,
+		"",
+		accounts.tradingSeed,
+		"",
+		accounts.trading,
+		network.TestNetworkPassphrase,
+		multithreading.MakeThreadTracker(),
+		0,
+		0,
+		false,
+		pair,
+		assetMap,
+		SdexFixedFeeFn(100),
+		false,
+		nil,
+		txMemo,
+	)
+}
+
+// submitFakeSDEX synchronously submits the ops and returns the error passed to the callback
+func submitFakeSDEX(sdex *SDEX, ops ...*txnbuild.ManageSellOffer) error {
+	txOps := []txnbuild.Operation{}
+	for _, op := range ops {
+		txOps = append(txOps, op)
+	}
+
+	var submitError error
+	e := sdex.SubmitOpsSynch(api.ConvertOperation2TM(txOps), api.SubmitModeBoth, func(hash string, e error) {
+		submitError = e
+	})
+	if e != nil {
+		return e
+	}
+	return submitError
+}
+
+func TestSDEX_SubmitOps(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	sell, e := sdex.CreateSellOffer(utils.NativeAsset, accounts.usd, 0.1, 100, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	buy, e := sdex.CreateBuyOffer(utils.NativeAsset, accounts.usd, 0.09, 100, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if !assert.NoError(t, submitFakeSDEX(sdex, sell, buy)) {
+		return
+	}
+
+	offers, e := sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, len(offers))
+
+	ob, e := sdex.GetOrderBook(sdex.pair, 10)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(ob.Asks())) && assert.Equal(t, 1, len(ob.Bids())) {
+		assert.Equal(t, 0.1, ob.Asks()[0].Price.AsFloat())
+		assert.Equal(t, 100.0, ob.Asks()[0].Volume.AsFloat())
+		assert.Equal(t, 0.09, ob.Bids()[0].Price.AsFloat())
+		// bid amounts are in units of the counter asset on horizon so the volume is subject to rounding
+		assert.InDelta(t, 100.0, ob.Bids()[0].Volume.AsFloat(), 0.000001)
+	}
+
+	xlm, e := sdex.GetBalanceHack(utils.NativeAsset)
+	if !assert.NoError(t, e) {
+		return
+	}
+	// a transaction with 2 ops pays 200 stroops, the trustline and both offers are subentries
+	assert.Equal(t, 999.99998, xlm.Balance)
+	assert.Equal(t, 2.5, xlm.Reserve)
+
+	// modify the ask and delete the bid in a single transaction
+	var ask, bid hProtocol.Offer
+	for _, o := range offers {
+		if o.Selling.Type == utils.Native {
+			ask = o
+		} else {
+			bid = o
+		}
+	}
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	modify, e := sdex.ModifySellOffer(ask, 0.11, 50, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	del := sdex.DeleteOffer(bid)
+	if !assert.NoError(t, submitFakeSDEX(sdex, modify, &del)) {
+		return
+	}
+
+	offers, e = sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(offers)) {
+		assert.Equal(t, ask.ID, offers[0].ID)
+		assert.Equal(t, "50.0000000", offers[0].Amount)
+		assert.Equal(t, "0.1100000", offers[0].Price)
+	}
+}
+
+func TestSDEX_SubmitOps_ReloadsSequenceNumber(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	sell, e := sdex.CreateSellOffer(utils.NativeAsset, accounts.usd, 0.1, 10, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if !assert.NoError(t, submitFakeSDEX(sdex, sell)) {
+		return
+	}
+
+	// simulate another process using the account's sequence numbers
+	sdex.seqNum += 5
+	e = submitFakeSDEX(sdex, sell)
+	if assert.Error(t, e) {
+		assert.True(t, sdex.reloadSeqNum)
+	}
+
+	if !assert.NoError(t, submitFakeSDEX(sdex, sell)) {
+		return
+	}
+	offers, e := sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, len(offers))
+}
+
+func TestSDEX_GetWithdrawalStatus(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	result, e := sdex.WithdrawFunds(model.USD, model.NumberFromFloat(10, 7), accounts.maker)
+	if !assert.NoError(t, e) {
+		return
+	}
+	status, e := sdex.GetWithdrawalStatus(model.USD, result.WithdrawalID)
+	if assert.NoError(t, e) {
+		assert.Equal(t, api.WithdrawalStatusSent, status)
+	}
+
+	status, e = sdex.GetWithdrawalStatus(model.USD, strings.Repeat("0", 64))
+	if assert.NoError(t, e) {
+		assert.Equal(t, api.WithdrawalStatusPending, status)
+	}
+}
+
+func TestSDEX_GetTradeHistory(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+
+	cursor, e := sdex.GetLatestTradeCursor()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Nil(t, cursor)
+
+	sdex.IEIF().ResetCachedLiabilities(utils.NativeAsset, accounts.usd)
+	sell, e := sdex.CreateSellOffer(utils.NativeAsset, accounts.usd, 0.1, 100, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	buy, e := sdex.CreateBuyOffer(utils.NativeAsset, accounts.usd, 0.09, 100, 0)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if !assert.NoError(t, submitFakeSDEX(sdex, sell, buy)) {
+		return
+	}
+	offers, e := sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// the maker buys 40 XLM from our ask and then sells 20 XLM into our bid
+	_, e = s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "4", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+	_, e = s.PlaceOffer(accounts.maker, utils.NativeAsset, accounts.usd, "20", "0.09")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// a rate limit error returns the trades fetched so far without moving the cursor
+	s.FailNext("account_trades", 429, "Rate Limit Exceeded")
+	result, e := sdex.GetTradeHistory(*sdex.pair, nil, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "", result.Cursor)
+	assert.Equal(t, 0, len(result.Trades))
+
+	result, e = sdex.GetTradeHistory(*sdex.pair, nil, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if !assert.Equal(t, 2, len(result.Trades)) {
+		return
+	}
+	sellTrade, buyTrade := result.Trades[0], result.Trades[1]
+	assert.Equal(t, model.OrderActionSell, sellTrade.OrderAction)
+	assert.Equal(t, 0.1, sellTrade.Price.AsFloat())
+	assert.Equal(t, 40.0, sellTrade.Volume.AsFloat())
+	assert.Equal(t, model.OrderActionBuy, buyTrade.OrderAction)
+	assert.Equal(t, 0.09, buyTrade.Price.AsFloat())
+	assert.Equal(t, 20.0, buyTrade.Volume.AsFloat())
+	for _, o := range offers {
+		if o.Selling.Type == utils.Native {
+			assert.Equal(t, strconv.FormatInt(o.ID, 10), sellTrade.OrderID)
+		} else {
+			assert.Equal(t, strconv.FormatInt(o.ID, 10), buyTrade.OrderID)
+		}
+	}
+	assert.Equal(t, buyTrade.TransactionID.String(), result.Cursor)
+
+	latestCursor, e := sdex.GetLatestTradeCursor()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, result.Cursor, latestCursor)
+
+	result, e = sdex.GetTradeHistory(*sdex.pair, result.Cursor, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, len(result.Trades))
+	assert.Equal(t, latestCursor, result.Cursor)
+}
+
+func TestSdexFeed(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	sdex, accounts := makeFakeSDEX(s)
+
+	privateSdexHackVar = &privateSdexHack{
+		API:     s.Client(),
+		Ieif:    sdex.IEIF(),
+		Network: network.TestNetworkPassphrase,
+	}
+	defer func() {
+		privateSdexHackVar = nil
+	}()
+
+	_, e := s.PlaceOffer(accounts.maker, utils.NativeAsset, accounts.usd, "100", "0.12")
+	if !assert.NoError(t, e) {
+		return
+	}
+	_, e = s.PlaceOffer(accounts.maker, accounts.usd, utils.NativeAsset, "10", "10")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	feed, e := makeSDEXFeed("XLM:/USD:" + accounts.usd.Issuer)
+	if !assert.NoError(t, e) {
+		return
+	}
+	p, e := feed.GetPrice()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.InDelta(t, 0.11, p, 0.0000001)
+}
@@ -0,0 +1,223 @@
+package plugins
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/support/networking"
+)
+
+// LocalSigner signs transactions with seeds held by the kelp process, pass in more seeds than the source and trading seeds to meet
+// the thresholds of a multi-signature account
+type LocalSigner struct {
+	seeds []string
+}
+
+// enforce LocalSigner implements api.Signer
+var _ api.Signer = &LocalSigner{}
+
+// MakeLocalSigner is a factory method for LocalSigner, empty and duplicate seeds are ignored
+func MakeLocalSigner(seeds ...string) *LocalSigner {
+	uniqueSeeds := []string{}
+	seen := map[string]bool{}
+	for _, s := range seeds {
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		uniqueSeeds = append(uniqueSeeds, s)
+	}
+
+	return &LocalSigner{
+		seeds: uniqueSeeds,
+	}
+}
+
+// Sign impl
+func (s *LocalSigner) Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error) {
+	signatures := []xdr.DecoratedSignature{}
+	for i, seed := range s.seeds {
+		kp, e := keypair.ParseFull(seed)
+		if e != nil {
+			return nil, fmt.Errorf("cannot parse seed into keypair at index %d: %s", i, e)
+		}
+
+		signature, e := kp.SignDecorated(txHash[:])
+		if e != nil {
+			return nil, fmt.Errorf("cannot sign tx with keypair at index %d (pubKey: %s): %s", i, kp.Address(), e)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// MultiSigner combines the signatures of several signers, such as local seeds and a remote signing service
+type MultiSigner struct {
+	signers []api.Signer
+}
+
+// enforce MultiSigner implements api.Signer
+var _ api.Signer = &MultiSigner{}
+
+// MakeMultiSigner is a factory method for MultiSigner
+func MakeMultiSigner(signers ...api.Signer) *MultiSigner {
+	return &MultiSigner{
+		signers: signers,
+	}
+}
+
+// Sign impl, signatures with the same hint are only added once
+func (s *MultiSigner) Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error) {
+	signatures := []xdr.DecoratedSignature{}
+	seenHints := map[xdr.SignatureHint]bool{}
+	for i, signer := range s.signers {
+		sigs, e := signer.Sign(txHash, txeB64)
+		if e != nil {
+			return nil, fmt.Errorf("signer at index %d could not sign tx: %s", i, e)
+		}
+
+		for _, sig := range sigs {
+			if seenHints[sig.Hint] {
+				continue
+			}
+			seenHints[sig.Hint] = true
+			signatures = append(signatures, sig)
+		}
+	}
+	return signatures, nil
+}
+
+// RemoteSigner delegates signing to an HTTP signing service. The service receives a POST request with a JSON body
+// {"network_passphrase": "...", "tx_hash": "<hex>", "xdr": "<unsigned tx envelope>"} and responds with
+// {"signatures": [{"public_key": "G...", "signature": "<base64>"}]} or {"error": "..."}
+type RemoteSigner struct {
+	url        string
+	authToken  string
+	network    string
+	httpClient *http.Client
+}
+
+// enforce RemoteSigner implements api.Signer
+var _ api.Signer = &RemoteSigner{}
+
+type remoteSignerRequest struct {
+	NetworkPassphrase string `json:"network_passphrase"`
+	TxHash            string `json:"tx_hash"`
+	XDR               string `json:"xdr"`
+}
+
+type remoteSignerSignature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+type remoteSignerResponse struct {
+	Signatures []remoteSignerSignature `json:"signatures"`
+}
+
+// MakeRemoteSigner is a factory method for RemoteSigner, the authToken is sent as a bearer token when non-empty
+func MakeRemoteSigner(url string, authToken string, network string, timeout time.Duration) *RemoteSigner {
+	return &RemoteSigner{
+		url:        url,
+		authToken:  authToken,
+		network:    network,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Sign impl, every signature returned by the service is verified against the tx hash before it is used
+func (s *RemoteSigner) Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error) {
+	reqBytes, e := json.Marshal(remoteSignerRequest{
+		NetworkPassphrase: s.network,
+		TxHash:            hex.EncodeToString(txHash[:]),
+		XDR:               txeB64,
+	})
+	if e != nil {
+		return nil, fmt.Errorf("could not marshal remote signer request: %s", e)
+	}
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	if s.authToken != "" {
+		headers["Authorization"] = "Bearer " + s.authToken
+	}
+	var response remoteSignerResponse
+	e = networking.JSONRequest(s.httpClient, "POST", s.url, string(reqBytes), headers, &response, "error")
+	if e != nil {
+		return nil, fmt.Errorf("error requesting signatures from remote signer: %s", e)
+	}
+	if len(response.Signatures) == 0 {
+		return nil, fmt.Errorf("remote signer did not return any signatures")
+	}
+
+	signatures := []xdr.DecoratedSignature{}
+	for _, rs := range response.Signatures {
+		kp, e := keypair.ParseAddress(rs.PublicKey)
+		if e != nil {
+			return nil, fmt.Errorf("remote signer returned an invalid public key '%s': %s", rs.PublicKey, e)
+		}
+		sig, e := base64.StdEncoding.DecodeString(rs.Signature)
+		if e != nil {
+			return nil, fmt.Errorf("remote signer returned a signature that is not base64 encoded for public key %s: %s", rs.PublicKey, e)
+		}
+		e = kp.Verify(txHash[:], sig)
+		if e != nil {
+			return nil, fmt.Errorf("remote signer returned an invalid signature for public key %s: %s", rs.PublicKey, e)
+		}
+
+		signatures = append(signatures, xdr.DecoratedSignature{
+			Hint:      xdr.SignatureHint(kp.Hint()),
+			Signature: xdr.Signature(sig),
+		})
+	}
+	return signatures, nil
+}
+
+// MockSigner is a local signer for tests that signs with a random keypair and records the transactions it was asked to sign
+type MockSigner struct {
+	KP *keypair.Full
+
+	mutex  *sync.Mutex
+	signed []string
+}
+
+// enforce MockSigner implements api.Signer
+var _ api.Signer = &MockSigner{}
+
+// MakeMockSigner is a factory method for MockSigner
+func MakeMockSigner() *MockSigner {
+	return &MockSigner{
+		KP:     keypair.MustRandom(),
+		mutex:  &sync.Mutex{},
+		signed: []string{},
+	}
+}
+
+// Sign impl
+func (s *MockSigner) Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.signed = append(s.signed, txeB64)
+	signature, e := s.KP.SignDecorated(txHash[:])
+	if e != nil {
+		return nil, e
+	}
+	return []xdr.DecoratedSignature{signature}, nil
+}
+
+// Signed returns the unsigned transaction envelopes the MockSigner was asked to sign
+func (s *MockSigner) Signed() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.signed...)
+}
@@ -0,0 +1,182 @@
+package plugins
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTxHash = [32]byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+func TestLocalSigner(t *testing.T) {
+	kp1 := keypair.MustRandom()
+	kp2 := keypair.MustRandom()
+
+	// source and trading seeds are the same when there is no source account, empty seeds are not set
+	signer := MakeLocalSigner(kp1.Seed(), kp1.Seed(), "", kp2.Seed())
+	signatures, e := signer.Sign(testTxHash, "")
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 2, len(signatures)) {
+		assert.Equal(t, xdr.SignatureHint(kp1.Hint()), signatures[0].Hint)
+		assert.NoError(t, kp1.Verify(testTxHash[:], signatures[0].Signature))
+		assert.Equal(t, xdr.SignatureHint(kp2.Hint()), signatures[1].Hint)
+		assert.NoError(t, kp2.Verify(testTxHash[:], signatures[1].Signature))
+	}
+
+	_, e = MakeLocalSigner(kp1.Address()).Sign(testTxHash, "")
+	assert.Error(t, e)
+}
+
+type failingSigner struct{}
+
+func (s failingSigner) Sign(txHash [32]byte, txeB64 string) ([]xdr.DecoratedSignature, error) {
+	return nil, fmt.Errorf("failed")
+}
+
+func TestMultiSigner(t *testing.T) {
+	mock := MakeMockSigner()
+	kp := keypair.MustRandom()
+
+	signatures, e := MakeMultiSigner(MakeLocalSigner(kp.Seed()), mock, mock).Sign(testTxHash, "txe")
+	if assert.NoError(t, e) {
+		if assert.Equal(t, 2, len(signatures)) {
+			assert.Equal(t, xdr.SignatureHint(kp.Hint()), signatures[0].Hint)
+			assert.Equal(t, xdr.SignatureHint(mock.KP.Hint()), signatures[1].Hint)
+		}
+	}
+	assert.Equal(t, []string{"txe", "txe"}, mock.Signed())
+
+	_, e = MakeMultiSigner(mock, failingSigner{}).Sign(testTxHash, "txe")
+	assert.Error(t, e)
+}
+
+func makeTestRemoteSignerServer(t *testing.T, respond func(req remoteSignerRequest) interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var req remoteSignerRequest
+		e := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, e)
+
+		w.Header().Set("Content-Type", "application/json")
+		e = json.NewEncoder(w).Encode(respond(req))
+		assert.NoError(t, e)
+	}))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	kp := keypair.MustRandom()
+	wrongKP := keypair.MustRandom()
+	signWith := func(signingKP *keypair.Full, publicKP *keypair.Full) func(req remoteSignerRequest) interface{} {
+		return func(req remoteSignerRequest) interface{} {
+			assert.Equal(t, network.TestNetworkPassphrase, req.NetworkPassphrase)
+			assert.Equal(t, "0102030405060708000000000000000000000000000000000000000000000000", req.TxHash)
+			assert.Equal(t, "txe", req.XDR)
+
+			sig, e := signingKP.Sign(testTxHash[:])
+			assert.NoError(t, e)
+			return remoteSignerResponse{Signatures: []remoteSignerSignature{{
+				PublicKey: publicKP.Address(),
+				Signature: base64.StdEncoding.EncodeToString(sig),
+			}}}
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		respond   func(req remoteSignerRequest) interface{}
+		wantError bool
+	}{
+		{
+			name:    "valid signature",
+			respond: signWith(kp, kp),
+		}, {
+			name:      "signature does not match public key",
+			respond:   signWith(wrongKP, kp),
+			wantError: true,
+		}, {
+			name: "error response",
+			respond: func(req remoteSignerRequest) interface{} {
+				return map[string]string{"error": "account not allowed"}
+			},
+			wantError: true,
+		}, {
+			name: "no signatures",
+			respond: func(req remoteSignerRequest) interface{} {
+				return remoteSignerResponse{}
+			},
+			wantError: true,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			server := makeTestRemoteSignerServer(t, k.respond)
+			defer server.Close()
+
+			signatures, e := MakeRemoteSigner(server.URL, "token", network.TestNetworkPassphrase, time.Second).Sign(testTxHash, "txe")
+			if k.wantError {
+				assert.Error(t, e)
+				return
+			}
+			if assert.NoError(t, e) && assert.Equal(t, 1, len(signatures)) {
+				assert.Equal(t, xdr.SignatureHint(kp.Hint()), signatures[0].Hint)
+				assert.NoError(t, kp.Verify(testTxHash[:], signatures[0].Signature))
+			}
+		})
+	}
+}
+
+func TestSDEXSignWithSigner(t *testing.T) {
+	sourceKP := keypair.MustRandom()
+	tx, e := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: sourceKP.Address(), Sequence: 1},
+		BaseFee:              100,
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 5}},
+		Timebounds:           txnbuild.NewInfiniteTimeout(),
+	})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	mock := MakeMockSigner()
+	sdex := &SDEX{
+		Network: network.TestNetworkPassphrase,
+		signer:  MakeMultiSigner(MakeLocalSigner(sourceKP.Seed()), mock),
+	}
+	txeB64, e := sdex.sign(tx)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	unsignedTxeB64, e := tx.Base64()
+	assert.NoError(t, e)
+	assert.Equal(t, []string{unsignedTxeB64}, mock.Signed())
+
+	var txe xdr.TransactionEnvelope
+	e = xdr.SafeUnmarshalBase64(txeB64, &txe)
+	if !assert.NoError(t, e) {
+		return
+	}
+	hash, e := tx.Hash(network.TestNetworkPassphrase)
+	assert.NoError(t, e)
+	signatures := txe.Signatures()
+	if assert.Equal(t, 2, len(signatures)) {
+		assert.NoError(t, sourceKP.Verify(hash[:], signatures[0].Signature))
+		assert.NoError(t, mock.KP.Verify(hash[:], signatures[1].Signature))
+	}
+}
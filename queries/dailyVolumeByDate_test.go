@@ -192,8 +192,10 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 		kelpdb.SqlTradesTableCreate,
 		"ALTER TABLE trades DROP COLUMN IF EXISTS account_id",
 		"ALTER TABLE trades DROP COLUMN IF EXISTS order_id",
+		"ALTER TABLE trades DROP COLUMN IF EXISTS client_order_id",
 		kelpdb.SqlTradesTableAlter1,
 		kelpdb.SqlTradesTableAlter2,
+		kelpdb.SqlTradesTableAlter3,
 		"DELETE FROM trades", // clear table
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -207,6 +209,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID1",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -220,6 +223,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID1",
 			"oid1",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -233,6 +237,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.10, // fee
 			"accountID1",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -246,6 +251,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID1",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -259,6 +265,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID1",
 			"",
+			"",
 		),
 		// add an extra one for accountID2
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
@@ -273,6 +280,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID2",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -286,6 +294,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.0,   // fee
 			"accountID2",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -299,6 +308,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.5,  // fee
 			"accountID2",
 			"",
+			"",
 		),
 		fmt.Sprintf(kelpdb.SqlTradesInsertTemplate,
 			"market1",
@@ -312,6 +322,7 @@ func TestDailyVolumeByDate_QueryRow(t *testing.T) {
 			0.7,   // fee
 			"accountID2",
 			"",
+			"",
 		),
 	}
 	db := connectTestDb()
@@ -0,0 +1,97 @@
+package queries
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// sqlQueryInactiveBotHeartbeats queries the bot_heartbeats table for the bots of an account that trade on SDEX and have not updated since
+// the cutoff. Bots trading on other exchanges write heartbeats too but their offers are not on SDEX, so they are filtered out by the
+// exchange_name of their market. Bots that share the account with a TX_MEMO write their heartbeats as "<account>:<memo>".
+const sqlQueryInactiveBotHeartbeats = "SELECT h.account_id, h.market_id, h.base_code, h.base_issuer, h.quote_code, h.quote_issuer, h.last_update_utc FROM bot_heartbeats h JOIN markets m ON m.market_id = h.market_id WHERE (h.account_id = $1 OR h.account_id LIKE $1::text || ':%') AND m.exchange_name = 'sdex' AND h.last_update_utc < $2 ORDER BY h.last_update_utc"
+
+// BotHeartbeat is a row of the bot_heartbeats table
+type BotHeartbeat struct {
+	AccountID     string
+	BotKey        model.BotKey
+	MarketID      string
+	LastUpdateUTC time.Time
+}
+
+// String impl
+func (h BotHeartbeat) String() string {
+	return fmt.Sprintf("BotHeartbeat(accountID=%s, botKey=%s, marketID=%s, lastUpdateUTC=%s)", h.AccountID, h.BotKey.Key(), h.MarketID, h.LastUpdateUTC.Format(time.RFC3339))
+}
+
+// InactiveBotHeartbeats is a query that fetches the heartbeats of the SDEX bots on an account that are older than a cutoff
+type InactiveBotHeartbeats struct {
+	db        *sql.DB
+	sqlQuery  string
+	accountID string
+}
+
+var _ api.Query = &InactiveBotHeartbeats{}
+
+// MakeInactiveBotHeartbeats makes the InactiveBotHeartbeats query
+func MakeInactiveBotHeartbeats(db *sql.DB, accountID string) (*InactiveBotHeartbeats, error) {
+	if db == nil {
+		utils.PrintErrorHintf("the provided POSTGRES_DB config in the terminator config file should be non-nil")
+		return nil, fmt.Errorf("the provided db should be non-nil")
+	}
+
+	return &InactiveBotHeartbeats{
+		db:        db,
+		sqlQuery:  sqlQueryInactiveBotHeartbeats,
+		accountID: accountID,
+	}, nil
+}
+
+// Name impl.
+func (q *InactiveBotHeartbeats) Name() string {
+	return "InactiveBotHeartbeats"
+}
+
+// QueryRow impl, returns the []BotHeartbeat that were last updated before the cutoff
+func (q *InactiveBotHeartbeats) QueryRow(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 args (cutoff time.Time), but got args %v", args)
+	}
+	cutoff, ok := args[0].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("input arg[0] needs to be of type 'time.Time', but was of type '%T'", args[0])
+	}
+
+	rows, e := q.db.Query(q.sqlQuery, q.accountID, cutoff.UTC())
+	if e != nil {
+		return nil, fmt.Errorf("could not execute InactiveBotHeartbeats query: %s", e)
+	}
+	defer rows.Close()
+
+	heartbeats := []BotHeartbeat{}
+	for rows.Next() {
+		var h BotHeartbeat
+		e := rows.Scan(
+			&h.AccountID,
+			&h.MarketID,
+			&h.BotKey.AssetBaseCode,
+			&h.BotKey.AssetBaseIssuer,
+			&h.BotKey.AssetQuoteCode,
+			&h.BotKey.AssetQuoteIssuer,
+			&h.LastUpdateUTC,
+		)
+		if e != nil {
+			return nil, fmt.Errorf("could not read data from InactiveBotHeartbeats query: %s", e)
+		}
+		h.LastUpdateUTC = h.LastUpdateUTC.UTC()
+		heartbeats = append(heartbeats, h)
+	}
+	if e := rows.Err(); e != nil {
+		return nil, fmt.Errorf("error while iterating over rows of InactiveBotHeartbeats query: %s", e)
+	}
+	return heartbeats, nil
+}
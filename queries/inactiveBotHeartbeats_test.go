@@ -0,0 +1,136 @@
+package queries
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/kelpdb"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/postgresdb"
+)
+
+func TestInactiveBotHeartbeats_QueryRow(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-01-21T15:00:00Z")
+	stale := now.Add(-10 * time.Minute)
+	staler := now.Add(-20 * time.Minute)
+	stalest := now.Add(-25 * time.Minute)
+	fresh := now.Add(-1 * time.Minute)
+
+	// setup db
+	setupStatements := []string{
+		kelpdb.SqlMarketsTableCreate,
+		kelpdb.SqlBotHeartbeatsTableCreate,
+		"DELETE FROM markets WHERE market_id LIKE 'hbmarket%'",
+		"DELETE FROM bot_heartbeats", // clear table
+		fmt.Sprintf(kelpdb.SqlMarketsInsertTemplate, "hbmarket_sdex1", "sdex", "XLM", "USD"),
+		fmt.Sprintf(kelpdb.SqlMarketsInsertTemplate, "hbmarket_sdex2", "sdex", "XLM", "EUR"),
+		fmt.Sprintf(kelpdb.SqlMarketsInsertTemplate, "hbmarket_binance", "binance", "XLM", "USD"),
+		// stale SDEX bots on the account, returned oldest first
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID1", "bot1", "hbmarket_sdex1", "native", "", "USD", "issuer", stale.Format(postgresdb.TimestampFormatString)),
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID1", "bot2", "hbmarket_sdex2", "native", "", "EUR", "issuer", staler.Format(postgresdb.TimestampFormatString)),
+		// active SDEX bot on the account
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID1", "bot3", "hbmarket_sdex1", "native", "", "BTC", "issuer", fresh.Format(postgresdb.TimestampFormatString)),
+		// stale CEX bot on the account, its assets match the SDEX offers of bot1 but it has no offers on SDEX
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID1", "bot4", "hbmarket_binance", "native", "", "USD", "issuer", stale.Format(postgresdb.TimestampFormatString)),
+		// stale SDEX bot sharing the account with a TX_MEMO
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID1:memo1", "bot5", "hbmarket_sdex1", "native", "", "USD", "issuer", stalest.Format(postgresdb.TimestampFormatString)),
+		// stale SDEX bots on other accounts, the ID of one of them starts with the ID of the account
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID2", "bot1", "hbmarket_sdex1", "native", "", "USD", "issuer", stale.Format(postgresdb.TimestampFormatString)),
+		fmt.Sprintf(kelpdb.SqlBotHeartbeatsUpsertTemplate, "accountID10", "bot1", "hbmarket_sdex1", "native", "", "USD", "issuer", stale.Format(postgresdb.TimestampFormatString)),
+	}
+	db := connectTestDb()
+	defer db.Close()
+	for _, s := range setupStatements {
+		_, e := db.Exec(s)
+		if e != nil {
+			panic(e)
+		}
+	}
+
+	testCases := []struct {
+		accountID string
+		cutoff    time.Time
+		want      []BotHeartbeat
+	}{
+		{
+			accountID: "accountID1",
+			cutoff:    now.Add(-5 * time.Minute),
+			want: []BotHeartbeat{
+				{
+					AccountID:     "accountID1:memo1",
+					BotKey:        model.BotKey{AssetBaseCode: "native", AssetQuoteCode: "USD", AssetQuoteIssuer: "issuer"},
+					MarketID:      "hbmarket_sdex1",
+					LastUpdateUTC: stalest,
+				}, {
+					AccountID:     "accountID1",
+					BotKey:        model.BotKey{AssetBaseCode: "native", AssetQuoteCode: "EUR", AssetQuoteIssuer: "issuer"},
+					MarketID:      "hbmarket_sdex2",
+					LastUpdateUTC: staler,
+				}, {
+					AccountID:     "accountID1",
+					BotKey:        model.BotKey{AssetBaseCode: "native", AssetQuoteCode: "USD", AssetQuoteIssuer: "issuer"},
+					MarketID:      "hbmarket_sdex1",
+					LastUpdateUTC: stale,
+				},
+			},
+		}, {
+			accountID: "accountID1",
+			cutoff:    now.Add(-15 * time.Minute),
+			want: []BotHeartbeat{
+				{
+					AccountID:     "accountID1:memo1",
+					BotKey:        model.BotKey{AssetBaseCode: "native", AssetQuoteCode: "USD", AssetQuoteIssuer: "issuer"},
+					MarketID:      "hbmarket_sdex1",
+					LastUpdateUTC: stalest,
+				}, {
+					AccountID:     "accountID1",
+					BotKey:        model.BotKey{AssetBaseCode: "native", AssetQuoteCode: "EUR", AssetQuoteIssuer: "issuer"},
+					MarketID:      "hbmarket_sdex2",
+					LastUpdateUTC: staler,
+				},
+			},
+		}, {
+			accountID: "accountID1",
+			cutoff:    now.Add(-30 * time.Minute),
+			want:      []BotHeartbeat{},
+		}, {
+			accountID: "accountID3", // does not exist
+			cutoff:    now,
+			want:      []BotHeartbeat{},
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(fmt.Sprintf("%s/%s", k.accountID, k.cutoff.Format(time.RFC3339)), func(t *testing.T) {
+			query, e := MakeInactiveBotHeartbeats(db, k.accountID)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, "InactiveBotHeartbeats", query.Name())
+
+			result, e := query.QueryRow(k.cutoff)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, k.want, result)
+		})
+	}
+}
+
+func TestInactiveBotHeartbeats_QueryRowArgs(t *testing.T) {
+	query := &InactiveBotHeartbeats{accountID: "accountID1"}
+
+	_, e := query.QueryRow()
+	assert.Error(t, e)
+
+	_, e = query.QueryRow("2020-01-21")
+	assert.Error(t, e)
+}
+
+func TestMakeInactiveBotHeartbeats_NilDb(t *testing.T) {
+	_, e := MakeInactiveBotHeartbeats(nil, "accountID1")
+	assert.Error(t, e)
+}
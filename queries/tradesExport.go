@@ -0,0 +1,193 @@
+package queries
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// sqlQueryTradesExportColumns are the columns of the trades table joined with the markets table, markets are left joined since a
+// market can be missing for trades written before the markets table existed
+const sqlQueryTradesExportColumns = "t.market_id, m.exchange_name, m.base, m.quote, t.txid, t.date_utc, t.action, t.type, t.counter_price, t.base_volume, t.counter_cost, t.fee, t.account_id, t.order_id, t.client_order_id"
+
+// sqlQueryTradesExportTemplate pages through the trades table in a stable order, the filters are added as a WHERE clause
+const sqlQueryTradesExportTemplate = "SELECT " + sqlQueryTradesExportColumns + " FROM trades t LEFT JOIN markets m ON t.market_id = m.market_id%s ORDER BY t.date_utc, t.market_id, t.txid LIMIT %d"
+
+// TradesExportFilter selects the trades to export, empty fields do not filter
+type TradesExportFilter struct {
+	MarketIDs  []string
+	AccountIDs []string
+	From       *time.Time // inclusive
+	To         *time.Time // exclusive
+	Action     *DailyVolumeAction
+}
+
+// ExportedTrade is a row of the trades table with the metadata of its market
+type ExportedTrade struct {
+	MarketID      string    `json:"market_id"`
+	ExchangeName  string    `json:"exchange_name"`
+	Base          string    `json:"base"`
+	Quote         string    `json:"quote"`
+	TxID          string    `json:"txid"`
+	DateUTC       time.Time `json:"date_utc"`
+	Action        string    `json:"action"`
+	Type          string    `json:"type"`
+	CounterPrice  float64   `json:"counter_price"`
+	BaseVolume    float64   `json:"base_volume"`
+	CounterCost   float64   `json:"counter_cost"`
+	Fee           float64   `json:"fee"`
+	AccountID     string    `json:"account_id"`
+	OrderID       string    `json:"order_id"`
+	ClientOrderID string    `json:"client_order_id"`
+}
+
+// TradesExportCursor is the position after the last trade of a page, trades are ordered by (date_utc, market_id, txid)
+type TradesExportCursor struct {
+	DateUTC  time.Time
+	MarketID string
+	TxID     string
+}
+
+// TradesExportPage is a page of exported trades, Next is nil on the last page
+type TradesExportPage struct {
+	Trades []ExportedTrade
+	Next   *TradesExportCursor
+}
+
+// TradesExport is a query that pages through the trades table for exports
+type TradesExport struct {
+	db       *sql.DB
+	filter   TradesExportFilter
+	pageSize int
+}
+
+var _ api.Query = &TradesExport{}
+
+// MakeTradesExport makes the TradesExport query
+func MakeTradesExport(db *sql.DB, filter TradesExportFilter, pageSize int) (*TradesExport, error) {
+	if db == nil {
+		utils.PrintErrorHintf("the provided POSTGRES_DB config in the trader.cfg file should be non-nil")
+		return nil, fmt.Errorf("the provided db should be non-nil")
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize needs to be positive, was %d", pageSize)
+	}
+
+	return &TradesExport{
+		db:       db,
+		filter:   filter,
+		pageSize: pageSize,
+	}, nil
+}
+
+// Name impl.
+func (q *TradesExport) Name() string {
+	return "TradesExport"
+}
+
+// QueryRow impl, takes the cursor of the page to fetch (nil for the first page) and returns a *TradesExportPage
+func (q *TradesExport) QueryRow(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 arg (cursor *TradesExportCursor), but got args %v", args)
+	}
+	cursor, ok := args[0].(*TradesExportCursor)
+	if !ok {
+		return nil, fmt.Errorf("input arg needs to be of type '*TradesExportCursor', but was of type '%T'", args[0])
+	}
+
+	sqlQuery, sqlArgs := makeSQLQueryTradesExport(q.filter, cursor, q.pageSize)
+	rows, e := q.db.Query(sqlQuery, sqlArgs...)
+	if e != nil {
+		return nil, fmt.Errorf("could not execute TradesExport query: %s", e)
+	}
+	defer rows.Close()
+
+	page := &TradesExportPage{Trades: []ExportedTrade{}}
+	for rows.Next() {
+		var t ExportedTrade
+		var exchangeName, base, quote, accountID, orderID, clientOrderID sql.NullString
+		e := rows.Scan(
+			&t.MarketID,
+			&exchangeName,
+			&base,
+			&quote,
+			&t.TxID,
+			&t.DateUTC,
+			&t.Action,
+			&t.Type,
+			&t.CounterPrice,
+			&t.BaseVolume,
+			&t.CounterCost,
+			&t.Fee,
+			&accountID,
+			&orderID,
+			&clientOrderID,
+		)
+		if e != nil {
+			return nil, fmt.Errorf("could not read data from TradesExport query: %s", e)
+		}
+		t.ExchangeName, t.Base, t.Quote = exchangeName.String, base.String, quote.String
+		t.AccountID, t.OrderID, t.ClientOrderID = accountID.String, orderID.String, clientOrderID.String
+		t.DateUTC = t.DateUTC.UTC()
+		page.Trades = append(page.Trades, t)
+	}
+	if e := rows.Err(); e != nil {
+		return nil, fmt.Errorf("error while iterating over rows of TradesExport query: %s", e)
+	}
+
+	if len(page.Trades) == q.pageSize {
+		last := page.Trades[len(page.Trades)-1]
+		page.Next = &TradesExportCursor{
+			DateUTC:  last.DateUTC,
+			MarketID: last.MarketID,
+			TxID:     last.TxID,
+		}
+	}
+	return page, nil
+}
+
+func makeSQLQueryTradesExport(filter TradesExportFilter, cursor *TradesExportCursor, pageSize int) (string, []interface{}) {
+	clauses := []string{}
+	args := []interface{}{}
+	nextParam := func(arg interface{}) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	inList := func(values []string) string {
+		params := []string{}
+		for _, v := range values {
+			params = append(params, nextParam(v))
+		}
+		return strings.Join(params, ", ")
+	}
+
+	if cursor != nil {
+		clauses = append(clauses, fmt.Sprintf("(t.date_utc, t.market_id, t.txid) > (%s, %s, %s)",
+			nextParam(cursor.DateUTC), nextParam(cursor.MarketID), nextParam(cursor.TxID)))
+	}
+	if len(filter.MarketIDs) > 0 {
+		clauses = append(clauses, fmt.Sprintf("t.market_id IN (%s)", inList(filter.MarketIDs)))
+	}
+	if len(filter.AccountIDs) > 0 {
+		clauses = append(clauses, fmt.Sprintf("t.account_id IN (%s)", inList(filter.AccountIDs)))
+	}
+	if filter.From != nil {
+		clauses = append(clauses, fmt.Sprintf("t.date_utc >= %s", nextParam(filter.From.UTC())))
+	}
+	if filter.To != nil {
+		clauses = append(clauses, fmt.Sprintf("t.date_utc < %s", nextParam(filter.To.UTC())))
+	}
+	if filter.Action != nil {
+		clauses = append(clauses, fmt.Sprintf("t.action = %s", nextParam(filter.Action.String())))
+	}
+
+	where := ""
+	if len(clauses) > 0 {
+		where = " WHERE " + strings.Join(clauses, " AND ")
+	}
+	return fmt.Sprintf(sqlQueryTradesExportTemplate, where, pageSize), args
+}
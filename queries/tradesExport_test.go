@@ -0,0 +1,65 @@
+package queries
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeSQLQueryTradesExport(t *testing.T) {
+	from := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cursorDate := time.Date(2020, time.June, 3, 12, 30, 0, 0, time.UTC)
+	sell := DailyVolumeActionSell
+
+	testCases := []struct {
+		name      string
+		filter    TradesExportFilter
+		cursor    *TradesExportCursor
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no filter",
+			filter:    TradesExportFilter{},
+			cursor:    nil,
+			wantWhere: "",
+			wantArgs:  []interface{}{},
+		}, {
+			name: "all filters",
+			filter: TradesExportFilter{
+				MarketIDs:  []string{"m1", "m2"},
+				AccountIDs: []string{"a1"},
+				From:       &from,
+				To:         &to,
+				Action:     &sell,
+			},
+			cursor:    nil,
+			wantWhere: " WHERE t.market_id IN ($1, $2) AND t.account_id IN ($3) AND t.date_utc >= $4 AND t.date_utc < $5 AND t.action = $6",
+			wantArgs:  []interface{}{"m1", "m2", "a1", from, to, "sell"},
+		}, {
+			name: "cursor with filter",
+			filter: TradesExportFilter{
+				MarketIDs: []string{"m1"},
+			},
+			cursor:    &TradesExportCursor{DateUTC: cursorDate, MarketID: "m1", TxID: "tx9"},
+			wantWhere: " WHERE (t.date_utc, t.market_id, t.txid) > ($1, $2, $3) AND t.market_id IN ($4)",
+			wantArgs:  []interface{}{cursorDate, "m1", "tx9", "m1"},
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			sqlQuery, args := makeSQLQueryTradesExport(k.filter, k.cursor, 500)
+			assert.Equal(t, fmt.Sprintf(sqlQueryTradesExportTemplate, k.wantWhere, 500), sqlQuery)
+			assert.Equal(t, k.wantArgs, args)
+		})
+	}
+}
+
+func TestMakeTradesExportValidation(t *testing.T) {
+	_, e := MakeTradesExport(nil, TradesExportFilter{}, 10)
+	assert.Error(t, e)
+}
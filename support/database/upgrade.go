@@ -68,6 +68,25 @@ func ConnectInitializedDatabase(postgresDbConfig *postgresdb.Config, upgradeScri
 	return db, nil
 }
 
+// ConnectReadOnlyDatabase opens an existing database without creating it or running the upgrade scripts, every transaction on the
+// connection is read-only so callers cannot modify the database
+func ConnectReadOnlyDatabase(postgresDbConfig *postgresdb.Config) (*sql.DB, error) {
+	// unknown keys in the connect string are sent to the server as run-time parameters
+	db, e := sql.Open("postgres", postgresDbConfig.MakeConnectString()+" default_transaction_read_only=on")
+	if e != nil {
+		return nil, fmt.Errorf("could not open database: %s", e)
+	}
+
+	e = db.Ping()
+	if e != nil {
+		if strings.Contains(e.Error(), "connect: connection refused") {
+			utils.PrintErrorHintf("ensure your postgres database is available on %s:%d, or remove the 'POSTGRES_DB' config from your trader config file\n", postgresDbConfig.GetHost(), postgresDbConfig.GetPort())
+		}
+		return nil, fmt.Errorf("could not connect to database: %s", e)
+	}
+	return db, nil
+}
+
 // RunUpgradeScripts is a utility function that can be run from outside this package so we need to export it
 func RunUpgradeScripts(db *sql.DB, scripts []*UpgradeScript, codeVersionString string) error {
 	// save feature flags for the db_version table here
@@ -0,0 +1,518 @@
+package horizonfake
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// baseReserveStroops is the reserve required for the account and for each subentry (0.5 XLM)
+const baseReserveStroops = 5000000
+
+var nativeAsset = hProtocol.Asset{Type: "native"}
+
+// trustline holds the balance of an account for a single asset, the native balance uses math.MaxInt64 as the limit
+type trustline struct {
+	balance int64
+	limit   int64
+}
+
+// account is an account on the fake ledger, balances are in stroops
+type account struct {
+	id                 string
+	seq                int64
+	subentries         int32
+	assets             []hProtocol.Asset // in the order in which the trustlines were added, native first
+	balances           map[hProtocol.Asset]*trustline
+	lastModifiedLedger uint32
+}
+
+func (a *account) clone() *account {
+	c := *a
+	c.assets = append([]hProtocol.Asset{}, a.assets...)
+	c.balances = map[hProtocol.Asset]*trustline{}
+	for asset, tl := range a.balances {
+		tlCopy := *tl
+		c.balances[asset] = &tlCopy
+	}
+	return &c
+}
+
+// minBalance is the native balance that the account has to hold as a reserve
+func (a *account) minBalance() int64 {
+	return int64(2+a.subentries) * baseReserveStroops
+}
+
+// offer is a resting offer on the fake ledger, price is the amount of the buying asset per unit of the selling asset
+type offer struct {
+	id                 int64
+	seller             string
+	selling            hProtocol.Asset
+	buying             hProtocol.Asset
+	amount             int64
+	price              xdr.Price
+	passive            bool
+	lastModifiedLedger uint32
+}
+
+// trade is a fill between a taker (the source of an operation) and the offer of a maker
+//
+// The base asset of a trade is the asset that sorts first (see assetLess), the base party is the one that sold the base asset.
+type trade struct {
+	opID           int64
+	index          int
+	base           hProtocol.Asset
+	counter        hProtocol.Asset
+	baseAccount    string
+	counterAccount string
+	baseOfferID    int64
+	counterOfferID int64
+	baseAmount     int64
+	counterAmount  int64
+	baseIsSeller   bool // true when the base party owns the resting offer
+	price          xdr.Price
+	closeTime      time.Time
+}
+
+func (t *trade) pagingToken() string {
+	return fmt.Sprintf("%d-%d", t.opID, t.index)
+}
+
+// effect is a trade effect from the point of view of account, seller is the counterparty
+type effect struct {
+	opID         int64
+	index        int
+	account      string
+	seller       string
+	offerID      int64
+	sold         hProtocol.Asset
+	soldAmount   int64
+	bought       hProtocol.Asset
+	boughtAmount int64
+	closeTime    time.Time
+}
+
+func (e *effect) pagingToken() string {
+	return fmt.Sprintf("%d-%d", e.opID, e.index)
+}
+
+// ledger is the in-memory state of the fake network, every transaction (or scripted offer) closes a new ledger
+type ledger struct {
+	sequence    uint32
+	accounts    map[string]*account
+	offers      map[int64]*offer
+	trades      []*trade
+	effects     map[int64][]*effect // operation ID -> effects
+	lastOfferID int64
+
+	// lastPathPaymentDestAmount is the amount received by the destination of the last path payment, used for its operation result
+	lastPathPaymentDestAmount int64
+}
+
+func makeLedger() *ledger {
+	return &ledger{
+		sequence: 1,
+		accounts: map[string]*account{},
+		offers:   map[int64]*offer{},
+		trades:   []*trade{},
+		effects:  map[int64][]*effect{},
+	}
+}
+
+// clone makes a deep copy so a transaction can be applied atomically
+func (l *ledger) clone() *ledger {
+	c := &ledger{
+		sequence:    l.sequence,
+		accounts:    map[string]*account{},
+		offers:      map[int64]*offer{},
+		trades:      append([]*trade{}, l.trades...),
+		effects:     map[int64][]*effect{},
+		lastOfferID: l.lastOfferID,
+	}
+	for id, a := range l.accounts {
+		c.accounts[id] = a.clone()
+	}
+	for id, o := range l.offers {
+		oCopy := *o
+		c.offers[id] = &oCopy
+	}
+	for opID, effects := range l.effects {
+		c.effects[opID] = effects
+	}
+	return c
+}
+
+// opID follows the layout of the operation IDs used by horizon: ledger sequence, transaction index (always 1) and operation index (1-based)
+func (l *ledger) opID(opIndex int) int64 {
+	return int64(l.sequence)<<32 | int64(1)<<12 | int64(opIndex+1)
+}
+
+func (l *ledger) addAccount(id string, nativeBalance int64) {
+	l.accounts[id] = &account{
+		id:  id,
+		seq: int64(l.sequence) << 32,
+		assets: []hProtocol.Asset{
+			nativeAsset,
+		},
+		balances: map[hProtocol.Asset]*trustline{
+			nativeAsset: {balance: nativeBalance, limit: math.MaxInt64},
+		},
+		lastModifiedLedger: l.sequence,
+	}
+}
+
+func (l *ledger) addTrustline(id string, asset hProtocol.Asset, limit int64) error {
+	a, ok := l.accounts[id]
+	if !ok {
+		return fmt.Errorf("account %s does not exist", id)
+	}
+	if asset.Type == nativeAsset.Type {
+		return fmt.Errorf("cannot add a trustline for the native asset")
+	}
+
+	if tl, ok := a.balances[asset]; ok {
+		tl.limit = limit
+		return nil
+	}
+	a.assets = append(a.assets, asset)
+	a.balances[asset] = &trustline{balance: 0, limit: limit}
+	a.subentries++
+	return nil
+}
+
+// isIssuer is true when the account issued the asset, issuers have an unlimited supply of their own assets
+func isIssuer(accountID string, asset hProtocol.Asset) bool {
+	return asset.Type != nativeAsset.Type && asset.Issuer == accountID
+}
+
+// credit changes the balance of the account, it is a no-op for the issuer of the asset
+func (l *ledger) credit(accountID string, asset hProtocol.Asset, delta int64) {
+	if isIssuer(accountID, asset) {
+		return
+	}
+	a := l.accounts[accountID]
+	a.balances[asset].balance += delta
+	a.lastModifiedLedger = l.sequence
+}
+
+// liabilities returns the amounts of the asset that the offers of the account (other than excludeOfferID) are selling and buying
+func (l *ledger) liabilities(accountID string, asset hProtocol.Asset, excludeOfferID int64) (selling int64, buying int64) {
+	for _, o := range l.offers {
+		if o.seller != accountID || o.id == excludeOfferID {
+			continue
+		}
+		if o.selling == asset {
+			selling += o.amount
+		}
+		if o.buying == asset {
+			buying += mulDiv(o.amount, int64(o.price.N), int64(o.price.D), true)
+		}
+	}
+	return selling, buying
+}
+
+// availableToSell is the balance that is not locked in offers (other than excludeOfferID) or in the reserve
+func (l *ledger) availableToSell(accountID string, asset hProtocol.Asset, excludeOfferID int64, extraSubentries int32) int64 {
+	if isIssuer(accountID, asset) {
+		return math.MaxInt64
+	}
+	a := l.accounts[accountID]
+	selling, _ := l.liabilities(accountID, asset, excludeOfferID)
+	available := a.balances[asset].balance - selling
+	if asset == nativeAsset {
+		available -= int64(2+a.subentries+extraSubentries) * baseReserveStroops
+	}
+	return available
+}
+
+// availableToBuy is the room left on the trustline after accounting for the offers (other than excludeOfferID) buying the asset
+func (l *ledger) availableToBuy(accountID string, asset hProtocol.Asset, excludeOfferID int64) int64 {
+	if isIssuer(accountID, asset) {
+		return math.MaxInt64
+	}
+	tl := l.accounts[accountID].balances[asset]
+	_, buying := l.liabilities(accountID, asset, excludeOfferID)
+	return tl.limit - tl.balance - buying
+}
+
+func (l *ledger) hasTrustline(accountID string, asset hProtocol.Asset) bool {
+	if isIssuer(accountID, asset) {
+		return true
+	}
+	_, ok := l.accounts[accountID].balances[asset]
+	return ok
+}
+
+// manageOffer applies a ManageSellOffer (or CreatePassiveSellOffer when passive is true) operation and returns the result code
+//
+// A new offer (offerID == 0) or an updated offer is matched against the book at the price of the resting offers, any remaining
+// amount rests on the book. An amount of 0 deletes the offer.
+func (l *ledger) manageOffer(opID int64, source string, selling hProtocol.Asset, buying hProtocol.Asset, amount int64, price xdr.Price, offerID int64, passive bool, now time.Time) string {
+	if amount < 0 || price.N <= 0 || price.D <= 0 || selling == buying {
+		return "op_malformed"
+	}
+
+	var existing *offer
+	if offerID != 0 {
+		var ok bool
+		existing, ok = l.offers[offerID]
+		if !ok || existing.seller != source {
+			return "op_offer_not_found"
+		}
+		passive = existing.passive
+	}
+	if amount == 0 {
+		if existing != nil {
+			l.removeOffer(existing)
+		}
+		return "op_success"
+	}
+
+	if !l.hasTrustline(source, selling) {
+		return "op_sell_no_trust"
+	}
+	if !l.hasTrustline(source, buying) {
+		return "op_buy_no_trust"
+	}
+
+	extraSubentries := int32(0)
+	if existing == nil {
+		extraSubentries = 1
+		nativeBalance := l.accounts[source].balances[nativeAsset].balance
+		sellingNative, _ := l.liabilities(source, nativeAsset, 0)
+		if nativeBalance-sellingNative < l.accounts[source].minBalance()+baseReserveStroops {
+			return "op_low_reserve"
+		}
+	}
+	if amount > l.availableToSell(source, selling, offerID, extraSubentries) {
+		return "op_underfunded"
+	}
+	if mulDiv(amount, int64(price.N), int64(price.D), true) > l.availableToBuy(source, buying, offerID) {
+		return "op_line_full"
+	}
+
+	if existing != nil {
+		l.removeOffer(existing)
+	} else {
+		l.lastOfferID++
+		offerID = l.lastOfferID
+	}
+
+	remaining := amount
+	for remaining > 0 {
+		maker := l.bestOffer(buying, selling)
+		if maker == nil || !crosses(price, maker.price, passive) {
+			break
+		}
+		if maker.seller == source {
+			return "op_cross_self"
+		}
+
+		// the taker gets the asset that the maker is selling at the price of the maker (selling per unit of buying)
+		bought := mulDiv(remaining, int64(maker.price.D), int64(maker.price.N), false)
+		if bought > maker.amount {
+			bought = maker.amount
+		}
+		if bought == 0 {
+			// the remaining amount cannot buy a single stroop, drop it instead of leaving a crossed offer on the book
+			remaining = 0
+			break
+		}
+		sold := mulDiv(bought, int64(maker.price.N), int64(maker.price.D), true)
+
+		l.credit(source, selling, -sold)
+		l.credit(source, buying, bought)
+		l.credit(maker.seller, buying, -bought)
+		l.credit(maker.seller, selling, sold)
+		maker.amount -= bought
+		maker.lastModifiedLedger = l.sequence
+		if maker.amount == 0 {
+			l.removeOffer(maker)
+		}
+		remaining -= sold
+
+		l.recordTrade(opID, source, offerID, selling, sold, maker, bought, now)
+	}
+
+	if remaining > 0 {
+		l.offers[offerID] = &offer{
+			id:                 offerID,
+			seller:             source,
+			selling:            selling,
+			buying:             buying,
+			amount:             remaining,
+			price:              price,
+			passive:            passive,
+			lastModifiedLedger: l.sequence,
+		}
+		l.accounts[source].subentries++
+	}
+	return "op_success"
+}
+
+func (l *ledger) removeOffer(o *offer) {
+	delete(l.offers, o.id)
+	l.accounts[o.seller].subentries--
+}
+
+// bestOffer returns the offer selling the asset with the lowest price, the oldest offer wins ties
+func (l *ledger) bestOffer(selling hProtocol.Asset, buying hProtocol.Asset) *offer {
+	book := l.book(selling, buying)
+	if len(book) == 0 {
+		return nil
+	}
+	return book[0]
+}
+
+// book returns the offers selling the asset sorted by ascending price (and by ID within a price)
+func (l *ledger) book(selling hProtocol.Asset, buying hProtocol.Asset) []*offer {
+	book := []*offer{}
+	for _, o := range l.offers {
+		if o.selling == selling && o.buying == buying {
+			book = append(book, o)
+		}
+	}
+	sort.Slice(book, func(i, j int) bool {
+		c := comparePrices(book[i].price, book[j].price)
+		if c != 0 {
+			return c < 0
+		}
+		return book[i].id < book[j].id
+	})
+	return book
+}
+
+// crosses is true when the taker (price in buying per unit of selling) accepts the maker's price (taker's selling per unit of taker's buying),
+// passive takers do not take offers at the same price
+func crosses(takerPrice xdr.Price, makerPrice xdr.Price, passive bool) bool {
+	lhs := int64(makerPrice.N) * int64(takerPrice.N)
+	rhs := int64(makerPrice.D) * int64(takerPrice.D)
+	if passive {
+		return lhs < rhs
+	}
+	return lhs <= rhs
+}
+
+func comparePrices(a xdr.Price, b xdr.Price) int {
+	lhs := int64(a.N) * int64(b.D)
+	rhs := int64(b.N) * int64(a.D)
+	if lhs < rhs {
+		return -1
+	} else if lhs > rhs {
+		return 1
+	}
+	return 0
+}
+
+func (l *ledger) recordTrade(opID int64, taker string, takerOfferID int64, takerSold hProtocol.Asset, takerSoldAmount int64, maker *offer, makerSoldAmount int64, now time.Time) {
+	t := &trade{
+		opID:      opID,
+		index:     0,
+		closeTime: now,
+	}
+	for _, existing := range l.trades {
+		if existing.opID == opID {
+			t.index++
+		}
+	}
+
+	if assetLess(maker.selling, takerSold) {
+		// the maker sold the base asset so the price of the maker is already in units of the counter asset
+		t.base, t.counter = maker.selling, takerSold
+		t.baseAccount, t.counterAccount = maker.seller, taker
+		t.baseOfferID, t.counterOfferID = maker.id, takerOfferID
+		t.baseAmount, t.counterAmount = makerSoldAmount, takerSoldAmount
+		t.baseIsSeller = true
+		t.price = maker.price
+	} else {
+		t.base, t.counter = takerSold, maker.selling
+		t.baseAccount, t.counterAccount = taker, maker.seller
+		t.baseOfferID, t.counterOfferID = takerOfferID, maker.id
+		t.baseAmount, t.counterAmount = takerSoldAmount, makerSoldAmount
+		t.baseIsSeller = false
+		t.price = xdr.Price{N: maker.price.D, D: maker.price.N}
+	}
+	l.trades = append(l.trades, t)
+
+	effects := append([]*effect{}, l.effects[opID]...)
+	effects = append(effects, &effect{
+		opID:         opID,
+		index:        len(effects) + 1,
+		account:      taker,
+		seller:       maker.seller,
+		offerID:      maker.id,
+		sold:         takerSold,
+		soldAmount:   takerSoldAmount,
+		bought:       maker.selling,
+		boughtAmount: makerSoldAmount,
+		closeTime:    now,
+	})
+	effects = append(effects, &effect{
+		opID:         opID,
+		index:        len(effects) + 1,
+		account:      maker.seller,
+		seller:       taker,
+		offerID:      maker.id,
+		sold:         maker.selling,
+		soldAmount:   makerSoldAmount,
+		bought:       takerSold,
+		boughtAmount: takerSoldAmount,
+		closeTime:    now,
+	})
+	l.effects[opID] = effects
+}
+
+// payment applies a Payment operation and returns the result code
+func (l *ledger) payment(source string, destination string, asset hProtocol.Asset, amount int64) string {
+	if amount <= 0 {
+		return "op_malformed"
+	}
+	if _, ok := l.accounts[destination]; !ok {
+		return "op_no_destination"
+	}
+	if !l.hasTrustline(source, asset) {
+		return "op_src_no_trust"
+	}
+	if !l.hasTrustline(destination, asset) {
+		return "op_no_trust"
+	}
+	if amount > l.availableToSell(source, asset, 0, 0) {
+		return "op_underfunded"
+	}
+	if amount > l.availableToBuy(destination, asset, 0) {
+		return "op_line_full"
+	}
+
+	l.credit(source, asset, -amount)
+	l.credit(destination, asset, amount)
+	return "op_success"
+}
+
+// assetLess orders assets the way trades pick their base asset: native first, then by code and issuer
+func assetLess(a hProtocol.Asset, b hProtocol.Asset) bool {
+	if a.Type == nativeAsset.Type || b.Type == nativeAsset.Type {
+		return a.Type == nativeAsset.Type && b.Type != nativeAsset.Type
+	}
+	if a.Code != b.Code {
+		return a.Code < b.Code
+	}
+	return a.Issuer < b.Issuer
+}
+
+// mulDiv computes a*n/d without overflowing, rounding up or down
+func mulDiv(a int64, n int64, d int64, roundUp bool) int64 {
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(n))
+	q, r := new(big.Int).QuoRem(num, big.NewInt(d), new(big.Int))
+	if roundUp && r.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if !q.IsInt64() {
+		return math.MaxInt64
+	}
+	return q.Int64()
+}
@@ -0,0 +1,222 @@
+package horizonfake
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUSD = hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: "issuer"}
+
+// makeTestLedger has a maker and a taker with 1000 XLM and 100 USD each
+func makeTestLedger() *ledger {
+	l := makeLedger()
+	for _, id := range []string{"maker", "taker"} {
+		l.addAccount(id, 1000*1e7)
+		_ = l.addTrustline(id, testUSD, 1000*1e7)
+		l.accounts[id].balances[testUSD].balance = 100 * 1e7
+	}
+	return l
+}
+
+func TestManageOffer_MatchesAtMakerPrice(t *testing.T) {
+	l := makeTestLedger()
+	now := time.Unix(1600000000, 0)
+
+	// the maker sells 100 XLM at 0.1 and 100 XLM at 0.2 USD per XLM
+	for i, p := range []xdr.Price{{N: 1, D: 10}, {N: 2, D: 10}} {
+		code := l.manageOffer(l.opID(i), "maker", nativeAsset, testUSD, 100*1e7, p, 0, false, now)
+		if !assert.Equal(t, "op_success", code) {
+			return
+		}
+	}
+	assert.Equal(t, int32(3), l.accounts["maker"].subentries)
+
+	// the taker sells 15 USD at a price of 5 XLM per USD (i.e. buys XLM up to 0.2 USD per XLM)
+	l.sequence++
+	opID := l.opID(0)
+	code := l.manageOffer(opID, "taker", testUSD, nativeAsset, 15*1e7, xdr.Price{N: 5, D: 1}, 0, false, now)
+	if !assert.Equal(t, "op_success", code) {
+		return
+	}
+
+	// 10 USD buys 100 XLM at 0.1 and 5 USD buys 25 XLM at 0.2
+	assert.Equal(t, int64(1125*1e7), l.accounts["taker"].balances[nativeAsset].balance)
+	assert.Equal(t, int64(85*1e7), l.accounts["taker"].balances[testUSD].balance)
+	assert.Equal(t, int64(875*1e7), l.accounts["maker"].balances[nativeAsset].balance)
+	assert.Equal(t, int64(115*1e7), l.accounts["maker"].balances[testUSD].balance)
+	assert.Equal(t, int32(2), l.accounts["maker"].subentries)
+	assert.Equal(t, int32(1), l.accounts["taker"].subentries, "taker was filled completely so no offer rests on the book")
+	if assert.Equal(t, 1, len(l.offers)) {
+		assert.Equal(t, int64(75*1e7), l.offers[2].amount)
+	}
+
+	if assert.Equal(t, 2, len(l.trades)) {
+		first := l.trades[0]
+		assert.Equal(t, nativeAsset, first.base)
+		assert.Equal(t, "maker", first.baseAccount)
+		assert.Equal(t, "taker", first.counterAccount)
+		assert.True(t, first.baseIsSeller)
+		assert.Equal(t, xdr.Price{N: 1, D: 10}, first.price)
+		assert.Equal(t, int64(100*1e7), first.baseAmount)
+		assert.Equal(t, int64(10*1e7), first.counterAmount)
+		assert.Equal(t, fmt.Sprintf("%d-1", opID), l.trades[1].pagingToken())
+	}
+	if assert.Equal(t, 4, len(l.effects[opID])) {
+		makerEffect := l.effects[opID][1]
+		assert.Equal(t, "maker", makerEffect.account)
+		assert.Equal(t, nativeAsset, makerEffect.sold)
+		assert.Equal(t, testUSD, makerEffect.bought)
+	}
+}
+
+func TestManageOffer_UpdateAndDelete(t *testing.T) {
+	l := makeTestLedger()
+	now := time.Now()
+	code := l.manageOffer(l.opID(0), "maker", nativeAsset, testUSD, 100*1e7, xdr.Price{N: 1, D: 10}, 0, false, now)
+	if !assert.Equal(t, "op_success", code) {
+		return
+	}
+	id := l.lastOfferID
+
+	code = l.manageOffer(l.opID(0), "maker", nativeAsset, testUSD, 50*1e7, xdr.Price{N: 1, D: 5}, id, false, now)
+	if !assert.Equal(t, "op_success", code) {
+		return
+	}
+	if assert.Equal(t, 1, len(l.offers)) {
+		assert.Equal(t, int64(50*1e7), l.offers[id].amount)
+		assert.Equal(t, xdr.Price{N: 1, D: 5}, l.offers[id].price)
+	}
+	assert.Equal(t, int32(2), l.accounts["maker"].subentries)
+
+	assert.Equal(t, "op_offer_not_found", l.manageOffer(l.opID(0), "taker", nativeAsset, testUSD, 0, xdr.Price{N: 1, D: 5}, id, false, now))
+	assert.Equal(t, "op_success", l.manageOffer(l.opID(0), "maker", nativeAsset, testUSD, 0, xdr.Price{N: 1, D: 5}, id, false, now))
+	assert.Equal(t, 0, len(l.offers))
+	assert.Equal(t, int32(1), l.accounts["maker"].subentries)
+}
+
+func TestManageOffer_Errors(t *testing.T) {
+	eur := hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: "issuer"}
+	testCases := []struct {
+		name     string
+		selling  hProtocol.Asset
+		buying   hProtocol.Asset
+		amount   int64
+		price    xdr.Price
+		offerID  int64
+		passive  bool
+		wantCode string
+	}{
+		{
+			name:     "same assets",
+			selling:  testUSD,
+			buying:   testUSD,
+			amount:   1e7,
+			price:    xdr.Price{N: 1, D: 1},
+			wantCode: "op_malformed",
+		}, {
+			name:     "no trustline for buying",
+			selling:  nativeAsset,
+			buying:   eur,
+			amount:   1e7,
+			price:    xdr.Price{N: 1, D: 1},
+			wantCode: "op_buy_no_trust",
+		}, {
+			name:     "more than the balance",
+			selling:  testUSD,
+			buying:   nativeAsset,
+			amount:   101 * 1e7,
+			price:    xdr.Price{N: 1, D: 1},
+			wantCode: "op_underfunded",
+		}, {
+			name:     "native balance locked in the reserve",
+			selling:  nativeAsset,
+			buying:   testUSD,
+			amount:   999 * 1e7,
+			price:    xdr.Price{N: 1, D: 1000},
+			wantCode: "op_underfunded",
+		}, {
+			name:     "more than the trust limit",
+			selling:  nativeAsset,
+			buying:   testUSD,
+			amount:   950 * 1e7,
+			price:    xdr.Price{N: 1, D: 1},
+			wantCode: "op_line_full",
+		}, {
+			name:     "unknown offer",
+			selling:  nativeAsset,
+			buying:   testUSD,
+			amount:   1e7,
+			price:    xdr.Price{N: 1, D: 1},
+			offerID:  42,
+			wantCode: "op_offer_not_found",
+		}, {
+			name:     "crosses own offer",
+			selling:  testUSD,
+			buying:   nativeAsset,
+			amount:   1e7,
+			price:    xdr.Price{N: 10, D: 1},
+			wantCode: "op_cross_self",
+		}, {
+			name:     "passive offer at the same price does not cross",
+			selling:  testUSD,
+			buying:   nativeAsset,
+			amount:   1e7,
+			price:    xdr.Price{N: 10, D: 1},
+			passive:  true,
+			wantCode: "op_success",
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			l := makeTestLedger()
+			code := l.manageOffer(l.opID(0), "maker", nativeAsset, testUSD, 10*1e7, xdr.Price{N: 1, D: 10}, 0, false, time.Now())
+			if !assert.Equal(t, "op_success", code) {
+				return
+			}
+
+			code = l.manageOffer(l.opID(1), "maker", k.selling, k.buying, k.amount, k.price, k.offerID, k.passive, time.Now())
+			assert.Equal(t, k.wantCode, code)
+			assert.Equal(t, 0, len(l.trades))
+		})
+	}
+}
+
+func TestPayment(t *testing.T) {
+	l := makeTestLedger()
+	l.addAccount("issuer", 10*1e7)
+
+	assert.Equal(t, "op_success", l.payment("issuer", "taker", testUSD, 50*1e7))
+	assert.Equal(t, int64(150*1e7), l.accounts["taker"].balances[testUSD].balance)
+	assert.Equal(t, "op_success", l.payment("taker", "maker", nativeAsset, 10*1e7))
+	assert.Equal(t, int64(1010*1e7), l.accounts["maker"].balances[nativeAsset].balance)
+
+	assert.Equal(t, "op_no_destination", l.payment("taker", "unknown", nativeAsset, 1e7))
+	l.addAccount("untrusted", 10*1e7)
+	assert.Equal(t, "op_no_trust", l.payment("issuer", "untrusted", testUSD, 1e7))
+	assert.Equal(t, "op_underfunded", l.payment("taker", "maker", testUSD, 151*1e7))
+	assert.Equal(t, "op_line_full", l.payment("issuer", "maker", testUSD, 901*1e7))
+}
+
+func TestAssetLess(t *testing.T) {
+	testCases := []struct {
+		a    hProtocol.Asset
+		b    hProtocol.Asset
+		want bool
+	}{
+		{nativeAsset, testUSD, true},
+		{testUSD, nativeAsset, false},
+		{nativeAsset, nativeAsset, false},
+		{hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: "issuer"}, testUSD, true},
+		{hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: "a"}, testUSD, true},
+	}
+
+	for _, k := range testCases {
+		assert.Equal(t, k.want, assetLess(k.a, k.b), "%v < %v", k.a, k.b)
+	}
+}
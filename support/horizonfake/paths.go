@@ -0,0 +1,340 @@
+package horizonfake
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/amount"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// pathPaymentStrictSend applies a PathPaymentStrictSend operation and returns the result code
+//
+// The send amount is converted through the order book of each hop on the path at the price of the resting offers. Intermediate
+// assets never touch the balances of the source, only the makers on the path and the final send and receive amounts do.
+func (l *ledger) pathPaymentStrictSend(opID int64, source string, destination string, sendAsset hProtocol.Asset, sendAmount int64, destAsset hProtocol.Asset, destMin int64, path []hProtocol.Asset, now time.Time) string {
+	if sendAmount <= 0 || destMin <= 0 {
+		return "op_malformed"
+	}
+	if code := l.checkPathPayment(source, destination, sendAsset, destAsset); code != "op_success" {
+		return code
+	}
+	if sendAmount > l.availableToSell(source, sendAsset, 0, 0) {
+		return "op_underfunded"
+	}
+
+	hops := pathHops(sendAsset, path, destAsset)
+	destAmount := sendAmount
+	for i := 0; i+1 < len(hops); i++ {
+		var code string
+		destAmount, code = l.sellThroughBook(opID, source, hops[i], hops[i+1], destAmount, now)
+		if code != "op_success" {
+			return code
+		}
+	}
+	if destAmount < destMin {
+		return "op_under_dest_min"
+	}
+	return l.settlePathPayment(source, destination, sendAsset, sendAmount, destAsset, destAmount)
+}
+
+// pathPaymentStrictReceive applies a PathPaymentStrictReceive operation and returns the result code, the hops are converted from the
+// destination back to the source so each hop buys exactly what the next hop needs
+func (l *ledger) pathPaymentStrictReceive(opID int64, source string, destination string, sendAsset hProtocol.Asset, sendMax int64, destAsset hProtocol.Asset, destAmount int64, path []hProtocol.Asset, now time.Time) string {
+	if sendMax <= 0 || destAmount <= 0 {
+		return "op_malformed"
+	}
+	if code := l.checkPathPayment(source, destination, sendAsset, destAsset); code != "op_success" {
+		return code
+	}
+
+	hops := pathHops(sendAsset, path, destAsset)
+	sendAmount := destAmount
+	for i := len(hops) - 1; i > 0; i-- {
+		var code string
+		sendAmount, code = l.buyThroughBook(opID, source, hops[i-1], hops[i], sendAmount, now)
+		if code != "op_success" {
+			return code
+		}
+	}
+	if sendAmount > sendMax {
+		return "op_over_source_max"
+	}
+	if sendAmount > l.availableToSell(source, sendAsset, 0, 0) {
+		return "op_underfunded"
+	}
+	return l.settlePathPayment(source, destination, sendAsset, sendAmount, destAsset, destAmount)
+}
+
+func (l *ledger) checkPathPayment(source string, destination string, sendAsset hProtocol.Asset, destAsset hProtocol.Asset) string {
+	if _, ok := l.accounts[destination]; !ok {
+		return "op_no_destination"
+	}
+	if !l.hasTrustline(source, sendAsset) {
+		return "op_src_no_trust"
+	}
+	if !l.hasTrustline(destination, destAsset) {
+		return "op_no_trust"
+	}
+	return "op_success"
+}
+
+func (l *ledger) settlePathPayment(source string, destination string, sendAsset hProtocol.Asset, sendAmount int64, destAsset hProtocol.Asset, destAmount int64) string {
+	l.credit(source, sendAsset, -sendAmount)
+	if destAmount > l.availableToBuy(destination, destAsset, 0) {
+		return "op_line_full"
+	}
+	l.credit(destination, destAsset, destAmount)
+	l.lastPathPaymentDestAmount = destAmount
+	return "op_success"
+}
+
+// pathHops is the list of assets that the payment goes through, consecutive duplicates are dropped since they need no conversion
+func pathHops(sendAsset hProtocol.Asset, path []hProtocol.Asset, destAsset hProtocol.Asset) []hProtocol.Asset {
+	hops := []hProtocol.Asset{sendAsset}
+	for _, a := range append(append([]hProtocol.Asset{}, path...), destAsset) {
+		if a != hops[len(hops)-1] {
+			hops = append(hops, a)
+		}
+	}
+	return hops
+}
+
+// sellThroughBook sells exactly amount of the from asset for the to asset by taking the best offers selling the to asset, it returns
+// the amount of the to asset received. A remainder too small to buy a single stroop goes to the last maker.
+func (l *ledger) sellThroughBook(opID int64, source string, from hProtocol.Asset, to hProtocol.Asset, amount int64, now time.Time) (int64, string) {
+	received := int64(0)
+	remaining := amount
+	for remaining > 0 {
+		maker := l.bestOffer(to, from)
+		if maker == nil {
+			return 0, "op_too_few_offers"
+		}
+		if maker.seller == source {
+			return 0, "op_offer_cross_self"
+		}
+
+		bought := mulDiv(remaining, int64(maker.price.D), int64(maker.price.N), false)
+		if bought > maker.amount {
+			bought = maker.amount
+		}
+		sold := mulDiv(bought, int64(maker.price.N), int64(maker.price.D), true)
+		if bought == 0 || sold > remaining {
+			sold = remaining
+		}
+
+		l.credit(maker.seller, to, -bought)
+		l.credit(maker.seller, from, sold)
+		maker.amount -= bought
+		maker.lastModifiedLedger = l.sequence
+		if maker.amount == 0 {
+			l.removeOffer(maker)
+		}
+		remaining -= sold
+		received += bought
+
+		if bought > 0 {
+			l.recordTrade(opID, source, 0, from, sold, maker, bought, now)
+		}
+	}
+	return received, "op_success"
+}
+
+// buyThroughBook buys exactly amount of the to asset with the from asset by taking the best offers selling the to asset, it returns
+// the amount of the from asset paid
+func (l *ledger) buyThroughBook(opID int64, source string, from hProtocol.Asset, to hProtocol.Asset, amount int64, now time.Time) (int64, string) {
+	paid := int64(0)
+	remaining := amount
+	for remaining > 0 {
+		maker := l.bestOffer(to, from)
+		if maker == nil {
+			return 0, "op_too_few_offers"
+		}
+		if maker.seller == source {
+			return 0, "op_offer_cross_self"
+		}
+
+		bought := remaining
+		if bought > maker.amount {
+			bought = maker.amount
+		}
+		sold := mulDiv(bought, int64(maker.price.N), int64(maker.price.D), true)
+
+		l.credit(maker.seller, to, -bought)
+		l.credit(maker.seller, from, sold)
+		maker.amount -= bought
+		maker.lastModifiedLedger = l.sequence
+		if maker.amount == 0 {
+			l.removeOffer(maker)
+		}
+		remaining -= bought
+		paid += sold
+
+		l.recordTrade(opID, source, 0, from, sold, maker, bought, now)
+	}
+	return paid, "op_success"
+}
+
+// candidatePaths are the direct path and the paths through a single intermediate asset that has offers on the ledger
+func (l *ledger) candidatePaths(sendAsset hProtocol.Asset, destAsset hProtocol.Asset) [][]hProtocol.Asset {
+	seen := map[hProtocol.Asset]bool{sendAsset: true, destAsset: true}
+	intermediates := []hProtocol.Asset{}
+	for _, o := range l.offers {
+		for _, a := range []hProtocol.Asset{o.selling, o.buying} {
+			if !seen[a] {
+				seen[a] = true
+				intermediates = append(intermediates, a)
+			}
+		}
+	}
+	sort.Slice(intermediates, func(i, j int) bool {
+		return assetLess(intermediates[i], intermediates[j])
+	})
+
+	paths := [][]hProtocol.Asset{{}}
+	for _, a := range intermediates {
+		paths = append(paths, []hProtocol.Asset{a})
+	}
+	return paths
+}
+
+// getStrictSendPaths serves the paths from the source asset to each of the destination assets, best destination amount first. Paths are
+// quoted against the current book without a source account, so they can include offers of the account that will send the payment.
+func (s *Server) getStrictSendPaths(w http.ResponseWriter, r *http.Request) {
+	sendAsset, e := queryAsset(r, "source_")
+	if e != nil || sendAsset == nil {
+		writeProblem(w, badRequest("source_asset_type is required"))
+		return
+	}
+	sendAmount, e := amount.Parse(r.Form.Get("source_amount"))
+	if e != nil || sendAmount <= 0 {
+		writeProblem(w, badRequest(fmt.Sprintf("invalid source_amount '%s'", r.Form.Get("source_amount"))))
+		return
+	}
+	destAssets, e := parseAssetList(r.Form.Get("destination_assets"))
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+
+	records := []hProtocol.Path{}
+	for _, destAsset := range destAssets {
+		for _, path := range s.ledger.candidatePaths(*sendAsset, destAsset) {
+			next := s.ledger.clone()
+			hops := pathHops(*sendAsset, path, destAsset)
+			destAmount := int64(sendAmount)
+			code := "op_success"
+			for i := 0; i+1 < len(hops) && code == "op_success"; i++ {
+				destAmount, code = next.sellThroughBook(0, "", hops[i], hops[i+1], destAmount, s.nowFn())
+			}
+			if code == "op_success" && destAmount > 0 {
+				records = append(records, pathJSON(*sendAsset, int64(sendAmount), destAsset, destAmount, path))
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, _ := amount.ParseInt64(records[i].DestinationAmount)
+		b, _ := amount.ParseInt64(records[j].DestinationAmount)
+		return a > b
+	})
+	writePaths(w, records)
+}
+
+// getStrictReceivePaths serves the paths from each of the source assets to the destination asset, least source amount first
+func (s *Server) getStrictReceivePaths(w http.ResponseWriter, r *http.Request) {
+	destAsset, e := queryAsset(r, "destination_")
+	if e != nil || destAsset == nil {
+		writeProblem(w, badRequest("destination_asset_type is required"))
+		return
+	}
+	destAmount, e := amount.Parse(r.Form.Get("destination_amount"))
+	if e != nil || destAmount <= 0 {
+		writeProblem(w, badRequest(fmt.Sprintf("invalid destination_amount '%s'", r.Form.Get("destination_amount"))))
+		return
+	}
+	sendAssets, e := parseAssetList(r.Form.Get("source_assets"))
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+
+	records := []hProtocol.Path{}
+	for _, sendAsset := range sendAssets {
+		for _, path := range s.ledger.candidatePaths(sendAsset, *destAsset) {
+			next := s.ledger.clone()
+			hops := pathHops(sendAsset, path, *destAsset)
+			sendAmount := int64(destAmount)
+			code := "op_success"
+			for i := len(hops) - 1; i > 0 && code == "op_success"; i-- {
+				sendAmount, code = next.buyThroughBook(0, "", hops[i-1], hops[i], sendAmount, s.nowFn())
+			}
+			if code == "op_success" {
+				records = append(records, pathJSON(sendAsset, sendAmount, *destAsset, int64(destAmount), path))
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, _ := amount.ParseInt64(records[i].SourceAmount)
+		b, _ := amount.ParseInt64(records[j].SourceAmount)
+		return a < b
+	})
+	writePaths(w, records)
+}
+
+func pathJSON(sendAsset hProtocol.Asset, sendAmount int64, destAsset hProtocol.Asset, destAmount int64, path []hProtocol.Asset) hProtocol.Path {
+	return hProtocol.Path{
+		SourceAssetType:        sendAsset.Type,
+		SourceAssetCode:        sendAsset.Code,
+		SourceAssetIssuer:      sendAsset.Issuer,
+		SourceAmount:           amount.StringFromInt64(sendAmount),
+		DestinationAssetType:   destAsset.Type,
+		DestinationAssetCode:   destAsset.Code,
+		DestinationAssetIssuer: destAsset.Issuer,
+		DestinationAmount:      amount.StringFromInt64(destAmount),
+		Path:                   append([]hProtocol.Asset{}, path...),
+	}
+}
+
+func writePaths(w http.ResponseWriter, records []hProtocol.Path) {
+	page := hProtocol.PathsPage{}
+	page.Embedded.Records = records
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseAssetList parses a comma separated list of assets in the format used by the path finding endpoints ("native" or "CODE:ISSUER")
+func parseAssetList(list string) ([]hProtocol.Asset, error) {
+	if list == "" {
+		return nil, fmt.Errorf("a list of assets is required, the fake horizon server does not look up assets by account")
+	}
+
+	assets := []hProtocol.Asset{}
+	for _, s := range strings.Split(list, ",") {
+		if s == nativeAsset.Type {
+			assets = append(assets, nativeAsset)
+			continue
+		}
+
+		parts := strings.Split(s, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid asset '%s' in list, needs to be 'native' or 'CODE:ISSUER'", s)
+		}
+		assetType := "credit_alphanum4"
+		if len(parts[0]) > 4 {
+			assetType = "credit_alphanum12"
+		}
+		assets = append(assets, hProtocol.Asset{Type: assetType, Code: parts[0], Issuer: parts[1]})
+	}
+	return assets, nil
+}
+
+func toHorizonAssets(path []xdr.Asset) []hProtocol.Asset {
+	assets := []hProtocol.Asset{}
+	for _, a := range path {
+		assets = append(assets, toHorizonAsset(a))
+	}
+	return assets
+}
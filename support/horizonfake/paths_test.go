@@ -0,0 +1,157 @@
+package horizonfake
+
+import (
+	"testing"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPayment(t *testing.T) {
+	testCases := []struct {
+		name           string
+		strictSend     bool
+		takerOffer     bool // the taker has an ask on the book below the maker's
+		amount         int64
+		bound          int64 // destMin for a strict send, sendMax for a strict receive
+		wantCode       string
+		wantTakerXLM   int64
+		wantTakerUSD   int64
+		wantMakerUSD   int64
+		wantNumOffers  int
+		wantNumEffects int
+	}{
+		{
+			name:       "strict send",
+			strictSend: true,
+			amount:     50 * 1e7,
+			bound:      5 * 1e7,
+			wantCode:   "op_success",
+			// 50 XLM buys 5 USD at 10 XLM per USD
+			wantTakerXLM:   950 * 1e7,
+			wantTakerUSD:   105 * 1e7,
+			wantMakerUSD:   95 * 1e7,
+			wantNumOffers:  1,
+			wantNumEffects: 2,
+		}, {
+			name:       "strict send below dest min",
+			strictSend: true,
+			amount:     50 * 1e7,
+			bound:      5*1e7 + 1,
+			wantCode:   "op_under_dest_min",
+		}, {
+			name:       "strict send through too few offers",
+			strictSend: true,
+			amount:     101 * 1e7,
+			bound:      1,
+			wantCode:   "op_too_few_offers",
+		}, {
+			name:       "strict send crossing our own offer",
+			strictSend: true,
+			takerOffer: true,
+			amount:     50 * 1e7,
+			bound:      1,
+			wantCode:   "op_offer_cross_self",
+		}, {
+			name:           "strict receive",
+			amount:         2 * 1e7,
+			bound:          20 * 1e7,
+			wantCode:       "op_success",
+			wantTakerXLM:   980 * 1e7,
+			wantTakerUSD:   102 * 1e7,
+			wantMakerUSD:   98 * 1e7,
+			wantNumOffers:  1,
+			wantNumEffects: 2,
+		}, {
+			name:     "strict receive over source max",
+			amount:   2 * 1e7,
+			bound:    20*1e7 - 1,
+			wantCode: "op_over_source_max",
+		}, {
+			name:       "strict receive crossing our own offer",
+			takerOffer: true,
+			amount:     2 * 1e7,
+			bound:      100 * 1e7,
+			wantCode:   "op_offer_cross_self",
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			l := makeTestLedger()
+			now := time.Now()
+			// the maker sells 10 USD at 10 XLM per USD
+			code := l.manageOffer(l.opID(0), "maker", testUSD, nativeAsset, 10*1e7, xdr.Price{N: 10, D: 1}, 0, false, now)
+			if !assert.Equal(t, "op_success", code) {
+				return
+			}
+			if k.takerOffer {
+				code = l.manageOffer(l.opID(1), "taker", testUSD, nativeAsset, 1*1e7, xdr.Price{N: 9, D: 1}, 0, false, now)
+				if !assert.Equal(t, "op_success", code) {
+					return
+				}
+			}
+
+			next := l.clone()
+			next.sequence++
+			opID := next.opID(0)
+			if k.strictSend {
+				code = next.pathPaymentStrictSend(opID, "taker", "taker", nativeAsset, k.amount, testUSD, k.bound, []hProtocol.Asset{}, now)
+			} else {
+				code = next.pathPaymentStrictReceive(opID, "taker", "taker", nativeAsset, k.bound, testUSD, k.amount, []hProtocol.Asset{}, now)
+			}
+			if !assert.Equal(t, k.wantCode, code) || code != "op_success" {
+				return
+			}
+
+			assert.Equal(t, k.wantTakerXLM, next.accounts["taker"].balances[nativeAsset].balance)
+			assert.Equal(t, k.wantTakerUSD, next.accounts["taker"].balances[testUSD].balance)
+			assert.Equal(t, k.wantMakerUSD, next.accounts["maker"].balances[testUSD].balance)
+			assert.Equal(t, k.wantNumOffers, len(next.offers))
+			assert.Equal(t, k.wantNumEffects, len(next.effects[opID]))
+		})
+	}
+}
+
+func TestPathPayment_ThroughIntermediateAsset(t *testing.T) {
+	l := makeTestLedger()
+	now := time.Now()
+	eur := hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: "issuer"}
+	_ = l.addTrustline("maker", eur, 1000*1e7)
+	l.accounts["maker"].balances[eur].balance = 100 * 1e7
+	// the maker sells EUR at 5 XLM per EUR and USD at 2 EUR per USD
+	assert.Equal(t, "op_success", l.manageOffer(l.opID(0), "maker", eur, nativeAsset, 10*1e7, xdr.Price{N: 5, D: 1}, 0, false, now))
+	assert.Equal(t, "op_success", l.manageOffer(l.opID(1), "maker", testUSD, eur, 10*1e7, xdr.Price{N: 2, D: 1}, 0, false, now))
+
+	assert.Equal(t, [][]hProtocol.Asset{{}, {eur}}, l.candidatePaths(nativeAsset, testUSD))
+
+	code := l.pathPaymentStrictSend(l.opID(2), "taker", "taker", nativeAsset, 50*1e7, testUSD, 5*1e7, []hProtocol.Asset{eur}, now)
+	if !assert.Equal(t, "op_success", code) {
+		return
+	}
+	assert.Equal(t, int64(105*1e7), l.accounts["taker"].balances[testUSD].balance)
+	// the taker has no trustline for the intermediate asset
+	_, ok := l.accounts["taker"].balances[eur]
+	assert.False(t, ok)
+	// the maker sold 10 EUR for XLM and bought them back for USD
+	assert.Equal(t, int64(100*1e7), l.accounts["maker"].balances[eur].balance)
+	assert.Equal(t, int64(95*1e7), l.accounts["maker"].balances[testUSD].balance)
+}
+
+func TestParseAssetList(t *testing.T) {
+	assets, e := parseAssetList("native,USD:issuer,LONGASSET:issuer")
+	if assert.NoError(t, e) {
+		assert.Equal(t, []hProtocol.Asset{
+			nativeAsset,
+			testUSD,
+			{Type: "credit_alphanum12", Code: "LONGASSET", Issuer: "issuer"},
+		}, assets)
+	}
+
+	for _, list := range []string{"", "USD", "USD:", ":issuer"} {
+		_, e := parseAssetList(list)
+		assert.Error(t, e, list)
+	}
+}
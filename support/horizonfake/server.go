@@ -0,0 +1,1032 @@
+// Package horizonfake provides an in-process fake of the subset of the Horizon API (https://www.stellar.org/developers/horizon/reference/)
+// used by the SDEX integration so that tests of SDEX, the SDEX price feed, the fee functions and the trader can run without a network connection.
+package horizonfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/price"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/support/render/hal"
+	"github.com/stellar/go/support/render/problem"
+	"github.com/stellar/go/xdr"
+)
+
+const defaultPageLimit = 10
+const maxPageLimit = 200
+
+// failure is a scripted error returned for the next request to a route
+type failure struct {
+	status int
+	title  string
+}
+
+// Server is a fake Horizon server backed by an in-memory ledger.
+//
+// Transactions are validated (source account, signatures, sequence number and fee) and their ManageSellOffer, CreatePassiveSellOffer,
+// Payment, PathPaymentStrictSend and PathPaymentStrictReceive operations are applied atomically, offers are matched against the book at
+// the price of the resting offers. Every transaction closes its own ledger. Other operation types fail with op_not_supported. Path
+// finding considers the direct path and paths through a single intermediate asset. Successful transactions keep their memo and
+// result and can be loaded with their operations, failed transactions are not recorded.
+type Server struct {
+	// URL is the base URL of the server, use it as the HorizonURL of a horizonclient.Client
+	URL string
+
+	httpServer        *httptest.Server
+	networkPassphrase string
+	lock              *sync.Mutex
+	ledger            *ledger
+	feeStats          hProtocol.FeeStats
+	transactions      []hProtocol.Transaction   // successful transactions, oldest first
+	operations        map[int64]operations.Base // operation ID -> operation of a successful transaction
+	failures          map[string][]failure      // route -> failures
+	calls             map[string]int            // route -> number of requests
+	nowFn             func() time.Time
+}
+
+var _ http.Handler = &Server{}
+
+// MakeServer starts a fake Horizon server on a local port for the network, callers should call Close when done
+func MakeServer(networkPassphrase string) *Server {
+	s := &Server{
+		networkPassphrase: networkPassphrase,
+		lock:              &sync.Mutex{},
+		ledger:            makeLedger(),
+		feeStats: hProtocol.FeeStats{
+			LastLedgerBaseFee:   100,
+			LedgerCapacityUsage: 0.1,
+		},
+		transactions: []hProtocol.Transaction{},
+		operations:   map[int64]operations.Base{},
+		failures:     map[string][]failure{},
+		calls:        map[string]int{},
+		nowFn:        time.Now,
+	}
+	s.httpServer = httptest.NewServer(s)
+	s.URL = s.httpServer.URL
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// Client returns a horizon client that talks to this server
+func (s *Server) Client() *horizonclient.Client {
+	return &horizonclient.Client{
+		HorizonURL: s.URL,
+		HTTP:       http.DefaultClient,
+	}
+}
+
+// SetNowFn sets the clock used as the close time of ledgers
+func (s *Server) SetNowFn(nowFn func() time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nowFn = nowFn
+}
+
+// SetFeeStats sets the response of the fee_stats endpoint, LastLedgerBaseFee is also the fee charged per operation
+func (s *Server) SetFeeStats(feeStats hProtocol.FeeStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.feeStats = feeStats
+}
+
+// AddAccount creates a funded account with the native balance (amount string, e.g. "100.5")
+func (s *Server) AddAccount(accountID string, nativeBalance string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ledger.addAccount(accountID, int64(amount.MustParse(nativeBalance)))
+}
+
+// AddTrustline adds a trustline with the limit (amount string) for the credit asset to the account, the limit is updated if it already exists
+func (s *Server) AddTrustline(accountID string, asset hProtocol.Asset, limit string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e := s.ledger.addTrustline(accountID, asset, int64(amount.MustParse(limit)))
+	if e != nil {
+		panic(fmt.Errorf("could not add trustline on the fake horizon server: %s", e))
+	}
+}
+
+// SetBalance sets the balance (amount string) of the account for the asset, the account needs a trustline for credit assets
+func (s *Server) SetBalance(accountID string, asset hProtocol.Asset, balance string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	a := s.mustGetAccount(accountID)
+	tl, ok := a.balances[asset]
+	if !ok {
+		panic(fmt.Errorf("account %s does not have a trustline for %s:%s on the fake horizon server", accountID, asset.Code, asset.Issuer))
+	}
+	tl.balance = int64(amount.MustParse(balance))
+}
+
+// PlaceOffer submits a ManageSellOffer from the account without a transaction (no signatures, sequence number or fee), this provides
+// liquidity to (or takes liquidity from) the offers of the account under test. Amount and price (of buying per unit of selling) are decimal strings.
+// The operation is recorded in a transaction of the account without a memo. Returns the ID of the resting offer, or 0 if it was filled completely.
+func (s *Server) PlaceOffer(accountID string, selling hProtocol.Asset, buying hProtocol.Asset, amountString string, priceString string) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustGetAccount(accountID)
+
+	amt, e := amount.Parse(amountString)
+	if e != nil {
+		return 0, fmt.Errorf("invalid amount '%s': %s", amountString, e)
+	}
+	p, e := price.Parse(priceString)
+	if e != nil {
+		return 0, fmt.Errorf("invalid price '%s': %s", priceString, e)
+	}
+
+	next := s.ledger.clone()
+	next.sequence++
+	now := s.nowFn()
+	code := next.manageOffer(next.opID(0), accountID, selling, buying, int64(amt), p, 0, false, now)
+	if code != "op_success" {
+		return 0, fmt.Errorf("could not place offer: %s", code)
+	}
+	e = s.recordPlacedOffer(next, accountID, selling, buying, now)
+	if e != nil {
+		return 0, fmt.Errorf("could not record the transaction of the offer: %s", e)
+	}
+	s.ledger = next
+	// a new offer always gets the last offer ID, it is only on the book if it was not filled completely
+	if _, ok := next.offers[next.lastOfferID]; ok {
+		return next.lastOfferID, nil
+	}
+	return 0, nil
+}
+
+// FailNext makes the next request to the route fail with the status and the title of the problem, without changing any state.
+// The routes are accounts, account_offers, account_trades, account_transactions, trades, order_book, operation, operation_effects,
+// assets, fee_stats, strict_send_paths, strict_receive_paths, transaction and transactions.
+func (s *Server) FailNext(route string, status int, title string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures[route] = append(s.failures[route], failure{status: status, title: title})
+}
+
+// CallCount returns the number of requests made to the route, including failed requests
+func (s *Server) CallCount(route string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls[route]
+}
+
+func (s *Server) mustGetAccount(accountID string) *account {
+	a, ok := s.ledger.accounts[accountID]
+	if !ok {
+		panic(fmt.Errorf("account '%s' has not been added to the fake horizon server", accountID))
+	}
+	return a
+}
+
+// ServeHTTP impl, routes the horizon paths used by the SDEX integration
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := r.ParseForm()
+	if e != nil {
+		writeProblem(w, badRequest(fmt.Sprintf("could not parse the request: %s", e)))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := ""
+	switch {
+	case len(parts) == 2 && parts[0] == "accounts" && r.Method == "GET":
+		route = "accounts"
+	case len(parts) == 3 && parts[0] == "accounts" && parts[2] == "offers" && r.Method == "GET":
+		route = "account_offers"
+	case len(parts) == 3 && parts[0] == "accounts" && parts[2] == "trades" && r.Method == "GET":
+		route = "account_trades"
+	case len(parts) == 3 && parts[0] == "accounts" && parts[2] == "transactions" && r.Method == "GET":
+		route = "account_transactions"
+	case len(parts) == 1 && parts[0] == "trades" && r.Method == "GET":
+		route = "trades"
+	case len(parts) == 1 && parts[0] == "order_book" && r.Method == "GET":
+		route = "order_book"
+	case len(parts) == 2 && parts[0] == "operations" && r.Method == "GET":
+		route = "operation"
+	case len(parts) == 3 && parts[0] == "operations" && parts[2] == "effects" && r.Method == "GET":
+		route = "operation_effects"
+	case len(parts) == 1 && parts[0] == "assets" && r.Method == "GET":
+		route = "assets"
+	case len(parts) == 1 && parts[0] == "fee_stats" && r.Method == "GET":
+		route = "fee_stats"
+	case len(parts) == 2 && parts[0] == "paths" && parts[1] == "strict-send" && r.Method == "GET":
+		route = "strict_send_paths"
+	case (len(parts) == 1 || (len(parts) == 2 && parts[1] == "strict-receive")) && parts[0] == "paths" && r.Method == "GET":
+		// the horizon client requests strict receive paths from the legacy /paths endpoint
+		route = "strict_receive_paths"
+	case len(parts) == 1 && parts[0] == "transactions" && r.Method == "POST":
+		route = "transactions"
+	case len(parts) == 2 && parts[0] == "transactions" && r.Method == "GET":
+		route = "transaction"
+	default:
+		writeProblem(w, notFound())
+		return
+	}
+
+	s.calls[route]++
+	if failures := s.failures[route]; len(failures) > 0 {
+		s.failures[route] = failures[1:]
+		writeProblem(w, problem.P{
+			Type:   "https://stellar.org/horizon-errors/scripted_failure",
+			Title:  failures[0].title,
+			Status: failures[0].status,
+		})
+		return
+	}
+
+	switch route {
+	case "accounts":
+		s.getAccount(w, parts[1])
+	case "account_offers":
+		s.getAccountOffers(w, r, parts[1])
+	case "account_trades":
+		s.getTrades(w, r, parts[1])
+	case "account_transactions":
+		s.getAccountTransactions(w, r, parts[1])
+	case "trades":
+		s.getTrades(w, r, "")
+	case "order_book":
+		s.getOrderBook(w, r)
+	case "operation":
+		s.getOperation(w, parts[1])
+	case "operation_effects":
+		s.getEffects(w, r, parts[1])
+	case "assets":
+		s.getAssets(w, r)
+	case "fee_stats":
+		writeJSON(w, http.StatusOK, s.feeStats)
+	case "strict_send_paths":
+		s.getStrictSendPaths(w, r)
+	case "strict_receive_paths":
+		s.getStrictReceivePaths(w, r)
+	case "transactions":
+		s.submitTransaction(w, r.Form.Get("tx"))
+	case "transaction":
+		s.getTransaction(w, parts[1])
+	}
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, accountID string) {
+	a, ok := s.ledger.accounts[accountID]
+	if !ok {
+		writeProblem(w, notFound())
+		return
+	}
+
+	result := hProtocol.Account{
+		ID:                 a.id,
+		AccountID:          a.id,
+		Sequence:           strconv.FormatInt(a.seq, 10),
+		SubentryCount:      a.subentries,
+		LastModifiedLedger: a.lastModifiedLedger,
+		Balances:           []hProtocol.Balance{},
+		Signers: []hProtocol.Signer{
+			{Weight: 1, Key: a.id, Type: "ed25519_public_key"},
+		},
+		Data: map[string]string{},
+		PT:   a.id,
+	}
+	result.Links.Self = s.link("/accounts/%s", a.id)
+	result.Links.Offers = s.link("/accounts/%s/offers", a.id)
+	result.Links.Trades = s.link("/accounts/%s/trades", a.id)
+	// credit assets come before the native asset, like on horizon
+	for i := len(a.assets) - 1; i >= 0; i-- {
+		asset := a.assets[i]
+		tl := a.balances[asset]
+		selling, buying := s.ledger.liabilities(a.id, asset, 0)
+		b := hProtocol.Balance{
+			Balance:            amount.StringFromInt64(tl.balance),
+			BuyingLiabilities:  amount.StringFromInt64(buying),
+			SellingLiabilities: amount.StringFromInt64(selling),
+			LastModifiedLedger: a.lastModifiedLedger,
+		}
+		b.Asset.Type, b.Asset.Code, b.Asset.Issuer = asset.Type, asset.Code, asset.Issuer
+		if asset != nativeAsset {
+			b.Limit = amount.StringFromInt64(tl.limit)
+		}
+		result.Balances = append(result.Balances, b)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getAccountOffers(w http.ResponseWriter, r *http.Request, accountID string) {
+	if _, ok := s.ledger.accounts[accountID]; !ok {
+		writeProblem(w, notFound())
+		return
+	}
+	cursor, limit, desc, e := pageParams(r)
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	var cursorID int64
+	if cursor != "" {
+		cursorID, e = strconv.ParseInt(cursor, 10, 64)
+		if e != nil {
+			writeProblem(w, badRequest(fmt.Sprintf("invalid cursor '%s'", cursor)))
+			return
+		}
+	}
+
+	offers := []*offer{}
+	for _, o := range s.ledger.offers {
+		if o.seller == accountID {
+			offers = append(offers, o)
+		}
+	}
+	sort.Slice(offers, func(i, j int) bool {
+		if desc {
+			return offers[i].id > offers[j].id
+		}
+		return offers[i].id < offers[j].id
+	})
+
+	page := hProtocol.OffersPage{}
+	page.Embedded.Records = []hProtocol.Offer{}
+	for _, o := range offers {
+		if len(page.Embedded.Records) >= limit {
+			break
+		}
+		if cursor != "" && ((!desc && o.id <= cursorID) || (desc && o.id >= cursorID)) {
+			continue
+		}
+		page.Embedded.Records = append(page.Embedded.Records, s.offerJSON(o))
+	}
+	nextCursor := cursor
+	if n := len(page.Embedded.Records); n > 0 {
+		nextCursor = page.Embedded.Records[n-1].PT
+	}
+	page.Links = s.pageLinks(r, nextCursor)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) offerJSON(o *offer) hProtocol.Offer {
+	result := hProtocol.Offer{
+		ID:                 o.id,
+		PT:                 strconv.FormatInt(o.id, 10),
+		Seller:             o.seller,
+		Selling:            o.selling,
+		Buying:             o.buying,
+		Amount:             amount.StringFromInt64(o.amount),
+		PriceR:             hProtocol.Price{N: int32(o.price.N), D: int32(o.price.D)},
+		Price:              priceString(o.price),
+		LastModifiedLedger: int32(o.lastModifiedLedger),
+	}
+	result.Links.Self = s.link("/offers/%d", o.id)
+	result.Links.OfferMaker = s.link("/accounts/%s", o.seller)
+	return result
+}
+
+// getTrades serves the trades of the account, or the trades of the asset pair in the query (in any orientation) when accountID is empty
+func (s *Server) getTrades(w http.ResponseWriter, r *http.Request, accountID string) {
+	if accountID != "" {
+		if _, ok := s.ledger.accounts[accountID]; !ok {
+			writeProblem(w, notFound())
+			return
+		}
+	}
+	cursor, limit, desc, e := pageParams(r)
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	var cursorOpID, cursorIndex int64
+	if cursor != "" {
+		cursorOpID, cursorIndex, e = parseTradeCursor(cursor)
+		if e != nil {
+			writeProblem(w, badRequest(e.Error()))
+			return
+		}
+	}
+
+	var base, counter *hProtocol.Asset
+	if accountID == "" {
+		base, e = queryAsset(r, "base_")
+		if e != nil {
+			writeProblem(w, badRequest(e.Error()))
+			return
+		}
+		counter, e = queryAsset(r, "counter_")
+		if e != nil {
+			writeProblem(w, badRequest(e.Error()))
+			return
+		}
+	}
+
+	trades := append([]*trade{}, s.ledger.trades...)
+	if desc {
+		for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+			trades[i], trades[j] = trades[j], trades[i]
+		}
+	}
+
+	page := hProtocol.TradesPage{}
+	page.Embedded.Records = []hProtocol.Trade{}
+	for _, t := range trades {
+		if len(page.Embedded.Records) >= limit {
+			break
+		}
+		if accountID != "" && t.baseAccount != accountID && t.counterAccount != accountID {
+			continue
+		}
+		if base != nil && *base != t.base && *base != t.counter {
+			continue
+		}
+		if counter != nil && *counter != t.base && *counter != t.counter {
+			continue
+		}
+		if cursor != "" {
+			isAfter := t.opID > cursorOpID || (t.opID == cursorOpID && int64(t.index) > cursorIndex)
+			isBefore := t.opID < cursorOpID || (t.opID == cursorOpID && int64(t.index) < cursorIndex)
+			if (!desc && !isAfter) || (desc && !isBefore) {
+				continue
+			}
+		}
+		page.Embedded.Records = append(page.Embedded.Records, s.tradeJSON(t))
+	}
+	nextCursor := cursor
+	if n := len(page.Embedded.Records); n > 0 {
+		nextCursor = page.Embedded.Records[n-1].PT
+	}
+	page.Links = s.pageLinks(r, nextCursor)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseTradeCursor(cursor string) (int64, int64, error) {
+	parts := strings.Split(cursor, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	opID, e := strconv.ParseInt(parts[0], 10, 64)
+	if e != nil {
+		return 0, 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	index, e := strconv.ParseInt(parts[1], 10, 64)
+	if e != nil {
+		return 0, 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	return opID, index, nil
+}
+
+func (s *Server) tradeJSON(t *trade) hProtocol.Trade {
+	result := hProtocol.Trade{
+		ID:                 t.pagingToken(),
+		PT:                 t.pagingToken(),
+		LedgerCloseTime:    t.closeTime,
+		BaseOfferID:        strconv.FormatInt(t.baseOfferID, 10),
+		BaseAccount:        t.baseAccount,
+		BaseAmount:         amount.StringFromInt64(t.baseAmount),
+		BaseAssetType:      t.base.Type,
+		BaseAssetCode:      t.base.Code,
+		BaseAssetIssuer:    t.base.Issuer,
+		CounterOfferID:     strconv.FormatInt(t.counterOfferID, 10),
+		CounterAccount:     t.counterAccount,
+		CounterAmount:      amount.StringFromInt64(t.counterAmount),
+		CounterAssetType:   t.counter.Type,
+		CounterAssetCode:   t.counter.Code,
+		CounterAssetIssuer: t.counter.Issuer,
+		BaseIsSeller:       t.baseIsSeller,
+		Price:              &hProtocol.Price{N: int32(t.price.N), D: int32(t.price.D)},
+	}
+	result.OfferID = result.BaseOfferID
+	if !t.baseIsSeller {
+		result.OfferID = result.CounterOfferID
+	}
+	result.Links.Base = s.link("/accounts/%s", t.baseAccount)
+	result.Links.Counter = s.link("/accounts/%s", t.counterAccount)
+	result.Links.Operation = s.link("/operations/%d", t.opID)
+	return result
+}
+
+// getOrderBook serves the asks (offers selling the selling asset) and the bids (offers buying the selling asset) with prices in units of the buying asset
+func (s *Server) getOrderBook(w http.ResponseWriter, r *http.Request) {
+	selling, e := queryAsset(r, "selling_")
+	if e == nil && selling == nil {
+		e = fmt.Errorf("missing selling_asset_type")
+	}
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	buying, e := queryAsset(r, "buying_")
+	if e == nil && buying == nil {
+		e = fmt.Errorf("missing buying_asset_type")
+	}
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	limit := defaultPageLimit
+	if l := r.Form.Get("limit"); l != "" {
+		limit, e = strconv.Atoi(l)
+		if e != nil || limit <= 0 {
+			writeProblem(w, badRequest(fmt.Sprintf("invalid limit '%s'", l)))
+			return
+		}
+	}
+
+	result := hProtocol.OrderBookSummary{
+		Selling: *selling,
+		Buying:  *buying,
+		Asks:    levels(s.ledger.book(*selling, *buying), limit, false),
+		// bids sell the buying asset, invert their prices so they are in units of the buying asset too
+		Bids: levels(s.ledger.book(*buying, *selling), limit, true),
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// levels aggregates the offers by price, the amount of a level is in units of the asset sold by the offers
+func levels(offers []*offer, limit int, invert bool) []hProtocol.PriceLevel {
+	result := []hProtocol.PriceLevel{}
+	var last xdr.Price
+	var total int64
+	for _, o := range offers {
+		if len(result) > 0 && comparePrices(last, o.price) == 0 {
+			total += o.amount
+			result[len(result)-1].Amount = amount.StringFromInt64(total)
+			continue
+		}
+		if len(result) >= limit {
+			break
+		}
+
+		last = o.price
+		total = o.amount
+		p := o.price
+		if invert {
+			p = xdr.Price{N: o.price.D, D: o.price.N}
+		}
+		result = append(result, hProtocol.PriceLevel{
+			PriceR: hProtocol.Price{N: int32(p.N), D: int32(p.D)},
+			Price:  priceString(p),
+			Amount: amount.StringFromInt64(total),
+		})
+	}
+	return result
+}
+
+// effectJSON is the representation of a trade effect
+type effectJSON struct {
+	Links struct {
+		Operation hal.Link `json:"operation"`
+	} `json:"_links"`
+	ID                string    `json:"id"`
+	PT                string    `json:"paging_token"`
+	Account           string    `json:"account"`
+	Type              string    `json:"type"`
+	TypeI             int32     `json:"type_i"`
+	CreatedAt         time.Time `json:"created_at"`
+	Seller            string    `json:"seller"`
+	OfferID           string    `json:"offer_id"`
+	SoldAmount        string    `json:"sold_amount"`
+	SoldAssetType     string    `json:"sold_asset_type"`
+	SoldAssetCode     string    `json:"sold_asset_code,omitempty"`
+	SoldAssetIssuer   string    `json:"sold_asset_issuer,omitempty"`
+	BoughtAmount      string    `json:"bought_amount"`
+	BoughtAssetType   string    `json:"bought_asset_type"`
+	BoughtAssetCode   string    `json:"bought_asset_code,omitempty"`
+	BoughtAssetIssuer string    `json:"bought_asset_issuer,omitempty"`
+}
+
+type effectsPage struct {
+	Links    hal.Links `json:"_links"`
+	Embedded struct {
+		Records []effectJSON `json:"records"`
+	} `json:"_embedded"`
+}
+
+// getEffects serves the effects of the operation, only trade effects are recorded
+func (s *Server) getEffects(w http.ResponseWriter, r *http.Request, opIDString string) {
+	opID, e := strconv.ParseInt(opIDString, 10, 64)
+	if e != nil {
+		writeProblem(w, notFound())
+		return
+	}
+	cursor, limit, desc, e := pageParams(r)
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	var cursorIndex int64
+	if cursor != "" {
+		_, cursorIndex, e = parseTradeCursor(cursor)
+		if e != nil {
+			writeProblem(w, badRequest(e.Error()))
+			return
+		}
+	}
+
+	effects := append([]*effect{}, s.ledger.effects[opID]...)
+	if desc {
+		for i, j := 0, len(effects)-1; i < j; i, j = i+1, j-1 {
+			effects[i], effects[j] = effects[j], effects[i]
+		}
+	}
+
+	page := effectsPage{}
+	page.Embedded.Records = []effectJSON{}
+	for _, eff := range effects {
+		if len(page.Embedded.Records) >= limit {
+			break
+		}
+		if cursor != "" && ((!desc && int64(eff.index) <= cursorIndex) || (desc && int64(eff.index) >= cursorIndex)) {
+			continue
+		}
+
+		record := effectJSON{
+			ID:                eff.pagingToken(),
+			PT:                eff.pagingToken(),
+			Account:           eff.account,
+			Type:              "trade",
+			TypeI:             33,
+			CreatedAt:         eff.closeTime,
+			Seller:            eff.seller,
+			OfferID:           strconv.FormatInt(eff.offerID, 10),
+			SoldAmount:        amount.StringFromInt64(eff.soldAmount),
+			SoldAssetType:     eff.sold.Type,
+			SoldAssetCode:     eff.sold.Code,
+			SoldAssetIssuer:   eff.sold.Issuer,
+			BoughtAmount:      amount.StringFromInt64(eff.boughtAmount),
+			BoughtAssetType:   eff.bought.Type,
+			BoughtAssetCode:   eff.bought.Code,
+			BoughtAssetIssuer: eff.bought.Issuer,
+		}
+		record.Links.Operation = s.link("/operations/%d", eff.opID)
+		page.Embedded.Records = append(page.Embedded.Records, record)
+	}
+	nextCursor := cursor
+	if n := len(page.Embedded.Records); n > 0 {
+		nextCursor = page.Embedded.Records[n-1].PT
+	}
+	page.Links = s.pageLinks(r, nextCursor)
+	writeJSON(w, http.StatusOK, page)
+}
+
+// getAssets serves the credit assets that have at least one trustline
+func (s *Server) getAssets(w http.ResponseWriter, r *http.Request) {
+	code := r.Form.Get("asset_code")
+	issuer := r.Form.Get("asset_issuer")
+
+	stats := map[hProtocol.Asset]*hProtocol.AssetStat{}
+	for _, a := range s.ledger.accounts {
+		for _, asset := range a.assets {
+			if asset == nativeAsset || (code != "" && asset.Code != code) || (issuer != "" && asset.Issuer != issuer) {
+				continue
+			}
+			stat, ok := stats[asset]
+			if !ok {
+				stat = &hProtocol.AssetStat{PT: fmt.Sprintf("%s_%s_%s", asset.Code, asset.Issuer, asset.Type)}
+				stat.Asset.Type, stat.Asset.Code, stat.Asset.Issuer = asset.Type, asset.Code, asset.Issuer
+				stats[asset] = stat
+			}
+			stat.NumAccounts++
+			total, e := amount.ParseInt64(stat.Amount)
+			if e != nil {
+				total = 0
+			}
+			stat.Amount = amount.StringFromInt64(total + a.balances[asset].balance)
+		}
+	}
+
+	records := []hProtocol.AssetStat{}
+	for _, stat := range stats {
+		records = append(records, *stat)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].PT < records[j].PT
+	})
+
+	page := hProtocol.AssetsPage{}
+	page.Embedded.Records = records
+	page.Links = s.pageLinks(r, "")
+	writeJSON(w, http.StatusOK, page)
+}
+
+// submitTransaction validates the transaction and applies its operations to a copy of the ledger, which replaces the ledger only if all operations succeed
+func (s *Server) submitTransaction(w http.ResponseWriter, txeB64 string) {
+	var txe xdr.TransactionEnvelope
+	e := xdr.SafeUnmarshalBase64(txeB64, &txe)
+	if e != nil || txe.IsFeeBump() {
+		writeProblem(w, problem.P{
+			Type:   "https://stellar.org/horizon-errors/transaction_malformed",
+			Title:  "Transaction Malformed",
+			Status: http.StatusBadRequest,
+			Detail: "the fake horizon server only accepts non fee-bump transaction envelopes",
+			Extras: map[string]interface{}{"envelope_xdr": txeB64},
+		})
+		return
+	}
+
+	sourceMuxed := txe.SourceAccount()
+	source := sourceMuxed.Address()
+	sourceAccount, ok := s.ledger.accounts[source]
+	if !ok {
+		writeProblem(w, txFailed(txeB64, "tx_no_source_account", nil))
+		return
+	}
+
+	ops := txe.Operations()
+	if len(ops) == 0 {
+		writeProblem(w, txFailed(txeB64, "tx_missing_operation", nil))
+		return
+	}
+
+	hash, e := network.HashTransactionInEnvelope(txe, s.networkPassphrase)
+	if e != nil {
+		writeProblem(w, badRequest(fmt.Sprintf("could not hash transaction: %s", e)))
+		return
+	}
+	signers := []string{source}
+	for _, op := range ops {
+		if op.SourceAccount != nil {
+			signers = append(signers, op.SourceAccount.Address())
+		}
+	}
+	if !isSigned(hash, txe.Signatures(), signers) {
+		writeProblem(w, txFailed(txeB64, "tx_bad_auth", nil))
+		return
+	}
+
+	if txe.SeqNum() != sourceAccount.seq+1 {
+		writeProblem(w, txFailed(txeB64, "tx_bad_seq", nil))
+		return
+	}
+
+	fee := s.feeStats.LastLedgerBaseFee * int64(len(ops))
+	if int64(txe.Fee()) < fee {
+		writeProblem(w, txFailed(txeB64, "tx_insufficient_fee", nil))
+		return
+	}
+	if sourceAccount.balances[nativeAsset].balance < fee {
+		writeProblem(w, txFailed(txeB64, "tx_insufficient_balance", nil))
+		return
+	}
+
+	// the fee and the sequence number are consumed even when an operation fails
+	s.ledger.sequence++
+	sourceAccount.seq = txe.SeqNum()
+	s.ledger.credit(source, nativeAsset, -fee)
+
+	now := s.nowFn()
+	next := s.ledger.clone()
+	opCodes := []string{}
+	opResults := []xdr.OperationResult{}
+	for i, op := range ops {
+		opSource := source
+		if op.SourceAccount != nil {
+			opSource = op.SourceAccount.Address()
+		}
+		if _, ok := next.accounts[opSource]; !ok {
+			opCodes = append(opCodes, "op_no_source_account")
+			writeProblem(w, txFailed(txeB64, "tx_failed", opCodes))
+			return
+		}
+
+		lastOfferID := next.lastOfferID
+		code := next.applyOp(next.opID(i), opSource, op.Body, now)
+		opCodes = append(opCodes, code)
+		if code != "op_success" {
+			log.Printf("fake horizon rejected transaction %x: operation %d failed with %s\n", hash, i, code)
+			writeProblem(w, txFailed(txeB64, "tx_failed", opCodes))
+			return
+		}
+
+		opResult, e := next.opResult(opSource, op.Body, lastOfferID)
+		if e != nil {
+			writeProblem(w, badRequest(fmt.Sprintf("could not make the result of operation %d: %s", i, e)))
+			return
+		}
+		opResults = append(opResults, opResult)
+	}
+	resultXdr, e := transactionResultXdr(fee, opResults)
+	if e != nil {
+		writeProblem(w, badRequest(fmt.Sprintf("could not encode the transaction result: %s", e)))
+		return
+	}
+	s.ledger = next
+
+	hashHex := fmt.Sprintf("%x", hash)
+	result := hProtocol.Transaction{
+		ID:              hashHex,
+		PT:              strconv.FormatInt(int64(next.sequence)<<32|int64(1)<<12, 10),
+		Successful:      true,
+		Hash:            hashHex,
+		Ledger:          int32(next.sequence),
+		LedgerCloseTime: now,
+		Account:         source,
+		AccountSequence: strconv.FormatInt(txe.SeqNum(), 10),
+		FeeAccount:      source,
+		FeeCharged:      fee,
+		MaxFee:          int64(txe.Fee()),
+		OperationCount:  int32(len(ops)),
+		EnvelopeXdr:     txeB64,
+		ResultXdr:       resultXdr,
+		Signatures:      []string{},
+	}
+	result.MemoType, result.Memo = memoJSON(txe.Memo())
+	result.Links.Self = s.link("/transactions/%s", hashHex)
+	result.Links.Transaction = result.Links.Self
+	s.transactions = append(s.transactions, result)
+	for i, op := range ops {
+		opSource := source
+		if op.SourceAccount != nil {
+			opSource = op.SourceAccount.Address()
+		}
+		s.operations[next.opID(i)] = s.operationJSON(next.opID(i), opSource, op.Body.Type, result)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// applyOp applies a single operation and returns its result code
+func (l *ledger) applyOp(opID int64, source string, body xdr.OperationBody, now time.Time) string {
+	switch body.Type {
+	case xdr.OperationTypeManageSellOffer:
+		op := body.MustManageSellOfferOp()
+		return l.manageOffer(opID, source, toHorizonAsset(op.Selling), toHorizonAsset(op.Buying), int64(op.Amount), op.Price, int64(op.OfferId), false, now)
+	case xdr.OperationTypeCreatePassiveSellOffer:
+		op := body.MustCreatePassiveSellOfferOp()
+		return l.manageOffer(opID, source, toHorizonAsset(op.Selling), toHorizonAsset(op.Buying), int64(op.Amount), op.Price, 0, true, now)
+	case xdr.OperationTypePayment:
+		op := body.MustPaymentOp()
+		return l.payment(source, op.Destination.Address(), toHorizonAsset(op.Asset), int64(op.Amount))
+	case xdr.OperationTypePathPaymentStrictSend:
+		op := body.MustPathPaymentStrictSendOp()
+		return l.pathPaymentStrictSend(opID, source, op.Destination.Address(), toHorizonAsset(op.SendAsset), int64(op.SendAmount), toHorizonAsset(op.DestAsset), int64(op.DestMin), toHorizonAssets(op.Path), now)
+	case xdr.OperationTypePathPaymentStrictReceive:
+		op := body.MustPathPaymentStrictReceiveOp()
+		return l.pathPaymentStrictReceive(opID, source, op.Destination.Address(), toHorizonAsset(op.SendAsset), int64(op.SendMax), toHorizonAsset(op.DestAsset), int64(op.DestAmount), toHorizonAssets(op.Path), now)
+	default:
+		return "op_not_supported"
+	}
+}
+
+// isSigned is true when every signer has a valid signature on the transaction hash
+func isSigned(hash [32]byte, signatures []xdr.DecoratedSignature, signers []string) bool {
+	for _, signer := range signers {
+		kp, e := keypair.ParseAddress(signer)
+		if e != nil {
+			return false
+		}
+
+		found := false
+		for _, sig := range signatures {
+			if kp.Verify(hash[:], []byte(sig.Signature)) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func toHorizonAsset(a xdr.Asset) hProtocol.Asset {
+	var assetType, code, issuer string
+	a.MustExtract(&assetType, &code, &issuer)
+	return hProtocol.Asset{Type: assetType, Code: code, Issuer: issuer}
+}
+
+// queryAsset reads the asset with the prefix (e.g. "selling_") from the query, returns nil when the type is missing
+func queryAsset(r *http.Request, prefix string) (*hProtocol.Asset, error) {
+	assetType := r.Form.Get(prefix + "asset_type")
+	if assetType == "" {
+		return nil, nil
+	}
+	if assetType == nativeAsset.Type {
+		return &nativeAsset, nil
+	}
+
+	code := r.Form.Get(prefix + "asset_code")
+	issuer := r.Form.Get(prefix + "asset_issuer")
+	if code == "" || issuer == "" {
+		return nil, fmt.Errorf("%sasset_code and %sasset_issuer are required for credit assets", prefix, prefix)
+	}
+	return &hProtocol.Asset{Type: assetType, Code: code, Issuer: issuer}, nil
+}
+
+// pageParams reads the cursor, limit and order of a paged request
+func pageParams(r *http.Request) (string, int, bool, error) {
+	limit := defaultPageLimit
+	if l := r.Form.Get("limit"); l != "" {
+		var e error
+		limit, e = strconv.Atoi(l)
+		if e != nil || limit <= 0 || limit > maxPageLimit {
+			return "", 0, false, fmt.Errorf("invalid limit '%s'", l)
+		}
+	}
+
+	order := r.Form.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		return "", 0, false, fmt.Errorf("invalid order '%s'", order)
+	}
+	return r.Form.Get("cursor"), limit, order == "desc", nil
+}
+
+// pageLinks links to the same request from the cursor of the last record, so the page after the last record is empty
+func (s *Server) pageLinks(r *http.Request, nextCursor string) hal.Links {
+	self := s.URL + r.URL.RequestURI()
+
+	query := url.Values{}
+	for k, v := range r.URL.Query() {
+		query[k] = v
+	}
+	query.Set("cursor", nextCursor)
+	next := s.URL + r.URL.Path + "?" + query.Encode()
+
+	return hal.Links{
+		Self: hal.NewLink(self),
+		Next: hal.NewLink(next),
+		Prev: hal.NewLink(self),
+	}
+}
+
+func (s *Server) link(format string, args ...interface{}) hal.Link {
+	return hal.NewLink(s.URL + fmt.Sprintf(format, args...))
+}
+
+// priceString formats the price with 7 decimals like horizon
+func priceString(p xdr.Price) string {
+	return strconv.FormatFloat(math.Round(float64(p.N)/float64(p.D)*1e7)/1e7, 'f', 7, 64)
+}
+
+func notFound() problem.P {
+	return problem.P{
+		Type:   "https://stellar.org/horizon-errors/not_found",
+		Title:  "Resource Missing",
+		Status: http.StatusNotFound,
+		Detail: "The resource at the url requested was not found.",
+	}
+}
+
+func badRequest(detail string) problem.P {
+	return problem.P{
+		Type:   "https://stellar.org/horizon-errors/bad_request",
+		Title:  "Bad Request",
+		Status: http.StatusBadRequest,
+		Detail: detail,
+	}
+}
+
+// txFailed is the problem returned by horizon when a transaction fails, the SDEX reads the result codes from the extras
+func txFailed(txeB64 string, txCode string, opCodes []string) problem.P {
+	resultCodes := map[string]interface{}{
+		"transaction": txCode,
+	}
+	if len(opCodes) > 0 {
+		resultCodes["operations"] = opCodes
+	}
+	return problem.P{
+		Type:   "https://stellar.org/horizon-errors/transaction_failed",
+		Title:  "Transaction Failed",
+		Status: http.StatusBadRequest,
+		Detail: "The transaction failed when submitted to the stellar network.",
+		Extras: map[string]interface{}{
+			"envelope_xdr": txeB64,
+			"result_codes": resultCodes,
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/hal+json; charset=utf-8")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w).Encode(v)
+	if e != nil {
+		log.Printf("fake horizon could not write response: %s\n", e)
+	}
+}
+
+func writeProblem(w http.ResponseWriter, p problem.P) {
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(p.Status)
+	e := json.NewEncoder(w).Encode(p)
+	if e != nil {
+		log.Printf("fake horizon could not write problem: %s\n", e)
+	}
+}
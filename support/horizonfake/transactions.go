@@ -0,0 +1,277 @@
+package horizonfake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/xdr"
+)
+
+// opResult is the result of an operation that applyOp applied successfully, lastOfferID is the last offer ID before the operation
+//
+// The result of a ManageSellOffer or CreatePassiveSellOffer operation has the offer that it left on the book (if any) but not the
+// offers that it claimed, path payment results only have the last payment.
+func (l *ledger) opResult(source string, body xdr.OperationBody, lastOfferID int64) (xdr.OperationResult, error) {
+	tr := xdr.OperationResultTr{Type: body.Type}
+	switch body.Type {
+	case xdr.OperationTypeManageSellOffer:
+		op := body.MustManageSellOfferOp()
+		result, e := l.manageOfferResult(source, int64(op.OfferId), lastOfferID, op.Selling, op.Buying)
+		if e != nil {
+			return xdr.OperationResult{}, e
+		}
+		tr.ManageSellOfferResult = result
+	case xdr.OperationTypeCreatePassiveSellOffer:
+		op := body.MustCreatePassiveSellOfferOp()
+		result, e := l.manageOfferResult(source, 0, lastOfferID, op.Selling, op.Buying)
+		if e != nil {
+			return xdr.OperationResult{}, e
+		}
+		tr.CreatePassiveSellOfferResult = result
+	case xdr.OperationTypePayment:
+		tr.PaymentResult = &xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentSuccess}
+	case xdr.OperationTypePathPaymentStrictSend:
+		op := body.MustPathPaymentStrictSendOp()
+		tr.PathPaymentStrictSendResult = &xdr.PathPaymentStrictSendResult{
+			Code: xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSuccess,
+			Success: &xdr.PathPaymentStrictSendResultSuccess{
+				Offers: []xdr.ClaimOfferAtom{},
+				Last:   xdr.SimplePaymentResult{Destination: op.Destination.ToAccountId(), Asset: op.DestAsset, Amount: xdr.Int64(l.lastPathPaymentDestAmount)},
+			},
+		}
+	case xdr.OperationTypePathPaymentStrictReceive:
+		op := body.MustPathPaymentStrictReceiveOp()
+		tr.PathPaymentStrictReceiveResult = &xdr.PathPaymentStrictReceiveResult{
+			Code: xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveSuccess,
+			Success: &xdr.PathPaymentStrictReceiveResultSuccess{
+				Offers: []xdr.ClaimOfferAtom{},
+				Last:   xdr.SimplePaymentResult{Destination: op.Destination.ToAccountId(), Asset: op.DestAsset, Amount: op.DestAmount},
+			},
+		}
+	default:
+		return xdr.OperationResult{}, fmt.Errorf("no result for operation type %s", body.Type)
+	}
+	return xdr.OperationResult{Code: xdr.OperationResultCodeOpInner, Tr: &tr}, nil
+}
+
+// manageOfferResult is the result of a ManageSellOffer (offerID is 0 for a new offer) or CreatePassiveSellOffer operation
+func (l *ledger) manageOfferResult(source string, offerID int64, lastOfferID int64, selling xdr.Asset, buying xdr.Asset) (*xdr.ManageSellOfferResult, error) {
+	if offerID == 0 && l.lastOfferID > lastOfferID {
+		offerID = l.lastOfferID
+	}
+	success := &xdr.ManageOfferSuccessResult{
+		OffersClaimed: []xdr.ClaimOfferAtom{},
+		Offer:         xdr.ManageOfferSuccessResultOffer{Effect: xdr.ManageOfferEffectManageOfferDeleted},
+	}
+
+	o, ok := l.offers[offerID]
+	if ok {
+		sellerID, e := xdr.AddressToAccountId(source)
+		if e != nil {
+			return nil, fmt.Errorf("invalid source account '%s': %s", source, e)
+		}
+		success.Offer.Effect = xdr.ManageOfferEffectManageOfferUpdated
+		if offerID > lastOfferID {
+			success.Offer.Effect = xdr.ManageOfferEffectManageOfferCreated
+		}
+		success.Offer.Offer = &xdr.OfferEntry{
+			SellerId: sellerID,
+			OfferId:  xdr.Int64(o.id),
+			Selling:  selling,
+			Buying:   buying,
+			Amount:   xdr.Int64(o.amount),
+			Price:    o.price,
+		}
+	}
+	return &xdr.ManageSellOfferResult{Code: xdr.ManageSellOfferResultCodeManageSellOfferSuccess, Success: success}, nil
+}
+
+// transactionResultXdr encodes the result of a successful transaction
+func transactionResultXdr(fee int64, opResults []xdr.OperationResult) (string, error) {
+	return xdr.MarshalBase64(xdr.TransactionResult{
+		FeeCharged: xdr.Int64(fee),
+		Result: xdr.TransactionResultResult{
+			Code:    xdr.TransactionResultCodeTxSuccess,
+			Results: &opResults,
+		},
+	})
+}
+
+// memoJSON returns the memo_type and memo fields of a transaction like horizon
+func memoJSON(memo xdr.Memo) (string, string) {
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		return "text", memo.MustText()
+	case xdr.MemoTypeMemoId:
+		return "id", strconv.FormatUint(uint64(memo.MustId()), 10)
+	case xdr.MemoTypeMemoHash:
+		hash := memo.MustHash()
+		return "hash", base64.StdEncoding.EncodeToString(hash[:])
+	case xdr.MemoTypeMemoReturn:
+		hash := memo.MustRetHash()
+		return "return", base64.StdEncoding.EncodeToString(hash[:])
+	default:
+		return "none", ""
+	}
+}
+
+// recordPlacedOffer records the transaction of an offer placed with PlaceOffer on the next ledger
+func (s *Server) recordPlacedOffer(next *ledger, accountID string, selling hProtocol.Asset, buying hProtocol.Asset, now time.Time) error {
+	sellingXdr, e := toXdrAsset(selling)
+	if e != nil {
+		return e
+	}
+	buyingXdr, e := toXdrAsset(buying)
+	if e != nil {
+		return e
+	}
+	opResult := xdr.OperationResult{Code: xdr.OperationResultCodeOpInner, Tr: &xdr.OperationResultTr{Type: xdr.OperationTypeManageSellOffer}}
+	opResult.Tr.ManageSellOfferResult, e = next.manageOfferResult(accountID, 0, s.ledger.lastOfferID, sellingXdr, buyingXdr)
+	if e != nil {
+		return e
+	}
+	resultXdr, e := transactionResultXdr(0, []xdr.OperationResult{opResult})
+	if e != nil {
+		return e
+	}
+
+	opID := next.opID(0)
+	// the transaction was never signed so it has no hash, derive a unique one from the ID of its operation
+	hash := fmt.Sprintf("%064x", opID)
+	tx := hProtocol.Transaction{
+		ID:              hash,
+		PT:              strconv.FormatInt(opID-1, 10),
+		Successful:      true,
+		Hash:            hash,
+		Ledger:          int32(next.sequence),
+		LedgerCloseTime: now,
+		Account:         accountID,
+		FeeAccount:      accountID,
+		OperationCount:  1,
+		ResultXdr:       resultXdr,
+		MemoType:        "none",
+		Signatures:      []string{},
+	}
+	tx.Links.Self = s.link("/transactions/%s", hash)
+	tx.Links.Transaction = tx.Links.Self
+	s.transactions = append(s.transactions, tx)
+	s.operations[opID] = s.operationJSON(opID, accountID, xdr.OperationTypeManageSellOffer, tx)
+	return nil
+}
+
+func toXdrAsset(a hProtocol.Asset) (xdr.Asset, error) {
+	if a.Type == nativeAsset.Type {
+		return xdr.NewAsset(xdr.AssetTypeAssetTypeNative, nil)
+	}
+	return xdr.BuildAsset(a.Type, a.Issuer, a.Code)
+}
+
+// getTransaction serves a transaction that was submitted successfully
+func (s *Server) getTransaction(w http.ResponseWriter, hash string) {
+	for _, tx := range s.transactions {
+		if tx.Hash == hash {
+			writeJSON(w, http.StatusOK, tx)
+			return
+		}
+	}
+	writeProblem(w, notFound())
+}
+
+// getAccountTransactions serves the successful transactions where the account is the source of the transaction or of an operation
+func (s *Server) getAccountTransactions(w http.ResponseWriter, r *http.Request, accountID string) {
+	if _, ok := s.ledger.accounts[accountID]; !ok {
+		writeProblem(w, notFound())
+		return
+	}
+	cursor, limit, desc, e := pageParams(r)
+	if e != nil {
+		writeProblem(w, badRequest(e.Error()))
+		return
+	}
+	var cursorID int64
+	if cursor != "" {
+		cursorID, e = strconv.ParseInt(cursor, 10, 64)
+		if e != nil {
+			writeProblem(w, badRequest(fmt.Sprintf("invalid cursor '%s'", cursor)))
+			return
+		}
+	}
+
+	txs := append([]hProtocol.Transaction{}, s.transactions...)
+	if desc {
+		for i, j := 0, len(txs)-1; i < j; i, j = i+1, j-1 {
+			txs[i], txs[j] = txs[j], txs[i]
+		}
+	}
+
+	page := hProtocol.TransactionsPage{}
+	page.Embedded.Records = []hProtocol.Transaction{}
+	for _, tx := range txs {
+		if len(page.Embedded.Records) >= limit {
+			break
+		}
+		if tx.Account != accountID && !s.hasOperationSource(tx.Hash, accountID) {
+			continue
+		}
+		if cursor != "" {
+			id, _ := strconv.ParseInt(tx.PT, 10, 64)
+			if (!desc && id <= cursorID) || (desc && id >= cursorID) {
+				continue
+			}
+		}
+		page.Embedded.Records = append(page.Embedded.Records, tx)
+	}
+	nextCursor := cursor
+	if n := len(page.Embedded.Records); n > 0 {
+		nextCursor = page.Embedded.Records[n-1].PT
+	}
+	page.Links = s.pageLinks(r, nextCursor)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) hasOperationSource(txHash string, accountID string) bool {
+	for _, op := range s.operations {
+		if op.TransactionHash == txHash && op.SourceAccount == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// getOperation serves an operation of a transaction that was submitted successfully
+func (s *Server) getOperation(w http.ResponseWriter, opIDString string) {
+	opID, e := strconv.ParseInt(opIDString, 10, 64)
+	if e != nil {
+		writeProblem(w, notFound())
+		return
+	}
+	op, ok := s.operations[opID]
+	if !ok {
+		writeProblem(w, notFound())
+		return
+	}
+	writeJSON(w, http.StatusOK, op)
+}
+
+// operationJSON is the operation record of horizon without the fields that are specific to the type of operation
+func (s *Server) operationJSON(opID int64, source string, opType xdr.OperationType, tx hProtocol.Transaction) operations.Base {
+	op := operations.Base{
+		ID:                    strconv.FormatInt(opID, 10),
+		PT:                    strconv.FormatInt(opID, 10),
+		TransactionSuccessful: true,
+		SourceAccount:         source,
+		Type:                  operations.TypeNames[opType],
+		TypeI:                 int32(opType),
+		LedgerCloseTime:       tx.LedgerCloseTime,
+		TransactionHash:       tx.Hash,
+	}
+	op.Links.Self = s.link("/operations/%d", opID)
+	op.Links.Transaction = s.link("/transactions/%s", tx.Hash)
+	op.Links.Effects = s.link("/operations/%d/effects", opID)
+	return op
+}
@@ -0,0 +1,98 @@
+package horizonfake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManageOfferResult(t *testing.T) {
+	l := makeLedger()
+	maker := keypair.MustRandom().Address()
+	taker := keypair.MustRandom().Address()
+	for _, id := range []string{maker, taker} {
+		l.addAccount(id, 1000*1e7)
+		_ = l.addTrustline(id, testUSD, 1000*1e7)
+		l.accounts[id].balances[testUSD].balance = 100 * 1e7
+	}
+	selling := xdr.MustNewNativeAsset()
+	buying := xdr.MustNewCreditAsset(testUSD.Code, keypair.MustRandom().Address())
+	now := time.Now()
+
+	apply := func(source string, amount int64, price xdr.Price, offerID int64) xdr.ManageOfferSuccessResultOffer {
+		lastOfferID := l.lastOfferID
+		sellingAsset, buyingAsset := nativeAsset, testUSD
+		if source == taker {
+			sellingAsset, buyingAsset = testUSD, nativeAsset
+		}
+		code := l.manageOffer(l.opID(0), source, sellingAsset, buyingAsset, amount, price, offerID, false, now)
+		if !assert.Equal(t, "op_success", code) {
+			t.FailNow()
+		}
+		result, e := l.manageOfferResult(source, offerID, lastOfferID, selling, buying)
+		if !assert.NoError(t, e) {
+			t.FailNow()
+		}
+		assert.Equal(t, xdr.ManageSellOfferResultCodeManageSellOfferSuccess, result.Code)
+		return result.Success.Offer
+	}
+
+	created := apply(maker, 100*1e7, xdr.Price{N: 1, D: 10}, 0)
+	assert.Equal(t, xdr.ManageOfferEffectManageOfferCreated, created.Effect)
+	if assert.NotNil(t, created.Offer) {
+		assert.Equal(t, xdr.Int64(l.lastOfferID), created.Offer.OfferId)
+		assert.Equal(t, maker, created.Offer.SellerId.Address())
+		assert.Equal(t, xdr.Int64(100*1e7), created.Offer.Amount)
+	}
+	id := int64(created.Offer.OfferId)
+
+	updated := apply(maker, 50*1e7, xdr.Price{N: 1, D: 10}, id)
+	assert.Equal(t, xdr.ManageOfferEffectManageOfferUpdated, updated.Effect)
+	if assert.NotNil(t, updated.Offer) {
+		assert.Equal(t, xdr.Int64(id), updated.Offer.OfferId)
+		assert.Equal(t, xdr.Int64(50*1e7), updated.Offer.Amount)
+	}
+
+	// the taker is filled completely so no offer is left on the book
+	taken := apply(taker, 1*1e7, xdr.Price{N: 10, D: 1}, 0)
+	assert.Equal(t, xdr.ManageOfferEffectManageOfferDeleted, taken.Effect)
+	assert.Nil(t, taken.Offer)
+
+	deleted := apply(maker, 0, xdr.Price{N: 1, D: 10}, id)
+	assert.Equal(t, xdr.ManageOfferEffectManageOfferDeleted, deleted.Effect)
+	assert.Nil(t, deleted.Offer)
+}
+
+func TestMemoJSON(t *testing.T) {
+	hash := xdr.Hash{1, 2, 3}
+	testCases := []struct {
+		memo         xdr.Memo
+		wantMemoType string
+		wantMemo     string
+	}{
+		{xdr.Memo{Type: xdr.MemoTypeMemoNone}, "none", ""},
+		{xdr.Memo{Type: xdr.MemoTypeMemoText, Text: stringPtr("bot1")}, "text", "bot1"},
+		{xdr.Memo{Type: xdr.MemoTypeMemoId, Id: uint64Ptr(42)}, "id", "42"},
+		{xdr.Memo{Type: xdr.MemoTypeMemoHash, Hash: &hash}, "hash", "AQIDAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.wantMemoType, func(t *testing.T) {
+			memoType, memo := memoJSON(k.memo)
+			assert.Equal(t, k.wantMemoType, memoType)
+			assert.Equal(t, k.wantMemo, memo)
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func uint64Ptr(v uint64) *xdr.Uint64 {
+	u := xdr.Uint64(v)
+	return &u
+}
@@ -36,8 +36,36 @@ func (kos *KelpOS) RegisterBotWithStateUpsert(bot *model2.Bot, state BotState) {
 	_ = kos.registerBotWithState(bot, state, true)
 }
 
+// SetBotStateListener sets the function that is called after the state of any bot changes
+func (kos *KelpOS) SetBotStateListener(listener func(botName string, state BotState)) {
+	kos.botLock.Lock()
+	defer kos.botLock.Unlock()
+
+	kos.botStateListener = listener
+}
+
+// notifyBotState calls the listener outside of the botLock so the listener can safely query the bots
+func (kos *KelpOS) notifyBotState(botName string, state BotState) {
+	kos.botLock.Lock()
+	listener := kos.botStateListener
+	kos.botLock.Unlock()
+
+	if listener != nil {
+		listener(botName, state)
+	}
+}
+
 // registerBotWithState registers a new bot with a given state, returning an error if one already exists with the same name
 func (kos *KelpOS) registerBotWithState(bot *model2.Bot, state BotState, forceRegister bool) error {
+	e := kos.doRegisterBotWithState(bot, state, forceRegister)
+	if e != nil {
+		return e
+	}
+	kos.notifyBotState(bot.Name, state)
+	return nil
+}
+
+func (kos *KelpOS) doRegisterBotWithState(bot *model2.Bot, state BotState, forceRegister bool) error {
 	kos.botLock.Lock()
 	defer kos.botLock.Unlock()
 
@@ -58,27 +86,36 @@ func (kos *KelpOS) registerBotWithState(bot *model2.Bot, state BotState, forceRe
 
 // AdvanceBotState advances the state of the given bot atomically, ensuring the bot is currently at the expected state
 func (kos *KelpOS) AdvanceBotState(botName string, expectedCurrentState BotState) error {
+	ns, e := kos.advanceBotState(botName, expectedCurrentState)
+	if e != nil {
+		return e
+	}
+	kos.notifyBotState(botName, ns)
+	return nil
+}
+
+func (kos *KelpOS) advanceBotState(botName string, expectedCurrentState BotState) (BotState, error) {
 	kos.botLock.Lock()
 	defer kos.botLock.Unlock()
 
 	b, exists := kos.bots[botName]
 	if !exists {
-		return fmt.Errorf("bot '%s' is not registered", botName)
+		return InitState(), fmt.Errorf("bot '%s' is not registered", botName)
 	}
 
 	if b.State != expectedCurrentState {
-		return fmt.Errorf("state of bot '%s' was not as expected (%s): %s", botName, expectedCurrentState, b.State)
+		return InitState(), fmt.Errorf("state of bot '%s' was not as expected (%s): %s", botName, expectedCurrentState, b.State)
 	}
 
 	ns, e := nextState(b.State)
 	if e != nil {
-		return fmt.Errorf("error while advancing bot state for '%s': %s", botName, e)
+		return InitState(), fmt.Errorf("error while advancing bot state for '%s': %s", botName, e)
 	}
 
 	b.State = ns
 	log.Printf("advanced bot state for bot '%s' to %s\n", botName, ns)
 
-	return nil
+	return ns, nil
 }
 
 // GetBot fetches the bot state for the given name
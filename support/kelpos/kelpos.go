@@ -23,6 +23,7 @@ type KelpOS struct {
 	bots                map[string]*BotInstance
 	botLock             *sync.Mutex
 	silentRegistrations bool
+	botStateListener    func(botName string, state BotState)
 }
 
 // GetBinDir accessor
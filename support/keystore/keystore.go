@@ -0,0 +1,265 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// RefPrefix is the prefix of config values that reference a secret in the keystore, for example "keystore:mybot"
+const RefPrefix = "keystore:"
+
+const keystoreVersion = 1
+
+// scrypt params recommended for interactive logins, see the docs of golang.org/x/crypto/scrypt
+const (
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	saltLength   = 32
+	keyLength    = 32
+	nonceLength  = 24
+	keystorePerm = 0600
+)
+
+// keystoreFile is the on-disk format of the keystore, the secrets are encrypted as a single json blob
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Keystore is a file of named secrets encrypted with a key derived from a passphrase
+type Keystore struct {
+	filepath string
+	salt     []byte
+	key      *[keyLength]byte
+	lock     *sync.Mutex
+	secrets  map[string]string
+}
+
+// Create makes a new empty keystore, which is only written to the file when it is saved
+func Create(filepath string, passphrase string) (*Keystore, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase for keystore cannot be empty")
+	}
+
+	salt := make([]byte, saltLength)
+	_, e := rand.Read(salt)
+	if e != nil {
+		return nil, fmt.Errorf("could not generate salt: %s", e)
+	}
+
+	key, e := deriveKey(passphrase, salt, scryptN, scryptR, scryptP)
+	if e != nil {
+		return nil, e
+	}
+
+	return &Keystore{
+		filepath: filepath,
+		salt:     salt,
+		key:      key,
+		lock:     &sync.Mutex{},
+		secrets:  map[string]string{},
+	}, nil
+}
+
+// Open decrypts the keystore file with the passphrase
+func Open(filepath string, passphrase string) (*Keystore, error) {
+	fileBytes, e := ioutil.ReadFile(filepath)
+	if e != nil {
+		return nil, fmt.Errorf("could not read keystore file '%s': %s", filepath, e)
+	}
+
+	var f keystoreFile
+	e = json.Unmarshal(fileBytes, &f)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse keystore file '%s': %s", filepath, e)
+	}
+	if f.Version != keystoreVersion || f.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore file '%s' (version=%d, kdf=%s)", filepath, f.Version, f.KDF)
+	}
+	if len(f.Nonce) != nonceLength {
+		return nil, fmt.Errorf("invalid nonce in keystore file '%s'", filepath)
+	}
+
+	key, e := deriveKey(passphrase, f.Salt, f.N, f.R, f.P)
+	if e != nil {
+		return nil, e
+	}
+
+	var nonce [nonceLength]byte
+	copy(nonce[:], f.Nonce)
+	plaintext, ok := secretbox.Open(nil, f.Ciphertext, &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("could not decrypt keystore file '%s', the passphrase is incorrect or the file is corrupted", filepath)
+	}
+
+	secrets := map[string]string{}
+	e = json.Unmarshal(plaintext, &secrets)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse decrypted secrets in keystore file '%s': %s", filepath, e)
+	}
+
+	return &Keystore{
+		filepath: filepath,
+		salt:     f.Salt,
+		key:      key,
+		lock:     &sync.Mutex{},
+		secrets:  secrets,
+	}, nil
+}
+
+// OpenOrCreate opens the keystore file if it exists, otherwise it creates a new keystore
+func OpenOrCreate(filepath string, passphrase string) (*Keystore, error) {
+	_, e := os.Stat(filepath)
+	if os.IsNotExist(e) {
+		return Create(filepath, passphrase)
+	}
+	return Open(filepath, passphrase)
+}
+
+func deriveKey(passphrase string, salt []byte, n int, r int, p int) (*[keyLength]byte, error) {
+	keyBytes, e := scrypt.Key([]byte(passphrase), salt, n, r, p, keyLength)
+	if e != nil {
+		return nil, fmt.Errorf("could not derive key from passphrase: %s", e)
+	}
+
+	var key [keyLength]byte
+	copy(key[:], keyBytes)
+	return &key, nil
+}
+
+// Filepath returns the path of the keystore file
+func (k *Keystore) Filepath() string {
+	return k.filepath
+}
+
+// Get returns the secret with the given name
+func (k *Keystore) Get(name string) (string, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	secret, ok := k.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("no secret named '%s' in keystore file '%s'", name, k.filepath)
+	}
+	return secret, nil
+}
+
+// Set adds or replaces the secret with the given name, call Save to persist it
+func (k *Keystore) Set(name string, secret string) error {
+	if name == "" {
+		return fmt.Errorf("name of secret cannot be empty")
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.secrets[name] = secret
+	return nil
+}
+
+// Delete removes the secret with the given name, call Save to persist it
+func (k *Keystore) Delete(name string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	delete(k.secrets, name)
+}
+
+// Names returns the sorted names of the secrets in the keystore
+func (k *Keystore) Names() []string {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	names := []string{}
+	for name := range k.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a fresh nonce and writes them to the keystore file
+func (k *Keystore) Save() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	plaintext, e := json.Marshal(k.secrets)
+	if e != nil {
+		return fmt.Errorf("could not marshal secrets: %s", e)
+	}
+
+	var nonce [nonceLength]byte
+	_, e = rand.Read(nonce[:])
+	if e != nil {
+		return fmt.Errorf("could not generate nonce: %s", e)
+	}
+
+	fileBytes, e := json.MarshalIndent(keystoreFile{
+		Version:    keystoreVersion,
+		KDF:        "scrypt",
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Salt:       k.salt,
+		Nonce:      nonce[:],
+		Ciphertext: secretbox.Seal(nil, plaintext, &nonce, k.key),
+	}, "", "  ")
+	if e != nil {
+		return fmt.Errorf("could not marshal keystore file: %s", e)
+	}
+
+	// write to a temporary file first so bots reading the keystore never see a partially written file
+	tmpFilepath := k.filepath + ".tmp"
+	e = ioutil.WriteFile(tmpFilepath, fileBytes, keystorePerm)
+	if e != nil {
+		return fmt.Errorf("could not write keystore file '%s': %s", tmpFilepath, e)
+	}
+	e = os.Rename(tmpFilepath, k.filepath)
+	if e != nil {
+		return fmt.Errorf("could not move keystore file from '%s' to '%s': %s", tmpFilepath, k.filepath, e)
+	}
+	return nil
+}
+
+// Resolve returns the secret referenced by the value, or the value itself if it is not a reference to the keystore
+func (k *Keystore) Resolve(value string) (string, error) {
+	name, ok := ParseRef(value)
+	if !ok {
+		return value, nil
+	}
+	return k.Get(name)
+}
+
+// IsRef returns whether the config value references a secret in the keystore
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, RefPrefix)
+}
+
+// ParseRef returns the name of the secret referenced by the config value
+func ParseRef(value string) (string, bool) {
+	if !IsRef(value) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, RefPrefix), true
+}
+
+// MakeRef returns the config value that references the secret with the given name
+func MakeRef(name string) string {
+	return RefPrefix + name
+}
@@ -0,0 +1,138 @@
+package keystore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestKeystorePath(t *testing.T) (string, func()) {
+	dir, e := ioutil.TempDir("", "kelp_keystore")
+	if e != nil {
+		t.Fatal(e)
+	}
+	return filepath.Join(dir, "keystore.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	keystorePath, cleanup := makeTestKeystorePath(t)
+	defer cleanup()
+
+	_, e := Open(keystorePath, "passphrase")
+	assert.Error(t, e)
+
+	k, e := OpenOrCreate(keystorePath, "passphrase")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.NoError(t, k.Set("mybot", "SBSECRET"))
+	assert.NoError(t, k.Set("other", "SBOTHER"))
+	assert.Error(t, k.Set("", "SBEMPTY"))
+	assert.NoError(t, k.Save())
+
+	// secrets are not written in plaintext
+	fileBytes, e := ioutil.ReadFile(keystorePath)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.False(t, strings.Contains(string(fileBytes), "SBSECRET"))
+
+	_, e = Open(keystorePath, "wrong passphrase")
+	assert.Error(t, e)
+
+	k2, e := OpenOrCreate(keystorePath, "passphrase")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []string{"mybot", "other"}, k2.Names())
+	secret, e := k2.Get("mybot")
+	assert.NoError(t, e)
+	assert.Equal(t, "SBSECRET", secret)
+	_, e = k2.Get("missing")
+	assert.Error(t, e)
+
+	k2.Delete("other")
+	assert.NoError(t, k2.Save())
+	k3, e := Open(keystorePath, "passphrase")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []string{"mybot"}, k3.Names())
+}
+
+func TestResolve(t *testing.T) {
+	keystorePath, cleanup := makeTestKeystorePath(t)
+	defer cleanup()
+
+	k, e := Create(keystorePath, "passphrase")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.NoError(t, k.Set("mybot", "SBSECRET"))
+
+	for _, kase := range []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "SBPLAINTEXT", want: "SBPLAINTEXT"},
+		{value: MakeRef("mybot"), want: "SBSECRET"},
+		{value: "keystore:missing", wantErr: true},
+	} {
+		t.Run(kase.value, func(t *testing.T) {
+			resolved, e := k.Resolve(kase.value)
+			if kase.wantErr {
+				assert.Error(t, e)
+				return
+			}
+			assert.NoError(t, e)
+			assert.Equal(t, kase.want, resolved)
+		})
+	}
+}
+
+func TestMakeSecretResolverWithoutKeystore(t *testing.T) {
+	resolve := MakeSecretResolver("")
+
+	// plaintext values never need the keystore
+	resolved, e := resolve("SBPLAINTEXT")
+	assert.NoError(t, e)
+	assert.Equal(t, "SBPLAINTEXT", resolved)
+
+	_, e = resolve("keystore:mybot")
+	assert.Error(t, e)
+}
+
+func TestReadPassphrase(t *testing.T) {
+	passphrase, e := readPassphrase("keystore.json", "from env", strings.NewReader("from stdin\n"), &bytes.Buffer{})
+	assert.NoError(t, e)
+	assert.Equal(t, "from env", passphrase)
+
+	out := &bytes.Buffer{}
+	passphrase, e = readPassphrase("keystore.json", "", strings.NewReader("from stdin\r\n"), out)
+	assert.NoError(t, e)
+	assert.Equal(t, "from stdin", passphrase)
+	assert.True(t, strings.Contains(out.String(), PassphraseEnvVar))
+
+	_, e = readPassphrase("keystore.json", "", strings.NewReader(""), &bytes.Buffer{})
+	assert.Error(t, e)
+
+	// files that are not a terminal, like a pipe, are read like any other reader
+	r, w, e := os.Pipe()
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer r.Close()
+	_, e = w.WriteString("from pipe\n")
+	assert.NoError(t, e)
+	w.Close()
+	passphrase, e = readPassphrase("keystore.json", "", r, &bytes.Buffer{})
+	assert.NoError(t, e)
+	assert.Equal(t, "from pipe", passphrase)
+}
@@ -0,0 +1,82 @@
+package keystore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/stellar/kelp/support/utils"
+)
+
+// PassphraseEnvVar is the environment variable used to unlock the keystore without being prompted for the passphrase
+const PassphraseEnvVar = "KELP_KEYSTORE_PASSPHRASE"
+
+// ReadPassphrase reads the passphrase from the environment variable, prompting for it on stdin if it is not set
+func ReadPassphrase(filepath string) (string, error) {
+	return readPassphrase(filepath, os.Getenv(PassphraseEnvVar), os.Stdin, os.Stderr)
+}
+
+func readPassphrase(filepath string, envValue string, in io.Reader, out io.Writer) (string, error) {
+	if envValue != "" {
+		return envValue, nil
+	}
+
+	fmt.Fprintf(out, "enter passphrase for keystore '%s' (or set %s): ", filepath, PassphraseEnvVar)
+	line, e := readLine(in, out)
+	if e != nil {
+		return "", fmt.Errorf("could not read passphrase: %s", e)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("no passphrase provided for keystore '%s'", filepath)
+	}
+	return passphrase, nil
+}
+
+// readLine reads a line from in without echoing it when in is a terminal, so the passphrase is not shown while it is typed. Pipes and
+// other readers are read as-is.
+func readLine(in io.Reader, out io.Writer) (string, error) {
+	if f, ok := in.(*os.File); ok && terminal.IsTerminal(int(f.Fd())) {
+		b, e := terminal.ReadPassword(int(f.Fd()))
+		// the newline typed by the user is not echoed either, so move the cursor past the prompt ourselves
+		fmt.Fprintln(out)
+		return string(b), e
+	}
+
+	line, e := bufio.NewReader(in).ReadString('\n')
+	if e == io.EOF {
+		return line, nil
+	}
+	return line, e
+}
+
+// MakeSecretResolver returns a resolver for config values that only unlocks the keystore at the filepath once it sees the first
+// reference to it, so configs without references never prompt for a passphrase
+func MakeSecretResolver(filepath string) utils.SecretResolver {
+	var k *Keystore
+	return func(value string) (string, error) {
+		name, ok := ParseRef(value)
+		if !ok {
+			return value, nil
+		}
+		if filepath == "" {
+			return "", fmt.Errorf("config references secret '%s' in a keystore but no keystore file was specified, use the --keystore flag", name)
+		}
+
+		if k == nil {
+			passphrase, e := ReadPassphrase(filepath)
+			if e != nil {
+				return "", e
+			}
+			k, e = Open(filepath, passphrase)
+			if e != nil {
+				return "", e
+			}
+		}
+		return k.Get(name)
+	}
+}
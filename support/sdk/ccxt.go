@@ -19,9 +19,10 @@ import (
 // ccxtBaseURL should not have suffix of '/'
 var ccxtBaseURL = "http://localhost:3000"
 
-// SetBaseURL allows setting the base URL for ccxt
+// SetBaseURL allows setting the base URL for ccxt, this clears the cached list of exchanges since it belongs to the previous server
 func SetBaseURL(baseURL string) error {
 	ccxtBaseURL = strings.TrimSuffix(baseURL, "/")
+	exchangeList = nil
 	log.Printf("updated ccxtBaseURL to '%s'\n", ccxtBaseURL)
 	return nil
 }
@@ -38,6 +39,7 @@ type Ccxt struct {
 	instanceName string
 	markets      map[string]CcxtMarket
 	headersMap   map[string]networking.HeaderFn
+	rateLimit    int64 // minimum millis between requests as reported by ccxt, 0 if unknown
 }
 
 // CcxtMarket represents the result of a LoadMarkets call
@@ -174,6 +176,16 @@ func (c *Ccxt) initialize(apiKey api.ExchangeAPIKey, params []api.ExchangeParam,
 	}
 	c.headersMap = headersMap
 
+	// fetch the rate limit of the exchange so callers can throttle requests, this is best-effort
+	var exchangeOutput map[string]interface{}
+	url = ccxtBaseURL + pathExchanges + "/" + c.exchangeName + "/" + c.instanceName
+	e = networking.JSONRequestDynamicHeaders(c.httpClient, "GET", url, "", c.headersMap, &exchangeOutput, "error")
+	if e != nil {
+		log.Printf("unable to fetch rateLimit for exchange instance (exchange=%s, instanceName=%s), continuing without it: %s\n", c.exchangeName, c.instanceName, e)
+	} else if rateLimit, ok := exchangeOutput["rateLimit"].(float64); ok && rateLimit > 0 {
+		c.rateLimit = int64(rateLimit)
+	}
+
 	return nil
 }
 
@@ -288,6 +300,11 @@ func (c *Ccxt) GetMarket(tradingPair string) *CcxtMarket {
 	return nil
 }
 
+// GetRateLimitMillis returns the minimum number of milliseconds between requests as reported by ccxt, 0 if unknown
+func (c *Ccxt) GetRateLimitMillis() int64 {
+	return c.rateLimit
+}
+
 // GetMarkets returns all the markets
 func (c *Ccxt) GetMarkets() map[string]CcxtMarket {
 	return c.markets
@@ -500,16 +517,18 @@ func (c *Ccxt) FetchBalance() (map[string]CcxtBalance, error) {
 
 // CcxtOpenOrder represents an open order
 type CcxtOpenOrder struct {
-	Amount    float64
-	Cost      float64
-	Filled    float64
-	ID        string
-	Price     float64
-	Side      string
-	Status    string
-	Symbol    string
-	Type      string
-	Timestamp int64
+	Amount        float64
+	Cost          float64
+	Filled        float64
+	ID            string
+	ClientOrderID string `mapstructure:"clientOrderId"` // not returned by all exchanges
+	Price         float64
+	Side          string
+	Status        string
+	Symbol        string
+	Type          string
+	Timestamp     int64
+	Info          interface{} // raw order response gotten from the exchange site's API
 }
 
 // FetchOpenOrders calls the /fetchOpenOrders endpoint on CCXT
@@ -564,7 +583,12 @@ func (c *Ccxt) FetchOpenOrders(tradingPairs []string) (map[string][]CcxtOpenOrde
 
 // CreateLimitOrder calls the /createOrder endpoint on CCXT with a limit price and the order type set to "limit"
 func (c *Ccxt) CreateLimitOrder(tradingPair string, side string, amount float64, price float64, maybeExchangeSpecificParams interface{}) (*CcxtOpenOrder, error) {
-	orderType := "limit"
+	return c.CreateOrder(tradingPair, "limit", side, amount, price, maybeExchangeSpecificParams)
+}
+
+// CreateOrder calls the /createOrder endpoint on CCXT with the order type, which can be "limit", "market" or an exchange-specific type
+// such as the stop orders on binance. The price is ignored by most exchanges for market orders
+func (c *Ccxt) CreateOrder(tradingPair string, orderType string, side string, amount float64, price float64, maybeExchangeSpecificParams interface{}) (*CcxtOpenOrder, error) {
 	e := c.symbolExists(tradingPair)
 	if e != nil {
 		return nil, fmt.Errorf("symbol does not exist: %s", e)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/stellar/kelp/api"
 	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/support/sdk/ccxtfake"
 )
 
 func TestMakeInstanceName(t *testing.T) {
@@ -145,6 +146,7 @@ func TestMakeValid(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	_, e := MakeInitializedCcxtExchange("kraken", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
 	if e != nil {
@@ -158,6 +160,7 @@ func TestMakeInvalid(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	_, e := MakeInitializedCcxtExchange("missing-exchange", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
 	if e == nil {
@@ -176,6 +179,7 @@ func TestFetchTickers(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	c, e := MakeInitializedCcxtExchange("binance", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
 	if e != nil {
@@ -200,6 +204,7 @@ func TestFetchTickersWithMissingSymbol(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	c, e := MakeInitializedCcxtExchange("binance", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
 	if e != nil {
@@ -224,6 +229,7 @@ func TestFetchOrderBook(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	limit5 := 5
 	limit2 := 2
@@ -328,6 +334,7 @@ func TestFetchTrades(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	// "id" is not always part of a trade result on Kraken for the public trades API
 	krakenFields := []string{"amount", "cost", "datetime", "price", "side", "symbol", "timestamp"}
@@ -389,6 +396,7 @@ func TestFetchMyTrades(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	krakenFields := []string{"amount", "cost", "datetime", "id", "price", "side", "symbol", "timestamp", "fee"}
 	binanceFields := []string{"amount", "cost", "datetime", "id", "price", "side", "symbol", "timestamp", "fee"}
@@ -489,6 +497,7 @@ func TestFetchBalance(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	for _, k := range []struct {
 		exchangeName string
@@ -532,6 +541,7 @@ func TestOpenOrders(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	for _, k := range []struct {
 		exchangeName string
@@ -598,6 +608,7 @@ func TestCreateLimitOrder(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	apiKey := api.ExchangeAPIKey{}
 	for _, k := range []struct {
@@ -689,6 +700,7 @@ func TestCancelOrder(t *testing.T) {
 	if testing.Short() {
 		return
 	}
+	ccxtfake.SkipIfUnreachable(t, GetBaseURL())
 
 	apiKey := api.ExchangeAPIKey{}
 	for _, k := range []struct {
@@ -782,3 +794,180 @@ func validateCanceledOrder(t *testing.T, openOrder *CcxtOpenOrder, tradingPair s
 
 	return true
 }
+
+func TestCcxtFake_MarketData(t *testing.T) {
+	s, stop := ccxtfake.StartServer("binance", GetBaseURL, SetBaseURL)
+	defer stop()
+
+	c, e := MakeInitializedCcxtExchange("binance", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, int64(100), c.GetRateLimitMillis())
+	if market := c.GetMarket("XLM/USDT"); assert.NotNil(t, market) {
+		assert.Equal(t, int8(5), market.Precision.Price)
+		assert.Equal(t, int8(1), market.Precision.Amount)
+		assert.Equal(t, 1.0, market.Limits.Cost.Min)
+	}
+
+	for _, o := range []struct {
+		side   string
+		price  float64
+		amount float64
+	}{
+		{"sell", 0.12, 100},
+		{"sell", 0.12, 50},
+		{"sell", 0.13, 10},
+		{"buy", 0.10, 40},
+		{"buy", 0.09, 30},
+		// trades against the first ask
+		{"buy", 0.12, 25},
+	} {
+		_, e = s.PlaceOrder("binance", "XLM/USDT", o.side, o.price, o.amount)
+		if !assert.NoError(t, e) {
+			return
+		}
+	}
+
+	ticker, e := c.FetchTicker("XLM/USDT")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0.12, ticker["ask"])
+	assert.Equal(t, 0.10, ticker["bid"])
+	assert.Equal(t, 0.12, ticker["last"])
+
+	_, e = c.FetchTicker("XLM/BTC")
+	assert.Error(t, e)
+
+	limit := 1
+	ob, e := c.FetchOrderBook("XLM/USDT", &limit)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, []CcxtOrder{{Price: 0.12, Amount: 125}}, ob["asks"])
+	assert.Equal(t, []CcxtOrder{{Price: 0.10, Amount: 40}}, ob["bids"])
+
+	trades, e := c.FetchTrades("XLM/USDT")
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(trades)) {
+		assert.Equal(t, "buy", trades[0].Side)
+		assert.Equal(t, 0.12, trades[0].Price)
+		assert.Equal(t, 25.0, trades[0].Amount)
+	}
+}
+
+func TestCcxtFake_Trading(t *testing.T) {
+	s, stop := ccxtfake.StartServer("binance", GetBaseURL, SetBaseURL)
+	defer stop()
+	s.SetBalance("binance", "XLM", 1000)
+	s.SetBalance("binance", "USDT", 50)
+
+	c, e := MakeInitializedCcxtExchange("binance", api.ExchangeAPIKey{Key: "key", Secret: "secret"}, []api.ExchangeParam{}, []api.ExchangeHeader{})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	sellOrder, e := c.CreateLimitOrder("XLM/USDT", "sell", 100, 0.2, map[string]interface{}{"newClientOrderId": "cid1"})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "cid1", sellOrder.ClientOrderID)
+	assert.Equal(t, "open", sellOrder.Status)
+
+	_, e = c.CreateLimitOrder("XLM/USDT", "buy", 1000, 0.1, nil)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "InsufficientFunds")
+	}
+	buyOrder, e := c.CreateLimitOrder("XLM/USDT", "buy", 100, 0.1, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	openOrders, e := c.FetchOpenOrders([]string{"XLM/USDT"})
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, len(openOrders["XLM/USDT"]))
+
+	balances, e := c.FetchBalance()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, CcxtBalance{Total: 1000, Used: 100, Free: 900}, balances["XLM"])
+	assert.Equal(t, CcxtBalance{Total: 50, Used: 10, Free: 40}, balances["USDT"])
+
+	// another participant takes part of our sell order
+	_, e = s.PlaceOrder("binance", "XLM/USDT", "buy", 0.2, 40)
+	if !assert.NoError(t, e) {
+		return
+	}
+	myTrades, e := c.FetchMyTrades("XLM/USDT", 50, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(myTrades)) {
+		assert.Equal(t, "sell", myTrades[0].Side)
+		assert.Equal(t, 40.0, myTrades[0].Amount)
+		assert.Equal(t, 8.0, myTrades[0].Cost)
+		assert.Equal(t, "USDT", myTrades[0].Fee.Currency)
+		info := myTrades[0].Info.(map[string]interface{})
+		assert.Equal(t, sellOrder.ID, fmt.Sprintf("%.0f", info["orderId"]))
+		assert.Equal(t, "cid1", info["clientOrderId"])
+	}
+	myTrades, e = c.FetchMyTrades("XLM/USDT", 50, myTrades[0].Timestamp+1)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 0, len(myTrades))
+
+	canceled, e := c.CancelOrder(buyOrder.ID, "XLM/USDT")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "canceled", canceled.Status)
+	_, e = c.CancelOrder(buyOrder.ID, "XLM/USDT")
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "OrderNotFound")
+	}
+
+	openOrders, e = c.FetchOpenOrders([]string{"XLM/USDT"})
+	if !assert.NoError(t, e) {
+		return
+	}
+	if assert.Equal(t, 1, len(openOrders["XLM/USDT"])) {
+		assert.Equal(t, sellOrder.ID, openOrders["XLM/USDT"][0].ID)
+		assert.Equal(t, 40.0, openOrders["XLM/USDT"][0].Filled)
+	}
+}
+
+func TestCcxtFake_FailNext(t *testing.T) {
+	s, stop := ccxtfake.StartServer("binance", GetBaseURL, SetBaseURL)
+	defer stop()
+	s.SetBalance("binance", "XLM", 1000)
+
+	c, e := MakeInitializedCcxtExchange("binance", api.ExchangeAPIKey{}, []api.ExchangeParam{}, []api.ExchangeHeader{})
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	s.FailNext("binance", "createOrder", "ExchangeNotAvailable: binance is down")
+	_, e = c.CreateLimitOrder("XLM/USDT", "sell", 100, 0.2, nil)
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "ExchangeNotAvailable")
+	}
+
+	s.FailNextAfterApplying("binance", "createOrder", "RequestTimeout: binance timed out")
+	_, e = c.CreateLimitOrder("XLM/USDT", "sell", 100, 0.2, nil)
+	assert.Error(t, e)
+
+	openOrders, e := c.FetchOpenOrders([]string{"XLM/USDT"})
+	if !assert.NoError(t, e) {
+		return
+	}
+	// only the order that failed after it was applied is open
+	assert.Equal(t, 1, len(openOrders["XLM/USDT"]))
+	assert.Equal(t, 2, s.CallCount("binance", "createOrder"))
+}
@@ -0,0 +1,383 @@
+package ccxtfake
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// epsilon is the smallest amount that we consider to be left on an order, anything smaller is treated as fully filled
+const epsilon = 1e-12
+
+// Market describes a market listed on a fake exchange, the fields map to the unified market structure of ccxt
+type Market struct {
+	Symbol          string
+	Base            string
+	Quote           string
+	PricePrecision  int8
+	AmountPrecision int8
+	MinAmount       float64
+	MinPrice        float64
+	MinCost         float64
+	MakerFee        float64 // fraction of the cost charged to the maker, paid in the quote asset
+	TakerFee        float64 // fraction of the cost charged to the taker, paid in the quote asset
+}
+
+// order is an order placed on the fake exchange, either by the account (via the API) or by the test (to provide liquidity)
+type order struct {
+	id            int64
+	clientOrderID string
+	symbol        string
+	side          string
+	price         float64
+	amount        float64
+	filled        float64
+	cost          float64
+	isAccount     bool
+	timestamp     int64
+	status        string
+}
+
+func (o *order) remaining() float64 {
+	return o.amount - o.filled
+}
+
+func (o *order) isBuy() bool {
+	return o.side == "buy"
+}
+
+// crosses returns true if this order can be matched against the resting order
+func (o *order) crosses(resting *order) bool {
+	if o.isBuy() {
+		return o.price >= resting.price
+	}
+	return o.price <= resting.price
+}
+
+// trade is one side of a fill, public trades use the side of the taker
+type trade struct {
+	id            int64
+	orderID       int64
+	clientOrderID string
+	symbol        string
+	side          string
+	price         float64
+	amount        float64
+	fee           float64
+	feeCurrency   string
+	takerOrMaker  string
+	timestamp     int64
+}
+
+// book holds the resting orders of a market, bids are sorted by descending price and asks by ascending price (FIFO within a price level)
+type book struct {
+	bids []*order
+	asks []*order
+}
+
+func (b *book) side(isBuy bool) *[]*order {
+	if isBuy {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+func (b *book) insert(o *order) {
+	orders := b.side(o.isBuy())
+	i := sort.Search(len(*orders), func(i int) bool {
+		if o.isBuy() {
+			return (*orders)[i].price < o.price
+		}
+		return (*orders)[i].price > o.price
+	})
+	*orders = append(*orders, nil)
+	copy((*orders)[i+1:], (*orders)[i:])
+	(*orders)[i] = o
+}
+
+func (b *book) remove(o *order) {
+	orders := b.side(o.isBuy())
+	for i, resting := range *orders {
+		if resting == o {
+			*orders = append((*orders)[:i], (*orders)[i+1:]...)
+			return
+		}
+	}
+}
+
+// exchange is the in-memory state of a fake exchange with a single account that is shared by all its instances
+type exchange struct {
+	name         string
+	rateLimit    int64
+	instances    []string
+	markets      map[string]Market
+	books        map[string]*book
+	orders       map[int64]*order
+	balances     map[string]float64 // total balance of the account by currency
+	publicTrades map[string][]*trade
+	myTrades     []*trade
+	lastID       int64
+}
+
+func makeExchange(name string, rateLimitMillis int64) *exchange {
+	return &exchange{
+		name:         name,
+		rateLimit:    rateLimitMillis,
+		instances:    []string{},
+		markets:      map[string]Market{},
+		books:        map[string]*book{},
+		orders:       map[int64]*order{},
+		balances:     map[string]float64{},
+		publicTrades: map[string][]*trade{},
+		myTrades:     []*trade{},
+	}
+}
+
+func (x *exchange) nextID() int64 {
+	x.lastID++
+	return x.lastID
+}
+
+func (x *exchange) addMarket(m Market) {
+	x.markets[m.Symbol] = m
+	if _, ok := x.books[m.Symbol]; !ok {
+		x.books[m.Symbol] = &book{}
+	}
+}
+
+func (x *exchange) symbols() []string {
+	symbols := []string{}
+	for s := range x.markets {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// currencies returns all the currencies of the listed markets and of the balances
+func (x *exchange) currencies() []string {
+	m := map[string]bool{}
+	for _, market := range x.markets {
+		m[market.Base] = true
+		m[market.Quote] = true
+	}
+	for c := range x.balances {
+		m[c] = true
+	}
+
+	currencies := []string{}
+	for c := range m {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// used returns the amount of the currency locked in open orders of the account
+func (x *exchange) used(currency string) float64 {
+	used := 0.0
+	for _, o := range x.orders {
+		if !o.isAccount || o.status != "open" {
+			continue
+		}
+
+		market := x.markets[o.symbol]
+		if o.isBuy() && market.Quote == currency {
+			used += o.remaining() * o.price
+		} else if !o.isBuy() && market.Base == currency {
+			used += o.remaining()
+		}
+	}
+	return used
+}
+
+func (x *exchange) free(currency string) float64 {
+	return x.balances[currency] - x.used(currency)
+}
+
+// placeOrder validates the order and matches it against the book, any remaining amount rests on the book
+func (x *exchange) placeOrder(symbol string, side string, price float64, amount float64, clientOrderID string, postOnly bool, isAccount bool, now int64) (*order, error) {
+	market, ok := x.markets[symbol]
+	if !ok {
+		return nil, fmt.Errorf("BadSymbol: %s does not have market symbol %s", x.name, symbol)
+	}
+	if side != "buy" && side != "sell" {
+		return nil, fmt.Errorf("InvalidOrder: invalid side '%s'", side)
+	}
+	if price <= 0 || price < market.MinPrice {
+		return nil, fmt.Errorf("InvalidOrder: price %f is below the minimum price %f for %s", price, market.MinPrice, symbol)
+	}
+	if amount <= 0 || amount < market.MinAmount {
+		return nil, fmt.Errorf("InvalidOrder: amount %f is below the minimum amount %f for %s", amount, market.MinAmount, symbol)
+	}
+	if price*amount < market.MinCost {
+		return nil, fmt.Errorf("InvalidOrder: cost %f is below the minimum cost %f for %s", price*amount, market.MinCost, symbol)
+	}
+
+	o := &order{
+		clientOrderID: clientOrderID,
+		symbol:        symbol,
+		side:          side,
+		price:         price,
+		amount:        amount,
+		isAccount:     isAccount,
+		timestamp:     now,
+		status:        "open",
+	}
+	if isAccount {
+		currency, required := market.Base, amount
+		if o.isBuy() {
+			currency, required = market.Quote, amount*price
+		}
+		if free := x.free(currency); free+epsilon < required {
+			return nil, fmt.Errorf("InsufficientFunds: need %f %s but only %f is free", required, currency, free)
+		}
+	}
+
+	b := x.books[symbol]
+	opposite := b.side(!o.isBuy())
+	if postOnly && len(*opposite) > 0 && o.crosses((*opposite)[0]) {
+		return nil, fmt.Errorf("OrderImmediatelyFillable: post only order would match against the book at price %f", (*opposite)[0].price)
+	}
+
+	o.id = x.nextID()
+	x.orders[o.id] = o
+	for len(*opposite) > 0 && o.remaining() > epsilon && o.crosses((*opposite)[0]) {
+		maker := (*opposite)[0]
+		x.fill(market, maker, o, math.Min(maker.remaining(), o.remaining()), now)
+		if maker.remaining() <= epsilon {
+			maker.status = "closed"
+			*opposite = (*opposite)[1:]
+		}
+	}
+
+	if o.remaining() <= epsilon {
+		o.status = "closed"
+	} else {
+		b.insert(o)
+	}
+	return o, nil
+}
+
+// fill executes the amount at the price of the maker and updates the trades and balances of the account
+func (x *exchange) fill(market Market, maker *order, taker *order, amount float64, now int64) {
+	price := maker.price
+	cost := price * amount
+	tradeID := x.nextID()
+
+	x.publicTrades[market.Symbol] = append(x.publicTrades[market.Symbol], &trade{
+		id:        tradeID,
+		symbol:    market.Symbol,
+		side:      taker.side,
+		price:     price,
+		amount:    amount,
+		timestamp: now,
+	})
+
+	for _, o := range []*order{maker, taker} {
+		o.filled += amount
+		o.cost += cost
+		if !o.isAccount {
+			continue
+		}
+
+		takerOrMaker, feeRate := "maker", market.MakerFee
+		if o == taker {
+			takerOrMaker, feeRate = "taker", market.TakerFee
+		}
+		fee := cost * feeRate
+		if o.isBuy() {
+			x.balances[market.Base] += amount
+			x.balances[market.Quote] -= cost + fee
+		} else {
+			x.balances[market.Base] -= amount
+			x.balances[market.Quote] += cost - fee
+		}
+
+		x.myTrades = append(x.myTrades, &trade{
+			id:            tradeID,
+			orderID:       o.id,
+			clientOrderID: o.clientOrderID,
+			symbol:        market.Symbol,
+			side:          o.side,
+			price:         price,
+			amount:        amount,
+			fee:           fee,
+			feeCurrency:   market.Quote,
+			takerOrMaker:  takerOrMaker,
+			timestamp:     now,
+		})
+	}
+}
+
+// cancelOrder cancels an open order of the account
+func (x *exchange) cancelOrder(orderID string, symbol string) (*order, error) {
+	id, e := strconv.ParseInt(orderID, 10, 64)
+	if e != nil {
+		return nil, fmt.Errorf("OrderNotFound: invalid order id '%s'", orderID)
+	}
+
+	o, ok := x.orders[id]
+	if !ok || !o.isAccount || o.symbol != symbol {
+		return nil, fmt.Errorf("OrderNotFound: order %s does not exist on %s for symbol %s", orderID, x.name, symbol)
+	}
+	if o.status != "open" {
+		return nil, fmt.Errorf("OrderNotFound: order %s is %s", orderID, o.status)
+	}
+
+	x.books[o.symbol].remove(o)
+	o.status = "canceled"
+	return o, nil
+}
+
+// openOrders returns the open orders of the account in the order in which they were placed, filtered by symbol if not empty
+func (x *exchange) openOrders(symbol string) []*order {
+	result := []*order{}
+	for _, o := range x.orders {
+		if o.isAccount && o.status == "open" && (symbol == "" || o.symbol == symbol) {
+			result = append(result, o)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].id < result[j].id
+	})
+	return result
+}
+
+// filterTrades returns the trades with a timestamp >= since (when > 0), at most limit trades (when > 0)
+func filterTrades(trades []*trade, symbol string, since int64, limit int) []*trade {
+	result := []*trade{}
+	for _, t := range trades {
+		if symbol != "" && t.symbol != symbol {
+			continue
+		}
+		if since > 0 && t.timestamp < since {
+			continue
+		}
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// priceLevel is an aggregated level of the book as returned by fetchOrderBook
+type priceLevel [2]float64
+
+// levels aggregates the orders by price, returning at most limit levels (when > 0)
+func levels(orders []*order, limit int) []priceLevel {
+	result := []priceLevel{}
+	for _, o := range orders {
+		if len(result) > 0 && result[len(result)-1][0] == o.price {
+			result[len(result)-1][1] += o.remaining()
+			continue
+		}
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, priceLevel{o.price, o.remaining()})
+	}
+	return result
+}
@@ -0,0 +1,250 @@
+package ccxtfake
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMarket = Market{
+	Symbol:          "XLM/USDT",
+	Base:            "XLM",
+	Quote:           "USDT",
+	PricePrecision:  5,
+	AmountPrecision: 1,
+	MinAmount:       1,
+	MakerFee:        0.001,
+	TakerFee:        0.002,
+}
+
+func makeTestExchange() *exchange {
+	x := makeExchange("binance", 100)
+	x.addMarket(testMarket)
+	x.balances["XLM"] = 1000
+	x.balances["USDT"] = 100
+	return x
+}
+
+func TestPlaceOrder_PriceTimePriority(t *testing.T) {
+	x := makeTestExchange()
+	for _, o := range []struct {
+		price  float64
+		amount float64
+	}{
+		{0.12, 10},
+		{0.11, 20},
+		{0.11, 30},
+	} {
+		_, e := x.placeOrder("XLM/USDT", "sell", o.price, o.amount, "", false, false, 1)
+		if !assert.NoError(t, e) {
+			return
+		}
+	}
+
+	// takes all of the first order at 0.11 and part of the second one, never reaching 0.12
+	taker, e := x.placeOrder("XLM/USDT", "buy", 0.115, 25, "", false, false, 2)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	assert.Equal(t, "closed", taker.status)
+	assert.InDelta(t, 25*0.11, taker.cost, epsilon)
+	assert.Equal(t, []priceLevel{{0.11, 25}, {0.12, 10}}, levels(x.books["XLM/USDT"].asks, 0))
+	assert.Equal(t, 0, len(x.books["XLM/USDT"].bids))
+	if assert.Equal(t, 2, len(x.publicTrades["XLM/USDT"])) {
+		assert.Equal(t, 20.0, x.publicTrades["XLM/USDT"][0].amount)
+		assert.Equal(t, 5.0, x.publicTrades["XLM/USDT"][1].amount)
+		assert.Equal(t, "buy", x.publicTrades["XLM/USDT"][1].side)
+	}
+	// none of the orders belonged to the account
+	assert.Equal(t, 0, len(x.myTrades))
+}
+
+func TestPlaceOrder_AccountFills(t *testing.T) {
+	x := makeTestExchange()
+	maker, e := x.placeOrder("XLM/USDT", "buy", 0.1, 200, "cid1", false, true, 1)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 20.0, x.used("USDT"))
+	assert.Equal(t, 80.0, x.free("USDT"))
+
+	// partially fills the account's order as the maker
+	_, e = x.placeOrder("XLM/USDT", "sell", 0.09, 50, "", false, false, 2)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	assert.Equal(t, "open", maker.status)
+	assert.Equal(t, 150.0, maker.remaining())
+	assert.InDelta(t, 1050, x.balances["XLM"], epsilon)
+	assert.InDelta(t, 100-5-0.005, x.balances["USDT"], epsilon)
+	assert.InDelta(t, 15, x.used("USDT"), epsilon)
+	if assert.Equal(t, 1, len(x.myTrades)) {
+		trade := x.myTrades[0]
+		assert.Equal(t, maker.id, trade.orderID)
+		assert.Equal(t, "cid1", trade.clientOrderID)
+		assert.Equal(t, "buy", trade.side)
+		assert.Equal(t, "maker", trade.takerOrMaker)
+		assert.Equal(t, 0.1, trade.price)
+		assert.InDelta(t, 0.005, trade.fee, epsilon)
+	}
+
+	// taking our own resting order records both sides
+	_, e = x.placeOrder("XLM/USDT", "sell", 0.1, 150, "cid2", false, true, 3)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "closed", maker.status)
+	assert.Equal(t, 3, len(x.myTrades))
+	assert.Equal(t, "taker", x.myTrades[2].takerOrMaker)
+	assert.Equal(t, "cid2", x.myTrades[2].clientOrderID)
+	assert.InDelta(t, 1050, x.balances["XLM"], epsilon)
+	assert.Equal(t, 0, len(x.openOrders("")))
+}
+
+func TestPlaceOrder_Errors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		symbol    string
+		side      string
+		price     float64
+		amount    float64
+		postOnly  bool
+		isAccount bool
+		wantError string
+	}{
+		{
+			name:      "unknown symbol",
+			symbol:    "XLM/BTC",
+			side:      "sell",
+			price:     0.2,
+			amount:    10,
+			wantError: "BadSymbol",
+		}, {
+			name:      "below min amount",
+			symbol:    "XLM/USDT",
+			side:      "sell",
+			price:     0.2,
+			amount:    0.5,
+			wantError: "InvalidOrder",
+		}, {
+			name:      "insufficient base",
+			symbol:    "XLM/USDT",
+			side:      "sell",
+			price:     0.2,
+			amount:    1001,
+			isAccount: true,
+			wantError: "InsufficientFunds",
+		}, {
+			name:      "insufficient quote",
+			symbol:    "XLM/USDT",
+			side:      "buy",
+			price:     0.11,
+			amount:    1000,
+			isAccount: true,
+			wantError: "InsufficientFunds",
+		}, {
+			name:      "post only crosses the book",
+			symbol:    "XLM/USDT",
+			side:      "buy",
+			price:     0.15,
+			amount:    10,
+			postOnly:  true,
+			isAccount: true,
+			wantError: "OrderImmediatelyFillable",
+		}, {
+			name:      "post only below the book",
+			symbol:    "XLM/USDT",
+			side:      "buy",
+			price:     0.14,
+			amount:    10,
+			postOnly:  true,
+			isAccount: true,
+			wantError: "",
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			x := makeTestExchange()
+			_, e := x.placeOrder("XLM/USDT", "sell", 0.15, 100, "", false, false, 1)
+			if !assert.NoError(t, e) {
+				return
+			}
+
+			_, e = x.placeOrder(k.symbol, k.side, k.price, k.amount, "", k.postOnly, k.isAccount, 2)
+			if k.wantError == "" {
+				assert.NoError(t, e)
+				return
+			}
+			if assert.Error(t, e) {
+				assert.True(t, strings.HasPrefix(e.Error(), k.wantError), e.Error())
+			}
+			// the book is unchanged
+			assert.Equal(t, []priceLevel{{0.15, 100}}, levels(x.books["XLM/USDT"].asks, 0))
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	x := makeTestExchange()
+	o, e := x.placeOrder("XLM/USDT", "sell", 0.2, 100, "", false, true, 1)
+	if !assert.NoError(t, e) {
+		return
+	}
+	external, e := x.placeOrder("XLM/USDT", "sell", 0.2, 100, "", false, false, 1)
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	_, e = x.cancelOrder("42", "XLM/USDT")
+	assert.Error(t, e)
+	_, e = x.cancelOrder(strconv.FormatInt(external.id, 10), "XLM/USDT")
+	assert.Error(t, e, "cannot cancel orders of other participants")
+	_, e = x.cancelOrder(strconv.FormatInt(o.id, 10), "XLM/BTC")
+	assert.Error(t, e)
+
+	canceled, e := x.cancelOrder(strconv.FormatInt(o.id, 10), "XLM/USDT")
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "canceled", canceled.status)
+	assert.Equal(t, 0.0, x.used("XLM"))
+	assert.Equal(t, []priceLevel{{0.2, 100}}, levels(x.books["XLM/USDT"].asks, 0))
+
+	_, e = x.cancelOrder(strconv.FormatInt(o.id, 10), "XLM/USDT")
+	assert.Error(t, e, "cannot cancel an order twice")
+}
+
+func TestFilterTrades(t *testing.T) {
+	trades := []*trade{
+		{id: 1, symbol: "XLM/USDT", timestamp: 10},
+		{id: 2, symbol: "XLM/BTC", timestamp: 20},
+		{id: 3, symbol: "XLM/USDT", timestamp: 30},
+		{id: 4, symbol: "XLM/USDT", timestamp: 40},
+	}
+
+	testCases := []struct {
+		symbol  string
+		since   int64
+		limit   int
+		wantIDs []int64
+	}{
+		{"", 0, 0, []int64{1, 2, 3, 4}},
+		{"XLM/USDT", 0, 0, []int64{1, 3, 4}},
+		{"XLM/USDT", 30, 0, []int64{3, 4}},
+		{"XLM/USDT", 11, 1, []int64{3}},
+		{"XLM/USDT", 41, 0, []int64{}},
+	}
+
+	for _, k := range testCases {
+		ids := []int64{}
+		for _, trade := range filterTrades(trades, k.symbol, k.since, k.limit) {
+			ids = append(ids, trade.id)
+		}
+		assert.Equal(t, k.wantIDs, ids)
+	}
+}
@@ -0,0 +1,548 @@
+// Package ccxtfake provides an in-process fake of the subset of the ccxt-rest API (https://github.com/franz-see/ccxt-rest) used by sdk.Ccxt
+// so that tests of the sdk and of the exchange plugins can run without a ccxt-rest instance or a connection to the real exchanges.
+package ccxtfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clientOrderIDParams are the exchange-specific params that are used to send a client order ID when creating an order
+var clientOrderIDParams = []string{"clientOrderId", "newClientOrderId", "client_oid", "client_order_id"}
+
+// failure is a scripted error returned for the next call to a method
+type failure struct {
+	message       string
+	afterApplying bool
+}
+
+// Server is a fake ccxt-rest server backed by an in-memory matching engine for each exchange.
+//
+// Each exchange has a single account that is shared by all its instances. Orders created via the API belong to the account,
+// orders added with PlaceOrder represent the rest of the market and provide liquidity to (or take liquidity from) the account.
+type Server struct {
+	// URL is the base URL of the server, pass this to sdk.SetBaseURL
+	URL string
+
+	httpServer *httptest.Server
+	lock       *sync.Mutex
+	exchanges  map[string]*exchange
+	failures   map[string][]failure // exchange/method -> failures
+	calls      map[string]int       // exchange/method -> number of calls
+	nowFn      func() time.Time
+}
+
+var _ http.Handler = &Server{}
+
+// MakeServer starts a fake ccxt-rest server on a local port, callers should call Close when done
+func MakeServer() *Server {
+	s := &Server{
+		lock:      &sync.Mutex{},
+		exchanges: map[string]*exchange{},
+		failures:  map[string][]failure{},
+		calls:     map[string]int{},
+		nowFn:     time.Now,
+	}
+	s.httpServer = httptest.NewServer(s)
+	s.URL = s.httpServer.URL
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// SetNowFn sets the clock used to timestamp orders and trades
+func (s *Server) SetNowFn(nowFn func() time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nowFn = nowFn
+}
+
+// AddExchange lists a new exchange on the server
+func (s *Server) AddExchange(exchangeName string, rateLimitMillis int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.exchanges[exchangeName] = makeExchange(exchangeName, rateLimitMillis)
+}
+
+// AddMarket lists a market on the exchange
+func (s *Server) AddMarket(exchangeName string, market Market) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustGetExchange(exchangeName).addMarket(market)
+}
+
+// SetBalance sets the total balance of the account for the currency
+func (s *Server) SetBalance(exchangeName string, currency string, total float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mustGetExchange(exchangeName).balances[currency] = total
+}
+
+// PlaceOrder places a limit order from another participant of the market, it matches against the open orders of the account.
+// Returns the ID of the order.
+func (s *Server) PlaceOrder(exchangeName string, symbol string, side string, price float64, amount float64) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	o, e := s.mustGetExchange(exchangeName).placeOrder(symbol, side, price, amount, "", false, false, s.nowMillis())
+	if e != nil {
+		return "", e
+	}
+	return strconv.FormatInt(o.id, 10), nil
+}
+
+// FailNext makes the next call to the method on the exchange fail with the message without changing any state
+func (s *Server) FailNext(exchangeName string, method string, message string) {
+	s.addFailure(exchangeName, method, failure{message: message, afterApplying: false})
+}
+
+// FailNextAfterApplying makes the next call to the method on the exchange fail with the message after the call has been applied,
+// this simulates errors where the request reached the exchange but the response was lost
+func (s *Server) FailNextAfterApplying(exchangeName string, method string, message string) {
+	s.addFailure(exchangeName, method, failure{message: message, afterApplying: true})
+}
+
+func (s *Server) addFailure(exchangeName string, method string, f failure) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := exchangeName + "/" + method
+	s.failures[key] = append(s.failures[key], f)
+}
+
+// CallCount returns the number of calls made to the method on the exchange, including failed calls
+func (s *Server) CallCount(exchangeName string, method string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls[exchangeName+"/"+method]
+}
+
+func (s *Server) mustGetExchange(exchangeName string) *exchange {
+	x, ok := s.exchanges[exchangeName]
+	if !ok {
+		panic(fmt.Errorf("exchange '%s' has not been added to the fake ccxt server", exchangeName))
+	}
+	return x
+}
+
+func (s *Server) nowMillis() int64 {
+	return s.nowFn().UnixNano() / int64(time.Millisecond)
+}
+
+// ServeHTTP impl, routes the ccxt-rest paths: GET /exchanges, GET and POST /exchanges/{exchange},
+// GET /exchanges/{exchange}/{instance} and POST /exchanges/{exchange}/{instance}/{method}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	body, e := ioutil.ReadAll(r.Body)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not read request body: %s", e))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "exchanges" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+
+	if len(parts) == 1 {
+		names := []string{}
+		for name := range s.exchanges {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+
+	x, ok := s.exchanges[parts[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("ExchangeNotFound: exchange '%s' is not supported", parts[1]))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, x.instances)
+	case len(parts) == 2 && r.Method == "POST":
+		s.createInstance(w, x, body)
+	case len(parts) == 3 && r.Method == "GET":
+		if !x.hasInstance(parts[2]) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("instance '%s' does not exist for exchange '%s'", parts[2], x.name))
+			return
+		}
+		writeJSON(w, http.StatusOK, x.details(parts[2]))
+	case len(parts) == 4 && r.Method == "POST":
+		if !x.hasInstance(parts[2]) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("instance '%s' does not exist for exchange '%s'", parts[2], x.name))
+			return
+		}
+		s.callMethod(w, x, parts[3], body)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) createInstance(w http.ResponseWriter, x *exchange, body []byte) {
+	var params map[string]interface{}
+	e := json.Unmarshal(body, &params)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not parse params of new instance: %s", e))
+		return
+	}
+
+	id, ok := params["id"].(string)
+	if !ok || id == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("'id' is required when creating a new instance"))
+		return
+	}
+	if x.hasInstance(id) {
+		writeError(w, http.StatusConflict, fmt.Errorf("instance '%s' already exists for exchange '%s'", id, x.name))
+		return
+	}
+
+	x.instances = append(x.instances, id)
+	writeJSON(w, http.StatusOK, x.details(id))
+}
+
+func (x *exchange) hasInstance(id string) bool {
+	for _, i := range x.instances {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *exchange) details(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        x.name,
+		"name":      x.name,
+		"instance":  id,
+		"rateLimit": x.rateLimit,
+		"symbols":   x.symbols(),
+		"urls":      map[string]interface{}{},
+	}
+}
+
+func (s *Server) callMethod(w http.ResponseWriter, x *exchange, method string, body []byte) {
+	key := x.name + "/" + method
+	s.calls[key]++
+
+	var f *failure
+	if failures := s.failures[key]; len(failures) > 0 {
+		f = &failures[0]
+		s.failures[key] = failures[1:]
+		if !f.afterApplying {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("%s", f.message))
+			return
+		}
+	}
+
+	args := []interface{}{}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		e := json.Unmarshal(body, &args)
+		if e != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("could not parse the arguments of %s as a JSON array: %s", method, e))
+			return
+		}
+	}
+
+	result, e := s.dispatch(x, method, args)
+	if f != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("%s", f.message))
+		return
+	}
+	if e != nil {
+		writeError(w, http.StatusBadRequest, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// dispatch calls the method with positional args, following the signatures of the unified ccxt API
+func (s *Server) dispatch(x *exchange, method string, args []interface{}) (interface{}, error) {
+	switch method {
+	case "loadMarkets":
+		return x.marketsJSON(), nil
+	case "fetchTicker":
+		symbol, e := requiredSymbol(x, args, 0)
+		if e != nil {
+			return nil, e
+		}
+		return x.tickerJSON(symbol), nil
+	case "fetchOrderBook":
+		symbol, e := requiredSymbol(x, args, 0)
+		if e != nil {
+			return nil, e
+		}
+		b := x.books[symbol]
+		limit := int(argFloat(args, 1))
+		return map[string]interface{}{
+			"symbol":    symbol,
+			"bids":      levels(b.bids, limit),
+			"asks":      levels(b.asks, limit),
+			"timestamp": s.nowMillis(),
+			"datetime":  datetime(s.nowMillis()),
+		}, nil
+	case "fetchTrades":
+		symbol, e := requiredSymbol(x, args, 0)
+		if e != nil {
+			return nil, e
+		}
+		return tradesJSON(filterTrades(x.publicTrades[symbol], symbol, int64(argFloat(args, 1)), int(argFloat(args, 2)))), nil
+	case "fetchMyTrades":
+		return tradesJSON(filterTrades(x.myTrades, argString(args, 0), int64(argFloat(args, 1)), int(argFloat(args, 2)))), nil
+	case "fetchBalance":
+		return x.balanceJSON(), nil
+	case "fetchOpenOrders":
+		orders := []interface{}{}
+		for _, o := range x.openOrders(argString(args, 0)) {
+			orders = append(orders, orderJSON(o))
+		}
+		return orders, nil
+	case "createOrder":
+		return s.createOrder(x, args)
+	case "cancelOrder":
+		o, e := x.cancelOrder(argString(args, 0), argString(args, 1))
+		if e != nil {
+			return nil, e
+		}
+		return orderJSON(o), nil
+	default:
+		return nil, fmt.Errorf("NotSupported: %s is not supported by the fake ccxt server", method)
+	}
+}
+
+// createOrder takes the args (symbol, type, side, amount, price, params)
+func (s *Server) createOrder(x *exchange, args []interface{}) (interface{}, error) {
+	symbol, e := requiredSymbol(x, args, 0)
+	if e != nil {
+		return nil, e
+	}
+	if orderType := argString(args, 1); orderType != "limit" {
+		return nil, fmt.Errorf("NotSupported: order type '%s' is not supported, only 'limit' orders are supported", orderType)
+	}
+
+	params := map[string]interface{}{}
+	if len(args) > 5 {
+		if m, ok := args[5].(map[string]interface{}); ok {
+			params = m
+		}
+	}
+	clientOrderID := ""
+	for _, k := range clientOrderIDParams {
+		if v, ok := params[k].(string); ok && v != "" {
+			clientOrderID = v
+			break
+		}
+	}
+	postOnly := params["postOnly"] == true || params["post_only"] == true || params["type"] == "LIMIT_MAKER" || params["timeInForce"] == "PO"
+
+	o, e := x.placeOrder(symbol, argString(args, 2), argFloat(args, 4), argFloat(args, 3), clientOrderID, postOnly, true, s.nowMillis())
+	if e != nil {
+		return nil, e
+	}
+	log.Printf("fake ccxt placed order on %s: id=%d, symbol=%s, side=%s, price=%f, amount=%f, filled=%f, clientOrderID=%s\n",
+		x.name, o.id, o.symbol, o.side, o.price, o.amount, o.filled, o.clientOrderID)
+	return orderJSON(o), nil
+}
+
+func requiredSymbol(x *exchange, args []interface{}, i int) (string, error) {
+	symbol := argString(args, i)
+	if _, ok := x.markets[symbol]; !ok {
+		return "", fmt.Errorf("BadSymbol: %s does not have market symbol '%s'", x.name, symbol)
+	}
+	return symbol, nil
+}
+
+// argString returns the arg at index i as a string, or an empty string if it is missing
+func argString(args []interface{}, i int) string {
+	if i >= len(args) || args[i] == nil {
+		return ""
+	}
+	if v, ok := args[i].(string); ok {
+		return v
+	}
+	return fmt.Sprintf("%v", args[i])
+}
+
+// argFloat returns the arg at index i as a float64, ccxt-rest accepts numbers as strings too; returns 0 if it is missing or not a number
+func argFloat(args []interface{}, i int) float64 {
+	if i >= len(args) {
+		return 0
+	}
+	switch v := args[i].(type) {
+	case float64:
+		return v
+	case string:
+		f, e := strconv.ParseFloat(v, 64)
+		if e != nil {
+			return 0
+		}
+		return f
+	default:
+		return 0
+	}
+}
+
+func (x *exchange) marketsJSON() map[string]interface{} {
+	result := map[string]interface{}{}
+	for symbol, m := range x.markets {
+		result[symbol] = map[string]interface{}{
+			"id":     strings.Replace(symbol, "/", "", -1),
+			"symbol": symbol,
+			"base":   m.Base,
+			"quote":  m.Quote,
+			"active": true,
+			"maker":  m.MakerFee,
+			"taker":  m.TakerFee,
+			"precision": map[string]interface{}{
+				"amount": m.AmountPrecision,
+				"price":  m.PricePrecision,
+			},
+			"limits": map[string]interface{}{
+				"amount": map[string]interface{}{"min": m.MinAmount},
+				"price":  map[string]interface{}{"min": m.MinPrice},
+				"cost":   map[string]interface{}{"min": m.MinCost},
+			},
+		}
+	}
+	return result
+}
+
+// tickerJSON uses the top of the book for the bid and ask (null when empty) and the last public trade for the last price
+func (x *exchange) tickerJSON(symbol string) map[string]interface{} {
+	b := x.books[symbol]
+	ticker := map[string]interface{}{
+		"symbol": symbol,
+		"bid":    nil,
+		"ask":    nil,
+		"last":   nil,
+	}
+	if len(b.bids) > 0 {
+		ticker["bid"] = b.bids[0].price
+	}
+	if len(b.asks) > 0 {
+		ticker["ask"] = b.asks[0].price
+	}
+	if trades := x.publicTrades[symbol]; len(trades) > 0 {
+		last := trades[len(trades)-1]
+		ticker["last"] = last.price
+		ticker["timestamp"] = last.timestamp
+		ticker["datetime"] = datetime(last.timestamp)
+	}
+	return ticker
+}
+
+func (x *exchange) balanceJSON() map[string]interface{} {
+	free := map[string]interface{}{}
+	used := map[string]interface{}{}
+	total := map[string]interface{}{}
+	result := map[string]interface{}{
+		"info":  map[string]interface{}{},
+		"free":  free,
+		"used":  used,
+		"total": total,
+	}
+	for _, c := range x.currencies() {
+		free[c] = x.free(c)
+		used[c] = x.used(c)
+		total[c] = x.balances[c]
+		result[c] = map[string]interface{}{
+			"free":  free[c],
+			"used":  used[c],
+			"total": total[c],
+		}
+	}
+	return result
+}
+
+func orderJSON(o *order) map[string]interface{} {
+	id := strconv.FormatInt(o.id, 10)
+	return map[string]interface{}{
+		"id":            id,
+		"clientOrderId": o.clientOrderID,
+		"timestamp":     o.timestamp,
+		"datetime":      datetime(o.timestamp),
+		"symbol":        o.symbol,
+		"type":          "limit",
+		"side":          o.side,
+		"price":         o.price,
+		"amount":        o.amount,
+		"cost":          o.cost,
+		"filled":        o.filled,
+		"remaining":     o.remaining(),
+		"status":        o.status,
+		"info": map[string]interface{}{
+			"orderId":       o.id,
+			"clientOrderId": o.clientOrderID,
+		},
+	}
+}
+
+func tradesJSON(trades []*trade) []interface{} {
+	result := []interface{}{}
+	for _, t := range trades {
+		m := map[string]interface{}{
+			"id":        strconv.FormatInt(t.id, 10),
+			"timestamp": t.timestamp,
+			"datetime":  datetime(t.timestamp),
+			"symbol":    t.symbol,
+			"side":      t.side,
+			"price":     t.price,
+			"amount":    t.amount,
+			"cost":      t.price * t.amount,
+			"info": map[string]interface{}{
+				"id": t.id,
+			},
+		}
+		if t.orderID != 0 {
+			m["order"] = strconv.FormatInt(t.orderID, 10)
+			m["takerOrMaker"] = t.takerOrMaker
+			m["fee"] = map[string]interface{}{
+				"cost":     t.fee,
+				"currency": t.feeCurrency,
+			}
+			m["info"] = map[string]interface{}{
+				"id":            t.id,
+				"orderId":       t.orderID,
+				"clientOrderId": t.clientOrderID,
+			}
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func datetime(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	e := json.NewEncoder(w).Encode(v)
+	if e != nil {
+		log.Printf("fake ccxt could not write response: %s\n", e)
+	}
+}
+
+// writeError writes the error in the format of ccxt-rest, the sdk detects errors by the presence of the "error" key
+func writeError(w http.ResponseWriter, status int, e error) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": e.Error(),
+	})
+}
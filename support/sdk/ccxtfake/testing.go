@@ -0,0 +1,53 @@
+package ccxtfake
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// MarketXLMUSDT is the XLM/USDT market listed by StartServer, it uses the precision and limits of binance
+var MarketXLMUSDT = Market{
+	Symbol:          "XLM/USDT",
+	Base:            "XLM",
+	Quote:           "USDT",
+	PricePrecision:  5,
+	AmountPrecision: 1,
+	MinAmount:       1,
+	MinCost:         1,
+	MakerFee:        0.001,
+	TakerFee:        0.001,
+}
+
+// StartServer starts a fake ccxt-rest server listing the exchange with MarketXLMUSDT and points the client at it with setBaseURL.
+// Pass sdk.GetBaseURL and sdk.SetBaseURL, this package cannot import the sdk because the tests of the sdk use it.
+// Callers should defer the returned function to stop the server and point the client back at the previous base URL.
+func StartServer(exchangeName string, getBaseURL func() string, setBaseURL func(string) error) (*Server, func()) {
+	s := MakeServer()
+	s.AddExchange(exchangeName, 100)
+	s.AddMarket(exchangeName, MarketXLMUSDT)
+
+	prevBaseURL := getBaseURL()
+	setBaseURL(s.URL)
+	return s, func() {
+		s.Close()
+		setBaseURL(prevBaseURL)
+	}
+}
+
+// SkipIfUnreachable skips the test when nothing is listening at the base URL, use this in tests that need a real ccxt-rest instance
+func SkipIfUnreachable(t *testing.T, baseURL string) {
+	u, e := url.Parse(baseURL)
+	if e != nil {
+		t.Skipf("skipping test, could not parse the ccxt-rest base URL '%s': %s", baseURL, e)
+		return
+	}
+
+	conn, e := net.DialTimeout("tcp", u.Host, time.Second)
+	if e != nil {
+		t.Skipf("skipping test, ccxt-rest is not reachable at '%s': %s", baseURL, e)
+		return
+	}
+	conn.Close()
+}
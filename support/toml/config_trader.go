@@ -1,6 +1,11 @@
 package toml
 
-import "github.com/stellar/kelp/api"
+import (
+	"fmt"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/support/utils"
+)
 
 // ExchangeAPIKeysToml is the toml representation of ExchangeAPIKeys
 type ExchangeAPIKeysToml []struct {
@@ -19,3 +24,14 @@ func (t *ExchangeAPIKeysToml) ToExchangeAPIKeys() []api.ExchangeAPIKey {
 	}
 	return apiKeys
 }
+
+// ResolveSecrets resolves references to secrets in the keys and secrets in place
+func (t *ExchangeAPIKeysToml) ResolveSecrets(resolve utils.SecretResolver) error {
+	for i := range *t {
+		e := utils.ResolveSecrets(resolve, &(*t)[i].Key, &(*t)[i].Secret)
+		if e != nil {
+			return fmt.Errorf("could not resolve EXCHANGE_API_KEYS at index %d: %s", i, e)
+		}
+	}
+	return nil
+}
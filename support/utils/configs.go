@@ -3,8 +3,11 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -107,3 +110,155 @@ func passthrough(i interface{}) interface{} {
 func Hide(i interface{}) interface{} {
 	return ""
 }
+
+// SecretResolver returns the secret referenced by a config value, or the value itself if it is not a reference
+type SecretResolver func(value string) (string, error)
+
+// ResolveSecrets resolves each of the config values in place
+func ResolveSecrets(resolve SecretResolver, values ...*string) error {
+	for _, v := range values {
+		resolved, e := resolve(*v)
+		if e != nil {
+			return e
+		}
+		*v = resolved
+	}
+	return nil
+}
+
+// ChainSecretResolvers returns a resolver that passes the config value through each of the resolvers in order
+func ChainSecretResolvers(resolvers ...SecretResolver) SecretResolver {
+	return func(value string) (string, error) {
+		for _, resolve := range resolvers {
+			var e error
+			value, e = resolve(value)
+			if e != nil {
+				return "", e
+			}
+		}
+		return value, nil
+	}
+}
+
+// FileRefPrefix is the prefix of config values that reference a file containing the secret, for example "file:/run/secrets/seed"
+const FileRefPrefix = "file:"
+
+// ResolveFileRef is a SecretResolver that reads the secret from the file referenced by the config value, ignoring surrounding whitespace
+func ResolveFileRef(value string) (string, error) {
+	if !strings.HasPrefix(value, FileRefPrefix) {
+		return value, nil
+	}
+
+	filepath := strings.TrimPrefix(value, FileRefPrefix)
+	secretBytes, e := ioutil.ReadFile(filepath)
+	if e != nil {
+		return "", fmt.Errorf("could not read secret from file '%s' referenced in config: %s", filepath, e)
+	}
+	secret := strings.TrimSpace(string(secretBytes))
+	if secret == "" {
+		return "", fmt.Errorf("file '%s' referenced in config is empty", filepath)
+	}
+	return secret, nil
+}
+
+var envRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// IsConfigRef returns true if the config value references a file or an environment variable instead of holding the value itself
+func IsConfigRef(value string) bool {
+	return strings.HasPrefix(value, FileRefPrefix) || envRefRegex.MatchString(value)
+}
+
+// ExpandEnvRefs replaces ${ENV_VAR} references in all the string fields of the config (which needs to be a pointer) with
+// the values of the environment variables, it is an error to reference an environment variable that is not set
+func ExpandEnvRefs(cfg interface{}) error {
+	return expandEnvRefs(reflect.ValueOf(cfg), "", os.LookupEnv)
+}
+
+func expandEnvRefs(v reflect.Value, fieldName string, lookupEnv func(string) (string, bool)) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			// values inside interfaces cannot be set in place so we expand a copy and set it back
+			if v.Elem().Kind() != reflect.String || !v.CanSet() {
+				return nil
+			}
+			expanded, e := expandEnvRefsString(v.Elem().String(), fieldName, lookupEnv)
+			if e != nil {
+				return e
+			}
+			v.Set(reflect.ValueOf(expanded))
+			return nil
+		}
+		return expandEnvRefs(v.Elem(), fieldName, lookupEnv)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				// skip unexported fields
+				continue
+			}
+			name := field.Tag.Get("toml")
+			if name == "" {
+				name = field.Name
+			}
+			if fieldName != "" {
+				name = fieldName + "." + name
+			}
+			e := expandEnvRefs(v.Field(i), name, lookupEnv)
+			if e != nil {
+				return e
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e := expandEnvRefs(v.Index(i), fmt.Sprintf("%s[%d]", fieldName, i), lookupEnv)
+			if e != nil {
+				return e
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			expanded, e := expandEnvRefsString(v.MapIndex(key).String(), fmt.Sprintf("%s[%v]", fieldName, key), lookupEnv)
+			if e != nil {
+				return e
+			}
+			v.SetMapIndex(key, reflect.ValueOf(expanded).Convert(v.Type().Elem()))
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		expanded, e := expandEnvRefsString(v.String(), fieldName, lookupEnv)
+		if e != nil {
+			return e
+		}
+		v.SetString(expanded)
+	}
+	return nil
+}
+
+func expandEnvRefsString(value string, fieldName string, lookupEnv func(string) (string, bool)) (string, error) {
+	var missing []string
+	expanded := envRefRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRefRegex.FindStringSubmatch(ref)[1]
+		envValue, ok := lookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return ref
+		}
+		return envValue
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("config field '%s' references environment variables that are not set: %s", fieldName, strings.Join(missing, ", "))
+	}
+	if strings.Contains(envRefRegex.ReplaceAllString(value, ""), "${") {
+		return "", fmt.Errorf("config field '%s' has an invalid environment variable reference, use the format ${ENV_VAR}", fieldName)
+	}
+	return expanded, nil
+}
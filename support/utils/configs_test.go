@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testInnerConfig struct {
+	Host     string `toml:"HOST"`
+	Password string `toml:"PASSWORD"`
+}
+
+type testConfig struct {
+	Seed    string           `toml:"SEED"`
+	Port    int              `toml:"PORT"`
+	DB      *testInnerConfig `toml:"DB"`
+	NilDB   *testInnerConfig `toml:"NIL_DB"`
+	Filters []string         `toml:"FILTERS"`
+	Params  []struct {
+		Value interface{} `toml:"VALUE"`
+	} `toml:"PARAMS"`
+	Headers map[string]string `toml:"HEADERS"`
+
+	unexported string
+}
+
+func testLookupEnv(name string) (string, bool) {
+	env := map[string]string{
+		"SEED":    "SBSEED",
+		"DB_HOST": "db.internal",
+		"EMPTY":   "",
+	}
+	v, ok := env[name]
+	return v, ok
+}
+
+func TestExpandEnvRefs(t *testing.T) {
+	cfg := testConfig{
+		Seed:       "${SEED}",
+		Port:       5432,
+		DB:         &testInnerConfig{Host: "${DB_HOST}:${EMPTY}5432", Password: "pa$$word"},
+		Filters:    []string{"price/min/${SEED}"},
+		Headers:    map[string]string{"X-Host": "${DB_HOST}"},
+		unexported: "${MISSING}",
+	}
+	cfg.Params = append(cfg.Params, struct {
+		Value interface{} `toml:"VALUE"`
+	}{Value: "${DB_HOST}"})
+	cfg.Params = append(cfg.Params, struct {
+		Value interface{} `toml:"VALUE"`
+	}{Value: 1.5})
+
+	e := expandEnvRefs(reflect.ValueOf(&cfg), "", testLookupEnv)
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, "SBSEED", cfg.Seed)
+	assert.Equal(t, 5432, cfg.Port)
+	assert.Equal(t, "db.internal:5432", cfg.DB.Host)
+	assert.Equal(t, "pa$$word", cfg.DB.Password)
+	assert.Nil(t, cfg.NilDB)
+	assert.Equal(t, []string{"price/min/SBSEED"}, cfg.Filters)
+	assert.Equal(t, "db.internal", cfg.Params[0].Value)
+	assert.Equal(t, 1.5, cfg.Params[1].Value)
+	assert.Equal(t, map[string]string{"X-Host": "db.internal"}, cfg.Headers)
+	assert.Equal(t, "${MISSING}", cfg.unexported)
+}
+
+func TestExpandEnvRefsErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     testConfig
+		wantErr string
+	}{
+		{
+			name:    "missing",
+			cfg:     testConfig{DB: &testInnerConfig{Password: "${MISSING}"}},
+			wantErr: "config field 'DB.PASSWORD' references environment variables that are not set: MISSING",
+		}, {
+			name:    "invalid",
+			cfg:     testConfig{Filters: []string{"${SEED"}},
+			wantErr: "config field 'FILTERS[0]' has an invalid environment variable reference, use the format ${ENV_VAR}",
+		},
+	}
+
+	for _, kase := range testCases {
+		t.Run(kase.name, func(t *testing.T) {
+			e := expandEnvRefs(reflect.ValueOf(&kase.cfg), "", testLookupEnv)
+			if assert.Error(t, e) {
+				assert.Equal(t, kase.wantErr, e.Error())
+			}
+		})
+	}
+}
+
+func TestResolveFileRef(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_file_ref")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	secretPath := filepath.Join(dir, "seed")
+	assert.NoError(t, ioutil.WriteFile(secretPath, []byte("SBSEED\n"), 0600))
+	emptyPath := filepath.Join(dir, "empty")
+	assert.NoError(t, ioutil.WriteFile(emptyPath, []byte("\n"), 0600))
+
+	resolved, e := ResolveFileRef("file:" + secretPath)
+	assert.NoError(t, e)
+	assert.Equal(t, "SBSEED", resolved)
+
+	resolved, e = ResolveFileRef("SBPLAINTEXT")
+	assert.NoError(t, e)
+	assert.Equal(t, "SBPLAINTEXT", resolved)
+
+	_, e = ResolveFileRef("file:" + emptyPath)
+	assert.Error(t, e)
+	_, e = ResolveFileRef("file:" + filepath.Join(dir, "missing"))
+	assert.Error(t, e)
+
+	// resolvers are applied in order
+	shout := func(value string) (string, error) {
+		return value + "!", nil
+	}
+	resolved, e = ChainSecretResolvers(ResolveFileRef, shout)("file:" + secretPath)
+	assert.NoError(t, e)
+	assert.Equal(t, "SBSEED!", resolved)
+}
@@ -3,16 +3,20 @@ package terminator
 import (
 	"fmt"
 
+	"github.com/stellar/kelp/support/postgresdb"
 	"github.com/stellar/kelp/support/utils"
 )
 
 // Config represents the configuration params for the bot
 type Config struct {
-	SourceSecretSeed     string `valid:"-" toml:"SOURCE_SECRET_SEED"`
-	TradingSecretSeed    string `valid:"-" toml:"TRADING_SECRET_SEED"`
-	AllowInactiveMinutes int32  `valid:"-" toml:"ALLOW_INACTIVE_MINUTES"` // bots that are inactive for more than this time will have its offers deleted
-	TickIntervalSeconds  int32  `valid:"-" toml:"TICK_INTERVAL_SECONDS"`
-	HorizonURL           string `valid:"-" toml:"HORIZON_URL"`
+	SourceSecretSeed     string             `valid:"-" toml:"SOURCE_SECRET_SEED"`
+	TradingSecretSeed    string             `valid:"-" toml:"TRADING_SECRET_SEED"`
+	AllowInactiveMinutes int32              `valid:"-" toml:"ALLOW_INACTIVE_MINUTES"` // bots that are inactive for more than this time will have its offers deleted
+	TickIntervalSeconds  int32              `valid:"-" toml:"TICK_INTERVAL_SECONDS"`
+	HorizonURL           string             `valid:"-" toml:"HORIZON_URL"`
+	PostgresDbConfig     *postgresdb.Config `valid:"-" toml:"POSTGRES_DB"` // the database that the bots write their heartbeats to
+	AlertType            string             `valid:"-" toml:"ALERT_TYPE"`
+	AlertAPIKey          string             `valid:"-" toml:"ALERT_API_KEY"`
 
 	TradingAccount *string
 	SourceAccount  *string // can be nil
@@ -23,9 +27,31 @@ func (c Config) String() string {
 	return utils.StructString(c, 0, map[string]func(interface{}) interface{}{
 		"SOURCE_SECRET_SEED":  utils.SecretKey2PublicKey,
 		"TRADING_SECRET_SEED": utils.SecretKey2PublicKey,
+		"ALERT_API_KEY":       utils.Hide,
 	})
 }
 
+// ResolveSecrets replaces references to secrets (such as "keystore:mybot") with the secrets, needs to be called before Init
+func (c *Config) ResolveSecrets(resolve utils.SecretResolver) error {
+	e := utils.ResolveSecrets(resolve, &c.SourceSecretSeed, &c.TradingSecretSeed)
+	if e != nil {
+		return fmt.Errorf("could not resolve secret seeds: %s", e)
+	}
+
+	e = utils.ResolveSecrets(resolve, &c.AlertAPIKey)
+	if e != nil {
+		return fmt.Errorf("could not resolve ALERT_API_KEY: %s", e)
+	}
+
+	if c.PostgresDbConfig != nil {
+		e = utils.ResolveSecrets(resolve, &c.PostgresDbConfig.Password)
+		if e != nil {
+			return fmt.Errorf("could not resolve POSTGRES_DB password: %s", e)
+		}
+	}
+	return nil
+}
+
 // Init initializes this config
 func (c *Config) Init() error {
 	var e error
@@ -37,6 +63,10 @@ func (c *Config) Init() error {
 	if c.TradingAccount == nil {
 		return fmt.Errorf("no trading account specified")
 	}
+	// the bots write their heartbeats to the db so we cannot find inactive bots without it
+	if c.PostgresDbConfig == nil {
+		return fmt.Errorf("no POSTGRES_DB specified, it should be the same database as in the trader config files of the bots")
+	}
 
 	c.SourceAccount, e = utils.ParseSecret(c.SourceSecretSeed)
 	return e
@@ -1,22 +1,29 @@
 package terminator
 
 import (
-	"encoding/base64"
+	"database/sql"
 	"fmt"
 	"log"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/stellar/go/clients/horizonclient"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/kelp/api"
-	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/kelpdb"
 	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/queries"
 	"github.com/stellar/kelp/support/utils"
 )
 
-const terminatorKey = "term"
+// maxOpsPerTx is the maximum number of operations allowed in a single transaction on the Stellar network
+const maxOpsPerTx = 100
+
+// execer is the part of *sql.DB used by the Terminator to delete heartbeats
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
 // Terminator contains the logic to terminate offers
 type Terminator struct {
@@ -25,6 +32,9 @@ type Terminator struct {
 	tradingAccount       string
 	tickIntervalSeconds  int32
 	allowInactiveMinutes int32
+	db                   execer
+	inactiveBotsQuery    api.Query
+	alert                api.Alert
 }
 
 // MakeTerminator is a factory method to make a Terminator
@@ -34,14 +44,24 @@ func MakeTerminator(
 	tradingAccount string,
 	tickIntervalSeconds int32,
 	allowInactiveMinutes int32,
-) *Terminator {
+	db *sql.DB,
+	alert api.Alert,
+) (*Terminator, error) {
+	inactiveBotsQuery, e := queries.MakeInactiveBotHeartbeats(db, tradingAccount)
+	if e != nil {
+		return nil, fmt.Errorf("could not make InactiveBotHeartbeats query: %s", e)
+	}
+
 	return &Terminator{
 		api:                  api,
 		sdex:                 sdex,
 		tradingAccount:       tradingAccount,
 		tickIntervalSeconds:  tickIntervalSeconds,
 		allowInactiveMinutes: allowInactiveMinutes,
-	}
+		db:                   db,
+		inactiveBotsQuery:    inactiveBotsQuery,
+		alert:                alert,
+	}, nil
 }
 
 // StartService starts the Terminator service
@@ -53,206 +73,122 @@ func (t *Terminator) StartService() {
 	}
 }
 
-// botKeyPair is a pair of the model.BotKey and the time the bot was last updated
-type botKeyPair struct {
-	dataKey     model.BotKey
-	lastUpdated int64
-}
-
-// String impl
-func (kp botKeyPair) String() string {
-	return fmt.Sprintf("botKeyPair(dataKey=%v, lastUpdated=%d)", kp.dataKey, kp.lastUpdated)
-}
-
-// TODO 3 add db-based support, manage-data based support is invalid since we don't write it from trader anymore.
+// run deletes the offers of all bots on the trading account whose heartbeat in the bot_heartbeats table is older than allowInactiveMinutes
 func (t *Terminator) run() {
-	// use condition to avoid unreachable code warning
-	if 1 < 2 {
-		panic("need to add db-based support, manage-data based support is invalid since we don't write it from trader anymore.")
-	}
+	cutoff := time.Now().UTC().Add(-time.Duration(t.allowInactiveMinutes) * time.Minute)
+	log.Printf("cutoff time: %s\n", cutoff.Format(time.RFC3339))
 
-	acctReq := horizonclient.AccountRequest{AccountID: t.tradingAccount}
-	account, e := t.api.AccountDetail(acctReq)
+	result, e := t.inactiveBotsQuery.QueryRow(cutoff)
 	if e != nil {
 		log.Println(e)
 		return
 	}
+	inactiveBots, ok := result.([]queries.BotHeartbeat)
+	if !ok {
+		log.Printf("query '%s' returned an unexpected result type '%T'\n", t.inactiveBotsQuery.Name(), result)
+		return
+	}
+	log.Printf("Found %d inactive bots\n", len(inactiveBots))
+	if len(inactiveBots) == 0 {
+		return
+	}
 
-	// m is a map of hashes to botKeyPair(s)
-	botList, e := reconstructBotList(account.Data)
+	offers, e := utils.LoadAllOffers(t.tradingAccount, t.api)
 	if e != nil {
 		log.Println(e)
 		return
 	}
 
-	// compute cutoff millis
-	nowMillis := time.Now().UnixNano() / 1000000
-	cutoffMillis := nowMillis - (int64(t.allowInactiveMinutes) * 60 * 1000)
-	log.Printf("cutoff millis: %d\n", cutoffMillis)
-
-	// compute the inactive bots
-	inactiveBots := excludeActiveBots(botList, cutoffMillis)
-	log.Printf("Found %d inactive bots\n", len(inactiveBots))
-	if len(inactiveBots) == 0 {
-		// update data to reflect a successful return from terminator
-		newTimestamp := time.Now().UnixNano() / 1000000
-		tsMillisStr := fmt.Sprintf("%d", newTimestamp)
-		ops := []txnbuild.Operation{
-			&txnbuild.ManageData{
-				Name:          terminatorKey,
-				Value:         []byte(tsMillisStr),
-				SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-			},
+	for _, bot := range inactiveBots {
+		log.Printf("working on inactive bot: %s\n", bot)
+		e = t.terminateBot(bot, offers, cutoff)
+		if e != nil {
+			log.Println(e)
+			t.triggerAlert(fmt.Sprintf("kelp terminator could not delete the offers of inactive bot %s/%s on account %s", bot.BotKey.AssetBaseCode, bot.BotKey.AssetQuoteCode, t.tradingAccount), bot, e)
 		}
+	}
+}
 
-		log.Printf("updating delete timestamp to %s\n", tsMillisStr)
-		e = t.sdex.SubmitOps(api.ConvertOperation2TM(ops), api.SubmitModeBoth, nil)
+// terminateBot deletes the offers of an inactive bot and then its heartbeat, so we alert once per outage. If the bot comes back it
+// writes a new heartbeat
+func (t *Terminator) terminateBot(bot queries.BotHeartbeat, offers []hProtocol.Offer, cutoff time.Time) error {
+	if memo := strings.TrimPrefix(bot.AccountID, t.tradingAccount+":"); memo != bot.AccountID {
+		// the bot shares the trading account with other bots (TX_MEMO) so only delete the offers that it opened
+		var e error
+		offers, e = plugins.FilterOffersByMemo(t.api, t.tradingAccount, memo, offers)
 		if e != nil {
-			log.Println(e)
+			return fmt.Errorf("could not find the offers of bot %s: %s", bot, e)
 		}
-		return
 	}
 
-	offers, e := utils.LoadAllOffers(t.tradingAccount, t.api)
+	assetA := convertToAsset(bot.BotKey.AssetBaseCode, bot.BotKey.AssetBaseIssuer)
+	assetB := convertToAsset(bot.BotKey.AssetQuoteCode, bot.BotKey.AssetQuoteIssuer)
+	sellOffers, buyOffers := utils.FilterOffers(offers, assetA, assetB)
+	numOffers, e := t.deleteOffers(sellOffers, buyOffers)
 	if e != nil {
-		log.Println(e)
-		return
+		return fmt.Errorf("could not delete offers of bot %s: %s", bot, e)
 	}
 
-	// delete the offers of inactive bots (don't ever use hash directly)
-	for _, bk := range inactiveBots {
-		log.Printf("working on bot with key: %+v\n", bk)
-		assetA := convertToAsset(bk.dataKey.AssetBaseCode, bk.dataKey.AssetBaseIssuer)
-		assetB := convertToAsset(bk.dataKey.AssetQuoteCode, bk.dataKey.AssetQuoteIssuer)
-		inactiveSellOffers, inactiveBuyOffers := utils.FilterOffers(offers, assetA, assetB)
-		newTimestamp := time.Now().UnixNano() / 1000000
-		t.deleteOffers(inactiveSellOffers, inactiveBuyOffers, bk.dataKey, newTimestamp)
+	t.triggerAlert(fmt.Sprintf("kelp terminator deleted %d offers of inactive bot %s/%s on account %s", numOffers, bot.BotKey.AssetBaseCode, bot.BotKey.AssetQuoteCode, t.tradingAccount), bot, nil)
+
+	// only delete the heartbeat if the bot did not come back while we were deleting its offers
+	_, e = t.db.Exec(kelpdb.SqlBotHeartbeatsDelete, bot.AccountID, bot.BotKey.Hash(), cutoff)
+	if e != nil {
+		return fmt.Errorf("deleted %d offers but could not delete heartbeat of bot %s: %s", numOffers, bot, e)
 	}
+	return nil
 }
 
 func convertToAsset(code string, issuer string) hProtocol.Asset {
 	if code == utils.Native {
 		return utils.Asset2Asset2(txnbuild.NativeAsset{})
 	}
-	return utils.Asset2Asset2(txnbuild.CreditAsset{code, issuer})
+	return utils.Asset2Asset2(txnbuild.CreditAsset{Code: code, Issuer: issuer})
 }
 
-// deleteOffers deletes passed in offers along with the data for the passed in hash
-func (t *Terminator) deleteOffers(sellOffers []hProtocol.Offer, buyOffers []hProtocol.Offer, botKey model.BotKey, tsMillis int64) {
+// deleteOffers deletes passed in offers, returning the number of offers deleted
+func (t *Terminator) deleteOffers(sellOffers []hProtocol.Offer, buyOffers []hProtocol.Offer) (int, error) {
 	ops := []txnbuild.Operation{}
 	ops = append(ops, t.sdex.DeleteAllOffers(sellOffers)...)
 	ops = append(ops, t.sdex.DeleteAllOffers(buyOffers)...)
-	numOffers := len(ops)
 
-	// delete existing data entries
-	ops = append(ops, &txnbuild.ManageData{Name: botKey.FullKey(0),
-		SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-	})
-	ops = append(ops, &txnbuild.ManageData{Name: botKey.FullKey(1),
-		SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-	})
-	if len(botKey.AssetBaseIssuer) > 0 {
-		ops = append(ops, &txnbuild.ManageData{Name: botKey.FullKey(2),
-			SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-		})
-	}
-	ops = append(ops, &txnbuild.ManageData{Name: botKey.FullKey(3),
-		SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-	})
-	if len(botKey.AssetQuoteIssuer) > 0 {
-		ops = append(ops, &txnbuild.ManageData{Name: botKey.FullKey(4),
-			SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
+	log.Printf("deleting %d offers\n", len(ops))
+	for i := 0; i < len(ops); i += maxOpsPerTx {
+		end := i + maxOpsPerTx
+		if end > len(ops) {
+			end = len(ops)
+		}
+		// submission errors are only reported through the callback, which is invoked before SubmitOpsSynch returns
+		var submitErr error
+		e := t.sdex.SubmitOpsSynch(api.ConvertOperation2TM(ops[i:end]), api.SubmitModeBoth, func(hash string, e error) {
+			submitErr = e
 		})
-	}
-
-	// update timestamp for terminator
-	tsMillisStr := fmt.Sprintf("%d", tsMillis)
-	ops = append(ops, &txnbuild.ManageData{
-		Name:          terminatorKey,
-		Value:         []byte(tsMillisStr),
-		SourceAccount: &txnbuild.SimpleAccount{AccountID: t.tradingAccount},
-	})
-
-	log.Printf("deleting %d offers and 5 data entries, updating delete timestamp to %s\n", numOffers, tsMillisStr)
-	if len(ops) > 0 {
-		e := t.sdex.SubmitOps(api.ConvertOperation2TM(ops), api.SubmitModeBoth, nil)
 		if e != nil {
-			log.Println(e)
-			return
+			return i, e
 		}
-	}
-}
-
-// excludeActiveBots filters out bots that have a lastUpdated timestamp that is greater than or equal to cutoffMillis
-func excludeActiveBots(botList []botKeyPair, cutoffMillis int64) []botKeyPair {
-	inactive := []botKeyPair{}
-	for _, v := range botList {
-		if v.lastUpdated < cutoffMillis {
-			inactive = append(inactive, v)
+		if submitErr != nil {
+			return i, fmt.Errorf("could not submit transaction to delete offers: %s", submitErr)
 		}
 	}
-	return inactive
+	return len(ops), nil
 }
 
-func reconstructBotList(data map[string]string) ([]botKeyPair, error) {
-	m := make(map[string]botKeyPair)
-	for k, v := range data {
-		if !model.IsBotKey(k) {
-			continue
-		}
-
-		hash, botKeyPart := model.SplitDataKey(k)
-		currentBotKey, ok := m[hash]
-		if !ok {
-			currentBotKey = botKeyPair{}
-		}
-		e := updateBotKey(&currentBotKey, botKeyPart, v)
-		if e != nil {
-			return nil, e
-		}
-		m[hash] = currentBotKey
-	}
-
-	// convert to a list
-	l := []botKeyPair{}
-	for _, k := range m {
-		l = append(l, k)
+func (t *Terminator) triggerAlert(description string, bot queries.BotHeartbeat, cause error) {
+	details := map[string]interface{}{
+		"account_id":      bot.AccountID,
+		"market_id":       bot.MarketID,
+		"base_code":       bot.BotKey.AssetBaseCode,
+		"base_issuer":     bot.BotKey.AssetBaseIssuer,
+		"quote_code":      bot.BotKey.AssetQuoteCode,
+		"quote_issuer":    bot.BotKey.AssetQuoteIssuer,
+		"last_update_utc": bot.LastUpdateUTC.Format(time.RFC3339),
 	}
-
-	log.Printf("Found %d bots\n", len(l))
-	if len(l) > 0 {
-		logLine := "bots in list:\n"
-		for _, k := range l {
-			logLine = logLine + fmt.Sprintf("\t%v\n", k)
-		}
-		log.Println(logLine)
+	if cause != nil {
+		details["error"] = cause.Error()
 	}
 
-	return l, nil
-}
-
-func updateBotKey(currentBotKey *botKeyPair, botKeyPart string, value string) error {
-	decoded, e := base64.StdEncoding.DecodeString(value)
+	e := t.alert.Trigger(description, details)
 	if e != nil {
-		return e
+		log.Printf("unable to trigger alert: %s\n", e)
 	}
-
-	switch botKeyPart {
-	case "0":
-		var e error
-		millisStr := string(decoded)
-		currentBotKey.lastUpdated, e = strconv.ParseInt(millisStr, 10, 64)
-		if e != nil {
-			return e
-		}
-	case "1":
-		currentBotKey.dataKey.AssetBaseCode = string(decoded)
-	case "2":
-		currentBotKey.dataKey.AssetBaseIssuer = string(decoded)
-	case "3":
-		currentBotKey.dataKey.AssetQuoteCode = string(decoded)
-	case "4":
-		currentBotKey.dataKey.AssetQuoteIssuer = string(decoded)
-	}
-	return nil
 }
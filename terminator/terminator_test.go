@@ -0,0 +1,307 @@
+package terminator
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nikhilsaraf/go-tools/multithreading"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/kelpdb"
+	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/queries"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// fakeInactiveBotsQuery returns the same result on every call and records the args
+type fakeInactiveBotsQuery struct {
+	heartbeats []queries.BotHeartbeat
+	err        error
+	args       [][]interface{}
+}
+
+func (q *fakeInactiveBotsQuery) Name() string {
+	return "fakeInactiveBotsQuery"
+}
+
+func (q *fakeInactiveBotsQuery) QueryRow(args ...interface{}) (interface{}, error) {
+	q.args = append(q.args, args)
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.heartbeats, nil
+}
+
+// recordingExecer records the statements executed against the db
+type recordingExecer struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (r *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+	return nil, nil
+}
+
+type recordingAlert struct {
+	descriptions []string
+}
+
+func (a *recordingAlert) Trigger(description string, details interface{}) error {
+	a.descriptions = append(a.descriptions, description)
+	return nil
+}
+
+type terminatorFixture struct {
+	terminator *Terminator
+	server     *horizonfake.Server
+	query      *fakeInactiveBotsQuery
+	db         *recordingExecer
+	alert      *recordingAlert
+	trading    string
+	seed       string
+	usd        hProtocol.Asset
+	eur        hProtocol.Asset
+}
+
+// makeTerminatorFixture makes a terminator for a trading account on a fake horizon server, the account has 2 offers on XLM/USD and
+// 1 offer on XLM/EUR
+func makeTerminatorFixture(t *testing.T, s *horizonfake.Server) *terminatorFixture {
+	issuer := keypair.MustRandom()
+	trading := keypair.MustRandom()
+	usd := hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()}
+	eur := hProtocol.Asset{Type: "credit_alphanum4", Code: "EUR", Issuer: issuer.Address()}
+
+	s.AddAccount(issuer.Address(), "100")
+	s.AddAccount(trading.Address(), "1000")
+	for _, asset := range []hProtocol.Asset{usd, eur} {
+		s.AddTrustline(trading.Address(), asset, "10000")
+		s.SetBalance(trading.Address(), asset, "100")
+	}
+	for _, o := range []struct {
+		selling hProtocol.Asset
+		buying  hProtocol.Asset
+		price   string
+	}{
+		{utils.NativeAsset, usd, "0.2"},
+		{usd, utils.NativeAsset, "10"},
+		{utils.NativeAsset, eur, "0.2"},
+	} {
+		_, e := s.PlaceOffer(trading.Address(), o.selling, o.buying, "10", o.price)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	f := &terminatorFixture{
+		server:  s,
+		query:   &fakeInactiveBotsQuery{},
+		db:      &recordingExecer{},
+		alert:   &recordingAlert{},
+		trading: trading.Address(),
+		seed:    trading.Seed(),
+		usd:     usd,
+		eur:     eur,
+	}
+	f.terminator = &Terminator{
+		api:                  s.Client(),
+		sdex:                 f.makeSDEX(""),
+		tradingAccount:       trading.Address(),
+		tickIntervalSeconds:  1,
+		allowInactiveMinutes: 5,
+		db:                   f.db,
+		inactiveBotsQuery:    f.query,
+		alert:                f.alert,
+	}
+	return f
+}
+
+// makeSDEX makes an SDEX on the trading account that tags its transactions with the memo
+func (f *terminatorFixture) makeSDEX(txMemo string) *plugins.SDEX {
+	return plugins.MakeSDEX(
+		f.server.Client(),
+		plugins.MakeIEIF(true),
+		nil,
+		"",
+		f.seed,
+		"",
+		f.trading,
+		network.TestNetworkPassphrase,
+		multithreading.MakeThreadTracker(),
+		-1,
+		-1,
+		false,
+		nil,
+		map[model.Asset]hProtocol.Asset{},
+		plugins.SdexFixedFeeFn(txnbuild.MinBaseFee),
+		false,
+		nil,
+		txMemo,
+	)
+}
+
+// placeOfferWithMemo sells 10 XLM for USD at the price in a transaction with the memo
+func (f *terminatorFixture) placeOfferWithMemo(t *testing.T, txMemo string, price string) {
+	op := &txnbuild.ManageSellOffer{
+		Selling:       txnbuild.NativeAsset{},
+		Buying:        txnbuild.CreditAsset{Code: f.usd.Code, Issuer: f.usd.Issuer},
+		Amount:        "10",
+		Price:         price,
+		SourceAccount: &txnbuild.SimpleAccount{AccountID: f.trading},
+	}
+	var submitErr error
+	e := f.makeSDEX(txMemo).SubmitOpsSynch(api.ConvertOperation2TM([]txnbuild.Operation{op}), api.SubmitModeBoth, func(hash string, e error) {
+		submitErr = e
+	})
+	if e != nil || submitErr != nil {
+		t.Fatal(e, submitErr)
+	}
+}
+
+func (f *terminatorFixture) heartbeat(quote hProtocol.Asset) queries.BotHeartbeat {
+	return queries.BotHeartbeat{
+		AccountID:     f.trading,
+		BotKey:        *model.MakeSortedBotKey(utils.NativeAsset, quote),
+		MarketID:      "market_" + quote.Code,
+		LastUpdateUTC: time.Now().UTC().Add(-10 * time.Minute),
+	}
+}
+
+func (f *terminatorFixture) offerCodes(t *testing.T) []string {
+	offers, e := utils.LoadAllOffers(f.trading, f.server.Client())
+	if e != nil {
+		t.Fatal(e)
+	}
+	codes := []string{}
+	for _, o := range offers {
+		codes = append(codes, fmt.Sprintf("%s/%s", assetCode(o.Selling), assetCode(o.Buying)))
+	}
+	return codes
+}
+
+func assetCode(a hProtocol.Asset) string {
+	if a.Type == "native" {
+		return utils.Native
+	}
+	return a.Code
+}
+
+func TestTerminatorRun_DeletesOffersOfInactiveBots(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	f := makeTerminatorFixture(t, s)
+	usdBot := f.heartbeat(f.usd)
+	f.query.heartbeats = []queries.BotHeartbeat{usdBot}
+
+	before := time.Now().UTC()
+	f.terminator.run()
+
+	// only the offers of the inactive bot are deleted
+	assert.Equal(t, []string{"native/EUR"}, f.offerCodes(t))
+	assert.Equal(t, 1, s.CallCount("transactions"))
+
+	// the cutoff is allowInactiveMinutes before now
+	if assert.Equal(t, 1, len(f.query.args)) && assert.Equal(t, 1, len(f.query.args[0])) {
+		cutoff := f.query.args[0][0].(time.Time)
+		assert.WithinDuration(t, before.Add(-5*time.Minute), cutoff, 5*time.Second)
+	}
+
+	// the heartbeat is deleted with the same cutoff so a bot that came back keeps its heartbeat
+	if assert.Equal(t, 1, len(f.db.queries)) {
+		assert.Equal(t, kelpdb.SqlBotHeartbeatsDelete, f.db.queries[0])
+		assert.Equal(t, []interface{}{f.trading, usdBot.BotKey.Hash(), f.query.args[0][0]}, f.db.args[0])
+	}
+
+	if assert.Equal(t, 1, len(f.alert.descriptions)) {
+		assert.Contains(t, f.alert.descriptions[0], "deleted 2 offers of inactive bot native/USD")
+	}
+}
+
+func TestTerminatorRun_NoInactiveBots(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	f := makeTerminatorFixture(t, s)
+
+	f.terminator.run()
+
+	assert.Equal(t, 3, len(f.offerCodes(t)))
+	assert.Equal(t, 0, s.CallCount("transactions"))
+	assert.Equal(t, 0, len(f.db.queries))
+	assert.Equal(t, 0, len(f.alert.descriptions))
+}
+
+func TestTerminatorRun_QueryError(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	f := makeTerminatorFixture(t, s)
+	f.query.err = fmt.Errorf("connection refused")
+
+	f.terminator.run()
+
+	assert.Equal(t, 0, s.CallCount("account_offers"))
+	assert.Equal(t, 3, len(f.offerCodes(t)))
+	assert.Equal(t, 0, len(f.db.queries))
+}
+
+func TestTerminatorRun_SubmitErrorKeepsHeartbeat(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	f := makeTerminatorFixture(t, s)
+	f.query.heartbeats = []queries.BotHeartbeat{f.heartbeat(f.usd)}
+	s.FailNext("transactions", 504, "Timeout")
+
+	f.terminator.run()
+
+	// the bot keeps its offers and its heartbeat so it is terminated again on the next run
+	assert.Equal(t, 1, s.CallCount("transactions"))
+	assert.Equal(t, 3, len(f.offerCodes(t)))
+	assert.Equal(t, 0, len(f.db.queries))
+	if assert.Equal(t, 1, len(f.alert.descriptions)) {
+		assert.Contains(t, f.alert.descriptions[0], "could not delete the offers of inactive bot native/USD")
+	}
+}
+
+func TestTerminatorRun_SharedTradingAccount(t *testing.T) {
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	f := makeTerminatorFixture(t, s)
+	f.placeOfferWithMemo(t, "bot1", "0.3")
+	f.placeOfferWithMemo(t, "bot2", "0.4")
+	bot1 := f.heartbeat(f.usd)
+	bot1.AccountID = f.trading + ":bot1"
+	f.query.heartbeats = []queries.BotHeartbeat{bot1}
+
+	f.terminator.run()
+
+	// only the offer that bot1 opened is deleted, bot2 and the offers without a memo keep trading on XLM/USD
+	offers, e := utils.LoadAllOffers(f.trading, s.Client())
+	if !assert.NoError(t, e) {
+		return
+	}
+	prices := []string{}
+	for _, o := range offers {
+		if o.Selling.Type == "native" && o.Buying.Code == "USD" {
+			prices = append(prices, o.Price)
+		}
+	}
+	assert.ElementsMatch(t, []string{"0.2000000", "0.4000000"}, prices)
+	assert.Equal(t, 4, len(offers))
+
+	// the heartbeat of bot1 is deleted, not the heartbeats of other bots on the trading account
+	if assert.Equal(t, 1, len(f.db.args)) {
+		assert.Equal(t, bot1.AccountID, f.db.args[0][0])
+	}
+	if assert.Equal(t, 1, len(f.alert.descriptions)) {
+		assert.Contains(t, f.alert.descriptions[0], "deleted 1 offers of inactive bot native/USD")
+	}
+}
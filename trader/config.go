@@ -19,6 +19,49 @@ type FeeConfig struct {
 	MaxOpFeeStroops uint64  `valid:"-" toml:"MAX_OP_FEE_STROOPS" json:"max_op_fee_stroops"` // max fee in stroops per operation to use
 }
 
+// EventTriggerConfig represents input data for running update cycles only when an event occurs instead of on every tick
+type EventTriggerConfig struct {
+	MinIntervalMillis    int64   `valid:"-" toml:"MIN_INTERVAL_MILLIS" json:"min_interval_millis"`       // minimum time between two updates
+	MaxIdleMillis        int64   `valid:"-" toml:"MAX_IDLE_MILLIS" json:"max_idle_millis"`               // maximum time between two updates when there are no events, 0 disables it
+	FeedType             string  `valid:"-" toml:"FEED_TYPE" json:"feed_type"`                           // reference price feed to watch, leave empty to not trigger on price moves
+	FeedURL              string  `valid:"-" toml:"FEED_URL" json:"feed_url"`                             // url of the reference price feed
+	PriceChangeThreshold float64 `valid:"-" toml:"PRICE_CHANGE_THRESHOLD" json:"price_change_threshold"` // relative move of the reference price that triggers an update (0.005 = 0.5%)
+	TriggerOnFill        bool    `valid:"-" toml:"TRIGGER_ON_FILL" json:"trigger_on_fill"`               // trigger an update when one of our orders is filled
+}
+
+// RateLimitConfig represents input data for throttling and retrying requests to centralized exchanges
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `valid:"-" toml:"REQUESTS_PER_SECOND" json:"requests_per_second"` // 0 uses the rate limit reported by the exchange (if any)
+	Burst             int     `valid:"-" toml:"BURST" json:"burst"`                             // number of requests that can be sent back-to-back before throttling kicks in
+	MaxRetries        int     `valid:"-" toml:"MAX_RETRIES" json:"max_retries"`                 // number of times to retry a request on transient errors
+	BaseBackoffMillis int64   `valid:"-" toml:"BASE_BACKOFF_MILLIS" json:"base_backoff_millis"` // backoff before the first retry, doubles on every retry
+	MaxBackoffMillis  int64   `valid:"-" toml:"MAX_BACKOFF_MILLIS" json:"max_backoff_millis"`   // upper limit for the backoff between retries
+}
+
+// ProtectiveOrdersConfig represents input data for the stop loss and take profit orders that protect the inventory held by the bot
+type ProtectiveOrdersConfig struct {
+	FeedType string                  `valid:"-" toml:"FEED_TYPE" json:"feed_type"` // price feed used to trigger the orders that the exchange cannot hold natively
+	FeedURL  string                  `valid:"-" toml:"FEED_URL" json:"feed_url"`   // url of the price feed
+	Slippage float64                 `valid:"-" toml:"SLIPPAGE" json:"slippage"`   // simulated orders without a limit price are sent as limit orders this fraction past the feed price, 0 sends market orders
+	Orders   []ProtectiveOrderConfig `valid:"-" toml:"ORDERS" json:"orders"`
+}
+
+// ProtectiveOrderConfig represents a single stop loss or take profit order
+type ProtectiveOrderConfig struct {
+	Type         string  `valid:"-" toml:"TYPE" json:"type"`                   // stop_loss or take_profit
+	Side         string  `valid:"-" toml:"SIDE" json:"side"`                   // side of the order sent when triggered: sell protects the base asset, buy protects the quote asset
+	TriggerPrice float64 `valid:"-" toml:"TRIGGER_PRICE" json:"trigger_price"` // price of the base asset in units of the quote asset
+	LimitPrice   float64 `valid:"-" toml:"LIMIT_PRICE" json:"limit_price"`     // limit price of the order sent when triggered, 0 sends a market order
+	Amount       float64 `valid:"-" toml:"AMOUNT" json:"amount"`               // in units of the base asset
+}
+
+// RemoteSignerConfig represents input data for delegating transaction signing to an HTTP signing service
+type RemoteSignerConfig struct {
+	URL           string `valid:"-" toml:"URL" json:"url"`                       // endpoint that receives the unsigned transaction and returns signatures
+	AuthToken     string `valid:"-" toml:"AUTH_TOKEN" json:"auth_token"`         // sent as a bearer token when set
+	TimeoutMillis int64  `valid:"-" toml:"TIMEOUT_MILLIS" json:"timeout_millis"` // timeout of each signing request, 0 uses the default
+}
+
 // BotConfig represents the configuration params for the bot
 type BotConfig struct {
 	SourceSecretSeed  string `valid:"-" toml:"SOURCE_SECRET_SEED" json:"source_secret_seed"`
@@ -32,8 +75,11 @@ type BotConfig struct {
 	TickIntervalMillis                 int32      `valid:"-" toml:"TICK_INTERVAL_MILLIS" json:"tick_interval_millis"`
 	MaxTickDelayMillis                 int64      `valid:"-" toml:"MAX_TICK_DELAY_MILLIS" json:"max_tick_delay_millis"`
 	SleepMode                          string     `valid:"-" toml:"SLEEP_MODE" json:"sleep_mode"`
+	TradingWindowTimezone              string     `valid:"-" toml:"TRADING_WINDOW_TIMEZONE" json:"trading_window_timezone"`
 	DeleteCyclesThreshold              int64      `valid:"-" toml:"DELETE_CYCLES_THRESHOLD" json:"delete_cycles_threshold"`
 	SubmitMode                         string     `valid:"-" toml:"SUBMIT_MODE" json:"submit_mode"`
+	PassiveSellOffers                  bool       `valid:"-" toml:"PASSIVE_SELL_OFFERS" json:"passive_sell_offers"`
+	TxMemo                             string     `valid:"-" toml:"TX_MEMO" json:"tx_memo"`
 	FillTrackerSleepMillis             uint32     `valid:"-" toml:"FILL_TRACKER_SLEEP_MILLIS" json:"fill_tracker_sleep_millis"`
 	FillTrackerDeleteCyclesThreshold   int64      `valid:"-" toml:"FILL_TRACKER_DELETE_CYCLES_THRESHOLD" json:"fill_tracker_delete_cycles_threshold"`
 	SynchronizeStateLoadEnable         bool       `valid:"-" toml:"SYNCHRONIZE_STATE_LOAD_ENABLE"`
@@ -50,9 +96,15 @@ type BotConfig struct {
 	MinCentralizedBaseVolumeDeprecated *float64                 `valid:"-" toml:"MIN_CENTRALIZED_BASE_VOLUME" deprecated:"true" json:"min_centralized_base_volume"`
 	CentralizedMinBaseVolumeOverride   *float64                 `valid:"-" toml:"CENTRALIZED_MIN_BASE_VOLUME_OVERRIDE" json:"centralized_min_base_volume_override"`
 	CentralizedMinQuoteVolumeOverride  *float64                 `valid:"-" toml:"CENTRALIZED_MIN_QUOTE_VOLUME_OVERRIDE" json:"centralized_min_quote_volume_override"`
+	SignerSeeds                        []string                 `valid:"-" toml:"SIGNER_SEEDS" json:"signer_seeds"`
+	RemoteSigner                       *RemoteSignerConfig      `valid:"-" toml:"REMOTE_SIGNER" json:"remote_signer"`
 	PostgresDbConfig                   *postgresdb.Config       `valid:"-" toml:"POSTGRES_DB" json:"postgres_db"`
 	DbOverrideAccountID                string                   `valid:"-" toml:"DB_OVERRIDE__ACCOUNT_ID" json:"db_override__account_id"`
 	Filters                            []string                 `valid:"-" toml:"FILTERS" json:"filters"`
+	TradingWindows                     []string                 `valid:"-" toml:"TRADING_WINDOWS" json:"trading_windows"`
+	TradingBlackouts                   []string                 `valid:"-" toml:"TRADING_BLACKOUTS" json:"trading_blackouts"`
+	EventTrigger                       *EventTriggerConfig      `valid:"-" toml:"EVENT_TRIGGER" json:"event_trigger"`
+	ProtectiveOrders                   *ProtectiveOrdersConfig  `valid:"-" toml:"PROTECTIVE_ORDERS" json:"protective_orders"`
 	AlertType                          string                   `valid:"-" toml:"ALERT_TYPE" json:"alert_type"`
 	AlertAPIKey                        string                   `valid:"-" toml:"ALERT_API_KEY" json:"alert_api_key"`
 	MonitoringPort                     uint16                   `valid:"-" toml:"MONITORING_PORT" json:"monitoring_port"`
@@ -65,6 +117,7 @@ type BotConfig struct {
 	ExchangeAPIKeys                    toml.ExchangeAPIKeysToml `valid:"-" toml:"EXCHANGE_API_KEYS" json:"exchange_api_keys"`
 	ExchangeParams                     toml.ExchangeParamsToml  `valid:"-" toml:"EXCHANGE_PARAMS" json:"exchange_params"`
 	ExchangeHeaders                    toml.ExchangeHeadersToml `valid:"-" toml:"EXCHANGE_HEADERS" json:"exchange_headers"`
+	ExchangeRateLimit                  *RateLimitConfig         `valid:"-" toml:"EXCHANGE_RATE_LIMIT" json:"exchange_rate_limit"`
 
 	// initialized later
 	tradingAccount *string
@@ -132,6 +185,8 @@ func (b BotConfig) String() string {
 		"SOURCE_SECRET_SEED":       utils.SecretKey2PublicKey,
 		"TRADING_SECRET_SEED":      utils.SecretKey2PublicKey,
 		"ALERT_API_KEY":            utils.Hide,
+		"SIGNER_SEEDS":             utils.Hide,
+		"REMOTE_SIGNER":            utils.Hide,
 		"GOOGLE_CLIENT_ID":         utils.Hide,
 		"GOOGLE_CLIENT_SECRET":     utils.Hide,
 		"ACCEPTABLE_GOOGLE_EMAILS": utils.Hide,
@@ -169,6 +224,40 @@ func (b *BotConfig) TradingPair() string {
 	return fmt.Sprintf("%s/%s", b.AssetCodeA, b.AssetCodeB)
 }
 
+// DbAccountID returns the account_id used for this bot in the database, this is the DB_OVERRIDE__ACCOUNT_ID when set, otherwise bots on SDEX
+// that set a TX_MEMO are told apart from other bots sharing the trading account by appending the memo to the trading account
+func (b *BotConfig) DbAccountID() string {
+	if b.DbOverrideAccountID != "" {
+		return b.DbOverrideAccountID
+	}
+	return b.memoAccountID()
+}
+
+// BotID tells apart bots that share the trading account, this is the DbAccountID when set and the trading account otherwise
+func (b *BotConfig) BotID() string {
+	if b.DbAccountID() != "" {
+		return b.DbAccountID()
+	}
+	return b.TradingAccount()
+}
+
+// HeartbeatAccountID returns the account_id of the heartbeats of this bot, this is the trading account with the TX_MEMO appended like in
+// DbAccountID so bots sharing the trading account do not overwrite each other's heartbeat. DB_OVERRIDE__ACCOUNT_ID is not used because the
+// terminator looks heartbeats up by the trading account.
+func (b *BotConfig) HeartbeatAccountID() string {
+	if accountID := b.memoAccountID(); accountID != "" {
+		return accountID
+	}
+	return b.TradingAccount()
+}
+
+func (b *BotConfig) memoAccountID() string {
+	if b.IsTradingSdex() && b.TxMemo != "" {
+		return fmt.Sprintf("%s:%s", b.TradingAccount(), b.TxMemo)
+	}
+	return ""
+}
+
 // IsTradingSdex returns whether the config is set to trade on SDEX
 func (b *BotConfig) IsTradingSdex() bool {
 	return b.isTradingSdex
@@ -182,6 +271,51 @@ func (b *BotConfig) TradingExchangeName() string {
 	return b.TradingExchange
 }
 
+// ResolveSecrets replaces references to secrets (such as "keystore:mybot") with the secrets, needs to be called before Init
+func (b *BotConfig) ResolveSecrets(resolve utils.SecretResolver) error {
+	e := utils.ResolveSecrets(resolve, &b.SourceSecretSeed, &b.TradingSecretSeed)
+	if e != nil {
+		return fmt.Errorf("could not resolve secret seeds: %s", e)
+	}
+
+	for i := range b.SignerSeeds {
+		e = utils.ResolveSecrets(resolve, &b.SignerSeeds[i])
+		if e != nil {
+			return fmt.Errorf("could not resolve SIGNER_SEEDS: %s", e)
+		}
+	}
+
+	if b.RemoteSigner != nil {
+		e = utils.ResolveSecrets(resolve, &b.RemoteSigner.AuthToken)
+		if e != nil {
+			return fmt.Errorf("could not resolve REMOTE_SIGNER auth token: %s", e)
+		}
+	}
+
+	e = b.ExchangeAPIKeys.ResolveSecrets(resolve)
+	if e != nil {
+		return e
+	}
+
+	e = utils.ResolveSecrets(resolve, &b.AlertAPIKey)
+	if e != nil {
+		return fmt.Errorf("could not resolve ALERT_API_KEY: %s", e)
+	}
+
+	e = utils.ResolveSecrets(resolve, &b.GoogleClientID, &b.GoogleClientSecret)
+	if e != nil {
+		return fmt.Errorf("could not resolve GOOGLE_CLIENT_ID or GOOGLE_CLIENT_SECRET: %s", e)
+	}
+
+	if b.PostgresDbConfig != nil {
+		e = utils.ResolveSecrets(resolve, &b.PostgresDbConfig.Password)
+		if e != nil {
+			return fmt.Errorf("could not resolve POSTGRES_DB password: %s", e)
+		}
+	}
+	return nil
+}
+
 // Init initializes this config
 func (b *BotConfig) Init() error {
 	b.isTradingSdex = b.TradingExchange == "" || b.TradingExchange == "sdex"
@@ -0,0 +1,56 @@
+package trader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDbAndHeartbeatAccountID(t *testing.T) {
+	tradingAccount := "GCB7WIQ3TILJLPOT4E7YMOYF6A5TKYRWK3ZHJ5UR6UKD7D7NJVWNWIQV"
+	testCases := []struct {
+		name                   string
+		tradingExchange        string
+		dbOverrideAccountID    string
+		txMemo                 string
+		wantAccountID          string
+		wantHeartbeatAccountID string
+	}{
+		{
+			name:                   "sdex without memo or override",
+			wantAccountID:          "",
+			wantHeartbeatAccountID: tradingAccount,
+		}, {
+			name:                   "sdex with override",
+			dbOverrideAccountID:    "account1",
+			txMemo:                 "bot1",
+			wantAccountID:          "account1",
+			wantHeartbeatAccountID: tradingAccount + ":bot1",
+		}, {
+			name:                   "sdex with memo",
+			txMemo:                 "bot1",
+			wantAccountID:          tradingAccount + ":bot1",
+			wantHeartbeatAccountID: tradingAccount + ":bot1",
+		}, {
+			name:                   "centralized exchange ignores memo",
+			tradingExchange:        "kraken",
+			txMemo:                 "bot1",
+			wantAccountID:          "",
+			wantHeartbeatAccountID: tradingAccount,
+		},
+	}
+
+	for _, k := range testCases {
+		t.Run(k.name, func(t *testing.T) {
+			b := &BotConfig{
+				TradingExchange:     k.tradingExchange,
+				DbOverrideAccountID: k.dbOverrideAccountID,
+				TxMemo:              k.txMemo,
+				tradingAccount:      &tradingAccount,
+				isTradingSdex:       k.tradingExchange == "",
+			}
+			assert.Equal(t, k.wantAccountID, b.DbAccountID())
+			assert.Equal(t, k.wantHeartbeatAccountID, b.HeartbeatAccountID())
+		})
+	}
+}
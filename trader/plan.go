@@ -0,0 +1,209 @@
+package trader
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/stellar/go/build"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/kelp/api"
+	"github.com/stellar/kelp/support/utils"
+)
+
+// OfferChangeType is the kind of change an operation makes to an offer
+type OfferChangeType string
+
+// types of offer changes
+const (
+	OfferChangeCreate OfferChangeType = "create"
+	OfferChangeModify OfferChangeType = "modify"
+	OfferChangeDelete OfferChangeType = "delete"
+)
+
+// OfferChange is a change to an offer that the bot would submit, prices are in units of the quote asset and amounts are in units
+// of the base asset for both sides of the book
+type OfferChange struct {
+	Type      OfferChangeType
+	IsSell    bool
+	IsPrune   bool // set for the deletions from PruneExistingOffers
+	OfferID   int64
+	OldPrice  float64 // zero for creates
+	OldAmount float64 // zero for creates
+	NewPrice  float64 // zero for deletes
+	NewAmount float64 // zero for deletes
+}
+
+// String is the diff line for this change
+func (c OfferChange) String() string {
+	side := "buy "
+	if c.IsSell {
+		side = "sell"
+	}
+
+	switch c.Type {
+	case OfferChangeCreate:
+		return fmt.Sprintf("+ %s  price=%.8f amount=%.8f", side, c.NewPrice, c.NewAmount)
+	case OfferChangeModify:
+		return fmt.Sprintf("~ %s  offerID=%d price=%.8f -> %.8f amount=%.8f -> %.8f", side, c.OfferID, c.OldPrice, c.NewPrice, c.OldAmount, c.NewAmount)
+	default:
+		suffix := ""
+		if c.IsPrune {
+			suffix = " (pruned)"
+		}
+		return fmt.Sprintf("- %s  offerID=%d price=%.8f amount=%.8f%s", side, c.OfferID, c.OldPrice, c.OldAmount, suffix)
+	}
+}
+
+// Plan is the outcome of running an update cycle without submitting any operations
+type Plan struct {
+	BaseBalance    *api.Balance
+	QuoteBalance   *api.Balance
+	NumSellOffers  int
+	NumBuyOffers   int
+	Changes        []OfferChange
+	NumFilteredOps int // number of operations from UpdateWithOps that were dropped by the submit filters
+}
+
+// Counts returns the number of creates, modifies and deletes in the plan
+func (p *Plan) Counts() (int /*numCreate*/, int /*numModify*/, int /*numDelete*/) {
+	numCreate, numModify, numDelete := 0, 0, 0
+	for _, c := range p.Changes {
+		switch c.Type {
+		case OfferChangeCreate:
+			numCreate++
+		case OfferChangeModify:
+			numModify++
+		default:
+			numDelete++
+		}
+	}
+	return numCreate, numModify, numDelete
+}
+
+// Plan loads balances and offers and runs the strategy and submit filters in the same way as an update cycle, without submitting
+// any operations, and returns the changes that the bot would make to its offers
+func (t *Trader) Plan() (*Plan, error) {
+	baseBalance, quoteBalance, e := t.getBalances()
+	if e != nil {
+		return nil, fmt.Errorf("unable to get balances: %s", e)
+	}
+	sellingAOffers, buyingAOffers, e := t.getExistingOffers()
+	if e != nil {
+		return nil, fmt.Errorf("unable to get offers: %s", e)
+	}
+	t.setBalances(baseBalance, quoteBalance)
+	t.setExistingOffers(sellingAOffers, buyingAOffers)
+
+	numSellOffers, numBuyOffers := len(sellingAOffers), len(buyingAOffers)
+	existingOffers := map[int64]hProtocol.Offer{}
+	for _, o := range append(append([]hProtocol.Offer{}, sellingAOffers...), buyingAOffers...) {
+		existingOffers[o.ID] = o
+	}
+
+	t.sdex.IEIF().ResetCachedBalances()
+	e = t.sdex.IEIF().ResetCachedLiabilities(t.assetBase, t.assetQuote)
+	if e != nil {
+		return nil, fmt.Errorf("unable to reset cached liabilities: %s", e)
+	}
+
+	e = t.strategy.PreUpdate(t.maxAssetA, t.maxAssetB, t.trustAssetA, t.trustAssetB)
+	if e != nil {
+		return nil, fmt.Errorf("error in PreUpdate: %s", e)
+	}
+
+	var pruneOps []build.TransactionMutator
+	pruneOps, t.buyingAOffers, t.sellingAOffers = t.strategy.PruneExistingOffers(t.buyingAOffers, t.sellingAOffers)
+
+	opsOld, e := t.strategy.UpdateWithOps(t.buyingAOffers, t.sellingAOffers)
+	if e != nil {
+		return nil, fmt.Errorf("error in UpdateWithOps: %s", e)
+	}
+	msos := api.ConvertTM2MSO(opsOld)
+	ops := api.ConvertMSO2Ops(msos)
+	for i, filter := range t.submitFilters {
+		ops, e = filter.Apply(ops, t.sellingAOffers, t.buyingAOffers)
+		if e != nil {
+			return nil, fmt.Errorf("error in filter index %d: %s", i, e)
+		}
+	}
+
+	plan := &Plan{
+		BaseBalance:    baseBalance,
+		QuoteBalance:   quoteBalance,
+		NumSellOffers:  numSellOffers,
+		NumBuyOffers:   numBuyOffers,
+		Changes:        []OfferChange{},
+		NumFilteredOps: len(msos) - len(ops),
+	}
+	for i, mso := range api.ConvertTM2MSO(pruneOps) {
+		c, e := t.makeOfferChange(mso, existingOffers, true)
+		if e != nil {
+			return nil, fmt.Errorf("invalid prune operation at index %d: %s", i, e)
+		}
+		plan.Changes = append(plan.Changes, c)
+	}
+	for i, op := range ops {
+		mso, ok := op.(*txnbuild.ManageSellOffer)
+		if !ok {
+			return nil, fmt.Errorf("operation at index %d was not of expected type ManageSellOffer (actual type = %T): %+v", i, op, op)
+		}
+		c, e := t.makeOfferChange(mso, existingOffers, false)
+		if e != nil {
+			return nil, fmt.Errorf("invalid update operation at index %d: %s", i, e)
+		}
+		plan.Changes = append(plan.Changes, c)
+	}
+	return plan, nil
+}
+
+func (t *Trader) makeOfferChange(mso *txnbuild.ManageSellOffer, existingOffers map[int64]hProtocol.Offer, isPrune bool) (OfferChange, error) {
+	if mso == nil {
+		return OfferChange{}, fmt.Errorf("operation was not of expected type ManageSellOffer")
+	}
+	isSell, e := utils.IsSelling(t.assetBase, t.assetQuote, mso.Selling, mso.Buying)
+	if e != nil {
+		return OfferChange{}, e
+	}
+	amount, e := strconv.ParseFloat(mso.Amount, 64)
+	if e != nil {
+		return OfferChange{}, fmt.Errorf("could not parse amount (%s): %s", mso.Amount, e)
+	}
+
+	c := OfferChange{
+		IsSell:  isSell,
+		IsPrune: isPrune,
+		OfferID: mso.OfferID,
+	}
+	// 0 amount represents deletion, 0 offer id represents creating a new offer, anything else updates an existing offer
+	if amount == 0 {
+		c.Type = OfferChangeDelete
+	} else {
+		price, e := strconv.ParseFloat(mso.Price, 64)
+		if e != nil {
+			return OfferChange{}, fmt.Errorf("could not parse price (%s): %s", mso.Price, e)
+		}
+		c.NewPrice, c.NewAmount = toBaseUnits(isSell, price, amount)
+		c.Type = OfferChangeCreate
+		if mso.OfferID != 0 {
+			c.Type = OfferChangeModify
+		}
+	}
+
+	if o, ok := existingOffers[mso.OfferID]; ok && mso.OfferID != 0 {
+		c.OldPrice, c.OldAmount = toBaseUnits(isSell, utils.GetPrice(o), utils.AmountStringAsFloat(o.Amount))
+	}
+	return c, nil
+}
+
+// toBaseUnits converts the price and amount of an offer to a price in units of the quote asset and an amount in units of the base
+// asset, buy offers sell the quote asset so they are quoted in the inverse units
+func toBaseUnits(isSell bool, price float64, amount float64) (float64 /*price*/, float64 /*amount*/) {
+	if isSell {
+		return price, amount
+	}
+	if price == 0 {
+		return 0, 0
+	}
+	return 1 / price, amount * price
+}
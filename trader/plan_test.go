@@ -0,0 +1,90 @@
+package trader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/kelp/support/utils"
+)
+
+func TestMakeOfferChange(t *testing.T) {
+	base := utils.String2Asset("XLM", "")
+	quote := utils.String2Asset("USD", "GCNSGHUCG5VMGLT5RIYYZSO7VQULQKAJ62QA33DBC5PPBSO57LFWVV6P")
+	trader := &Trader{assetBase: base, assetQuote: quote}
+	existingOffers := map[int64]hProtocol.Offer{
+		1: hProtocol.Offer{ID: 1, Selling: base, Buying: quote, Amount: "100.0", PriceR: hProtocol.Price{N: 1, D: 10}},
+		2: hProtocol.Offer{ID: 2, Selling: quote, Buying: base, Amount: "5.0", PriceR: hProtocol.Price{N: 20, D: 1}},
+	}
+	sell := func(offerID int64, amount string, price string) *txnbuild.ManageSellOffer {
+		return &txnbuild.ManageSellOffer{Selling: utils.Asset2Asset(base), Buying: utils.Asset2Asset(quote), Amount: amount, Price: price, OfferID: offerID}
+	}
+	buy := func(offerID int64, amount string, price string) *txnbuild.ManageSellOffer {
+		return &txnbuild.ManageSellOffer{Selling: utils.Asset2Asset(quote), Buying: utils.Asset2Asset(base), Amount: amount, Price: price, OfferID: offerID}
+	}
+
+	testCases := []struct {
+		name     string
+		mso      *txnbuild.ManageSellOffer
+		isPrune  bool
+		want     OfferChange
+		wantLine string
+	}{
+		{
+			name:     "create sell",
+			mso:      sell(0, "50.0", "0.12"),
+			want:     OfferChange{Type: OfferChangeCreate, IsSell: true, NewPrice: 0.12, NewAmount: 50},
+			wantLine: "+ sell  price=0.12000000 amount=50.00000000",
+		}, {
+			name:     "modify sell",
+			mso:      sell(1, "80.0", "0.11"),
+			want:     OfferChange{Type: OfferChangeModify, IsSell: true, OfferID: 1, OldPrice: 0.1, OldAmount: 100, NewPrice: 0.11, NewAmount: 80},
+			wantLine: "~ sell  offerID=1 price=0.10000000 -> 0.11000000 amount=100.00000000 -> 80.00000000",
+		}, {
+			name:     "modify buy is converted to base units",
+			mso:      buy(2, "4.0", "25.0"),
+			want:     OfferChange{Type: OfferChangeModify, IsSell: false, OfferID: 2, OldPrice: 0.05, OldAmount: 100, NewPrice: 0.04, NewAmount: 100},
+			wantLine: "~ buy   offerID=2 price=0.05000000 -> 0.04000000 amount=100.00000000 -> 100.00000000",
+		}, {
+			name:     "pruned delete",
+			mso:      sell(1, "0", "0.1"),
+			isPrune:  true,
+			want:     OfferChange{Type: OfferChangeDelete, IsSell: true, IsPrune: true, OfferID: 1, OldPrice: 0.1, OldAmount: 100},
+			wantLine: "- sell  offerID=1 price=0.10000000 amount=100.00000000 (pruned)",
+		},
+	}
+
+	for _, kase := range testCases {
+		t.Run(kase.name, func(t *testing.T) {
+			c, e := trader.makeOfferChange(kase.mso, existingOffers, kase.isPrune)
+			if !assert.NoError(t, e) {
+				return
+			}
+			assert.Equal(t, kase.want.Type, c.Type)
+			assert.Equal(t, kase.want.IsSell, c.IsSell)
+			assert.Equal(t, kase.want.IsPrune, c.IsPrune)
+			assert.Equal(t, kase.want.OfferID, c.OfferID)
+			assert.InDelta(t, kase.want.OldPrice, c.OldPrice, 1e-9)
+			assert.InDelta(t, kase.want.OldAmount, c.OldAmount, 1e-9)
+			assert.InDelta(t, kase.want.NewPrice, c.NewPrice, 1e-9)
+			assert.InDelta(t, kase.want.NewAmount, c.NewAmount, 1e-9)
+			assert.Equal(t, kase.wantLine, c.String())
+		})
+	}
+
+	_, e := trader.makeOfferChange(&txnbuild.ManageSellOffer{Selling: txnbuild.CreditAsset{Code: "EUR", Issuer: quote.Issuer}, Buying: utils.Asset2Asset(base), Amount: "1", Price: "1"}, existingOffers, false)
+	assert.Error(t, e)
+
+	plan := &Plan{Changes: []OfferChange{
+		{Type: OfferChangeCreate},
+		{Type: OfferChangeCreate},
+		{Type: OfferChangeModify},
+		{Type: OfferChangeDelete},
+	}}
+	numCreate, numModify, numDelete := plan.Counts()
+	assert.Equal(t, 2, numCreate)
+	assert.Equal(t, 1, numModify)
+	assert.Equal(t, 1, numDelete)
+}
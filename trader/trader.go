@@ -48,6 +48,9 @@ type Trader struct {
 	alert                          api.Alert
 	metricsTracker                 *plugins.MetricsTracker
 	startTime                      time.Time
+	orderTracker                   *plugins.OrderTracker       // can be nil
+	heartbeatWriter                *plugins.BotHeartbeatWriter // can be nil
+	protectiveOrders               *plugins.ProtectiveOrders   // can be nil
 
 	// initialized runtime vars
 	deleteCycles int64
@@ -87,6 +90,9 @@ func MakeTrader(
 	alert api.Alert,
 	metricsTracker *plugins.MetricsTracker,
 	startTime time.Time,
+	orderTracker *plugins.OrderTracker,
+	heartbeatWriter *plugins.BotHeartbeatWriter,
+	protectiveOrders *plugins.ProtectiveOrders,
 ) *Trader {
 	return &Trader{
 		api:                            api,
@@ -113,6 +119,9 @@ func MakeTrader(
 		alert:                          alert,
 		metricsTracker:                 metricsTracker,
 		startTime:                      startTime,
+		orderTracker:                   orderTracker,
+		heartbeatWriter:                heartbeatWriter,
+		protectiveOrders:               protectiveOrders,
 		// initialized runtime vars
 		deleteCycles: 0,
 	}
@@ -140,10 +149,32 @@ func (t *Trader) Start() {
 		}
 
 		currentUpdateTime := time.Now()
-		if updateRefTime.IsZero() || t.timeController.ShouldUpdate(updateRefTime, currentUpdateTime) {
+		shouldUpdate := updateRefTime.IsZero() || t.timeController.ShouldUpdate(updateRefTime, currentUpdateTime)
+		if shouldUpdate {
+			// protective orders keep running outside the trading window since they protect inventory that we already hold
+			t.runProtectiveOrders()
+		}
+		if shouldUpdate && !t.isTradingWindowOpen(currentUpdateTime) {
+			t.idleOutsideTradingWindow()
+			// the bot is alive while it idles, so keep its heartbeat fresh for the terminator
+			t.writeHeartbeat()
+			log.Println("----------------------------------------------------------------------------------------------------")
+			lastUpdateStartTime = currentUpdateTime
+			lastUpdateEndTime = time.Now()
+		} else if shouldUpdate {
 			updateResult := t.update()
 			millisForUpdate := time.Since(currentUpdateTime).Milliseconds()
 			log.Printf("time taken for update loop: %d millis\n", millisForUpdate)
+			summaryLine, e := plugins.MakeUpdateLoopSummaryLogLine(plugins.UpdateLoopSummary{
+				UpdateLoopResult: updateResult,
+				StartTime:        currentUpdateTime,
+				MillisForUpdate:  millisForUpdate,
+			})
+			if e != nil {
+				log.Printf("unable to make update loop summary log line: %s\n", e)
+			} else {
+				log.Println(summaryLine)
+			}
 			if shouldSendUpdateMetric(t.startTime, currentUpdateTime, t.metricsTracker.GetUpdateEventSentTime()) {
 				e := t.threadTracker.TriggerGoroutine(func(inputs []interface{}) {
 					e := t.metricsTracker.SendUpdateEvent(currentUpdateTime, updateResult, millisForUpdate)
@@ -156,6 +187,11 @@ func (t *Trader) Start() {
 				}
 			}
 
+			// a failed update does not refresh the heartbeat so the terminator deletes the offers of a bot that keeps failing
+			if updateResult.Success {
+				t.writeHeartbeat()
+			}
+
 			if t.fixedIterations != nil && updateResult.Success {
 				*t.fixedIterations = *t.fixedIterations - 1
 				if *t.fixedIterations <= 0 {
@@ -172,6 +208,9 @@ func (t *Trader) Start() {
 			lastUpdateStartTime = currentUpdateTime
 			// lastUpdateEndTime uses the real time.Now() because we want to capture the actual end time
 			lastUpdateEndTime = time.Now()
+		} else {
+			// the timeController skipped this cycle (e.g. waiting for an event) but the bot is still alive
+			t.writeHeartbeat()
 		}
 
 		if !t.sleepMode.shouldSleepAtBeginning() {
@@ -181,6 +220,44 @@ func (t *Trader) Start() {
 	}
 }
 
+// isTradingWindowOpen returns true unless the timeController restricts trading to scheduled windows that are closed at the given time
+func (t *Trader) isTradingWindowOpen(now time.Time) bool {
+	if tw, ok := t.timeController.(api.TradingWindow); ok {
+		return tw.IsTradingWindowOpen(now)
+	}
+	return true
+}
+
+// idleOutsideTradingWindow deletes all offers for the bot without running the strategy, this does not count towards the deleteCyclesThreshold
+func (t *Trader) idleOutsideTradingWindow() {
+	log.Printf("outside of trading window, idling\n")
+	sellingAOffers, buyingAOffers, e := t.getExistingOffers()
+	if e != nil {
+		log.Printf("unable to load offers to delete when outside of trading window, will try again in the next cycle: %s\n", e)
+		return
+	}
+
+	dOps := []txnbuild.Operation{}
+	dOps = append(dOps, t.sdex.DeleteAllOffers(sellingAOffers)...)
+	dOps = append(dOps, t.sdex.DeleteAllOffers(buyingAOffers)...)
+	log.Printf("created %d operations to delete offers outside of trading window\n", len(dOps))
+	if len(dOps) == 0 {
+		return
+	}
+
+	// to delete offers the submitMode doesn't matter, so use api.SubmitModeBoth as the default
+	t.recordSubmittedOps(dOps)
+	e = t.exchangeShim.SubmitOps(api.ConvertOperation2TM(dOps), api.SubmitModeBoth, nil)
+	if e != nil {
+		log.Printf("unable to delete offers when outside of trading window, will try again in the next cycle: %s\n", e)
+		return
+	}
+	t.setExistingOffers([]hProtocol.Offer{}, []hProtocol.Offer{})
+
+	// wait for the deletion to be submitted so we don't have inconsistent state reads in the next cycle
+	t.threadTracker.Wait()
+}
+
 func (t *Trader) doSleep(lastUpdateTime time.Time) {
 	sleepTime := t.timeController.SleepTime(lastUpdateTime)
 	log.Printf("sleeping for %s...\n", sleepTime)
@@ -302,6 +379,7 @@ func (t *Trader) synchronizeFetchBalancesOffersTrades() error {
 		log.Printf("synchronized state loading is disabled\n")
 		t.setBalances(baseBalance1, quoteBalance1)
 		t.setExistingOffers(sellingAOffers1, buyingAOffers1)
+		t.reconcileOrders(sellingAOffers1, buyingAOffers1)
 		return nil
 	}
 
@@ -340,6 +418,7 @@ func (t *Trader) synchronizeFetchBalancesOffersTrades() error {
 			// this is the only success case
 			t.setBalances(baseBalance1, quoteBalance1)
 			t.setExistingOffers(sellingAOffers1, buyingAOffers1)
+			t.reconcileOrders(sellingAOffers1, buyingAOffers1)
 			return nil
 		}
 		log.Printf("could not synchronize data in attempt %d of %d (1-indexed), trying again...\n", i+1, t.synchronizeStateLoadMaxRetries+1)
@@ -446,6 +525,7 @@ func (t *Trader) update() plugins.UpdateLoopResult {
 	log.Printf("created %d operations to prune excess offers\n", numPruneOps)
 	if numPruneOps > 0 {
 		// to prune/delete offers the submitMode doesn't matter, so use api.SubmitModeBoth as the default
+		t.recordSubmittedOps(api.ConvertTM2Operation(pruneOps))
 		e = t.exchangeShim.SubmitOps(pruneOps, api.SubmitModeBoth, nil)
 		if e != nil {
 			log.Println(e)
@@ -528,6 +608,7 @@ func (t *Trader) update() plugins.UpdateLoopResult {
 
 	log.Printf("created %d operations to update existing offers\n", len(ops))
 	if len(ops) > 0 {
+		t.recordSubmittedOps(ops)
 		e = t.exchangeShim.SubmitOps(api.ConvertOperation2TM(ops), t.submitMode, func(hash string, e error) {
 			// if there is an error we want it to count towards the delete cycles threshold, so run the check
 			if e != nil {
@@ -643,6 +724,60 @@ func (t *Trader) setExistingOffers(sellingAOffers []hProtocol.Offer, buyingAOffe
 	t.sellingAOffers, t.buyingAOffers = sellingAOffers, buyingAOffers
 }
 
+func (t *Trader) recordSubmittedOps(ops []txnbuild.Operation) {
+	if t.orderTracker == nil {
+		return
+	}
+	t.orderTracker.RecordSubmittedOps(ops, time.Now())
+}
+
+// writeHeartbeat is best-effort and only logs, a bot that cannot write its heartbeat will have its offers deleted by the terminator
+func (t *Trader) writeHeartbeat() {
+	if t.heartbeatWriter == nil {
+		return
+	}
+
+	e := t.heartbeatWriter.WriteHeartbeat(time.Now())
+	if e != nil {
+		log.Printf("unable to write bot heartbeat: %s\n", e)
+	}
+}
+
+// runProtectiveOrders is best-effort and only logs, triggered orders are surfaced through the alert by the protective orders themselves
+func (t *Trader) runProtectiveOrders() {
+	if t.protectiveOrders == nil {
+		return
+	}
+
+	e := t.protectiveOrders.Run()
+	if e != nil {
+		log.Printf("unable to run protective orders: %s\n", e)
+	}
+}
+
+// reconcileOrders is best-effort and only logs, we do not want the bot to stop trading if the orders table cannot be updated
+func (t *Trader) reconcileOrders(sellingAOffers []hProtocol.Offer, buyingAOffers []hProtocol.Offer) {
+	if t.orderTracker == nil {
+		return
+	}
+
+	r, e := t.orderTracker.Reconcile(sellingAOffers, buyingAOffers, time.Now())
+	if e != nil {
+		log.Printf("unable to reconcile orders: %s\n", e)
+		return
+	}
+	log.Printf("reconciled orders: %s\n", r)
+	if len(r.UnknownIDs) > 0 {
+		log.Printf("warning: found %d open orders that were not submitted by this bot: %v\n", len(r.UnknownIDs), r.UnknownIDs)
+	}
+	if len(r.LeakedIDs) > 0 {
+		log.Printf("warning: found %d orders that are still open after being cancelled: %v\n", len(r.LeakedIDs), r.LeakedIDs)
+	}
+	if len(r.OrphanedIDs) > 0 {
+		log.Printf("warning: found %d orders that were closed without being cancelled or fully filled: %v\n", len(r.OrphanedIDs), r.OrphanedIDs)
+	}
+}
+
 func countOfferChangeTypes(offers []*txnbuild.ManageSellOffer) (int /*numDelete*/, int /*numUpdate*/, int /*numCreate*/, error) {
 	numDelete, numUpdate, numCreate := 0, 0, 0
 	for i, o := range offers {
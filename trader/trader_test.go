@@ -2,15 +2,24 @@ package trader
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/nikhilsaraf/go-tools/multithreading"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/kelp/api"
 	"github.com/stellar/kelp/model"
+	"github.com/stellar/kelp/plugins"
+	"github.com/stellar/kelp/support/horizonfake"
+	"github.com/stellar/kelp/support/utils"
 )
 
 func TestIsStateSynchronized(t *testing.T) {
@@ -346,3 +355,168 @@ func createTestMSO(msoType string) *txnbuild.ManageSellOffer {
 	}
 	return &mso
 }
+
+const testBuySellConfig = `
+DATA_TYPE_A="fixed"
+DATA_FEED_A_URL="0.1"
+DATA_TYPE_B="fixed"
+DATA_FEED_B_URL="1.0"
+PRICE_TOLERANCE=0.001
+AMOUNT_TOLERANCE=0.001
+RATE_OFFSET_PERCENT=0.0
+RATE_OFFSET=0.0
+RATE_OFFSET_PERCENT_FIRST=true
+AMOUNT_OF_A_BASE=10.0
+[[LEVELS]]
+SPREAD=0.1
+AMOUNT=10.0
+`
+
+type testFillHandler struct {
+	trades []model.Trade
+}
+
+// HandleFill impl
+func (h *testFillHandler) HandleFill(trade model.Trade) error {
+	h.trades = append(h.trades, trade)
+	return nil
+}
+
+func TestUpdate_FakeHorizon(t *testing.T) {
+	dir, e := ioutil.TempDir("", "kelp_trader")
+	if !assert.NoError(t, e) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	stratConfigPath := filepath.Join(dir, "buysell.cfg")
+	if !assert.NoError(t, ioutil.WriteFile(stratConfigPath, []byte(testBuySellConfig), 0600)) {
+		return
+	}
+
+	s := horizonfake.MakeServer(network.TestNetworkPassphrase)
+	defer s.Close()
+	issuer := keypair.MustRandom()
+	trading := keypair.MustRandom()
+	maker := keypair.MustRandom()
+	usd := hProtocol.Asset{Type: "credit_alphanum4", Code: "USD", Issuer: issuer.Address()}
+	s.AddAccount(issuer.Address(), "100")
+	for _, kp := range []*keypair.Full{trading, maker} {
+		s.AddAccount(kp.Address(), "1000")
+		s.AddTrustline(kp.Address(), usd, "10000")
+		s.SetBalance(kp.Address(), usd, "100")
+	}
+
+	client := s.Client()
+	threadTracker := multithreading.MakeThreadTracker()
+	ieif := plugins.MakeIEIF(true)
+	pair := &model.TradingPair{Base: model.XLM, Quote: model.USD}
+	sdex := plugins.MakeSDEX(
+		client,
+		ieif,
+		nil,
+		"",
+		trading.Seed(),
+		"",
+		trading.Address(),
+		network.TestNetworkPassphrase,
+		threadTracker,
+		0,
+		0,
+		false,
+		pair,
+		map[model.Asset]hProtocol.Asset{model.XLM: utils.NativeAsset, model.USD: usd},
+		plugins.SdexFixedFeeFn(100),
+		false,
+		nil,
+		"",
+	)
+	strategy, e := plugins.MakeStrategy(sdex, sdex, sdex, ieif, pair, &utils.NativeAsset, &usd, "", "buysell", stratConfigPath, false, true, nil, nil, nil, nil)
+	if !assert.NoError(t, e) {
+		return
+	}
+	fillHandler := &testFillHandler{}
+	fillTracker := plugins.MakeFillTracker(pair, threadTracker, sdex, 0, 0, nil)
+	fillTracker.RegisterHandler(fillHandler)
+
+	trader := MakeTrader(
+		client,
+		ieif,
+		utils.NativeAsset,
+		usd,
+		nil,
+		nil,
+		trading.Address(),
+		sdex,
+		sdex,
+		strategy,
+		nil,
+		SleepModeEnd,
+		true,
+		3,
+		fillTracker,
+		-1,
+		api.SubmitModeBoth,
+		[]plugins.SubmitFilter{},
+		threadTracker,
+		nil,
+		nil,
+		nil,
+		nil,
+		time.Now(),
+		nil,
+		nil,
+		nil,
+	)
+	update := func() plugins.UpdateLoopResult {
+		r := trader.update()
+		// offers are submitted asynchronously
+		threadTracker.Wait()
+		return r
+	}
+
+	// the first cycle places a bid and an ask around the mid price of 0.1
+	r := update()
+	assert.True(t, r.Success)
+	assert.Equal(t, 2, r.NumUpdateOpsCreate)
+	offers, e := sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	assert.Equal(t, 2, len(offers))
+	assert.Equal(t, 0, len(fillHandler.trades))
+
+	// the maker buys 40 of the 100 XLM offered at 0.11
+	_, e = s.PlaceOffer(maker.Address(), usd, utils.NativeAsset, "4.4", "9")
+	if !assert.NoError(t, e) {
+		return
+	}
+
+	// the second cycle sees the fill and replenishes the ask
+	r = update()
+	assert.True(t, r.Success)
+	assert.Equal(t, 1, r.NumUpdateOpsUpdate)
+	assert.Equal(t, 0, r.NumUpdateOpsCreate)
+	if assert.Equal(t, 1, len(fillHandler.trades)) {
+		fill := fillHandler.trades[0]
+		assert.Equal(t, model.OrderActionSell, fill.OrderAction)
+		assert.Equal(t, 0.11, fill.Price.AsFloat())
+		assert.Equal(t, 40.0, fill.Volume.AsFloat())
+	}
+	offers, e = sdex.LoadOffersHack()
+	if !assert.NoError(t, e) {
+		return
+	}
+	for _, o := range offers {
+		if o.Selling.Type == utils.Native {
+			assert.Equal(t, "100.0000000", o.Amount)
+		}
+	}
+
+	// nothing changed so the third cycle does not submit anything
+	numTransactions := s.CallCount("transactions")
+	r = update()
+	assert.True(t, r.Success)
+	assert.Equal(t, 0, r.NumUpdateOpsCreate+r.NumUpdateOpsUpdate+r.NumUpdateOpsDelete+r.NumPruneOps)
+	assert.Equal(t, numTransactions, s.CallCount("transactions"))
+	assert.Equal(t, 1, len(fillHandler.trades))
+}